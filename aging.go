@@ -0,0 +1,212 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// agingOldest is how many of the oldest complaints each bucket lists
+const agingOldest = 5
+
+// agingUnknown is the bucket of complaints whose age cannot be told: their
+// CreatedAt is missing, does not parse or lies in the future
+const agingUnknown = "unknown"
+
+// agingBuckets are the age ranges of /agingReport, youngest first. A
+// complaint falls in the first bucket whose limit its age is below; the
+// last bucket has no limit.
+var agingBuckets = []struct {
+	name  string
+	limit time.Duration
+}{
+	{"<24h", 24 * time.Hour},
+	{"1-3d", 3 * 24 * time.Hour},
+	{"3-7d", 7 * 24 * time.Hour},
+	{"7-30d", 30 * 24 * time.Hour},
+	{">30d", 0},
+}
+
+type AgingReportRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+// AgingBucket counts the open complaints of one age range and lists the
+// oldest of them. Complaints of unknown age are listed by ID.
+type AgingBucket struct {
+	Bucket string           `json:"bucket"`
+	Count  int              `json:"count"`
+	Oldest []StaleComplaint `json:"oldest"`
+}
+
+// AgingGroup is the aging of the open complaints of one priority or
+// department
+type AgingGroup struct {
+	Name    string        `json:"name"`
+	Open    int           `json:"open"`
+	Buckets []AgingBucket `json:"buckets"`
+}
+
+type AgingReport struct {
+	GeneratedAt  string        `json:"generated_at"`
+	Open         int           `json:"open"`
+	Buckets      []AgingBucket `json:"buckets"`
+	ByPriority   []AgingGroup  `json:"by_priority"`
+	ByDepartment []AgingGroup  `json:"by_department"` // most open first
+}
+
+// agedComplaint is an open complaint with its age, or ok false when its
+// age is unknown
+type agedComplaint struct {
+	summary StaleComplaint
+	age     time.Duration
+	ok      bool
+}
+
+// agingBucketOf names the bucket of complaint
+func agingBucketOf(complaint agedComplaint) string {
+	if !complaint.ok {
+		return agingUnknown
+	}
+	for _, bucket := range agingBuckets {
+		if bucket.limit == 0 || complaint.age < bucket.limit {
+			return bucket.name
+		}
+	}
+	return agingUnknown
+}
+
+// ageComplaints buckets complaints, listing every bucket even when empty
+func ageComplaints(complaints []agedComplaint) []AgingBucket {
+	names := make([]string, 0, len(agingBuckets)+1)
+	for _, bucket := range agingBuckets {
+		names = append(names, bucket.name)
+	}
+	names = append(names, agingUnknown)
+
+	members := make(map[string][]agedComplaint)
+	for _, complaint := range complaints {
+		name := agingBucketOf(complaint)
+		members[name] = append(members[name], complaint)
+	}
+
+	buckets := make([]AgingBucket, 0, len(names))
+	for _, name := range names {
+		list := members[name]
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].age != list[j].age {
+				return list[i].age > list[j].age
+			}
+			return list[i].summary.ID < list[j].summary.ID
+		})
+		bucket := AgingBucket{Bucket: name, Count: len(list), Oldest: []StaleComplaint{}}
+		for i := 0; i < len(list) && i < agingOldest; i++ {
+			bucket.Oldest = append(bucket.Oldest, list[i].summary)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// buildAgingReport buckets the open complaints viewer's organization may see
+// by their age at now
+func buildAgingReport(viewer *User, now time.Time) AgingReport {
+	var open []agedComplaint
+	byPriority := make(map[string][]agedComplaint)
+	byDepartment := make(map[string][]agedComplaint)
+
+	storage.mutex.RLock()
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted || !isOpen(complaint) || !canSeeOrg(viewer, complaint.OrgID) {
+			continue
+		}
+		aged := agedComplaint{summary: StaleComplaint{
+			ID:        complaint.ID,
+			Reference: complaint.Reference,
+			Title:     complaint.Title,
+			UserName:  complaint.UserName,
+			CreatedAt: complaint.CreatedAt,
+		}}
+		if created, err := parseTimestamp(complaint.CreatedAt); err == nil && !created.After(now) {
+			aged.age, aged.ok = now.Sub(created), true
+			aged.summary.AgeDays = roundTo(aged.age.Hours()/24, 1)
+		}
+
+		open = append(open, aged)
+		// Complaints stored before priorities existed count as medium
+		priority := complaint.Priority
+		if priority == "" {
+			priority = priorityMedium
+		}
+		byPriority[priority] = append(byPriority[priority], aged)
+		department := complaint.Department
+		if department == "" {
+			department = generalDepartment
+		}
+		byDepartment[department] = append(byDepartment[department], aged)
+	}
+	// Departments without open complaints are listed too
+	for _, department := range storage.departments {
+		if _, exists := byDepartment[department.Name]; !exists {
+			byDepartment[department.Name] = nil
+		}
+	}
+	storage.mutex.RUnlock()
+
+	report := AgingReport{
+		GeneratedAt:  now.Local().Format(timestampLayout),
+		Open:         len(open),
+		Buckets:      ageComplaints(open),
+		ByPriority:   []AgingGroup{},
+		ByDepartment: []AgingGroup{},
+	}
+	for _, priority := range priorities {
+		list := byPriority[priority]
+		report.ByPriority = append(report.ByPriority, AgingGroup{Name: priority, Open: len(list), Buckets: ageComplaints(list)})
+	}
+	for name, list := range byDepartment {
+		report.ByDepartment = append(report.ByDepartment, AgingGroup{Name: name, Open: len(list), Buckets: ageComplaints(list)})
+	}
+	sort.Slice(report.ByDepartment, func(i, j int) bool {
+		a, b := report.ByDepartment[i], report.ByDepartment[j]
+		if a.Open != b.Open {
+			return a.Open > b.Open
+		}
+		return a.Name < b.Name
+	})
+	return report
+}
+
+// /agingReport - How long open complaints have been waiting (admin only)
+func agingReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req AgingReportRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Aging report generated successfully",
+		Data:    buildAgingReport(user, clock.Now()),
+	})
+}
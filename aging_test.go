@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAgingReport(t *testing.T) {
+	srv := newTestServer(t)
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.Local)
+	useFakeClock(t, now)
+
+	ages := []time.Duration{
+		time.Hour,
+		24*time.Hour - time.Second,
+		24 * time.Hour, // a bucket's lower bound belongs to it
+		2 * 24 * time.Hour,
+		5 * 24 * time.Hour,
+		7 * 24 * time.Hour,
+		40 * 24 * time.Hour,
+		100 * 24 * time.Hour,
+	}
+	for days := 10; days <= 16; days++ {
+		ages = append(ages, time.Duration(days)*24*time.Hour)
+	}
+	fixture := Fixture{Users: []FixtureUser{{ID: 1, Name: "Aging User", Email: "aging@example.com"}}}
+	for i, age := range ages {
+		fixture.Complaints = append(fixture.Complaints, FixtureComplaint{
+			UserID: 1, Title: "Complaint " + strconv.Itoa(i), Summary: "Aging", Rating: 5, Priority: priorityMedium,
+			CreatedAt: now.Add(-age).Format(timestampLayout),
+		})
+	}
+	// Neither resolved nor garbled complaints have a usable age
+	fixture.Complaints = append(fixture.Complaints,
+		FixtureComplaint{UserID: 1, Title: "Resolved", Summary: "Aging", Rating: 5, CreatedAt: now.Add(-50 * 24 * time.Hour).Format(timestampLayout), IsResolved: true},
+		FixtureComplaint{UserID: 1, Title: "Garbled", Summary: "Aging", Rating: 5},
+		FixtureComplaint{UserID: 1, Title: "From the future", Summary: "Aging", Rating: 5},
+	)
+	if _, err := loadFixture(fixture); err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	storage.mutex.Lock()
+	storage.complaints[len(ages)+2].CreatedAt = "last tuesday"
+	storage.complaints[len(ages)+3].CreatedAt = now.Add(time.Hour).Format(timestampLayout)
+	storage.complaints[1].Priority = priorityCritical
+	storage.complaints[8].Department = "Facilities"
+	storage.mutex.Unlock()
+
+	status, resp := postJSON(t, srv, "/agingReport", AgingReportRequest{SecretCode: adminSecret})
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+	}
+	var report AgingReport
+	resp.decode(t, &report)
+
+	counts := func(buckets []AgingBucket) map[string]int {
+		result := make(map[string]int)
+		for _, bucket := range buckets {
+			result[bucket.Bucket] = bucket.Count
+		}
+		return result
+	}
+	want := map[string]int{"<24h": 2, "1-3d": 2, "3-7d": 1, "7-30d": 8, ">30d": 2, agingUnknown: 2}
+	if got := counts(report.Buckets); len(got) != len(want) || report.Open != 17 {
+		t.Fatalf("Expected buckets %v of 17 open, got %v of %d", want, got, report.Open)
+	} else {
+		for name, count := range want {
+			if got[name] != count {
+				t.Errorf("Bucket %s: expected %d, got %d", name, count, got[name])
+			}
+		}
+	}
+
+	t.Run("Oldest Per Bucket", func(t *testing.T) {
+		month := report.Buckets[3]
+		if len(month.Oldest) != agingOldest {
+			t.Fatalf("Expected the %d oldest, got %d", agingOldest, len(month.Oldest))
+		}
+		for i, days := range []float64{16, 15, 14, 13, 12} {
+			if month.Oldest[i].AgeDays != days {
+				t.Errorf("Position %d: expected %v days old, got %v", i, days, month.Oldest[i].AgeDays)
+			}
+		}
+		if unknown := report.Buckets[5]; len(unknown.Oldest) != 2 || unknown.Oldest[0].Title != "Garbled" {
+			t.Errorf("Expected both complaints of unknown age listed, got %+v", unknown.Oldest)
+		}
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		if critical := report.ByPriority[0]; critical.Name != priorityCritical || critical.Open != 1 || counts(critical.Buckets)["<24h"] != 1 {
+			t.Errorf("Expected one young critical complaint, got %+v", critical)
+		}
+		var facilities *AgingGroup
+		for i := range report.ByDepartment {
+			if report.ByDepartment[i].Name == "Facilities" {
+				facilities = &report.ByDepartment[i]
+			}
+		}
+		if facilities == nil || facilities.Open != 1 || counts(facilities.Buckets)[">30d"] != 1 {
+			t.Errorf("Expected one Facilities complaint over 30 days old, got %+v", facilities)
+		}
+	})
+
+	_, code := registerTestUser(t, srv, "Regular User", "regular@example.com")
+	if status, _ := postJSON(t, srv, "/agingReport", AgingReportRequest{SecretCode: code}); status != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin, got %d", status)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAnalyticsWeeks is how many weeks /analytics/ratings covers when
+// no start date is given
+const defaultAnalyticsWeeks = 8
+
+type RatingAnalyticsRequest struct {
+	SecretCode    string `json:"secret_code"`
+	From          string `json:"from,omitempty"`            // YYYY-MM-DD, UTC; its ISO week is the first
+	To            string `json:"to,omitempty"`              // YYYY-MM-DD, UTC; its ISO week is the last; defaults to today
+	RatingsByWeek bool   `json:"ratings_by_week,omitempty"` // add a rating histogram to every week
+	Format        string `json:"format,omitempty"`          // json (default) or csv
+
+	IncludeArchived bool `json:"include_archived,omitempty"`
+}
+
+// RatingCount is how many complaints were submitted with a rating
+type RatingCount struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count"`
+}
+
+// WeekTrend is the complaints submitted in one ISO week, and how they
+// compare with those of the week before
+type WeekTrend struct {
+	Week                 string        `json:"week"`  // ISO week, e.g. 2024-W23
+	Start                string        `json:"start"` // its Monday
+	Created              int           `json:"created"`
+	Resolved             int           `json:"resolved"`        // of those created, resolved since
+	ResolutionRate       float64       `json:"resolution_rate"` // resolved over created; 0 without complaints
+	CreatedChange        int           `json:"created_change"`
+	ResolutionRateChange float64       `json:"resolution_rate_change"`
+	Ratings              []RatingCount `json:"ratings,omitempty"` // with ratings_by_week only
+}
+
+// RatingAnalytics counts ratings on the configured scale. Complaints rated
+// on another scale are counted at the nearest rating on it.
+type RatingAnalytics struct {
+	From    string        `json:"from"` // Monday of the first week
+	To      string        `json:"to"`   // Sunday of the last week
+	Scale   RatingScale   `json:"scale"`
+	Total   int           `json:"total"`
+	Ratings []RatingCount `json:"ratings"` // every complaint, whenever submitted
+	Weeks   []WeekTrend   `json:"weeks"`   // oldest first, empty weeks included
+}
+
+// isoWeekStart is the Monday, in UTC, of the ISO week t falls in
+func isoWeekStart(t time.Time) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+	return day.AddDate(0, 0, -offset)
+}
+
+// isoWeekName formats the ISO week starting on monday as YYYY-Www
+func isoWeekName(monday time.Time) string {
+	year, week := monday.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+func newRatingHistogram(scale RatingScale) []RatingCount {
+	histogram := make([]RatingCount, 0, scale.Max-scale.Min+1)
+	for rating := scale.Min; rating <= scale.Max; rating++ {
+		histogram = append(histogram, RatingCount{Rating: rating})
+	}
+	return histogram
+}
+
+// countRating adds complaint's rating to histogram, which is on the
+// configured scale
+func countRating(histogram []RatingCount, complaint *Complaint) {
+	histogram[ratingOnCurrentScale(complaint)-histogram[0].Rating].Count++
+}
+
+// buildRatingAnalytics computes the analytics of the complaints viewer's
+// organization may see, archived ones only if includeArchived, for the ISO
+// weeks from the one containing from to the one containing to
+func buildRatingAnalytics(viewer *User, from, to time.Time, ratingsByWeek, includeArchived bool) RatingAnalytics {
+	first, last := isoWeekStart(from), isoWeekStart(to)
+	numWeeks := int(last.Sub(first).Hours()/24)/7 + 1
+	scale := currentRatingScale()
+	analytics := RatingAnalytics{
+		From:    first.Format(dateLayout),
+		To:      last.AddDate(0, 0, 6).Format(dateLayout),
+		Scale:   scale,
+		Ratings: newRatingHistogram(scale),
+	}
+
+	// The week before the first is counted too, for the first week's change
+	weeks := make([]WeekTrend, numWeeks+1)
+	for i := range weeks {
+		monday := first.AddDate(0, 0, 7*(i-1))
+		weeks[i].Week = isoWeekName(monday)
+		weeks[i].Start = monday.Format(dateLayout)
+		if ratingsByWeek {
+			weeks[i].Ratings = newRatingHistogram(scale)
+		}
+	}
+	before := first.AddDate(0, 0, -7)
+
+	storage.mutex.RLock()
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted || (isArchived(complaint) && !includeArchived) || !canSeeOrg(viewer, complaint.OrgID) {
+			continue
+		}
+		analytics.Total++
+		countRating(analytics.Ratings, complaint)
+
+		created, err := parseTimestamp(complaint.CreatedAt)
+		if err != nil {
+			continue
+		}
+		monday := isoWeekStart(created)
+		if monday.Before(before) || monday.After(last) {
+			continue
+		}
+		week := &weeks[int(monday.Sub(before).Hours()/24)/7]
+		week.Created++
+		if complaint.IsResolved {
+			week.Resolved++
+		}
+		if ratingsByWeek {
+			countRating(week.Ratings, complaint)
+		}
+	}
+	storage.mutex.RUnlock()
+
+	for i := range weeks {
+		if weeks[i].Created > 0 {
+			weeks[i].ResolutionRate = roundTo(float64(weeks[i].Resolved)/float64(weeks[i].Created), 3)
+		}
+		if i > 0 {
+			weeks[i].CreatedChange = weeks[i].Created - weeks[i-1].Created
+			weeks[i].ResolutionRateChange = roundTo(weeks[i].ResolutionRate-weeks[i-1].ResolutionRate, 3)
+		}
+	}
+	analytics.Weeks = weeks[1:]
+	return analytics
+}
+
+// writeRatingAnalyticsCSV renders the analytics as two blank-line separated
+// CSV tables: the weeks, then the rating histogram
+func writeRatingAnalyticsCSV(w http.ResponseWriter, analytics RatingAnalytics) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="ratings-%s-%s.csv"`, analytics.From, analytics.To))
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	header := []string{"week", "start", "created", "resolved", "resolution_rate", "created_change", "resolution_rate_change"}
+	byWeek := len(analytics.Weeks) > 0 && analytics.Weeks[0].Ratings != nil
+	if byWeek {
+		for rating := analytics.Scale.Min; rating <= analytics.Scale.Max; rating++ {
+			header = append(header, fmt.Sprintf("rating_%d", rating))
+		}
+	}
+	out.Write(header)
+	for _, week := range analytics.Weeks {
+		row := []string{
+			week.Week, week.Start, strconv.Itoa(week.Created), strconv.Itoa(week.Resolved),
+			strconv.FormatFloat(week.ResolutionRate, 'f', 3, 64), strconv.Itoa(week.CreatedChange),
+			strconv.FormatFloat(week.ResolutionRateChange, 'f', 3, 64),
+		}
+		for _, count := range week.Ratings {
+			row = append(row, strconv.Itoa(count.Count))
+		}
+		out.Write(row)
+	}
+	out.Write(nil)
+	out.Write([]string{"rating", "count"})
+	for _, count := range analytics.Ratings {
+		out.Write([]string{strconv.Itoa(count.Rating), strconv.Itoa(count.Count)})
+	}
+	out.Flush()
+}
+
+// /analytics/ratings - Rating histogram and weekly volume and resolution
+// trends (admin only)
+func ratingAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RatingAnalyticsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.Format != "" && req.Format != "json" && req.Format != "csv" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Format must be json or csv")
+		return
+	}
+
+	now := clock.Now()
+	from, to, err := parseReportRange(ReportRequest{From: req.From, To: req.To}, now)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	if req.From == "" {
+		from = to.AddDate(0, 0, -7*(defaultAnalyticsWeeks-1))
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	analytics := buildRatingAnalytics(user, from, to, req.RatingsByWeek, req.IncludeArchived)
+
+	if req.Format == "csv" {
+		writeRatingAnalyticsCSV(w, analytics)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Rating analytics generated successfully",
+		Data:    analytics,
+	})
+}
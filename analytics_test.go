@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestISOWeeks(t *testing.T) {
+	tests := []struct {
+		at     time.Time
+		monday string
+		name   string
+	}{
+		{time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), "2024-05-06", "2024-W19"},
+		{time.Date(2024, 5, 12, 23, 59, 0, 0, time.UTC), "2024-05-06", "2024-W19"},
+		{time.Date(2024, 5, 13, 1, 0, 0, 0, time.FixedZone("CEST", 2*3600)), "2024-05-06", "2024-W19"}, // still Sunday in UTC
+		{time.Date(2021, 1, 3, 12, 0, 0, 0, time.UTC), "2020-12-28", "2020-W53"},
+		{time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC), "2024-12-30", "2025-W01"},
+	}
+	for _, tc := range tests {
+		monday := isoWeekStart(tc.at)
+		if got := monday.Format(dateLayout); got != tc.monday {
+			t.Errorf("isoWeekStart(%v) = %s, want %s", tc.at, got, tc.monday)
+		}
+		if got := isoWeekName(monday); got != tc.name {
+			t.Errorf("isoWeekName(%s) = %s, want %s", tc.monday, got, tc.name)
+		}
+	}
+}
+
+func TestRatingAnalytics(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	fake := useFakeClock(t, time.Date(2024, 5, 5, 23, 0, 0, 0, time.UTC)) // Sunday of 2024-W18
+	_, code := registerTestUser(t, srv, "Analytics User", "analytics@example.com")
+
+	moveTo := func(month time.Month, day, hour int) {
+		fake.Advance(time.Date(2024, month, day, hour, 0, 0, 0, time.UTC).Sub(clock.Now()))
+	}
+	resolve := func(id int) {
+		t.Helper()
+		if status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: id}); status != http.StatusOK {
+			t.Fatalf("Resolve %d: expected 200, got %d (%s)", id, status, resp.Error)
+		}
+	}
+
+	// 2024-W18, the week before the range
+	submitTestComplaint(t, srv, code, "Sunday night", 2)
+	// 2024-W19: three complaints, one resolved, one deleted
+	moveTo(time.May, 6, 9)
+	resolved := submitTestComplaint(t, srv, code, "Heating", 5)
+	submitTestComplaint(t, srv, code, "Parking", 3)
+	submitTestComplaint(t, srv, code, "Printer", 7)
+	deleted := submitTestComplaint(t, srv, code, "Mistake", 9)
+	postJSON(t, srv, "/deleteComplaint", DeleteComplaintRequest{SecretCode: code, ComplaintID: deleted.ID})
+	resolve(resolved.ID)
+	// 2024-W20 stays empty; 2024-W21: two complaints, both resolved
+	moveTo(time.May, 20, 9)
+	for _, rating := range []int{10, 7} {
+		resolve(submitTestComplaint(t, srv, code, "Week 21", rating).ID)
+	}
+	// 2024-W24, after the range
+	moveTo(time.June, 10, 9)
+	submitTestComplaint(t, srv, code, "Later", 1)
+
+	request := RatingAnalyticsRequest{SecretCode: adminSecret, From: "2024-05-08", To: "2024-05-29", RatingsByWeek: true}
+
+	t.Run("Series", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/analytics/ratings", request)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var analytics RatingAnalytics
+		resp.decode(t, &analytics)
+
+		if analytics.From != "2024-05-06" || analytics.To != "2024-06-02" || analytics.Total != 7 {
+			t.Errorf("Expected whole weeks 2024-05-06 to 2024-06-02 and 7 complaints, got %s to %s and %d", analytics.From, analytics.To, analytics.Total)
+		}
+		wantRatings := []int{1, 1, 1, 0, 1, 0, 2, 0, 0, 1}
+		for i, count := range analytics.Ratings {
+			if count.Rating != i+1 || count.Count != wantRatings[i] {
+				t.Errorf("Expected %d complaints rated %d, got %+v", wantRatings[i], i+1, count)
+			}
+		}
+
+		histogram := func(counts map[int]int) []RatingCount {
+			h := newRatingHistogram(legacyRatingScale)
+			for rating, count := range counts {
+				h[rating-1].Count = count
+			}
+			return h
+		}
+		want := []WeekTrend{
+			{Week: "2024-W19", Start: "2024-05-06", Created: 3, Resolved: 1, ResolutionRate: 0.333, CreatedChange: 2, ResolutionRateChange: 0.333, Ratings: histogram(map[int]int{3: 1, 5: 1, 7: 1})},
+			{Week: "2024-W20", Start: "2024-05-13", CreatedChange: -3, ResolutionRateChange: -0.333, Ratings: histogram(nil)},
+			{Week: "2024-W21", Start: "2024-05-20", Created: 2, Resolved: 2, ResolutionRate: 1, CreatedChange: 2, ResolutionRateChange: 1, Ratings: histogram(map[int]int{7: 1, 10: 1})},
+			{Week: "2024-W22", Start: "2024-05-27", CreatedChange: -2, ResolutionRateChange: -1, Ratings: histogram(nil)},
+		}
+		if !reflect.DeepEqual(analytics.Weeks, want) {
+			t.Errorf("Expected weeks\n%+v\ngot\n%+v", want, analytics.Weeks)
+		}
+	})
+
+	t.Run("Default Range", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/analytics/ratings", RatingAnalyticsRequest{SecretCode: adminSecret})
+		var analytics RatingAnalytics
+		resp.decode(t, &analytics)
+		if len(analytics.Weeks) != defaultAnalyticsWeeks || analytics.Weeks[defaultAnalyticsWeeks-1].Week != "2024-W24" {
+			t.Fatalf("Expected %d weeks up to 2024-W24, got %+v", defaultAnalyticsWeeks, analytics.Weeks)
+		}
+		if analytics.Weeks[0].Ratings != nil {
+			t.Error("Expected no weekly ratings unless asked for")
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		httpResp, err := http.Post(srv.URL+"/analytics/ratings", "application/json",
+			strings.NewReader(`{"secret_code":"ADMIN_SECRET_123","from":"2024-05-08","to":"2024-05-29","ratings_by_week":true,"format":"csv"}`))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != http.StatusOK || !strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/csv") {
+			t.Fatalf("Expected 200 text/csv, got %d %q", httpResp.StatusCode, httpResp.Header.Get("Content-Type"))
+		}
+		data, _ := io.ReadAll(httpResp.Body)
+		body := string(data)
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		wantLines := []string{
+			"week,start,created,resolved,resolution_rate,created_change,resolution_rate_change,rating_1,rating_2,rating_3,rating_4,rating_5,rating_6,rating_7,rating_8,rating_9,rating_10",
+			"2024-W19,2024-05-06,3,1,0.333,2,0.333,0,0,1,0,1,0,1,0,0,0",
+			"2024-W20,2024-05-13,0,0,0.000,-3,-0.333,0,0,0,0,0,0,0,0,0,0",
+			"2024-W21,2024-05-20,2,2,1.000,2,1.000,0,0,0,0,0,0,1,0,0,1",
+			"2024-W22,2024-05-27,0,0,0.000,-2,-1.000,0,0,0,0,0,0,0,0,0,0",
+			"",
+			"rating,count",
+			"1,1",
+		}
+		for i, want := range wantLines {
+			if i >= len(lines) || lines[i] != want {
+				t.Fatalf("Line %d: expected %q, got\n%s", i+1, want, body)
+			}
+		}
+	})
+
+	t.Run("Admins Only", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/analytics/ratings", RatingAnalyticsRequest{SecretCode: code}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a user, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/analytics/ratings", RatingAnalyticsRequest{SecretCode: adminSecret, From: "2024-06-01", To: "2024-05-01"}); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a reversed range, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	maxAnnouncementTitleLength = 200
+	maxAnnouncementBodyLength  = 2000
+)
+
+// maxAnnouncementRecipients bounds the inboxes one announcement is sent to;
+// the users with the lowest IDs get it
+const maxAnnouncementRecipients = 10000
+
+// announcementBatchSize is how many inboxes a fan-out fills per hold of
+// storage.mutex, so a large organization does not stall other requests
+const announcementBatchSize = 200
+
+// Announcement is a message from the admins to every user of an
+// organization, shown until it expires or is deleted
+type Announcement struct {
+	ID            int    `json:"id"`
+	OrgID         int    `json:"org_id"`
+	Title         string `json:"title"`
+	Body          string `json:"body"`
+	CreatedBy     int    `json:"created_by"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at,omitempty"`     // never expires when empty
+	NotifiedUsers int    `json:"notified_users,omitempty"` // inboxes it was sent to
+
+	expires time.Time
+}
+
+type CreateAnnouncementRequest struct {
+	SecretCode     string `json:"secret_code"`
+	Title          string `json:"title"`
+	Body           string `json:"body"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"` // 0 for no expiry
+	NotifyUsers    bool   `json:"notify_users,omitempty"`     // also put it in every user's inbox
+	OrgID          int    `json:"org_id,omitempty"`           // super-admins only; the caller's organization when left out
+}
+
+type DeleteAnnouncementRequest struct {
+	SecretCode     string `json:"secret_code"`
+	AnnouncementID int    `json:"announcement_id"`
+}
+
+type GetAnnouncementsRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+// announcementFanOuts tracks the goroutines filling inboxes, so shutdown
+// and tests can wait for them
+var announcementFanOuts sync.WaitGroup
+
+func (a *Announcement) isActive(now time.Time) bool {
+	return a.expires.IsZero() || now.Before(a.expires)
+}
+
+// activeAnnouncements returns the unexpired announcements of orgID, newest
+// first. Callers must hold storage.mutex.
+func activeAnnouncements(orgID int) []Announcement {
+	now := clock.Now()
+	active := []Announcement{}
+	for _, announcement := range storage.announcements {
+		if announcement.OrgID == orgID && announcement.isActive(now) {
+			active = append(active, *announcement)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ID > active[j].ID })
+	return active
+}
+
+// announcementRecipients lists the users announcement is sent to: everyone
+// active in its organization but its author, by ID, up to
+// maxAnnouncementRecipients. Callers must hold storage.mutex.
+func announcementRecipients(announcement *Announcement) []int {
+	var recipients []int
+	for _, user := range storage.users {
+		if user.OrgID == announcement.OrgID && user.ID != announcement.CreatedBy && user.IsActive {
+			recipients = append(recipients, user.ID)
+		}
+	}
+	sort.Ints(recipients)
+	if len(recipients) > maxAnnouncementRecipients {
+		recipients = recipients[:maxAnnouncementRecipients]
+	}
+	return recipients
+}
+
+// fanOutAnnouncement puts announcement in the inboxes of recipients in the
+// background, announcementBatchSize at a time. It stops early if the
+// announcement is deleted or storage is replaced by a restore.
+func fanOutAnnouncement(announcement *Announcement, recipients []int) {
+	announcementFanOuts.Add(1)
+	go func() {
+		defer announcementFanOuts.Done()
+		for start := 0; start < len(recipients); start += announcementBatchSize {
+			end := min(start+announcementBatchSize, len(recipients))
+			storage.mutex.Lock()
+			if storage.announcements[announcement.ID] != announcement {
+				storage.mutex.Unlock()
+				return
+			}
+			for _, userID := range recipients[start:end] {
+				if _, active := activeUser(userID); active {
+					addNotification(Notification{
+						UserID:         userID,
+						Type:           notificationAnnouncement,
+						Message:        "Announcement: " + announcement.Title,
+						AnnouncementID: announcement.ID,
+					})
+				}
+			}
+			storage.mutex.Unlock()
+		}
+	}()
+}
+
+// /createAnnouncement - Show a message to every user of an organization
+// (admin only)
+func createAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req CreateAnnouncementRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.Title = sanitizeText(req.Title, false)
+	req.Body = sanitizeText(req.Body, true)
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.required("title", req.Title)
+	v.maxRunes("title", req.Title, maxAnnouncementTitleLength)
+	v.required("body", req.Body)
+	v.maxRunes("body", req.Body, maxAnnouncementBodyLength)
+	if req.ExpiresInHours < 0 {
+		v.add("expires_in_hours", "must not be negative")
+	}
+	if req.OrgID < 0 {
+		v.add("org_id", "must not be negative")
+	}
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	orgID, apiErr := ruleOrganization(user, req.OrgID)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	storage.announceIDGen++
+	announcement := &Announcement{
+		ID:        storage.announceIDGen,
+		OrgID:     orgID,
+		Title:     req.Title,
+		Body:      req.Body,
+		CreatedBy: user.ID,
+		CreatedAt: getCurrentTime(),
+	}
+	if req.ExpiresInHours > 0 {
+		announcement.expires = clock.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		announcement.ExpiresAt = announcement.expires.Local().Format(timestampLayout)
+	}
+	if req.NotifyUsers {
+		recipients := announcementRecipients(announcement)
+		announcement.NotifiedUsers = len(recipients)
+		fanOutAnnouncement(announcement, recipients)
+	}
+	storage.announcements[announcement.ID] = announcement
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Announcement created successfully",
+		Data:    *announcement,
+	})
+}
+
+// /deleteAnnouncement - Take an announcement down, along with the inbox
+// entries it made (admin only)
+func deleteAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req DeleteAnnouncementRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("announcement_id", req.AnnouncementID)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	announcement, exists := storage.announcements[req.AnnouncementID]
+	if !exists || !canSeeOrg(user, announcement.OrgID) {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Announcement not found")
+		return
+	}
+	delete(storage.announcements, announcement.ID)
+	removeNotifications(func(notification *Notification) bool { return notification.AnnouncementID == announcement.ID })
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Announcement deleted successfully",
+	})
+}
+
+// /getAnnouncements - The unexpired announcements of the caller's
+// organization, newest first
+func getAnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req GetAnnouncementsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.RLock()
+	announcements := activeAnnouncements(user.OrgID)
+	storage.mutex.RUnlock()
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Announcements retrieved successfully",
+		Data:    announcements,
+	})
+}
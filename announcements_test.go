@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAnnouncements(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.Local))
+	codes := make([]string, 3)
+	for i := range codes {
+		_, codes[i] = registerTestUser(t, srv, "Tenant", fmt.Sprintf("tenant%d@example.com", i))
+	}
+	inviteCode, _, orgAdmin := createTestOrganization(t, srv, "Acme", "admin@acme.example")
+	_, outsider := registerInOrganization(t, srv, "Acme User", "user@acme.example", inviteCode)
+
+	create := func(req CreateAnnouncementRequest) Announcement {
+		t.Helper()
+		req.SecretCode = adminSecret
+		status, resp := postJSON(t, srv, "/createAnnouncement", req)
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var announcement Announcement
+		resp.decode(t, &announcement)
+		return announcement
+	}
+	list := func(secretCode string) []Announcement {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/getAnnouncements", GetAnnouncementsRequest{SecretCode: secretCode})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var announcements []Announcement
+		resp.decode(t, &announcements)
+		return announcements
+	}
+	activeCount := func(secretCode string) int {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/me", MeRequest{SecretCode: secretCode})
+		var me MeResponse
+		resp.decode(t, &me)
+		return me.ActiveAnnouncements
+	}
+
+	elevator := create(CreateAnnouncementRequest{Title: "Elevator maintenance", Body: "Friday 8-12, use the stairs", ExpiresInHours: 72})
+	fake.Advance(time.Minute)
+	parking := create(CreateAnnouncementRequest{Title: "New parking rules", Body: "See the notice board", NotifyUsers: true})
+
+	t.Run("Newest First", func(t *testing.T) {
+		got := list(codes[0])
+		if len(got) != 2 || got[0].ID != parking.ID || got[1].ID != elevator.ID {
+			t.Fatalf("Expected announcements %d then %d, got %+v", parking.ID, elevator.ID, got)
+		}
+		if got[1].ExpiresAt != "2024-06-06 09:00:00" {
+			t.Errorf("Expected expiry 2024-06-06 09:00:00, got %q", got[1].ExpiresAt)
+		}
+		if n := activeCount(codes[0]); n != 2 {
+			t.Errorf("Expected 2 active announcements in /me, got %d", n)
+		}
+		if got := list(outsider); len(got) != 0 {
+			t.Errorf("Expected no announcements for another organization, got %+v", got)
+		}
+	})
+
+	t.Run("Fan Out", func(t *testing.T) {
+		// Every user of the organization but the author
+		if parking.NotifiedUsers != len(codes) || elevator.NotifiedUsers != 0 {
+			t.Fatalf("Expected %d users notified of the parking rules only, got %d and %d", len(codes), parking.NotifiedUsers, elevator.NotifiedUsers)
+		}
+		announcementFanOuts.Wait()
+		for _, code := range codes {
+			_, resp := postJSON(t, srv, "/getNotifications", GetNotificationsRequest{SecretCode: code})
+			var page NotificationPage
+			resp.decode(t, &page)
+			if page.UnreadCount != 1 || page.Notifications[0].AnnouncementID != parking.ID || page.Notifications[0].Type != notificationAnnouncement {
+				t.Errorf("Expected one unread announcement notification, got %+v", page)
+			}
+		}
+		for _, code := range []string{adminSecret, outsider} {
+			_, resp := postJSON(t, srv, "/getNotifications", GetNotificationsRequest{SecretCode: code})
+			var page NotificationPage
+			resp.decode(t, &page)
+			if page.TotalCount != 0 {
+				t.Errorf("Expected no notification for the author or another organization, got %+v", page.Notifications)
+			}
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		fake.Advance(72 * time.Hour)
+		if got := list(codes[0]); len(got) != 1 || got[0].ID != parking.ID {
+			t.Errorf("Expected the elevator announcement to expire, got %+v", got)
+		}
+		if n := activeCount(codes[0]); n != 1 {
+			t.Errorf("Expected 1 active announcement in /me, got %d", n)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/deleteAnnouncement", DeleteAnnouncementRequest{SecretCode: orgAdmin, AnnouncementID: parking.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for another organization's admin, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/deleteAnnouncement", DeleteAnnouncementRequest{SecretCode: codes[0], AnnouncementID: parking.ID}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a user, got %d", status)
+		}
+		if status, resp := postJSON(t, srv, "/deleteAnnouncement", DeleteAnnouncementRequest{SecretCode: adminSecret, AnnouncementID: parking.ID}); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if got := list(codes[0]); len(got) != 0 {
+			t.Errorf("Expected no announcements left, got %+v", got)
+		}
+		_, resp := postJSON(t, srv, "/getNotifications", GetNotificationsRequest{SecretCode: codes[0]})
+		var page NotificationPage
+		resp.decode(t, &page)
+		if page.TotalCount != 0 {
+			t.Errorf("Expected the announcement's notifications removed, got %+v", page.Notifications)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			req    CreateAnnouncementRequest
+			status int
+		}{
+			{"No Title", CreateAnnouncementRequest{SecretCode: adminSecret, Body: "Body"}, http.StatusBadRequest},
+			{"Negative Expiry", CreateAnnouncementRequest{SecretCode: adminSecret, Title: "Title", Body: "Body", ExpiresInHours: -1}, http.StatusBadRequest},
+			{"Regular User", CreateAnnouncementRequest{SecretCode: codes[0], Title: "Title", Body: "Body"}, http.StatusForbidden},
+			{"Other Organization", CreateAnnouncementRequest{SecretCode: orgAdmin, Title: "Title", Body: "Body", OrgID: storage.defaultOrgID}, http.StatusNotFound},
+		}
+		for _, tc := range tests {
+			if status, _ := postJSON(t, srv, "/createAnnouncement", tc.req); status != tc.status {
+				t.Errorf("%s: expected %d, got %d", tc.name, tc.status, status)
+			}
+		}
+	})
+}
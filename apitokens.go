@@ -0,0 +1,311 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// API token scopes
+const (
+	scopeRead        = "read"         // read routes only
+	scopeReadWrite   = "read_write"   // everything the issuing admin can do
+	scopeIngestEmail = "ingest_email" // /ingestEmail only, for the mail gateway
+)
+
+const (
+	apiTokenPrefix      = "cpt_"
+	apiTokenShownLength = 12 // characters of a token kept for display, prefix included
+	maxAPITokenLabel    = 100
+)
+
+// APIToken lets an integration act as the admin who created it, limited to
+// its scope. Only a hash of the token is kept; the token itself is shown
+// once, when it is created.
+type APIToken struct {
+	ID        int    `json:"id"`
+	Label     string `json:"label"`
+	Scope     string `json:"scope"`
+	Prefix    string `json:"prefix"` // start of the token, to tell tokens apart
+	CreatedBy int    `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at,omitempty"` // never expires when empty
+	RevokedAt string `json:"revoked_at,omitempty"`
+
+	hash    string
+	expires time.Time
+}
+
+// CreatedAPIToken is the /createApiToken payload, the only time the full
+// token is returned
+type CreatedAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+type CreateAPITokenRequest struct {
+	SecretCode     string `json:"secret_code"`
+	Label          string `json:"label"`
+	Scope          string `json:"scope"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"` // 0 for no expiry
+}
+
+type ListAPITokensRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+type RevokeAPITokenRequest struct {
+	SecretCode string `json:"secret_code"`
+	TokenID    int    `json:"token_id"`
+}
+
+func isAPIToken(credential string) bool {
+	return strings.HasPrefix(credential, apiTokenPrefix)
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+// activeAPIToken returns the unrevoked, unexpired token stored for
+// credential and the admin it acts for. Callers must hold storage.mutex.
+func activeAPIToken(credential string, now time.Time) (*APIToken, *User, *APIError) {
+	token, exists := storage.apiTokens[hashAPIToken(credential)]
+	if !exists || token.RevokedAt != "" {
+		return nil, nil, newAPIError(http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid API token")
+	}
+	if !token.expires.IsZero() && !now.Before(token.expires) {
+		return nil, nil, newAPIError(http.StatusUnauthorized, ErrCodeTokenExpired, "API token has expired")
+	}
+	user, exists := storage.users[token.CreatedBy]
+	if !exists {
+		return nil, nil, newAPIError(http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid API token")
+	}
+	switch d := adminOnly(actorOfLocked(user)); {
+	case d.code == ErrCodeAccountDeactivated:
+		return nil, nil, newAPIError(http.StatusForbidden, ErrCodeAccountDeactivated, "The admin who created this API token has been deactivated")
+	case !d.allowed():
+		return nil, nil, newAPIError(http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid API token")
+	}
+	return token, user, nil
+}
+
+// authenticateAPIToken is authenticate for an API token. A read-scoped token
+// is refused on write routes, and an ingest_email one everywhere; see
+// authenticateIngestToken.
+func authenticateAPIToken(w http.ResponseWriter, r *http.Request, credential string) *User {
+	storage.mutex.RLock()
+	token, user, err := activeAPIToken(credential, clock.Now())
+	storage.mutex.RUnlock()
+	if err != nil {
+		respondWithAPIError(w, err)
+		return nil
+	}
+	if token.Scope == scopeRead && requestWrites(r) {
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, "Access denied. This API token is read-only")
+		return nil
+	}
+	if token.Scope == scopeIngestEmail {
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, "Access denied. This API token can only ingest email")
+		return nil
+	}
+	return user
+}
+
+// /createApiToken - Issue a scoped token for an integration (admin only)
+func createAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req CreateAPITokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	label := sanitizeText(req.Label, false)
+	if label == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Label is required")
+		return
+	}
+	if err := checkLength("Label", label, maxAPITokenLabel); err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	if req.Scope != scopeRead && req.Scope != scopeReadWrite && req.Scope != scopeIngestEmail {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Scope must be read, read_write or ingest_email")
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Expires in hours must not be negative")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+	// Otherwise a leaked token could mint tokens that outlive its revocation
+	if isAPIToken(req.SecretCode) {
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, "API tokens cannot manage API tokens")
+		return
+	}
+
+	secret, err := generateAPIToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate API token")
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	now := clock.Now()
+	storage.apiTokenIDGen++
+	token := &APIToken{
+		ID:        storage.apiTokenIDGen,
+		Label:     label,
+		Scope:     req.Scope,
+		Prefix:    secret[:apiTokenShownLength],
+		CreatedBy: user.ID,
+		CreatedAt: getCurrentTime(),
+		hash:      hashAPIToken(secret),
+	}
+	if req.ExpiresInHours > 0 {
+		token.expires = now.Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		token.ExpiresAt = token.expires.Local().Format(timestampLayout)
+	}
+	storage.apiTokens[token.hash] = token
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "API token created. Store it now; it will not be shown again",
+		Data:    CreatedAPIToken{APIToken: *token, Token: secret},
+	})
+}
+
+// /listApiTokens - The API tokens of the caller's organization, without the
+// tokens themselves (admin only)
+func listAPITokensHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ListAPITokensRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	tokens := make([]APIToken, 0, len(storage.apiTokens))
+	for _, token := range storage.apiTokens {
+		if scopedUser(user, token.CreatedBy) != nil {
+			tokens = append(tokens, *token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "API tokens retrieved successfully",
+		Data:    tokens,
+	})
+}
+
+// /revokeApiToken - Disable an API token at once (admin only)
+func revokeAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RevokeAPITokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.TokenID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid token ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+	if isAPIToken(req.SecretCode) {
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, "API tokens cannot manage API tokens")
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	var token *APIToken
+	for _, candidate := range storage.apiTokens {
+		if candidate.ID == req.TokenID && scopedUser(user, candidate.CreatedBy) != nil {
+			token = candidate
+			break
+		}
+	}
+	if token == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "API token not found")
+		return
+	}
+	if token.RevokedAt == "" {
+		token.RevokedAt = getCurrentTime()
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "API token revoked",
+		Data:    *token,
+	})
+}
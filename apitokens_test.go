@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPITokens(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local))
+	_, userCode := registerTestUser(t, srv, "Reporter", "reporter@example.com")
+	complaint := submitTestComplaint(t, srv, userCode, "Broken window", 3)
+
+	create := func(scope string, expiresInHours int) CreatedAPIToken {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/createApiToken", CreateAPITokenRequest{
+			SecretCode: adminSecret, Label: "Wallboard " + scope, Scope: scope, ExpiresInHours: expiresInHours,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var token CreatedAPIToken
+		resp.decode(t, &token)
+		return token
+	}
+	list := func(credential string) (int, testResponse) {
+		return postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: credential})
+	}
+
+	readToken := create(scopeRead, 0)
+	if !strings.HasPrefix(readToken.Token, apiTokenPrefix) || readToken.Prefix != readToken.Token[:apiTokenShownLength] {
+		t.Fatalf("Expected a %s token and its prefix, got %+v", apiTokenPrefix, readToken)
+	}
+
+	t.Run("Read Scope", func(t *testing.T) {
+		status, resp := list(readToken.Token)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 listing with a read token, got %d (%s)", status, resp.Error)
+		}
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		if len(complaints) != 1 {
+			t.Errorf("Expected 1 complaint, got %d", len(complaints))
+		}
+
+		status, resp = postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: readToken.Token, ComplaintID: complaint.ID})
+		if status != http.StatusForbidden || resp.ErrorCode != ErrCodeForbidden {
+			t.Errorf("Expected 403 resolving with a read token, got %d %s", status, resp.ErrorCode)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/complaints/1/resolve", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+readToken.Token)
+		v1Resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		v1Resp.Body.Close()
+		if v1Resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 from /v1 with a read token, got %d", v1Resp.StatusCode)
+		}
+
+		if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: readToken.Token}); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 logging in with an API token, got %d", status)
+		}
+	})
+
+	t.Run("Read Write Scope", func(t *testing.T) {
+		writeToken := create(scopeReadWrite, 0)
+		status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: writeToken.Token, ComplaintID: complaint.ID})
+		if status != http.StatusOK {
+			t.Errorf("Expected 200 resolving with a read_write token, got %d (%s)", status, resp.Error)
+		}
+		status, _ = postJSON(t, srv, "/createApiToken", CreateAPITokenRequest{SecretCode: writeToken.Token, Label: "Minted", Scope: scopeRead})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 creating a token with a token, got %d", status)
+		}
+	})
+
+	t.Run("Listing Hides Tokens", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/listApiTokens", ListAPITokensRequest{SecretCode: adminSecret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if strings.Contains(string(resp.Data), readToken.Token) || strings.Contains(string(resp.Data), `"token"`) {
+			t.Errorf("Expected no full tokens in the listing, got %s", resp.Data)
+		}
+		var tokens []APIToken
+		resp.decode(t, &tokens)
+		if len(tokens) != 2 || tokens[0].Prefix != readToken.Prefix {
+			t.Errorf("Expected both tokens by prefix, got %+v", tokens)
+		}
+
+		status, _ = postJSON(t, srv, "/listApiTokens", ListAPITokensRequest{SecretCode: userCode})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a non-admin, got %d", status)
+		}
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/revokeApiToken", RevokeAPITokenRequest{SecretCode: adminSecret, TokenID: readToken.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		status, resp = list(readToken.Token)
+		if status != http.StatusUnauthorized || resp.ErrorCode != ErrCodeUnauthorized {
+			t.Errorf("Expected 401 with a revoked token, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expiring := create(scopeRead, 2)
+		if status, resp := list(expiring.Token); status != http.StatusOK {
+			t.Fatalf("Expected 200 before expiry, got %d (%s)", status, resp.Error)
+		}
+		fake.Advance(2 * time.Hour)
+		status, resp := list(expiring.Token)
+		if status != http.StatusUnauthorized || resp.ErrorCode != ErrCodeTokenExpired {
+			t.Errorf("Expected 401 TOKEN_EXPIRED, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, req := range []CreateAPITokenRequest{
+			{Label: "", Scope: scopeRead},
+			{Label: "Bad scope", Scope: "admin"},
+			{Label: "Negative", Scope: scopeRead, ExpiresInHours: -1},
+		} {
+			req.SecretCode = adminSecret
+			if status, _ := postJSON(t, srv, "/createApiToken", req); status != http.StatusBadRequest {
+				body, _ := json.Marshal(req)
+				t.Errorf("Expected 400 for %s, got %d", body, status)
+			}
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ArchiveComplaintRequest is the body of /archiveComplaint and
+// /unarchiveComplaint
+type ArchiveComplaintRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+}
+
+func isArchived(complaint *Complaint) bool {
+	return complaint.ArchivedAt != ""
+}
+
+// lastUnarchived returns when complaint was last taken out of the archive,
+// or the zero time if it never was
+func lastUnarchived(complaint *Complaint) time.Time {
+	for i := len(complaint.History) - 1; i >= 0; i-- {
+		if complaint.History[i].Action != historyUnarchived {
+			continue
+		}
+		at, err := parseTimestamp(complaint.History[i].At)
+		if err != nil {
+			break
+		}
+		return at
+	}
+	return time.Time{}
+}
+
+// archiveResolvedComplaints archives the complaints resolved before cutoff
+// and returns how many it archived. A complaint an admin took out of the
+// archive is left alone until cutoff passes that too, so the job does not
+// undo it on its next run.
+func archiveResolvedComplaints(cutoff time.Time) int {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	archived := 0
+	for _, complaint := range storage.complaints {
+		if !complaint.IsResolved || complaint.PendingConfirmation || complaint.IsDeleted || isArchived(complaint) {
+			continue
+		}
+		resolvedAt, err := parseTimestamp(complaint.ResolvedAt)
+		if err != nil || resolvedAt.After(cutoff) || lastUnarchived(complaint).After(cutoff) {
+			continue
+		}
+		complaint.ArchivedAt = getCurrentTime()
+		addHistory(complaint, HistoryEntry{Action: historyArchived})
+		touchComplaint(complaint)
+		archived++
+	}
+	return archived
+}
+
+// decodeArchiveRequest reads and checks an archive or unarchive request,
+// writing the error response when it fails
+func decodeArchiveRequest(w http.ResponseWriter, r *http.Request) (ArchiveComplaintRequest, *User) {
+	var req ArchiveComplaintRequest
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return req, nil
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return req, nil
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return req, nil
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return req, nil
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return req, nil
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return req, nil
+	}
+	return req, user
+}
+
+// /archiveComplaint - Archive a resolved complaint now rather than waiting
+// for the archive job (admin only)
+func archiveComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	req, user := decodeArchiveRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+	if isArchived(complaint) {
+		respondWithError(w, http.StatusConflict, ErrCodeAlreadyArchived, "Complaint is already archived")
+		return
+	}
+	if !complaint.IsResolved {
+		respondWithError(w, http.StatusConflict, ErrCodeNotResolved, "Only resolved complaints can be archived")
+		return
+	}
+
+	complaint.ArchivedAt = getCurrentTime()
+	addHistory(complaint, HistoryEntry{Action: historyArchived, ActorID: user.ID, CorrelationID: correlationID(r)})
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint archived successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
+
+// /unarchiveComplaint - Bring an archived complaint back into listings and
+// reports (admin only)
+func unarchiveComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	req, user := decodeArchiveRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+	if !isArchived(complaint) {
+		respondWithError(w, http.StatusConflict, ErrCodeNotArchived, "Complaint is not archived")
+		return
+	}
+
+	complaint.ArchivedAt = ""
+	addHistory(complaint, HistoryEntry{Action: historyUnarchived, ActorID: user.ID, CorrelationID: correlationID(r)})
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint unarchived successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
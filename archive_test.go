@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	start := time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	fake := useFakeClock(t, start)
+	_, code := registerTestUser(t, srv, "Archive User", "archive@example.com")
+	old := submitTestComplaint(t, srv, code, "Resolved long ago", 4)
+	recent := submitTestComplaint(t, srv, code, "Resolved lately", 5)
+	open := submitTestComplaint(t, srv, code, "Still open", 6)
+
+	resolve := func(id int) {
+		t.Helper()
+		if status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: id}); status != http.StatusOK {
+			t.Fatalf("Expected 200 resolving %d, got %d (%s)", id, status, resp.Error)
+		}
+	}
+	runArchiveJob := func() int {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/runJobs", RunJobsRequest{SecretCode: adminSecret, Job: "archive_resolved_complaints"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 running the archive job, got %d (%s)", status, resp.Error)
+		}
+		var results []JobResult
+		resp.decode(t, &results)
+		return results[0].Affected
+	}
+	adminIDs := func(includeArchived bool) []int {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret, IncludeArchived: includeArchived})
+		var list []Complaint
+		resp.decode(t, &list)
+		ids := []int{}
+		for _, c := range list {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+
+	resolve(old.ID)
+	fake.Advance(60 * 24 * time.Hour)
+	resolve(recent.ID)
+	fake.Advance(31 * 24 * time.Hour)
+
+	t.Run("Job", func(t *testing.T) {
+		if archived := runArchiveJob(); archived != 1 {
+			t.Fatalf("Expected only the complaint resolved 91 days ago archived, got %d", archived)
+		}
+		if ids := adminIDs(false); len(ids) != 2 || ids[0] != recent.ID || ids[1] != open.ID {
+			t.Errorf("Expected the archived complaint left out of the listing, got %v", ids)
+		}
+		if ids := adminIDs(true); len(ids) != 3 {
+			t.Errorf("Expected include_archived to list all 3 complaints, got %v", ids)
+		}
+		if archived := runArchiveJob(); archived != 0 {
+			t.Errorf("Expected a second run to archive nothing, got %d", archived)
+		}
+	})
+
+	t.Run("Owner Can View", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: code, ComplaintID: old.ID})
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		if status != http.StatusOK || complaint.ArchivedAt == "" {
+			t.Errorf("Expected the owner to view the archived complaint, got %d %+v", status, complaint)
+		}
+	})
+
+	t.Run("Report And Export", func(t *testing.T) {
+		day := start.Format(dateLayout)
+		_, resp := postJSON(t, srv, "/report", ReportRequest{SecretCode: adminSecret, From: day, To: day})
+		var report Report
+		resp.decode(t, &report)
+		if report.TotalCreated != 2 {
+			t.Errorf("Expected the archived complaint left out of the report, got %d created", report.TotalCreated)
+		}
+		_, resp = postJSON(t, srv, "/report", ReportRequest{SecretCode: adminSecret, From: day, To: day, IncludeArchived: true})
+		resp.decode(t, &report)
+		if report.TotalCreated != 3 {
+			t.Errorf("Expected include_archived to count all 3 complaints, got %d", report.TotalCreated)
+		}
+
+		if lines := exportLines(t, srv.URL+"/exportComplaints?secret_code="+adminSecret); len(lines) != 2 {
+			t.Errorf("Expected 2 exported complaints, got %d", len(lines))
+		}
+		lines := exportLines(t, srv.URL+"/exportComplaints?include_archived=true&secret_code="+adminSecret)
+		if len(lines) != 3 || lines[0].ArchivedAt == "" {
+			t.Errorf("Expected the archived complaint exported with archived_at, got %+v", lines)
+		}
+	})
+
+	t.Run("Manual", func(t *testing.T) {
+		archive := func(endpoint string, id int) (int, testResponse) {
+			t.Helper()
+			return postJSON(t, srv, endpoint, ArchiveComplaintRequest{SecretCode: adminSecret, ComplaintID: id})
+		}
+		if status, resp := archive("/archiveComplaint", open.ID); status != http.StatusConflict || resp.ErrorCode != ErrCodeNotResolved {
+			t.Errorf("Expected 409 %s archiving an open complaint, got %d %s", ErrCodeNotResolved, status, resp.ErrorCode)
+		}
+		if status, resp := archive("/archiveComplaint", old.ID); status != http.StatusConflict || resp.ErrorCode != ErrCodeAlreadyArchived {
+			t.Errorf("Expected 409 %s, got %d %s", ErrCodeAlreadyArchived, status, resp.ErrorCode)
+		}
+		if status, resp := archive("/unarchiveComplaint", recent.ID); status != http.StatusConflict || resp.ErrorCode != ErrCodeNotArchived {
+			t.Errorf("Expected 409 %s, got %d %s", ErrCodeNotArchived, status, resp.ErrorCode)
+		}
+		if status, _ := postJSON(t, srv, "/archiveComplaint", ArchiveComplaintRequest{SecretCode: code, ComplaintID: recent.ID}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a non-admin, got %d", status)
+		}
+
+		if status, resp := archive("/archiveComplaint", recent.ID); status != http.StatusOK {
+			t.Fatalf("Expected 200 archiving a resolved complaint, got %d (%s)", status, resp.Error)
+		}
+		if status, resp := archive("/unarchiveComplaint", old.ID); status != http.StatusOK {
+			t.Fatalf("Expected 200 unarchiving, got %d (%s)", status, resp.Error)
+		}
+		if ids := adminIDs(false); len(ids) != 2 || ids[0] != old.ID || ids[1] != open.ID {
+			t.Errorf("Expected the unarchived complaint back in the listing, got %v", ids)
+		}
+
+		// The job leaves an unarchived complaint alone for another period
+		if archived := runArchiveJob(); archived != 0 {
+			t.Errorf("Expected the job to keep the unarchived complaint out, got %d", archived)
+		}
+		fake.Advance(91 * 24 * time.Hour)
+		if archived := runArchiveJob(); archived != 1 {
+			t.Errorf("Expected the job to archive it again after a full period, got %d", archived)
+		}
+	})
+}
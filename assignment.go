@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Strategies for assigning new complaints to admins
+const (
+	assignNone        = "none"
+	assignRoundRobin  = "round_robin"  // admins take turns, in ID order
+	assignLeastLoaded = "least_loaded" // the admin with the fewest open assigned complaints; ties go to the lowest ID
+)
+
+func validateAssignmentStrategy(strategy string) error {
+	switch strategy {
+	case assignNone, assignRoundRobin, assignLeastLoaded:
+		return nil
+	}
+	return fmt.Errorf("Assignment strategy must be one of: %s, %s, %s", assignNone, assignRoundRobin, assignLeastLoaded)
+}
+
+// assignableAdmins returns the admins of organization orgID new complaints
+// can be assigned to, in ID order. The default admin is a bootstrap account
+// and never takes complaints, nor do deactivated admins. Callers must hold storage.mutex for reading.
+func assignableAdmins(orgID int) []*User {
+	var admins []*User
+	for _, user := range storage.users {
+		if user.ID != storage.defaultAdminID && canTakeAssignment(actorOfLocked(user), orgID).allowed() {
+			admins = append(admins, user)
+		}
+	}
+	sort.Slice(admins, func(i, j int) bool { return admins[i].ID < admins[j].ID })
+	return admins
+}
+
+// pickAssignee chooses the admin for a new complaint of organization orgID
+// under strategy, or nil when complaints stay unassigned. Each organization
+// keeps its own rotation. Callers must hold storage.mutex for writing, as
+// round robin moves on to the next admin.
+func pickAssignee(strategy string, orgID int) *User {
+	if strategy == assignNone || strategy == "" {
+		return nil
+	}
+	org, exists := storage.organizations[orgID]
+	admins := assignableAdmins(orgID)
+	if !exists || len(admins) == 0 {
+		return nil
+	}
+
+	if strategy == assignRoundRobin {
+		// The first admin after the previous pick, wrapping around; going by
+		// ID keeps the rotation fair when admins come and go
+		next := admins[0]
+		for _, admin := range admins {
+			if admin.ID > org.lastAssigneeID {
+				next = admin
+				break
+			}
+		}
+		org.lastAssigneeID = next.ID
+		return next
+	}
+
+	open := make(map[int]int)
+	for _, complaint := range storage.complaints {
+		if complaint.AssignedTo != 0 && isOpen(complaint) && !complaint.IsDeleted {
+			open[complaint.AssignedTo]++
+		}
+	}
+	best := admins[0]
+	for _, admin := range admins[1:] {
+		if open[admin.ID] < open[best.ID] {
+			best = admin
+		}
+	}
+	return best
+}
+
+// autoAssign assigns a new complaint according to config.AssignmentStrategy
+// and records it in the complaint's history under correlationID. Callers
+// must hold storage.mutex for writing.
+func autoAssign(complaint *Complaint, correlationID string) {
+	admin := pickAssignee(config.AssignmentStrategy, complaint.OrgID)
+	if admin == nil {
+		return
+	}
+	complaint.AssignedTo = admin.ID
+	complaint.AssignedToName = admin.Name
+	addHistory(complaint, HistoryEntry{
+		Action:        historyAssigned,
+		Detail:        fmt.Sprintf("Assigned to %s (%s)", admin.Name, config.AssignmentStrategy),
+		CorrelationID: correlationID,
+	})
+}
+
+// /getMyAssignedComplaints - Complaints assigned to the calling admin
+func getMyAssignedComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req GetComplaintsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	opts, err := parseListOptions(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	assigned := listComplaints(user, func(c *Complaint) bool { return c.AssignedTo == user.ID }, opts)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Assigned complaints retrieved successfully",
+		Data:    assigned,
+	})
+}
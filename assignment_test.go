@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// registerTestAdmin registers a user and promotes them to admin, returning
+// their ID and secret code
+func registerTestAdmin(t *testing.T, srv *httptest.Server, name, email string) (int, string) {
+	t.Helper()
+	id, code := registerTestUser(t, srv, name, email)
+	storage.mutex.Lock()
+	storage.users[id].IsAdmin = true
+	storage.mutex.Unlock()
+	return id, code
+}
+
+// assigneeOf returns who complaint id is assigned to, as the default admin sees it
+func assigneeOf(t *testing.T, srv *httptest.Server, id int) Complaint {
+	t.Helper()
+	status, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: adminSecret, ComplaintID: id})
+	if status != http.StatusOK {
+		t.Fatalf("View %d: expected 200, got %d (%s)", id, status, resp.Error)
+	}
+	var complaint Complaint
+	resp.decode(t, &complaint)
+	return complaint
+}
+
+func TestRoundRobinAssignment(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	config.AssignmentStrategy = assignRoundRobin
+	first, firstCode := registerTestAdmin(t, srv, "First Admin", "first@example.com")
+	second, _ := registerTestAdmin(t, srv, "Second Admin", "second@example.com")
+	third, _ := registerTestAdmin(t, srv, "Third Admin", "third@example.com")
+	_, code := registerTestUser(t, srv, "Busy User", "busy@example.com")
+
+	var got []int
+	for i := 0; i < 4; i++ {
+		complaint := submitTestComplaint(t, srv, code, "Rotated", 5)
+		if complaint.AssignedTo != 0 || complaint.History != nil {
+			t.Errorf("Expected the assignment to be hidden from the submitter, got %+v", complaint)
+		}
+		got = append(got, assigneeOf(t, srv, complaint.ID).AssignedTo)
+	}
+	want := []int{first, second, third, first}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected rotation %v, got %v", want, got)
+		}
+	}
+
+	// A demoted admin drops out of the rotation
+	storage.mutex.Lock()
+	storage.users[second].IsAdmin = false
+	storage.mutex.Unlock()
+	if assigned := assigneeOf(t, srv, submitTestComplaint(t, srv, code, "Skip", 5).ID); assigned.AssignedTo != third {
+		t.Errorf("Expected the demoted admin to be skipped, got %d", assigned.AssignedTo)
+	}
+
+	t.Run("History", func(t *testing.T) {
+		complaint := assigneeOf(t, srv, 1)
+		if len(complaint.History) != 1 || complaint.History[0].Action != historyAssigned || complaint.AssignedToName != "First Admin" {
+			t.Fatalf("Expected an assignment entry, got %+v", complaint.History)
+		}
+		if complaint.History[0].Detail != "Assigned to First Admin (round_robin)" {
+			t.Errorf("Unexpected detail %q", complaint.History[0].Detail)
+		}
+	})
+
+	t.Run("My Assigned Complaints", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/getMyAssignedComplaints", GetComplaintsRequest{SecretCode: firstCode})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var list []Complaint
+		resp.decode(t, &list)
+		if len(list) != 2 || list[0].ID != 1 || list[1].ID != 4 {
+			t.Errorf("Expected complaints 1 and 4, got %+v", list)
+		}
+
+		if status, _ := postJSON(t, srv, "/getMyAssignedComplaints", GetComplaintsRequest{SecretCode: code}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a non-admin, got %d", status)
+		}
+	})
+
+	t.Run("Event", func(t *testing.T) {
+		sub := eventBus.subscribe()
+		defer eventBus.unsubscribe(sub)
+		complaint := submitTestComplaint(t, srv, code, "Announced", 5)
+
+		var types []string
+		for len(types) < 2 {
+			select {
+			case event := <-sub.events:
+				types = append(types, event.Type)
+				if event.Type == eventComplaintAssigned && (event.Complaint.ID != complaint.ID || event.Complaint.AssignedTo == 0) {
+					t.Errorf("Expected the assigned complaint %d, got %+v", complaint.ID, event.Complaint)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("Expected 2 events, got %v", types)
+			}
+		}
+		if types[0] != eventComplaintCreated || types[1] != eventComplaintAssigned {
+			t.Errorf("Expected %s then %s, got %v", eventComplaintCreated, eventComplaintAssigned, types)
+		}
+	})
+}
+
+func TestLeastLoadedAssignment(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	config.AssignmentStrategy = assignLeastLoaded
+	first, _ := registerTestAdmin(t, srv, "First Admin", "first@example.com")
+	second, _ := registerTestAdmin(t, srv, "Second Admin", "second@example.com")
+	third, _ := registerTestAdmin(t, srv, "Third Admin", "third@example.com")
+	_, code := registerTestUser(t, srv, "Busy User", "busy@example.com")
+
+	submit := func() Complaint {
+		t.Helper()
+		return assigneeOf(t, srv, submitTestComplaint(t, srv, code, "Balanced", 5).ID)
+	}
+
+	// All idle: ties go to the lowest ID, so the admins fill up in order
+	a, b, c := submit(), submit(), submit()
+	if a.AssignedTo != first || b.AssignedTo != second || c.AssignedTo != third {
+		t.Fatalf("Expected %d, %d, %d, got %d, %d, %d", first, second, third, a.AssignedTo, b.AssignedTo, c.AssignedTo)
+	}
+
+	// Resolving the second admin's complaint leaves them the least loaded
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: b.ID})
+	if next := submit(); next.AssignedTo != second {
+		t.Errorf("Expected the least loaded admin %d, got %d", second, next.AssignedTo)
+	}
+	// Now tied again at one each
+	if next := submit(); next.AssignedTo != first {
+		t.Errorf("Expected the tie to go to %d, got %d", first, next.AssignedTo)
+	}
+}
+
+func TestAssignmentWithOnlyTheDefaultAdmin(t *testing.T) {
+	srv := newTestServer(t)
+	config.AssignmentStrategy = assignRoundRobin
+	_, code := registerTestUser(t, srv, "Lone User", "lone@example.com")
+
+	complaint := assigneeOf(t, srv, submitTestComplaint(t, srv, code, "Unassigned", 5).ID)
+	if complaint.AssignedTo != 0 || complaint.History != nil {
+		t.Errorf("Expected no assignment, got %+v", complaint)
+	}
+}
+
+func TestValidateAssignmentStrategy(t *testing.T) {
+	for _, strategy := range []string{assignNone, assignRoundRobin, assignLeastLoaded} {
+		if err := validateAssignmentStrategy(strategy); err != nil {
+			t.Errorf("%s: unexpected error %v", strategy, err)
+		}
+	}
+	if err := validateAssignmentStrategy("random"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
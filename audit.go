@@ -0,0 +1,27 @@
+package main
+
+// Audit log actions
+const (
+	auditRetentionPurge = "retention_purge"
+)
+
+// AuditEntry records something done to the portal as a whole rather than
+// to one complaint, which has its own history. Entries are kept for
+// config.RetainAuditDays and are part of backups.
+type AuditEntry struct {
+	ID      int    `json:"id"`
+	Action  string `json:"action"`
+	ActorID int    `json:"actor_id,omitempty"` // 0 for the server itself
+	Detail  string `json:"detail,omitempty"`
+	At      string `json:"at"`
+}
+
+// recordAudit appends entry to the audit log, numbering and timing it.
+// Callers must hold storage.mutex for writing.
+func recordAudit(entry AuditEntry) AuditEntry {
+	storage.auditIDGen++
+	entry.ID = storage.auditIDGen
+	entry.At = getCurrentTime()
+	storage.auditLog = append(storage.auditLog, entry)
+	return entry
+}
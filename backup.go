@@ -0,0 +1,891 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupSchemaVersion is the version of Backup this server writes. Older
+// documents are upgraded by backupMigrations when they are loaded.
+const backupSchemaVersion = 6
+
+// maxBackupSize bounds the document accepted by /admin/restore
+const maxBackupSize = 256 << 20
+
+// Backup is the whole of storage as one JSON document. Every list is sorted
+// by ID (or user ID), so backing up the same state always gives the same
+// bytes. User.Complaints is left out and rebuilt on restore.
+type Backup struct {
+	SchemaVersion int                   `json:"schema_version"`
+	CreatedAt     string                `json:"created_at"`
+	Counters      BackupCounters        `json:"counters"`
+	Users         []BackupUser          `json:"users"`
+	Complaints    []BackupComplaint     `json:"complaints"`
+	Notifications []Notification        `json:"notifications"`
+	LoginHistory  []BackupLoginHistory  `json:"login_history"`
+	Submissions   []BackupSubmissions   `json:"submissions"`
+	Departments   []Department          `json:"departments"`
+	APITokens     []BackupAPIToken      `json:"api_tokens"`
+	SavedFilters  []SavedFilter         `json:"saved_filters"`
+	Organizations []BackupOrganization  `json:"organizations"`
+	Invites       []BackupInvite        `json:"invites"`
+	Outbox        []BackupOutboxMessage `json:"outbox"`
+	TagRules      []TagRule             `json:"tag_rules"`
+	Announcements []BackupAnnouncement  `json:"announcements"`
+	Buildings     []Building            `json:"buildings"`
+	FeedTokens    []BackupFeedToken     `json:"feed_tokens"`
+	AuditLog      []AuditEntry          `json:"audit_log"`
+	// RevokedShareLinks keep revoked links from working again after a
+	// restore, when -share-key is set
+	RevokedShareLinks []RevokedShareLink `json:"revoked_share_links"`
+}
+
+// BackupCounters are the ID generators and other scalar state of storage
+type BackupCounters struct {
+	UserID         int `json:"user_id"`
+	ComplaintID    int `json:"complaint_id"`
+	NotificationID int `json:"notification_id"`
+	DepartmentID   int `json:"department_id"`
+	APITokenID     int `json:"api_token_id"`
+	SavedFilterID  int `json:"saved_filter_id"`
+	OrganizationID int `json:"organization_id"`
+	InviteID       int `json:"invite_id"`
+	OutboxID       int `json:"outbox_id"`
+	TagRuleID      int `json:"tag_rule_id"`
+	AnnouncementID int `json:"announcement_id"`
+	BuildingID     int `json:"building_id"`
+	AuditID        int `json:"audit_id"`
+	DefaultAdminID int `json:"default_admin_id"`
+	DefaultOrgID   int `json:"default_organization_id"`
+}
+
+type BackupUser struct {
+	ID            int    `json:"id"`
+	SecretCode    string `json:"secret_code"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	OrgID         int    `json:"org_id"`
+	IsAdmin       bool   `json:"is_admin"`
+	IsSuperAdmin  bool   `json:"is_super_admin,omitempty"`
+	LastLoginAt   string `json:"last_login_at,omitempty"`
+	TOSVersion    string `json:"tos_version,omitempty"`
+	TOSAcceptedAt string `json:"tos_accepted_at,omitempty"`
+	Deactivated   bool   `json:"deactivated,omitempty"` // users are active unless set
+	DeactivatedAt string `json:"deactivated_at,omitempty"`
+	// CredentialGeneration keeps the tokens revoked before the backup
+	// revoked after a restore
+	CredentialGeneration int `json:"credential_generation,omitempty"`
+}
+
+// BackupComplaint is a stored complaint with the fields the API hides. Its
+// resolution is stored as Status rather than the API's is_resolved.
+type BackupComplaint struct {
+	Complaint
+	IsResolved *bool  `json:"is_resolved,omitempty"` // always nil; hides Complaint.IsResolved
+	Status     string `json:"status"`                // open or resolved; merges are in merged_into
+	Watchers   []int  `json:"watchers,omitempty"`
+	Related    []int  `json:"related,omitempty"` // linked complaints
+}
+
+// BackupLoginHistory is a user's login history, oldest first
+type BackupLoginHistory struct {
+	UserID  int          `json:"user_id"`
+	Entries []LoginEntry `json:"entries"`
+}
+
+// BackupSubmissions are a user's recent submission times, for throttling
+type BackupSubmissions struct {
+	UserID int         `json:"user_id"`
+	Times  []time.Time `json:"times"`
+}
+
+// BackupAPIToken is an API token with its hash, which is all the server
+// keeps of the secret
+type BackupAPIToken struct {
+	APIToken
+	Hash    string     `json:"hash"`
+	Expires *time.Time `json:"expires,omitempty"` // exact expiry; ExpiresAt is to the second
+}
+
+// BackupFeedToken is a feed token with its hash
+type BackupFeedToken struct {
+	FeedToken
+	Hash string `json:"hash"`
+}
+
+// RevokedShareLink is a share link revoked before it expired
+type RevokedShareLink struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+// BackupOrganization is an organization with its round-robin state
+type BackupOrganization struct {
+	Organization
+	LastAssigneeID int `json:"last_assignee_id,omitempty"`
+}
+
+// BackupInvite is an invite with its exact expiry
+type BackupInvite struct {
+	Invite
+	Expires *time.Time `json:"expires,omitempty"` // ExpiresAt is to the second
+}
+
+// BackupAnnouncement is an announcement with its exact expiry
+type BackupAnnouncement struct {
+	Announcement
+	Expires *time.Time `json:"expires,omitempty"` // ExpiresAt is to the second
+}
+
+// BackupOutboxMessage is an unsent notification or dead letter with the
+// time of its next attempt
+type BackupOutboxMessage struct {
+	OutboxMessage
+	NextAttempt *time.Time `json:"next_attempt,omitempty"` // due now when left out
+}
+
+// RestoreResult is the /admin/restore payload
+type RestoreResult struct {
+	SchemaVersion int `json:"schema_version"` // of the document, before migration
+	Users         int `json:"users"`
+	Complaints    int `json:"complaints"`
+	Notifications int `json:"notifications"`
+	Departments   int `json:"departments"`
+	APITokens     int `json:"api_tokens"`
+	SavedFilters  int `json:"saved_filters"`
+	Organizations int `json:"organizations"`
+	Invites       int `json:"invites"`
+	Outbox        int `json:"outbox"`
+	TagRules      int `json:"tag_rules"`
+	Announcements int `json:"announcements"`
+	Buildings     int `json:"buildings"`
+	FeedTokens    int `json:"feed_tokens"`
+	AuditLog      int `json:"audit_log"`
+
+	RevokedShareLinks int `json:"revoked_share_links"`
+}
+
+// snapshotStorage copies storage into a Backup. Callers must hold
+// storage.mutex for reading.
+func snapshotStorage() Backup {
+	backup := Backup{
+		SchemaVersion: backupSchemaVersion,
+		CreatedAt:     clock.Now().UTC().Format(time.RFC3339),
+		Counters: BackupCounters{
+			UserID:         storage.userIDGen,
+			ComplaintID:    storage.compIDGen,
+			NotificationID: storage.notifIDGen,
+			DepartmentID:   storage.deptIDGen,
+			APITokenID:     storage.apiTokenIDGen,
+			SavedFilterID:  storage.filterIDGen,
+			OrganizationID: storage.orgIDGen,
+			InviteID:       storage.inviteIDGen,
+			OutboxID:       storage.outboxIDGen,
+			TagRuleID:      storage.tagRuleIDGen,
+			AnnouncementID: storage.announceIDGen,
+			BuildingID:     storage.buildingIDGen,
+			AuditID:        storage.auditIDGen,
+			DefaultAdminID: storage.defaultAdminID,
+			DefaultOrgID:   storage.defaultOrgID,
+		},
+		Users:         []BackupUser{},
+		Complaints:    []BackupComplaint{},
+		Notifications: []Notification{},
+		LoginHistory:  []BackupLoginHistory{},
+		Submissions:   []BackupSubmissions{},
+		Departments:   []Department{},
+		APITokens:     []BackupAPIToken{},
+		SavedFilters:  []SavedFilter{},
+		Organizations: []BackupOrganization{},
+		Invites:       []BackupInvite{},
+		Outbox:        []BackupOutboxMessage{},
+		TagRules:      []TagRule{},
+		Announcements: []BackupAnnouncement{},
+		Buildings:     []Building{},
+		FeedTokens:    []BackupFeedToken{},
+		AuditLog:      append([]AuditEntry{}, storage.auditLog...), // already in ID order
+
+		RevokedShareLinks: []RevokedShareLink{},
+	}
+
+	storage.loginMutex.Lock()
+	for _, user := range storage.users {
+		backup.Users = append(backup.Users, BackupUser{
+			ID: user.ID, SecretCode: user.SecretCode, Name: user.Name, Email: user.Email, OrgID: user.OrgID,
+			IsAdmin: user.IsAdmin, IsSuperAdmin: user.IsSuperAdmin, LastLoginAt: user.LastLoginAt,
+			TOSVersion: user.TOSVersion, TOSAcceptedAt: user.TOSAcceptedAt,
+			Deactivated: !user.IsActive, DeactivatedAt: user.DeactivatedAt, CredentialGeneration: user.CredentialGeneration,
+		})
+	}
+	storage.loginMutex.Unlock()
+	sort.Slice(backup.Users, func(i, j int) bool { return backup.Users[i].ID < backup.Users[j].ID })
+
+	for _, complaint := range storage.complaints {
+		status := statusOpen
+		if complaint.IsResolved {
+			status = statusResolved
+		}
+		backup.Complaints = append(backup.Complaints, BackupComplaint{Complaint: *complaint, Status: status, Watchers: complaint.Watchers, Related: complaint.Related})
+	}
+	sort.Slice(backup.Complaints, func(i, j int) bool { return backup.Complaints[i].ID < backup.Complaints[j].ID })
+
+	for _, inbox := range storage.notifications {
+		for _, notification := range inbox {
+			backup.Notifications = append(backup.Notifications, *notification)
+		}
+	}
+	sort.Slice(backup.Notifications, func(i, j int) bool { return backup.Notifications[i].ID < backup.Notifications[j].ID })
+
+	storage.loginMutex.Lock()
+	for userID, ring := range storage.loginHistory {
+		entries := ring.newestFirst(maxLoginHistory)
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+		backup.LoginHistory = append(backup.LoginHistory, BackupLoginHistory{UserID: userID, Entries: entries})
+	}
+	storage.loginMutex.Unlock()
+	sort.Slice(backup.LoginHistory, func(i, j int) bool { return backup.LoginHistory[i].UserID < backup.LoginHistory[j].UserID })
+
+	for userID, times := range storage.submissions {
+		if len(times) > 0 {
+			backup.Submissions = append(backup.Submissions, BackupSubmissions{UserID: userID, Times: append([]time.Time{}, times...)})
+		}
+	}
+	sort.Slice(backup.Submissions, func(i, j int) bool { return backup.Submissions[i].UserID < backup.Submissions[j].UserID })
+
+	for _, department := range storage.departments {
+		backup.Departments = append(backup.Departments, *department)
+	}
+	sort.Slice(backup.Departments, func(i, j int) bool { return backup.Departments[i].ID < backup.Departments[j].ID })
+
+	for hash, token := range storage.apiTokens {
+		entry := BackupAPIToken{APIToken: *token, Hash: hash}
+		if !token.expires.IsZero() {
+			expires := token.expires
+			entry.Expires = &expires
+		}
+		backup.APITokens = append(backup.APITokens, entry)
+	}
+	sort.Slice(backup.APITokens, func(i, j int) bool { return backup.APITokens[i].ID < backup.APITokens[j].ID })
+
+	for _, saved := range storage.savedFilters {
+		backup.SavedFilters = append(backup.SavedFilters, *saved)
+	}
+	sort.Slice(backup.SavedFilters, func(i, j int) bool { return backup.SavedFilters[i].ID < backup.SavedFilters[j].ID })
+
+	for _, org := range storage.organizations {
+		backup.Organizations = append(backup.Organizations, BackupOrganization{Organization: *org, LastAssigneeID: org.lastAssigneeID})
+	}
+	sort.Slice(backup.Organizations, func(i, j int) bool { return backup.Organizations[i].ID < backup.Organizations[j].ID })
+
+	for _, invite := range storage.invites {
+		entry := BackupInvite{Invite: *invite}
+		if !invite.expires.IsZero() {
+			expires := invite.expires
+			entry.Expires = &expires
+		}
+		backup.Invites = append(backup.Invites, entry)
+	}
+	sort.Slice(backup.Invites, func(i, j int) bool { return backup.Invites[i].ID < backup.Invites[j].ID })
+
+	for _, message := range storage.outbox {
+		entry := BackupOutboxMessage{OutboxMessage: *message}
+		if !message.nextAttempt.IsZero() {
+			nextAttempt := message.nextAttempt
+			entry.NextAttempt = &nextAttempt
+		}
+		backup.Outbox = append(backup.Outbox, entry)
+	}
+	sort.Slice(backup.Outbox, func(i, j int) bool { return backup.Outbox[i].ID < backup.Outbox[j].ID })
+
+	for _, rule := range storage.tagRules {
+		backup.TagRules = append(backup.TagRules, *rule)
+	}
+	sort.Slice(backup.TagRules, func(i, j int) bool { return backup.TagRules[i].ID < backup.TagRules[j].ID })
+
+	for _, announcement := range storage.announcements {
+		entry := BackupAnnouncement{Announcement: *announcement}
+		if !announcement.expires.IsZero() {
+			expires := announcement.expires
+			entry.Expires = &expires
+		}
+		backup.Announcements = append(backup.Announcements, entry)
+	}
+	sort.Slice(backup.Announcements, func(i, j int) bool { return backup.Announcements[i].ID < backup.Announcements[j].ID })
+
+	for _, building := range storage.buildings {
+		backup.Buildings = append(backup.Buildings, *building)
+	}
+	sort.Slice(backup.Buildings, func(i, j int) bool { return backup.Buildings[i].ID < backup.Buildings[j].ID })
+
+	for _, token := range storage.feedTokens {
+		backup.FeedTokens = append(backup.FeedTokens, BackupFeedToken{FeedToken: *token, Hash: token.hash})
+	}
+	sort.Slice(backup.FeedTokens, func(i, j int) bool { return backup.FeedTokens[i].UserID < backup.FeedTokens[j].UserID })
+
+	for id, expires := range storage.revokedShares {
+		backup.RevokedShareLinks = append(backup.RevokedShareLinks, RevokedShareLink{ID: id, Expires: expires})
+	}
+	sort.Slice(backup.RevokedShareLinks, func(i, j int) bool { return backup.RevokedShareLinks[i].ID < backup.RevokedShareLinks[j].ID })
+
+	return backup
+}
+
+// storageFromBackup validates backup and builds the storage it describes.
+// Every reference must resolve and every counter must be at least the
+// largest ID it has handed out.
+func storageFromBackup(backup Backup) (*Storage, error) {
+	restored := newStorage()
+	counters := backup.Counters
+
+	inviteCodes := map[string]int{}
+	for _, entry := range backup.Organizations {
+		org := entry.Organization
+		if org.ID <= 0 || org.ID > counters.OrganizationID {
+			return nil, fmt.Errorf("organization %d: ID must be between 1 and the organization counter (%d)", org.ID, counters.OrganizationID)
+		}
+		if _, duplicate := restored.organizations[org.ID]; duplicate {
+			return nil, fmt.Errorf("organization %d: duplicate ID", org.ID)
+		}
+		if strings.TrimSpace(org.Name) == "" {
+			return nil, fmt.Errorf("organization %d: name is required", org.ID)
+		}
+		if other, taken := inviteCodes[org.InviteCode]; taken && org.InviteCode != "" {
+			return nil, fmt.Errorf("organization %d: invite code is shared with organization %d", org.ID, other)
+		}
+		inviteCodes[org.InviteCode] = org.ID
+		org.lastAssigneeID = entry.LastAssigneeID
+		restored.organizations[org.ID] = &org
+	}
+	if restored.organizations[counters.DefaultOrgID] == nil {
+		return nil, fmt.Errorf("default organization %d does not exist", counters.DefaultOrgID)
+	}
+
+	for _, entry := range backup.Users {
+		if entry.ID <= 0 || entry.ID > counters.UserID {
+			return nil, fmt.Errorf("user %d: ID must be between 1 and the user counter (%d)", entry.ID, counters.UserID)
+		}
+		if _, duplicate := restored.users[entry.ID]; duplicate {
+			return nil, fmt.Errorf("user %d: duplicate ID", entry.ID)
+		}
+		if strings.TrimSpace(entry.SecretCode) == "" {
+			return nil, fmt.Errorf("user %d: secret code is required", entry.ID)
+		}
+		if owner, taken := restored.secretIndex[entry.SecretCode]; taken {
+			return nil, fmt.Errorf("user %d: secret code is shared with user %d", entry.ID, owner)
+		}
+		if restored.organizations[entry.OrgID] == nil {
+			return nil, fmt.Errorf("user %d: organization %d does not exist", entry.ID, entry.OrgID)
+		}
+		if entry.IsSuperAdmin && !entry.IsAdmin {
+			return nil, fmt.Errorf("user %d: a super-admin must be an admin", entry.ID)
+		}
+		restored.users[entry.ID] = &User{
+			ID: entry.ID, SecretCode: entry.SecretCode, Name: entry.Name, Email: entry.Email, Complaints: []Complaint{},
+			OrgID: entry.OrgID, IsAdmin: entry.IsAdmin, IsSuperAdmin: entry.IsSuperAdmin, LastLoginAt: entry.LastLoginAt,
+			TOSVersion: entry.TOSVersion, TOSAcceptedAt: entry.TOSAcceptedAt,
+			IsActive: !entry.Deactivated, DeactivatedAt: entry.DeactivatedAt, CredentialGeneration: entry.CredentialGeneration,
+		}
+		restored.secretIndex[entry.SecretCode] = entry.ID
+	}
+	if counters.DefaultAdminID != 0 {
+		if admin, exists := restored.users[counters.DefaultAdminID]; !exists || !admin.IsSuperAdmin {
+			return nil, fmt.Errorf("default admin %d is not a super-admin", counters.DefaultAdminID)
+		} else if !admin.IsActive {
+			return nil, fmt.Errorf("default admin %d is deactivated", counters.DefaultAdminID)
+		}
+	}
+	for _, org := range restored.organizations {
+		if org.lastAssigneeID == 0 {
+			continue
+		}
+		if assignee := restored.users[org.lastAssigneeID]; assignee == nil || assignee.OrgID != org.ID {
+			return nil, fmt.Errorf("organization %d: last assignee %d is not one of its users", org.ID, org.lastAssigneeID)
+		}
+	}
+	userExists := func(id int) bool { return restored.users[id] != nil }
+
+	for _, entry := range backup.Complaints {
+		complaint := entry.Complaint
+		if complaint.ID <= 0 || complaint.ID > counters.ComplaintID {
+			return nil, fmt.Errorf("complaint %d: ID must be between 1 and the complaint counter (%d)", complaint.ID, counters.ComplaintID)
+		}
+		if _, duplicate := restored.complaints[complaint.ID]; duplicate {
+			return nil, fmt.Errorf("complaint %d: duplicate ID", complaint.ID)
+		}
+		if !userExists(complaint.UserID) {
+			return nil, fmt.Errorf("complaint %d: user %d does not exist", complaint.ID, complaint.UserID)
+		}
+		if complaint.OrgID != restored.users[complaint.UserID].OrgID {
+			return nil, fmt.Errorf("complaint %d: organization %d is not its submitter's", complaint.ID, complaint.OrgID)
+		}
+		if complaint.AssignedTo != 0 && !userExists(complaint.AssignedTo) {
+			return nil, fmt.Errorf("complaint %d: assignee %d does not exist", complaint.ID, complaint.AssignedTo)
+		}
+		reference, ok := normalizeReference(complaint.Reference)
+		if !ok || reference != complaint.Reference {
+			return nil, fmt.Errorf("complaint %d: reference %q is not a valid reference", complaint.ID, complaint.Reference)
+		}
+		if other, duplicate := restored.references[reference]; duplicate {
+			return nil, fmt.Errorf("complaint %d: reference %s is also complaint %d's", complaint.ID, reference, other)
+		}
+		restored.references[reference] = complaint.ID
+		if !containsString(complaintSources, complaint.Source) {
+			return nil, fmt.Errorf("complaint %d: source %q is not one of %s", complaint.ID, complaint.Source, strings.Join(complaintSources, ", "))
+		}
+		if err := validateRatingScale(complaint.RatingScale); err != nil {
+			return nil, fmt.Errorf("complaint %d: rating scale: %v", complaint.ID, err)
+		}
+		complaint.RatingOutOfRange = false
+		switch entry.Status {
+		case statusOpen:
+			complaint.IsResolved = false
+		case statusResolved:
+			complaint.IsResolved = true
+		default:
+			return nil, fmt.Errorf("complaint %d: status must be %s or %s", complaint.ID, statusOpen, statusResolved)
+		}
+		complaint.Status = ""
+		for _, id := range entry.Watchers {
+			if !userExists(id) {
+				return nil, fmt.Errorf("complaint %d: watcher %d does not exist", complaint.ID, id)
+			}
+		}
+		complaint.Watchers = entry.Watchers
+		complaint.Related = entry.Related
+		complaint.words = similarityWords(complaint.Title, complaint.Summary)
+		restored.complaints[complaint.ID] = &complaint
+	}
+	for _, complaint := range restored.complaints {
+		if complaint.MergedInto != 0 && restored.complaints[complaint.MergedInto] == nil {
+			return nil, fmt.Errorf("complaint %d: merged into missing complaint %d", complaint.ID, complaint.MergedInto)
+		}
+		for _, id := range complaint.Duplicates {
+			if restored.complaints[id] == nil {
+				return nil, fmt.Errorf("complaint %d: duplicate %d does not exist", complaint.ID, id)
+			}
+		}
+		for _, id := range complaint.Related {
+			if other := restored.complaints[id]; other == nil || !isLinked(other, complaint.ID) {
+				return nil, fmt.Errorf("complaint %d: related complaint %d does not exist or does not link back", complaint.ID, id)
+			}
+		}
+	}
+
+	for i := range backup.Notifications {
+		notification := backup.Notifications[i]
+		if notification.ID <= 0 || notification.ID > counters.NotificationID {
+			return nil, fmt.Errorf("notification %d: ID must be between 1 and the notification counter (%d)", notification.ID, counters.NotificationID)
+		}
+		if !userExists(notification.UserID) {
+			return nil, fmt.Errorf("notification %d: user %d does not exist", notification.ID, notification.UserID)
+		}
+		restored.notifications[notification.UserID] = append(restored.notifications[notification.UserID], &notification)
+	}
+
+	for _, history := range backup.LoginHistory {
+		if !userExists(history.UserID) {
+			return nil, fmt.Errorf("login history: user %d does not exist", history.UserID)
+		}
+		ring := &loginRing{}
+		for _, entry := range history.Entries {
+			ring.add(entry)
+		}
+		restored.loginHistory[history.UserID] = ring
+	}
+
+	for _, submissions := range backup.Submissions {
+		if !userExists(submissions.UserID) {
+			return nil, fmt.Errorf("submissions: user %d does not exist", submissions.UserID)
+		}
+		restored.submissions[submissions.UserID] = submissions.Times
+	}
+
+	for i := range backup.Departments {
+		department := backup.Departments[i]
+		if department.ID <= 0 || department.ID > counters.DepartmentID {
+			return nil, fmt.Errorf("department %d: ID must be between 1 and the department counter (%d)", department.ID, counters.DepartmentID)
+		}
+		if _, duplicate := restored.departments[department.ID]; duplicate {
+			return nil, fmt.Errorf("department %d: duplicate ID", department.ID)
+		}
+		restored.departments[department.ID] = &department
+	}
+
+	for _, entry := range backup.APITokens {
+		token := entry.APIToken
+		if token.ID <= 0 || token.ID > counters.APITokenID {
+			return nil, fmt.Errorf("API token %d: ID must be between 1 and the API token counter (%d)", token.ID, counters.APITokenID)
+		}
+		if entry.Hash == "" || restored.apiTokens[entry.Hash] != nil {
+			return nil, fmt.Errorf("API token %d: missing or duplicate hash", token.ID)
+		}
+		if !userExists(token.CreatedBy) {
+			return nil, fmt.Errorf("API token %d: creator %d does not exist", token.ID, token.CreatedBy)
+		}
+		token.hash = entry.Hash
+		if entry.Expires != nil {
+			token.expires = *entry.Expires
+		}
+		restored.apiTokens[entry.Hash] = &token
+	}
+
+	for i := range backup.SavedFilters {
+		saved := backup.SavedFilters[i]
+		if saved.ID <= 0 || saved.ID > counters.SavedFilterID {
+			return nil, fmt.Errorf("saved filter %d: ID must be between 1 and the saved filter counter (%d)", saved.ID, counters.SavedFilterID)
+		}
+		if _, duplicate := restored.savedFilters[saved.ID]; duplicate {
+			return nil, fmt.Errorf("saved filter %d: duplicate ID", saved.ID)
+		}
+		if !userExists(saved.OwnerID) {
+			return nil, fmt.Errorf("saved filter %d: owner %d does not exist", saved.ID, saved.OwnerID)
+		}
+		if _, err := normalizeListFilter(saved.Filter); err != nil {
+			return nil, fmt.Errorf("saved filter %d: %v", saved.ID, err)
+		}
+		restored.savedFilters[saved.ID] = &saved
+	}
+
+	for _, entry := range backup.Invites {
+		invite := entry.Invite
+		if invite.ID <= 0 || invite.ID > counters.InviteID {
+			return nil, fmt.Errorf("invite %d: ID must be between 1 and the invite counter (%d)", invite.ID, counters.InviteID)
+		}
+		if _, duplicate := restored.invites[invite.ID]; duplicate {
+			return nil, fmt.Errorf("invite %d: duplicate ID", invite.ID)
+		}
+		if _, taken := inviteCodes[invite.Code]; taken || invite.Code == "" {
+			return nil, fmt.Errorf("invite %d: missing or duplicate code", invite.ID)
+		}
+		if restored.organizations[invite.OrgID] == nil {
+			return nil, fmt.Errorf("invite %d: organization %d does not exist", invite.ID, invite.OrgID)
+		}
+		if invite.MaxUses <= 0 || len(invite.UsedBy) > invite.MaxUses {
+			return nil, fmt.Errorf("invite %d: max uses must be positive and at least its uses", invite.ID)
+		}
+		if !userExists(invite.CreatedBy) {
+			return nil, fmt.Errorf("invite %d: creator %d does not exist", invite.ID, invite.CreatedBy)
+		}
+		for _, id := range invite.UsedBy {
+			if !userExists(id) {
+				return nil, fmt.Errorf("invite %d: user %d does not exist", invite.ID, id)
+			}
+		}
+		if invite.UsedBy == nil {
+			invite.UsedBy = []int{}
+		}
+		invite.Status = ""
+		if entry.Expires != nil {
+			invite.expires = *entry.Expires
+		}
+		inviteCodes[invite.Code] = 0
+		restored.invites[invite.ID] = &invite
+	}
+
+	for _, entry := range backup.Outbox {
+		message := entry.OutboxMessage
+		if message.ID <= 0 || message.ID > counters.OutboxID {
+			return nil, fmt.Errorf("outbox message %d: ID must be between 1 and the outbox counter (%d)", message.ID, counters.OutboxID)
+		}
+		if _, duplicate := restored.outbox[message.ID]; duplicate {
+			return nil, fmt.Errorf("outbox message %d: duplicate ID", message.ID)
+		}
+		if message.Channel != outboxChannelEmail && message.Channel != outboxChannelSlack {
+			return nil, fmt.Errorf("outbox message %d: channel must be %s or %s", message.ID, outboxChannelEmail, outboxChannelSlack)
+		}
+		if !json.Valid(message.Payload) {
+			return nil, fmt.Errorf("outbox message %d: payload must be JSON", message.ID)
+		}
+		if entry.NextAttempt != nil {
+			message.nextAttempt = *entry.NextAttempt
+		}
+		restored.outbox[message.ID] = &message
+	}
+
+	for i := range backup.TagRules {
+		rule := backup.TagRules[i]
+		if rule.ID <= 0 || rule.ID > counters.TagRuleID {
+			return nil, fmt.Errorf("tag rule %d: ID must be between 1 and the tag rule counter (%d)", rule.ID, counters.TagRuleID)
+		}
+		if _, duplicate := restored.tagRules[rule.ID]; duplicate {
+			return nil, fmt.Errorf("tag rule %d: duplicate ID", rule.ID)
+		}
+		if restored.organizations[rule.OrgID] == nil {
+			return nil, fmt.Errorf("tag rule %d: organization %d does not exist", rule.ID, rule.OrgID)
+		}
+		if rule.Tag == "" || len(rule.Keywords) == 0 {
+			return nil, fmt.Errorf("tag rule %d: tag and keywords are required", rule.ID)
+		}
+		restored.tagRules[rule.ID] = &rule
+	}
+
+	for _, entry := range backup.Announcements {
+		announcement := entry.Announcement
+		if announcement.ID <= 0 || announcement.ID > counters.AnnouncementID {
+			return nil, fmt.Errorf("announcement %d: ID must be between 1 and the announcement counter (%d)", announcement.ID, counters.AnnouncementID)
+		}
+		if _, duplicate := restored.announcements[announcement.ID]; duplicate {
+			return nil, fmt.Errorf("announcement %d: duplicate ID", announcement.ID)
+		}
+		if restored.organizations[announcement.OrgID] == nil {
+			return nil, fmt.Errorf("announcement %d: organization %d does not exist", announcement.ID, announcement.OrgID)
+		}
+		if !userExists(announcement.CreatedBy) {
+			return nil, fmt.Errorf("announcement %d: creator %d does not exist", announcement.ID, announcement.CreatedBy)
+		}
+		if announcement.Title == "" || announcement.Body == "" {
+			return nil, fmt.Errorf("announcement %d: title and body are required", announcement.ID)
+		}
+		if entry.Expires != nil {
+			announcement.expires = *entry.Expires
+		}
+		restored.announcements[announcement.ID] = &announcement
+	}
+
+	for i := range backup.Buildings {
+		building := backup.Buildings[i]
+		if building.ID <= 0 || building.ID > counters.BuildingID {
+			return nil, fmt.Errorf("building %d: ID must be between 1 and the building counter (%d)", building.ID, counters.BuildingID)
+		}
+		if _, duplicate := restored.buildings[building.ID]; duplicate {
+			return nil, fmt.Errorf("building %d: duplicate ID", building.ID)
+		}
+		if restored.organizations[building.OrgID] == nil {
+			return nil, fmt.Errorf("building %d: organization %d does not exist", building.ID, building.OrgID)
+		}
+		if building.Name == "" {
+			return nil, fmt.Errorf("building %d: name is required", building.ID)
+		}
+		restored.buildings[building.ID] = &building
+	}
+
+	for _, entry := range backup.FeedTokens {
+		token := entry.FeedToken
+		if !userExists(token.UserID) {
+			return nil, fmt.Errorf("feed token of user %d: user does not exist", token.UserID)
+		}
+		if _, duplicate := restored.feedTokens[token.UserID]; duplicate {
+			return nil, fmt.Errorf("feed token of user %d: user has two feed tokens", token.UserID)
+		}
+		if entry.Hash == "" {
+			return nil, fmt.Errorf("feed token of user %d: hash is required", token.UserID)
+		}
+		token.hash = entry.Hash
+		restored.feedTokens[token.UserID] = &token
+	}
+
+	for _, link := range backup.RevokedShareLinks {
+		if link.ID == "" {
+			return nil, fmt.Errorf("revoked share link: ID is required")
+		}
+		if _, duplicate := restored.revokedShares[link.ID]; duplicate {
+			return nil, fmt.Errorf("revoked share link %s: duplicate ID", link.ID)
+		}
+		restored.revokedShares[link.ID] = link.Expires
+	}
+
+	for i, entry := range backup.AuditLog {
+		if entry.ID <= 0 || entry.ID > counters.AuditID {
+			return nil, fmt.Errorf("audit entry %d: ID must be between 1 and the audit counter (%d)", entry.ID, counters.AuditID)
+		}
+		if i > 0 && entry.ID <= backup.AuditLog[i-1].ID {
+			return nil, fmt.Errorf("audit entry %d: entries must be in ID order", entry.ID)
+		}
+		if entry.Action == "" {
+			return nil, fmt.Errorf("audit entry %d: action is required", entry.ID)
+		}
+		restored.auditLog = append(restored.auditLog, entry)
+	}
+
+	restored.userIDGen = counters.UserID
+	restored.compIDGen = counters.ComplaintID
+	restored.notifIDGen = counters.NotificationID
+	restored.deptIDGen = counters.DepartmentID
+	restored.apiTokenIDGen = counters.APITokenID
+	restored.filterIDGen = counters.SavedFilterID
+	restored.orgIDGen = counters.OrganizationID
+	restored.inviteIDGen = counters.InviteID
+	restored.outboxIDGen = counters.OutboxID
+	restored.tagRuleIDGen = counters.TagRuleID
+	restored.announceIDGen = counters.AnnouncementID
+	restored.buildingIDGen = counters.BuildingID
+	restored.auditIDGen = counters.AuditID
+	restored.defaultAdminID = counters.DefaultAdminID
+	restored.defaultOrgID = counters.DefaultOrgID
+	return restored, nil
+}
+
+// replaceStorage swaps the contents of storage for restored's and rebuilds
+// each owner's User.Complaints. Callers must hold storage.mutex for writing.
+func replaceStorage(restored *Storage) {
+	storage.usersMutex.Lock()
+	storage.loginMutex.Lock()
+	storage.users = restored.users
+	storage.secretIndex = restored.secretIndex
+	storage.loginHistory = restored.loginHistory
+	storage.loginMutex.Unlock()
+	storage.usersMutex.Unlock()
+
+	storage.complaints = restored.complaints
+	storage.references = restored.references
+	storage.submissions = restored.submissions
+	storage.notifications = restored.notifications
+	storage.departments = restored.departments
+	storage.apiTokens = restored.apiTokens
+	storage.savedFilters = restored.savedFilters
+	storage.drafts = restored.drafts // drafts are private, so never backed up
+	storage.organizations = restored.organizations
+	storage.invites = restored.invites
+	storage.outbox = restored.outbox
+	storage.tagRules = restored.tagRules
+	storage.announcements = restored.announcements
+	storage.buildings = restored.buildings
+	storage.feedTokens = restored.feedTokens
+	storage.revokedShares = restored.revokedShares
+	storage.auditLog = restored.auditLog
+	storage.userIDGen = restored.userIDGen
+	storage.compIDGen = restored.compIDGen
+	storage.notifIDGen = restored.notifIDGen
+	storage.deptIDGen = restored.deptIDGen
+	storage.apiTokenIDGen = restored.apiTokenIDGen
+	storage.filterIDGen = restored.filterIDGen
+	storage.orgIDGen = restored.orgIDGen
+	storage.inviteIDGen = restored.inviteIDGen
+	storage.outboxIDGen = restored.outboxIDGen
+	storage.tagRuleIDGen = restored.tagRuleIDGen
+	storage.announceIDGen = restored.announceIDGen
+	storage.buildingIDGen = restored.buildingIDGen
+	storage.auditIDGen = restored.auditIDGen
+	storage.defaultAdminID = restored.defaultAdminID
+	storage.defaultOrgID = restored.defaultOrgID
+
+	for _, complaint := range storage.complaints {
+		syncUserComplaint(complaint)
+	}
+}
+
+// authenticateSuperAdminRequest authenticates a request carrying its secret
+// code in the secret_code query parameter or a header, as /exportComplaints
+// does, and requires a super-admin. On failure it has already written the
+// response.
+func authenticateSuperAdminRequest(w http.ResponseWriter, r *http.Request) *User {
+	secretCode := r.URL.Query().Get("secret_code")
+	if secretCode == "" {
+		secretCode = secretFromRequest(r)
+	}
+	if secretCode == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return nil
+	}
+
+	user := authenticate(w, r, secretCode)
+	if user == nil {
+		return nil
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return nil
+	}
+	return user
+}
+
+// /admin/backup - Download the whole of storage (super-admin only)
+func backupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if authenticateSuperAdminRequest(w, r) == nil {
+		return
+	}
+
+	storage.mutex.RLock()
+	backup := snapshotStorage()
+	storage.mutex.RUnlock()
+
+	// A large backup may take longer than the server's WriteTimeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	stamp := strings.NewReplacer("-", "", ":", "").Replace(backup.CreatedAt)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="complaint-portal-backup-%s.json"`, stamp))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(backup)
+}
+
+// /admin/restore - Replace the whole of storage with a backup (super-admin
+// only)
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if authenticateSuperAdminRequest(w, r) == nil {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBackupSize))
+	if isBodyTooLarge(err) {
+		respondWithAPIError(w, errBodyTooLarge(maxBackupSize))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Failed to read the backup")
+		return
+	}
+
+	backup, version, err := decodeBackup(data)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid backup: "+err.Error())
+		return
+	}
+	restored, err := storageFromBackup(backup)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid backup: "+err.Error())
+		return
+	}
+
+	storage.mutex.Lock()
+	replaceStorage(restored)
+	storage.mutex.Unlock()
+	dispatcher.notify()
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Backup restored",
+		Data:    restoreResult(backup, version),
+	})
+}
+
+// restoreResult summarizes backup, which was written in schema version
+func restoreResult(backup Backup, version int) RestoreResult {
+	return RestoreResult{
+		SchemaVersion: version,
+		Users:         len(backup.Users),
+		Complaints:    len(backup.Complaints),
+		Notifications: len(backup.Notifications),
+		Departments:   len(backup.Departments),
+		APITokens:     len(backup.APITokens),
+		SavedFilters:  len(backup.SavedFilters),
+		Organizations: len(backup.Organizations),
+		Invites:       len(backup.Invites),
+		Outbox:        len(backup.Outbox),
+		TagRules:      len(backup.TagRules),
+		Announcements: len(backup.Announcements),
+		Buildings:     len(backup.Buildings),
+		FeedTokens:    len(backup.FeedTokens),
+		AuditLog:      len(backup.AuditLog),
+
+		RevokedShareLinks: len(backup.RevokedShareLinks),
+	}
+}
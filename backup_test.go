@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fetchBackup downloads /admin/backup as the default admin
+func fetchBackup(t *testing.T, srv *httptest.Server) []byte {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/admin/backup", nil)
+	req.Header.Set("X-Secret-Code", adminSecret)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Backup request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Disposition"), "attachment;") {
+		t.Errorf("Expected a download, got Content-Disposition %q", resp.Header.Get("Content-Disposition"))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	return data
+}
+
+// restoreBackup posts document to /admin/restore as the default admin
+func restoreBackup(t *testing.T, srv *httptest.Server, document []byte) (int, testResponse) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/admin/restore", bytes.NewReader(document))
+	req.Header.Set("Authorization", "Bearer "+adminSecret)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Restore request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var result testResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	srv := newTestServer(t)
+	useFakeClock(t, time.Date(2024, 6, 3, 10, 0, 0, 0, time.Local))
+	_, userCode := registerTestUser(t, srv, "Backup User", "backup@example.com")
+	_, watcherCode := registerTestUser(t, srv, "Watcher", "watcher@example.com")
+	postJSON(t, srv, "/login", LoginRequest{SecretCode: userCode})
+	postJSON(t, srv, "/addDepartment", AddDepartmentRequest{SecretCode: adminSecret, Name: "Facilities", Keywords: []string{"heater"}})
+	first := submitTestComplaint(t, srv, userCode, "Heater broken", 8)
+	second := submitTestComplaint(t, srv, userCode, "Heater still broken", 6)
+	postJSON(t, srv, "/watchComplaint", WatchComplaintRequest{SecretCode: watcherCode, ComplaintID: first.ID})
+	postJSON(t, srv, "/addAdminNote", AddAdminNoteRequest{SecretCode: adminSecret, ComplaintID: first.ID, Note: "Parts ordered"})
+	postJSON(t, srv, "/mergeComplaints", MergeComplaintsRequest{SecretCode: adminSecret, PrimaryID: first.ID, DuplicateIDs: []int{second.ID}})
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: first.ID, Note: "Replaced"})
+	postJSON(t, srv, "/createApiToken", CreateAPITokenRequest{SecretCode: adminSecret, Label: "Wallboard", Scope: scopeRead, ExpiresInHours: 24})
+	postJSON(t, srv, "/createInvite", CreateInviteRequest{SecretCode: adminSecret, MaxUses: 5, ExpiresInHours: 48})
+
+	original := fetchBackup(t, srv)
+
+	// Wipe everything but the bootstrap admin, who performs the restore
+	storage = newStorage()
+	createDefaultAdmin()
+
+	status, resp := restoreBackup(t, srv, original)
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+	}
+	var result RestoreResult
+	resp.decode(t, &result)
+	if result.Users != 3 || result.Complaints != 2 || result.APITokens != 1 || result.Invites != 1 {
+		t.Errorf("Unexpected restore summary %+v", result)
+	}
+
+	if again := fetchBackup(t, srv); !bytes.Equal(again, original) {
+		t.Errorf("Expected the restored state to back up identically:\n%s\n%s", original, again)
+	}
+
+	status, resp = postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: userCode})
+	if status != http.StatusOK {
+		t.Fatalf("Expected the restored user to log in, got %d (%s)", status, resp.Error)
+	}
+	var complaints []Complaint
+	resp.decode(t, &complaints)
+	if len(complaints) != 2 || complaints[0].Status != "resolved" || complaints[1].MergedInto != first.ID {
+		t.Errorf("Expected both restored complaints, got %+v", complaints)
+	}
+}
+
+func TestRestoreValidation(t *testing.T) {
+	srv := newTestServer(t)
+	useFakeClock(t, time.Date(2024, 6, 3, 10, 0, 0, 0, time.Local))
+	_, userCode := registerTestUser(t, srv, "Backup User", "backup@example.com")
+	submitTestComplaint(t, srv, userCode, "Heater broken", 8)
+	original := fetchBackup(t, srv)
+
+	corrupt := func(change func(*Backup)) []byte {
+		var backup Backup
+		if err := json.Unmarshal(original, &backup); err != nil {
+			t.Fatalf("Failed to decode backup: %v", err)
+		}
+		change(&backup)
+		data, _ := json.Marshal(backup)
+		return data
+	}
+	tests := []struct {
+		name     string
+		document []byte
+	}{
+		{"Newer Format", corrupt(func(b *Backup) { b.SchemaVersion = backupSchemaVersion + 1 })},
+		{"Missing Format", corrupt(func(b *Backup) { b.SchemaVersion = 0 })},
+		{"Unknown Owner", corrupt(func(b *Backup) { b.Complaints[0].UserID = 99 })},
+		{"Counter Behind IDs", corrupt(func(b *Backup) { b.Counters.ComplaintID = 0 })},
+		{"Shared Secret Code", corrupt(func(b *Backup) { b.Users[1].SecretCode = b.Users[0].SecretCode })},
+		{"Unknown Organization", corrupt(func(b *Backup) { b.Users[1].OrgID = 99 })},
+		{"Complaint Outside Its Owner's Organization", corrupt(func(b *Backup) { b.Complaints[0].OrgID = 99 })},
+		{"Default Admin Not Super", corrupt(func(b *Backup) { b.Users[0].IsSuperAdmin = false })},
+		{"Not JSON", []byte("backup")},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if status, resp := restoreBackup(t, srv, tc.document); status != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d (%s)", status, resp.Error)
+			}
+		})
+	}
+
+	if after := fetchBackup(t, srv); !bytes.Equal(after, original) {
+		t.Error("Expected rejected restores to leave storage untouched")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/admin/backup?secret_code="+userCode, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Backup request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin backup, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+//go:embed templates/board.html templates/shared.html
+var templateFS embed.FS
+
+var boardTemplate = template.Must(template.ParseFS(templateFS, "templates/board.html"))
+
+// redactedName replaces submitter names on the board unless an admin token
+// is supplied
+const redactedName = "Anonymous"
+
+type boardRow struct {
+	Reference   string
+	Title       string
+	SubmittedBy string
+	Rating      int
+	Age         string
+	Escalated   bool
+}
+
+type boardPage struct {
+	Complaints  []boardRow
+	GeneratedAt string
+}
+
+// formatAge renders d as a compact age such as "3d 4h" or "25m"
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
+
+// /board - Read-only HTML wall display of open complaints, oldest first
+func boardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Names are shown only to admins, who pass their secret as ?token=
+	var viewer *User
+	if token := r.URL.Query().Get("token"); token != "" {
+		viewer = authenticate(w, r, token)
+		if viewer == nil {
+			return
+		}
+		if !authorize(w, adminOnly(actorOf(viewer))) {
+			return
+		}
+	}
+
+	now := clock.Now()
+	page := boardPage{GeneratedAt: now.Local().Format(timestampLayout)}
+
+	storage.mutex.RLock()
+	// Complaints awaiting moderation stay off the wall until approved
+	open := filterComplaints(viewer, func(c *Complaint) bool { return !c.NeedsReview }, listOptions{status: statusOpen, sort: sortOldest})
+	storage.mutex.RUnlock()
+
+	for _, complaint := range open {
+		row := boardRow{
+			Reference:   complaint.Reference,
+			Title:       complaint.Title,
+			SubmittedBy: redactedName,
+			Rating:      complaint.Rating,
+			Escalated:   complaint.Escalated,
+		}
+		if viewer != nil {
+			row.SubmittedBy = complaint.UserName
+		}
+		if createdAt, err := parseTimestamp(complaint.CreatedAt); err == nil {
+			row.Age = formatAge(now.Sub(createdAt))
+		}
+		page.Complaints = append(page.Complaints, row)
+	}
+
+	var body bytes.Buffer
+	if err := boardTemplate.Execute(&body, page); err != nil {
+		requestLogger(r).Error("Rendering board failed", logKeyError, err)
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to render board")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getBoard(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading board: %v", err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestBoard(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2024, 7, 1, 8, 0, 0, 0, time.Local))
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Mallory Doe", "mallory@example.com")
+
+	legacy := submitTestComplaint(t, srv, code, "Placeholder", 5)
+	// Submissions are sanitized, so plant markup directly to check the
+	// template still escapes anything that slips into storage
+	storage.mutex.Lock()
+	storage.complaints[legacy.ID].Title = "<script>alert('x')</script>"
+	storage.mutex.Unlock()
+	fake.Advance(26 * time.Hour)
+	resolved := submitTestComplaint(t, srv, code, "Already fixed", 2)
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: resolved.ID})
+
+	status, body := getBoard(t, srv.URL+"/board")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", status, body)
+	}
+
+	if strings.Contains(body, "<script>alert") {
+		t.Error("Complaint title was rendered as markup")
+	}
+	if !strings.Contains(body, "&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt;") {
+		t.Errorf("Expected the escaped title on the board:\n%s", body)
+	}
+	if strings.Contains(body, "Mallory") {
+		t.Error("Expected submitter names to be redacted without a token")
+	}
+	if !strings.Contains(body, redactedName) {
+		t.Error("Expected redacted placeholder on the board")
+	}
+	if strings.Contains(body, "Already fixed") {
+		t.Error("Expected resolved complaints to be left off the board")
+	}
+	if !strings.Contains(body, "1d 2h") {
+		t.Errorf("Expected the complaint age on the board:\n%s", body)
+	}
+
+	status, body = getBoard(t, srv.URL+"/board?token="+adminSecret)
+	if status != http.StatusOK || !strings.Contains(body, "Mallory Doe") {
+		t.Errorf("Expected names with the admin token, got %d", status)
+	}
+
+	if status, _ := getBoard(t, srv.URL+"/board?token="+code); status != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin token, got %d", status)
+	}
+	if status, _ := getBoard(t, srv.URL+"/board?token=WRONG"); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an invalid token, got %d", status)
+	}
+}
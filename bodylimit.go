@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// bodyLimit is the largest request body accepted on the route registered
+// as pattern. A new endpoint that takes uploads, such as attachments, gets
+// its own case here; every other route gets config.MaxBodySize.
+func bodyLimit(pattern string) int64 {
+	switch pattern {
+	case "/login", "/register":
+		return config.MaxLoginBodySize
+	case "/importComplaints", "/seed":
+		return config.MaxBulkBodySize
+	case "/admin/restore":
+		return maxBackupSize
+	}
+	return config.MaxBodySize
+}
+
+func validateBodyLimits() error {
+	if config.MaxBodySize <= 0 || config.MaxLoginBodySize <= 0 || config.MaxBulkBodySize <= 0 {
+		return errors.New("request body limits must be positive")
+	}
+	return nil
+}
+
+// formatSize renders a byte count the way limits are usually set, such as
+// "4 KB" or "10 MB"
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+func errBodyTooLarge(limit int64) *APIError {
+	return newAPIError(http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, "Request body must be at most "+formatSize(limit))
+}
+
+// isBodyTooLarge reports whether err came from reading past an
+// http.MaxBytesReader
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// limitBody holds r's body to limit bytes. A body declared larger is
+// refused unread. One of unknown length is read ahead, up to limit, so
+// that going over is answered with 413 before the handler sees any of it
+// rather than surfacing as a decoding error. On failure it has already
+// written the response.
+func limitBody(w http.ResponseWriter, r *http.Request, limit int64) bool {
+	if r.ContentLength > limit {
+		respondWithAPIError(w, errBodyTooLarge(limit))
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if r.ContentLength >= 0 {
+		return true
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if isBodyTooLarge(err) {
+		respondWithAPIError(w, errBodyTooLarge(limit))
+		return false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Failed to read request body")
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	return true
+}
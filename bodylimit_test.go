@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimits(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Limit User", "limit@example.com")
+	config.MaxBodySize = 64 << 10
+	config.MaxBulkBodySize = 256 << 10
+
+	// padded is a valid request whose unknown field pads it to size bytes
+	padded := func(fields map[string]interface{}, size int) []byte {
+		fields["padding"] = strings.Repeat("x", size)
+		data, _ := json.Marshal(fields)
+		return data
+	}
+	var upload bytes.Buffer
+	form := multipart.NewWriter(&upload)
+	form.WriteField("secret_code", adminSecret)
+	file, _ := form.CreateFormFile("file", "complaints.csv")
+	file.Write([]byte("email,title,summary,rating\n" + strings.Repeat("bulk@example.com,Title,Summary,5\n", 10000)))
+	form.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        []byte
+		chunked     bool // sent without a Content-Length
+		limit       string
+	}{
+		{"Login", "/login", "application/json", padded(map[string]interface{}{"secret_code": code}, 5<<10), false, "4 KB"},
+		{"Register Chunked", "/register", "application/json", padded(map[string]interface{}{"name": "Big", "email": "big@example.com"}, 5<<10), true, "4 KB"},
+		{"Submit", "/submitComplaint", "application/json", padded(map[string]interface{}{"secret_code": code, "title": "T", "summary": "S", "rating": 5}, 100<<10), false, "64 KB"},
+		{"Submit Chunked", "/submitComplaint", "application/json", padded(map[string]interface{}{"secret_code": code, "title": "T", "summary": "S", "rating": 5}, 100<<10), true, "64 KB"},
+		{"Import", "/importComplaints", form.FormDataContentType(), upload.Bytes(), false, "256 KB"},
+		{"Import Chunked", "/importComplaints", form.FormDataContentType(), upload.Bytes(), true, "256 KB"},
+	}
+	for _, tc := range tests {
+		var body io.Reader = bytes.NewReader(tc.body)
+		if tc.chunked {
+			body = io.MultiReader(body) // hides the length from the client
+		}
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+tc.path, body)
+		req.Header.Set("Content-Type", tc.contentType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tc.name, err)
+		}
+		var response testResponse
+		json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge || response.ErrorCode != ErrCodePayloadTooLarge || response.Error != "Request body must be at most "+tc.limit {
+			t.Errorf("%s: expected 413 stating the %s limit, got %d %+v", tc.name, tc.limit, resp.StatusCode, response)
+		}
+	}
+
+	t.Run("Still Responsive", func(t *testing.T) {
+		if status, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: code}); status != http.StatusOK {
+			t.Errorf("Expected login to work after the oversized requests, got %d (%s)", status, resp.Error)
+		}
+		complaint := submitTestComplaint(t, srv, code, "Within the limit", 5)
+		if complaint.ID == 0 {
+			t.Error("Expected a complaint within the limit to be accepted")
+		}
+	})
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{4 << 10: "4 KB", 10 << 20: "10 MB", 1500: "1500 bytes", 1<<20 + 1<<10: "1025 KB"} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// X-Cache values, telling how readCache answered a request
+const (
+	cacheHit    = "HIT"
+	cacheMiss   = "MISS"
+	cacheBypass = "BYPASS" // the request asked for Cache-Control: no-cache
+)
+
+// storageGeneration counts the writes to storage. Every write path takes
+// storage.mutex or storage.usersMutex for writing, and unlocking either
+// bumps it, so a generation that has not moved means nothing has changed.
+var storageGeneration atomic.Uint64
+
+// generationLock is a sync.RWMutex that bumps storageGeneration on every
+// write unlock, before letting go
+type generationLock struct {
+	sync.RWMutex
+}
+
+func (l *generationLock) Unlock() {
+	storageGeneration.Add(1)
+	l.RWMutex.Unlock()
+}
+
+// cachedResponse is a serialized response and what it was computed from
+type cachedResponse struct {
+	key         string
+	generation  uint64
+	storedAt    time.Time
+	contentType string
+	body        []byte
+}
+
+// responseCache keeps the serialized responses of hot read endpoints, up
+// to config.ResponseCacheSize of them, dropping the least recently used. A
+// response is served while storage is at the generation it was computed
+// at, and for config.ResponseCacheTTL at most, which bounds how stale
+// figures that move with the clock alone, such as is_overdue, can get.
+type responseCache struct {
+	entries map[string]*list.Element // of *cachedResponse
+	order   *list.List               // most recently used first
+	mutex   sync.Mutex
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{entries: make(map[string]*list.Element), order: list.New()}
+}
+
+var readCache = newResponseCache()
+
+// cacheKey identifies a response by endpoint, the caller it was shaped
+// for and the request's normalized parameters
+func cacheKey(endpoint string, userID int, params string) string {
+	return endpoint + "|" + strconv.Itoa(userID) + "|" + params
+}
+
+func (c *responseCache) get(key string, generation uint64, now time.Time) (*cachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	entry := element.Value.(*cachedResponse)
+	if entry.generation != generation || now.Sub(entry.storedAt) >= config.ResponseCacheTTL {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry, true
+}
+
+func (c *responseCache) put(entry *cachedResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, exists := c.entries[entry.key]; exists {
+		c.order.Remove(element)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > config.ResponseCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+func (c *responseCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+// noCache reports whether r asked for a fresh response
+func noCache(r *http.Request) bool {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return false
+}
+
+// serve answers r with the response build makes, from the cache when storage
+// has not changed since it was last made for key. build runs with
+// storage.mutex held for reading and must only read.
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request, key string, build func() APIResponse) {
+	if config.ResponseCacheSize <= 0 {
+		storage.mutex.RLock()
+		response := build()
+		storage.mutex.RUnlock()
+		respondWithJSON(w, http.StatusOK, response)
+		return
+	}
+
+	// JSON and XML are cached apart
+	if wantsXML(w) {
+		key += "|xml"
+	}
+	now := clock.Now()
+	status := cacheBypass
+	if !noCache(r) {
+		if entry, hit := c.get(key, storageGeneration.Load(), now); hit {
+			writeCached(w, cacheHit, entry)
+			return
+		}
+		status = cacheMiss
+	}
+
+	storage.mutex.RLock()
+	generation := storageGeneration.Load()
+	response := build()
+	storage.mutex.RUnlock()
+
+	contentType, body := encodeResponse(w, consistentResponse(http.StatusOK, response))
+	entry := &cachedResponse{key: key, generation: generation, storedAt: now, contentType: contentType, body: body}
+	c.put(entry)
+	writeCached(w, status, entry)
+}
+
+func writeCached(w http.ResponseWriter, status string, entry *cachedResponse) {
+	w.Header().Set("X-Cache", status)
+	w.Header().Set("Content-Type", entry.contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.body)
+}
+
+// cacheKey identifies the listing o describes, for readCache. The time
+// overdue is judged against is left out; the cache's TTL bounds how far
+// behind it gets.
+func (o listOptions) cacheKey() string {
+	key, _ := json.Marshal([]interface{}{
+		o.status, o.priority, o.escalated, o.overdue, o.tags, o.department, o.building, o.floor, o.source,
+		o.from, o.to, o.sort, o.page, o.pageSize, o.paginate, o.useCursor, o.cursor, o.fields,
+		o.full, o.includeArchived, o.pinnedFirst,
+	})
+	return string(key)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestReadCache(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.Local))
+	_, code := registerTestUser(t, srv, "Cache User", "cache@example.com")
+	complaint := submitTestComplaint(t, srv, code, "Radiator cold", 5)
+	submitTestComplaint(t, srv, code, "Window stuck", 6)
+
+	// list returns how the cache answered and the listing
+	list := func(req GetComplaintsRequest, cacheControl string) (string, []Complaint) {
+		t.Helper()
+		data, _ := json.Marshal(req)
+		httpReq, _ := http.NewRequest(http.MethodPost, srv.URL+"/getAllComplaintsForAdmin", bytes.NewReader(data))
+		if cacheControl != "" {
+			httpReq.Header.Set("Cache-Control", cacheControl)
+		}
+		resp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body testResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", resp.StatusCode, body.Error)
+		}
+		var complaints []Complaint
+		body.decode(t, &complaints)
+		return resp.Header.Get("X-Cache"), complaints
+	}
+	all := GetComplaintsRequest{SecretCode: adminSecret}
+
+	t.Run("Repeated Listing", func(t *testing.T) {
+		if status, _ := list(all, ""); status != cacheMiss {
+			t.Errorf("Expected the first listing computed, got %s", status)
+		}
+		status, complaints := list(all, "")
+		if status != cacheHit || len(complaints) != 2 {
+			t.Errorf("Expected the second listing from the cache, got %s with %d complaints", status, len(complaints))
+		}
+		// Parameters are part of the key
+		if status, complaints := list(GetComplaintsRequest{SecretCode: adminSecret, Status: "open", Sort: "oldest"}, ""); status != cacheMiss || len(complaints) != 2 {
+			t.Errorf("Expected another filter computed apart, got %s with %d complaints", status, len(complaints))
+		}
+	})
+
+	t.Run("Resolve Invalidates", func(t *testing.T) {
+		list(all, "")
+		if status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID}); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		status, complaints := list(all, "")
+		if status != cacheMiss {
+			t.Errorf("Expected the resolve to invalidate the listing, got %s", status)
+		}
+		for _, listed := range complaints {
+			if listed.ID == complaint.ID && !listed.IsResolved {
+				t.Errorf("Expected the listing to show the resolution, got %+v", listed)
+			}
+		}
+		if _, open := list(GetComplaintsRequest{SecretCode: adminSecret, Status: "open", Sort: "oldest"}, ""); len(open) != 1 {
+			t.Errorf("Expected the filtered listing invalidated too, got %d open complaints", len(open))
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		list(all, "")
+		fake.Advance(config.ResponseCacheTTL - time.Millisecond)
+		if status, _ := list(all, ""); status != cacheHit {
+			t.Errorf("Expected a hit just inside the TTL, got %s", status)
+		}
+		fake.Advance(time.Millisecond)
+		if status, _ := list(all, ""); status != cacheMiss {
+			t.Errorf("Expected the TTL to expire the listing, got %s", status)
+		}
+	})
+
+	t.Run("No Cache", func(t *testing.T) {
+		list(all, "")
+		if status, _ := list(all, "max-age=0, No-Cache"); status != cacheBypass {
+			t.Errorf("Expected no-cache to bypass the cache, got %s", status)
+		}
+	})
+
+	t.Run("Bounded", func(t *testing.T) {
+		config.ResponseCacheSize = 2
+		defer func() { config.ResponseCacheSize = defaultConfig().ResponseCacheSize }()
+		readCache = newResponseCache()
+		first := GetComplaintsRequest{SecretCode: adminSecret, Sort: "oldest"}
+		second := GetComplaintsRequest{SecretCode: adminSecret, Sort: "newest"}
+		third := GetComplaintsRequest{SecretCode: adminSecret, Sort: "rating_desc"}
+		list(first, "")
+		list(second, "")
+		list(first, "") // now the most recently used
+		list(third, "")
+		if got := readCache.len(); got != 2 {
+			t.Errorf("Expected 2 cached responses, got %d", got)
+		}
+		if status, _ := list(first, ""); status != cacheHit {
+			t.Errorf("Expected the recently used listing kept, got %s", status)
+		}
+		if status, _ := list(second, ""); status != cacheMiss {
+			t.Errorf("Expected the least recently used listing evicted, got %s", status)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		config.ResponseCacheSize = 0
+		defer func() { config.ResponseCacheSize = defaultConfig().ResponseCacheSize }()
+		list(all, "")
+		if status, complaints := list(all, ""); status != "" || len(complaints) != 2 {
+			t.Errorf("Expected no caching, got %q with %d complaints", status, len(complaints))
+		}
+	})
+}
+
+// Every cached listing matches what the same request computes afresh
+func TestReadCacheMatchesFresh(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Fresh User", "fresh@example.com")
+	for i := 0; i < 3; i++ {
+		submitTestComplaint(t, srv, code, fmt.Sprintf("Complaint %d", i), 5)
+	}
+	fetch := func(cacheControl string) []byte {
+		data, _ := json.Marshal(GetComplaintsRequest{SecretCode: adminSecret, Sort: "oldest"})
+		req := httptest.NewRequest(http.MethodPost, "/getAllComplaintsForAdmin", bytes.NewReader(data))
+		if cacheControl != "" {
+			req.Header.Set("Cache-Control", cacheControl)
+		}
+		rec := httptest.NewRecorder()
+		srv.Config.Handler.ServeHTTP(rec, req)
+		return rec.Body.Bytes()
+	}
+	fetch("")
+	if cached, fresh := fetch(""), fetch("no-cache"); !bytes.Equal(cached, fresh) {
+		t.Errorf("Expected the cached listing to match a fresh one\n%s\n%s", cached, fresh)
+	}
+}
+
+// BenchmarkRepeatedAdminListing lists every complaint as the same admin
+// over and over, with and without the cache, and reports the p99 latency
+func BenchmarkRepeatedAdminListing(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		size int
+	}{
+		{"Uncached", 0},
+		{"Cached", defaultConfig().ResponseCacheSize},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			benchmarkHandler(b)
+			// Without the test server's checks, which decode every response
+			handler := newHandler()
+			config.ResponseCacheSize = bc.size
+			data, _ := json.Marshal(GetComplaintsRequest{SecretCode: adminSecret})
+			latencies := make([]time.Duration, 0, b.N)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/getAllComplaintsForAdmin", bytes.NewReader(data))
+				rec := httptest.NewRecorder()
+				start := time.Now()
+				handler.ServeHTTP(rec, req)
+				latencies = append(latencies, time.Since(start))
+				if rec.Code != http.StatusOK {
+					b.Fatalf("Expected 200, got %d", rec.Code)
+				}
+				io.Copy(io.Discard, rec.Body)
+			}
+			b.StopTimer()
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPKey is the context key of the client address found by
+// withClientIP
+type clientIPKey struct{}
+
+// parseTrustedProxies reads a comma-separated list of CIDR ranges; a bare
+// address stands for itself
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q is not an address or CIDR range", item)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is not an address or CIDR range", item)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// parseHopAddr reads an address as it appears in RemoteAddr or an
+// X-Forwarded-For entry: bare, or with a port ("1.2.3.4:80", "[::1]:80")
+func parseHopAddr(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if addr, err := netip.ParseAddr(strings.Trim(value, "[]")); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveClientIP finds the client of a request from its immediate peer and
+// X-Forwarded-For headers. Only a trusted peer's header is believed: it is
+// read right to left, skipping trusted proxies, and the first untrusted hop
+// is the client. If every hop is trusted the leftmost is the client. A
+// malformed header is ignored in favour of the peer. ok is false when even
+// the peer's address cannot be read.
+func resolveClientIP(remoteAddr string, forwardedFor []string, trusted []netip.Prefix) (netip.Addr, bool) {
+	peer, ok := parseHopAddr(remoteAddr)
+	if !ok || !isTrustedProxy(peer, trusted) {
+		return peer, ok
+	}
+
+	var hops []string
+	for _, header := range forwardedFor {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	if len(hops) == 0 {
+		return peer, true
+	}
+
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHopAddr(hops[i])
+		if !ok {
+			return peer, true
+		}
+		client = hop
+		if !isTrustedProxy(hop, trusted) {
+			break
+		}
+	}
+	return client, true
+}
+
+// withClientIP resolves the client address of each request once, for
+// clientIP to return
+func withClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		if addr, ok := resolveClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For"), config.TrustedProxies); ok {
+			ip = addr.String()
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+	})
+}
+
+// clientIP is the address of the client that made r, behind any trusted
+// proxies. Use it rather than RemoteAddr everywhere.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	// Requests that did not pass through withClientIP, as in handler tests
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
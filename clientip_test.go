@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1, fd00::/8")
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{"Direct Client", "203.0.113.7:51234", nil, "203.0.113.7"},
+		{"Spoofed Header From Untrusted Peer", "203.0.113.7:51234", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"Trusted Proxy", "10.0.0.2:443", []string{"198.51.100.9"}, "198.51.100.9"},
+		{"Chained Proxies", "10.0.0.2:443", []string{"198.51.100.9, 192.168.1.1, 10.1.2.3"}, "198.51.100.9"},
+		{"Client Prepends A Spoofed Hop", "10.0.0.2:443", []string{"6.6.6.6, 198.51.100.9, 10.1.2.3"}, "198.51.100.9"},
+		{"Repeated Headers", "10.0.0.2:443", []string{"6.6.6.6", "198.51.100.9", "10.1.2.3"}, "198.51.100.9"},
+		{"Every Hop Trusted", "10.0.0.2:443", []string{"10.9.9.9, 10.1.2.3"}, "10.9.9.9"},
+		{"No Header From Trusted Peer", "10.0.0.2:443", nil, "10.0.0.2"},
+		{"Malformed Header", "10.0.0.2:443", []string{"198.51.100.9, not-an-ip"}, "10.0.0.2"},
+		{"Empty Hop", "10.0.0.2:443", []string{"198.51.100.9,,"}, "10.0.0.2"},
+		{"IPv6 Peer With Port", "[2001:db8::1]:8443", []string{"1.2.3.4"}, "2001:db8::1"},
+		{"IPv6 Trusted Peer", "[fd00::2]:443", []string{"2001:db8::5"}, "2001:db8::5"},
+		{"IPv6 Hop With Port", "[fd00::2]:443", []string{"[2001:db8::5]:1234, fd00::3"}, "2001:db8::5"},
+		{"IPv4 Hop With Port", "10.0.0.2:443", []string{"198.51.100.9:5555"}, "198.51.100.9"},
+		{"IPv4-Mapped Peer", "[::ffff:10.0.0.2]:443", []string{"198.51.100.9"}, "198.51.100.9"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := resolveClientIP(tc.remoteAddr, tc.forwardedFor, trusted)
+			if !ok || got.String() != tc.want {
+				t.Errorf("Expected %s, got %s (ok %v)", tc.want, got, ok)
+			}
+		})
+	}
+
+	if _, ok := resolveClientIP("not an address", []string{"1.2.3.4"}, trusted); ok {
+		t.Error("Expected an unreadable peer address to be reported")
+	}
+	for _, invalid := range []string{"10.0.0.0/33", "proxy.internal", "10.0.0.0/8,,nope"} {
+		if _, err := parseTrustedProxies(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	newTestServer(t)
+	config.TrustedProxies, _ = parseTrustedProxies("127.0.0.1")
+
+	var got string
+	handler := withClientIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientIP(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = "127.0.0.1:40000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.9")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "198.51.100.9" {
+		t.Errorf("Expected the forwarded client, got %q", got)
+	}
+
+	// Whatever the peer sends, an unreadable address falls back to RemoteAddr
+	req.RemoteAddr = "pipe"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "pipe" {
+		t.Errorf("Expected RemoteAddr, got %q", got)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import "time"
+
+// Clock abstracts time.Now so time-dependent behaviour can be tested
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+var clock Clock = realClock{}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiResponse mirrors the server's response envelope
+type apiResponse struct {
+	Success   bool            `json:"success"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data"`
+	Error     string          `json:"error"`
+	ErrorCode string          `json:"error_code"`
+}
+
+type user struct {
+	ID         int    `json:"id"`
+	SecretCode string `json:"secret_code"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	IsAdmin    bool   `json:"is_admin"`
+}
+
+type complaint struct {
+	ID             int    `json:"id"`
+	Title          string `json:"title"`
+	Summary        string `json:"summary"`
+	Rating         int    `json:"rating"`
+	UserID         int    `json:"user_id"`
+	UserName       string `json:"user_name,omitempty"`
+	IsResolved     bool   `json:"is_resolved"`
+	CreatedAt      string `json:"created_at"`
+	ResolvedAt     string `json:"resolved_at,omitempty"`
+	ResolutionNote string `json:"resolution_note,omitempty"`
+}
+
+// apiError is a non-2xx response from the server
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s (%d %s)", e.Message, e.Status, e.Code)
+	}
+	return fmt.Sprintf("%s (%d)", e.Message, e.Status)
+}
+
+// clientSource is sent as X-Client-Source, so complaints filed from here
+// are recorded as coming from the CLI
+const clientSource = "cli"
+
+type client struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newClient(baseURL string) *client {
+	return &client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// call POSTs payload to endpoint and decodes the response data into out
+func (c *client) call(endpoint string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-Source", clientSource)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var decoded apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("unexpected response from %s (%d): %v", endpoint, resp.StatusCode, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := decoded.Error
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return &apiError{Status: resp.StatusCode, Code: decoded.ErrorCode, Message: message}
+	}
+
+	if out != nil && len(decoded.Data) > 0 && string(decoded.Data) != "null" {
+		return json.Unmarshal(decoded.Data, out)
+	}
+	return nil
+}
@@ -0,0 +1,419 @@
+// Command complaintctl manages complaints from a terminal.
+//
+//	complaintctl register --name "Jane Doe" --email jane@example.com [--accept-tos]
+//	complaintctl login --secret SEC_1696348800_2
+//	complaintctl submit --title "Broken elevator" --summary "Stuck on 3" --rating 8
+//	complaintctl list [--all] [--unresolved] [--json | --table]
+//	complaintctl view <id> [--json]
+//	complaintctl resolve <id> [--note "Replaced the cable"]
+//
+// login stores the server URL and secret code in a config file
+// (~/.complaintctl.json, or $COMPLAINTCTL_CONFIG) used by later commands.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// Exit codes
+const (
+	exitOK       = 0
+	exitError    = 1 // network failures and other API errors
+	exitUsage    = 2
+	exitAuth     = 3 // 401, 403 and 423 responses
+	exitNotFound = 4
+	exitConflict = 5 // 409 responses
+)
+
+// cliConfig is persisted between invocations by login
+type cliConfig struct {
+	Server     string `json:"server"`
+	SecretCode string `json:"secret_code"`
+}
+
+func configPath() string {
+	if path := os.Getenv("COMPLAINTCTL_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".complaintctl.json"
+	}
+	return filepath.Join(home, ".complaintctl.json")
+}
+
+func loadConfig(path string) cliConfig {
+	cfg := cliConfig{Server: defaultServer}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg
+	}
+	json.Unmarshal(data, &cfg)
+	if cfg.Server == "" {
+		cfg.Server = defaultServer
+	}
+	return cfg
+}
+
+func saveConfig(path string, cfg cliConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The file holds a credential, so keep it private
+	return os.WriteFile(path, data, 0600)
+}
+
+// cli carries the state shared by every subcommand
+type cli struct {
+	stdout     io.Writer
+	stderr     io.Writer
+	configPath string
+	config     cliConfig
+	client     *client
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("complaintctl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	server := global.String("server", "", "API base URL (default from config, else "+defaultServer+")")
+	cfgPath := global.String("config", configPath(), "path of the config file written by login")
+	global.Usage = func() { usage(stderr) }
+	if err := global.Parse(args); err != nil {
+		return exitUsage
+	}
+	if global.NArg() == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	c := &cli{stdout: stdout, stderr: stderr, configPath: *cfgPath}
+	c.config = loadConfig(c.configPath)
+	if *server != "" {
+		c.config.Server = *server
+	}
+	c.client = newClient(c.config.Server)
+
+	commands := map[string]func([]string) error{
+		"register": c.register,
+		"login":    c.login,
+		"submit":   c.submit,
+		"list":     c.list,
+		"view":     c.view,
+		"resolve":  c.resolve,
+	}
+	command, exists := commands[global.Arg(0)]
+	if !exists {
+		fmt.Fprintf(stderr, "unknown command %q\n", global.Arg(0))
+		usage(stderr)
+		return exitUsage
+	}
+
+	if err := command(global.Args()[1:]); err != nil {
+		return report(stderr, err)
+	}
+	return exitOK
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, `usage: complaintctl [--server URL] [--config PATH] <command> [flags]
+
+commands:
+  register --name NAME --email EMAIL [--accept-tos]
+  login    --secret CODE
+  submit   --title TITLE --summary TEXT --rating N
+  list     [--all] [--unresolved] [--json | --table]
+  view     <id> [--json]
+  resolve  <id> [--note TEXT]`)
+}
+
+// usageError marks errors caused by bad command-line input
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+// report prints err and maps it to an exit code
+func report(stderr io.Writer, err error) int {
+	fmt.Fprintln(stderr, "error:", err)
+
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		return exitUsage
+	}
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusLocked:
+			return exitAuth
+		case http.StatusNotFound:
+			return exitNotFound
+		case http.StatusConflict:
+			return exitConflict
+		}
+	}
+	return exitError
+}
+
+// parseArgs parses flags that may appear before or after positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageError{err.Error()}
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func (c *cli) requireSecret() error {
+	if c.config.SecretCode == "" {
+		return usageError{"not logged in; run complaintctl login --secret CODE first"}
+	}
+	return nil
+}
+
+func parseID(positional []string) (int, error) {
+	if len(positional) != 1 {
+		return 0, usageError{"expected exactly one complaint ID"}
+	}
+	id, err := strconv.Atoi(positional[0])
+	if err != nil || id <= 0 {
+		return 0, usageError{fmt.Sprintf("invalid complaint ID %q", positional[0])}
+	}
+	return id, nil
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (c *cli) register(args []string) error {
+	fs := flag.NewFlagSet("register", flag.ContinueOnError)
+	name := fs.String("name", "", "full name")
+	email := fs.String("email", "", "email address")
+	acceptTOS := fs.Bool("accept-tos", false, "accept the server's terms of service, which it may require")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *name == "" || *email == "" {
+		return usageError{"register requires --name and --email"}
+	}
+
+	var registered user
+	payload := map[string]interface{}{"name": *name, "email": *email, "accept_tos": *acceptTOS}
+	if err := c.client.call("/register", payload, &registered); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Registered user %d (%s)\nSecret code: %s\nRun: complaintctl login --secret %s\n",
+		registered.ID, registered.Email, registered.SecretCode, registered.SecretCode)
+	return nil
+}
+
+func (c *cli) login(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	secret := fs.String("secret", "", "secret code")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *secret == "" && len(positional) == 1 {
+		*secret = positional[0]
+	}
+	if *secret == "" {
+		return usageError{"login requires --secret"}
+	}
+
+	var loggedIn user
+	if err := c.client.call("/login", map[string]string{"secret_code": *secret}, &loggedIn); err != nil {
+		return err
+	}
+
+	c.config.SecretCode = *secret
+	if err := saveConfig(c.configPath, c.config); err != nil {
+		return fmt.Errorf("logged in but could not save %s: %v", c.configPath, err)
+	}
+
+	role := "user"
+	if loggedIn.IsAdmin {
+		role = "admin"
+	}
+	fmt.Fprintf(c.stdout, "Logged in as %s (%s, %s)\n", loggedIn.Name, loggedIn.Email, role)
+	return nil
+}
+
+func (c *cli) submit(args []string) error {
+	fs := flag.NewFlagSet("submit", flag.ContinueOnError)
+	title := fs.String("title", "", "complaint title")
+	summary := fs.String("summary", "", "complaint details")
+	rating := fs.Int("rating", 0, "severity from 1 to 10")
+	asJSON := fs.Bool("json", false, "print the created complaint as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if err := c.requireSecret(); err != nil {
+		return err
+	}
+
+	var created complaint
+	err := c.client.call("/submitComplaint", map[string]interface{}{
+		"secret_code": c.config.SecretCode,
+		"title":       *title,
+		"summary":     *summary,
+		"rating":      *rating,
+	}, &created)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return c.printJSON(created)
+	}
+	fmt.Fprintf(c.stdout, "Submitted complaint %d: %s\n", created.ID, created.Title)
+	return nil
+}
+
+func (c *cli) list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	all := fs.Bool("all", false, "list every complaint (admin only)")
+	unresolved := fs.Bool("unresolved", false, "only open complaints")
+	asJSON := fs.Bool("json", false, "print JSON")
+	asTable := fs.Bool("table", false, "print a table (default)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *asJSON && *asTable {
+		return usageError{"--json and --table are mutually exclusive"}
+	}
+	if err := c.requireSecret(); err != nil {
+		return err
+	}
+
+	endpoint := "/getAllComplaintsForUser"
+	if *all {
+		endpoint = "/getAllComplaintsForAdmin"
+	}
+	payload := map[string]interface{}{"secret_code": c.config.SecretCode}
+	if *unresolved {
+		payload["status"] = "open"
+	}
+
+	complaints := []complaint{}
+	if err := c.client.call(endpoint, payload, &complaints); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return c.printJSON(complaints)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tRATING\tSTATUS\tSUBMITTER\tCREATED")
+	for _, item := range complaints {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n", item.ID, item.Title, item.Rating, status(item), item.UserName, item.CreatedAt)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) view(args []string) error {
+	fs := flag.NewFlagSet("view", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(positional)
+	if err != nil {
+		return err
+	}
+	if err := c.requireSecret(); err != nil {
+		return err
+	}
+
+	var found complaint
+	if err := c.client.call("/viewComplaint", map[string]interface{}{
+		"secret_code":  c.config.SecretCode,
+		"complaint_id": id,
+	}, &found); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return c.printJSON(found)
+	}
+	c.printComplaint(found)
+	return nil
+}
+
+func (c *cli) resolve(args []string) error {
+	fs := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	note := fs.String("note", "", "resolution note shown to the submitter")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(positional)
+	if err != nil {
+		return err
+	}
+	if err := c.requireSecret(); err != nil {
+		return err
+	}
+
+	var resolved complaint
+	if err := c.client.call("/resolveComplaint", map[string]interface{}{
+		"secret_code":  c.config.SecretCode,
+		"complaint_id": id,
+		"note":         *note,
+	}, &resolved); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Resolved complaint %d: %s\n", resolved.ID, resolved.Title)
+	return nil
+}
+
+func status(item complaint) string {
+	if item.IsResolved {
+		return "resolved"
+	}
+	return "open"
+}
+
+func (c *cli) printComplaint(item complaint) {
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%d\n", item.ID)
+	fmt.Fprintf(tw, "Title:\t%s\n", item.Title)
+	fmt.Fprintf(tw, "Rating:\t%d\n", item.Rating)
+	fmt.Fprintf(tw, "Status:\t%s\n", status(item))
+	fmt.Fprintf(tw, "Submitter:\t%s (user %d)\n", item.UserName, item.UserID)
+	fmt.Fprintf(tw, "Created:\t%s\n", item.CreatedAt)
+	if item.IsResolved {
+		fmt.Fprintf(tw, "Resolved:\t%s\n", item.ResolvedAt)
+	}
+	if item.ResolutionNote != "" {
+		fmt.Fprintf(tw, "Note:\t%s\n", item.ResolutionNote)
+	}
+	tw.Flush()
+	fmt.Fprintf(c.stdout, "\n%s\n", item.Summary)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubAPI imitates the complaint portal closely enough to drive the CLI
+type stubAPI struct {
+	requests map[string]map[string]interface{}
+	sources  map[string]string // X-Client-Source of the last request to each path
+}
+
+func newStubAPI(t *testing.T) (*stubAPI, *httptest.Server) {
+	stub := &stubAPI{requests: make(map[string]map[string]interface{}), sources: make(map[string]string)}
+	srv := httptest.NewServer(http.HandlerFunc(stub.serve))
+	t.Cleanup(srv.Close)
+	return stub, srv
+}
+
+func (s *stubAPI) serve(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	s.requests[r.URL.Path] = body
+	s.sources[r.URL.Path] = r.Header.Get("X-Client-Source")
+
+	respond := func(status int, data interface{}, errMsg, code string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": status < 300, "data": data, "error": errMsg, "error_code": code,
+		})
+	}
+
+	secret, _ := body["secret_code"].(string)
+	switch {
+	case r.URL.Path == "/register":
+		respond(http.StatusCreated, user{ID: 2, SecretCode: "SEC_1_2", Name: "Jane", Email: "jane@example.com"}, "", "")
+	case secret != "SEC_1_2" && secret != "ADMIN":
+		respond(http.StatusUnauthorized, nil, "Invalid secret code", "UNAUTHORIZED")
+	case r.URL.Path == "/login":
+		respond(http.StatusOK, user{ID: 2, Name: "Jane", Email: "jane@example.com", IsAdmin: secret == "ADMIN"}, "", "")
+	case r.URL.Path == "/getAllComplaintsForAdmin" && secret != "ADMIN":
+		respond(http.StatusForbidden, nil, "Access denied. Admin privileges required", "FORBIDDEN")
+	case r.URL.Path == "/getAllComplaintsForUser", r.URL.Path == "/getAllComplaintsForAdmin":
+		respond(http.StatusOK, []complaint{
+			{ID: 1, Title: "Broken elevator", Rating: 8, UserName: "Jane", CreatedAt: "2024-01-01 10:00:00"},
+			{ID: 2, Title: "Cold office", Rating: 4, UserName: "Jane", IsResolved: true, CreatedAt: "2024-01-02 10:00:00"},
+		}, "", "")
+	case r.URL.Path == "/submitComplaint":
+		respond(http.StatusCreated, complaint{ID: 3, Title: body["title"].(string)}, "", "")
+	case r.URL.Path == "/viewComplaint" || r.URL.Path == "/resolveComplaint":
+		if body["complaint_id"].(float64) != 1 {
+			respond(http.StatusNotFound, nil, "Complaint not found", "NOT_FOUND")
+			return
+		}
+		respond(http.StatusOK, complaint{ID: 1, Title: "Broken elevator", Summary: "Stuck on floor 3", Rating: 8, UserID: 2, UserName: "Jane"}, "", "")
+	default:
+		respond(http.StatusNotFound, nil, "not found", "NOT_FOUND")
+	}
+}
+
+type cliHarness struct {
+	t      *testing.T
+	server string
+	config string
+}
+
+func newHarness(t *testing.T, srv *httptest.Server) *cliHarness {
+	return &cliHarness{t: t, server: srv.URL, config: filepath.Join(t.TempDir(), "config.json")}
+}
+
+func (h *cliHarness) run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	full := append([]string{"--server", h.server, "--config", h.config}, args...)
+	code := run(full, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestLoginStoresSecret(t *testing.T) {
+	_, srv := newStubAPI(t)
+	h := newHarness(t, srv)
+
+	code, out, errOut := h.run("login", "--secret", "SEC_1_2")
+	if code != exitOK {
+		t.Fatalf("Expected exit 0, got %d (%s)", code, errOut)
+	}
+	if !strings.Contains(out, "Logged in as Jane") {
+		t.Errorf("Unexpected output %q", out)
+	}
+
+	data, err := os.ReadFile(h.config)
+	if err != nil {
+		t.Fatalf("Config file not written: %v", err)
+	}
+	var saved cliConfig
+	json.Unmarshal(data, &saved)
+	if saved.SecretCode != "SEC_1_2" || saved.Server != srv.URL {
+		t.Errorf("Unexpected saved config %+v", saved)
+	}
+	if info, _ := os.Stat(h.config); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected config mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	stub, srv := newStubAPI(t)
+	h := newHarness(t, srv)
+	if code, _, errOut := h.run("login", "SEC_1_2"); code != exitOK {
+		t.Fatalf("Login failed: %s", errOut)
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		code, out, _ := h.run("register", "--name", "Jane", "--email", "jane@example.com")
+		if code != exitOK || !strings.Contains(out, "Secret code: SEC_1_2") {
+			t.Errorf("Unexpected result %d %q", code, out)
+		}
+	})
+
+	t.Run("List Table", func(t *testing.T) {
+		code, out, _ := h.run("list", "--unresolved")
+		if code != exitOK {
+			t.Fatalf("Expected exit 0, got %d", code)
+		}
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "Broken elevator") || !strings.Contains(lines[2], "resolved") {
+			t.Errorf("Unexpected table:\n%s", out)
+		}
+		if stub.requests["/getAllComplaintsForUser"]["status"] != "open" {
+			t.Errorf("Expected --unresolved to send status open, got %v", stub.requests["/getAllComplaintsForUser"])
+		}
+	})
+
+	t.Run("List JSON", func(t *testing.T) {
+		code, out, _ := h.run("list", "--json")
+		var listed []complaint
+		if code != exitOK || json.Unmarshal([]byte(out), &listed) != nil || len(listed) != 2 {
+			t.Errorf("Expected two complaints as JSON, got %d %q", code, out)
+		}
+	})
+
+	t.Run("Submit", func(t *testing.T) {
+		code, out, _ := h.run("submit", "--title", "Noisy fan", "--summary", "Loud", "--rating", "3")
+		if code != exitOK || !strings.Contains(out, "Submitted complaint 3") {
+			t.Errorf("Unexpected result %d %q", code, out)
+		}
+		if stub.requests["/submitComplaint"]["rating"].(float64) != 3 {
+			t.Errorf("Expected rating 3 to be sent, got %v", stub.requests["/submitComplaint"])
+		}
+		if stub.sources["/submitComplaint"] != "cli" {
+			t.Errorf("Expected the CLI to name itself as the source, got %q", stub.sources["/submitComplaint"])
+		}
+	})
+
+	t.Run("View", func(t *testing.T) {
+		code, out, _ := h.run("view", "1")
+		if code != exitOK || !strings.Contains(out, "Stuck on floor 3") {
+			t.Errorf("Unexpected result %d %q", code, out)
+		}
+	})
+
+	t.Run("Resolve With Note", func(t *testing.T) {
+		code, _, _ := h.run("resolve", "1", "--note", "Replaced the cable")
+		if code != exitOK {
+			t.Fatalf("Expected exit 0, got %d", code)
+		}
+		if stub.requests["/resolveComplaint"]["note"] != "Replaced the cable" {
+			t.Errorf("Expected note to be sent, got %v", stub.requests["/resolveComplaint"])
+		}
+	})
+}
+
+func TestExitCodes(t *testing.T) {
+	_, srv := newStubAPI(t)
+	h := newHarness(t, srv)
+	h.run("login", "--secret", "SEC_1_2")
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"No Command", nil, exitUsage},
+		{"Unknown Command", []string{"frobnicate"}, exitUsage},
+		{"Bad ID", []string{"view", "abc"}, exitUsage},
+		{"Conflicting Output Flags", []string{"list", "--json", "--table"}, exitUsage},
+		{"Not Found", []string{"view", "99"}, exitNotFound},
+		{"Forbidden", []string{"list", "--all"}, exitAuth},
+		{"Unauthorized", []string{"login", "--secret", "WRONG"}, exitAuth},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if code, _, _ := h.run(tc.args...); code != tc.want {
+				t.Errorf("Expected exit %d, got %d", tc.want, code)
+			}
+		})
+	}
+
+	t.Run("Server Down", func(t *testing.T) {
+		down := &cliHarness{t: t, server: "http://127.0.0.1:1", config: h.config}
+		if code, _, _ := down.run("list"); code != exitError {
+			t.Errorf("Expected exit %d, got %d", exitError, code)
+		}
+	})
+
+	t.Run("Not Logged In", func(t *testing.T) {
+		fresh := newHarness(t, srv)
+		if code, _, _ := fresh.run("list"); code != exitUsage {
+			t.Errorf("Expected exit %d, got %d", exitUsage, code)
+		}
+	})
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// maxCommentLength caps a single comment, in characters
+const maxCommentLength = 5000
+
+// Who deleted a comment
+const (
+	commentDeletedByAuthor    = "author"
+	commentDeletedByModerator = "moderator"
+)
+
+// Bodies left in place of deleted comments, so replies to them still read
+// in order
+const (
+	commentTombstoneAuthor    = "[deleted]"
+	commentTombstoneModerator = "[removed by moderator]"
+)
+
+// Comment is a reply added to a complaint, through /addComment or as an
+// email answering one of its notifications. Deleted comments stay in the
+// thread as tombstones.
+type Comment struct {
+	ID        int    `json:"id" xml:"id"` // numbered from 1 within the complaint
+	UserID    int    `json:"user_id" xml:"user_id"`
+	UserName  string `json:"user_name" xml:"user_name"`
+	Body      string `json:"body" xml:"body"`
+	Source    string `json:"source,omitempty" xml:"source,omitempty"` // e.g. email
+	CreatedAt string `json:"created_at" xml:"created_at"`
+	EditedAt  string `json:"edited_at,omitempty" xml:"edited_at,omitempty"`
+	DeletedAt string `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+	DeletedBy string `json:"deleted_by,omitempty" xml:"deleted_by,omitempty"` // author or moderator
+}
+
+type AddCommentRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+	Body        string `json:"body"`
+}
+
+// CommentRequest is the body of /editComment and /deleteComment; Body is
+// only read when editing
+type CommentRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+	CommentID   int    `json:"comment_id"`
+	Body        string `json:"body,omitempty"`
+}
+
+// addComment appends a comment by author to complaint, records it and lets
+// the people following the complaint know. Comments are only shown to the
+// owner and admins, so only they are notified, and never the author; the
+// event goes to the owner's /ws and the admins' /events. Callers must hold
+// storage.mutex for writing.
+func addComment(complaint *Complaint, author *User, body, source, correlationID string) Comment {
+	comment := Comment{
+		// Deleted comments are kept, so IDs are never reused
+		ID:        len(complaint.Comments) + 1,
+		UserID:    author.ID,
+		UserName:  author.Name,
+		Body:      body,
+		Source:    source,
+		CreatedAt: getCurrentTime(),
+	}
+	complaint.Comments = append(complaint.Comments, comment)
+
+	detail := fmt.Sprintf("Comment #%d", comment.ID)
+	if source == sourceEmail {
+		detail += " by email"
+	}
+	addHistory(complaint, HistoryEntry{Action: historyCommented, ActorID: author.ID, Detail: detail, CorrelationID: correlationID})
+	touchComplaint(complaint)
+
+	message := fmt.Sprintf("%s commented on %q", author.Name, complaint.Title)
+	if author.ID != complaint.UserID {
+		notifyOwner(complaint, notificationCommented, message)
+	}
+	for _, id := range complaint.Watchers {
+		if watcher, exists := storage.users[id]; exists && id != author.ID && canViewComplaint(actorOfLocked(watcher), complaint).allowed() {
+			notifyUser(id, complaint, notificationCommented, message)
+		}
+	}
+	publishEvent(eventComplaintCommented, complaint, correlationID)
+	return comment
+}
+
+// findComment returns comment id of complaint, or nil
+func findComment(complaint *Complaint, id int) *Comment {
+	for i := range complaint.Comments {
+		if complaint.Comments[i].ID == id {
+			return &complaint.Comments[i]
+		}
+	}
+	return nil
+}
+
+// withinEditWindow reports whether comment's author may still change it
+func withinEditWindow(comment *Comment) bool {
+	created, err := parseTimestamp(comment.CreatedAt)
+	return err == nil && clock.Now().Sub(created) <= config.CommentEditWindow
+}
+
+// /addComment - Reply on a complaint. The submitter may comment on their
+// own complaints, admins on any in their organization.
+func addCommentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req AddCommentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	body := sanitizeText(req.Body, true)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("complaint_id", req.ComplaintID)
+	v.required("body", body)
+	v.maxRunes("body", body, maxCommentLength)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint, apiErr := accessibleComplaint(user, req.ComplaintID, false, "Access denied. You can only comment on your own complaints")
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	addComment(complaint, user, body, "", correlationID(r))
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Comment added successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
+
+// decodeCommentRequest reads and checks an edit or delete request, writing
+// the error response when it fails
+func decodeCommentRequest(w http.ResponseWriter, r *http.Request, editing bool) (CommentRequest, *User) {
+	var req CommentRequest
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return req, nil
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return req, nil
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("complaint_id", req.ComplaintID)
+	v.positiveID("comment_id", req.CommentID)
+	if editing {
+		req.Body = sanitizeText(req.Body, true)
+		v.required("body", req.Body)
+		v.maxRunes("body", req.Body, maxCommentLength)
+	}
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return req, nil
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return req, nil
+	}
+	return req, user
+}
+
+// commentForRequest looks up the complaint and comment req points at,
+// writing the error response when either is missing or the comment was
+// already deleted. Callers must hold storage.mutex for writing.
+func commentForRequest(w http.ResponseWriter, user *User, req CommentRequest) (*Complaint, *Comment) {
+	complaint, apiErr := accessibleComplaint(user, req.ComplaintID, false, "Access denied. You can only change comments on your own complaints")
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return nil, nil
+	}
+	comment := findComment(complaint, req.CommentID)
+	if comment == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Comment not found")
+		return nil, nil
+	}
+	if comment.DeletedAt != "" {
+		respondWithError(w, http.StatusConflict, ErrCodeAlreadyDeleted, "Comment is already deleted")
+		return nil, nil
+	}
+	return complaint, comment
+}
+
+// /editComment - Change one of your comments, within the edit window of
+// posting it. Edits are recorded but not announced again.
+func editCommentHandler(w http.ResponseWriter, r *http.Request) {
+	req, user := decodeCommentRequest(w, r, true)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint, comment := commentForRequest(w, user, req)
+	if comment == nil {
+		return
+	}
+	if !authorize(w, canEditComment(actorOf(user), complaint, comment)) {
+		return
+	}
+	if !withinEditWindow(comment) {
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, fmt.Sprintf("Comments can only be edited within %s of posting", config.CommentEditWindow))
+		return
+	}
+
+	comment.Body = req.Body
+	comment.EditedAt = getCurrentTime()
+	addHistory(complaint, HistoryEntry{Action: historyCommentEdited, ActorID: user.ID, Detail: fmt.Sprintf("Comment #%d", comment.ID), CorrelationID: correlationID(r)})
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Comment edited successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
+
+// /deleteComment - Remove a comment, leaving a tombstone in its place.
+// Authors may delete their own within the edit window; admins may remove
+// any comment at any time.
+func deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	req, user := decodeCommentRequest(w, r, false)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint, comment := commentForRequest(w, user, req)
+	if comment == nil {
+		return
+	}
+
+	caller := actorOf(user)
+	if !authorize(w, canDeleteComment(caller, complaint, comment)) {
+		return
+	}
+	switch {
+	case canEditComment(caller, complaint, comment).allowed() && withinEditWindow(comment):
+		comment.DeletedBy = commentDeletedByAuthor
+		comment.Body = commentTombstoneAuthor
+	case adminOnly(caller).allowed():
+		comment.DeletedBy = commentDeletedByModerator
+		comment.Body = commentTombstoneModerator
+	default:
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, fmt.Sprintf("Comments can only be deleted within %s of posting", config.CommentEditWindow))
+		return
+	}
+	comment.DeletedAt = getCurrentTime()
+	addHistory(complaint, HistoryEntry{
+		Action:        historyCommentDeleted,
+		ActorID:       user.ID,
+		Detail:        fmt.Sprintf("Comment #%d, by %s", comment.ID, comment.DeletedBy),
+		CorrelationID: correlationID(r),
+	})
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Comment deleted successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
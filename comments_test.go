@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestComments(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.Local))
+	ownerID, ownerCode := registerTestUser(t, srv, "Resident", "resident@example.com")
+	_, otherCode := registerTestUser(t, srv, "Neighbour", "neighbour@example.com")
+	_, staffCode := registerTestAdmin(t, srv, "Caretaker", "caretaker@example.com")
+	complaint := submitTestComplaint(t, srv, ownerCode, "Lift out of order", 7)
+
+	add := func(secret, body string) Complaint {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/addComment", AddCommentRequest{SecretCode: secret, ComplaintID: complaint.ID, Body: body})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var updated Complaint
+		resp.decode(t, &updated)
+		return updated
+	}
+	change := func(endpoint, secret string, commentID int, body string) (int, testResponse) {
+		t.Helper()
+		return postJSON(t, srv, endpoint, CommentRequest{SecretCode: secret, ComplaintID: complaint.ID, CommentID: commentID, Body: body})
+	}
+	notificationCount := func() int {
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		return len(storage.notifications[ownerID])
+	}
+	lastHistory := func() HistoryEntry {
+		t.Helper()
+		history := assigneeOf(t, srv, complaint.ID).History
+		return history[len(history)-1]
+	}
+
+	updated := add(staffCode, "An engineer is booked for Thursday")
+	if len(updated.Comments) != 1 || updated.Comments[0].ID != 1 || updated.Comments[0].Body != "An engineer is booked for Thursday" {
+		t.Fatalf("Expected the comment added as #1, got %+v", updated.Comments)
+	}
+	if got := notificationCount(); got != 1 {
+		t.Errorf("Expected the owner notified of the comment, got %d notifications", got)
+	}
+	if entry := lastHistory(); entry.Action != historyCommented || entry.Detail != "Comment #1" {
+		t.Errorf("Expected the comment in the history, got %+v", entry)
+	}
+	add(ownerCode, "Thursday works, thanks")
+
+	t.Run("Edit Within Window", func(t *testing.T) {
+		fake.Advance(10 * time.Minute)
+		status, resp := change("/editComment", staffCode, 1, "An engineer is booked for Friday")
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var edited Complaint
+		resp.decode(t, &edited)
+		comment := edited.Comments[0]
+		if comment.Body != "An engineer is booked for Friday" || comment.EditedAt != getCurrentTime() {
+			t.Errorf("Expected the edited comment with edited_at, got %+v", comment)
+		}
+		if entry := lastHistory(); entry.Action != historyCommentEdited || entry.Detail != "Comment #1" {
+			t.Errorf("Expected the edit in the history, got %+v", entry)
+		}
+		if got := notificationCount(); got != 1 {
+			t.Errorf("Expected no notification for the edit, got %d notifications", got)
+		}
+	})
+
+	t.Run("Someone Else's", func(t *testing.T) {
+		if status, resp := change("/editComment", ownerCode, 1, "Never mind"); status != http.StatusForbidden || resp.ErrorCode != ErrCodeForbidden {
+			t.Errorf("Expected 403 editing another's comment, got %d %s", status, resp.ErrorCode)
+		}
+		if status, resp := change("/editComment", staffCode, 2, "Never mind"); status != http.StatusForbidden || resp.ErrorCode != ErrCodeForbidden {
+			t.Errorf("Expected 403 for admins editing too, got %d %s", status, resp.ErrorCode)
+		}
+		if status, _ := change("/deleteComment", otherCode, 2, ""); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for a complaint the user cannot see, got %d", status)
+		}
+	})
+
+	t.Run("Window Closed", func(t *testing.T) {
+		fake.Advance(10 * time.Minute)
+		if status, resp := change("/editComment", ownerCode, 2, "Thursday or Friday"); status != http.StatusForbidden || resp.ErrorCode != ErrCodeForbidden {
+			t.Errorf("Expected 403 editing after the window, got %d %s", status, resp.ErrorCode)
+		}
+		if status, resp := change("/deleteComment", ownerCode, 2, ""); status != http.StatusForbidden || resp.ErrorCode != ErrCodeForbidden {
+			t.Errorf("Expected 403 deleting after the window, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Author Deletes", func(t *testing.T) {
+		add(ownerCode, "Wrong complaint, sorry")
+		fake.Advance(time.Minute)
+		status, resp := change("/deleteComment", ownerCode, 3, "")
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var deleted Complaint
+		resp.decode(t, &deleted)
+		if len(deleted.Comments) != 3 {
+			t.Fatalf("Expected the comment kept as a tombstone, got %+v", deleted.Comments)
+		}
+		if tomb := deleted.Comments[2]; tomb.Body != commentTombstoneAuthor || tomb.DeletedBy != commentDeletedByAuthor || tomb.DeletedAt == "" {
+			t.Errorf("Expected an author tombstone, got %+v", tomb)
+		}
+		if status, resp := change("/deleteComment", ownerCode, 3, ""); status != http.StatusConflict || resp.ErrorCode != ErrCodeAlreadyDeleted {
+			t.Errorf("Expected 409 deleting again, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Moderator Removes", func(t *testing.T) {
+		fake.Advance(24 * time.Hour)
+		status, resp := change("/deleteComment", staffCode, 2, "")
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var removed Complaint
+		resp.decode(t, &removed)
+		if tomb := removed.Comments[1]; tomb.Body != commentTombstoneModerator || tomb.DeletedBy != commentDeletedByModerator || tomb.UserID != ownerID {
+			t.Errorf("Expected a moderator tombstone keeping its author, got %+v", tomb)
+		}
+		if entry := lastHistory(); entry.Action != historyCommentDeleted || entry.Detail != "Comment #2, by moderator" {
+			t.Errorf("Expected the removal in the history, got %+v", entry)
+		}
+		if status, _ := change("/editComment", ownerCode, 2, "Back again"); status != http.StatusConflict {
+			t.Errorf("Expected 409 editing a removed comment, got %d", status)
+		}
+		if status, _ := change("/deleteComment", staffCode, 99, ""); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for a missing comment, got %d", status)
+		}
+	})
+
+	t.Run("New IDs After Deletion", func(t *testing.T) {
+		updated := add(ownerCode, "Still broken on Monday")
+		if last := updated.Comments[len(updated.Comments)-1]; last.ID != 4 {
+			t.Errorf("Expected the next comment numbered 4, got %+v", last)
+		}
+	})
+}
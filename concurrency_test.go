@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOptimisticConcurrency(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Contested User", "contested@example.com")
+	complaint := submitTestComplaint(t, srv, code, "Broken lock", 5)
+	if complaint.Version != 1 {
+		t.Fatalf("Expected a new complaint at version 1, got %d", complaint.Version)
+	}
+
+	t.Run("Version Required", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/setPriority", SetPriorityRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Priority: priorityHigh})
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("Expected 400 without a version, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Lost Update", func(t *testing.T) {
+		// Both admins load version 1; the first write wins
+		status, resp := postJSON(t, srv, "/setPriority", SetPriorityRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Priority: priorityHigh, Version: 1})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var updated Complaint
+		resp.decode(t, &updated)
+		if updated.Version != 2 {
+			t.Errorf("Expected version 2, got %d", updated.Version)
+		}
+
+		// The second admin is still working from version 1
+		status, resp = postJSON(t, srv, "/setPriority", SetPriorityRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Priority: priorityLow, Version: 1})
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeVersionConflict {
+			t.Fatalf("Expected 409 VERSION_CONFLICT, got %d %s", status, resp.ErrorCode)
+		}
+		var current Complaint
+		resp.decode(t, &current)
+		if current.Priority != priorityHigh || current.Version != 2 {
+			t.Errorf("Expected the current complaint in the conflict, got %q at version %d", current.Priority, current.Version)
+		}
+		if current.User == nil {
+			t.Error("Expected the conflict to be shaped for an admin")
+		}
+
+		// Retrying with the fresh version goes through
+		status, resp = postJSON(t, srv, "/setPriority", SetPriorityRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Priority: priorityLow, Version: current.Version})
+		if status != http.StatusOK {
+			t.Errorf("Expected 200 on retry, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Resolve Opt In", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Version: 2})
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeVersionConflict {
+			t.Errorf("Expected 409 for a stale resolve, got %d %s", status, resp.ErrorCode)
+		}
+
+		status, resp = postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		if status != http.StatusOK {
+			t.Errorf("Expected a resolve without a version to win, got %d (%s)", status, resp.Error)
+		}
+	})
+}
+
+// TestConcurrentTraffic mixes registrations, submissions, logins and admin
+// listings so that go test -race can catch unguarded shared state
+func TestConcurrentTraffic(t *testing.T) {
+	srv := newTestServer(t)
+	const workers = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan string, workers*3)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%5 == 0 {
+				status, _, err := sendJSON(srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret})
+				if err != nil || status != http.StatusOK {
+					errs <- fmt.Sprintf("worker %d: admin listing returned %d (%v)", i, status, err)
+				}
+				return
+			}
+
+			status, resp, err := sendJSON(srv, "/register", RegisterRequest{Name: fmt.Sprintf("Worker %d", i), Email: fmt.Sprintf("worker%d@example.com", i)})
+			var user User
+			if err == nil {
+				err = json.Unmarshal(resp.Data, &user)
+			}
+			if err != nil || status != http.StatusCreated {
+				errs <- fmt.Sprintf("worker %d: register returned %d (%s %v)", i, status, resp.Error, err)
+				return
+			}
+
+			status, resp, err = sendJSON(srv, "/submitComplaint", SubmitComplaintRequest{
+				SecretCode: user.SecretCode, Title: fmt.Sprintf("Complaint %d", i), Summary: "Filed concurrently", Rating: 5,
+			})
+			if err != nil || status != http.StatusCreated {
+				errs <- fmt.Sprintf("worker %d: submit returned %d (%s %v)", i, status, resp.Error, err)
+			}
+
+			// Logins take only the user locks, so they race the writes above
+			status, resp, err = sendJSON(srv, "/login", LoginRequest{SecretCode: user.SecretCode})
+			if err != nil || status != http.StatusOK {
+				errs <- fmt.Sprintf("worker %d: login returned %d (%s %v)", i, status, resp.Error, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret})
+	var complaints []Complaint
+	resp.decode(t, &complaints)
+	if want := workers - workers/5; len(complaints) != want {
+		t.Errorf("Expected %d complaints, got %d", want, len(complaints))
+	}
+	seen := map[int]bool{}
+	for _, complaint := range complaints {
+		if seen[complaint.ID] {
+			t.Errorf("Complaint ID %d was handed out twice", complaint.ID)
+		}
+		seen[complaint.ID] = true
+	}
+}
+
+// benchmarkUsers and benchmarkComplaints size the store the benchmarks run
+// against, so that writes do the scans they would in production
+const (
+	benchmarkUsers      = 100
+	benchmarkComplaints = 20 // per user
+)
+
+// benchmarkHandler returns the API with benchmarkUsers users, each with
+// benchmarkComplaints complaints, and their secret codes. Requests go
+// straight to the handler, so the network does not hide lock contention.
+func benchmarkHandler(b *testing.B) (http.Handler, []string) {
+	srv := newTestServer(b)
+	disableSubmissionLimits()
+	useLogOutput(b, io.Discard)
+
+	handler := srv.Config.Handler
+	codes := make([]string, benchmarkUsers)
+	for i := range codes {
+		var user User
+		resp := serveJSON(handler, "/register", RegisterRequest{Name: fmt.Sprintf("Bench %d", i), Email: fmt.Sprintf("bench%d@example.com", i)})
+		if err := json.Unmarshal(resp.Data, &user); err != nil || user.SecretCode == "" {
+			b.Fatalf("Register failed: %s %v", resp.Error, err)
+		}
+		codes[i] = user.SecretCode
+		for j := 0; j < benchmarkComplaints; j++ {
+			serveJSON(handler, "/submitComplaint", SubmitComplaintRequest{SecretCode: user.SecretCode, Title: fmt.Sprintf("Complaint %d", j), Summary: "Filed before the benchmark", Rating: 3})
+		}
+	}
+	return handler, codes
+}
+
+// serveJSON sends payload to handler and returns the decoded response
+func serveJSON(handler http.Handler, path string, payload interface{}) testResponse {
+	data, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var response testResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return response
+}
+
+func BenchmarkSubmitParallel(b *testing.B) {
+	handler, codes := benchmarkHandler(b)
+	var next int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			code := codes[atomic.AddInt64(&next, 1)%int64(len(codes))]
+			if resp := serveJSON(handler, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: "Parallel", Summary: "Filed during the benchmark", Rating: 3}); !resp.Success {
+				b.Errorf("Submit failed: %s", resp.Error)
+			}
+		}
+	})
+}
+
+// BenchmarkLoginUnderWriteLoad measures logins while other goroutines keep
+// submitting complaints as fast as they can. The writers file for other
+// users, so the size of each login's response stays the same throughout.
+func BenchmarkLoginUnderWriteLoad(b *testing.B) {
+	handler, codes := benchmarkHandler(b)
+	writerCodes, codes := codes[:len(codes)/2], codes[len(codes)/2:]
+	stop := make(chan struct{})
+	var writers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		writers.Add(1)
+		go func(i int) {
+			defer writers.Done()
+			for n := i; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				serveJSON(handler, "/submitComplaint", SubmitComplaintRequest{SecretCode: writerCodes[n%len(writerCodes)], Title: "Load", Summary: "Keeps the writers busy", Rating: 3})
+			}
+		}(i)
+	}
+
+	var next int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			code := codes[atomic.AddInt64(&next, 1)%int64(len(codes))]
+			if resp := serveJSON(handler, "/login", LoginRequest{SecretCode: code}); !resp.Success {
+				b.Errorf("Login failed: %s", resp.Error)
+			}
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	writers.Wait()
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/netip"
+	"os"
+	"time"
+)
+
+// Config holds the runtime settings that can be tuned from the command line
+type Config struct {
+	Addr             string // address the API listens on
+	TLSCertFile      string // certificate for HTTPS; plain HTTP when empty
+	TLSKeyFile       string // private key for TLSCertFile
+	HTTPRedirectAddr string // plain-HTTP listener that redirects to HTTPS; off when empty
+	AutocertDomains  string // comma-separated hostnames to get Let's Encrypt certificates for; off when empty
+	AutocertCacheDir string // where Let's Encrypt certificates and the account key are kept
+	AutocertEmail    string // contact address given to Let's Encrypt; optional
+
+	TrustedProxies []netip.Prefix // peers whose X-Forwarded-For header is believed
+
+	LogFormat string     // "json" or "text"
+	LogLevel  slog.Level // lines below it are dropped; /admin/logLevel changes it at runtime
+
+	JWTSigningKey  string        // HS256 key for issuing and checking tokens; JWT mode is off when empty
+	JWTPreviousKey string        // an older key tokens are still accepted with while it is rotated out
+	JWTTTL         time.Duration // how long an issued token is valid
+	JWTClockSkew   time.Duration // leeway when checking a token's times
+
+	ShareLinkKey string // HMAC key share links are signed with; a random one per process when empty
+
+	RevealForbiddenComplaints bool   // answer 403 rather than 404 when a user asks for someone else's complaint
+	RequireInvites            bool   // registration needs an invite code
+	TOSVersion                string // terms of service users must accept; none when empty
+	EmailDomainPolicy         string // "allow" or "block" to limit registration by email domain; any domain when empty
+	AllowedEmailDomains       string // comma-separated domains that may register in allow mode
+	BlockedEmailDomainsFile   string // domains refused in block mode, one per line; the bundled disposable list when empty
+	ModerationWordsFile       string // words that hold a complaint for review, one per line; nothing is flagged when empty
+
+	MaxFailedLogins  int           // consecutive failed auth attempts before an account is locked
+	LockoutDuration  time.Duration // how long a lock lasts; idle failure counters expire after the same period
+	EventHeartbeat   time.Duration // interval between keep-alive comments on /events and pings on /ws
+	RequestTimeout   time.Duration // how long a handler may run before the request fails with 503; 0 disables it
+	ReadTimeout      time.Duration // how long a client may take to send a request, body included
+	WriteTimeout     time.Duration // how long writing a response may take; /events and /exportComplaints are exempt
+	IdleTimeout      time.Duration // how long an idle keep-alive connection stays open
+	PurgeAfterDays   int           // days a deleted complaint stays in the trash before it is purged
+	PurgeInterval    time.Duration // how often the background purge runs
+	DraftMaxAgeDays  int           // days a draft is kept after it was last saved
+	ArchiveAfterDays int           // days after resolution a complaint is archived; 0 disables archiving
+
+	// Retention policies, in days; records older than that are permanently
+	// removed by the retention job. 0 keeps them forever. See retention.go.
+	RetainResolvedDays      int // after resolution
+	RetainAuditDays         int // audit log entries
+	RetainNotificationsDays int // after a notification was sent, read or not
+	RetainLoginHistoryDays  int // login history entries
+
+	// Largest request bodies accepted, in bytes; see bodyLimit
+	MaxBodySize      int64 // endpoints without a limit of their own
+	MaxLoginBodySize int64 // /login and /register
+	MaxBulkBodySize  int64 // /importComplaints and /seed
+
+	MaxOpenComplaints int           // unresolved complaints a user may have at once; 0 disables the quota
+	SubmitRateLimit   int           // complaints a user may submit per SubmitRateWindow; 0 disables throttling
+	SubmitRateWindow  time.Duration // sliding window for SubmitRateLimit
+
+	EscalateAfterDays  int           // days an unresolved complaint may stay open before it is escalated
+	EscalationInterval time.Duration // how often the escalation job runs
+
+	ListSummaryLength int // runes of a summary listings send before cutting it short; 0 sends it whole
+
+	ResponseCacheSize int           // responses readCache keeps; 0 disables it
+	ResponseCacheTTL  time.Duration // longest a cached response is served, however quiet storage is
+
+	// With ConfirmResolutions on, a resolution awaits its owner's
+	// confirmation, given for them after AutoConfirmAfterDays
+	ConfirmResolutions   bool
+	AutoConfirmAfterDays int
+
+	// Business time a complaint of each priority has to be resolved; weekends
+	// do not count and 0 means no deadline
+	SLACritical      time.Duration
+	SLAHigh          time.Duration
+	SLAMedium        time.Duration
+	SLALow           time.Duration
+	SLACheckInterval time.Duration // how often newly overdue complaints are flagged
+
+	AssignmentStrategy string // how new complaints are assigned to admins: none, round_robin or least_loaded
+
+	// Complaints are rated from RatingMin to RatingMax. Stored complaints
+	// rated outside the range, after it changed, are kept and flagged.
+	RatingMin int
+	RatingMax int
+
+	MaintenanceMode       bool          // start in read-only maintenance mode
+	MaintenanceMessage    string        // shown to rejected writes; a default is used when empty
+	MaintenanceRetryAfter time.Duration // Retry-After sent to rejected writes
+
+	DevMode        bool   // enables development-only endpoints such as /seed
+	SeedFile       string // fixture loaded at startup
+	SeedRandom     int    // generated users loaded at startup
+	SeedRandomSeed int64  // makes SeedRandom data reproducible
+	RestoreFile    string // /admin/backup document loaded at startup
+
+	SlackWebhookURL   string // Slack incoming webhook; the integration is off when empty
+	SlackLinkTemplate string // link to a complaint in Slack messages, with {id} for its ID
+
+	PublicURL string // the server's address as clients see it, for links in feeds; taken from each request when empty
+
+	SMTPHost     string // mail server for emails to complaint owners; no emails are sent when empty
+	SMTPPort     int
+	SMTPUsername string // PLAIN authentication when set
+	SMTPPassword string
+	SMTPFrom     string // sender address
+
+	OutboxMaxAttempts int           // failed sends before a notification is dead-lettered
+	OutboxRetryDelay  time.Duration // wait after a notification's first failed send; doubles after each one
+
+	// /ingestEmail creates a provisional user for an unknown sender with
+	// IngestCreateUsers, and files their emails at IngestPriority
+	IngestCreateUsers bool
+	IngestPriority    string
+
+	// With ReplayProtection on, every authenticated write must carry a
+	// nonce not used before and a timestamp within ReplayWindow of now
+	ReplayProtection bool
+	ReplayWindow     time.Duration
+
+	MaxPinned int // complaints each organization may have pinned at once (0 for no limit)
+
+	CommentEditWindow time.Duration // how long authors may edit or delete their comments
+
+	CheckOnly      bool // run the startup self-check, print it and exit
+	CheckEndpoints bool // have the self-check also connect to the Slack and SMTP servers
+}
+
+func defaultConfig() Config {
+	return Config{
+		Addr:             ":8080",
+		AutocertCacheDir: "autocert-cache",
+
+		LogFormat: logFormatJSON,
+
+		JWTTTL:       time.Hour,
+		JWTClockSkew: time.Minute,
+
+		MaxFailedLogins:  10,
+		LockoutDuration:  15 * time.Minute,
+		EventHeartbeat:   30 * time.Second,
+		RequestTimeout:   15 * time.Second,
+		ReadTimeout:      30 * time.Second,
+		WriteTimeout:     30 * time.Second,
+		IdleTimeout:      2 * time.Minute,
+		PurgeAfterDays:   30,
+		PurgeInterval:    time.Hour,
+		DraftMaxAgeDays:  30,
+		ArchiveAfterDays: 90,
+
+		MaxBodySize:      1 << 20,
+		MaxLoginBodySize: 4 << 10,
+		MaxBulkBodySize:  10 << 20,
+
+		MaxOpenComplaints: 20,
+		SubmitRateLimit:   5,
+		SubmitRateWindow:  time.Hour,
+
+		EscalateAfterDays:  7,
+		EscalationInterval: time.Hour,
+
+		ListSummaryLength: 280,
+
+		ResponseCacheSize: 256,
+		ResponseCacheTTL:  5 * time.Second,
+
+		AutoConfirmAfterDays: 7,
+
+		SLACritical:      24 * time.Hour,
+		SLAHigh:          3 * 24 * time.Hour,
+		SLAMedium:        5 * 24 * time.Hour,
+		SLALow:           10 * 24 * time.Hour,
+		SLACheckInterval: 15 * time.Minute,
+
+		AssignmentStrategy: assignNone,
+
+		RatingMin: legacyRatingScale.Min,
+		RatingMax: legacyRatingScale.Max,
+
+		MaintenanceRetryAfter: 5 * time.Minute,
+
+		SeedRandomSeed: 1,
+
+		SMTPPort: 587,
+		SMTPFrom: "complaints@localhost",
+
+		OutboxMaxAttempts: 8,
+		OutboxRetryDelay:  30 * time.Second,
+
+		IngestPriority: priorityMedium,
+
+		ReplayWindow: 5 * time.Minute,
+
+		MaxPinned: 10,
+
+		CommentEditWindow: 15 * time.Minute,
+	}
+}
+
+var config = defaultConfig()
+
+// parseFlags overrides the defaults in config from the command line
+func parseFlags() {
+	flag.StringVar(&config.Addr, "addr", config.Addr, "address to listen on")
+	flag.StringVar(&config.TLSCertFile, "tls-cert", config.TLSCertFile, "PEM certificate (chain) to serve HTTPS with")
+	flag.StringVar(&config.TLSKeyFile, "tls-key", config.TLSKeyFile, "PEM private key for -tls-cert")
+	flag.StringVar(&config.HTTPRedirectAddr, "http-redirect-addr", config.HTTPRedirectAddr, "also listen for plain HTTP here and redirect it to HTTPS, e.g. :80")
+	flag.StringVar(&config.AutocertDomains, "autocert-domains", config.AutocertDomains, "comma-separated hostnames to serve HTTPS for with certificates from Let's Encrypt, instead of -tls-cert")
+	flag.StringVar(&config.AutocertCacheDir, "autocert-cache", config.AutocertCacheDir, "directory Let's Encrypt certificates are kept in between restarts")
+	flag.StringVar(&config.AutocertEmail, "autocert-email", config.AutocertEmail, "contact address for Let's Encrypt expiry notices")
+	if value, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		proxies, err := parseTrustedProxies(value)
+		if err != nil {
+			fatal("Invalid TRUSTED_PROXIES", logKeyError, err)
+		}
+		config.TrustedProxies = proxies
+	}
+	flag.Func("trusted-proxies", "comma-separated CIDR ranges of reverse proxies whose X-Forwarded-For is believed, e.g. 10.0.0.0/8 (or $TRUSTED_PROXIES)", func(value string) (err error) {
+		config.TrustedProxies, err = parseTrustedProxies(value)
+		return err
+	})
+	flag.StringVar(&config.LogFormat, "log-format", envOr("LOG_FORMAT", config.LogFormat), "format of log lines on stderr: json or text (or $LOG_FORMAT)")
+	flag.TextVar(&config.LogLevel, "log-level", config.LogLevel, "lowest level logged: debug, info, warn or error")
+	flag.StringVar(&config.JWTSigningKey, "jwt-key", envOr("JWT_SIGNING_KEY", config.JWTSigningKey), "HS256 key; /login also issues tokens and they are accepted in place of secret codes (or $JWT_SIGNING_KEY)")
+	flag.StringVar(&config.JWTPreviousKey, "jwt-previous-key", envOr("JWT_PREVIOUS_KEY", config.JWTPreviousKey), "previous HS256 key, still accepted for verification during rotation (or $JWT_PREVIOUS_KEY)")
+	flag.DurationVar(&config.JWTTTL, "jwt-ttl", config.JWTTTL, "how long an issued token is valid")
+	flag.DurationVar(&config.JWTClockSkew, "jwt-clock-skew", config.JWTClockSkew, "leeway when checking token expiry and issue times")
+	flag.StringVar(&config.ShareLinkKey, "share-key", envOr("SHARE_LINK_KEY", config.ShareLinkKey), "HMAC key for /createShareLink links; when empty, a random key is used and links stop working on restart (or $SHARE_LINK_KEY)")
+	flag.BoolVar(&config.RevealForbiddenComplaints, "reveal-forbidden-complaints", config.RevealForbiddenComplaints, "answer 403 rather than 404 for someone else's complaint, revealing that its ID exists")
+	flag.BoolVar(&config.RequireInvites, "require-invites", config.RequireInvites, "only allow registration with an invite code from /createInvite or an organization")
+	flag.StringVar(&config.TOSVersion, "tos-version", envOr("TOS_VERSION", config.TOSVersion), "terms of service version users must accept, e.g. 2024-06; bumping it makes everyone accept again (or $TOS_VERSION)")
+	flag.StringVar(&config.EmailDomainPolicy, "email-domain-policy", envOr("EMAIL_DOMAIN_POLICY", config.EmailDomainPolicy), "limit registration by email domain: allow for only -allowed-email-domains, block to refuse disposable domains (or $EMAIL_DOMAIN_POLICY)")
+	flag.StringVar(&config.AllowedEmailDomains, "allowed-email-domains", envOr("ALLOWED_EMAIL_DOMAINS", config.AllowedEmailDomains), "comma-separated domains that may register with -email-domain-policy allow, e.g. example.com; subdomains are included (or $ALLOWED_EMAIL_DOMAINS)")
+	flag.StringVar(&config.BlockedEmailDomainsFile, "blocked-email-domains-file", envOr("BLOCKED_EMAIL_DOMAINS_FILE", config.BlockedEmailDomainsFile), "file of domains refused with -email-domain-policy block, one per line, re-read by /reloadEmailDomains; a bundled disposable list when empty (or $BLOCKED_EMAIL_DOMAINS_FILE)")
+	flag.StringVar(&config.ModerationWordsFile, "moderation-words-file", envOr("MODERATION_WORDS_FILE", config.ModerationWordsFile), "file of words, one per line, that hold a complaint for admin review in /reviewQueue (or $MODERATION_WORDS_FILE)")
+	flag.IntVar(&config.MaxFailedLogins, "max-failed-logins", config.MaxFailedLogins, "failed auth attempts before an account is locked")
+	flag.DurationVar(&config.LockoutDuration, "lockout-duration", config.LockoutDuration, "how long a locked account stays locked")
+	flag.DurationVar(&config.RequestTimeout, "request-timeout", config.RequestTimeout, "how long a request may take before it fails with 503 (0 for no limit)")
+	flag.DurationVar(&config.ReadTimeout, "read-timeout", config.ReadTimeout, "how long a client may take to send a request")
+	flag.DurationVar(&config.WriteTimeout, "write-timeout", config.WriteTimeout, "how long writing a response may take")
+	flag.DurationVar(&config.IdleTimeout, "idle-timeout", config.IdleTimeout, "how long an idle keep-alive connection stays open")
+	flag.IntVar(&config.PurgeAfterDays, "purge-after-days", config.PurgeAfterDays, "days before a deleted complaint is permanently removed")
+	flag.DurationVar(&config.PurgeInterval, "purge-interval", config.PurgeInterval, "how often deleted complaints are checked for purging")
+	flag.IntVar(&config.DraftMaxAgeDays, "draft-max-age-days", config.DraftMaxAgeDays, "days before a draft that has not been saved again is removed")
+	flag.IntVar(&config.ArchiveAfterDays, "archive-after-days", config.ArchiveAfterDays, "days after resolution before a complaint is archived (0 to never archive)")
+	flag.IntVar(&config.RetainResolvedDays, "retain-resolved-days", config.RetainResolvedDays, "days after resolution before a complaint is permanently removed (0 to keep forever)")
+	flag.IntVar(&config.RetainAuditDays, "retain-audit-days", config.RetainAuditDays, "days an audit log entry is kept (0 to keep forever)")
+	flag.IntVar(&config.RetainNotificationsDays, "retain-notifications-days", config.RetainNotificationsDays, "days a notification is kept (0 to keep forever)")
+	flag.IntVar(&config.RetainLoginHistoryDays, "retain-login-history-days", config.RetainLoginHistoryDays, "days a login history entry is kept (0 to keep forever)")
+	flag.Int64Var(&config.MaxBodySize, "max-body-size", config.MaxBodySize, "largest request body in bytes, for endpoints without a limit of their own")
+	flag.Int64Var(&config.MaxLoginBodySize, "max-login-body-size", config.MaxLoginBodySize, "largest request body in bytes for /login and /register")
+	flag.Int64Var(&config.MaxBulkBodySize, "max-bulk-body-size", config.MaxBulkBodySize, "largest request body in bytes for /importComplaints and /seed")
+	flag.IntVar(&config.MaxOpenComplaints, "max-open-complaints", config.MaxOpenComplaints, "open complaints a user may have at once (0 for no limit)")
+	flag.IntVar(&config.SubmitRateLimit, "submit-rate-limit", config.SubmitRateLimit, "complaints a user may submit per rate window (0 for no limit)")
+	flag.DurationVar(&config.SubmitRateWindow, "submit-rate-window", config.SubmitRateWindow, "sliding window for -submit-rate-limit")
+	flag.IntVar(&config.EscalateAfterDays, "escalate-after-days", config.EscalateAfterDays, "days before an unresolved complaint is escalated")
+	flag.DurationVar(&config.EscalationInterval, "escalation-interval", config.EscalationInterval, "how often stale complaints are checked for escalation")
+	flag.IntVar(&config.ListSummaryLength, "list-summary-length", config.ListSummaryLength, "characters of a summary sent in listings (0 for the full text)")
+	flag.IntVar(&config.ResponseCacheSize, "response-cache-size", config.ResponseCacheSize, "responses of hot read endpoints kept in memory (0 to disable the cache)")
+	flag.DurationVar(&config.ResponseCacheTTL, "response-cache-ttl", config.ResponseCacheTTL, "longest a cached response is served")
+	flag.BoolVar(&config.ConfirmResolutions, "confirm-resolutions", config.ConfirmResolutions, "have owners confirm or reject the resolution of their complaints")
+	flag.IntVar(&config.AutoConfirmAfterDays, "auto-confirm-days", config.AutoConfirmAfterDays, "days before a resolution its owner has not answered is confirmed for them (0 to wait forever)")
+	flag.DurationVar(&config.SLACritical, "sla-critical", config.SLACritical, "business time to resolve a critical complaint; weekends do not count (0 for no deadline)")
+	flag.DurationVar(&config.SLAHigh, "sla-high", config.SLAHigh, "business time to resolve a high priority complaint (0 for no deadline)")
+	flag.DurationVar(&config.SLAMedium, "sla-medium", config.SLAMedium, "business time to resolve a medium priority complaint (0 for no deadline)")
+	flag.DurationVar(&config.SLALow, "sla-low", config.SLALow, "business time to resolve a low priority complaint (0 for no deadline)")
+	flag.DurationVar(&config.SLACheckInterval, "sla-check-interval", config.SLACheckInterval, "how often newly overdue complaints are flagged")
+	flag.StringVar(&config.AssignmentStrategy, "assignment-strategy", config.AssignmentStrategy, "assign new complaints to admins: none, round_robin or least_loaded")
+	flag.IntVar(&config.RatingMin, "rating-min", config.RatingMin, "lowest rating a complaint can be given, at least 1")
+	flag.IntVar(&config.RatingMax, "rating-max", config.RatingMax, "highest rating a complaint can be given, e.g. 5 for stars")
+	flag.BoolVar(&config.MaintenanceMode, "maintenance", config.MaintenanceMode, "start in read-only maintenance mode")
+	flag.StringVar(&config.MaintenanceMessage, "maintenance-message", config.MaintenanceMessage, "message for writes rejected during maintenance")
+	flag.DurationVar(&config.MaintenanceRetryAfter, "maintenance-retry-after", config.MaintenanceRetryAfter, "Retry-After for writes rejected during maintenance")
+	flag.BoolVar(&config.DevMode, "dev", config.DevMode, "enable development-only endpoints such as /seed")
+	flag.StringVar(&config.SeedFile, "seed", config.SeedFile, "JSON fixture of users and complaints to load at startup")
+	flag.IntVar(&config.SeedRandom, "seed-random", config.SeedRandom, "generate this many users with random complaints at startup")
+	flag.Int64Var(&config.SeedRandomSeed, "seed-random-seed", config.SeedRandomSeed, "random seed for -seed-random; the same seed gives the same data")
+	flag.StringVar(&config.RestoreFile, "restore-file", config.RestoreFile, "backup from /admin/backup to load at startup, replacing all data; older schema versions are migrated")
+	flag.StringVar(&config.SlackWebhookURL, "slack-webhook-url", envOr("SLACK_WEBHOOK_URL", config.SlackWebhookURL), "Slack incoming webhook for urgent and escalated complaints (or $SLACK_WEBHOOK_URL)")
+	flag.StringVar(&config.SlackLinkTemplate, "slack-link-template", envOr("SLACK_LINK_TEMPLATE", config.SlackLinkTemplate), "complaint link in Slack messages, with {id} for the ID (or $SLACK_LINK_TEMPLATE)")
+	flag.StringVar(&config.PublicURL, "public-url", envOr("PUBLIC_URL", config.PublicURL), "the server's address as clients see it, e.g. https://complaints.example.com, for links in the Atom feed (or $PUBLIC_URL)")
+	flag.StringVar(&config.SMTPHost, "smtp-host", envOr("SMTP_HOST", config.SMTPHost), "mail server for resolution emails to complaint owners (or $SMTP_HOST)")
+	flag.IntVar(&config.SMTPPort, "smtp-port", config.SMTPPort, "mail server port")
+	flag.StringVar(&config.SMTPUsername, "smtp-username", envOr("SMTP_USERNAME", config.SMTPUsername), "mail server username; no authentication when empty (or $SMTP_USERNAME)")
+	flag.StringVar(&config.SMTPPassword, "smtp-password", envOr("SMTP_PASSWORD", config.SMTPPassword), "mail server password (or $SMTP_PASSWORD)")
+	flag.StringVar(&config.SMTPFrom, "smtp-from", envOr("SMTP_FROM", config.SMTPFrom), "sender address of emails (or $SMTP_FROM)")
+	flag.IntVar(&config.OutboxMaxAttempts, "outbox-max-attempts", config.OutboxMaxAttempts, "failed sends of a Slack post or email before it is dead-lettered")
+	flag.DurationVar(&config.OutboxRetryDelay, "outbox-retry-delay", config.OutboxRetryDelay, "wait after the first failed send of a Slack post or email; doubles after each one, up to an hour")
+	flag.BoolVar(&config.IngestCreateUsers, "ingest-create-users", config.IngestCreateUsers, "create a provisional user for an email ingested from an unknown address, instead of refusing it")
+	flag.StringVar(&config.IngestPriority, "ingest-priority", config.IngestPriority, "priority of complaints filed from ingested emails: low, medium, high or critical")
+	flag.BoolVar(&config.ReplayProtection, "replay-protection", config.ReplayProtection, "require X-Request-Nonce and X-Request-Timestamp on authenticated writes and reject replayed ones")
+	flag.DurationVar(&config.ReplayWindow, "replay-window", config.ReplayWindow, "how far X-Request-Timestamp may be from the server's clock, either way, with -replay-protection")
+	flag.IntVar(&config.MaxPinned, "max-pinned", config.MaxPinned, "complaints an organization may have pinned at once (0 for no limit)")
+	flag.DurationVar(&config.CommentEditWindow, "comment-edit-window", config.CommentEditWindow, "how long after posting authors may edit or delete their comments")
+	flag.BoolVar(&config.CheckOnly, "check", config.CheckOnly, "check the configuration and exit: 0 when the server would start, 1 when a check fails")
+	flag.BoolVar(&config.CheckEndpoints, "check-endpoints", config.CheckEndpoints, "also try to connect to the Slack and SMTP servers during the self-check; failing to only warns")
+	flag.Parse()
+}
+
+// envOr returns environment variable name, or fallback when it is unset
+func envOr(name, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return fallback
+}
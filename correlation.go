@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// correlationHeader carries the ID that ties a request to the log lines,
+// history entries, events and notifications it causes. It is accepted on
+// requests, echoed on responses and sent on outbound notifications.
+const correlationHeader = "X-Correlation-Id"
+
+// maxCorrelationIDLength bounds an accepted correlation ID
+const maxCorrelationIDLength = 64
+
+type correlationIDKey struct{}
+
+// cleanCorrelationID keeps the letters, digits, '-', '_', '.' and ':' of a
+// client-supplied ID, up to maxCorrelationIDLength of them, so it is safe
+// in log lines and headers. It returns "" when nothing is left.
+func cleanCorrelationID(raw string) string {
+	var b strings.Builder
+	for _, r := range raw {
+		if b.Len() == maxCorrelationIDLength {
+			break
+		}
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func generateCorrelationID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+// withCorrelationID gives every request a correlation ID, the client's if
+// it sent a usable one and a new one otherwise, for correlationID to return
+func withCorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := cleanCorrelationID(r.Header.Get(correlationHeader))
+		if id == "" {
+			id = generateCorrelationID()
+		}
+		w.Header().Set(correlationHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), correlationIDKey{}, id)))
+	})
+}
+
+// correlationID is the correlation ID of r, or "" for requests that did
+// not pass through withCorrelationID, as in handler tests
+func correlationID(r *http.Request) string {
+	id, _ := r.Context().Value(correlationIDKey{}).(string)
+	return id
+}
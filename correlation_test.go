@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanCorrelationID(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"req-42_a.b:c", "req-42_a.b:c"},
+		{"abc\r\nGET /admin 200", "abcGETadmin200"},
+		{"\x1b[31mred", "31mred"},
+		{"  \n ", ""},
+		{strings.Repeat("x", 100), strings.Repeat("x", maxCorrelationIDLength)},
+		{"é" + strings.Repeat("y", 70), strings.Repeat("y", maxCorrelationIDLength)},
+	}
+	for _, tc := range tests {
+		if got := cleanCorrelationID(tc.raw); got != tc.want {
+			t.Errorf("cleanCorrelationID(%q) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
+
+// postWithCorrelationID posts payload to endpoint with id in the
+// correlation header, and returns the response's status and header value
+func postWithCorrelationID(t *testing.T, url, id string, payload interface{}) (int, string) {
+	t.Helper()
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if id != "" {
+		req.Header.Set(correlationHeader, id)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get(correlationHeader)
+}
+
+func TestCorrelationID(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	config.AssignmentStrategy = assignRoundRobin
+	capture, hook := newSlackCapture(t)
+	useDispatcher(t, map[string]outboxChannel{outboxChannelSlack: newSlackNotifier(hook.URL, "")})
+	registerTestAdmin(t, srv, "Assignee", "assignee@example.com")
+	_, code := registerTestUser(t, srv, "Traced User", "traced@example.com")
+	sub := eventBus.subscribe()
+	defer eventBus.unsubscribe(sub)
+
+	const id = "trace-1234"
+	status, echoed := postWithCorrelationID(t, srv.URL+"/submitComplaint", id, SubmitComplaintRequest{
+		SecretCode: code, Title: "Gas smell", Summary: "In the basement", Rating: 10, Priority: priorityCritical,
+	})
+	if status != http.StatusCreated || echoed != id {
+		t.Fatalf("Expected 201 with the correlation ID echoed, got %d and %q", status, echoed)
+	}
+
+	t.Run("Webhook", func(t *testing.T) {
+		capture.wait(t)
+		capture.mutex.Lock()
+		defer capture.mutex.Unlock()
+		if len(capture.correlationIDs) != 1 || capture.correlationIDs[0] != id {
+			t.Errorf("Expected the webhook to carry %q, got %v", id, capture.correlationIDs)
+		}
+	})
+
+	t.Run("History", func(t *testing.T) {
+		complaint := assigneeOf(t, srv, 1)
+		if len(complaint.History) != 1 || complaint.History[0].Action != historyAssigned || complaint.History[0].CorrelationID != id {
+			t.Errorf("Expected an assignment entry with correlation ID %q, got %+v", id, complaint.History)
+		}
+	})
+
+	t.Run("Event", func(t *testing.T) {
+		select {
+		case event := <-sub.events:
+			if event.Type != eventComplaintCreated || event.CorrelationID != id {
+				t.Errorf("Expected a %s event with correlation ID %q, got %+v", eventComplaintCreated, id, event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected an event")
+		}
+	})
+
+	t.Run("Generated", func(t *testing.T) {
+		for _, sent := range []string{"", "<> !"} {
+			_, first := postWithCorrelationID(t, srv.URL+"/myStats", sent, MyStatsRequest{SecretCode: code})
+			_, second := postWithCorrelationID(t, srv.URL+"/myStats", sent, MyStatsRequest{SecretCode: code})
+			if len(first) != 32 || first == second {
+				t.Errorf("Expected fresh generated IDs for %q, got %q and %q", sent, first, second)
+			}
+		}
+	})
+}
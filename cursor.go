@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// sortExport is the cursor sort of /exportComplaints, which walks complaints
+// in ID order
+const sortExport = "export"
+
+var errInvalidCursor = errors.New("Invalid cursor")
+
+// cursorKey signs cursors so clients cannot forge positions. It is random
+// per process, so cursors do not survive a restart.
+var cursorKey = newCursorKey()
+
+func newCursorKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("generating cursor key: " + err.Error())
+	}
+	return key
+}
+
+// listPosition is where a complaint sits in a sort order: when it was
+// pinned, in listings that put pinned complaints first, the sort key (rating
+// or priority rank; unused for ID orders) and the ID that breaks ties
+type listPosition struct {
+	Sort   string `json:"s"`
+	Pinned int64  `json:"p,omitempty"` // see pinnedAtUnix
+	Key    int    `json:"k,omitempty"`
+	ID     int    `json:"i"`
+}
+
+func positionOf(complaint *Complaint, sortKey string) listPosition {
+	position := listPosition{Sort: sortKey, ID: complaint.ID}
+	switch sortKey {
+	case sortRatingDesc, sortRatingAsc:
+		position.Key = complaint.Rating
+	case sortPriority:
+		position.Key = priorityRank(complaint.Priority)
+	}
+	return position
+}
+
+// before reports whether a comes before b in their sort order. Every order
+// ends with the ID, so no two complaints share a position.
+func (a listPosition) before(b listPosition) bool {
+	if a.Pinned != b.Pinned {
+		return a.Pinned > b.Pinned
+	}
+	switch a.Sort {
+	case sortRatingDesc, sortPriority:
+		if a.Key != b.Key {
+			return a.Key > b.Key
+		}
+	case sortRatingAsc:
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+	case sortNewest:
+		return a.ID > b.ID
+	}
+	return a.ID < b.ID
+}
+
+func cursorSignature(payload string) string {
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// encodeCursor turns position into an opaque, signed next_cursor
+func encodeCursor(position listPosition) string {
+	data, _ := json.Marshal(position)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + cursorSignature(payload)
+}
+
+// decodeCursor checks cursor's signature and that it was issued for
+// sortKey. An empty cursor starts from the beginning and returns nil.
+func decodeCursor(cursor, sortKey string) (*listPosition, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	payload, signature, found := strings.Cut(cursor, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(cursorSignature(payload))) {
+		return nil, errInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var position listPosition
+	if err := json.Unmarshal(data, &position); err != nil {
+		return nil, errInvalidCursor
+	}
+	if position.Sort != sortKey {
+		return nil, errors.New("Cursor was issued for a different sort")
+	}
+	return &position, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types a department's custom field may have
+const (
+	customFieldString = "string"
+	customFieldNumber = "number"
+)
+
+// Limits on custom fields; lengths are in runes
+const (
+	maxCustomFields          = 20
+	maxCustomFieldNameLength = 50
+	maxCustomFieldLength     = 500
+)
+
+// CustomField is one extra field a department asks for on complaints routed
+// to it, such as an IT asset tag or a Facilities location
+type CustomField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // string or number
+	Required bool   `json:"required"`
+}
+
+// CustomValues are the custom fields of a complaint by name: strings and
+// float64 numbers, as decoded from JSON
+type CustomValues map[string]interface{}
+
+// MarshalXML writes the values as <field name="...">value</field>
+// elements, by name, since encoding/xml has no form for maps
+func (values CustomValues) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, name := range names {
+		value := fmt.Sprint(values[name])
+		if number, ok := values[name].(float64); ok {
+			value = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		field := xml.StartElement{Name: xml.Name{Local: "field"}, Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: name}}}
+		if err := e.EncodeElement(value, field); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// FieldError is why one custom field of a submission was rejected
+type FieldError struct {
+	Field string `json:"field" xml:"field"`
+	Error string `json:"error" xml:"error"`
+}
+
+// normalizeCustomFields checks a department's field template, trimming the
+// names. Names are matched exactly, so "Location" and "location" clash only
+// by being confusing.
+func normalizeCustomFields(fields []CustomField) ([]CustomField, error) {
+	if len(fields) > maxCustomFields {
+		return nil, fmt.Errorf("A department can have at most %d custom fields", maxCustomFields)
+	}
+	normalized := []CustomField{}
+	seen := map[string]bool{}
+	for _, field := range fields {
+		field.Name = sanitizeText(field.Name, false)
+		if field.Name == "" {
+			return nil, errors.New("Each custom field needs a name")
+		}
+		if err := checkLength("Each custom field name", field.Name, maxCustomFieldNameLength); err != nil {
+			return nil, err
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("Custom field %q is defined twice", field.Name)
+		}
+		if field.Type != customFieldString && field.Type != customFieldNumber {
+			return nil, fmt.Errorf("Custom field %q must have type string or number", field.Name)
+		}
+		seen[field.Name] = true
+		normalized = append(normalized, field)
+	}
+	return normalized, nil
+}
+
+// normalizeSLA checks a department's SLA, a Go duration such as "48h", and
+// returns it in canonical form. An empty SLA leaves priorities in charge.
+func normalizeSLA(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	sla, err := time.ParseDuration(value)
+	if err != nil || sla <= 0 {
+		return "", errors.New("SLA must be a positive duration such as 48h")
+	}
+	return sla.String(), nil
+}
+
+// validateCustomFields checks the custom fields of a submission against the
+// template of department, which is nil for General, and returns the values
+// to store. Every problem is reported, one FieldError per field.
+func validateCustomFields(department *Department, submitted CustomValues) (CustomValues, *APIError) {
+	var template []CustomField
+	name := generalDepartment
+	if department != nil {
+		template, name = department.Fields, department.Name
+	}
+
+	values := make(CustomValues)
+	var fieldErrors []FieldError
+	defined := make(map[string]bool, len(template))
+	for _, field := range template {
+		defined[field.Name] = true
+		value := submitted[field.Name]
+		switch field.Type {
+		case customFieldString:
+			if value == nil {
+				break
+			}
+			text, ok := value.(string)
+			if !ok {
+				fieldErrors = append(fieldErrors, FieldError{field.Name, "must be a string"})
+				continue
+			}
+			text = sanitizeText(text, false)
+			if err := checkLength(field.Name, text, maxCustomFieldLength); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{field.Name, err.Error()})
+				continue
+			}
+			if text != "" {
+				values[field.Name] = text
+			}
+		case customFieldNumber:
+			if value == nil {
+				break
+			}
+			number, ok := value.(float64)
+			if !ok {
+				fieldErrors = append(fieldErrors, FieldError{field.Name, "must be a number"})
+				continue
+			}
+			values[field.Name] = number
+		}
+		if _, set := values[field.Name]; !set && field.Required {
+			fieldErrors = append(fieldErrors, FieldError{field.Name, "is required"})
+		}
+	}
+	for field := range submitted {
+		if !defined[field] {
+			fieldErrors = append(fieldErrors, FieldError{field, "is not a field of the " + name + " department"})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		sort.Slice(fieldErrors, func(i, j int) bool { return fieldErrors[i].Field < fieldErrors[j].Field })
+		err := newAPIError(http.StatusBadRequest, ErrCodeValidationFailed, "Custom fields are invalid")
+		err.Data = fieldErrors
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values, nil
+}
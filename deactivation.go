@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+)
+
+// UserStatusRequest is the body of /deactivateUser and /reactivateUser
+type UserStatusRequest struct {
+	SecretCode string `json:"secret_code"`
+	UserID     int    `json:"user_id"`
+}
+
+// requireActive answers 403 and returns false when user was deactivated
+func requireActive(w http.ResponseWriter, user *User) bool {
+	storage.usersMutex.RLock()
+	active := user.IsActive
+	storage.usersMutex.RUnlock()
+	if active {
+		return true
+	}
+	respondWithError(w, http.StatusForbidden, ErrCodeAccountDeactivated, "This account has been deactivated. Contact an administrator")
+	return false
+}
+
+// activeUser is the user userID when they exist and were not deactivated,
+// for deciding who hears about a change. Callers must hold storage.mutex.
+func activeUser(userID int) (*User, bool) {
+	user, exists := storage.users[userID]
+	return user, exists && user.IsActive
+}
+
+// decodeUserStatusRequest reads and checks a deactivate or reactivate
+// request, writing the error response when it fails
+func decodeUserStatusRequest(w http.ResponseWriter, r *http.Request) (UserStatusRequest, *User) {
+	var req UserStatusRequest
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return req, nil
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return req, nil
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("user_id", req.UserID)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return req, nil
+	}
+
+	admin := authenticate(w, r, req.SecretCode)
+	if admin == nil {
+		return req, nil
+	}
+
+	if !authorize(w, adminOnly(actorOf(admin))) {
+		return req, nil
+	}
+	return req, admin
+}
+
+// /deactivateUser - Suspend a user without deleting them or their
+// complaints, e.g. when a contractor leaves (admin only)
+func deactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	req, admin := decodeUserStatusRequest(w, r)
+	if admin == nil {
+		return
+	}
+
+	// Registered first so that it runs once storage.mutex is released
+	revoked := 0
+	defer func() {
+		if revoked != 0 {
+			wsHub.closeUser(revoked, "Account deactivated")
+		}
+	}()
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	target := scopedUser(admin, req.UserID)
+	if target == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	if target.ID == storage.defaultAdminID {
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, "The default admin cannot be deactivated")
+		return
+	}
+	if actorOf(admin).is(target.ID) {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "You cannot deactivate your own account")
+		return
+	}
+	if !target.IsActive {
+		respondWithError(w, http.StatusConflict, ErrCodeAlreadyDeactivated, "User is already deactivated")
+		return
+	}
+
+	storage.usersMutex.Lock()
+	target.IsActive = false
+	target.DeactivatedAt = getCurrentTime()
+	revokeCredentials(target)
+	summary := summarizeUser(target)
+	storage.usersMutex.Unlock()
+	revoked = target.ID
+
+	requestLogger(r).Info("User deactivated", "target_user_id", target.ID)
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "User deactivated successfully",
+		Data:    summary,
+	})
+}
+
+// /reactivateUser - Give a deactivated user their access back (admin only)
+func reactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	req, admin := decodeUserStatusRequest(w, r)
+	if admin == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	target := scopedUser(admin, req.UserID)
+	if target == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	if target.IsActive {
+		respondWithError(w, http.StatusConflict, ErrCodeNotDeactivated, "User is not deactivated")
+		return
+	}
+
+	storage.usersMutex.Lock()
+	target.IsActive = true
+	target.DeactivatedAt = ""
+	summary := summarizeUser(target)
+	storage.usersMutex.Unlock()
+
+	requestLogger(r).Info("User reactivated", "target_user_id", target.ID)
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "User reactivated successfully",
+		Data:    summary,
+	})
+}
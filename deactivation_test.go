@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDeactivateUser(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	ownerID, ownerCode := registerTestUser(t, srv, "Contractor", "contractor@example.com")
+	_, watcherCode := registerTestUser(t, srv, "Watcher", "watcher@example.com")
+	leaverID, _ := registerTestAdmin(t, srv, "Leaving Admin", "leaving@example.com")
+	stayerID, stayerCode := registerTestAdmin(t, srv, "Staying Admin", "staying@example.com")
+	complaint := submitTestComplaint(t, srv, ownerCode, "Broken badge reader", 6)
+	postJSON(t, srv, "/watchComplaint", WatchComplaintRequest{SecretCode: watcherCode, ComplaintID: complaint.ID})
+
+	setActive := func(endpoint string, userID int) (int, testResponse) {
+		t.Helper()
+		return postJSON(t, srv, endpoint, UserStatusRequest{SecretCode: stayerCode, UserID: userID})
+	}
+	inbox := func(secret string) []Notification {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/getNotifications", GetNotificationsRequest{SecretCode: secret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var page NotificationPage
+		resp.decode(t, &page)
+		return page.Notifications
+	}
+
+	status, resp := setActive("/deactivateUser", ownerID)
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+	}
+	var summary UserSummary
+	resp.decode(t, &summary)
+	if summary.IsActive || summary.DeactivatedAt == "" {
+		t.Errorf("Expected the user shown deactivated, got %+v", summary)
+	}
+
+	t.Run("Authentication Refused", func(t *testing.T) {
+		if status, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: ownerCode}); status != http.StatusForbidden || resp.ErrorCode != ErrCodeAccountDeactivated {
+			t.Errorf("Expected 403 ACCOUNT_DEACTIVATED logging in, got %d %s", status, resp.ErrorCode)
+		}
+		if status, resp := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: ownerCode}); status != http.StatusForbidden || resp.ErrorCode != ErrCodeAccountDeactivated {
+			t.Errorf("Expected 403 ACCOUNT_DEACTIVATED listing, got %d %s", status, resp.ErrorCode)
+		}
+		if resp, body := doV1(t, srv, http.MethodGet, "/v1/users/me", ownerCode, nil); resp.StatusCode != http.StatusForbidden || body.ErrorCode != ErrCodeAccountDeactivated {
+			t.Errorf("Expected 403 ACCOUNT_DEACTIVATED on /v1, got %d %s", resp.StatusCode, body.ErrorCode)
+		}
+		if status, resp := postJSON(t, srv, "/register", RegisterRequest{Name: "Again", Email: "contractor@example.com"}); status != http.StatusConflict || resp.ErrorCode != ErrCodeEmailExists {
+			t.Errorf("Expected 409 EMAIL_EXISTS reusing the email, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Complaints Visible", func(t *testing.T) {
+		if got := assigneeOf(t, srv, complaint.ID); got.UserID != ownerID || got.Title != complaint.Title {
+			t.Errorf("Expected admins to still see the complaint, got %+v", got)
+		}
+		_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret})
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		if len(complaints) != 1 || complaints[0].ID != complaint.ID {
+			t.Errorf("Expected the complaint in the admin listing, got %+v", complaints)
+		}
+	})
+
+	t.Run("Notifications Skipped", func(t *testing.T) {
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: stayerCode, ComplaintID: complaint.ID})
+		if got := inbox(watcherCode); len(got) != 1 {
+			t.Errorf("Expected the active watcher notified, got %+v", got)
+		}
+		storage.mutex.RLock()
+		kept := len(storage.notifications[ownerID])
+		storage.mutex.RUnlock()
+		if kept != 0 {
+			t.Errorf("Expected nothing kept for the deactivated owner, got %d notifications", kept)
+		}
+	})
+
+	t.Run("Auto Assignment Skipped", func(t *testing.T) {
+		config.AssignmentStrategy = assignRoundRobin
+		if status, resp := setActive("/deactivateUser", leaverID); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		for i := 0; i < 2; i++ {
+			submitted := submitTestComplaint(t, srv, watcherCode, "Printer jam", 4)
+			if got := assigneeOf(t, srv, submitted.ID).AssignedTo; got != stayerID {
+				t.Errorf("Expected every complaint assigned to the active admin %d, got %d", stayerID, got)
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		storage.mutex.RLock()
+		defaultAdminID := storage.defaultAdminID
+		storage.mutex.RUnlock()
+		cases := []struct {
+			name     string
+			endpoint string
+			secret   string
+			userID   int
+			status   int
+			code     ErrorCode
+		}{
+			{"Default Admin", "/deactivateUser", adminSecret, defaultAdminID, http.StatusForbidden, ErrCodeForbidden},
+			{"Self", "/deactivateUser", stayerCode, stayerID, http.StatusBadRequest, ErrCodeValidationFailed},
+			{"Already", "/deactivateUser", stayerCode, ownerID, http.StatusConflict, ErrCodeAlreadyDeactivated},
+			{"Not Deactivated", "/reactivateUser", stayerCode, stayerID, http.StatusConflict, ErrCodeNotDeactivated},
+			{"Missing", "/reactivateUser", stayerCode, 9999, http.StatusNotFound, ErrCodeNotFound},
+			{"Not Admin", "/reactivateUser", watcherCode, ownerID, http.StatusForbidden, ErrCodeForbidden},
+		}
+		for _, tc := range cases {
+			status, resp := postJSON(t, srv, tc.endpoint, UserStatusRequest{SecretCode: tc.secret, UserID: tc.userID})
+			if status != tc.status || resp.ErrorCode != tc.code {
+				t.Errorf("%s: expected %d %s, got %d %s", tc.name, tc.status, tc.code, status, resp.ErrorCode)
+			}
+		}
+	})
+
+	t.Run("Reactivation", func(t *testing.T) {
+		status, resp := setActive("/reactivateUser", ownerID)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if status, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: ownerCode}); status != http.StatusOK {
+			t.Errorf("Expected 200 logging in again, got %d (%s)", status, resp.Error)
+		}
+		_, resp = postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: ownerCode})
+		var own []Complaint
+		resp.decode(t, &own)
+		if len(own) != 1 || !own[0].IsResolved {
+			t.Errorf("Expected the user's complaint back, resolved, got %+v", own)
+		}
+		if got := inbox(ownerCode); len(got) != 0 {
+			t.Errorf("Expected no notifications from while deactivated, got %+v", got)
+		}
+	})
+}
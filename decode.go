@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// maxJSONDepth is how deeply a request body may nest objects and arrays.
+// No request goes past a few levels; the limit turns hostile input away
+// before the decoder spends any time on it.
+const maxJSONDepth = 32
+
+// decodeJSON reads r's JSON body into v. On failure it writes the response
+// and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if apiErr := decodeBody(r.Body, v, false); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return false
+	}
+	return true
+}
+
+// decodeOptionalJSON is decodeJSON for endpoints whose body may be left
+// out, which leaves v as it is
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if apiErr := decodeBody(r.Body, v, true); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return false
+	}
+	return true
+}
+
+// decodeBody reads the JSON in body into v. Anything after the first value
+// is ignored, as it always has been. A value of the wrong type is reported
+// against its field, in the words the validator uses, rather than with the
+// decoder's Go type names.
+func decodeBody(body io.Reader, v interface{}, optional bool) *APIError {
+	data, apiErr := readJSONBody(body)
+	if apiErr != nil {
+		return apiErr
+	}
+	if optional && len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	err := json.NewDecoder(bytes.NewReader(data)).Decode(v)
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		return newAPIError(http.StatusBadRequest, ErrCodeInvalidJSON, "Request body must be a JSON object")
+	case errors.As(err, &typeErr):
+		var fieldErr validator
+		fieldErr.add(jsonFieldPath(typeErr.Field), typeProblem(typeErr))
+		return fieldErr.err()
+	}
+	return errInvalidJSON()
+}
+
+// readJSONBody reads body whole, turning away one nested past maxJSONDepth
+func readJSONBody(body io.Reader) ([]byte, *APIError) {
+	data, err := io.ReadAll(body)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return nil, errBodyTooLarge(maxBytesErr.Limit)
+	case err != nil:
+		return nil, errInvalidJSON()
+	case jsonTooDeep(data):
+		return nil, newAPIError(http.StatusBadRequest, ErrCodeInvalidJSON, fmt.Sprintf("Invalid JSON format: nested more than %d levels deep", maxJSONDepth))
+	}
+	return data, nil
+}
+
+func errInvalidJSON() *APIError {
+	return newAPIError(http.StatusBadRequest, ErrCodeInvalidJSON, "Invalid JSON format")
+}
+
+// jsonTooDeep reports whether data nests objects and arrays more than
+// maxJSONDepth levels deep. It only counts brackets outside strings, so it
+// works on malformed input too.
+func jsonTooDeep(data []byte) bool {
+	depth, inString, escaped := 0, false, false
+	for _, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			escaped = c == '\\'
+			inString = c != '"'
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			if depth++; depth > maxJSONDepth {
+				return true
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return false
+}
+
+// jsonFieldPath turns the decoder's path to a field, such as "tags.0", into
+// the one clients write, "tags[0]"
+func jsonFieldPath(path string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(path, ".") {
+		switch {
+		case part != "" && strings.Trim(part, "0123456789") == "":
+			b.WriteString("[" + part + "]")
+		case i > 0:
+			b.WriteString("." + part)
+		default:
+			b.WriteString(part)
+		}
+	}
+	return b.String()
+}
+
+// typeProblem phrases a type mismatch the way validator rules are, such as
+// "must be an integer"
+func typeProblem(typeErr *json.UnmarshalTypeError) string {
+	t := typeErr.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// A whole number the type cannot hold, as opposed to 7.9 or 1e3
+		if number, isNumber := strings.CutPrefix(typeErr.Value, "number "); isNumber && !strings.ContainsAny(number, ".eE") {
+			return "is out of range"
+		}
+		return "must be an integer"
+	case reflect.Float32, reflect.Float64:
+		return "must be a number"
+	case reflect.String:
+		return "must be a string"
+	case reflect.Bool:
+		return "must be true or false"
+	case reflect.Slice, reflect.Array:
+		return "must be an array"
+	case reflect.Map, reflect.Struct:
+		return "must be an object"
+	}
+	return "has the wrong type"
+}
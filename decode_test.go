@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// serveRaw sends body to handler as is and returns the status, the raw
+// response and its decoded envelope
+func serveRaw(handler http.Handler, path string, body []byte) (*httptest.ResponseRecorder, testResponse) {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var response testResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec, response
+}
+
+func TestDecodeErrors(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Decode User", "decode@example.com")
+
+	tests := []struct {
+		name      string
+		path      string
+		body      string
+		errorCode ErrorCode
+		field     string // for validation failures
+		message   string
+	}{
+		{"Quoted ID", "/viewComplaint", `{"secret_code": "` + code + `", "complaint_id": "5"}`, ErrCodeValidationFailed, "complaint_id", "Complaint ID must be an integer"},
+		{"Fractional Rating", "/submitComplaint", `{"secret_code": "` + code + `", "title": "T", "summary": "S", "rating": 7.9}`, ErrCodeValidationFailed, "rating", "Rating must be an integer"},
+		{"Exponent Rating", "/submitComplaint", `{"secret_code": "` + code + `", "title": "T", "summary": "S", "rating": 1e3}`, ErrCodeValidationFailed, "rating", "Rating must be an integer"},
+		{"Huge ID", "/viewComplaint", `{"secret_code": "` + code + `", "complaint_id": 123456789012345678901234567890}`, ErrCodeValidationFailed, "complaint_id", "Complaint ID is out of range"},
+		{"Numeric Name", "/register", `{"name": 42, "email": "n@example.com"}`, ErrCodeValidationFailed, "name", "Name must be a string"},
+		{"String Flag", "/register", `{"name": "N", "email": "n@example.com", "accept_tos": "yes"}`, ErrCodeValidationFailed, "accept_tos", "Accept tos must be true or false"},
+		{"Tag Not A String", "/submitComplaint", `{"secret_code": "` + code + `", "title": "T", "summary": "S", "rating": 5, "tags": ["ok", 1]}`, ErrCodeValidationFailed, "tags[1]", ""},
+		{"Tags Not An Array", "/submitComplaint", `{"secret_code": "` + code + `", "title": "T", "summary": "S", "rating": 5, "tags": "noise"}`, ErrCodeValidationFailed, "tags", ""},
+		{"Top Level Array", "/login", `[{"secret_code": "` + code + `"}]`, ErrCodeInvalidJSON, "", "Request body must be a JSON object"},
+		{"Top Level String", "/login", `"` + code + `"`, ErrCodeInvalidJSON, "", "Request body must be a JSON object"},
+		{"Too Deep", "/login", `{"secret_code": "` + code + `", "x": ` + strings.Repeat("[", maxJSONDepth) + strings.Repeat("]", maxJSONDepth) + `}`, ErrCodeInvalidJSON, "", "Invalid JSON format: nested more than 32 levels deep"},
+		{"Truncated", "/login", `{"secret_code": "`, ErrCodeInvalidJSON, "", "Invalid JSON format"},
+		{"Empty", "/login", ``, ErrCodeInvalidJSON, "", "Invalid JSON format"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, resp := serveRaw(srv.Config.Handler, tc.path, []byte(tc.body))
+			if rec.Code != http.StatusBadRequest || resp.ErrorCode != tc.errorCode {
+				t.Fatalf("Expected 400 %s, got %d %s (%s)", tc.errorCode, rec.Code, resp.ErrorCode, resp.Error)
+			}
+			if tc.message != "" && resp.Error != tc.message {
+				t.Errorf("Expected message %q, got %q", tc.message, resp.Error)
+			}
+			if tc.field != "" {
+				var fieldErrs []FieldError
+				resp.decode(t, &fieldErrs)
+				if len(fieldErrs) != 1 || fieldErrs[0].Field != tc.field {
+					t.Errorf("Expected one error for %s, got %+v", tc.field, fieldErrs)
+				}
+			}
+		})
+	}
+
+	t.Run("Nested Within Limit", func(t *testing.T) {
+		body := `{"secret_code": "` + code + `", "x": ` + strings.Repeat("[", maxJSONDepth-1) + strings.Repeat("]", maxJSONDepth-1) + `}`
+		if rec, resp := serveRaw(srv.Config.Handler, "/login", []byte(body)); rec.Code != http.StatusOK {
+			t.Errorf("Expected unknown fields nested to the limit ignored, got %d (%s)", rec.Code, resp.Error)
+		}
+	})
+
+	t.Run("Brackets In Strings", func(t *testing.T) {
+		body := `{"secret_code": "` + code + `", "x": "` + strings.Repeat(`[{\"`, 100) + `"}`
+		if rec, resp := serveRaw(srv.Config.Handler, "/login", []byte(body)); rec.Code != http.StatusOK {
+			t.Errorf("Expected brackets inside strings not counted, got %d (%s)", rec.Code, resp.Error)
+		}
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		complaint := submitTestComplaint(t, srv, code, "Patched", 5)
+		for _, tc := range []struct {
+			name, body, message string
+		}{
+			{"Fractional Rating", `{"rating": 7.9}`, "Rating must be an integer"},
+			{"Too Deep", `{"title": "T", "x": ` + strings.Repeat("{\"a\":", 40) + "1" + strings.Repeat("}", 40) + `}`, "Invalid JSON format: nested more than 32 levels deep"},
+		} {
+			httpResp, resp := doV1(t, srv, http.MethodPatch, "/v1/complaints/"+strconv.Itoa(complaint.ID), code, json.RawMessage(tc.body))
+			if httpResp.StatusCode != http.StatusBadRequest || resp.Error != tc.message {
+				t.Errorf("%s: expected 400 %q, got %d %q", tc.name, tc.message, httpResp.StatusCode, resp.Error)
+			}
+		}
+	})
+}
+
+// fuzzDecode feeds arbitrary bodies to path. Whatever comes in, the answer
+// must be a well-formed envelope, never a server error, and an error must
+// not show the decoder's Go-specific wording.
+func fuzzDecode(f *testing.F, path string, seeds ...string) {
+	newTestServer(f)
+	disableSubmissionLimits()
+	useLogOutput(f, io.Discard)
+	// Without the test server's checks, which would fail f from inside the
+	// fuzz function
+	handler := newHandler()
+	var user User
+	resp := serveJSON(handler, "/register", RegisterRequest{Name: "Fuzz User", Email: "fuzz@example.com"})
+	if err := json.Unmarshal(resp.Data, &user); err != nil || user.SecretCode == "" {
+		f.Fatalf("Register failed: %s %v", resp.Error, err)
+	}
+	code := user.SecretCode
+
+	for _, seed := range seeds {
+		f.Add([]byte(strings.ReplaceAll(seed, "CODE", code)))
+	}
+	f.Add([]byte(``))
+	f.Add([]byte(`null`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`{"secret_code": "` + code + `"`))
+	f.Add([]byte(strings.Repeat("[", 2000)))
+	f.Add([]byte(`{"a": "\ud800", "b": 1e999999}`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		rec, resp := serveRaw(handler, path, body)
+		if rec.Code >= http.StatusInternalServerError {
+			t.Fatalf("Expected no server error for %q, got %d (%s)", body, rec.Code, resp.Error)
+		}
+		if err := responseProblem(rec.Code, rec.Header(), rec.Body.Bytes()); err != nil {
+			t.Fatalf("Bad response for %q: %v", body, err)
+		}
+		if rec.Code < http.StatusBadRequest {
+			return
+		}
+		for _, leak := range []string{"Go struct", "Go value", "json:", "main.", "reflect"} {
+			if strings.Contains(resp.Error, leak) || strings.Contains(string(resp.Data), leak) {
+				t.Fatalf("Expected no %q in the error for %q, got %s", leak, body, rec.Body.Bytes())
+			}
+		}
+	})
+}
+
+func FuzzRegisterDecode(f *testing.F) {
+	fuzzDecode(f, "/register",
+		`{"name": "Fuzzed", "email": "fuzzed@example.com"}`,
+		`{"name": 1, "email": ["a"], "accept_tos": "true"}`,
+		`{"name": "N", "email": "e@example.com", "invite_code": {"x": 1}}`,
+	)
+}
+
+func FuzzLoginDecode(f *testing.F) {
+	fuzzDecode(f, "/login",
+		`{"secret_code": "CODE"}`,
+		`{"secret_code": 123}`,
+		`{"secret_code": null}`,
+	)
+}
+
+func FuzzSubmitDecode(f *testing.F) {
+	fuzzDecode(f, "/submitComplaint",
+		`{"secret_code": "CODE", "title": "Fuzzed", "summary": "Summary", "rating": 5, "tags": ["noise"]}`,
+		`{"secret_code": "CODE", "title": "T", "summary": "S", "rating": "5"}`,
+		`{"secret_code": "CODE", "title": "T", "summary": "S", "rating": 9999999999999999999999}`,
+		`{"secret_code": "CODE", "title": "T", "summary": "S", "rating": 5, "custom_fields": {"floor": [1]}}`,
+		`{"secret_code": "CODE", "title": "T", "summary": "S", "rating": 5, "tags": [[[]]]}`,
+	)
+}
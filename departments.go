@@ -0,0 +1,496 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// generalDepartment receives every complaint no department's rules match.
+// It always exists and cannot be added or deleted.
+const generalDepartment = "General"
+
+// Limits on departments and tag rules; lengths are in runes
+const (
+	maxDepartmentNameLength = 50
+	maxKeywords             = 50
+	maxKeywordLength        = 50
+)
+
+// Department is a team complaints are routed to. A complaint whose title or
+// summary contains one of the keywords, as whole words, is routed here.
+// Complaints routed here must fill in its custom fields, and get its SLA
+// instead of their priority's when it has one.
+type Department struct {
+	ID        int           `json:"id"`
+	Name      string        `json:"name"`
+	Keywords  []string      `json:"keywords"`
+	Priority  int           `json:"priority"` // rules are evaluated lowest first
+	Fields    []CustomField `json:"fields,omitempty"`
+	SLA       string        `json:"sla,omitempty"` // business time, e.g. "48h"
+	CreatedAt string        `json:"created_at"`
+}
+
+// routingText lowercases text and turns everything but letters and digits
+// into single spaces, so keywords match whole words regardless of
+// punctuation
+func routingText(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// normalizeKeywords reduces keywords to their routing form, dropping blanks
+// and duplicates but keeping the order
+func normalizeKeywords(keywords []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, keyword := range keywords {
+		keyword = routingText(keyword)
+		if keyword == "" || seen[keyword] {
+			continue
+		}
+		if err := checkLength("Each keyword", keyword, maxKeywordLength); err != nil {
+			return nil, err
+		}
+		seen[keyword] = true
+		normalized = append(normalized, keyword)
+	}
+	if len(normalized) > maxKeywords {
+		return nil, fmt.Errorf("At most %d keywords are allowed", maxKeywords)
+	}
+	return normalized, nil
+}
+
+// orderedDepartments returns the departments in rule evaluation order: by
+// priority, then by ID. Callers must hold storage.mutex for reading.
+func orderedDepartments() []*Department {
+	list := make([]*Department, 0, len(storage.departments))
+	for _, department := range storage.departments {
+		list = append(list, department)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority < list[j].Priority
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// routeComplaint picks the department for a new complaint. The first
+// department in evaluation order with a matching keyword wins. Callers must
+// hold storage.mutex for reading.
+func routeComplaint(complaint *Complaint) string {
+	text := " " + routingText(complaint.Title+" "+complaint.Summary) + " "
+	for _, department := range orderedDepartments() {
+		for _, keyword := range department.Keywords {
+			if strings.Contains(text, " "+keyword+" ") {
+				return department.Name
+			}
+		}
+	}
+	return generalDepartment
+}
+
+// findDepartment looks a department up by name, ignoring case. Callers must
+// hold storage.mutex for reading.
+func findDepartment(name string) *Department {
+	for _, department := range storage.departments {
+		if strings.EqualFold(department.Name, name) {
+			return department
+		}
+	}
+	return nil
+}
+
+// resolveDepartmentName returns the canonical spelling of an existing
+// department, General included. Callers must hold storage.mutex for reading.
+func resolveDepartmentName(name string) (string, bool) {
+	if strings.EqualFold(name, generalDepartment) {
+		return generalDepartment, true
+	}
+	if department := findDepartment(name); department != nil {
+		return department.Name, true
+	}
+	return "", false
+}
+
+type AddDepartmentRequest struct {
+	SecretCode string        `json:"secret_code"`
+	Name       string        `json:"name"`
+	Keywords   []string      `json:"keywords"`
+	Priority   int           `json:"priority,omitempty"`
+	Fields     []CustomField `json:"fields,omitempty"`
+	SLA        string        `json:"sla,omitempty"`
+}
+
+type UpdateDepartmentRequest struct {
+	SecretCode   string        `json:"secret_code"`
+	DepartmentID int           `json:"department_id"`
+	Keywords     []string      `json:"keywords,omitempty"` // replaces the rules when given
+	Priority     *int          `json:"priority,omitempty"`
+	Fields       []CustomField `json:"fields,omitempty"` // replaces the template when given; [] removes it
+	SLA          *string       `json:"sla,omitempty"`    // "" removes it
+}
+
+type DeleteDepartmentRequest struct {
+	SecretCode   string `json:"secret_code"`
+	DepartmentID int    `json:"department_id"`
+}
+
+type SetComplaintDepartmentRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+	Department  string `json:"department"`
+	Version     int    `json:"version,omitempty"` // opt-in concurrency check
+}
+
+// DepartmentCount is the number of complaints routed to one department
+type DepartmentCount struct {
+	Department string `json:"department"`
+	Open       int    `json:"open"`    // all open complaints, regardless of range
+	Overdue    int    `json:"overdue"` // open complaints past their deadline
+	Created    int    `json:"created"` // complaints created in range
+}
+
+// /addDepartment - Create a department with its routing keywords
+// (super-admin only)
+func addDepartmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req AddDepartmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	name := sanitizeText(req.Name, false)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Department name is required")
+		return
+	}
+	if err := checkLength("Department name", name, maxDepartmentNameLength); err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	keywords, err := normalizeKeywords(req.Keywords)
+	if err == nil && len(keywords) == 0 {
+		err = errors.New("At least one keyword is required")
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	fields, err := normalizeCustomFields(req.Fields)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	sla, err := normalizeSLA(req.SLA)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if _, exists := resolveDepartmentName(name); exists {
+		respondWithError(w, http.StatusConflict, ErrCodeDepartmentExists, "A department with this name already exists")
+		return
+	}
+
+	storage.deptIDGen++
+	department := &Department{
+		ID:        storage.deptIDGen,
+		Name:      name,
+		Keywords:  keywords,
+		Priority:  req.Priority,
+		Fields:    fields,
+		SLA:       sla,
+		CreatedAt: getCurrentTime(),
+	}
+	storage.departments[department.ID] = department
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Department added successfully",
+		Data:    *department,
+	})
+}
+
+// /listDepartments - Departments in rule evaluation order (admin only)
+func listDepartmentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req GetComplaintsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	list := []Department{}
+	for _, department := range orderedDepartments() {
+		list = append(list, *department)
+	}
+	storage.mutex.RUnlock()
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Departments retrieved successfully",
+		Data:    list,
+	})
+}
+
+// /updateDepartment - Replace a department's keywords, priority, custom
+// fields or SLA (super-admin only). Complaints already routed keep their
+// department, custom fields and deadline.
+func updateDepartmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req UpdateDepartmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.DepartmentID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid department ID is required")
+		return
+	}
+	var keywords []string
+	if req.Keywords != nil {
+		var err error
+		keywords, err = normalizeKeywords(req.Keywords)
+		if err == nil && len(keywords) == 0 {
+			err = errors.New("At least one keyword is required")
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+			return
+		}
+	}
+	var fields []CustomField
+	if req.Fields != nil {
+		var err error
+		if fields, err = normalizeCustomFields(req.Fields); err != nil {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+			return
+		}
+	}
+	var sla *string
+	if req.SLA != nil {
+		normalized, err := normalizeSLA(*req.SLA)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+			return
+		}
+		sla = &normalized
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	department, exists := storage.departments[req.DepartmentID]
+	if !exists {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Department not found")
+		return
+	}
+
+	if keywords != nil {
+		department.Keywords = keywords
+	}
+	if req.Priority != nil {
+		department.Priority = *req.Priority
+	}
+	if fields != nil {
+		department.Fields = fields
+	}
+	if sla != nil {
+		department.SLA = *sla
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Department updated successfully",
+		Data:    *department,
+	})
+}
+
+// /deleteDepartment - Remove a department, moving its complaints to General
+// (super-admin only)
+func deleteDepartmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req DeleteDepartmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.DepartmentID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid department ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	department, exists := storage.departments[req.DepartmentID]
+	if !exists {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Department not found")
+		return
+	}
+
+	delete(storage.departments, department.ID)
+	moved := 0
+	for _, complaint := range storage.complaints {
+		if complaint.Department == department.Name {
+			complaint.Department = generalDepartment
+			resetDueAt(complaint)
+			touchComplaint(complaint)
+			moved++
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Department deleted; %d complaints moved to %s", moved, generalDepartment),
+	})
+}
+
+// /setComplaintDepartment - Reassign a complaint to another department
+// (admin only)
+func setComplaintDepartmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req SetComplaintDepartmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return
+	}
+	if strings.TrimSpace(req.Department) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Department is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	name, exists := resolveDepartmentName(strings.TrimSpace(req.Department))
+	if !exists {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Department does not exist")
+		return
+	}
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+
+	if req.Version != 0 {
+		if err := checkVersion(user, complaint, req.Version); err != nil {
+			respondWithAPIError(w, err)
+			return
+		}
+	}
+
+	// The deadline follows the new department's SLA; custom fields stay as
+	// they were submitted
+	complaint.Department = name
+	resetDueAt(complaint)
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Department updated successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
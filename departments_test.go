@@ -0,0 +1,315 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeKeywords(t *testing.T) {
+	keywords, err := normalizeKeywords([]string{" Water-Leak ", "billing", "BILLING", "", "late, delivery!"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []string{"water leak", "billing", "late delivery"}; !reflect.DeepEqual(keywords, want) {
+		t.Errorf("Expected %v, got %v", want, keywords)
+	}
+}
+
+func TestDepartmentRouting(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Routed User", "routed@example.com")
+
+	addDepartment := func(name string, priority int, keywords ...string) Department {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/addDepartment", AddDepartmentRequest{
+			SecretCode: adminSecret, Name: name, Keywords: keywords, Priority: priority,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Add %q: expected status 201, got %d (%s)", name, status, resp.Error)
+		}
+		var department Department
+		resp.decode(t, &department)
+		return department
+	}
+
+	// Added out of order: Billing is evaluated first despite its higher ID
+	facilities := addDepartment("Facilities", 20, "heating", "water leak", "lift")
+	addDepartment("Billing", 10, "invoice", "refund", "charged")
+
+	t.Run("Keyword Matching", func(t *testing.T) {
+		for _, tc := range []struct {
+			title string
+			want  string
+		}{
+			{"Heating is off", "Facilities"},
+			{"WATER-LEAK in the kitchen", "Facilities"},
+			{"Wrong invoice", "Billing"},
+			// Both match; the lower priority value wins
+			{"Refund for the broken lift", "Billing"},
+			// Keywords match whole words only
+			{"Shoplifting nearby", generalDepartment},
+			{"Noise at night", generalDepartment},
+		} {
+			complaint := submitTestComplaint(t, srv, code, tc.title, 5)
+			if complaint.Department != tc.want {
+				t.Errorf("%q: expected %s, got %q", tc.title, tc.want, complaint.Department)
+			}
+		}
+	})
+
+	t.Run("Priority Change Reorders Rules", func(t *testing.T) {
+		priority := 5
+		status, resp := postJSON(t, srv, "/updateDepartment", UpdateDepartmentRequest{SecretCode: adminSecret, DepartmentID: facilities.ID, Priority: &priority})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if complaint := submitTestComplaint(t, srv, code, "Refund for the broken lift", 5); complaint.Department != "Facilities" {
+			t.Errorf("Expected Facilities to win after the priority change, got %q", complaint.Department)
+		}
+
+		_, resp = postJSON(t, srv, "/listDepartments", GetComplaintsRequest{SecretCode: adminSecret})
+		var list []Department
+		resp.decode(t, &list)
+		if len(list) != 2 || list[0].Name != "Facilities" || list[1].Name != "Billing" {
+			t.Errorf("Expected departments in evaluation order, got %+v", list)
+		}
+	})
+
+	t.Run("Validation And Access", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			req    AddDepartmentRequest
+			status int
+		}{
+			{"Non-admin", AddDepartmentRequest{SecretCode: code, Name: "Legal", Keywords: []string{"lawyer"}}, http.StatusForbidden},
+			{"Duplicate", AddDepartmentRequest{SecretCode: adminSecret, Name: "billing", Keywords: []string{"money"}}, http.StatusConflict},
+			{"General Is Reserved", AddDepartmentRequest{SecretCode: adminSecret, Name: "General", Keywords: []string{"misc"}}, http.StatusConflict},
+			{"No Keywords", AddDepartmentRequest{SecretCode: adminSecret, Name: "Legal", Keywords: []string{" ", "!"}}, http.StatusBadRequest},
+			{"No Name", AddDepartmentRequest{SecretCode: adminSecret, Keywords: []string{"lawyer"}}, http.StatusBadRequest},
+		} {
+			if status, _ := postJSON(t, srv, "/addDepartment", tc.req); status != tc.status {
+				t.Errorf("%s: expected %d, got %d", tc.name, tc.status, status)
+			}
+		}
+		if status, _ := postJSON(t, srv, "/listDepartments", GetComplaintsRequest{SecretCode: code}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 listing departments as a user, got %d", status)
+		}
+	})
+}
+
+func TestComplaintDepartmentOverrideAndFilter(t *testing.T) {
+	srv := newTestServer(t)
+	useFakeClock(t, time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC))
+	_, code := registerTestUser(t, srv, "Routed User", "routed@example.com")
+	postJSON(t, srv, "/addDepartment", AddDepartmentRequest{SecretCode: adminSecret, Name: "Billing", Keywords: []string{"invoice"}})
+	postJSON(t, srv, "/addDepartment", AddDepartmentRequest{SecretCode: adminSecret, Name: "Facilities", Keywords: []string{"heating"}})
+
+	invoice := submitTestComplaint(t, srv, code, "Invoice is wrong", 5)
+	heating := submitTestComplaint(t, srv, code, "Heating is off", 5)
+	noise := submitTestComplaint(t, srv, code, "Noise at night", 5)
+
+	t.Run("Manual Override", func(t *testing.T) {
+		status, _ := postJSON(t, srv, "/setComplaintDepartment", SetComplaintDepartmentRequest{SecretCode: code, ComplaintID: noise.ID, Department: "Facilities"})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a non-admin, got %d", status)
+		}
+		status, _ = postJSON(t, srv, "/setComplaintDepartment", SetComplaintDepartmentRequest{SecretCode: adminSecret, ComplaintID: noise.ID, Department: "Legal"})
+		if status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an unknown department, got %d", status)
+		}
+		status, _ = postJSON(t, srv, "/setComplaintDepartment", SetComplaintDepartmentRequest{SecretCode: adminSecret, ComplaintID: noise.ID, Department: "Facilities", Version: noise.Version + 1})
+		if status != http.StatusConflict {
+			t.Errorf("Expected 409 for a stale version, got %d", status)
+		}
+
+		status, resp := postJSON(t, srv, "/setComplaintDepartment", SetComplaintDepartmentRequest{
+			SecretCode: adminSecret, ComplaintID: noise.ID, Department: "facilities", Version: noise.Version,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var updated Complaint
+		resp.decode(t, &updated)
+		if updated.Department != "Facilities" || updated.Version != noise.Version+1 {
+			t.Errorf("Expected the canonical name and a new version, got %q v%d", updated.Department, updated.Version)
+		}
+	})
+
+	t.Run("Filtering", func(t *testing.T) {
+		ids := func(list []Complaint) []int {
+			var out []int
+			for _, c := range list {
+				out = append(out, c.ID)
+			}
+			return out
+		}
+		for _, tc := range []struct {
+			department string
+			want       []int
+		}{
+			{"Facilities", []int{heating.ID, noise.ID}},
+			{"billing", []int{invoice.ID}},
+			{"General", nil},
+		} {
+			_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret, Department: tc.department})
+			var list []Complaint
+			resp.decode(t, &list)
+			if got := ids(list); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s: expected %v, got %v", tc.department, tc.want, got)
+			}
+		}
+
+		_, resp := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: code, Department: "Billing"})
+		var own []Complaint
+		resp.decode(t, &own)
+		if got := ids(own); !reflect.DeepEqual(got, []int{invoice.ID}) {
+			t.Errorf("Expected the user's Billing complaint, got %v", got)
+		}
+	})
+
+	t.Run("Report Breakdown", func(t *testing.T) {
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: invoice.ID})
+		_, resp := postJSON(t, srv, "/report", ReportRequest{SecretCode: adminSecret})
+		var report Report
+		resp.decode(t, &report)
+		want := []DepartmentCount{
+			{Department: "Facilities", Open: 2, Created: 2},
+			{Department: "Billing", Open: 0, Created: 1},
+		}
+		if !reflect.DeepEqual(report.ByDepartment, want) {
+			t.Errorf("Expected %+v, got %+v", want, report.ByDepartment)
+		}
+	})
+
+	t.Run("Deleting Moves Complaints To General", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/listDepartments", GetComplaintsRequest{SecretCode: adminSecret})
+		var list []Department
+		resp.decode(t, &list)
+		status, _ := postJSON(t, srv, "/deleteDepartment", DeleteDepartmentRequest{SecretCode: adminSecret, DepartmentID: list[1].ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", status)
+		}
+		_, resp = postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: adminSecret, ComplaintID: heating.ID})
+		var moved Complaint
+		resp.decode(t, &moved)
+		if moved.Department != generalDepartment {
+			t.Errorf("Expected the complaint to move to General, got %q", moved.Department)
+		}
+	})
+}
+
+func TestDepartmentCustomFields(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	// A Monday, so the business-time SLA runs straight through
+	useFakeClock(t, time.Date(2024, 6, 3, 10, 0, 0, 0, time.Local))
+	_, code := registerTestUser(t, srv, "Intake User", "intake@example.com")
+
+	status, resp := postJSON(t, srv, "/addDepartment", AddDepartmentRequest{
+		SecretCode: adminSecret, Name: "IT", Keywords: []string{"laptop"}, SLA: "4h",
+		Fields: []CustomField{
+			{Name: "asset_tag", Type: customFieldString, Required: true},
+			{Name: "floor", Type: customFieldNumber, Required: true},
+		},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d (%s)", status, resp.Error)
+	}
+
+	submit := func(title, department string, fields CustomValues) (int, testResponse) {
+		return postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: code, Title: title, Summary: "Intake", Rating: 5,
+			Department: department, CustomFields: fields,
+		})
+	}
+
+	t.Run("Rejected Submissions", func(t *testing.T) {
+		for _, tc := range []struct {
+			name       string
+			title      string
+			department string
+			fields     CustomValues
+			want       []FieldError
+		}{
+			{"Missing", "Broken laptop", "", nil, []FieldError{
+				{"asset_tag", "is required"}, {"floor", "is required"},
+			}},
+			{"Blank", "Broken laptop", "", CustomValues{"asset_tag": "  ", "floor": 2}, []FieldError{
+				{"asset_tag", "is required"},
+			}},
+			{"Wrong Types", "Broken laptop", "", CustomValues{"asset_tag": 1234, "floor": "third"}, []FieldError{
+				{"asset_tag", "must be a string"}, {"floor", "must be a number"},
+			}},
+			{"Extra", "Broken laptop", "", CustomValues{"asset_tag": "IT-1", "floor": 3, "colour": "grey"}, []FieldError{
+				{"colour", "is not a field of the IT department"},
+			}},
+			{"Chosen Department", "Screen flickers", "it", CustomValues{"floor": 3}, []FieldError{
+				{"asset_tag", "is required"},
+			}},
+			{"General Has No Fields", "Noise at night", "", CustomValues{"floor": 3}, []FieldError{
+				{"floor", "is not a field of the General department"},
+			}},
+		} {
+			status, resp := submit(tc.title, tc.department, tc.fields)
+			if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+				t.Errorf("%s: expected 400 %s, got %d %s", tc.name, ErrCodeValidationFailed, status, resp.ErrorCode)
+				continue
+			}
+			var got []FieldError
+			resp.decode(t, &got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+			}
+		}
+
+		if status, _ := submit("Broken laptop", "Plumbing", nil); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an unknown department, got %d", status)
+		}
+	})
+
+	status, resp = submit("Broken laptop", "", CustomValues{"asset_tag": " IT-0042 ", "floor": 3})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d (%s)", status, resp.Error)
+	}
+	var complaint Complaint
+	resp.decode(t, &complaint)
+	want := CustomValues{"asset_tag": "IT-0042", "floor": float64(3)}
+	if complaint.Department != "IT" || !reflect.DeepEqual(complaint.CustomFields, want) {
+		t.Errorf("Expected IT with %v, got %s with %v", want, complaint.Department, complaint.CustomFields)
+	}
+	if complaint.DueAt != "2024-06-03 14:00:00" {
+		t.Errorf("Expected the department's 4h SLA, got due at %q", complaint.DueAt)
+	}
+
+	t.Run("Shown In Views And Exports", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: code, ComplaintID: complaint.ID})
+		var viewed Complaint
+		resp.decode(t, &viewed)
+		if !reflect.DeepEqual(viewed.CustomFields, want) {
+			t.Errorf("Expected %v in the view, got %v", want, viewed.CustomFields)
+		}
+
+		lines := exportLines(t, srv.URL+"/exportComplaints?secret_code="+adminSecret)
+		if len(lines) != 1 || !reflect.DeepEqual(lines[0].CustomFields, want) {
+			t.Errorf("Expected %v in the export, got %+v", want, lines)
+		}
+	})
+
+	t.Run("Invalid Templates", func(t *testing.T) {
+		for _, req := range []AddDepartmentRequest{
+			{Name: "Facilities", Fields: []CustomField{{Name: "location", Type: "date"}}},
+			{Name: "Facilities", Fields: []CustomField{{Name: "location", Type: "string"}, {Name: "location", Type: "number"}}},
+			{Name: "Facilities", Fields: []CustomField{{Type: "string"}}},
+			{Name: "Facilities", SLA: "two days"},
+			{Name: "Facilities", SLA: "-4h"},
+		} {
+			req.SecretCode, req.Keywords = adminSecret, []string{"heating"}
+			if status, _ := postJSON(t, srv, "/addDepartment", req); status != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %+v, got %d", req, status)
+			}
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Draft is a complaint a user has started but not submitted. Each user has
+// at most one. Drafts are private to their author: no listing, report or
+// admin endpoint sees them.
+type Draft struct {
+	Title      string `json:"title"`
+	Summary    string `json:"summary"`
+	Rating     int    `json:"rating,omitempty"`
+	Department string `json:"department,omitempty"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+type SaveDraftRequest struct {
+	SecretCode string `json:"secret_code"`
+	Title      string `json:"title"`
+	Summary    string `json:"summary"`
+	Rating     int    `json:"rating,omitempty"`
+	Department string `json:"department,omitempty"`
+}
+
+type GetDraftRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+// withDraft fills in the complaint fields req leaves out from user's draft.
+// It fails when the user has no draft.
+func withDraft(user *User, req SubmitComplaintRequest) (SubmitComplaintRequest, *APIError) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	draft, exists := storage.drafts[user.ID]
+	if !exists {
+		return req, newAPIError(http.StatusNotFound, ErrCodeNotFound, "No draft to submit")
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		req.Title = draft.Title
+	}
+	if strings.TrimSpace(req.Summary) == "" {
+		req.Summary = draft.Summary
+	}
+	if req.Rating == 0 {
+		req.Rating = draft.Rating
+	}
+	if strings.TrimSpace(req.Department) == "" {
+		req.Department = draft.Department
+	}
+	return req, nil
+}
+
+// purgeStaleDrafts removes drafts last saved before cutoff and returns how
+// many it removed
+func purgeStaleDrafts(cutoff time.Time) int {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	purged := 0
+	for userID, draft := range storage.drafts {
+		updated, err := parseTimestamp(draft.UpdatedAt)
+		if err == nil && !updated.Before(cutoff) {
+			continue
+		}
+		delete(storage.drafts, userID)
+		purged++
+	}
+	return purged
+}
+
+// /saveDraft - Save the caller's draft, replacing any earlier one. Only
+// lengths are checked; the rest waits for submission.
+func saveDraftHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req SaveDraftRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	draft := Draft{
+		Title:      sanitizeText(req.Title, false),
+		Summary:    sanitizeText(req.Summary, true),
+		Rating:     req.Rating,
+		Department: strings.TrimSpace(req.Department),
+	}
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.maxRunes("title", draft.Title, maxTitleLength)
+	v.maxRunes("summary", draft.Summary, maxSummaryLength)
+	v.maxRunes("department", draft.Department, maxDepartmentNameLength)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	draft.UpdatedAt = getCurrentTime()
+	storage.drafts[user.ID] = &draft
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Draft saved successfully",
+		Data:    draft,
+	})
+}
+
+// /getDraft - The caller's draft
+func getDraftHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req GetDraftRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	draft, exists := storage.drafts[user.ID]
+	if !exists {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "No draft saved")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Draft retrieved successfully",
+		Data:    *draft,
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrafts(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 10, 0, 0, 0, time.Local))
+	_, code := registerTestUser(t, srv, "Drafting User", "drafting@example.com")
+
+	getDraft := func(secret string) (int, Draft) {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/getDraft", GetDraftRequest{SecretCode: secret})
+		var draft Draft
+		if status == http.StatusOK {
+			resp.decode(t, &draft)
+		}
+		return status, draft
+	}
+	saveDraft := func(req SaveDraftRequest) {
+		t.Helper()
+		req.SecretCode = code
+		if status, resp := postJSON(t, srv, "/saveDraft", req); status != http.StatusOK {
+			t.Fatalf("Expected 200 saving a draft, got %d (%s)", status, resp.Error)
+		}
+	}
+
+	if status, _ := getDraft(code); status != http.StatusNotFound {
+		t.Errorf("Expected 404 before any draft, got %d", status)
+	}
+
+	// Incomplete drafts are fine; only lengths are checked
+	saveDraft(SaveDraftRequest{Title: "Heating"})
+	saveDraft(SaveDraftRequest{Title: "Heating is off", Summary: "Since Monday the radiators on floor 3 are cold", Rating: 15})
+	status, draft := getDraft(code)
+	if status != http.StatusOK || draft.Title != "Heating is off" || draft.Rating != 15 || draft.UpdatedAt == "" {
+		t.Fatalf("Expected the overwritten draft, got %d %+v", status, draft)
+	}
+	long := SaveDraftRequest{SecretCode: code, Summary: strings.Repeat("word ", maxSummaryLength/4)}
+	if status, _ := postJSON(t, srv, "/saveDraft", long); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a summary that is too long, got %d", status)
+	}
+
+	t.Run("Private", func(t *testing.T) {
+		if status, _ := getDraft(adminSecret); status != http.StatusNotFound {
+			t.Errorf("Expected the admin to see no draft, got %d", status)
+		}
+		_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret})
+		var all []Complaint
+		resp.decode(t, &all)
+		if len(all) != 0 {
+			t.Errorf("Expected drafts left out of listings, got %+v", all)
+		}
+	})
+
+	t.Run("Promote", func(t *testing.T) {
+		// Full validation applies: the draft's rating is out of range
+		status, _ := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, FromDraft: true})
+		if status != http.StatusBadRequest {
+			t.Fatalf("Expected 400 for an invalid draft, got %d", status)
+		}
+		if status, _ := getDraft(code); status != http.StatusOK {
+			t.Fatalf("Expected a rejected submission to keep the draft, got %d", status)
+		}
+
+		// Fields in the request override the draft's
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, FromDraft: true, Rating: 8})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		if complaint.Title != "Heating is off" || complaint.Summary != "Since Monday the radiators on floor 3 are cold" || complaint.Rating != 8 {
+			t.Errorf("Expected the draft submitted with rating 8, got %+v", complaint)
+		}
+
+		if status, _ := getDraft(code); status != http.StatusNotFound {
+			t.Errorf("Expected the draft cleared, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, FromDraft: true}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 submitting without a draft, got %d", status)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		saveDraft(SaveDraftRequest{Title: "Old idea"})
+		_, other := registerTestUser(t, srv, "Other User", "other@example.com")
+		fake.Advance(20 * 24 * time.Hour)
+		postJSON(t, srv, "/saveDraft", SaveDraftRequest{SecretCode: other, Title: "Newer idea"})
+		fake.Advance(11 * 24 * time.Hour)
+
+		status, resp := postJSON(t, srv, "/runJobs", RunJobsRequest{SecretCode: adminSecret, Job: "purge_stale_drafts"})
+		var results []JobResult
+		resp.decode(t, &results)
+		if status != http.StatusOK || len(results) != 1 || results[0].Affected != 1 {
+			t.Fatalf("Expected one draft purged, got %d %+v", status, results)
+		}
+		if status, _ := getDraft(code); status != http.StatusNotFound {
+			t.Errorf("Expected the 31 day old draft purged, got %d", status)
+		}
+		if status, _ := getDraft(other); status != http.StatusOK {
+			t.Errorf("Expected the 11 day old draft kept, got %d", status)
+		}
+	})
+}
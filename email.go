@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// EmailMessage is a plain-text email to one recipient
+type EmailMessage struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	EventID int    `json:"-"` // the outbox message ID, sent as X-Event-ID when set
+	// CorrelationID is sent as X-Correlation-Id when set
+	CorrelationID string `json:"-"`
+}
+
+// Notifier delivers emails
+type Notifier interface {
+	Send(message EmailMessage) error
+}
+
+// smtpNotifier sends through an SMTP server, authenticating when a username
+// is set. net/smtp only sends credentials over TLS or to localhost.
+type smtpNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func newSMTPNotifier(host string, port int, username, password, from string) *smtpNotifier {
+	n := &smtpNotifier{addr: net.JoinHostPort(host, strconv.Itoa(port)), from: from}
+	if username != "" {
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n
+}
+
+func (n *smtpNotifier) Send(message EmailMessage) error {
+	return smtp.SendMail(n.addr, n.auth, n.from, []string{message.To}, formatEmail(n.from, message))
+}
+
+// formatEmail renders message with its headers. The subject is MIME-encoded
+// so any text is safe in a header, and the body's line endings become CRLF.
+func formatEmail(from string, message EmailMessage) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	if message.EventID != 0 {
+		fmt.Fprintf(&b, "X-Event-ID: %d\r\n", message.EventID)
+	}
+	if message.CorrelationID != "" {
+		fmt.Fprintf(&b, "%s: %s\r\n", correlationHeader, message.CorrelationID)
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// oneLine collapses control characters, including line breaks, to spaces so
+// user text cannot add lines to a subject or break a template's layout
+func oneLine(s string) string {
+	return strings.Join(strings.FieldsFunc(s, unicode.IsControl), " ")
+}
+
+// multiLine keeps the line breaks in s but drops other control characters
+func multiLine(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		if r != '\n' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+var emailFuncs = template.FuncMap{"oneLine": oneLine, "multiLine": multiLine}
+
+// Templates of the emails sent to complaint owners and assignees. Every
+// user-supplied field goes through oneLine or multiLine.
+var (
+	resolvedSubject = template.Must(template.New("resolvedSubject").Funcs(emailFuncs).Parse(
+		`Your complaint "{{oneLine .Complaint.Title}}" has been resolved`))
+	resolvedBody = template.Must(template.New("resolvedBody").Funcs(emailFuncs).Parse(
+		`Hello {{oneLine .User.Name}},
+
+Your complaint {{.Complaint.Reference}} "{{oneLine .Complaint.Title}}" has been resolved.
+{{- if .Complaint.ResolutionNote}}
+
+Resolution note:
+{{multiLine .Complaint.ResolutionNote}}
+{{- end}}
+
+Complaint Portal
+`))
+	escalatedSubject = template.Must(template.New("escalatedSubject").Funcs(emailFuncs).Parse(
+		`Complaint "{{oneLine .Complaint.Title}}" has been escalated`))
+	escalatedBody = template.Must(template.New("escalatedBody").Funcs(emailFuncs).Parse(
+		`Hello {{oneLine .User.Name}},
+
+The complaint {{.Complaint.Reference}} "{{oneLine .Complaint.Title}}", assigned to you, is still unresolved
+{{- with .Complaint.CreatedAt}} since {{.}}{{end}} and has been escalated.
+
+Complaint Portal
+`))
+)
+
+// emailData is what the email templates are rendered with
+type emailData struct {
+	User      *User
+	Complaint *Complaint
+}
+
+// Mailer is the outbox channel that emails owners when their complaints
+// are resolved, and assignees when theirs are escalated, sending through a
+// Notifier
+type Mailer struct {
+	notifier Notifier
+}
+
+func newMailer(notifier Notifier) *Mailer {
+	return &Mailer{notifier: notifier}
+}
+
+func (m *Mailer) deliver(eventID int, correlationID string, payload []byte) error {
+	var message EmailMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+	message.EventID = eventID
+	message.CorrelationID = correlationID
+	return m.notifier.Send(message)
+}
+
+// message renders the resolution email to the owner of a resolved
+// complaint, or the escalation email to the assignee of an escalated one.
+// Callers must hold storage.mutex.
+func (m *Mailer) message(event Event) (interface{}, bool) {
+	complaint := event.Complaint
+	var recipientID int
+	var subjectTemplate, bodyTemplate *template.Template
+	switch event.Type {
+	case eventComplaintResolved:
+		recipientID, subjectTemplate, bodyTemplate = complaint.UserID, resolvedSubject, resolvedBody
+	case eventComplaintEscalated:
+		recipientID, subjectTemplate, bodyTemplate = complaint.AssignedTo, escalatedSubject, escalatedBody
+	default:
+		return nil, false
+	}
+	recipient, active := activeUser(recipientID)
+	if !active || recipient.Email == "" {
+		return nil, false
+	}
+
+	data := emailData{User: recipient, Complaint: &complaint}
+	var subject, body strings.Builder
+	if err := subjectTemplate.Execute(&subject, data); err != nil {
+		logger.Error("Email not rendered", "event", event.Type, logKeyComplaintID, complaint.ID, logKeyError, err)
+		return nil, false
+	}
+	if err := bodyTemplate.Execute(&body, data); err != nil {
+		logger.Error("Email not rendered", "event", event.Type, logKeyComplaintID, complaint.ID, logKeyError, err)
+		return nil, false
+	}
+	return EmailMessage{To: recipient.Email, Subject: subject.String(), Body: body.String()}, true
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotifier records every email and fails the first failures sends
+type fakeNotifier struct {
+	mutex    sync.Mutex
+	messages []EmailMessage
+	sends    int
+	failures int
+	received chan EmailMessage
+}
+
+func (n *fakeNotifier) Send(message EmailMessage) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.sends++
+	if n.sends <= n.failures {
+		return errors.New("mail server unavailable")
+	}
+	n.messages = append(n.messages, message)
+	n.received <- message
+	return nil
+}
+
+func (n *fakeNotifier) wait(t *testing.T) EmailMessage {
+	t.Helper()
+	select {
+	case message := <-n.received:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for an email")
+	}
+	return EmailMessage{}
+}
+
+// useFakeNotifier sends the test's emails to a fake notifier
+func useFakeNotifier(t *testing.T, failures int) *fakeNotifier {
+	t.Helper()
+	fake := &fakeNotifier{failures: failures, received: make(chan EmailMessage, 100)}
+	useDispatcher(t, map[string]outboxChannel{outboxChannelEmail: newMailer(fake)})
+	return fake
+}
+
+func TestResolutionEmail(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Dana Scully", "dana@example.com")
+
+	t.Run("Sent To Owner", func(t *testing.T) {
+		fake := useFakeNotifier(t, 0)
+		complaint := submitTestComplaint(t, srv, code, "Heating broken", 8)
+		status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{
+			SecretCode: adminSecret, ComplaintID: complaint.ID, Note: "Boiler replaced\nCall us if it fails again",
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+
+		message := fake.wait(t)
+		if message.To != "dana@example.com" {
+			t.Errorf("Expected the owner as recipient, got %q", message.To)
+		}
+		if message.Subject != `Your complaint "Heating broken" has been resolved` {
+			t.Errorf("Unexpected subject %q", message.Subject)
+		}
+		for _, want := range []string{"Hello Dana Scully,", complaint.Reference + ` "Heating broken"`, "Boiler replaced\nCall us if it fails again"} {
+			if !strings.Contains(message.Body, want) {
+				t.Errorf("Expected %q in body:\n%s", want, message.Body)
+			}
+		}
+	})
+
+	t.Run("Retried Without Failing The Request", func(t *testing.T) {
+		fake := useFakeNotifier(t, 2)
+		complaint := submitTestComplaint(t, srv, code, "Leaking tap", 4)
+		if status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID}); status != http.StatusOK {
+			t.Fatalf("Expected 200 while the mail server fails, got %d (%s)", status, resp.Error)
+		}
+		message := fake.wait(t)
+		if strings.Contains(message.Body, "Resolution note") {
+			t.Errorf("Expected no note section without a note:\n%s", message.Body)
+		}
+		fake.mutex.Lock()
+		defer fake.mutex.Unlock()
+		if fake.sends != 3 {
+			t.Errorf("Expected 3 attempts, got %d", fake.sends)
+		}
+		if message.EventID == 0 {
+			t.Error("Expected the email to carry its event ID")
+		}
+	})
+}
+
+func TestEmailTemplateEscaping(t *testing.T) {
+	var subject strings.Builder
+	data := emailData{User: &User{Name: "Eve"}, Complaint: &Complaint{Title: "Broken\r\nBcc: everyone@example.com"}}
+	if err := resolvedSubject.Execute(&subject, data); err != nil {
+		t.Fatalf("Failed to render subject: %v", err)
+	}
+	if strings.ContainsAny(subject.String(), "\r\n") {
+		t.Errorf("Expected a single-line subject, got %q", subject.String())
+	}
+
+	raw := string(formatEmail("portal@example.com", EmailMessage{To: "eve@example.com", Subject: "Réparé", Body: "one\ntwo", EventID: 7}))
+	if !strings.Contains(raw, "Subject: =?utf-8?q?R=C3=A9par=C3=A9?=\r\n") {
+		t.Errorf("Expected a MIME-encoded subject in:\n%s", raw)
+	}
+	if !strings.Contains(raw, "X-Event-ID: 7\r\n") {
+		t.Errorf("Expected the event ID header in:\n%s", raw)
+	}
+	if !strings.HasSuffix(raw, "\r\n\r\none\r\ntwo") {
+		t.Errorf("Expected CRLF line endings in the body:\n%q", raw)
+	}
+}
+
+// TestSMTPNotifier delivers through net/smtp to a minimal SMTP listener
+func TestSMTPNotifier(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var transcript []string
+		reply("220 localhost ESMTP")
+		for inData := false; ; {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				received <- transcript
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			transcript = append(transcript, line)
+			switch {
+			case inData && line == ".":
+				inData = false
+				reply("250 OK")
+			case inData:
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 localhost")
+			case line == "DATA":
+				inData = true
+				reply("354 End data with <CR><LF>.<CR><LF>")
+			case line == "QUIT":
+				reply("221 Bye")
+				received <- transcript
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	notifier := newSMTPNotifier(host, portNumber, "", "", "portal@example.com")
+	err = notifier.Send(EmailMessage{To: "dana@example.com", Subject: "Resolved", Body: "Hello\n.\nBye"})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var transcript []string
+	select {
+	case transcript = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the SMTP session")
+	}
+	session := strings.Join(transcript, "\n")
+	for _, want := range []string{"MAIL FROM:<portal@example.com>", "RCPT TO:<dana@example.com>", "Subject: Resolved", "Hello\n..\nBye"} {
+		if !strings.Contains(session, want) {
+			t.Errorf("Expected %q in the SMTP session:\n%s", want, session)
+		}
+	}
+}
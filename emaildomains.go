@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Email domain policies
+const (
+	emailDomainsAny   = ""      // any domain may register
+	emailDomainsAllow = "allow" // only -allowed-email-domains
+	emailDomainsBlock = "block" // any but the blocked domains
+)
+
+//go:embed data/disposable_domains.txt
+var bundledBlockedDomains []byte
+
+// EmailDomainPolicy decides which email domains may register. A listed
+// domain covers its subdomains, so blocking blocked.com blocks
+// mail.blocked.com too.
+type EmailDomainPolicy struct {
+	mode    string
+	allowed []string
+	blocked map[string]bool
+	source  string // where the blocked domains came from
+	mutex   sync.RWMutex
+}
+
+var emailDomains = &EmailDomainPolicy{}
+
+// EmailDomainsStatus is the /reloadEmailDomains payload
+type EmailDomainsStatus struct {
+	Mode           string `json:"mode"`
+	AllowedDomains int    `json:"allowed_domains"`
+	BlockedDomains int    `json:"blocked_domains"`
+	Source         string `json:"source,omitempty"` // file of the blocked domains, or "bundled"
+}
+
+type ReloadEmailDomainsRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+// normalizeDomain lowercases a domain and drops a trailing dot
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// emailDomain is the normalized domain of email
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return normalizeDomain(email[at+1:])
+}
+
+// parseDomainList reads one domain per line, skipping blanks and # comments
+func parseDomainList(r io.Reader) (map[string]bool, error) {
+	domains := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		domain := normalizeDomain(text)
+		if strings.ContainsAny(domain, " @/") || !strings.Contains(domain, ".") {
+			return nil, fmt.Errorf("line %d: %q is not a domain", line, text)
+		}
+		domains[domain] = true
+	}
+	return domains, scanner.Err()
+}
+
+// parseAllowedDomains reads -allowed-email-domains, a comma-separated list
+func parseAllowedDomains(value string) []string {
+	var domains []string
+	for _, item := range strings.Split(value, ",") {
+		if domain := normalizeDomain(strings.TrimPrefix(strings.TrimSpace(item), "@")); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// load reads the policy from config, and in block mode the blocked domains
+// from -blocked-email-domains-file or the bundled list. On error the policy
+// in force is kept.
+func (p *EmailDomainPolicy) load() error {
+	mode := config.EmailDomainPolicy
+	allowed := parseAllowedDomains(config.AllowedEmailDomains)
+	var blocked map[string]bool
+	source := ""
+
+	switch mode {
+	case emailDomainsAny:
+	case emailDomainsAllow:
+		if len(allowed) == 0 {
+			return errors.New("email domain policy allow needs -allowed-email-domains")
+		}
+	case emailDomainsBlock:
+		data, name := bundledBlockedDomains, "bundled"
+		if config.BlockedEmailDomainsFile != "" {
+			var err error
+			if data, err = os.ReadFile(config.BlockedEmailDomainsFile); err != nil {
+				return err
+			}
+			name = config.BlockedEmailDomainsFile
+		}
+		var err error
+		if blocked, err = parseDomainList(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		source = name
+	default:
+		return fmt.Errorf("email domain policy must be allow or block, not %q", mode)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.mode, p.allowed, p.blocked, p.source = mode, allowed, blocked, source
+	return nil
+}
+
+func (p *EmailDomainPolicy) status() EmailDomainsStatus {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return EmailDomainsStatus{Mode: p.mode, AllowedDomains: len(p.allowed), BlockedDomains: len(p.blocked), Source: p.source}
+}
+
+// covers reports whether domain is listed, itself or as a parent
+func covers(listed func(string) bool, domain string) bool {
+	for domain != "" {
+		if listed(domain) {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
+
+// check refuses an email whose domain the policy does not let register.
+// Every path that creates a user goes through it, but for imports, which
+// an admin vouches for.
+func (p *EmailDomainPolicy) check(email string) *APIError {
+	domain := emailDomain(email)
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	switch p.mode {
+	case emailDomainsAllow:
+		allowed := covers(func(candidate string) bool {
+			for _, entry := range p.allowed {
+				if candidate == entry {
+					return true
+				}
+			}
+			return false
+		}, domain)
+		if !allowed {
+			return newAPIError(http.StatusBadRequest, ErrCodeEmailDomainNotAllowed, "Email addresses at "+domain+" cannot be used here; use "+strings.Join(p.allowed, " or ")+" instead")
+		}
+	case emailDomainsBlock:
+		if covers(func(candidate string) bool { return p.blocked[candidate] }, domain) {
+			return newAPIError(http.StatusBadRequest, ErrCodeEmailDomainNotAllowed, "Disposable email addresses such as "+domain+" cannot be used")
+		}
+	}
+	return nil
+}
+
+// /reloadEmailDomains - Re-read the blocked email domains, such as after the
+// list file was updated (super-admin only)
+func reloadEmailDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ReloadEmailDomainsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	if err := emailDomains.load(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to reload email domains: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Email domains reloaded",
+		Data:    emailDomains.status(),
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmailDomainPolicy(t *testing.T) {
+	srv := newTestServer(t)
+
+	register := func(email string) (int, ErrorCode) {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/register", RegisterRequest{Name: "Domain User", Email: email})
+		return status, resp.ErrorCode
+	}
+
+	t.Run("Allow Mode", func(t *testing.T) {
+		config.EmailDomainPolicy = emailDomainsAllow
+		config.AllowedEmailDomains = "example.com, @Campus.edu"
+		if err := emailDomains.load(); err != nil {
+			t.Fatalf("Expected the policy to load, got %v", err)
+		}
+
+		for _, email := range []string{"a@example.com", "b@EXAMPLE.com", "c@cs.campus.edu"} {
+			if status, code := register(email); status != http.StatusCreated {
+				t.Errorf("Expected %s to register, got %d %s", email, status, code)
+			}
+		}
+		for _, email := range []string{"d@gmail.com", "e@notexample.com", "f@example.com.evil.org"} {
+			if status, code := register(email); status != http.StatusBadRequest || code != ErrCodeEmailDomainNotAllowed {
+				t.Errorf("Expected %s to be refused with EMAIL_DOMAIN_NOT_ALLOWED, got %d %s", email, status, code)
+			}
+		}
+
+		org := CreateOrganizationRequest{SecretCode: adminSecret, Name: "Gamma", AdminName: "Gamma Admin", AdminEmail: "admin@gmail.com"}
+		if status, resp := postJSON(t, srv, "/createOrganization", org); status != http.StatusBadRequest || resp.ErrorCode != ErrCodeEmailDomainNotAllowed {
+			t.Errorf("Expected the first admin's email to be refused with EMAIL_DOMAIN_NOT_ALLOWED, got %d %s", status, resp.ErrorCode)
+		}
+		org.AdminEmail = "admin@example.com"
+		if status, resp := postJSON(t, srv, "/createOrganization", org); status != http.StatusCreated {
+			t.Errorf("Expected the organization to be created with an allowed email, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Block Mode", func(t *testing.T) {
+		config.EmailDomainPolicy = emailDomainsBlock
+		config.AllowedEmailDomains = ""
+		if err := emailDomains.load(); err != nil {
+			t.Fatalf("Expected the bundled list to load, got %v", err)
+		}
+
+		for _, email := range []string{"g@mailinator.com", "h@Mail.Mailinator.com", "i@YopMail.com"} {
+			if status, code := register(email); status != http.StatusBadRequest || code != ErrCodeEmailDomainNotAllowed {
+				t.Errorf("Expected %s to be refused with EMAIL_DOMAIN_NOT_ALLOWED, got %d %s", email, status, code)
+			}
+		}
+		if status, code := register("j@gmail.com"); status != http.StatusCreated {
+			t.Errorf("Expected an unlisted domain to register, got %d %s", status, code)
+		}
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "blocked.txt")
+		if err := os.WriteFile(path, []byte("# local list\nblocked.com\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		config.BlockedEmailDomainsFile = path
+		if err := emailDomains.load(); err != nil {
+			t.Fatalf("Expected the file to load, got %v", err)
+		}
+		if status, _ := register("k@mailinator.com"); status != http.StatusCreated {
+			t.Errorf("Expected the file to replace the bundled list, got %d", status)
+		}
+		if status, _ := register("l@mail.blocked.com"); status != http.StatusBadRequest {
+			t.Errorf("Expected a subdomain of a blocked domain to be refused, got %d", status)
+		}
+
+		if err := os.WriteFile(path, []byte("blocked.com\nspam.example\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if status, _ := register("m@spam.example"); status != http.StatusCreated {
+			t.Errorf("Expected the old list until a reload, got %d", status)
+		}
+
+		_, code := registerTestUser(t, srv, "Plain User", "plain@gmail.com")
+		if status, _ := postJSON(t, srv, "/reloadEmailDomains", ReloadEmailDomainsRequest{SecretCode: code}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a regular user, got %d", status)
+		}
+		status, resp := postJSON(t, srv, "/reloadEmailDomains", ReloadEmailDomainsRequest{SecretCode: adminSecret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var reloaded EmailDomainsStatus
+		resp.decode(t, &reloaded)
+		if reloaded.Mode != emailDomainsBlock || reloaded.BlockedDomains != 2 || reloaded.Source != path {
+			t.Errorf("Expected two blocked domains from the file, got %+v", reloaded)
+		}
+		if status, _ := register("n@spam.example"); status != http.StatusBadRequest {
+			t.Errorf("Expected the reloaded list to apply, got %d", status)
+		}
+
+		if err := os.WriteFile(path, []byte("not a domain\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		status, resp = postJSON(t, srv, "/reloadEmailDomains", ReloadEmailDomainsRequest{SecretCode: adminSecret})
+		if status != http.StatusInternalServerError || !strings.Contains(resp.Error, "line 1") {
+			t.Errorf("Expected a bad file to fail with its line, got %d %q", status, resp.Error)
+		}
+		if status, _ := register("o@spam.example"); status != http.StatusBadRequest {
+			t.Errorf("Expected a failed reload to keep the previous list, got %d", status)
+		}
+	})
+
+	t.Run("Bad Config", func(t *testing.T) {
+		config.EmailDomainPolicy = emailDomainsAllow
+		config.AllowedEmailDomains = ""
+		if err := emailDomains.load(); err == nil {
+			t.Error("Expected allow mode without domains to fail")
+		}
+		config.EmailDomainPolicy = "deny"
+		if err := emailDomains.load(); err == nil {
+			t.Error("Expected an unknown policy to fail")
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorCode is a stable, machine-readable identifier for a failure. Clients
+// should branch on these rather than on the human-readable error message,
+// which may change.
+type ErrorCode string
+
+const (
+	// ErrCodeInvalidJSON: the request body could not be decoded (400)
+	ErrCodeInvalidJSON ErrorCode = "INVALID_JSON"
+	// ErrCodeValidationFailed: a field is missing or out of range (400)
+	ErrCodeValidationFailed ErrorCode = "VALIDATION_FAILED"
+	// ErrCodeUnauthorized: the secret code is missing from the index (401)
+	ErrCodeUnauthorized ErrorCode = "UNAUTHORIZED"
+	// ErrCodeTokenExpired: a JWT that was valid has passed its expiry (401)
+	ErrCodeTokenExpired ErrorCode = "TOKEN_EXPIRED"
+	// ErrCodeForbidden: the caller is authenticated but not allowed (403)
+	ErrCodeForbidden ErrorCode = "FORBIDDEN"
+	// ErrCodeNotFound: the referenced user or complaint does not exist (404)
+	ErrCodeNotFound ErrorCode = "NOT_FOUND"
+	// ErrCodeMethodNotAllowed: wrong HTTP method for the endpoint (405)
+	ErrCodeMethodNotAllowed ErrorCode = "METHOD_NOT_ALLOWED"
+	// ErrCodeEmailExists: registration with an email already in use (409)
+	ErrCodeEmailExists ErrorCode = "EMAIL_EXISTS"
+	// ErrCodeDepartmentExists: adding a department with a name already in use (409)
+	ErrCodeDepartmentExists ErrorCode = "DEPARTMENT_EXISTS"
+	// ErrCodeOrganizationExists: creating an organization with a name already in use (409)
+	ErrCodeOrganizationExists ErrorCode = "ORGANIZATION_EXISTS"
+	// ErrCodeBuildingExists: adding a building to a location registry under a name already in use (409)
+	ErrCodeBuildingExists ErrorCode = "BUILDING_EXISTS"
+	// ErrCodeEmailDomainNotAllowed: registering with an email domain the domain policy refuses (400)
+	ErrCodeEmailDomainNotAllowed ErrorCode = "EMAIL_DOMAIN_NOT_ALLOWED"
+	// ErrCodeAccountDeactivated: the caller's account was deactivated by an admin (403)
+	ErrCodeAccountDeactivated ErrorCode = "ACCOUNT_DEACTIVATED"
+	// ErrCodeInviteRequired: registering without an invite code while invites are required (403)
+	ErrCodeInviteRequired ErrorCode = "INVITE_REQUIRED"
+	// ErrCodeInviteInvalid: registering with an invite code that does not exist (403)
+	ErrCodeInviteInvalid ErrorCode = "INVITE_INVALID"
+	// ErrCodeInviteExpired: registering with an invite code past its expiry (403)
+	ErrCodeInviteExpired ErrorCode = "INVITE_EXPIRED"
+	// ErrCodeInviteExhausted: registering with an invite code that has no uses left (403)
+	ErrCodeInviteExhausted ErrorCode = "INVITE_EXHAUSTED"
+	// ErrCodeAlreadyResolved: resolving a complaint that is already resolved (400)
+	ErrCodeAlreadyResolved ErrorCode = "ALREADY_RESOLVED"
+	// ErrCodeNotResolved: an action that needs a resolved complaint on an open one (409)
+	ErrCodeNotResolved ErrorCode = "NOT_RESOLVED"
+	// ErrCodeFeedbackExists: feedback was already given for the complaint (409)
+	ErrCodeFeedbackExists ErrorCode = "FEEDBACK_EXISTS"
+	// ErrCodeAlreadyDeleted: deleting a complaint that is already in the trash, or a comment already deleted (409)
+	ErrCodeAlreadyDeleted ErrorCode = "ALREADY_DELETED"
+	// ErrCodeNotDeleted: restoring a complaint that is not in the trash (409)
+	ErrCodeNotDeleted ErrorCode = "NOT_DELETED"
+	// ErrCodeAlreadyArchived: archiving a complaint that is already archived (409)
+	ErrCodeAlreadyArchived ErrorCode = "ALREADY_ARCHIVED"
+	// ErrCodeNotArchived: unarchiving a complaint that is not archived (409)
+	ErrCodeNotArchived ErrorCode = "NOT_ARCHIVED"
+	// ErrCodeAlreadyMerged: merging, or resolving, a complaint that was merged into another (409)
+	ErrCodeAlreadyMerged ErrorCode = "ALREADY_MERGED"
+	// ErrCodeNotInReview: approving or redacting a complaint moderation did not flag, or that was already reviewed (409)
+	ErrCodeNotInReview ErrorCode = "NOT_IN_REVIEW"
+	// ErrCodeNotPendingConfirmation: confirming or rejecting a resolution that is not awaiting the owner's answer (409)
+	ErrCodeNotPendingConfirmation ErrorCode = "NOT_PENDING_CONFIRMATION"
+	// ErrCodeAlreadyPinned: pinning a complaint that is already pinned (409)
+	ErrCodeAlreadyPinned ErrorCode = "ALREADY_PINNED"
+	// ErrCodeNotPinned: unpinning a complaint that is not pinned (409)
+	ErrCodeNotPinned ErrorCode = "NOT_PINNED"
+	// ErrCodePinLimitReached: pinning more complaints than -max-pinned allows (409)
+	ErrCodePinLimitReached ErrorCode = "PIN_LIMIT_REACHED"
+	// ErrCodeAlreadyDeactivated: deactivating a user who is already deactivated (409)
+	ErrCodeAlreadyDeactivated ErrorCode = "ALREADY_DEACTIVATED"
+	// ErrCodeNotDeactivated: reactivating a user who is active (409)
+	ErrCodeNotDeactivated ErrorCode = "NOT_DEACTIVATED"
+	// ErrCodeAlreadyLinked: linking two complaints that are already linked (409)
+	ErrCodeAlreadyLinked ErrorCode = "ALREADY_LINKED"
+	// ErrCodeVersionConflict: the complaint changed since the version the client sent (409)
+	ErrCodeVersionConflict ErrorCode = "VERSION_CONFLICT"
+	// ErrCodeReplayDetected: a write repeated a nonce or carried a stale timestamp, with -replay-protection on (409)
+	ErrCodeReplayDetected ErrorCode = "REPLAY_DETECTED"
+	// ErrCodeQuotaExceeded: the user has too many open complaints to submit another (409)
+	ErrCodeQuotaExceeded ErrorCode = "QUOTA_EXCEEDED"
+	// ErrCodeUnknownSender: an email was ingested from an address no user has, with -ingest-create-users off (422)
+	ErrCodeUnknownSender ErrorCode = "UNKNOWN_SENDER"
+	// ErrCodeAccountLocked: too many failed authentication attempts (423)
+	ErrCodeAccountLocked ErrorCode = "ACCOUNT_LOCKED"
+	// ErrCodeTermsNotAccepted: the caller has not accepted the current terms of service (428)
+	ErrCodeTermsNotAccepted ErrorCode = "TERMS_NOT_ACCEPTED"
+	// ErrCodeRateLimited: the caller is sending requests too quickly (429)
+	ErrCodeRateLimited ErrorCode = "RATE_LIMITED"
+	// ErrCodeInternal: an unexpected server-side failure (500)
+	ErrCodeInternal ErrorCode = "INTERNAL_ERROR"
+	// ErrCodeUnavailable: the server is up but not ready to serve (503)
+	ErrCodeUnavailable ErrorCode = "SERVICE_UNAVAILABLE"
+	// ErrCodeMaintenance: writes are off while the portal is in maintenance mode (503)
+	ErrCodeMaintenance ErrorCode = "MAINTENANCE"
+	// ErrCodeTimeout: the request took longer than the server allows (503)
+	ErrCodeTimeout ErrorCode = "REQUEST_TIMEOUT"
+	// ErrCodePayloadTooLarge: the request body is over the endpoint's limit (413)
+	ErrCodePayloadTooLarge ErrorCode = "PAYLOAD_TOO_LARGE"
+)
+
+// errorStatuses is the HTTP status every error code is sent with. Each
+// code has exactly one, so clients can rely on either.
+var errorStatuses = map[ErrorCode]int{
+	ErrCodeInvalidJSON:            http.StatusBadRequest,
+	ErrCodeValidationFailed:       http.StatusBadRequest,
+	ErrCodeUnauthorized:           http.StatusUnauthorized,
+	ErrCodeTokenExpired:           http.StatusUnauthorized,
+	ErrCodeForbidden:              http.StatusForbidden,
+	ErrCodeNotFound:               http.StatusNotFound,
+	ErrCodeMethodNotAllowed:       http.StatusMethodNotAllowed,
+	ErrCodeEmailExists:            http.StatusConflict,
+	ErrCodeDepartmentExists:       http.StatusConflict,
+	ErrCodeOrganizationExists:     http.StatusConflict,
+	ErrCodeBuildingExists:         http.StatusConflict,
+	ErrCodeEmailDomainNotAllowed:  http.StatusBadRequest,
+	ErrCodeAccountDeactivated:     http.StatusForbidden,
+	ErrCodeInviteRequired:         http.StatusForbidden,
+	ErrCodeInviteInvalid:          http.StatusForbidden,
+	ErrCodeInviteExpired:          http.StatusForbidden,
+	ErrCodeInviteExhausted:        http.StatusForbidden,
+	ErrCodeAlreadyResolved:        http.StatusBadRequest,
+	ErrCodeNotResolved:            http.StatusConflict,
+	ErrCodeFeedbackExists:         http.StatusConflict,
+	ErrCodeAlreadyDeleted:         http.StatusConflict,
+	ErrCodeNotDeleted:             http.StatusConflict,
+	ErrCodeAlreadyArchived:        http.StatusConflict,
+	ErrCodeNotArchived:            http.StatusConflict,
+	ErrCodeAlreadyMerged:          http.StatusConflict,
+	ErrCodeNotInReview:            http.StatusConflict,
+	ErrCodeNotPendingConfirmation: http.StatusConflict,
+	ErrCodeAlreadyPinned:          http.StatusConflict,
+	ErrCodeNotPinned:              http.StatusConflict,
+	ErrCodePinLimitReached:        http.StatusConflict,
+	ErrCodeAlreadyDeactivated:     http.StatusConflict,
+	ErrCodeNotDeactivated:         http.StatusConflict,
+	ErrCodeAlreadyLinked:          http.StatusConflict,
+	ErrCodeVersionConflict:        http.StatusConflict,
+	ErrCodeReplayDetected:         http.StatusConflict,
+	ErrCodeQuotaExceeded:          http.StatusConflict,
+	ErrCodeUnknownSender:          http.StatusUnprocessableEntity,
+	ErrCodeAccountLocked:          http.StatusLocked,
+	ErrCodeTermsNotAccepted:       http.StatusPreconditionRequired,
+	ErrCodeRateLimited:            http.StatusTooManyRequests,
+	ErrCodeInternal:               http.StatusInternalServerError,
+	ErrCodeUnavailable:            http.StatusServiceUnavailable,
+	ErrCodeMaintenance:            http.StatusServiceUnavailable,
+	ErrCodeTimeout:                http.StatusServiceUnavailable,
+	ErrCodePayloadTooLarge:        http.StatusRequestEntityTooLarge,
+}
+
+// codeForStatus is the error code of an error response sent without one
+func codeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrCodeMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		return ErrCodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return ErrCodeRateLimited
+	case http.StatusServiceUnavailable:
+		return ErrCodeUnavailable
+	}
+	if status >= http.StatusInternalServerError {
+		return ErrCodeInternal
+	}
+	return ErrCodeValidationFailed
+}
+
+// consistentResponse makes response agree with statusCode, which decides
+// the outcome: a 4xx or 5xx status is sent as success false with an error
+// and an error code, anything else as success true with neither. A
+// response that said otherwise is a bug in its handler, and is logged.
+func consistentResponse(statusCode int, response APIResponse) APIResponse {
+	if statusCode >= http.StatusBadRequest {
+		if response.Success {
+			logger.Error("BUG: response sent with success true", "status", statusCode, "message", response.Message)
+		}
+		response.Success = false
+		if response.ErrorCode == "" {
+			response.ErrorCode = codeForStatus(statusCode)
+		}
+		if response.Error == "" {
+			response.Error = http.StatusText(statusCode)
+		}
+		return response
+	}
+
+	if !response.Success || response.Error != "" || response.ErrorCode != "" {
+		logger.Error("BUG: response sent as a failure", "status", statusCode, "error_code", response.ErrorCode, logKeyError, response.Error)
+	}
+	response.Success = true
+	response.Error = ""
+	response.ErrorCode = ""
+	return response
+}
+
+// APIError is a failure carrying the response it should produce. Operations
+// shared by the flat and /v1 routes return it instead of writing to the
+// ResponseWriter themselves.
+type APIError struct {
+	Status     int
+	Code       ErrorCode
+	Message    string
+	RetryAfter time.Duration // sent as a Retry-After header when set
+	Data       interface{}   // sent as the response data when set
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, code ErrorCode, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func respondWithAPIError(w http.ResponseWriter, err *APIError) {
+	if err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	}
+	respondWithJSON(w, err.Status, APIResponse{
+		Success:   false,
+		Error:     err.Message,
+		ErrorCode: err.Code,
+		Data:      err.Data,
+	})
+}
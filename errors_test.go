@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorCodes(t *testing.T) {
+	srv := newTestServer(t)
+	_, userCode := registerTestUser(t, srv, "Error User", "errors@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+
+	status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+		SecretCode: otherCode, Title: "Other's complaint", Summary: "Not yours", Rating: 5,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", status)
+	}
+	var complaint Complaint
+	resp.decode(t, &complaint)
+	if status, _ := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID}); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		endpoint string
+		payload  interface{}
+		status   int
+		code     ErrorCode
+	}{
+		{"Wrong Method", http.MethodGet, "/register", nil, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed},
+		{"Malformed JSON", http.MethodPost, "/login", "{not json", http.StatusBadRequest, ErrCodeInvalidJSON},
+		{"Register Missing Name", http.MethodPost, "/register", RegisterRequest{Email: "x@example.com"}, http.StatusBadRequest, ErrCodeValidationFailed},
+		{"Register Duplicate Email", http.MethodPost, "/register", RegisterRequest{Name: "Dup", Email: "errors@example.com"}, http.StatusConflict, ErrCodeEmailExists},
+		{"Login Missing Secret", http.MethodPost, "/login", LoginRequest{}, http.StatusBadRequest, ErrCodeValidationFailed},
+		{"Login Invalid Secret", http.MethodPost, "/login", LoginRequest{SecretCode: "NOPE"}, http.StatusUnauthorized, ErrCodeUnauthorized},
+		{"Submit Bad Rating", http.MethodPost, "/submitComplaint", SubmitComplaintRequest{SecretCode: userCode, Title: "T", Summary: "S", Rating: 11}, http.StatusBadRequest, ErrCodeValidationFailed},
+		{"Submit Missing Title", http.MethodPost, "/submitComplaint", SubmitComplaintRequest{SecretCode: userCode, Summary: "S", Rating: 5}, http.StatusBadRequest, ErrCodeValidationFailed},
+		{"Admin Listing As User", http.MethodPost, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: userCode}, http.StatusForbidden, ErrCodeForbidden},
+		{"View Missing ID", http.MethodPost, "/viewComplaint", ViewComplaintRequest{SecretCode: userCode}, http.StatusBadRequest, ErrCodeValidationFailed},
+		{"View Nonexistent", http.MethodPost, "/viewComplaint", ViewComplaintRequest{SecretCode: userCode, ComplaintID: 999}, http.StatusNotFound, ErrCodeNotFound},
+		{"View Someone Else's", http.MethodPost, "/viewComplaint", ViewComplaintRequest{SecretCode: userCode, ComplaintID: complaint.ID}, http.StatusNotFound, ErrCodeNotFound},
+		{"Resolve As User", http.MethodPost, "/resolveComplaint", ResolveComplaintRequest{SecretCode: userCode, ComplaintID: complaint.ID}, http.StatusForbidden, ErrCodeForbidden},
+		{"Resolve Nonexistent", http.MethodPost, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: 999}, http.StatusNotFound, ErrCodeNotFound},
+		{"Resolve Twice", http.MethodPost, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID}, http.StatusBadRequest, ErrCodeAlreadyResolved},
+		{"Rotate Other As User", http.MethodPost, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: userCode, UserID: 1}, http.StatusForbidden, ErrCodeForbidden},
+		{"Rotate Unknown User", http.MethodPost, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: adminSecret, UserID: 999}, http.StatusNotFound, ErrCodeNotFound},
+		{"Unlock Missing ID", http.MethodPost, "/unlockUser", UnlockUserRequest{SecretCode: adminSecret}, http.StatusBadRequest, ErrCodeValidationFailed},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var body string
+			switch p := tc.payload.(type) {
+			case nil:
+			case string:
+				body = p
+			default:
+				data, _ := json.Marshal(p)
+				body = string(data)
+			}
+
+			req, _ := http.NewRequest(tc.method, srv.URL+tc.endpoint, strings.NewReader(body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var response testResponse
+			if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, resp.StatusCode)
+			}
+			if response.ErrorCode != tc.code {
+				t.Errorf("Expected error_code %s, got %q", tc.code, response.ErrorCode)
+			}
+			if response.Success || response.Error == "" {
+				t.Errorf("Expected success false with a message, got %+v", response)
+			}
+		})
+	}
+
+	t.Run("Account Locked", func(t *testing.T) {
+		config.MaxFailedLogins = 1
+		status, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: "SEC_1_2"})
+		if status != http.StatusLocked {
+			t.Errorf("Expected status 423, got %d", status)
+		}
+		if resp.ErrorCode != ErrCodeAccountLocked {
+			t.Errorf("Expected error_code %s, got %q", ErrCodeAccountLocked, resp.ErrorCode)
+		}
+	})
+}
+
+func TestEveryErrorCodeHasAStatus(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse errors.go: %v", err)
+	}
+	codes := 0
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok || spec.Type == nil || spec.Type.(*ast.Ident).Name != "ErrorCode" {
+			return true
+		}
+		for _, name := range spec.Names {
+			codes++
+			value := spec.Values[0].(*ast.BasicLit).Value
+			if _, known := errorStatuses[ErrorCode(strings.Trim(value, `"`))]; !known {
+				t.Errorf("%s has no status in errorStatuses", name.Name)
+			}
+		}
+		return true
+	})
+	if codes != len(errorStatuses) {
+		t.Errorf("Expected one status per error code, got %d codes and %d statuses", codes, len(errorStatuses))
+	}
+}
+
+func TestResponsesAreConsistent(t *testing.T) {
+	t.Run("Status Decides", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			status   int
+			response APIResponse
+		}{
+			{"Failure With Success Status", http.StatusOK, APIResponse{Success: false, Message: "Done"}},
+			{"Error With Success Status", http.StatusCreated, APIResponse{Success: true, Error: "Oops", ErrorCode: ErrCodeInternal}},
+			{"Success With Error Status", http.StatusNotFound, APIResponse{Success: true, Message: "Found"}},
+			{"Error Without Code", http.StatusConflict, APIResponse{Error: "Taken"}},
+			{"Error Without Message", http.StatusBadGateway, APIResponse{}},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				respondWithJSON(rec, tc.status, tc.response)
+				var response testResponse
+				json.Unmarshal(rec.Body.Bytes(), &response)
+				failed := tc.status >= http.StatusBadRequest
+				if response.Success == failed || (response.ErrorCode != "") != failed || (response.Error != "") != failed {
+					t.Errorf("Expected success %v, got %+v", !failed, response)
+				}
+			})
+		}
+	})
+
+	t.Run("Error After Headers", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Type", "text/csv")
+		rec.Header().Set("Content-Disposition", `attachment; filename="report.csv"`)
+		respondWithError(rec, http.StatusInternalServerError, ErrCodeInternal, "Failed to build report")
+		if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected application/json, got %q", contentType)
+		}
+		if rec.Header().Get("Content-Disposition") != "" {
+			t.Error("Expected the attachment header to be dropped")
+		}
+	})
+
+	t.Run("Checker Catches Problems", func(t *testing.T) {
+		header := http.Header{"Content-Type": {"application/json"}}
+		bad := []struct {
+			status int
+			header http.Header
+			body   string
+		}{
+			{http.StatusOK, header, `{"success": false, "message": ""}`},
+			{http.StatusOK, header, `{"success": true, "error": "Oops"}`},
+			{http.StatusNotFound, header, `{"success": false, "error": "Gone", "error_code": "FORBIDDEN"}`},
+			{http.StatusNotFound, header, `{"success": false, "error": "Gone"}`},
+			{http.StatusNotFound, http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, "404 page not found\n"},
+		}
+		for _, tc := range bad {
+			if responseProblem(tc.status, tc.header, []byte(tc.body)) == nil {
+				t.Errorf("Expected %d %s to be caught", tc.status, tc.body)
+			}
+		}
+		if err := responseProblem(http.StatusOK, header, []byte(`{"schema_version": 5}`)); err != nil {
+			t.Errorf("Expected downloads to pass, got %v", err)
+		}
+	})
+
+	t.Run("Unknown Endpoint", func(t *testing.T) {
+		srv := newTestServer(t)
+		resp, err := http.Get(srv.URL + "/noSuchEndpoint")
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var response testResponse
+		json.NewDecoder(resp.Body).Decode(&response)
+		if resp.StatusCode != http.StatusNotFound || resp.Header.Get("Content-Type") != "application/json" || response.ErrorCode != ErrCodeNotFound {
+			t.Errorf("Expected a JSON 404, got %d %q %+v", resp.StatusCode, resp.Header.Get("Content-Type"), response)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestComplaintETag(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Polling User", "poll@example.com")
+	complaint := submitTestComplaint(t, srv, code, "Slow elevator", 5)
+
+	// view fetches the complaint through /viewComplaint, sending ifNoneMatch
+	// when set, and returns the status, ETag and body length
+	view := func(ifNoneMatch string) (int, string, int) {
+		t.Helper()
+		jsonData, _ := json.Marshal(ViewComplaintRequest{SecretCode: code, ComplaintID: complaint.ID})
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/viewComplaint", bytes.NewReader(jsonData))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST /viewComplaint failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("ETag"), len(body)
+	}
+
+	status, etag, _ := view("")
+	if status != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %d %q", status, etag)
+	}
+	if etag[:2] != "W/" {
+		t.Errorf("Expected a weak ETag, got %q", etag)
+	}
+
+	status, again, size := view(etag)
+	if status != http.StatusNotModified || size != 0 {
+		t.Errorf("Expected 304 with no body, got %d with %d bytes", status, size)
+	}
+	if again != etag {
+		t.Errorf("Expected the same ETag on 304, got %q", again)
+	}
+
+	if status, _, _ := view(`"other", ` + etag); status != http.StatusNotModified {
+		t.Errorf("Expected 304 when the ETag is one of several, got %d", status)
+	}
+
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+
+	status, resolved, size := view(etag)
+	if status != http.StatusOK || size == 0 {
+		t.Fatalf("Expected 200 with a body after resolving, got %d", status)
+	}
+	if resolved == etag {
+		t.Errorf("Expected the ETag to change after resolving, still %q", etag)
+	}
+
+	t.Run("V1", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/complaints/"+strconv.Itoa(complaint.ID), nil)
+		req.Header.Set("Authorization", "Bearer "+code)
+		req.Header.Set("If-None-Match", resolved)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /v1/complaints failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected 304, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Every Write Bumps The Version", func(t *testing.T) {
+		before := complaint.Version
+		steps := []struct {
+			endpoint string
+			payload  interface{}
+		}{
+			{"/setPriority", SetPriorityRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Priority: priorityHigh, Version: before + 1}},
+			{"/addAdminNote", AddAdminNoteRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Note: "Checked"}},
+			{"/deleteComplaint", DeleteComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID}},
+			{"/restoreComplaint", RestoreComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID}},
+		}
+		for _, step := range steps {
+			status, resp := postJSON(t, srv, step.endpoint, step.payload)
+			if status >= 300 {
+				t.Fatalf("%s: got %d (%s)", step.endpoint, status, resp.Error)
+			}
+		}
+
+		storage.mutex.RLock()
+		after := storage.complaints[complaint.ID].Version
+		storage.mutex.RUnlock()
+		// Resolve plus the four steps above
+		if after != before+5 {
+			t.Errorf("Expected version %d, got %d", before+5, after)
+		}
+	})
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`W/"1-2"`, true},
+		{`"1-2"`, true},
+		{`W/"1-3"`, false},
+		{`"a", W/"1-2"`, true},
+		{`*`, true},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `W/"1-2"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
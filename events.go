@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Complaint event types published on the event bus
+const (
+	eventComplaintCreated   = "complaint.created"
+	eventComplaintResolved  = "complaint.resolved"
+	eventComplaintEscalated = "complaint.escalated"
+	eventComplaintFeedback  = "complaint.feedback"
+	eventComplaintMerged    = "complaint.merged"
+	eventComplaintCommented = "complaint.commented"
+	eventComplaintAssigned  = "complaint.assigned"
+	eventSLABreached        = "sla.breached"
+)
+
+// eventBufferSize is how many undelivered events a subscriber may queue
+// before it is considered too slow and dropped
+const eventBufferSize = 64
+
+// Event is a change to a complaint, fanned out to every subscriber
+type Event struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Complaint Complaint `json:"complaint"`
+	CreatedAt string    `json:"created_at"`
+	// CorrelationID is that of the request that made the change; empty for
+	// changes the server makes on its own
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// eventData is the data of an event on the /events stream: the complaint,
+// with the correlation ID of the change alongside its fields
+type eventData struct {
+	Complaint
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+type subscription struct {
+	events chan Event
+}
+
+// EventBus broadcasts events to subscribers without ever blocking the
+// publisher: a subscriber whose buffer is full is disconnected
+type EventBus struct {
+	subscribers map[*subscription]struct{}
+	nextID      int64
+	mutex       sync.Mutex
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[*subscription]struct{})}
+}
+
+var eventBus = newEventBus()
+
+func (b *EventBus) subscribe() *subscription {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sub := &subscription{events: make(chan Event, eventBufferSize)}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes sub and closes its channel, unless it was already
+// dropped for being slow
+func (b *EventBus) unsubscribe(sub *subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, exists := b.subscribers[sub]; exists {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (b *EventBus) subscriberCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.subscribers)
+}
+
+// publish sends a snapshot of complaint to every subscriber and returns
+// the event
+func (b *EventBus) publish(eventType string, complaint Complaint, correlationID string) Event {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.nextID++
+	event := Event{
+		ID:        b.nextID,
+		Type:      eventType,
+		Complaint: complaint,
+		CreatedAt: getCurrentTime(),
+
+		CorrelationID: correlationID,
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+	return event
+}
+
+// canSeeEvent reports whether event concerns a complaint of an organization
+// viewer may see
+func canSeeEvent(viewer *User, event Event) bool {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	return canSeeOrg(viewer, event.Complaint.OrgID)
+}
+
+// /events - Server-Sent Events stream of complaint changes (admin only)
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// EventSource cannot send a body, so the secret comes from the query or a header
+	secretCode := r.URL.Query().Get("secret_code")
+	if secretCode == "" {
+		secretCode = r.Header.Get("X-Secret-Code")
+	}
+	if secretCode == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, secretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Streaming not supported")
+		return
+	}
+
+	// The stream is meant to outlive the server's WriteTimeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	sub := eventBus.subscribe()
+	defer eventBus.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(config.EventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, open := <-sub.events:
+			if !open {
+				// Dropped for falling behind; the client will reconnect
+				return
+			}
+			if !canSeeEvent(user, event) {
+				continue
+			}
+			data, err := json.Marshal(eventData{Complaint: event.Complaint, CorrelationID: event.CorrelationID})
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openEventStream connects to /events and returns a channel of received lines
+func openEventStream(t *testing.T, ctx context.Context, url string) (*http.Response, <-chan string) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to connect to event stream: %v", err)
+	}
+
+	lines := make(chan string, 100)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- strings.TrimRight(line, "\n")
+		}
+	}()
+	return resp, lines
+}
+
+// waitForLine reads lines until one starts with prefix
+func waitForLine(t *testing.T, lines <-chan string, prefix string) string {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("Stream closed before %q arrived", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %q", prefix)
+		}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	srv := newTestServer(t)
+	config.EventHeartbeat = 50 * time.Millisecond
+	_, code := registerTestUser(t, srv, "Streamed User", "stream@example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp, lines := openEventStream(t, ctx, srv.URL+"/events?secret_code="+adminSecret)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %q", resp.Header.Get("Content-Type"))
+	}
+	waitForLine(t, lines, ": connected")
+
+	complaint := submitTestComplaint(t, srv, code, "Broken elevator", 8)
+
+	if line := waitForLine(t, lines, "event:"); line != "event: "+eventComplaintCreated {
+		t.Errorf("Expected created event, got %q", line)
+	}
+	var received Complaint
+	data := waitForLine(t, lines, "data:")
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &received); err != nil {
+		t.Fatalf("Event data is not a complaint: %v", err)
+	}
+	if received.ID != complaint.ID || received.Title != "Broken elevator" {
+		t.Errorf("Unexpected complaint in event: %+v", received)
+	}
+
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+	if line := waitForLine(t, lines, "event:"); line != "event: "+eventComplaintResolved {
+		t.Errorf("Expected resolved event, got %q", line)
+	}
+
+	waitForLine(t, lines, ": heartbeat")
+
+	t.Run("Disconnect Cleans Up", func(t *testing.T) {
+		cancel()
+		deadline := time.Now().Add(2 * time.Second)
+		for eventBus.subscriberCount() != 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Subscriber still registered after disconnect")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestEventStreamRequiresAdmin(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Curious User", "curious@example.com")
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("X-Secret-Code", code)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestEventBusDropsSlowSubscribers(t *testing.T) {
+	bus := newEventBus()
+	slow := bus.subscribe()
+	fast := bus.subscribe()
+
+	for i := 0; i < eventBufferSize+1; i++ {
+		bus.publish(eventComplaintCreated, Complaint{ID: i}, "")
+		<-fast.events
+	}
+
+	if bus.subscriberCount() != 1 {
+		t.Errorf("Expected slow subscriber to be dropped, %d remain", bus.subscriberCount())
+	}
+	drained := 0
+	for range slow.events {
+		drained++
+	}
+	if drained != eventBufferSize {
+		t.Errorf("Expected %d buffered events before the drop, got %d", eventBufferSize, drained)
+	}
+
+	// Unsubscribing a dropped subscriber must not close its channel twice
+	bus.unsubscribe(slow)
+	bus.unsubscribe(fast)
+	if bus.subscriberCount() != 0 {
+		t.Errorf("Expected no subscribers, got %d", bus.subscriberCount())
+	}
+}
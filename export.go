@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// exportBatchSize is how many complaints the export copies per read lock and
+// writes between flushes, so memory stays flat and writers are never blocked
+// for long
+const exportBatchSize = 500
+
+// Page sizes of a cursor export, set with ?limit=
+const (
+	defaultExportLimit = 1000
+	maxExportLimit     = 10000
+)
+
+// ExportedComplaint is one line of an NDJSON export: the admin view of a
+// complaint with its timestamps in RFC 3339
+type ExportedComplaint struct {
+	Complaint
+	CreatedAt   string `json:"created_at"`
+	ResolvedAt  string `json:"resolved_at,omitempty"`
+	DeletedAt   string `json:"deleted_at,omitempty"`
+	ArchivedAt  string `json:"archived_at,omitempty"`
+	EscalatedAt string `json:"escalated_at,omitempty"`
+}
+
+// exportScope is which complaints outside the live set an export includes
+type exportScope struct {
+	deleted  bool
+	archived bool
+}
+
+func (s exportScope) includes(complaint *Complaint) bool {
+	return (s.deleted || !complaint.IsDeleted) && (s.archived || !isArchived(complaint))
+}
+
+// rfc3339 converts a stored timestamp to RFC 3339, keeping empty values empty
+func rfc3339(value string) string {
+	if value == "" {
+		return ""
+	}
+	parsed, err := parseTimestamp(value)
+	if err != nil {
+		return value
+	}
+	return parsed.Format(time.RFC3339)
+}
+
+func exportComplaint(complaint Complaint) ExportedComplaint {
+	return ExportedComplaint{
+		Complaint:   complaint,
+		CreatedAt:   rfc3339(complaint.CreatedAt),
+		ResolvedAt:  rfc3339(complaint.ResolvedAt),
+		DeletedAt:   rfc3339(complaint.DeletedAt),
+		ArchivedAt:  rfc3339(complaint.ArchivedAt),
+		EscalatedAt: rfc3339(complaint.EscalatedAt),
+	}
+}
+
+// exportIDs snapshots the IDs of the complaints in scope that viewer's
+// organization may see, in ID order
+func exportIDs(viewer *User, scope exportScope) []int {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	ids := make([]int, 0, len(storage.complaints))
+	for id, complaint := range storage.complaints {
+		if scope.includes(complaint) && canSeeOrg(viewer, complaint.OrgID) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// exportBatch copies the complaints in ids that still exist, shaped for
+// viewer. Complaints deleted, archived or purged since the snapshot are
+// skipped unless scope includes them.
+func exportBatch(viewer *User, ids []int, scope exportScope) []Complaint {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	batch := make([]Complaint, 0, len(ids))
+	for _, id := range ids {
+		complaint, exists := storage.complaints[id]
+		if exists && scope.includes(complaint) {
+			batch = append(batch, complaintForViewer(viewer, *complaint))
+		}
+	}
+	return batch
+}
+
+// parseExportScope reads ?include_deleted= and ?include_archived=, writing
+// the error response when either is not true or false
+func parseExportScope(w http.ResponseWriter, query url.Values) (exportScope, bool) {
+	var scope exportScope
+	for _, param := range []struct {
+		name    string
+		include *bool
+	}{{"include_deleted", &scope.deleted}, {"include_archived", &scope.archived}} {
+		switch query.Get(param.name) {
+		case "", "false":
+		case "true":
+			*param.include = true
+		default:
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, param.name+" must be true or false")
+			return scope, false
+		}
+	}
+	return scope, true
+}
+
+// authenticateExport finds the admin an export is for. Like /events, the
+// secret comes from the query or a header. On failure it has already written
+// the response.
+func authenticateExport(w http.ResponseWriter, r *http.Request) *User {
+	secretCode := r.URL.Query().Get("secret_code")
+	if secretCode == "" {
+		secretCode = secretFromRequest(r)
+	}
+	if secretCode == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return nil
+	}
+
+	user := authenticate(w, r, secretCode)
+	if user == nil {
+		return nil
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return nil
+	}
+	return user
+}
+
+// /exportComplaints - Stream every complaint as NDJSON (admin only)
+func exportComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "ndjson" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Format must be ndjson")
+		return
+	}
+	scope, ok := parseExportScope(w, query)
+	if !ok {
+		return
+	}
+
+	// With ?cursor= (empty for the first page) the export is cut into pages
+	// of ?limit= complaints, and X-Next-Cursor resumes after the last one
+	var after *listPosition
+	limit := 0
+	if query.Has("cursor") {
+		var err error
+		if after, err = decodeCursor(query.Get("cursor"), sortExport); err != nil {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+			return
+		}
+		limit = defaultExportLimit
+		if value := query.Get("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxExportLimit {
+				respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, fmt.Sprintf("limit must be between 1 and %d", maxExportLimit))
+				return
+			}
+		}
+	} else if query.Has("limit") {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "limit requires cursor")
+		return
+	}
+
+	user := authenticateExport(w, r)
+	if user == nil {
+		return
+	}
+
+	// A large export may take longer than the server's WriteTimeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	ids := exportIDs(user, scope)
+	if after != nil {
+		ids = ids[sort.SearchInts(ids, after.ID+1):]
+	}
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+		w.Header().Set("X-Next-Cursor", encodeCursor(listPosition{Sort: sortExport, ID: ids[limit-1]}))
+	}
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	exported := 0
+	for start := 0; start < len(ids); start += exportBatchSize {
+		if err := r.Context().Err(); err != nil {
+			requestLogger(r).Warn("Export aborted", "exported", exported, logKeyError, err)
+			return
+		}
+		end := start + exportBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		for _, complaint := range exportBatch(user, ids[start:end], scope) {
+			// The status is already sent, so a failed write can only end the stream
+			if err := encoder.Encode(exportComplaint(complaint)); err != nil {
+				requestLogger(r).Warn("Export aborted", "exported", exported, logKeyError, err)
+				return
+			}
+			exported++
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
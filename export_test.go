@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// exportLines fetches /exportComplaints with query and decodes every line
+func exportLines(t *testing.T, url string) []ExportedComplaint {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected NDJSON content type, got %q", ct)
+	}
+
+	var lines []ExportedComplaint
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line ExportedComplaint
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Line %d does not parse on its own: %v", len(lines)+1, err)
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	return lines
+}
+
+func TestExportComplaints(t *testing.T) {
+	srv := newTestServer(t)
+
+	const total = 10000
+	fixture := Fixture{Users: []FixtureUser{{ID: 1, Name: "Bulk User", Email: "bulk@example.com"}}}
+	for i := 0; i < total; i++ {
+		fixture.Complaints = append(fixture.Complaints, FixtureComplaint{
+			UserID: 1, Title: "Complaint " + strconv.Itoa(i), Summary: "Bulk", Rating: 1 + i%10, IsResolved: i%4 == 0,
+		})
+	}
+	if _, err := loadFixture(fixture); err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	postJSON(t, srv, "/deleteComplaint", DeleteComplaintRequest{SecretCode: adminSecret, ComplaintID: 2})
+
+	base := srv.URL + "/exportComplaints?format=ndjson&secret_code=" + adminSecret
+
+	lines := exportLines(t, base)
+	if len(lines) != total-1 {
+		t.Fatalf("Expected %d lines without deleted complaints, got %d", total-1, len(lines))
+	}
+	for i, line := range lines {
+		if i > 0 && line.ID <= lines[i-1].ID {
+			t.Fatalf("Expected lines in ID order, got %d after %d", line.ID, lines[i-1].ID)
+		}
+	}
+
+	first := lines[0]
+	if _, err := time.Parse(time.RFC3339, first.CreatedAt); err != nil {
+		t.Errorf("Expected an RFC 3339 created_at, got %q", first.CreatedAt)
+	}
+	if !first.IsResolved {
+		t.Fatal("Expected the first complaint to be resolved")
+	}
+	if _, err := time.Parse(time.RFC3339, first.ResolvedAt); err != nil {
+		t.Errorf("Expected an RFC 3339 resolved_at, got %q", first.ResolvedAt)
+	}
+	if first.User == nil || first.User.Email != "bulk@example.com" {
+		t.Errorf("Expected the admin view with the submitter, got %+v", first.User)
+	}
+
+	withDeleted := exportLines(t, base+"&include_deleted=true")
+	if len(withDeleted) != total {
+		t.Errorf("Expected %d lines with deleted complaints, got %d", total, len(withDeleted))
+	}
+	if !withDeleted[1].IsDeleted {
+		t.Error("Expected the deleted complaint to be flagged")
+	}
+	if _, err := time.Parse(time.RFC3339, withDeleted[1].DeletedAt); err != nil {
+		t.Errorf("Expected an RFC 3339 deleted_at, got %q", withDeleted[1].DeletedAt)
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		_, code := registerTestUser(t, srv, "Regular User", "regular@example.com")
+		for _, tc := range []struct {
+			query  string
+			status int
+		}{
+			{"?format=ndjson", http.StatusBadRequest},
+			{"?format=csv&secret_code=" + adminSecret, http.StatusBadRequest},
+			{"?include_deleted=yes&secret_code=" + adminSecret, http.StatusBadRequest},
+			{"?secret_code=" + code, http.StatusForbidden},
+		} {
+			resp, err := http.Get(srv.URL + "/exportComplaints" + tc.query)
+			if err != nil {
+				t.Fatalf("GET failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Errorf("%s: expected %d, got %d", tc.query, tc.status, resp.StatusCode)
+			}
+		}
+	})
+}
+
+func TestExportCursor(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Export User", "export@example.com")
+	for i := 0; i < 7; i++ {
+		submitTestComplaint(t, srv, code, "Complaint "+strconv.Itoa(i), 5)
+	}
+
+	base := srv.URL + "/exportComplaints?format=ndjson&limit=3&secret_code=" + adminSecret
+	var ids []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		resp, err := http.Get(base + "&cursor=" + cursor)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var line ExportedComplaint
+			json.Unmarshal(scanner.Bytes(), &line)
+			ids = append(ids, line.ID)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+		}
+		cursor = resp.Header.Get("X-Next-Cursor")
+		if cursor == "" {
+			break
+		}
+		if pages == 0 {
+			submitTestComplaint(t, srv, code, "Added mid-export", 5)
+		}
+	}
+
+	if len(ids) != 8 {
+		t.Fatalf("Expected all 8 complaints, got %v", ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("Expected increasing IDs with no repeats, got %v", ids)
+		}
+	}
+
+	for _, query := range []string{"&cursor=bogus", "&limit=0&cursor=", "&limit=5"} {
+		resp, err := http.Get(srv.URL + "/exportComplaints?secret_code=" + adminSecret + query)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sheets of /exportReport
+const (
+	reportComplaintsSheet = "Complaints"
+	reportSummarySheet    = "Summary"
+)
+
+// Breakdowns of the summary sheet, in the order they are written
+const (
+	breakdownDepartment = "department"
+	breakdownSource     = "source"
+	breakdownStatus     = "status"
+	breakdownMonth      = "month"
+)
+
+// reportNoDepartment groups complaints no routing rule sent anywhere
+const reportNoDepartment = "(none)"
+
+// reportStatuses is the order of the status breakdown
+var reportStatuses = []string{statusOpen, statusPendingConfirmation, statusResolved, statusMerged}
+
+var reportComplaintColumns = []interface{}{
+	"ID", "Reference", "Title", "Status", "Priority", "Department", "Source",
+	"Rating", "Tags", "User ID", "Assigned To", "Created At", "Resolved At",
+	"Resolved By",
+}
+
+var reportSummaryColumns = []interface{}{"Breakdown", "Group", "Complaints", "Open", "Resolved"}
+
+// reportGroup counts the complaints of one row of the summary sheet
+type reportGroup struct {
+	total    int
+	open     int
+	resolved int
+}
+
+func (g *reportGroup) add(complaint Complaint) {
+	g.total++
+	if complaint.IsResolved {
+		g.resolved++
+	} else if complaint.MergedInto == 0 {
+		g.open++
+	}
+}
+
+// reportSummary accumulates the summary sheet while the complaints sheet is
+// written; it grows with the departments and months seen, not the
+// complaints
+type reportSummary map[string]map[string]*reportGroup
+
+func (s reportSummary) add(complaint Complaint) {
+	department := complaint.Department
+	if department == "" {
+		department = reportNoDepartment
+	}
+	month := "unknown"
+	if created, err := parseTimestamp(complaint.CreatedAt); err == nil {
+		month = created.UTC().Format("2006-01")
+	}
+	for breakdown, group := range map[string]string{
+		breakdownDepartment: department,
+		breakdownSource:     complaint.Source,
+		breakdownStatus:     complaint.Status,
+		breakdownMonth:      month,
+	} {
+		if s[breakdown] == nil {
+			s[breakdown] = make(map[string]*reportGroup)
+		}
+		if s[breakdown][group] == nil {
+			s[breakdown][group] = &reportGroup{}
+		}
+		s[breakdown][group].add(complaint)
+	}
+}
+
+// write adds the summary rows to the current sheet: departments and
+// sources by name, statuses in their usual order and months oldest first
+func (s reportSummary) write(x *xlsxWriter) error {
+	for _, breakdown := range []string{breakdownDepartment, breakdownSource, breakdownStatus, breakdownMonth} {
+		var groups []string
+		if breakdown == breakdownStatus {
+			for _, status := range reportStatuses {
+				if s[breakdown][status] != nil {
+					groups = append(groups, status)
+				}
+			}
+		} else {
+			for group := range s[breakdown] {
+				groups = append(groups, group)
+			}
+			sort.Strings(groups)
+		}
+		for _, group := range groups {
+			counts := s[breakdown][group]
+			if err := x.writeRow(breakdown, group, counts.total, counts.open, counts.resolved); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func reportComplaintRow(complaint Complaint) []interface{} {
+	return []interface{}{
+		complaint.ID, complaint.Reference, complaint.Title, complaint.Status,
+		complaint.Priority, complaint.Department, complaint.Source, complaint.Rating,
+		strings.Join(complaint.Tags, ", "), complaint.UserID, reportAdmin(complaint.AssignedTo, complaint.AssignedToName),
+		rfc3339(complaint.CreatedAt), rfc3339(complaint.ResolvedAt), reportAdmin(complaint.ResolvedBy, complaint.ResolvedByName),
+	}
+}
+
+// reportAdmin names an admin in a report cell, falling back to their ID
+// when no name was recorded, and leaves the cell empty for none
+func reportAdmin(id int, name string) string {
+	switch {
+	case id == 0:
+		return ""
+	case name == "":
+		return fmt.Sprint(id)
+	}
+	return name
+}
+
+// /exportReport - Download complaints as an Excel workbook: one sheet of
+// complaints and one of counts per department, status and month (admin only)
+func exportReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "xlsx" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Format must be xlsx")
+		return
+	}
+	scope, ok := parseExportScope(w, query)
+	if !ok {
+		return
+	}
+
+	user := authenticateExport(w, r)
+	if user == nil {
+		return
+	}
+
+	// A large report may take longer than the server's WriteTimeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	ids := exportIDs(user, scope)
+	filename := "complaints-report-" + clock.Now().UTC().Format("2006-01-02") + ".xlsx"
+	w.Header().Set("Content-Type", xlsxContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	// The status is already sent, so a failed write can only end the download
+	exported := 0
+	abort := func(err error) {
+		requestLogger(r).Warn("Report aborted", "exported", exported, logKeyError, err)
+	}
+	flusher, _ := w.(http.Flusher)
+	report := newXLSXWriter(w)
+	summary := make(reportSummary)
+
+	if err := report.startSheet(reportComplaintsSheet); err != nil {
+		abort(err)
+		return
+	}
+	if err := report.writeRow(reportComplaintColumns...); err != nil {
+		abort(err)
+		return
+	}
+	for start := 0; start < len(ids); start += exportBatchSize {
+		if err := r.Context().Err(); err != nil {
+			abort(err)
+			return
+		}
+		end := start + exportBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		for _, complaint := range exportBatch(user, ids[start:end], scope) {
+			if err := report.writeRow(reportComplaintRow(complaint)...); err != nil {
+				abort(err)
+				return
+			}
+			summary.add(complaint)
+			exported++
+		}
+		if err := report.flush(); err != nil {
+			abort(err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if err := report.startSheet(reportSummarySheet); err != nil {
+		abort(err)
+		return
+	}
+	if err := report.writeRow(reportSummaryColumns...); err != nil {
+		abort(err)
+		return
+	}
+	if err := summary.write(report); err != nil {
+		abort(err)
+		return
+	}
+	if err := report.close(); err != nil {
+		abort(err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// xlsxSheet is the part of a worksheet the tests read
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readReport fetches /exportReport with query and returns each sheet's rows
+// by name, every cell as text
+func readReport(t *testing.T, url string) map[string][][]string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != xlsxContentType {
+		t.Errorf("Expected the xlsx content type, got %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Report is not a zip: %v", err)
+	}
+	parse := func(name string, into interface{}) {
+		t.Helper()
+		file, err := archive.Open(name)
+		if err != nil {
+			t.Fatalf("Report has no %s: %v", name, err)
+		}
+		defer file.Close()
+		if err := xml.NewDecoder(file).Decode(into); err != nil {
+			t.Fatalf("Failed to parse %s: %v", name, err)
+		}
+	}
+	parse("[Content_Types].xml", &struct{}{})
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	parse("xl/workbook.xml", &workbook)
+	sheets := make(map[string][][]string)
+	for i, sheet := range workbook.Sheets {
+		var data xlsxSheet
+		parse(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), &data)
+		var rows [][]string
+		for _, row := range data.Rows {
+			var cells []string
+			for _, cell := range row.Cells {
+				// Empty cells are left out; pad up to this one
+				column := strings.TrimRight(cell.Ref, "0123456789")
+				for len(cells) < 100 && xlsxColumn(len(cells)) != column {
+					cells = append(cells, "")
+				}
+				cells = append(cells, cell.Value+cell.Inline)
+			}
+			rows = append(rows, cells)
+		}
+		sheets[sheet.Name] = rows
+	}
+	return sheets
+}
+
+func TestExportReport(t *testing.T) {
+	srv := newTestServer(t)
+
+	// More complaints than one batch, over two months and two departments
+	const total = 1200
+	fixture := Fixture{Users: []FixtureUser{{ID: 1, Name: "Bulk User", Email: "bulk@example.com"}}}
+	for i := 0; i < total; i++ {
+		created := "2024-06-15 10:00:00"
+		if i%3 == 0 {
+			created = "2024-05-15 10:00:00"
+		}
+		fixture.Complaints = append(fixture.Complaints, FixtureComplaint{
+			UserID: 1, Title: "Complaint " + strconv.Itoa(i), Summary: "Bulk", Rating: 5, CreatedAt: created, IsResolved: i%4 == 0,
+		})
+	}
+	if _, err := loadFixture(fixture); err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	storage.mutex.Lock()
+	for id, complaint := range storage.complaints {
+		if id%5 == 0 {
+			complaint.Department = "facilities"
+		}
+	}
+	storage.mutex.Unlock()
+	postJSON(t, srv, "/deleteComplaint", DeleteComplaintRequest{SecretCode: adminSecret, ComplaintID: 2})
+
+	sheets := readReport(t, srv.URL+"/exportReport?format=xlsx&secret_code="+adminSecret)
+
+	complaints := sheets[reportComplaintsSheet]
+	if len(complaints) != total {
+		t.Fatalf("Expected a header and %d complaints without the deleted one, got %d rows", total-1, len(complaints))
+	}
+	if complaints[0][0] != "ID" || complaints[1][0] != "1" || complaints[1][2] != "Complaint 0" || complaints[1][3] != statusResolved {
+		t.Errorf("Unexpected first rows %v, %v", complaints[0], complaints[1])
+	}
+	if complaints[2][0] != "3" {
+		t.Errorf("Expected the deleted complaint left out, got ID %s", complaints[2][0])
+	}
+
+	// IDs run from 1, so complaint i has ID i+1
+	var wantMay, wantFacilities, wantFacilitiesResolved int
+	for i := 0; i < total; i++ {
+		if i == 1 {
+			continue
+		}
+		if i%3 == 0 {
+			wantMay++
+		}
+		if (i+1)%5 == 0 {
+			wantFacilities++
+			if i%4 == 0 {
+				wantFacilitiesResolved++
+			}
+		}
+	}
+	summary := make(map[string][]string)
+	for _, row := range sheets[reportSummarySheet][1:] {
+		summary[row[0]+"/"+row[1]] = row[2:]
+	}
+	for key, want := range map[string][]string{
+		breakdownMonth + "/2024-05":                   {strconv.Itoa(wantMay)},
+		breakdownMonth + "/2024-06":                   {strconv.Itoa(total - 1 - wantMay)},
+		breakdownDepartment + "/facilities":           {strconv.Itoa(wantFacilities), strconv.Itoa(wantFacilities - wantFacilitiesResolved), strconv.Itoa(wantFacilitiesResolved)},
+		breakdownDepartment + "/" + generalDepartment: {strconv.Itoa(total - 1 - wantFacilities)},
+		breakdownStatus + "/" + statusResolved:        {strconv.Itoa(total / 4), "0", strconv.Itoa(total / 4)},
+	} {
+		got := summary[key]
+		if len(got) < len(want) || fmt.Sprint(got[:len(want)]) != fmt.Sprint(want) {
+			t.Errorf("%s: expected %v, got %v", key, want, got)
+		}
+	}
+
+	if with := readReport(t, srv.URL+"/exportReport?include_deleted=true&secret_code="+adminSecret); len(with[reportComplaintsSheet]) != total+1 {
+		t.Errorf("Expected %d complaints with the deleted one, got %d rows", total, len(with[reportComplaintsSheet])-1)
+	}
+
+	t.Run("Not Gzipped", func(t *testing.T) {
+		// Setting Accept-Encoding ourselves stops the transport from
+		// decompressing, so the body is what the server sent
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/exportReport?secret_code="+adminSecret, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+			t.Errorf("Expected the workbook, a zip already, not to be compressed again, got Content-Encoding %q", encoding)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if _, err := zip.NewReader(bytes.NewReader(body), int64(len(body))); err != nil {
+			t.Errorf("Expected the body to unzip as it is, got %v", err)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, code := registerTestUser(t, srv, "Regular User", "regular@example.com")
+		for _, tc := range []struct {
+			query  string
+			status int
+		}{
+			{"?format=xlsx", http.StatusBadRequest},
+			{"?format=ods&secret_code=" + adminSecret, http.StatusBadRequest},
+			{"?include_archived=1&secret_code=" + adminSecret, http.StatusBadRequest},
+			{"?secret_code=" + code, http.StatusForbidden},
+		} {
+			resp, err := http.Get(srv.URL + "/exportReport" + tc.query)
+			if err != nil {
+				t.Fatalf("GET failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Errorf("%s: expected %d, got %d", tc.query, tc.status, resp.StatusCode)
+			}
+		}
+	})
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(index); got != want {
+			t.Errorf("Column %d: expected %s, got %s", index, want, got)
+		}
+	}
+}
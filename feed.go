@@ -0,0 +1,306 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	feedTokenPrefix      = "cpf_"
+	feedTokenShownLength = 12 // characters of a token kept for display, prefix included
+	feedSize             = 50 // complaints in the feed, newest first
+	feedSummaryLength    = 500
+	atomNamespace        = "http://www.w3.org/2005/Atom"
+)
+
+// FeedToken lets an admin's feed reader fetch /feed.atom. Feed readers
+// cannot send headers or bodies, so the token goes in the URL; it is good for
+// the feed only. An admin has at most one, and only its hash is kept.
+type FeedToken struct {
+	UserID    int    `json:"user_id"`
+	Prefix    string `json:"prefix"` // start of the token, to tell tokens apart
+	CreatedAt string `json:"created_at"`
+
+	hash string
+}
+
+// CreatedFeedToken is the /createFeedToken payload, the only time the full
+// token is returned
+type CreatedFeedToken struct {
+	FeedToken
+	Token   string `json:"token"`
+	FeedURL string `json:"feed_url"`
+}
+
+type CreateFeedTokenRequest struct {
+	SecretCode string `json:"secret_code"`
+	// Revoke removes the caller's token without issuing a new one
+	Revoke bool `json:"revoke,omitempty"`
+}
+
+// Atom documents, as described by RFC 4287
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Summary   string     `xml:"summary"`
+	Author    atomAuthor `xml:"author"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Link      atomLink   `xml:"link"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+func generateFeedToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return feedTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+// baseURL is the server's address as clients see it, for absolute links:
+// -public-url, or else the scheme and host of r
+func baseURL(r *http.Request) string {
+	if config.PublicURL != "" {
+		return strings.TrimRight(config.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// feedUser is the user whose feed token credential is; the handler checks
+// they are still an active admin. Callers must hold storage.mutex.
+func feedUser(credential string) *User {
+	hash := hashAPIToken(credential)
+	for _, token := range storage.feedTokens {
+		if subtle.ConstantTimeCompare([]byte(token.hash), []byte(hash)) == 1 {
+			return storage.users[token.UserID]
+		}
+	}
+	return nil
+}
+
+// truncateRunes shortens text to at most max characters, ending it with an
+// ellipsis when anything was cut
+func truncateRunes(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max-1]) + "…"
+}
+
+// atomTime formats a CreatedAt value as an RFC 3339 timestamp
+func atomTime(timestamp string) string {
+	created, err := parseTimestamp(timestamp)
+	if err != nil {
+		return time.Unix(0, 0).UTC().Format(time.RFC3339)
+	}
+	return created.UTC().Format(time.RFC3339)
+}
+
+// buildFeed is the Atom feed of the newest complaints viewer may see. Entries
+// are keyed by complaint reference and dated by creation, so a complaint
+// keeps its ID and timestamps however often the feed is fetched. Callers
+// must hold storage.mutex.
+func buildFeed(viewer *User, base string) atomFeed {
+	complaints := []*Complaint{}
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted || complaint.ArchivedAt != "" || !canSeeOrg(viewer, complaint.OrgID) {
+			continue
+		}
+		complaints = append(complaints, complaint)
+	}
+	created := make(map[int]time.Time, len(complaints))
+	for _, complaint := range complaints {
+		created[complaint.ID], _ = parseTimestamp(complaint.CreatedAt)
+	}
+	sort.Slice(complaints, func(i, j int) bool {
+		a, b := complaints[i], complaints[j]
+		if !created[a.ID].Equal(created[b.ID]) {
+			return created[a.ID].After(created[b.ID])
+		}
+		return a.ID > b.ID
+	})
+	if len(complaints) > feedSize {
+		complaints = complaints[:feedSize]
+	}
+
+	feed := atomFeed{
+		Xmlns:   atomNamespace,
+		ID:      "urn:complaint-portal:feed:" + strconv.Itoa(viewer.ID),
+		Title:   "New complaints",
+		Updated: clock.Now().UTC().Format(time.RFC3339),
+		Link:    atomLink{Rel: "self", Href: base + "/feed.atom"},
+		Author:  atomAuthor{Name: "Complaint Portal"},
+		Entries: []atomEntry{},
+	}
+	if len(complaints) > 0 {
+		// The newest entry, so an unchanged feed keeps its timestamp
+		feed.Updated = atomTime(complaints[0].CreatedAt)
+	}
+	for _, complaint := range complaints {
+		timestamp := atomTime(complaint.CreatedAt)
+		author := complaint.UserName
+		if author == "" {
+			author = "Unknown"
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        "urn:complaint-portal:complaint:" + complaint.Reference,
+			Title:     complaint.Title,
+			Summary:   truncateRunes(complaint.Summary, feedSummaryLength),
+			Author:    atomAuthor{Name: author},
+			Published: timestamp,
+			Updated:   timestamp,
+			Link:      atomLink{Rel: "alternate", Href: base + "/v1/complaints/" + complaint.Reference},
+		})
+	}
+	return feed
+}
+
+// /createFeedToken - Issue a token for the caller's Atom feed, replacing
+// any previous one, or revoke it (admin only)
+func createFeedTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req CreateFeedTokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+	if isAPIToken(req.SecretCode) {
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, "API tokens cannot manage feed tokens")
+		return
+	}
+
+	if req.Revoke {
+		storage.mutex.Lock()
+		delete(storage.feedTokens, user.ID)
+		storage.mutex.Unlock()
+
+		respondWithJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Message: "Feed token revoked",
+		})
+		return
+	}
+
+	secret, err := generateFeedToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate feed token")
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	token := &FeedToken{
+		UserID:    user.ID,
+		Prefix:    secret[:feedTokenShownLength],
+		CreatedAt: getCurrentTime(),
+		hash:      hashAPIToken(secret),
+	}
+	storage.feedTokens[user.ID] = token
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Feed token created. Store it now; it will not be shown again",
+		Data: CreatedFeedToken{
+			FeedToken: *token,
+			Token:     secret,
+			FeedURL:   baseURL(r) + "/feed.atom?token=" + secret,
+		},
+	})
+}
+
+// /feed.atom - Atom feed of the newest complaints, for feed readers (admin
+// only, with a feed token)
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	credential := r.URL.Query().Get("token")
+	if credential == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Feed token is required")
+		return
+	}
+
+	storage.mutex.RLock()
+	user := feedUser(credential)
+	if user == nil {
+		storage.mutex.RUnlock()
+		respondWithError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid feed token")
+		return
+	}
+	if !authorize(w, adminOnly(actorOfLocked(user))) {
+		storage.mutex.RUnlock()
+		return
+	}
+	if !acceptedTerms(user) {
+		storage.mutex.RUnlock()
+		requireCurrentTerms(w, user)
+		return
+	}
+	feed := buildFeed(user, baseURL(r))
+	storage.mutex.RUnlock()
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to build feed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+	w.Write([]byte("\n"))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// fetchFeed gets /feed.atom with token and returns the status and body
+func fetchFeed(t *testing.T, feedURL string) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := http.Get(feedURL)
+	if err != nil {
+		t.Fatalf("GET /feed.atom failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading the feed failed: %v", err)
+	}
+	return resp, body
+}
+
+func TestAtomFeed(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC))
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Fed User", "fed@example.com")
+
+	var first Complaint
+	for i := 0; i < feedSize+1; i++ {
+		complaint := submitTestComplaint(t, srv, code, "Complaint", 5)
+		if i == 0 {
+			first = complaint
+		}
+		fake.Advance(time.Minute)
+	}
+	status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+		SecretCode: code, Title: "Long <story>", Summary: strings.Repeat("é", 800), Rating: 5,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+	}
+	var newest Complaint
+	resp.decode(t, &newest)
+
+	if status, _ := postJSON(t, srv, "/createFeedToken", CreateFeedTokenRequest{SecretCode: code}); status != http.StatusForbidden {
+		t.Errorf("Expected 403 for a regular user, got %d", status)
+	}
+	status, resp = postJSON(t, srv, "/createFeedToken", CreateFeedTokenRequest{SecretCode: adminSecret})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+	}
+	var token CreatedFeedToken
+	resp.decode(t, &token)
+	if !strings.HasPrefix(token.Token, feedTokenPrefix) || !strings.HasPrefix(token.FeedURL, srv.URL+"/feed.atom?token=") {
+		t.Fatalf("Expected a token and its feed URL, got %+v", token)
+	}
+
+	type entry struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Summary   string `xml:"summary"`
+		Author    string `xml:"author>name"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+		Link      struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+	}
+	type feed struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Title   string   `xml:"title"`
+		Updated string   `xml:"updated"`
+		Entries []entry  `xml:"entry"`
+	}
+	read := func() feed {
+		t.Helper()
+		httpResp, body := fetchFeed(t, token.FeedURL)
+		if httpResp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", httpResp.StatusCode, body)
+		}
+		if contentType := httpResp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/atom+xml") {
+			t.Errorf("Expected an Atom content type, got %q", contentType)
+		}
+		var parsed feed
+		if err := xml.Unmarshal(body, &parsed); err != nil {
+			t.Fatalf("Expected an Atom document, got %v:\n%s", err, body)
+		}
+		return parsed
+	}
+
+	t.Run("Structure", func(t *testing.T) {
+		parsed := read()
+		if parsed.ID == "" || parsed.Title == "" {
+			t.Errorf("Expected a feed ID and title, got %+v", parsed)
+		}
+		if len(parsed.Entries) != feedSize {
+			t.Fatalf("Expected %d entries, got %d", feedSize, len(parsed.Entries))
+		}
+
+		top := parsed.Entries[0]
+		if top.Title != newest.Title || top.Author != "Fed User" {
+			t.Errorf("Expected the newest complaint first, got %+v", top)
+		}
+		if top.ID != "urn:complaint-portal:complaint:"+newest.Reference || top.Link.Href != srv.URL+"/v1/complaints/"+newest.Reference {
+			t.Errorf("Expected the ID and link to use the reference, got %q and %q", top.ID, top.Link.Href)
+		}
+		if n := utf8.RuneCountInString(top.Summary); n != feedSummaryLength || !strings.HasSuffix(top.Summary, "…") {
+			t.Errorf("Expected the summary cut to %d characters, got %d", feedSummaryLength, n)
+		}
+		if top.Updated != "2024-06-03T10:51:00Z" || top.Published != top.Updated || parsed.Updated != top.Updated {
+			t.Errorf("Expected the entry and feed dated by creation, got %q %q %q", top.Published, top.Updated, parsed.Updated)
+		}
+		for _, e := range parsed.Entries {
+			if e.ID == "urn:complaint-portal:complaint:"+first.Reference {
+				t.Errorf("Expected the oldest complaint to drop out of the feed")
+			}
+		}
+	})
+
+	t.Run("Stable Entries", func(t *testing.T) {
+		before := read()
+		fake.Advance(time.Hour)
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: newest.ID})
+		after := read()
+		if before.Updated != after.Updated || before.Entries[0] != after.Entries[0] {
+			t.Errorf("Expected unchanged entries to keep their ID and timestamps, got %+v then %+v", before.Entries[0], after.Entries[0])
+		}
+	})
+
+	t.Run("Token Auth", func(t *testing.T) {
+		if httpResp, _ := fetchFeed(t, srv.URL+"/feed.atom"); httpResp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 without a token, got %d", httpResp.StatusCode)
+		}
+		if httpResp, _ := fetchFeed(t, srv.URL+"/feed.atom?token="+adminSecret); httpResp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for a secret code in place of a feed token, got %d", httpResp.StatusCode)
+		}
+
+		status, resp := postJSON(t, srv, "/createFeedToken", CreateFeedTokenRequest{SecretCode: adminSecret})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var replacement CreatedFeedToken
+		resp.decode(t, &replacement)
+		if httpResp, _ := fetchFeed(t, token.FeedURL); httpResp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for a replaced token, got %d", httpResp.StatusCode)
+		}
+		if httpResp, _ := fetchFeed(t, srv.URL+"/feed.atom?token="+url.QueryEscape(replacement.Token)); httpResp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200 for the new token, got %d", httpResp.StatusCode)
+		}
+
+		if status, _ := postJSON(t, srv, "/createFeedToken", CreateFeedTokenRequest{SecretCode: adminSecret, Revoke: true}); status != http.StatusOK {
+			t.Fatalf("Expected 200 for a revoke, got %d", status)
+		}
+		if httpResp, _ := fetchFeed(t, replacement.FeedURL); httpResp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for a revoked token, got %d", httpResp.StatusCode)
+		}
+	})
+
+	t.Run("Demoted Admin", func(t *testing.T) {
+		demotedID, demotedCode := registerTestAdmin(t, srv, "Demoted Admin", "demoted@example.com")
+		status, resp := postJSON(t, srv, "/createFeedToken", CreateFeedTokenRequest{SecretCode: demotedCode})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var demoted CreatedFeedToken
+		resp.decode(t, &demoted)
+		storage.mutex.Lock()
+		storage.users[demotedID].IsAdmin = false
+		storage.mutex.Unlock()
+		if httpResp, _ := fetchFeed(t, demoted.FeedURL); httpResp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 once the token's admin is demoted, got %d", httpResp.StatusCode)
+		}
+	})
+}
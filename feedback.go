@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+)
+
+// maxFeedbackCommentLength caps a feedback comment, in characters
+const maxFeedbackCommentLength = 1000
+
+// Feedback is the submitter's verdict on how their complaint was resolved.
+// It is sent to admins and to the submitter; see complaintForViewer.
+type Feedback struct {
+	Score       int    `json:"score" xml:"score"` // 1 (very unhappy) to 5 (very happy)
+	Comment     string `json:"comment,omitempty" xml:"comment,omitempty"`
+	SubmittedAt string `json:"submitted_at" xml:"submitted_at"`
+}
+
+type SubmitFeedbackRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+	Score       int    `json:"score"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// /submitFeedback - Rate the resolution of your own complaint, once
+func submitFeedbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req SubmitFeedbackRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	comment := sanitizeText(req.Comment, true)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("complaint_id", req.ComplaintID)
+	v.between("score", req.Score, 1, 5)
+	v.maxRunes("comment", comment, maxFeedbackCommentLength)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint, apiErr := accessibleComplaint(user, req.ComplaintID, false, "Access denied. Only the submitter can give feedback")
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	if !authorize(w, canAnswerForComplaint(actorOf(user), complaint).because("Access denied. Only the submitter can give feedback")) {
+		return
+	}
+
+	// Eligibility follows IsResolved, so a complaint that is reopened cannot
+	// take feedback until it is resolved again
+	if !complaint.IsResolved {
+		respondWithError(w, http.StatusConflict, ErrCodeNotResolved, "Feedback can only be given once the complaint is resolved")
+		return
+	}
+	if complaint.Feedback != nil {
+		respondWithError(w, http.StatusConflict, ErrCodeFeedbackExists, "Feedback has already been given for this complaint")
+		return
+	}
+
+	complaint.Feedback = &Feedback{
+		Score:       req.Score,
+		Comment:     comment,
+		SubmittedAt: getCurrentTime(),
+	}
+	touchComplaint(complaint)
+	publishEvent(eventComplaintFeedback, complaint, correlationID(r))
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Feedback submitted successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
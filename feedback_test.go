@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSubmitFeedback(t *testing.T) {
+	srv := newTestServer(t)
+	_, ownerCode := registerTestUser(t, srv, "Happy User", "happy@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+	complaint := submitTestComplaint(t, srv, ownerCode, "Cold showers", 6)
+
+	feedback := func(code string, score int) (int, testResponse) {
+		return postJSON(t, srv, "/submitFeedback", SubmitFeedbackRequest{
+			SecretCode: code, ComplaintID: complaint.ID, Score: score, Comment: "Hot water is back",
+		})
+	}
+
+	t.Run("Before Resolution", func(t *testing.T) {
+		status, resp := feedback(ownerCode, 5)
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeNotResolved {
+			t.Errorf("Expected 409 NOT_RESOLVED, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, score := range []int{0, 6} {
+			if status, _ := feedback(ownerCode, score); status != http.StatusBadRequest {
+				t.Errorf("Score %d: expected 400, got %d", score, status)
+			}
+		}
+	})
+
+	t.Run("Non Owner", func(t *testing.T) {
+		status, _ := feedback(otherCode, 4)
+		if status != http.StatusNotFound {
+			t.Errorf("Expected 404 for another user, got %d", status)
+		}
+		status, _ = feedback(adminSecret, 4)
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 for an admin, got %d", status)
+		}
+	})
+
+	t.Run("Happy Path", func(t *testing.T) {
+		status, resp := feedback(ownerCode, 4)
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var got Complaint
+		resp.decode(t, &got)
+		if got.Feedback == nil || got.Feedback.Score != 4 || got.Feedback.Comment != "Hot water is back" {
+			t.Errorf("Expected the feedback on the owner's copy, got %+v", got.Feedback)
+		}
+
+		_, resp = postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		resp.decode(t, &got)
+		if got.Feedback == nil || got.Feedback.Score != 4 {
+			t.Errorf("Expected admins to see the feedback, got %+v", got.Feedback)
+		}
+	})
+
+	t.Run("Only Once", func(t *testing.T) {
+		status, resp := feedback(ownerCode, 1)
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeFeedbackExists {
+			t.Errorf("Expected 409 FEEDBACK_EXISTS, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		other := submitTestComplaint(t, srv, otherCode, "Broken chair", 3)
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: other.ID})
+		postJSON(t, srv, "/submitFeedback", SubmitFeedbackRequest{SecretCode: otherCode, ComplaintID: other.ID, Score: 1})
+
+		_, resp := postJSON(t, srv, "/report", ReportRequest{SecretCode: adminSecret})
+		var report Report
+		resp.decode(t, &report)
+		if report.FeedbackCount != 2 || report.AverageSatisfaction != 2.5 {
+			t.Errorf("Expected 2 feedback averaging 2.5, got %d averaging %v", report.FeedbackCount, report.AverageSatisfaction)
+		}
+	})
+}
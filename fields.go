@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// complaintFields are the JSON names of Complaint's top-level fields, the
+// names a listing's fields parameter may select
+var complaintFields = jsonFieldNames(reflect.TypeOf(Complaint{}))
+
+// jsonFieldNames is the set of names encoding/json gives the fields of
+// struct type t
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// parseFields validates a comma-separated fields parameter. id is always
+// selected; an empty parameter selects everything and returns nil.
+func parseFields(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	fields := []string{"id"}
+	var unknown []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "" || name == "id":
+		case name == "summary":
+			// So a summary cut short is never mistaken for the whole text
+			fields = append(fields, name, "summary_truncated")
+		case complaintFields[name]:
+			fields = append(fields, name)
+		default:
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		valid := make([]string, 0, len(complaintFields))
+		for name := range complaintFields {
+			valid = append(valid, name)
+		}
+		sort.Strings(valid)
+		return nil, fmt.Errorf("Unknown fields: %s. Valid fields are: %s", strings.Join(unknown, ", "), strings.Join(valid, ", "))
+	}
+	return fields, nil
+}
+
+// projectComplaints keeps only fields of each complaint. It works on the
+// encoded complaints, so whatever complaintForViewer withheld from the
+// viewer stays withheld, and fields left out of the full encoding as empty
+// are left out here too.
+func projectComplaints(list []Complaint, fields []string) []map[string]json.RawMessage {
+	projected := make([]map[string]json.RawMessage, 0, len(list))
+	for _, complaint := range list {
+		data, _ := json.Marshal(complaint)
+		var full map[string]json.RawMessage
+		json.Unmarshal(data, &full)
+
+		sparse := make(map[string]json.RawMessage, len(fields))
+		for _, name := range fields {
+			if value, exists := full[name]; exists {
+				sparse[name] = value
+			}
+		}
+		projected = append(projected, sparse)
+	}
+	return projected
+}
+
+// projectListing applies fields to a listComplaints result: a plain list, a
+// ComplaintPage or a CursorPage. The envelope keeps its shape.
+func projectListing(result interface{}, fields []string) interface{} {
+	var complaints []Complaint
+	switch listing := result.(type) {
+	case []Complaint:
+		return projectComplaints(listing, fields)
+	case ComplaintPage:
+		complaints = listing.Complaints
+	case CursorPage:
+		complaints = listing.Complaints
+	default:
+		return result
+	}
+
+	data, _ := json.Marshal(result)
+	var envelope map[string]json.RawMessage
+	json.Unmarshal(data, &envelope)
+	envelope["complaints"], _ = json.Marshal(projectComplaints(complaints, fields))
+	return envelope
+}
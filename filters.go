@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Limits on saved filters; lengths are in runes
+const (
+	maxSavedFilters     = 50 // per admin
+	maxFilterNameLength = 50
+)
+
+// ListFilter is the filtering and sorting part of a listing request, as
+// stored by /saveFilter
+type ListFilter struct {
+	Status      string   `json:"status,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+	Department  string   `json:"department,omitempty"`
+	Building    string   `json:"building,omitempty"`
+	Floor       string   `json:"floor,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Escalated   *bool    `json:"escalated,omitempty"`
+	Overdue     *bool    `json:"overdue,omitempty"`
+	CreatedFrom string   `json:"created_from,omitempty"`
+	CreatedTo   string   `json:"created_to,omitempty"`
+	Sort        string   `json:"sort,omitempty"`
+}
+
+// SavedFilter is a named ListFilter belonging to one admin
+type SavedFilter struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	OwnerID   int        `json:"owner_id"`
+	Filter    ListFilter `json:"filter"`
+	CreatedAt string     `json:"created_at"`
+	UpdatedAt string     `json:"updated_at,omitempty"`
+}
+
+type SaveFilterRequest struct {
+	SecretCode string     `json:"secret_code"`
+	Name       string     `json:"name"`
+	Filter     ListFilter `json:"filter"`
+}
+
+type DeleteFilterRequest struct {
+	SecretCode string `json:"secret_code"`
+	FilterID   int    `json:"filter_id"`
+}
+
+// withFilter fills in the listing fields req leaves out from filter. Every
+// field req sets, even to false, overrides the filter's.
+func withFilter(req GetComplaintsRequest, filter ListFilter) GetComplaintsRequest {
+	if req.Status == "" {
+		req.Status = filter.Status
+	}
+	if req.Priority == "" {
+		req.Priority = filter.Priority
+	}
+	if req.Department == "" {
+		req.Department = filter.Department
+	}
+	if req.Building == "" {
+		req.Building = filter.Building
+	}
+	if req.Floor == "" {
+		req.Floor = filter.Floor
+	}
+	if req.Source == "" {
+		req.Source = filter.Source
+	}
+	if req.Tags == nil {
+		req.Tags = filter.Tags
+	}
+	if req.Escalated == nil {
+		req.Escalated = filter.Escalated
+	}
+	if req.Overdue == nil {
+		req.Overdue = filter.Overdue
+	}
+	if req.CreatedFrom == "" {
+		req.CreatedFrom = filter.CreatedFrom
+	}
+	if req.CreatedTo == "" {
+		req.CreatedTo = filter.CreatedTo
+	}
+	if req.Sort == "" {
+		req.Sort = filter.Sort
+	}
+	return req
+}
+
+// normalizeListFilter checks filter with parseListOptions, the code that
+// will run it, and returns it in normalized form, so a saved filter always
+// executes
+func normalizeListFilter(filter ListFilter) (ListFilter, error) {
+	opts, err := parseListOptions(withFilter(GetComplaintsRequest{}, filter))
+	if err != nil {
+		return filter, err
+	}
+	filter.Department = opts.department
+	filter.Building, filter.Floor = opts.building, opts.floor
+	filter.Source = opts.source
+	filter.Tags = opts.tags
+	if len(filter.Tags) == 0 {
+		filter.Tags = nil
+	}
+	filter.CreatedFrom, filter.CreatedTo = opts.from, opts.to
+	return filter, nil
+}
+
+// savedFilterFor returns filter id if it belongs to user
+func savedFilterFor(user *User, id int) (ListFilter, *APIError) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	saved, exists := storage.savedFilters[id]
+	if !exists {
+		return ListFilter{}, newAPIError(http.StatusNotFound, ErrCodeNotFound, "Filter not found")
+	}
+	if apiErr := canUseFilter(actorOf(user), saved).apiError(); apiErr != nil {
+		return ListFilter{}, apiErr
+	}
+	return saved.Filter, nil
+}
+
+// filtersOf lists the filters saved by userID, by name. Callers must hold
+// storage.mutex for reading.
+func filtersOf(userID int) []*SavedFilter {
+	list := []*SavedFilter{}
+	for _, saved := range storage.savedFilters {
+		if saved.OwnerID == userID {
+			list = append(list, saved)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if a, b := strings.ToLower(list[i].Name), strings.ToLower(list[j].Name); a != b {
+			return a < b
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// /saveFilter - Save a listing filter under a name (admin only). Saving
+// under a name already in use replaces that filter.
+func saveFilterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req SaveFilterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	name := sanitizeText(req.Name, false)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Filter name is required")
+		return
+	}
+	if err := checkLength("Filter name", name, maxFilterNameLength); err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	filter, err := normalizeListFilter(req.Filter)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	own := filtersOf(user.ID)
+	for _, saved := range own {
+		if strings.EqualFold(saved.Name, name) {
+			saved.Name = name
+			saved.Filter = filter
+			saved.UpdatedAt = getCurrentTime()
+			respondWithJSON(w, http.StatusOK, APIResponse{
+				Success: true,
+				Message: "Filter updated successfully",
+				Data:    *saved,
+			})
+			return
+		}
+	}
+	if len(own) >= maxSavedFilters {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, fmt.Sprintf("An admin can save at most %d filters", maxSavedFilters))
+		return
+	}
+
+	storage.filterIDGen++
+	saved := &SavedFilter{
+		ID:        storage.filterIDGen,
+		Name:      name,
+		OwnerID:   user.ID,
+		Filter:    filter,
+		CreatedAt: getCurrentTime(),
+	}
+	storage.savedFilters[saved.ID] = saved
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Filter saved successfully",
+		Data:    *saved,
+	})
+}
+
+// /listFilters - The caller's saved filters, by name (admin only)
+func listFiltersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req GetComplaintsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	list := []SavedFilter{}
+	for _, saved := range filtersOf(user.ID) {
+		list = append(list, *saved)
+	}
+	storage.mutex.RUnlock()
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Filters retrieved successfully",
+		Data:    list,
+	})
+}
+
+// /deleteFilter - Delete one of the caller's saved filters (admin only)
+func deleteFilterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req DeleteFilterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.FilterID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid filter ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	saved, exists := storage.savedFilters[req.FilterID]
+	if !exists {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Filter not found")
+		return
+	}
+	if !authorize(w, canUseFilter(actorOf(user), saved).because("Access denied. You can only delete your own filters")) {
+		return
+	}
+
+	delete(storage.savedFilters, saved.ID)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Filter deleted successfully",
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSavedFilters(t *testing.T) {
+	srv := newTestServer(t)
+	_, otherAdmin := registerTestAdmin(t, srv, "Other Admin", "other-admin@example.com")
+
+	day := func(d int) string {
+		return time.Date(2024, 6, d, 12, 0, 0, 0, time.UTC).Local().Format(timestampLayout)
+	}
+	fixture := Fixture{Users: []FixtureUser{{ID: 1, Name: "Filter User", Email: "filter@example.com"}}}
+	for _, c := range []struct {
+		priority string
+		day      int
+		resolved bool
+	}{
+		{priorityHigh, 1, false},
+		{priorityHigh, 5, false},
+		{priorityLow, 5, false},
+		{priorityHigh, 5, false}, // stays in General
+		{priorityHigh, 6, true},
+		{priorityHigh, 8, false},
+	} {
+		fixture.Complaints = append(fixture.Complaints, FixtureComplaint{
+			UserID: 1, Title: "Complaint", Summary: "Filters", Rating: 5, Priority: c.priority,
+			CreatedAt: day(c.day), IsResolved: c.resolved,
+		})
+	}
+	if _, err := loadFixture(fixture); err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	storage.mutex.Lock()
+	for _, id := range []int{1, 2, 3, 5, 6} {
+		storage.complaints[id].Department = "Facilities"
+	}
+	storage.mutex.Unlock()
+
+	save := func(secret, name string, filter ListFilter) (int, SavedFilter) {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/saveFilter", SaveFilterRequest{SecretCode: secret, Name: name, Filter: filter})
+		var saved SavedFilter
+		if resp.Success {
+			resp.decode(t, &saved)
+		}
+		return status, saved
+	}
+	list := func(req GetComplaintsRequest) (int, []int) {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", req)
+		if status != http.StatusOK {
+			return status, nil
+		}
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		ids := []int{}
+		for _, complaint := range complaints {
+			ids = append(ids, complaint.ID)
+		}
+		return status, ids
+	}
+
+	status, urgent := save(adminSecret, "Urgent facilities", ListFilter{
+		Status: statusOpen, Priority: priorityHigh, Department: " facilities ", CreatedFrom: "2024-06-02", Sort: sortNewest,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", status)
+	}
+	if urgent.Filter.Department != "facilities" {
+		t.Errorf("Expected the department trimmed, got %q", urgent.Filter.Department)
+	}
+	_, stale := save(adminSecret, "Stale", ListFilter{Status: statusOpen, CreatedTo: "2024-06-04"})
+	_, foreign := save(otherAdmin, "Mine", ListFilter{Priority: priorityLow})
+
+	t.Run("List", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/listFilters", GetComplaintsRequest{SecretCode: adminSecret})
+		var filters []SavedFilter
+		resp.decode(t, &filters)
+		if len(filters) != 2 || filters[0].ID != stale.ID || filters[1].ID != urgent.ID {
+			t.Errorf("Expected the admin's own two filters by name, got %+v", filters)
+		}
+	})
+
+	t.Run("Apply And Override", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			req  GetComplaintsRequest
+			want []int
+		}{
+			{"Saved", GetComplaintsRequest{FilterID: urgent.ID}, []int{6, 2}},
+			{"Priority", GetComplaintsRequest{FilterID: urgent.ID, Priority: priorityLow}, []int{3}},
+			{"Date Range", GetComplaintsRequest{FilterID: urgent.ID, CreatedFrom: "2024-06-01", CreatedTo: "2024-06-05"}, []int{2, 1}},
+			{"Sort", GetComplaintsRequest{FilterID: urgent.ID, Sort: sortOldest}, []int{2, 6}},
+			{"Status", GetComplaintsRequest{FilterID: urgent.ID, Status: statusResolved}, []int{5}},
+			{"Other Filter", GetComplaintsRequest{FilterID: stale.ID}, []int{1}},
+		} {
+			tc.req.SecretCode = adminSecret
+			status, got := list(tc.req)
+			if status != http.StatusOK || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s: expected %v, got %d %v", tc.name, tc.want, status, got)
+			}
+		}
+
+		// An override that makes the whole invalid is rejected
+		if status, _ := list(GetComplaintsRequest{SecretCode: adminSecret, FilterID: urgent.ID, CreatedTo: "2024-06-01"}); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an empty range, got %d", status)
+		}
+	})
+
+	t.Run("Validated On Save", func(t *testing.T) {
+		for _, filter := range []ListFilter{
+			{Status: "pending"},
+			{Priority: "urgent"},
+			{Sort: "random"},
+			{CreatedFrom: "June 1st"},
+			{CreatedFrom: "2024-06-05", CreatedTo: "2024-06-01"},
+		} {
+			if status, _ := save(adminSecret, "Broken", filter); status != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %+v, got %d", filter, status)
+			}
+		}
+		if status, _ := save(adminSecret, "", ListFilter{}); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 without a name, got %d", status)
+		}
+	})
+
+	t.Run("Replace By Name", func(t *testing.T) {
+		status, replaced := save(adminSecret, "STALE", ListFilter{Status: statusOpen, CreatedTo: "2024-06-05"})
+		if status != http.StatusOK || replaced.ID != stale.ID || replaced.Name != "STALE" {
+			t.Fatalf("Expected filter %d replaced, got %d %+v", stale.ID, status, replaced)
+		}
+		if _, got := list(GetComplaintsRequest{SecretCode: adminSecret, FilterID: stale.ID}); !reflect.DeepEqual(got, []int{1, 2, 3, 4}) {
+			t.Errorf("Expected the new definition applied, got %v", got)
+		}
+	})
+
+	t.Run("Another Admin's Filter", func(t *testing.T) {
+		if status, _ := list(GetComplaintsRequest{SecretCode: adminSecret, FilterID: foreign.ID}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 applying another admin's filter, got %d", status)
+		}
+		status, _ := postJSON(t, srv, "/deleteFilter", DeleteFilterRequest{SecretCode: adminSecret, FilterID: foreign.ID})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 deleting another admin's filter, got %d", status)
+		}
+		if status, _ := list(GetComplaintsRequest{SecretCode: adminSecret, FilterID: 999}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for a missing filter, got %d", status)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		status, _ := postJSON(t, srv, "/deleteFilter", DeleteFilterRequest{SecretCode: adminSecret, FilterID: urgent.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", status)
+		}
+		if status, _ := list(GetComplaintsRequest{SecretCode: adminSecret, FilterID: urgent.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 after deleting, got %d", status)
+		}
+	})
+
+	_, code := registerTestUser(t, srv, "Regular User", "regular@example.com")
+	if status, _ := save(code, "Nope", ListFilter{}); status != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin, got %d", status)
+	}
+}
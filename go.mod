@@ -1,3 +1,10 @@
 module complaint-portal
 
-go 1.21
\ No newline at end of file
+go 1.21
+
+require golang.org/x/crypto v0.31.0
+
+require (
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
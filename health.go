@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// version identifies the build; release builds set it with
+// -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// startedAt is when the process started, for uptime
+var startedAt = time.Now()
+
+const (
+	// storageLockTimeout is how long the readiness check waits for the
+	// storage lock before declaring storage wedged
+	storageLockTimeout = 2 * time.Second
+	// jobStallTimeout is how long a background job may run before the
+	// runner is considered wedged
+	jobStallTimeout = 5 * time.Minute
+)
+
+// HealthCheck is the outcome of one readiness check
+type HealthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Readiness is the /health/ready payload
+type Readiness struct {
+	Status        string        `json:"status"` // ok or degraded
+	Version       string        `json:"version"`
+	UptimeSeconds int64         `json:"uptime_seconds"`
+	Users         int           `json:"users"`
+	Complaints    int           `json:"complaints"`
+	Checks        []HealthCheck `json:"checks"`
+}
+
+type readinessCheck struct {
+	name  string
+	check func() error
+}
+
+// readinessChecks run on every /health/ready request, in order
+var readinessChecks = []readinessCheck{
+	{"storage", checkStorage},
+	{"jobs", checkJobs},
+}
+
+// checkStorage fails when the storage lock cannot be taken in time, which
+// means a writer is stuck and every request would hang
+func checkStorage() error {
+	acquired := make(chan struct{})
+	go func() {
+		storage.mutex.RLock()
+		storage.mutex.RUnlock()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		return nil
+	case <-time.After(storageLockTimeout):
+		return fmt.Errorf("storage lock not acquired within %s", storageLockTimeout)
+	}
+}
+
+// checkJobs fails when a background job has been running for too long
+func checkJobs() error {
+	if name, elapsed := jobRunner.running(clock.Now()); name != "" && elapsed > jobStallTimeout {
+		return fmt.Errorf("job %s has been running for %s", name, elapsed.Round(time.Second))
+	}
+	return nil
+}
+
+// /health/live - The process is up and serving requests
+func healthLiveHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint Portal API is running",
+	})
+}
+
+// /health/ready - Whether the server can do useful work; 503 with the failing
+// checks when it cannot
+func healthReadyHandler(w http.ResponseWriter, r *http.Request) {
+	ready := Readiness{
+		Status:        "ok",
+		Version:       version,
+		UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+		Checks:        []HealthCheck{},
+	}
+
+	for _, rc := range readinessChecks {
+		result := HealthCheck{Name: rc.name, OK: true}
+		if err := rc.check(); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+			ready.Status = "degraded"
+		}
+		ready.Checks = append(ready.Checks, result)
+	}
+
+	// Counts are best effort so a wedged lock cannot hang the probe
+	if storage.mutex.TryRLock() {
+		ready.Users = len(storage.users)
+		for _, complaint := range storage.complaints {
+			if !complaint.IsDeleted {
+				ready.Complaints++
+			}
+		}
+		storage.mutex.RUnlock()
+	}
+
+	if ready.Status != "ok" {
+		respondWithJSON(w, http.StatusServiceUnavailable, APIResponse{
+			Success:   false,
+			Error:     "Service degraded",
+			ErrorCode: ErrCodeUnavailable,
+			Data:      ready,
+		})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint Portal API is ready",
+		Data:    ready,
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func getHealth(t *testing.T, url string) (int, testResponse) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	var decoded testResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp.StatusCode, decoded
+}
+
+func TestHealthLive(t *testing.T) {
+	srv := newTestServer(t)
+	for _, path := range []string{"/health", "/health/live"} {
+		if status, _ := getHealth(t, srv.URL+path); status != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, status)
+		}
+	}
+}
+
+func TestHealthReady(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2024, 8, 1, 12, 0, 0, 0, time.Local))
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Ready User", "ready@example.com")
+	submitTestComplaint(t, srv, code, "Ready check", 3)
+
+	status, resp := getHealth(t, srv.URL+"/health/ready")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+	}
+	var ready Readiness
+	resp.decode(t, &ready)
+	if ready.Status != "ok" || ready.Version != version || ready.Users != 2 || ready.Complaints != 1 {
+		t.Errorf("Unexpected readiness %+v", ready)
+	}
+	if len(ready.Checks) != len(readinessChecks) {
+		t.Errorf("Expected %d checks, got %+v", len(readinessChecks), ready.Checks)
+	}
+
+	t.Run("Wedged Job", func(t *testing.T) {
+		jobRunner.setCurrent("escalate_stale_complaints", fake.Now())
+		defer jobRunner.setCurrent("", time.Time{})
+		fake.Advance(jobStallTimeout + time.Minute)
+
+		status, resp := getHealth(t, srv.URL+"/health/ready")
+		if status != http.StatusServiceUnavailable || resp.ErrorCode != ErrCodeUnavailable {
+			t.Fatalf("Expected 503 SERVICE_UNAVAILABLE, got %d %s", status, resp.ErrorCode)
+		}
+		var degraded Readiness
+		resp.decode(t, &degraded)
+		if degraded.Status != "degraded" {
+			t.Errorf("Expected degraded status, got %q", degraded.Status)
+		}
+		for _, check := range degraded.Checks {
+			if check.OK != (check.Name != "jobs") {
+				t.Errorf("Expected only the jobs check to fail, got %+v", degraded.Checks)
+			}
+		}
+	})
+
+	t.Run("Failing Check", func(t *testing.T) {
+		original := readinessChecks
+		readinessChecks = append(readinessChecks, readinessCheck{"persistence", func() error {
+			return errors.New("data file is not writable")
+		}})
+		defer func() { readinessChecks = original }()
+
+		status, resp := getHealth(t, srv.URL+"/health/ready")
+		if status != http.StatusServiceUnavailable {
+			t.Fatalf("Expected 503, got %d", status)
+		}
+		var degraded Readiness
+		resp.decode(t, &degraded)
+		last := degraded.Checks[len(degraded.Checks)-1]
+		if last.Name != "persistence" || last.OK || last.Error != "data file is not writable" {
+			t.Errorf("Expected the failing persistence check, got %+v", last)
+		}
+	})
+}
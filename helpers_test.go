@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// adminSecret is the default admin created by createDefaultAdmin
+const adminSecret = "ADMIN_SECRET_123"
+
+// testResponse mirrors APIResponse but keeps Data raw so tests can decode it
+// into the concrete type they expect
+type testResponse struct {
+	Success   bool            `json:"success"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data"`
+	Error     string          `json:"error"`
+	ErrorCode ErrorCode       `json:"error_code"`
+}
+
+func (r testResponse) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Data, v); err != nil {
+		t.Fatalf("Failed to decode response data %s: %v", r.Data, err)
+	}
+}
+
+// fakeClock is a Clock that only moves when the test advances it
+type fakeClock struct {
+	now   time.Time
+	mutex sync.Mutex
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// useFakeClock installs a fake clock for the duration of the test
+func useFakeClock(t *testing.T, start time.Time) *fakeClock {
+	t.Helper()
+	fake := &fakeClock{now: start}
+	clock = fake
+	t.Cleanup(func() { clock = realClock{} })
+	return fake
+}
+
+// newTestServer resets the global state and serves the API routes
+func newTestServer(t testing.TB) *httptest.Server {
+	t.Helper()
+	config = defaultConfig()
+	storage = newStorage()
+	loginLimiter = newLoginLimiter()
+	replayGuard = newReplayGuard()
+	eventBus = newEventBus()
+	wsHub = newWSHub()
+	dispatcher = newDispatcher(nil)
+	jobRunner = newJobRunner()
+	maintenance = &MaintenanceMode{}
+	metrics = newMetrics()
+	emailDomains = &EmailDomainPolicy{}
+	moderator = newWordlistChecker(nil)
+	publicStats = &publicStatsCache{}
+	readCache = newResponseCache()
+	createDefaultAdmin()
+
+	srv := httptest.NewServer(checkResponses(t, newHandler()))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// checkingWriter keeps the status, headers and start of a response for
+// checkResponses, passing everything through
+type checkingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (c *checkingWriter) WriteHeader(code int) {
+	if c.status == 0 {
+		c.status = code
+		c.header = c.ResponseWriter.Header().Clone()
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *checkingWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.body.Len() < 1<<20 {
+		c.body.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *checkingWriter) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (c *checkingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// checkResponses fails t on any response of the server whose status,
+// success flag and error code disagree, or an error that is not an envelope
+func checkResponses(t testing.TB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &checkingWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+		if cw.status == 0 {
+			return
+		}
+		if err := responseProblem(cw.status, cw.header, cw.body.Bytes()); err != nil {
+			t.Errorf("%s %s answered %d: %v", r.Method, r.URL.Path, cw.status, err)
+		}
+	})
+}
+
+// responseProblem reports how a response breaks the envelope's rules, or nil.
+// Errors must be envelopes with success false and the error code's status;
+// envelopes of successes must say success true and carry no error.
+func responseProblem(status int, header http.Header, body []byte) error {
+	if status < http.StatusOK || (status >= http.StatusMultipleChoices && status < http.StatusBadRequest) {
+		return nil
+	}
+	contentType := header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/xml") {
+		return nil // the XML envelope has its own tests
+	}
+	if contentType != "application/json" {
+		if status >= http.StatusBadRequest {
+			return fmt.Errorf("error sent as %q, not application/json", contentType)
+		}
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields["success"] == nil {
+		if status >= http.StatusBadRequest {
+			return fmt.Errorf("error is not an envelope: %.200s", body)
+		}
+		return nil // a download, such as a backup
+	}
+	var response testResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("malformed envelope: %v", err)
+	}
+
+	if status < http.StatusBadRequest {
+		if !response.Success || response.Error != "" || response.ErrorCode != "" {
+			return fmt.Errorf("success status with success %v, error %q, error_code %q", response.Success, response.Error, response.ErrorCode)
+		}
+		return nil
+	}
+	if response.Success || response.Error == "" {
+		return fmt.Errorf("error status with success %v and error %q", response.Success, response.Error)
+	}
+	if expected, known := errorStatuses[response.ErrorCode]; !known || expected != status {
+		return fmt.Errorf("error_code %q belongs with status %d", response.ErrorCode, expected)
+	}
+	return nil
+}
+
+// disableSubmissionLimits lifts the open complaint quota and submission rate
+// limit for tests that need many complaints from one user
+func disableSubmissionLimits() {
+	config.MaxOpenComplaints = 0
+	config.SubmitRateLimit = 0
+}
+
+func postJSON(t *testing.T, srv *httptest.Server, endpoint string, payload interface{}) (int, testResponse) {
+	t.Helper()
+	status, response, err := sendJSON(srv, endpoint, payload)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", endpoint, err)
+	}
+	return status, response
+}
+
+// sendJSON is postJSON for goroutines other than the test's, which must not
+// call t.Fatal
+func sendJSON(srv *httptest.Server, endpoint string, payload interface{}) (int, testResponse, error) {
+	var response testResponse
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return 0, response, err
+	}
+
+	resp, err := http.Post(srv.URL+endpoint, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return 0, response, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return resp.StatusCode, response, fmt.Errorf("decoding response: %w", err)
+	}
+	return resp.StatusCode, response, nil
+}
+
+// registerTestUser registers a user and returns its ID and secret code
+func registerTestUser(t *testing.T, srv *httptest.Server, name, email string) (int, string) {
+	t.Helper()
+	status, resp := postJSON(t, srv, "/register", RegisterRequest{Name: name, Email: email})
+	if status != http.StatusCreated {
+		t.Fatalf("Register %s: expected status 201, got %d (%s)", email, status, resp.Error)
+	}
+
+	var user User
+	resp.decode(t, &user)
+	return user.ID, user.SecretCode
+}
+
+// submitTestComplaint files a complaint and returns it as stored
+func submitTestComplaint(t *testing.T, srv *httptest.Server, secretCode, title string, rating int) Complaint {
+	t.Helper()
+	status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+		SecretCode: secretCode,
+		Title:      title,
+		Summary:    "Summary of " + title,
+		Rating:     rating,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Submit %q: expected status 201, got %d (%s)", title, status, resp.Error)
+	}
+
+	var complaint Complaint
+	resp.decode(t, &complaint)
+	return complaint
+}
@@ -0,0 +1,44 @@
+package main
+
+// Complaint history actions
+const (
+	historyAssigned       = "assigned"
+	historySLABreached    = "sla_breached"
+	historyEscalated      = "escalated"
+	historyMerged         = "merged"
+	historyLinked         = "linked"
+	historyUnlinked       = "unlinked"
+	historyArchived       = "archived"
+	historyUnarchived     = "unarchived"
+	historyApproved       = "review_approved"
+	historyRedacted       = "redacted"
+	historyUpdated        = "updated"
+	historyCommented      = "commented"
+	historyCommentEdited  = "comment_edited"
+	historyCommentDeleted = "comment_deleted"
+	historyPinned         = "pinned"
+	historyUnpinned       = "unpinned"
+	historyResolved       = "resolved"
+	historyShared         = "shared"
+	historyShareRevoked   = "share_revoked"
+
+	historyResolutionProposed  = "resolution_proposed"
+	historyResolutionConfirmed = "resolution_confirmed"
+	historyResolutionRejected  = "resolution_rejected"
+)
+
+// HistoryEntry is one change to a complaint, kept for admins
+type HistoryEntry struct {
+	Action  string `json:"action" xml:"action"`
+	ActorID int    `json:"actor_id,omitempty" xml:"actor_id,omitempty"` // 0 when the server made the change
+	Detail  string `json:"detail,omitempty" xml:"detail,omitempty"`
+	At      string `json:"at" xml:"at"`
+	// CorrelationID is that of the request that made the change
+	CorrelationID string `json:"correlation_id,omitempty" xml:"correlation_id,omitempty"`
+}
+
+// addHistory appends entry to complaint's history, stamping the time
+func addHistory(complaint *Complaint, entry HistoryEntry) {
+	entry.At = getCurrentTime()
+	complaint.History = append(complaint.History, entry)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importColumns are the CSV columns /importComplaints understands. email,
+// title, summary and rating are required; name is only used for users the
+// import creates.
+var importColumns = []string{"email", "title", "summary", "rating", "created_at", "resolved_at", "name"}
+
+var requiredImportColumns = []string{"email", "title", "summary", "rating"}
+
+// ImportRowResult is the outcome of one CSV row. Row is the line number in
+// the file, counting the header as line 1.
+type ImportRowResult struct {
+	Row         int    `json:"row"`
+	ComplaintID int    `json:"complaint_id,omitempty"` // not set on a dry run
+	UserCreated bool   `json:"user_created,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type ImportResult struct {
+	DryRun   bool              `json:"dry_run"`
+	Imported int               `json:"imported"` // rows that were, or on a dry run would be, imported
+	Failed   int               `json:"failed"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+// importRow is a CSV row keyed by column name
+type importRow struct {
+	line   int
+	fields map[string]string
+}
+
+// readImportCSV reads the header and every row. Malformed CSV fails the whole
+// import before anything is written.
+func readImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: %v", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		known := false
+		for _, column := range importColumns {
+			known = known || name == column
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown column %q; columns are %s", name, strings.Join(importColumns, ", "))
+		}
+		columns[name] = i
+	}
+	for _, column := range requiredImportColumns {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("Missing required column %q", column)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line, fields: map[string]string{}}
+		for name, i := range columns {
+			if i < len(record) {
+				row.fields[name] = strings.TrimSpace(record[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseImportTime accepts the stored timestamp format, RFC 3339 or a bare
+// date, as spreadsheets export all three
+func parseImportTime(value string) (time.Time, error) {
+	if t, err := parseTimestamp(value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(dateLayout, value, time.Local)
+}
+
+// parseImportRow checks row with the same rules as /submitComplaint and
+// returns the complaint to store
+func parseImportRow(row importRow, now time.Time) (Complaint, error) {
+	if row.fields["email"] == "" {
+		return Complaint{}, errors.New("Email is required")
+	}
+	rating, err := strconv.Atoi(row.fields["rating"])
+	if err != nil {
+		return Complaint{}, errors.New("Rating must be a whole number")
+	}
+	req, apiErr := validateSubmission(SubmitComplaintRequest{
+		Title:   row.fields["title"],
+		Summary: row.fields["summary"],
+		Rating:  rating,
+	})
+	if apiErr != nil {
+		return Complaint{}, apiErr
+	}
+
+	createdAt := now
+	if value := row.fields["created_at"]; value != "" {
+		if createdAt, err = parseImportTime(value); err != nil {
+			return Complaint{}, fmt.Errorf("created_at %q is not a date or timestamp", value)
+		}
+		if createdAt.After(now) {
+			return Complaint{}, errors.New("created_at is in the future")
+		}
+	}
+
+	complaint := Complaint{
+		Title:     req.Title,
+		Summary:   req.Summary,
+		Rating:    req.Rating,
+		Priority:  req.Priority,
+		CreatedAt: createdAt.Local().Format(timestampLayout),
+		Source:    sourceImport,
+	}
+
+	if value := row.fields["resolved_at"]; value != "" {
+		resolvedAt, err := parseImportTime(value)
+		if err != nil {
+			return Complaint{}, fmt.Errorf("resolved_at %q is not a date or timestamp", value)
+		}
+		if resolvedAt.Before(createdAt) {
+			return Complaint{}, errors.New("resolved_at is before created_at")
+		}
+		complaint.IsResolved = true
+		complaint.ResolvedAt = resolvedAt.Local().Format(timestampLayout)
+	}
+	return complaint, nil
+}
+
+// importComplaints stores each valid row on its own, so one bad row never
+// affects another. With createUsers, unknown emails get a new user; with
+// dryRun, nothing is written. Rows left when ctx ends are not imported.
+func importComplaints(ctx context.Context, rows []importRow, createUsers, dryRun bool) ImportResult {
+	now := clock.Now()
+	result := ImportResult{DryRun: dryRun, Rows: []ImportRowResult{}}
+	pendingUsers := map[string]bool{} // users a dry run would have created
+
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			break
+		}
+		outcome := ImportRowResult{Row: row.line}
+		complaint, err := parseImportRow(row, now)
+		if err == nil {
+			outcome.ComplaintID, outcome.UserCreated, err = importComplaint(row, complaint, createUsers, dryRun, pendingUsers)
+		}
+		if err != nil {
+			outcome.Error = err.Error()
+			result.Failed++
+		} else {
+			result.Imported++
+		}
+		result.Rows = append(result.Rows, outcome)
+	}
+	return result
+}
+
+// importComplaint stores one parsed row under the write lock and returns the
+// new complaint's ID and whether its user was created
+func importComplaint(row importRow, complaint Complaint, createUsers, dryRun bool, pendingUsers map[string]bool) (int, bool, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	email := row.fields["email"]
+	var owner *User
+	for _, user := range storage.users {
+		if user.Email == email {
+			owner = user
+			break
+		}
+	}
+
+	created := false
+	if owner == nil {
+		if !createUsers {
+			return 0, false, fmt.Errorf("No user with email %s", email)
+		}
+		if dryRun {
+			created = !pendingUsers[email]
+			pendingUsers[email] = true
+			return 0, created, nil
+		}
+
+		name := sanitizeText(row.fields["name"], false)
+		if name == "" {
+			name, _, _ = strings.Cut(email, "@")
+		}
+		storage.userIDGen++
+		owner = &User{
+			ID:         storage.userIDGen,
+			SecretCode: generateSecretCode(storage.userIDGen),
+			Name:       name,
+			Email:      email,
+			Complaints: []Complaint{},
+			OrgID:      storage.defaultOrgID,
+			IsActive:   true,
+		}
+		storage.usersMutex.Lock()
+		storage.users[owner.ID] = owner
+		storage.secretIndex[owner.SecretCode] = owner.ID
+		storage.usersMutex.Unlock()
+		created = true
+	}
+
+	if dryRun {
+		return 0, false, nil
+	}
+	stored, err := storeComplaint(owner, complaint)
+	if err != nil {
+		return 0, created, err
+	}
+	return stored.ID, created, nil
+}
+
+// formBool reads an optional true/false form value
+func formBool(r *http.Request, name string) (bool, error) {
+	switch r.FormValue(name) {
+	case "", "false":
+		return false, nil
+	case "true":
+		return true, nil
+	}
+	return false, fmt.Errorf("%s must be true or false", name)
+}
+
+// /importComplaints - Load historical complaints from a CSV upload
+// (super-admin only)
+func importComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	limit := bodyLimit("/importComplaints")
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if err := r.ParseMultipartForm(limit); err != nil {
+		if isBodyTooLarge(err) {
+			respondWithAPIError(w, errBodyTooLarge(limit))
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Request must be a multipart form with a CSV file")
+		return
+	}
+
+	secretCode := strings.TrimSpace(r.FormValue("secret_code"))
+	if secretCode == "" {
+		secretCode = secretFromRequest(r)
+	}
+	if secretCode == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	createUsers, err := formBool(r, "create_users")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	dryRun, err := formBool(r, "dry_run")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "A CSV file is required in the file field")
+		return
+	}
+	defer file.Close()
+
+	user := authenticate(w, r, secretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	rows, err := readImportCSV(file)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	result := importComplaints(r.Context(), rows, createUsers, dryRun)
+	if err := r.Context().Err(); err != nil {
+		// The client has gone or already received a timeout
+		return
+	}
+
+	message := "Complaints imported"
+	if dryRun {
+		message = "Dry run completed; nothing was imported"
+	}
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
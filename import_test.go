@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// postImport uploads csvData to /importComplaints with the given form fields
+func postImport(t *testing.T, srv *httptest.Server, csvData []byte, fields map[string]string) (int, testResponse) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	if csvData != nil {
+		part, _ := form.CreateFormFile("file", "complaints.csv")
+		part.Write(csvData)
+	}
+	form.Close()
+
+	resp, err := http.Post(srv.URL+"/importComplaints", form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("POST /importComplaints failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response testResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp.StatusCode, response
+}
+
+func TestImportComplaints(t *testing.T) {
+	srv := newTestServer(t)
+	knownID, knownCode := registerTestUser(t, srv, "Known User", "known@example.com")
+
+	csvData, err := os.ReadFile("testdata/import_complaints.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	complaintCount := func() int {
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		return len(storage.complaints)
+	}
+
+	// Row numbers match the fixture's lines; rows 6-11 are bad
+	wantErrors := map[int]bool{6: true, 7: true, 8: true, 9: true, 10: true, 11: true}
+
+	checkRows := func(t *testing.T, result ImportResult) {
+		t.Helper()
+		if result.Imported != 4 || result.Failed != 6 || len(result.Rows) != 10 {
+			t.Fatalf("Expected 4 imported and 6 failed rows, got %+v", result)
+		}
+		for i, row := range result.Rows {
+			if row.Row != i+2 {
+				t.Errorf("Expected row %d, got %d", i+2, row.Row)
+			}
+			if (row.Error != "") != wantErrors[row.Row] {
+				t.Errorf("Row %d: unexpected outcome %+v", row.Row, row)
+			}
+		}
+	}
+
+	t.Run("Unknown Users Without Create", func(t *testing.T) {
+		status, resp := postImport(t, srv, csvData, map[string]string{"secret_code": adminSecret, "dry_run": "true"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var result ImportResult
+		resp.decode(t, &result)
+		if result.Imported != 2 || result.Rows[2].Error != "No user with email new@example.com" {
+			t.Errorf("Expected rows for an unknown email to fail, got %+v", result)
+		}
+	})
+
+	t.Run("Dry Run", func(t *testing.T) {
+		status, resp := postImport(t, srv, csvData, map[string]string{"secret_code": adminSecret, "dry_run": "true", "create_users": "true"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var result ImportResult
+		resp.decode(t, &result)
+		checkRows(t, result)
+		if !result.DryRun || result.Rows[0].ComplaintID != 0 {
+			t.Errorf("Expected a dry run without IDs, got %+v", result)
+		}
+		if !result.Rows[2].UserCreated || result.Rows[3].UserCreated {
+			t.Errorf("Expected only the first row for a new email to create its user")
+		}
+		if complaintCount() != 0 || findUserByEmail("new@example.com") != nil {
+			t.Error("Expected a dry run to write nothing")
+		}
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		status, resp := postImport(t, srv, csvData, map[string]string{"secret_code": adminSecret, "create_users": "true"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var result ImportResult
+		resp.decode(t, &result)
+		checkRows(t, result)
+
+		newUser := findUserByEmail("new@example.com")
+		if newUser == nil || newUser.Name != "New Person" {
+			t.Fatalf("Expected the missing user to be created, got %+v", newUser)
+		}
+
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		if len(storage.complaints) != 4 {
+			t.Fatalf("Expected 4 complaints stored, got %d", len(storage.complaints))
+		}
+		heater := storage.complaints[result.Rows[0].ComplaintID]
+		if heater.UserID != knownID || heater.CreatedAt != "2023-01-10 09:00:00" || !heater.IsResolved || heater.ResolvedAt != "2023-01-12 17:30:00" {
+			t.Errorf("Expected the provided timestamps to be kept, got %+v", heater)
+		}
+		if lights := storage.complaints[result.Rows[1].ComplaintID]; lights.CreatedAt != "2023-02-01 00:00:00" || lights.IsResolved {
+			t.Errorf("Expected a date-only created_at and an open complaint, got %+v", lights)
+		}
+		if len(newUser.Complaints) != 2 {
+			t.Errorf("Expected both new-user rows on one user, got %d", len(newUser.Complaints))
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		status, _ := postImport(t, srv, csvData, map[string]string{"secret_code": knownCode})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a non-admin, got %d", status)
+		}
+		status, _ = postImport(t, srv, nil, map[string]string{"secret_code": adminSecret})
+		if status != http.StatusBadRequest {
+			t.Errorf("Expected 400 without a file, got %d", status)
+		}
+		status, resp := postImport(t, srv, []byte("email,title\nx@example.com,T\n"), map[string]string{"secret_code": adminSecret})
+		if status != http.StatusBadRequest || resp.Error != `Missing required column "summary"` {
+			t.Errorf("Expected 400 for a missing column, got %d %q", status, resp.Error)
+		}
+		status, _ = postImport(t, srv, []byte("email,title,summary,rating\n\"unclosed,T,S,5\n"), map[string]string{"secret_code": adminSecret})
+		if status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for malformed CSV, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Results of /ingestEmail
+const (
+	ingestCreated   = "created"
+	ingestCommented = "commented"
+)
+
+// subjectReference finds a complaint reference tag such as [CMP-7F3K9Q] in
+// an email subject, wherever replies put it
+var subjectReference = regexp.MustCompile(`(?i)\[\s*(` + referencePrefix + `[0-9a-z]+)\s*\]`)
+
+// IngestEmailRequest is an email as the mail gateway passes it on
+type IngestEmailRequest struct {
+	From    string `json:"from"` // e.g. "Jane Doe <jane@example.com>"
+	Subject string `json:"subject"`
+	Body    string `json:"body"` // plain text
+}
+
+type IngestEmailResult struct {
+	Action      string    `json:"action"` // created or commented
+	UserID      int       `json:"user_id"`
+	UserCreated bool      `json:"user_created,omitempty"` // the sender was unknown and a provisional user was made for them
+	Complaint   Complaint `json:"complaint"`              // as the sender sees it
+}
+
+// validateIngestPriority checks -ingest-priority at startup
+func validateIngestPriority(priority string) error {
+	for _, candidate := range priorities {
+		if priority == candidate {
+			return nil
+		}
+	}
+	return fmt.Errorf("Ingest priority must be one of: %s", strings.Join(priorities, ", "))
+}
+
+// ingestRating is the rating given to complaints filed by email, the
+// middle of the scale, as an email says nothing about it
+func ingestRating() int {
+	scale := currentRatingScale()
+	return scale.Min + (scale.Max-scale.Min)/2
+}
+
+// subjectComplaintReference is the reference tagged in subject, if any
+func subjectComplaintReference(subject string) (string, bool) {
+	match := subjectReference.FindStringSubmatch(subject)
+	if match == nil {
+		return "", false
+	}
+	return normalizeReference(match[1])
+}
+
+// authenticateIngestToken authenticates the mail gateway, which must send
+// an ingest_email API token, and returns the admin the token acts for. On
+// failure it has already written the response.
+func authenticateIngestToken(w http.ResponseWriter, r *http.Request) *User {
+	credential := secretFromRequest(r)
+	if !isAPIToken(credential) {
+		respondWithError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "An ingest_email API token is required")
+		return nil
+	}
+
+	storage.mutex.RLock()
+	token, admin, apiErr := activeAPIToken(credential, clock.Now())
+	storage.mutex.RUnlock()
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return nil
+	}
+	if token.Scope != scopeIngestEmail {
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, "Access denied. An ingest_email API token is required")
+		return nil
+	}
+	setLogUser(r, admin.ID)
+	return admin
+}
+
+// provisionalUser adds a user for an unknown sender, in the organization of
+// the admin whose token ingested the email. Callers must hold storage.mutex
+// for writing, but not storage.usersMutex.
+func provisionalUser(address *mail.Address, orgID int) *User {
+	name := sanitizeText(address.Name, false)
+	if name == "" {
+		name, _, _ = strings.Cut(address.Address, "@")
+	}
+	if runes := []rune(name); len(runes) > maxNameLength {
+		name = string(runes[:maxNameLength])
+	}
+
+	storage.userIDGen++
+	user := &User{
+		ID:          storage.userIDGen,
+		SecretCode:  generateSecretCode(storage.userIDGen),
+		Name:        name,
+		Email:       address.Address,
+		Complaints:  []Complaint{},
+		OrgID:       orgID,
+		Provisional: true,
+		IsActive:    true,
+	}
+
+	storage.usersMutex.Lock()
+	storage.users[user.ID] = user
+	storage.secretIndex[user.SecretCode] = user.ID
+	storage.usersMutex.Unlock()
+	return user
+}
+
+// threadedComplaint is the complaint an email from sender tagged with
+// reference replies to, or nil when there is none sender may comment on: the
+// tag is unknown, the complaint is in the trash, or it is neither sender's
+// own nor one of their organization's for an admin. Callers must hold
+// storage.mutex.
+func threadedComplaint(sender *User, reference string) *Complaint {
+	id, exists := storage.references[reference]
+	if !exists {
+		return nil
+	}
+	complaint, exists := storage.complaints[id]
+	if !exists || complaint.IsDeleted {
+		return nil
+	}
+	if !canViewComplaint(actorOf(sender), complaint).allowed() {
+		return nil
+	}
+	return complaint
+}
+
+// /ingestEmail - Turn an email into a complaint, or a reply to one into a
+// comment (mail gateway only, with an ingest_email API token)
+func ingestEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req IngestEmailRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	subject := sanitizeText(req.Subject, false)
+	body := sanitizeText(req.Body, true)
+	var v validator
+	v.required("from", req.From)
+	address, err := mail.ParseAddress(req.From)
+	if err != nil {
+		v.add("from", "must be a valid email address")
+	}
+	v.required("subject", subject)
+	v.maxRunes("subject", subject, maxTitleLength)
+	v.required("body", body)
+	v.maxRunes("body", body, maxSummaryLength)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	admin := authenticateIngestToken(w, r)
+	if admin == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	sender, created := findUserByEmail(address.Address), false
+	if sender == nil {
+		if !config.IngestCreateUsers {
+			storage.mutex.Unlock()
+			respondWithError(w, http.StatusUnprocessableEntity, ErrCodeUnknownSender, "No user has the sender's email address")
+			return
+		}
+		if apiErr := emailDomains.check(address.Address); apiErr != nil {
+			storage.mutex.Unlock()
+			respondWithAPIError(w, apiErr)
+			return
+		}
+		sender, created = provisionalUser(address, admin.OrgID), true
+	}
+	if !canSeeOrg(admin, sender.OrgID) {
+		storage.mutex.Unlock()
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, "Access denied. The sender belongs to another organization")
+		return
+	}
+	if !sender.IsActive {
+		storage.mutex.Unlock()
+		respondWithError(w, http.StatusForbidden, ErrCodeAccountDeactivated, "The sender's account has been deactivated")
+		return
+	}
+
+	// A reply to a complaint's email is a comment on it
+	if reference, found := subjectComplaintReference(subject); found {
+		if complaint := threadedComplaint(sender, reference); complaint != nil {
+			addComment(complaint, sender, body, sourceEmail, correlationID(r))
+			result := IngestEmailResult{Action: ingestCommented, UserID: sender.ID, UserCreated: created, Complaint: complaintForViewer(sender, *complaint)}
+			storage.mutex.Unlock()
+
+			requestLogger(r).Info("Email ingested", "action", ingestCommented, logKeyComplaintID, complaint.ID)
+			respondWithJSON(w, http.StatusOK, APIResponse{
+				Success: true,
+				Message: "Reply added as a comment",
+				Data:    result,
+			})
+			return
+		}
+	}
+	secretCode := sender.SecretCode
+	storage.mutex.Unlock()
+
+	submission, apiErr := validateSubmission(SubmitComplaintRequest{
+		Title:    subject,
+		Summary:  body,
+		Rating:   ingestRating(),
+		Priority: config.IngestPriority,
+		Source:   sourceEmail,
+	})
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+	complaint, apiErr := createComplaint(sender, secretCode, submission, correlationID(r))
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	requestLogger(r).Info("Email ingested", "action", ingestCreated, logKeyComplaintID, complaint.ID)
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Complaint created from email",
+		Data:    IngestEmailResult{Action: ingestCreated, UserID: sender.ID, UserCreated: created, Complaint: complaint},
+	})
+}
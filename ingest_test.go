@@ -0,0 +1,176 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestIngestEmail(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	ownerID, ownerCode := registerTestUser(t, srv, "Mail User", "mailer@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+
+	createToken := func(scope string) string {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/createApiToken", CreateAPITokenRequest{SecretCode: adminSecret, Label: "Mail gateway", Scope: scope})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var token CreatedAPIToken
+		resp.decode(t, &token)
+		return token.Token
+	}
+	token := createToken(scopeIngestEmail)
+	ingest := func(email IngestEmailRequest) (int, IngestEmailResult, testResponse) {
+		t.Helper()
+		httpResp, resp := doV1(t, srv, http.MethodPost, "/ingestEmail", token, email)
+		var result IngestEmailResult
+		if resp.Success {
+			resp.decode(t, &result)
+		}
+		return httpResp.StatusCode, result, resp
+	}
+	ownComplaints := func(secret string) []Complaint {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: secret})
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		return complaints
+	}
+
+	var filed Complaint
+	t.Run("New Complaint", func(t *testing.T) {
+		status, result, resp := ingest(IngestEmailRequest{
+			From:    "Mail User <mailer@example.com>",
+			Subject: "Broken lift",
+			Body:    "The lift on floor 3 is stuck again.",
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		filed = result.Complaint
+		if result.Action != ingestCreated || result.UserID != ownerID || result.UserCreated {
+			t.Errorf("Unexpected result %+v", result)
+		}
+		if filed.Title != "Broken lift" || filed.Summary != "The lift on floor 3 is stuck again." || filed.UserID != ownerID {
+			t.Errorf("Expected the email as the owner's complaint, got %+v", filed)
+		}
+		if filed.Source != sourceEmail || filed.Priority != priorityMedium || filed.Rating != ingestRating() {
+			t.Errorf("Expected source email at the defaults, got %q %q %d", filed.Source, filed.Priority, filed.Rating)
+		}
+		if complaints := ownComplaints(ownerCode); len(complaints) != 1 || complaints[0].ID != filed.ID {
+			t.Errorf("Expected the complaint in the owner's list, got %+v", complaints)
+		}
+	})
+
+	t.Run("Threaded Reply", func(t *testing.T) {
+		status, result, resp := ingest(IngestEmailRequest{
+			From:    "mailer@example.com",
+			Subject: "Re: [" + strings.ToLower(filed.Reference) + "] Broken lift",
+			Body:    "Still stuck this morning.",
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if result.Action != ingestCommented || result.Complaint.ID != filed.ID || result.Complaint.Version != filed.Version+1 {
+			t.Errorf("Expected a comment on complaint %d, got %+v", filed.ID, result)
+		}
+		comments := result.Complaint.Comments
+		if len(comments) != 1 || comments[0].Body != "Still stuck this morning." || comments[0].UserID != ownerID || comments[0].Source != sourceEmail {
+			t.Errorf("Unexpected comments %+v", comments)
+		}
+		if complaints := ownComplaints(ownerCode); len(complaints) != 1 {
+			t.Errorf("Expected no new complaint, got %d", len(complaints))
+		}
+
+		view := assigneeOf(t, srv, filed.ID)
+		if len(view.Comments) != 1 || view.History[len(view.History)-1].Action != historyCommented {
+			t.Errorf("Expected admins to see the comment and its history, got %+v", view)
+		}
+		_, viewResp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: otherCode, ComplaintID: filed.ID})
+		if viewResp.Success {
+			t.Error("Expected another user not to see the complaint")
+		}
+
+		// Someone else quoting the tag files a complaint of their own
+		status, result, _ = ingest(IngestEmailRequest{From: "other@example.com", Subject: "Fwd: [" + filed.Reference + "]", Body: "Me too"})
+		if status != http.StatusCreated || result.Action != ingestCreated {
+			t.Errorf("Expected a new complaint for another sender, got %d %+v", status, result)
+		}
+		// An unknown tag is only part of the title
+		status, result, _ = ingest(IngestEmailRequest{From: "mailer@example.com", Subject: "[CMP-ZZZZZZ] Heating", Body: "Cold"})
+		if status != http.StatusCreated || result.Complaint.Title != "[CMP-ZZZZZZ] Heating" {
+			t.Errorf("Expected a new complaint for an unknown tag, got %d %+v", status, result)
+		}
+	})
+
+	t.Run("Unknown Sender Refused", func(t *testing.T) {
+		status, _, resp := ingest(IngestEmailRequest{From: "stranger@example.com", Subject: "Hello", Body: "Noise"})
+		if status != http.StatusUnprocessableEntity || resp.ErrorCode != ErrCodeUnknownSender {
+			t.Errorf("Expected 422 UNKNOWN_SENDER, got %d %s", status, resp.ErrorCode)
+		}
+		if findUserByEmail("stranger@example.com") != nil {
+			t.Error("Expected no user created")
+		}
+	})
+
+	t.Run("Unknown Sender Created", func(t *testing.T) {
+		config.IngestCreateUsers = true
+		status, result, resp := ingest(IngestEmailRequest{From: "Sam Stranger <stranger@example.com>", Subject: "Hello", Body: "Noise at night"})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		user := findUserByEmail("stranger@example.com")
+		if user == nil || !user.Provisional || user.Name != "Sam Stranger" || user.ID != result.UserID || !result.UserCreated {
+			t.Fatalf("Expected a provisional user, got %+v and %+v", user, result)
+		}
+		if result.Complaint.UserID != user.ID {
+			t.Errorf("Expected the complaint filed for the new user, got %+v", result.Complaint)
+		}
+
+		// The next email finds the user made for the first
+		status, result, _ = ingest(IngestEmailRequest{From: "stranger@example.com", Subject: "Again", Body: "Still noisy"})
+		if status != http.StatusCreated || result.UserCreated || result.UserID != user.ID {
+			t.Errorf("Expected the same user, got %d %+v", status, result)
+		}
+	})
+
+	t.Run("Malformed Payloads", func(t *testing.T) {
+		for _, email := range []IngestEmailRequest{
+			{From: "not an address", Subject: "Hi", Body: "Text"},
+			{From: "mailer@example.com", Subject: "", Body: "Text"},
+			{From: "mailer@example.com", Subject: "Hi", Body: "   "},
+			{From: "mailer@example.com", Subject: strings.Repeat("x", maxTitleLength+1), Body: "Text"},
+		} {
+			if status, _, resp := ingest(email); status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+				t.Errorf("%+v: expected 400 VALIDATION_FAILED, got %d %s", email, status, resp.ErrorCode)
+			}
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/ingestEmail", strings.NewReader(`{"from":`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		httpResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		httpResp.Body.Close()
+		if httpResp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for invalid JSON, got %d", httpResp.StatusCode)
+		}
+	})
+
+	t.Run("Token Scope", func(t *testing.T) {
+		email := IngestEmailRequest{From: "mailer@example.com", Subject: "Hi", Body: "Text"}
+		for _, credential := range []string{adminSecret, createToken(scopeReadWrite)} {
+			httpResp, _ := doV1(t, srv, http.MethodPost, "/ingestEmail", credential, email)
+			if httpResp.StatusCode != http.StatusUnauthorized && httpResp.StatusCode != http.StatusForbidden {
+				t.Errorf("Expected the endpoint refused without an ingest_email token, got %d", httpResp.StatusCode)
+			}
+		}
+		if status, _ := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: token}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for an ingest_email token elsewhere, got %d", status)
+		}
+	})
+}
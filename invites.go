@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	invitePrefix  = "INV_"
+	maxInviteUses = 1000
+)
+
+// Invite statuses, as listed by /listInvites
+const (
+	inviteStatusActive    = "active"
+	inviteStatusExpired   = "expired"
+	inviteStatusExhausted = "exhausted"
+)
+
+// Invite lets up to MaxUses people register into the organization of the
+// admin who created it
+type Invite struct {
+	ID        int    `json:"id"`
+	Code      string `json:"code"`
+	OrgID     int    `json:"org_id"`
+	MaxUses   int    `json:"max_uses"`
+	UsedBy    []int  `json:"used_by"` // users who registered with it, in order
+	CreatedBy int    `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at,omitempty"` // never expires when empty
+	Status    string `json:"status,omitempty"`     // filled in when listed
+
+	expires time.Time
+}
+
+type CreateInviteRequest struct {
+	SecretCode     string `json:"secret_code"`
+	MaxUses        int    `json:"max_uses,omitempty"`         // 1 when left out
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"` // 0 for no expiry
+	OrgID          int    `json:"org_id,omitempty"`           // super-admins only; the caller's organization when left out
+}
+
+type ListInvitesRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+func generateInvite() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return invitePrefix + hex.EncodeToString(buf), nil
+}
+
+// inviteStatus reports whether invite can still be used at now
+func inviteStatus(invite *Invite, now time.Time) string {
+	switch {
+	case len(invite.UsedBy) >= invite.MaxUses:
+		return inviteStatusExhausted
+	case !invite.expires.IsZero() && !now.Before(invite.expires):
+		return inviteStatusExpired
+	}
+	return inviteStatusActive
+}
+
+// redeemableInvite resolves the invite code of a registration to the
+// organization it joins: an invite from /createInvite, which is returned so
+// the caller can record its use, or an organization's own invite code. An
+// empty code joins the default organization unless config.RequireInvites is
+// set. Callers must hold storage.mutex.
+func redeemableInvite(code string, now time.Time) (int, *Invite, *APIError) {
+	if code == "" {
+		if config.RequireInvites {
+			return 0, nil, newAPIError(http.StatusForbidden, ErrCodeInviteRequired, "An invite code is required to register")
+		}
+		return storage.defaultOrgID, nil, nil
+	}
+	if org := organizationByInviteCode(code); org != nil {
+		return org.ID, nil, nil
+	}
+	for _, invite := range storage.invites {
+		if invite.Code != code {
+			continue
+		}
+		switch inviteStatus(invite, now) {
+		case inviteStatusExhausted:
+			return 0, nil, newAPIError(http.StatusForbidden, ErrCodeInviteExhausted, "Invite code has already been used")
+		case inviteStatusExpired:
+			return 0, nil, newAPIError(http.StatusForbidden, ErrCodeInviteExpired, "Invite code has expired")
+		}
+		return invite.OrgID, invite, nil
+	}
+	return 0, nil, newAPIError(http.StatusForbidden, ErrCodeInviteInvalid, "Invalid invite code")
+}
+
+// /createInvite - Mint an invite code for the caller's organization (admin
+// only)
+func createInviteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req CreateInviteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.MaxUses < 0 || req.MaxUses > maxInviteUses {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, fmt.Sprintf("Max uses must be between 1 and %d", maxInviteUses))
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Expires in hours must not be negative")
+		return
+	}
+	if req.OrgID < 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Organization ID must not be negative")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	code, err := generateInvite()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate invite code")
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	orgID := user.OrgID
+	if req.OrgID != 0 {
+		if _, exists := storage.organizations[req.OrgID]; !exists || !canSeeOrg(user, req.OrgID) {
+			respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Organization not found")
+			return
+		}
+		orgID = req.OrgID
+	}
+
+	storage.inviteIDGen++
+	invite := &Invite{
+		ID:        storage.inviteIDGen,
+		Code:      code,
+		OrgID:     orgID,
+		MaxUses:   req.MaxUses,
+		UsedBy:    []int{},
+		CreatedBy: user.ID,
+		CreatedAt: getCurrentTime(),
+	}
+	if req.ExpiresInHours > 0 {
+		invite.expires = clock.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		invite.ExpiresAt = invite.expires.Local().Format(timestampLayout)
+	}
+	storage.invites[invite.ID] = invite
+
+	shown := *invite
+	shown.Status = inviteStatusActive
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Invite created successfully",
+		Data:    shown,
+	})
+}
+
+// /listInvites - The invites of the caller's organization with their
+// status, by ID (admin only)
+func listInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ListInvitesRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	now := clock.Now()
+	invites := []Invite{}
+	for _, invite := range storage.invites {
+		if !canSeeOrg(user, invite.OrgID) {
+			continue
+		}
+		shown := *invite
+		shown.UsedBy = append([]int{}, invite.UsedBy...)
+		shown.Status = inviteStatus(invite, now)
+		invites = append(invites, shown)
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].ID < invites[j].ID })
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Invites retrieved successfully",
+		Data:    invites,
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestInvites(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 10, 0, 0, 0, time.Local))
+
+	createInvite := func(req CreateInviteRequest) Invite {
+		t.Helper()
+		req.SecretCode = adminSecret
+		status, resp := postJSON(t, srv, "/createInvite", req)
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201 creating an invite, got %d (%s)", status, resp.Error)
+		}
+		var invite Invite
+		resp.decode(t, &invite)
+		return invite
+	}
+	register := func(email, code string) (int, testResponse) {
+		t.Helper()
+		return postJSON(t, srv, "/register", RegisterRequest{Name: "Invited", Email: email, InviteCode: code})
+	}
+	listInvites := func() []Invite {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/listInvites", ListInvitesRequest{SecretCode: adminSecret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 listing invites, got %d (%s)", status, resp.Error)
+		}
+		var invites []Invite
+		resp.decode(t, &invites)
+		return invites
+	}
+
+	// Registration is open until -require-invites is set
+	_, userCode := registerTestUser(t, srv, "Walk In", "walkin@example.com")
+
+	config.RequireInvites = true
+
+	t.Run("Required", func(t *testing.T) {
+		status, resp := register("uninvited@example.com", "")
+		if status != http.StatusForbidden || resp.ErrorCode != ErrCodeInviteRequired {
+			t.Errorf("Expected 403 %s, got %d %s", ErrCodeInviteRequired, status, resp.ErrorCode)
+		}
+		status, resp = register("uninvited@example.com", "INV_0000000000000000")
+		if status != http.StatusForbidden || resp.ErrorCode != ErrCodeInviteInvalid {
+			t.Errorf("Expected 403 %s, got %d %s", ErrCodeInviteInvalid, status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Single Use", func(t *testing.T) {
+		invite := createInvite(CreateInviteRequest{})
+		if invite.MaxUses != 1 || invite.Status != inviteStatusActive || invite.OrgID != storage.defaultOrgID {
+			t.Fatalf("Expected an active single-use invite, got %+v", invite)
+		}
+		status, resp := register("first@example.com", invite.Code)
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201 with a fresh invite, got %d (%s)", status, resp.Error)
+		}
+		var first User
+		resp.decode(t, &first)
+
+		status, resp = register("second@example.com", invite.Code)
+		if status != http.StatusForbidden || resp.ErrorCode != ErrCodeInviteExhausted {
+			t.Errorf("Expected 403 %s on reuse, got %d %s", ErrCodeInviteExhausted, status, resp.ErrorCode)
+		}
+
+		listed := listInvites()
+		if len(listed) != 1 || listed[0].Status != inviteStatusExhausted || len(listed[0].UsedBy) != 1 || listed[0].UsedBy[0] != first.ID {
+			t.Errorf("Expected the invite exhausted by user %d, got %+v", first.ID, listed)
+		}
+	})
+
+	t.Run("Several Uses And Expiry", func(t *testing.T) {
+		invite := createInvite(CreateInviteRequest{MaxUses: 3, ExpiresInHours: 24})
+		if invite.ExpiresAt == "" {
+			t.Errorf("Expected an expiry, got %+v", invite)
+		}
+		for _, email := range []string{"a@example.com", "b@example.com"} {
+			if status, resp := register(email, invite.Code); status != http.StatusCreated {
+				t.Fatalf("Expected 201 for %s, got %d (%s)", email, status, resp.Error)
+			}
+		}
+
+		fake.Advance(24 * time.Hour)
+		status, resp := register("c@example.com", invite.Code)
+		if status != http.StatusForbidden || resp.ErrorCode != ErrCodeInviteExpired {
+			t.Errorf("Expected 403 %s after expiry, got %d %s", ErrCodeInviteExpired, status, resp.ErrorCode)
+		}
+		listed := listInvites()
+		if last := listed[len(listed)-1]; last.Status != inviteStatusExpired || len(last.UsedBy) != 2 {
+			t.Errorf("Expected the invite expired with 2 uses, got %+v", last)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			req    CreateInviteRequest
+			status int
+		}{
+			{"Too Many Uses", CreateInviteRequest{SecretCode: adminSecret, MaxUses: maxInviteUses + 1}, http.StatusBadRequest},
+			{"Negative Expiry", CreateInviteRequest{SecretCode: adminSecret, ExpiresInHours: -1}, http.StatusBadRequest},
+			{"Unknown Organization", CreateInviteRequest{SecretCode: adminSecret, OrgID: 99}, http.StatusNotFound},
+			{"Not Admin", CreateInviteRequest{SecretCode: userCode}, http.StatusForbidden},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				if status, resp := postJSON(t, srv, "/createInvite", tc.req); status != tc.status {
+					t.Errorf("Expected %d, got %d (%s)", tc.status, status, resp.Error)
+				}
+			})
+		}
+	})
+
+	t.Run("Organization Scoped", func(t *testing.T) {
+		orgInvite, _, orgAdmin := createTestOrganization(t, srv, "Acme", "admin@acme.example")
+		// An organization's own invite code still works while invites are required
+		registerInOrganization(t, srv, "Acme User", "user@acme.example", orgInvite)
+
+		status, resp := postJSON(t, srv, "/createInvite", CreateInviteRequest{SecretCode: orgAdmin})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var invite Invite
+		resp.decode(t, &invite)
+		id, _ := registerInOrganization(t, srv, "Acme Invitee", "invitee@acme.example", invite.Code)
+		storage.mutex.RLock()
+		orgID := storage.users[id].OrgID
+		storage.mutex.RUnlock()
+		if orgID != invite.OrgID || orgID == storage.defaultOrgID {
+			t.Errorf("Expected the invitee in organization %d, got %d", invite.OrgID, orgID)
+		}
+
+		_, resp = postJSON(t, srv, "/listInvites", ListInvitesRequest{SecretCode: orgAdmin})
+		var listed []Invite
+		resp.decode(t, &listed)
+		if len(listed) != 1 || listed[0].ID != invite.ID {
+			t.Errorf("Expected the organization admin to see only their invite, got %+v", listed)
+		}
+		if status, _ := postJSON(t, srv, "/createInvite", CreateInviteRequest{SecretCode: orgAdmin, OrgID: storage.defaultOrgID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 inviting into another organization, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// escalationBatchSize is how many complaints the escalation job updates per
+// write lock, so a large backlog never blocks request handlers for long
+const escalationBatchSize = 50
+
+// escalationLevelStale is the escalation level of a complaint left open for
+// config.EscalateAfterDays, the only level there is so far
+const escalationLevelStale = 1
+
+// Job is a piece of periodic background work. Run returns how many items it
+// changed and must be safe to repeat; it logs through loggerFrom(ctx).
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context, now time.Time) int
+}
+
+type JobResult struct {
+	Name     string `json:"name"`
+	Affected int    `json:"affected"`
+}
+
+type RunJobsRequest struct {
+	SecretCode string `json:"secret_code"`
+	Job        string `json:"job,omitempty"` // run only this job; all jobs when empty
+}
+
+// scheduledJobs lists every background job. Intervals are read from config,
+// so call it after parseFlags.
+func scheduledJobs() []Job {
+	return []Job{
+		{Name: "escalate_stale_complaints", Interval: config.EscalationInterval, Run: escalateStaleComplaints},
+		{Name: "purge_deleted_complaints", Interval: config.PurgeInterval, Run: purgeExpiredTrash},
+		{Name: "purge_stale_drafts", Interval: config.PurgeInterval, Run: purgeExpiredDrafts},
+		{Name: "archive_resolved_complaints", Interval: config.PurgeInterval, Run: archiveOldComplaints},
+		{Name: "flag_overdue_complaints", Interval: config.SLACheckInterval, Run: flagOverdueComplaints},
+		{Name: "confirm_pending_resolutions", Interval: config.PurgeInterval, Run: confirmPendingResolutions},
+		{Name: "enforce_retention", Interval: config.PurgeInterval, Run: enforceRetention},
+	}
+}
+
+// JobRunner runs jobs on their own tickers until stopped. Runs are
+// serialized, whether they come from a ticker or from /runJobs.
+type JobRunner struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+
+	// current is the job being run and when it started, for readiness checks
+	current      string
+	currentStart time.Time
+	stateMutex   sync.Mutex
+}
+
+func newJobRunner() *JobRunner {
+	return &JobRunner{stop: make(chan struct{})}
+}
+
+var jobRunner = newJobRunner()
+
+func (r *JobRunner) run(job Job) JobResult {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.setCurrent(job.Name, clock.Now())
+	defer r.setCurrent("", time.Time{})
+
+	ctx := withLogger(context.Background(), logger.With(logKeyJob, job.Name))
+	start := time.Now()
+	affected := job.Run(ctx, clock.Now())
+	loggerFrom(ctx).Debug("Job finished", "affected", affected, logKeyDurationMS, durationMS(time.Since(start)))
+	return JobResult{Name: job.Name, Affected: affected}
+}
+
+func (r *JobRunner) setCurrent(name string, start time.Time) {
+	r.stateMutex.Lock()
+	defer r.stateMutex.Unlock()
+	r.current, r.currentStart = name, start
+}
+
+// running reports the job in progress, if any, and how long it has run
+func (r *JobRunner) running(now time.Time) (string, time.Duration) {
+	r.stateMutex.Lock()
+	defer r.stateMutex.Unlock()
+	if r.current == "" {
+		return "", 0
+	}
+	return r.current, now.Sub(r.currentStart)
+}
+
+// start launches one ticker goroutine per job
+func (r *JobRunner) start(jobs []Job) {
+	for _, job := range jobs {
+		r.wg.Add(1)
+		go func(job Job) {
+			defer r.wg.Done()
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-r.stop:
+					return
+				case <-ticker.C:
+					// Data must not change under a migration
+					if maintenance.current().Enabled {
+						logger.Info("Skipping job during maintenance", logKeyJob, job.Name)
+						continue
+					}
+					r.run(job)
+				}
+			}
+		}(job)
+	}
+}
+
+// shutdown stops every ticker and waits for in-flight runs to finish
+func (r *JobRunner) shutdown() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	r.wg.Wait()
+}
+
+// escalateStaleComplaints flags open complaints older than
+// config.EscalateAfterDays and tells the assignee and watchers. Complaints
+// already escalated and notified are left alone, so repeated runs are
+// no-ops.
+func escalateStaleComplaints(ctx context.Context, now time.Time) int {
+	cutoff := now.AddDate(0, 0, -config.EscalateAfterDays)
+
+	storage.mutex.RLock()
+	var candidates []int
+	for id, complaint := range storage.complaints {
+		if !isOpen(complaint) || complaint.IsDeleted || escalationNotified(complaint, escalationLevelStale) {
+			continue
+		}
+		createdAt, err := parseTimestamp(complaint.CreatedAt)
+		if err == nil && !createdAt.After(cutoff) {
+			candidates = append(candidates, id)
+		}
+	}
+	storage.mutex.RUnlock()
+
+	escalated := 0
+	for start := 0; start < len(candidates); start += escalationBatchSize {
+		end := start + escalationBatchSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		escalated += escalateBatch(candidates[start:end], now)
+	}
+
+	if escalated > 0 {
+		loggerFrom(ctx).Info("Escalated stale complaints", "count", escalated)
+	}
+	return escalated
+}
+
+// escalateBatch escalates ids under a single write lock, re-checking each one
+// since it may have been resolved or deleted since it was picked
+func escalateBatch(ids []int, now time.Time) int {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	escalated := 0
+	for _, id := range ids {
+		complaint, exists := storage.complaints[id]
+		if !exists || !isOpen(complaint) || complaint.IsDeleted || escalationNotified(complaint, escalationLevelStale) {
+			continue
+		}
+		if !complaint.Escalated {
+			complaint.Escalated = true
+			complaint.EscalatedAt = getCurrentTime()
+		}
+		complaint.EscalationLevel = escalationLevelStale
+		days := 0
+		if created, err := parseTimestamp(complaint.CreatedAt); err == nil {
+			days = int(now.Sub(created).Hours() / 24)
+		}
+		addHistory(complaint, HistoryEntry{Action: historyEscalated, Detail: fmt.Sprintf("Open for %d days", days)})
+		touchComplaint(complaint)
+		notifyEscalation(complaint, days)
+		publishEvent(eventComplaintEscalated, complaint, "")
+		escalated++
+	}
+	return escalated
+}
+
+// escalationNotified reports whether complaint has reached level and
+// everyone was told about it
+func escalationNotified(complaint *Complaint, level int) bool {
+	return complaint.Escalated && complaint.EscalationLevel >= level
+}
+
+// notifyEscalation tells the assignee and watchers of complaint that it was
+// escalated after days open. The assignee's email goes out with the
+// complaint.escalated event. Callers must hold storage.mutex for writing.
+func notifyEscalation(complaint *Complaint, days int) {
+	if complaint.AssignedTo != 0 {
+		notifyUser(complaint.AssignedTo, complaint, notificationEscalated, fmt.Sprintf("A complaint assigned to you, %q, was escalated after %d days unresolved", complaint.Title, days))
+	}
+	for _, id := range complaint.Watchers {
+		if _, exists := storage.users[id]; exists && id != complaint.AssignedTo {
+			notifyUser(id, complaint, notificationEscalated, fmt.Sprintf("A complaint you are watching, %q, was escalated after %d days unresolved", complaint.Title, days))
+		}
+	}
+}
+
+// purgeExpiredTrash removes complaints deleted more than
+// config.PurgeAfterDays ago
+func purgeExpiredTrash(ctx context.Context, now time.Time) int {
+	purged := purgeDeletedComplaints(now.AddDate(0, 0, -config.PurgeAfterDays), func(*Complaint) bool { return true })
+	if len(purged) > 0 {
+		loggerFrom(ctx).Info("Purged deleted complaints", "count", len(purged), "complaint_ids", purged)
+	}
+	return len(purged)
+}
+
+// purgeExpiredDrafts removes drafts last saved more than config.DraftMaxAgeDays
+// days ago
+func purgeExpiredDrafts(ctx context.Context, now time.Time) int {
+	purged := purgeStaleDrafts(now.AddDate(0, 0, -config.DraftMaxAgeDays))
+	if purged > 0 {
+		loggerFrom(ctx).Info("Purged stale drafts", "count", purged)
+	}
+	return purged
+}
+
+// archiveOldComplaints archives complaints resolved more than
+// config.ArchiveAfterDays days ago
+func archiveOldComplaints(ctx context.Context, now time.Time) int {
+	if config.ArchiveAfterDays <= 0 {
+		return 0
+	}
+	archived := archiveResolvedComplaints(now.AddDate(0, 0, -config.ArchiveAfterDays))
+	if archived > 0 {
+		loggerFrom(ctx).Info("Archived resolved complaints", "count", archived)
+	}
+	return archived
+}
+
+// /runJobs - Run background jobs immediately (super-admin only)
+func runJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RunJobsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	var selected []Job
+	var names []string
+	for _, job := range scheduledJobs() {
+		names = append(names, job.Name)
+		if req.Job == "" || req.Job == job.Name {
+			selected = append(selected, job)
+		}
+	}
+	if len(selected) == 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, fmt.Sprintf("Job must be one of: %s", strings.Join(names, ", ")))
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	results := []JobResult{}
+	for _, job := range selected {
+		results = append(results, jobRunner.run(job))
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Jobs completed successfully",
+		Data:    results,
+	})
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEscalationJob(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local))
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Patient User", "patient@example.com")
+
+	stale := submitTestComplaint(t, srv, code, "Leaking roof", 7)
+	resolved := submitTestComplaint(t, srv, code, "Fixed quickly", 3)
+	postJSON(t, srv, "/resolveComplaint", map[string]interface{}{
+		"secret_code": adminSecret, "complaint_id": resolved.ID,
+	})
+	fake.Advance(8 * 24 * time.Hour)
+	fresh := submitTestComplaint(t, srv, code, "Just happened", 5)
+
+	runJobs := func(secret string) (int, []JobResult) {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/runJobs", map[string]string{
+			"secret_code": secret, "job": "escalate_stale_complaints",
+		})
+		var results []JobResult
+		if status == http.StatusOK {
+			resp.decode(t, &results)
+		}
+		return status, results
+	}
+
+	if status, _ := runJobs(code); status != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-admin, got %d", status)
+	}
+
+	status, results := runJobs(adminSecret)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(results) != 1 || results[0].Affected != 1 {
+		t.Errorf("Expected one escalation, got %+v", results)
+	}
+
+	_, resp := postJSON(t, srv, "/getAllComplaintsForUser", map[string]interface{}{
+		"secret_code": code, "escalated": true,
+	})
+	var escalated []Complaint
+	resp.decode(t, &escalated)
+	if len(escalated) != 1 || escalated[0].ID != stale.ID || escalated[0].EscalatedAt == "" {
+		t.Errorf("Expected only complaint %d escalated, got %+v", stale.ID, escalated)
+	}
+
+	_, resp = postJSON(t, srv, "/getAllComplaintsForAdmin", map[string]interface{}{
+		"secret_code": adminSecret, "escalated": false,
+	})
+	var notEscalated []Complaint
+	resp.decode(t, &notEscalated)
+	if len(notEscalated) != 2 || notEscalated[0].ID != resolved.ID || notEscalated[1].ID != fresh.ID {
+		t.Errorf("Expected complaints %d and %d not escalated, got %+v", resolved.ID, fresh.ID, notEscalated)
+	}
+
+	// A second run finds nothing new
+	if _, results := runJobs(adminSecret); len(results) != 1 || results[0].Affected != 0 {
+		t.Errorf("Expected rerun to escalate nothing, got %+v", results)
+	}
+
+	fake.Advance(7 * 24 * time.Hour)
+	if _, results := runJobs(adminSecret); len(results) != 1 || results[0].Affected != 1 {
+		t.Errorf("Expected the newer complaint to escalate after another week, got %+v", results)
+	}
+
+	status, resp = postJSON(t, srv, "/runJobs", map[string]string{"secret_code": adminSecret, "job": "nope"})
+	if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+		t.Errorf("Expected 400 VALIDATION_FAILED for unknown job, got %d %s", status, resp.ErrorCode)
+	}
+}
+
+func TestEscalationNotifications(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local))
+	srv := newTestServer(t)
+	mail := useFakeNotifier(t, 0)
+	config.AssignmentStrategy = assignRoundRobin
+	_, assigneeCode := registerTestAdmin(t, srv, "On Call", "oncall@example.com")
+	_, code := registerTestUser(t, srv, "Stuck User", "stuck@example.com")
+	_, watcherCode := registerTestUser(t, srv, "Watching User", "watching@example.com")
+
+	stale := submitTestComplaint(t, srv, code, "Broken lift", 6)
+	if status, resp := postJSON(t, srv, "/watchComplaint", WatchComplaintRequest{SecretCode: watcherCode, ComplaintID: stale.ID}); status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+	}
+	fake.Advance(8 * 24 * time.Hour)
+
+	escalations := func(secret string) []Notification {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/getNotifications", GetNotificationsRequest{SecretCode: secret})
+		var page NotificationPage
+		resp.decode(t, &page)
+		var found []Notification
+		for _, notification := range page.Notifications {
+			if notification.Type == notificationEscalated {
+				found = append(found, notification)
+			}
+		}
+		return found
+	}
+
+	if n := escalateStaleComplaints(context.Background(), clock.Now()); n != 1 {
+		t.Fatalf("Expected one escalation, got %d", n)
+	}
+	message := mail.wait(t)
+	if message.To != "oncall@example.com" || !strings.Contains(message.Subject, `"Broken lift" has been escalated`) {
+		t.Errorf("Expected the escalation email to the assignee, got %+v", message)
+	}
+
+	if n := escalateStaleComplaints(context.Background(), clock.Now()); n != 0 {
+		t.Errorf("Expected rerun to escalate nothing, got %d", n)
+	}
+	if pending, _ := outboxCounts(); pending != 0 {
+		t.Errorf("Expected no second email, got %d queued", pending)
+	}
+
+	if found := escalations(assigneeCode); len(found) != 1 || !strings.Contains(found[0].Message, "assigned to you") {
+		t.Errorf("Expected one notification for the assignee, got %+v", found)
+	}
+	if found := escalations(watcherCode); len(found) != 1 || found[0].ComplaintID != stale.ID {
+		t.Errorf("Expected one notification for the watcher, got %+v", found)
+	}
+	if found := escalations(code); len(found) != 0 {
+		t.Errorf("Expected the owner not to be notified, got %+v", found)
+	}
+
+	storage.mutex.RLock()
+	complaint := *storage.complaints[stale.ID]
+	storage.mutex.RUnlock()
+	var entries []HistoryEntry
+	for _, entry := range complaint.History {
+		if entry.Action == historyEscalated {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) != 1 || entries[0].Detail != "Open for 8 days" {
+		t.Errorf("Expected one escalation in the history with its age, got %+v", entries)
+	}
+	if complaint.EscalationLevel != escalationLevelStale {
+		t.Errorf("Expected escalation level %d, got %d", escalationLevelStale, complaint.EscalationLevel)
+	}
+}
+
+func TestEscalationBatches(t *testing.T) {
+	useFakeClock(t, time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local))
+	newTestServer(t)
+
+	storage.mutex.Lock()
+	for i := 1; i <= escalationBatchSize*2+5; i++ {
+		storage.complaints[i] = &Complaint{ID: i, UserID: 1, CreatedAt: "2024-04-01 09:00:00"}
+	}
+	storage.mutex.Unlock()
+
+	if n := escalateStaleComplaints(context.Background(), clock.Now()); n != escalationBatchSize*2+5 {
+		t.Errorf("Expected %d escalations, got %d", escalationBatchSize*2+5, n)
+	}
+	if n := escalateStaleComplaints(context.Background(), clock.Now()); n != 0 {
+		t.Errorf("Expected rerun to escalate nothing, got %d", n)
+	}
+}
+
+func TestJobRunnerShutdown(t *testing.T) {
+	runner := newJobRunner()
+	var runs int32
+	runner.start([]Job{{
+		Name:     "count",
+		Interval: time.Millisecond,
+		Run:      func(context.Context, time.Time) int { return int(atomic.AddInt32(&runs, 1)) },
+	}})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&runs) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	runner.shutdown()
+	runner.shutdown() // safe to call twice
+
+	stopped := atomic.LoadInt32(&runs)
+	if stopped < 3 {
+		t.Fatalf("Expected the job to run at least 3 times, ran %d", stopped)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if after := atomic.LoadInt32(&runs); after != stopped {
+		t.Errorf("Job kept running after shutdown: %d -> %d", stopped, after)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JWT mode is on when config.JWTSigningKey is set. /login then also returns
+// a signed HS256 token, and authenticate accepts that token wherever a
+// secret code is accepted. Tokens are checked from their signature and
+// claims; storage is only consulted to confirm the user still exists and has
+// not had their credentials revoked since.
+
+var (
+	errInvalidToken = errors.New("invalid token")
+	errExpiredToken = errors.New("token has expired")
+)
+
+// jwtHeader is the only header this server issues or accepts
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// tokenClaims is the payload of an issued token
+type tokenClaims struct {
+	Subject string `json:"sub"` // user ID
+	Admin   bool   `json:"adm"`
+	// Generation is the user's CredentialGeneration when the token was issued
+	Generation int   `json:"gen,omitempty"`
+	IssuedAt   int64 `json:"iat"`
+	ExpiresAt  int64 `json:"exp"`
+}
+
+// LoginResponse is the /login payload in JWT mode: the user plus a token
+type LoginResponse struct {
+	User
+	Token          string `json:"token" xml:"token"`
+	TokenExpiresAt string `json:"token_expires_at" xml:"token_expires_at"`
+}
+
+func jwtEnabled() bool {
+	return config.JWTSigningKey != ""
+}
+
+// looksLikeToken tells a JWT apart from a secret code, which never contains
+// a dot
+func looksLikeToken(credential string) bool {
+	return strings.Count(credential, ".") == 2
+}
+
+func signToken(signingInput, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issueToken signs a token for user that expires config.JWTTTL from now
+func issueToken(user *User, now time.Time) (string, time.Time) {
+	expires := now.Add(config.JWTTTL)
+	payload, _ := json.Marshal(tokenClaims{
+		Subject:    strconv.Itoa(user.ID),
+		Admin:      user.IsAdmin,
+		Generation: user.CredentialGeneration,
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  expires.Unix(),
+	})
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + signToken(signingInput, config.JWTSigningKey), expires
+}
+
+// parseToken checks token's signature against the signing key, then the
+// previous key, and its times against now with config.JWTClockSkew of
+// leeway either way
+func parseToken(token string, now time.Time) (tokenClaims, error) {
+	var claims tokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return claims, errInvalidToken
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	valid := false
+	for _, key := range []string{config.JWTSigningKey, config.JWTPreviousKey} {
+		if key != "" && hmac.Equal([]byte(parts[2]), []byte(signToken(signingInput, key))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return claims, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return claims, errInvalidToken
+	}
+	if claims.IssuedAt > now.Add(config.JWTClockSkew).Unix() {
+		return claims, errInvalidToken
+	}
+	if now.Add(-config.JWTClockSkew).Unix() >= claims.ExpiresAt {
+		return claims, errExpiredToken
+	}
+	return claims, nil
+}
+
+// revokeCredentials invalidates every token issued to user so far, for when
+// their secret code is rotated or they are deactivated. Callers must hold
+// storage.mutex and storage.usersMutex for writing, and close the user's
+// WebSockets with wsHub.closeUser once they have released them.
+func revokeCredentials(user *User) {
+	user.CredentialGeneration++
+}
+
+// userForToken returns the user token was issued to, provided they still
+// exist and neither their admin flag nor their credentials have changed
+// since
+func userForToken(token string, now time.Time) (*User, error) {
+	claims, err := parseToken(token, now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	user, exists := storage.users[id]
+	if !exists || user.IsAdmin != claims.Admin || user.CredentialGeneration != claims.Generation {
+		return nil, errInvalidToken
+	}
+	return user, nil
+}
+
+// authenticateToken is authenticate for a JWT. Bad tokens do not count
+// towards the lockout, which guards guessable secret codes.
+func authenticateToken(w http.ResponseWriter, token string) *User {
+	user, err := userForToken(token, clock.Now())
+	switch {
+	case errors.Is(err, errExpiredToken):
+		respondWithError(w, http.StatusUnauthorized, ErrCodeTokenExpired, "Token has expired")
+		return nil
+	case err != nil:
+		respondWithError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid token")
+		return nil
+	}
+	return user
+}
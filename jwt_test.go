@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWTAuthentication(t *testing.T) {
+	srv := newTestServer(t)
+	config.JWTSigningKey = "current-key"
+	fake := useFakeClock(t, time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local))
+	userID, code := registerTestUser(t, srv, "Token User", "token@example.com")
+
+	login := func(t *testing.T) LoginResponse {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: code})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var login LoginResponse
+		resp.decode(t, &login)
+		return login
+	}
+	list := func(credential string) (int, testResponse) {
+		return postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: credential})
+	}
+
+	issued := login(t)
+	if issued.ID != userID || issued.Token == "" || issued.TokenExpiresAt != "2024-05-01 10:00:00" {
+		t.Fatalf("Expected a token for user %d expiring in an hour, got %+v", userID, issued)
+	}
+	token := issued.Token
+
+	t.Run("Valid Token", func(t *testing.T) {
+		submitTestComplaint(t, srv, token, "Submitted with a token", 4)
+		status, resp := list(token)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		if len(complaints) != 1 || complaints[0].UserID != userID {
+			t.Errorf("Expected the user's complaint, got %+v", complaints)
+		}
+
+		// Secret codes keep working alongside tokens
+		if status, resp := list(code); status != http.StatusOK {
+			t.Errorf("Expected 200 with the secret code, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Tampered Signature", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		for name, tampered := range map[string]string{
+			"Signature":  parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])),
+			"Payload":    parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1","adm":true,"iat":1714546800,"exp":1999999999}`)) + "." + parts[2],
+			"Other Key":  withKey(t, "some-other-key", func() string { tok, _ := issueToken(storage.users[userID], clock.Now()); return tok }),
+			"Admin Flip": withKey(t, "current-key", func() string { tok, _ := issueToken(&User{ID: userID, IsAdmin: true}, clock.Now()); return tok }),
+		} {
+			status, resp := list(tampered)
+			if status != http.StatusUnauthorized || resp.ErrorCode != ErrCodeUnauthorized {
+				t.Errorf("%s: expected 401 UNAUTHORIZED, got %d %s", name, status, resp.ErrorCode)
+			}
+		}
+		// Bad tokens do not lock the account
+		if status, resp := list(token); status != http.StatusOK {
+			t.Errorf("Expected the real token to still work, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Cannot Log In With A Token", func(t *testing.T) {
+		status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: token})
+		if status != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", status)
+		}
+	})
+
+	t.Run("Key Rotation", func(t *testing.T) {
+		config.JWTSigningKey, config.JWTPreviousKey = "next-key", "current-key"
+		defer func() { config.JWTSigningKey, config.JWTPreviousKey = "current-key", "" }()
+
+		if status, resp := list(token); status != http.StatusOK {
+			t.Errorf("Expected a token signed with the previous key to work, got %d (%s)", status, resp.Error)
+		}
+		config.JWTPreviousKey = ""
+		if status, _ := list(token); status != http.StatusUnauthorized {
+			t.Errorf("Expected 401 once the previous key is dropped, got %d", status)
+		}
+	})
+
+	t.Run("Expiry And Clock Skew", func(t *testing.T) {
+		// Within the skew after expiry the token is still accepted
+		fake.Advance(time.Hour + 30*time.Second)
+		if status, resp := list(token); status != http.StatusOK {
+			t.Errorf("Expected 200 within the clock skew, got %d (%s)", status, resp.Error)
+		}
+		fake.Advance(time.Minute)
+		status, resp := list(token)
+		if status != http.StatusUnauthorized || resp.ErrorCode != ErrCodeTokenExpired {
+			t.Errorf("Expected 401 TOKEN_EXPIRED, got %d %s", status, resp.ErrorCode)
+		}
+
+		// A token issued slightly in the future is fine, far in the future is not
+		early, _ := issueToken(storage.users[userID], clock.Now().Add(30*time.Second))
+		if status, resp := list(early); status != http.StatusOK {
+			t.Errorf("Expected 200 for a token issued within the skew, got %d (%s)", status, resp.Error)
+		}
+		future, _ := issueToken(storage.users[userID], clock.Now().Add(10*time.Minute))
+		if status, _ := list(future); status != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for a token issued in the future, got %d", status)
+		}
+	})
+
+	t.Run("Rotated Secret Code", func(t *testing.T) {
+		leakedID, leaked := registerTestUser(t, srv, "Leaky User", "leaky@example.com")
+		status, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: leaked})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var stolen LoginResponse
+		resp.decode(t, &stolen)
+
+		status, resp = postJSON(t, srv, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: leaked})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 rotating, got %d (%s)", status, resp.Error)
+		}
+		var rotated RotateSecretCodeResponse
+		resp.decode(t, &rotated)
+
+		for name, credential := range map[string]string{"old code": leaked, "token from the old code": stolen.Token} {
+			if status, resp := list(credential); status != http.StatusUnauthorized || resp.ErrorCode != ErrCodeUnauthorized {
+				t.Errorf("Expected 401 UNAUTHORIZED for the %s, got %d %s", name, status, resp.ErrorCode)
+			}
+		}
+		// Nor does it pass the re-check of writes that authenticated before
+		// the rotation
+		storage.mutex.RLock()
+		belongs := credentialBelongsTo(stolen.Token, leakedID)
+		storage.mutex.RUnlock()
+		if belongs {
+			t.Error("Expected the token from the old code not to belong to the user any more")
+		}
+		status, resp = postJSON(t, srv, "/login", LoginRequest{SecretCode: rotated.SecretCode})
+		var fresh LoginResponse
+		resp.decode(t, &fresh)
+		if status, resp := list(fresh.Token); status != http.StatusOK {
+			t.Errorf("Expected a token from the new code to work, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Deactivated User", func(t *testing.T) {
+		suspendedID, suspended := registerTestUser(t, srv, "Suspended User", "suspended@example.com")
+		_, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: suspended})
+		var issued LoginResponse
+		resp.decode(t, &issued)
+
+		// Reactivating the user does not bring back tokens issued before
+		for _, path := range []string{"/deactivateUser", "/reactivateUser"} {
+			if status, resp := postJSON(t, srv, path, UserStatusRequest{SecretCode: adminSecret, UserID: suspendedID}); status != http.StatusOK {
+				t.Fatalf("Expected 200 from %s, got %d (%s)", path, status, resp.Error)
+			}
+		}
+		if status, _ := list(issued.Token); status != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for a token issued before deactivation, got %d", status)
+		}
+		if status, resp := list(suspended); status != http.StatusOK {
+			t.Errorf("Expected the secret code to work again, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Deleted User", func(t *testing.T) {
+		fresh := login(t).Token
+		storage.mutex.Lock()
+		delete(storage.users, userID)
+		delete(storage.secretIndex, code)
+		storage.mutex.Unlock()
+
+		status, resp := list(fresh)
+		if status != http.StatusUnauthorized || resp.ErrorCode != ErrCodeUnauthorized {
+			t.Errorf("Expected 401 UNAUTHORIZED, got %d %s", status, resp.ErrorCode)
+		}
+	})
+}
+
+// withKey runs issue with config.JWTSigningKey set to key
+func withKey(t *testing.T, key string, issue func() string) string {
+	t.Helper()
+	previous := config.JWTSigningKey
+	config.JWTSigningKey = key
+	defer func() { config.JWTSigningKey = previous }()
+	return issue()
+}
+
+func TestLoginWithoutJWT(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Plain User", "plain@example.com")
+
+	_, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: code})
+	var login LoginResponse
+	resp.decode(t, &login)
+	if login.Token != "" || login.SecretCode != code {
+		t.Errorf("Expected the plain user payload without a token, got %+v", login)
+	}
+}
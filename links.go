@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// LinkComplaintsRequest is the body of /linkComplaints and /unlinkComplaints
+type LinkComplaintsRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+	RelatedID   int    `json:"related_id"`
+}
+
+// RelatedComplaint is a linked complaint as listed on the other one
+type RelatedComplaint struct {
+	ID        int    `json:"id" xml:"id"`
+	Reference string `json:"reference" xml:"reference"`
+	Title     string `json:"title" xml:"title"`
+	Status    string `json:"status" xml:"status"`
+}
+
+func isLinked(complaint *Complaint, id int) bool {
+	for _, related := range complaint.Related {
+		if related == id {
+			return true
+		}
+	}
+	return false
+}
+
+// removeLink drops id from complaint's links, reporting whether it was there
+func removeLink(complaint *Complaint, id int) bool {
+	for i, related := range complaint.Related {
+		if related == id {
+			complaint.Related = append(complaint.Related[:i:i], complaint.Related[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// unlinkAll removes every link of complaint, from both sides, as it goes
+// to the trash. Callers must hold storage.mutex for writing.
+func unlinkAll(complaint *Complaint) {
+	for _, id := range complaint.Related {
+		if other, exists := storage.complaints[id]; exists && removeLink(other, complaint.ID) {
+			touchComplaint(other)
+		}
+	}
+	complaint.Related = nil
+}
+
+// relatedComplaints lists the complaints linked to complaint that viewer may
+// see, in ID order. Callers must hold storage.mutex.
+func relatedComplaints(viewer *User, complaint *Complaint) []RelatedComplaint {
+	var list []RelatedComplaint
+	caller := actorOf(viewer)
+	for _, id := range complaint.Related {
+		other, exists := storage.complaints[id]
+		if !exists || other.IsDeleted || !canViewComplaint(caller, other).allowed() {
+			continue
+		}
+		list = append(list, RelatedComplaint{ID: other.ID, Reference: other.Reference, Title: other.Title, Status: complaintStatus(other)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// decodeLinkRequest reads and checks a link or unlink request, writing the
+// error response when it fails
+func decodeLinkRequest(w http.ResponseWriter, r *http.Request) (LinkComplaintsRequest, *User) {
+	var req LinkComplaintsRequest
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return req, nil
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return req, nil
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return req, nil
+	}
+	if req.ComplaintID <= 0 || req.RelatedID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID and related ID are required")
+		return req, nil
+	}
+	if req.ComplaintID == req.RelatedID {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "A complaint cannot be linked to itself")
+		return req, nil
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return req, nil
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return req, nil
+	}
+	return req, user
+}
+
+// linkedPair looks up both complaints of a link request by user, which must
+// belong to the same organization. Callers must hold storage.mutex.
+func linkedPair(user *User, req LinkComplaintsRequest) (*Complaint, *Complaint, *APIError) {
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		return nil, nil, newAPIError(http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+	}
+	related := scopedComplaint(user, req.RelatedID)
+	if related == nil || related.IsDeleted {
+		return nil, nil, newAPIError(http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("Related complaint %d not found", req.RelatedID))
+	}
+	if related.OrgID != complaint.OrgID {
+		return nil, nil, newAPIError(http.StatusBadRequest, ErrCodeValidationFailed, "Complaints of different organizations cannot be linked")
+	}
+	return complaint, related, nil
+}
+
+// /linkComplaints - Mark two complaints as related without merging them
+// (admin only)
+func linkComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	req, user := decodeLinkRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint, related, apiErr := linkedPair(user, req)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	if isLinked(complaint, related.ID) {
+		respondWithError(w, http.StatusConflict, ErrCodeAlreadyLinked, "Complaints are already linked")
+		return
+	}
+
+	complaint.Related = append(complaint.Related, related.ID)
+	related.Related = append(related.Related, complaint.ID)
+	addHistory(complaint, HistoryEntry{Action: historyLinked, ActorID: user.ID, Detail: fmt.Sprintf("Linked to #%d", related.ID), CorrelationID: correlationID(r)})
+	addHistory(related, HistoryEntry{Action: historyLinked, ActorID: user.ID, Detail: fmt.Sprintf("Linked to #%d", complaint.ID), CorrelationID: correlationID(r)})
+	touchComplaint(complaint)
+	touchComplaint(related)
+
+	shaped := complaintForViewer(user, *complaint)
+	shaped.RelatedComplaints = relatedComplaints(user, complaint)
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaints linked successfully",
+		Data:    shaped,
+	})
+}
+
+// /unlinkComplaints - Remove the link between two complaints (admin only)
+func unlinkComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	req, user := decodeLinkRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint, related, apiErr := linkedPair(user, req)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	if !removeLink(complaint, related.ID) {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaints are not linked")
+		return
+	}
+	removeLink(related, complaint.ID)
+	addHistory(complaint, HistoryEntry{Action: historyUnlinked, ActorID: user.ID, Detail: fmt.Sprintf("Unlinked from #%d", related.ID), CorrelationID: correlationID(r)})
+	addHistory(related, HistoryEntry{Action: historyUnlinked, ActorID: user.ID, Detail: fmt.Sprintf("Unlinked from #%d", complaint.ID), CorrelationID: correlationID(r)})
+	touchComplaint(complaint)
+	touchComplaint(related)
+
+	shaped := complaintForViewer(user, *complaint)
+	shaped.RelatedComplaints = relatedComplaints(user, complaint)
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaints unlinked successfully",
+		Data:    shaped,
+	})
+}
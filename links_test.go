@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestLinkComplaints(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, alice := registerTestUser(t, srv, "Alice", "alice@example.com")
+	_, bob := registerTestUser(t, srv, "Bob", "bob@example.com")
+	leak := submitTestComplaint(t, srv, alice, "Water leak", 7)
+	stain := submitTestComplaint(t, srv, alice, "Stain on the ceiling", 4)
+	flood := submitTestComplaint(t, srv, bob, "Flooded basement", 9)
+
+	link := func(endpoint string, complaintID, relatedID int) (int, testResponse) {
+		return postJSON(t, srv, endpoint, LinkComplaintsRequest{SecretCode: adminSecret, ComplaintID: complaintID, RelatedID: relatedID})
+	}
+	related := func(secret string, id int) []int {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: secret, ComplaintID: id})
+		if status != http.StatusOK {
+			t.Fatalf("View %d: expected 200, got %d (%s)", id, status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		var ids []int
+		for _, other := range complaint.RelatedComplaints {
+			ids = append(ids, other.ID)
+		}
+		return ids
+	}
+
+	for _, pair := range [][2]int{{leak.ID, stain.ID}, {flood.ID, leak.ID}} {
+		if status, resp := link("/linkComplaints", pair[0], pair[1]); status != http.StatusOK {
+			t.Fatalf("Link %v: expected 200, got %d (%s)", pair, status, resp.Error)
+		}
+	}
+
+	t.Run("Symmetric", func(t *testing.T) {
+		for id, want := range map[int][]int{
+			leak.ID:  {stain.ID, flood.ID},
+			stain.ID: {leak.ID},
+			flood.ID: {leak.ID},
+		} {
+			if got := related(adminSecret, id); !reflect.DeepEqual(got, want) {
+				t.Errorf("Complaint %d: expected links %v, got %v", id, want, got)
+			}
+		}
+
+		_, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: adminSecret, ComplaintID: stain.ID})
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		want := []RelatedComplaint{{ID: leak.ID, Reference: leak.Reference, Title: "Water leak", Status: statusOpen}}
+		if !reflect.DeepEqual(complaint.RelatedComplaints, want) {
+			t.Errorf("Expected %+v, got %+v", want, complaint.RelatedComplaints)
+		}
+	})
+
+	t.Run("Owners See Only Their Own", func(t *testing.T) {
+		if got := related(alice, leak.ID); !reflect.DeepEqual(got, []int{stain.ID}) {
+			t.Errorf("Expected Alice to see only her own complaint, got %v", got)
+		}
+		if got := related(bob, flood.ID); got != nil {
+			t.Errorf("Expected Bob to see no links, got %v", got)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		for _, tc := range []struct {
+			name      string
+			secret    string
+			endpoint  string
+			complaint int
+			related   int
+			want      int
+		}{
+			{"Itself", adminSecret, "/linkComplaints", leak.ID, leak.ID, http.StatusBadRequest},
+			{"Duplicate", adminSecret, "/linkComplaints", stain.ID, leak.ID, http.StatusConflict},
+			{"Missing", adminSecret, "/linkComplaints", leak.ID, 999, http.StatusNotFound},
+			{"Not Linked", adminSecret, "/unlinkComplaints", stain.ID, flood.ID, http.StatusNotFound},
+			{"Not Admin", alice, "/linkComplaints", stain.ID, flood.ID, http.StatusForbidden},
+		} {
+			status, _ := postJSON(t, srv, tc.endpoint, LinkComplaintsRequest{SecretCode: tc.secret, ComplaintID: tc.complaint, RelatedID: tc.related})
+			if status != tc.want {
+				t.Errorf("%s: expected %d, got %d", tc.name, tc.want, status)
+			}
+		}
+	})
+
+	t.Run("Unlink", func(t *testing.T) {
+		if status, resp := link("/unlinkComplaints", stain.ID, leak.ID); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if got := related(adminSecret, leak.ID); !reflect.DeepEqual(got, []int{flood.ID}) {
+			t.Errorf("Expected only the flood link left, got %v", got)
+		}
+		if got := related(adminSecret, stain.ID); got != nil {
+			t.Errorf("Expected no links on the stain, got %v", got)
+		}
+	})
+
+	t.Run("Cleanup On Delete", func(t *testing.T) {
+		link("/linkComplaints", stain.ID, flood.ID)
+		if status, _ := postJSON(t, srv, "/deleteComplaint", DeleteComplaintRequest{SecretCode: adminSecret, ComplaintID: flood.ID}); status != http.StatusOK {
+			t.Fatalf("Expected the delete to succeed, got %d", status)
+		}
+		for _, id := range []int{leak.ID, stain.ID} {
+			if got := related(adminSecret, id); got != nil {
+				t.Errorf("Complaint %d: expected the deleted complaint unlinked, got %v", id, got)
+			}
+		}
+		storage.mutex.RLock()
+		left := storage.complaints[flood.ID].Related
+		storage.mutex.RUnlock()
+		if left != nil {
+			t.Errorf("Expected the deleted complaint to keep no links, got %v", left)
+		}
+	})
+}
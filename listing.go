@@ -0,0 +1,347 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Complaint status filter values
+const (
+	statusOpen     = "open"
+	statusResolved = "resolved"
+	statusMerged   = "merged" // folded into another complaint, awaiting its resolution
+
+	statusPendingConfirmation = "pending_confirmation" // resolved, awaiting the owner's confirmation
+)
+
+// Sort keys accepted by the listing endpoints
+const (
+	sortOldest     = "oldest"
+	sortNewest     = "newest"
+	sortRatingDesc = "rating_desc"
+	sortRatingAsc  = "rating_asc"
+	sortPriority   = "priority" // most urgent first
+)
+
+// ComplaintPage is the envelope returned by the listing endpoints when a
+// page or page_size is requested
+type ComplaintPage struct {
+	Complaints []Complaint `json:"complaints" xml:"complaints>complaint"`
+	Page       int         `json:"page" xml:"page"`
+	PageSize   int         `json:"page_size" xml:"page_size"`
+	TotalCount int         `json:"total_count" xml:"total_count"`
+	TotalPages int         `json:"total_pages" xml:"total_pages"`
+}
+
+// CursorPage is the envelope returned by the listing endpoints when a cursor
+// is given. NextCursor is empty on the last page.
+type CursorPage struct {
+	Complaints []Complaint `json:"complaints" xml:"complaints>complaint"`
+	PageSize   int         `json:"page_size" xml:"page_size"`
+	NextCursor string      `json:"next_cursor,omitempty" xml:"next_cursor,omitempty"`
+}
+
+// listOptions is the validated form of the listing fields of GetComplaintsRequest
+type listOptions struct {
+	status     string
+	priority   string
+	escalated  *bool
+	overdue    *bool
+	now        time.Time // overdue is judged against this
+	tags       []string
+	department string
+	building   string
+	floor      string
+	source     string
+	from, to   string // creation dates, YYYY-MM-DD in UTC; empty for open-ended
+	sort       string
+	page       int
+	pageSize   int
+	paginate   bool
+	useCursor  bool          // return a CursorPage
+	cursor     *listPosition // resume after this; nil for the first page
+	fields     []string      // complaint fields to return; nil for all
+	full       bool          // summaries whole, not cut to config.ListSummaryLength
+
+	includeArchived bool
+	pinnedFirst     bool // pinned complaints before the rest, whatever the sort
+}
+
+func parseListOptions(req GetComplaintsRequest) (listOptions, error) {
+	opts := listOptions{
+		status:    req.Status,
+		escalated: req.Escalated,
+		overdue:   req.Overdue,
+		now:       clock.Now(),
+		priority:  req.Priority,
+		sort:      req.Sort,
+		full:      req.Full,
+
+		includeArchived: req.IncludeArchived,
+	}
+	opts.department = strings.TrimSpace(req.Department)
+	opts.building = strings.TrimSpace(req.Building)
+	opts.floor = strings.TrimSpace(req.Floor)
+	opts.source = strings.ToLower(strings.TrimSpace(req.Source))
+
+	switch opts.status {
+	case "", statusOpen, statusResolved, statusMerged, statusPendingConfirmation:
+	default:
+		return opts, fmt.Errorf("Status must be one of: %s, %s, %s, %s", statusOpen, statusResolved, statusMerged, statusPendingConfirmation)
+	}
+
+	if opts.priority != "" {
+		if err := validatePriority(opts.priority); err != nil {
+			return opts, err
+		}
+	}
+
+	if opts.source != "" && !containsString(complaintSources, opts.source) {
+		return opts, fmt.Errorf("Source must be one of: %s", strings.Join(complaintSources, ", "))
+	}
+
+	var err error
+	if opts.tags, err = normalizeTags(req.Tags); err != nil {
+		return opts, err
+	}
+
+	if opts.from, opts.to, err = parseDateRange(req.CreatedFrom, req.CreatedTo); err != nil {
+		return opts, err
+	}
+
+	switch opts.sort {
+	case "":
+		opts.sort = sortOldest
+	case sortOldest, sortNewest, sortRatingDesc, sortRatingAsc, sortPriority:
+	default:
+		return opts, fmt.Errorf("Sort must be one of: %s, %s, %s, %s, %s", sortOldest, sortNewest, sortRatingDesc, sortRatingAsc, sortPriority)
+	}
+
+	if opts.fields, err = parseFields(req.Fields); err != nil {
+		return opts, err
+	}
+
+	opts.page, opts.pageSize, opts.paginate, err = parsePage(req.Page, req.PageSize)
+	if err != nil || req.Cursor == nil {
+		return opts, err
+	}
+	if req.Page != nil {
+		return opts, errors.New("Cursor cannot be combined with page")
+	}
+	opts.paginate, opts.useCursor = false, true
+	opts.cursor, err = decodeCursor(*req.Cursor, opts.sort)
+	return opts, err
+}
+
+// parseDateRange validates optional created_from and created_to dates
+func parseDateRange(from, to string) (string, string, error) {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from != "" {
+		if _, err := time.Parse(dateLayout, from); err != nil {
+			return from, to, errors.New("Created from must be a date in YYYY-MM-DD format")
+		}
+	}
+	if to != "" {
+		if _, err := time.Parse(dateLayout, to); err != nil {
+			return from, to, errors.New("Created to must be a date in YYYY-MM-DD format")
+		}
+	}
+	// Dates in this layout order the same as strings
+	if from != "" && to != "" && from > to {
+		return from, to, errors.New("Created from must not be after created to")
+	}
+	return from, to, nil
+}
+
+// parsePage validates optional page and page_size fields, applying the
+// defaults. paginate reports whether either was given.
+func parsePage(page, pageSize *int) (int, int, bool, error) {
+	p, size, paginate := 1, defaultPageSize, false
+	if page != nil {
+		if *page < 1 {
+			return p, size, paginate, errors.New("Page must be 1 or greater")
+		}
+		p = *page
+		paginate = true
+	}
+	if pageSize != nil {
+		if *pageSize < 1 || *pageSize > maxPageSize {
+			return p, size, paginate, fmt.Errorf("Page size must be between 1 and %d", maxPageSize)
+		}
+		size = *pageSize
+		paginate = true
+	}
+	return p, size, paginate, nil
+}
+
+// pageBounds returns the slice bounds of a page over total items and the
+// number of pages
+func pageBounds(total, page, pageSize int) (start, end, totalPages int) {
+	start = (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end = start + pageSize
+	if end > total {
+		end = total
+	}
+	return start, end, (total + pageSize - 1) / pageSize
+}
+
+func (o listOptions) matches(c *Complaint) bool {
+	if o.escalated != nil && c.Escalated != *o.escalated {
+		return false
+	}
+	if o.overdue != nil && isOverdue(c, o.now) != *o.overdue {
+		return false
+	}
+	if o.priority != "" && c.Priority != o.priority {
+		return false
+	}
+	if o.department != "" && !strings.EqualFold(c.Department, o.department) {
+		return false
+	}
+	if o.source != "" && c.Source != o.source {
+		return false
+	}
+	if o.building != "" && !strings.EqualFold(c.Building, o.building) {
+		return false
+	}
+	if o.floor != "" && !strings.EqualFold(c.Floor, o.floor) {
+		return false
+	}
+	if !hasTags(c, o.tags) {
+		return false
+	}
+	if o.from != "" || o.to != "" {
+		created, err := parseTimestamp(c.CreatedAt)
+		if err != nil {
+			return false
+		}
+		day := created.UTC().Format(dateLayout)
+		if (o.from != "" && day < o.from) || (o.to != "" && day > o.to) {
+			return false
+		}
+	}
+	return o.status == "" || complaintStatus(c) == o.status
+}
+
+// sortComplaints orders list by key, breaking ties by ID so pages are stable
+func sortComplaints(list []Complaint, key string) {
+	sort.Slice(list, func(i, j int) bool {
+		return positionOf(&list[i], key).before(positionOf(&list[j], key))
+	})
+}
+
+// position is where complaint sits in the order o asks for
+func (o listOptions) position(complaint *Complaint) listPosition {
+	position := positionOf(complaint, o.sort)
+	if o.pinnedFirst {
+		position.Pinned = pinnedAtUnix(complaint)
+	}
+	return position
+}
+
+// filterComplaints returns the complaints of viewer's organization matching
+// include and opts, sorted and shaped for viewer. Archived complaints are
+// left out unless opts asks for them. Callers must hold storage.mutex for
+// reading.
+func filterComplaints(viewer *User, include func(*Complaint) bool, opts listOptions) []Complaint {
+	var list []Complaint
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted || (isArchived(complaint) && !opts.includeArchived) || !canSeeOrg(viewer, complaint.OrgID) {
+			continue
+		}
+		if include(complaint) && opts.matches(complaint) {
+			list = append(list, complaintForViewer(viewer, *complaint))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return opts.position(&list[i]).before(opts.position(&list[j]))
+	})
+	return list
+}
+
+// listComplaints is filterComplaints plus, when requested, cutting out a
+// single page. Callers must hold storage.mutex for reading.
+func listComplaints(viewer *User, include func(*Complaint) bool, opts listOptions) interface{} {
+	list := filterComplaints(viewer, include, opts)
+	if !opts.full {
+		for i := range list {
+			list[i].Summary, list[i].SummaryTruncated = truncateSummary(list[i].Summary, config.ListSummaryLength)
+		}
+	}
+
+	var result interface{} = list
+	if opts.useCursor {
+		result = cursorPage(list, opts)
+	} else if opts.paginate {
+		start, end, totalPages := pageBounds(len(list), opts.page, opts.pageSize)
+		result = ComplaintPage{
+			Complaints: append([]Complaint{}, list[start:end]...),
+			Page:       opts.page,
+			PageSize:   opts.pageSize,
+			TotalCount: len(list),
+			TotalPages: totalPages,
+		}
+	}
+
+	if opts.fields != nil {
+		return projectListing(result, opts.fields)
+	}
+	return result
+}
+
+// truncateSummary cuts summary to at most limit runes, ellipsis included,
+// at the last word boundary when that keeps at least half of it. It reports
+// whether it cut anything; a limit of 0 or less keeps every summary whole.
+func truncateSummary(summary string, limit int) (string, bool) {
+	if limit <= 0 || utf8.RuneCountInString(summary) <= limit {
+		return summary, false
+	}
+	// The byte offset of rune limit-1, leaving room for the ellipsis
+	cut := 0
+	for i := 0; i < limit-1; i++ {
+		_, size := utf8.DecodeRuneInString(summary[cut:])
+		cut += size
+	}
+	text := summary[:cut]
+	if next, _ := utf8.DecodeRuneInString(summary[cut:]); !unicode.IsSpace(next) {
+		if space := strings.LastIndexFunc(text, unicode.IsSpace); space >= len(text)/2 {
+			text = text[:space]
+		}
+	}
+	return strings.TrimRightFunc(text, unicode.IsSpace) + "…", true
+}
+
+// cursorPage cuts the page after opts.cursor out of the sorted list. The
+// walk resumes from the cursor's position rather than an offset, so
+// complaints added or removed meanwhile never cause repeats or gaps.
+func cursorPage(list []Complaint, opts listOptions) CursorPage {
+	start := 0
+	if opts.cursor != nil {
+		start = sort.Search(len(list), func(i int) bool {
+			return opts.cursor.before(opts.position(&list[i]))
+		})
+	}
+	end := start + opts.pageSize
+	if end > len(list) {
+		end = len(list)
+	}
+
+	page := CursorPage{Complaints: append([]Complaint{}, list[start:end]...), PageSize: opts.pageSize}
+	if end < len(list) {
+		page.NextCursor = encodeCursor(opts.position(&list[end-1]))
+	}
+	return page
+}
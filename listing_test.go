@@ -0,0 +1,414 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestUserComplaintPagination(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Power User", "power@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+
+	for i := 1; i <= 60; i++ {
+		submitTestComplaint(t, srv, code, fmt.Sprintf("Complaint %d", i), i%10+1)
+	}
+	submitTestComplaint(t, srv, otherCode, "Not mine", 5)
+
+	seen := make(map[int]bool)
+	lastID := 0
+	for page := 1; page <= 3; page++ {
+		status, resp := postJSON(t, srv, "/getAllComplaintsForUser", map[string]interface{}{
+			"secret_code": code, "page": page, "page_size": 20,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Page %d: expected status 200, got %d (%s)", page, status, resp.Error)
+		}
+
+		var result ComplaintPage
+		resp.decode(t, &result)
+		if result.TotalCount != 60 || result.TotalPages != 3 || result.Page != page || result.PageSize != 20 {
+			t.Errorf("Page %d: unexpected envelope %+v", page, result)
+		}
+		if len(result.Complaints) != 20 {
+			t.Fatalf("Page %d: expected 20 complaints, got %d", page, len(result.Complaints))
+		}
+		for _, c := range result.Complaints {
+			if seen[c.ID] {
+				t.Errorf("Complaint %d returned twice", c.ID)
+			}
+			if c.ID <= lastID {
+				t.Errorf("Complaint %d out of order after %d", c.ID, lastID)
+			}
+			seen[c.ID] = true
+			lastID = c.ID
+		}
+	}
+	if len(seen) != 60 {
+		t.Errorf("Expected 60 distinct complaints across pages, got %d", len(seen))
+	}
+
+	t.Run("Past Last Page", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/getAllComplaintsForUser", map[string]interface{}{
+			"secret_code": code, "page": 4, "page_size": 20,
+		})
+		var result ComplaintPage
+		resp.decode(t, &result)
+		if len(result.Complaints) != 0 || result.TotalCount != 60 {
+			t.Errorf("Expected empty page with total 60, got %d items, total %d", len(result.Complaints), result.TotalCount)
+		}
+	})
+}
+
+func TestComplaintListingFilters(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Filter User", "filter@example.com")
+
+	var ids []int
+	for i, rating := range []int{3, 9, 6, 9} {
+		ids = append(ids, submitTestComplaint(t, srv, code, fmt.Sprintf("Complaint %d", i), rating).ID)
+	}
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: ids[1]})
+
+	listIDs := func(t *testing.T, endpoint string, payload map[string]interface{}) []int {
+		t.Helper()
+		status, resp := postJSON(t, srv, endpoint, payload)
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+		}
+		var result ComplaintPage
+		resp.decode(t, &result)
+		var got []int
+		for _, c := range result.Complaints {
+			got = append(got, c.ID)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+		want    []int
+	}{
+		{"Open Only", map[string]interface{}{"status": "open"}, []int{ids[0], ids[2], ids[3]}},
+		{"Resolved Only", map[string]interface{}{"status": "resolved"}, []int{ids[1]}},
+		{"Newest First", map[string]interface{}{"sort": "newest"}, []int{ids[3], ids[2], ids[1], ids[0]}},
+		{"Highest Rating First", map[string]interface{}{"sort": "rating_desc"}, []int{ids[1], ids[3], ids[2], ids[0]}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, endpoint := range []string{"/getAllComplaintsForUser", "/getAllComplaintsForAdmin"} {
+				payload := map[string]interface{}{"page": 1}
+				for k, v := range tc.payload {
+					payload[k] = v
+				}
+				payload["secret_code"] = code
+				if endpoint == "/getAllComplaintsForAdmin" {
+					payload["secret_code"] = adminSecret
+				}
+				if got := listIDs(t, endpoint, payload); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+					t.Errorf("%s: expected %v, got %v", endpoint, tc.want, got)
+				}
+			}
+		})
+	}
+
+	invalid := []map[string]interface{}{
+		{"page_size": 0},
+		{"page_size": -5},
+		{"page_size": maxPageSize + 1},
+		{"page": 0},
+		{"status": "pending"},
+		{"sort": "alphabetical"},
+	}
+	for _, payload := range invalid {
+		payload["secret_code"] = code
+		status, resp := postJSON(t, srv, "/getAllComplaintsForUser", payload)
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("%v: expected 400 VALIDATION_FAILED, got %d %s", payload, status, resp.ErrorCode)
+		}
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Cursor User", "cursor@example.com")
+	for i := 0; i < 12; i++ {
+		submitTestComplaint(t, srv, code, fmt.Sprintf("Complaint %d", i), 1+i%10)
+	}
+
+	fetch := func(payload map[string]interface{}) CursorPage {
+		t.Helper()
+		payload["secret_code"] = adminSecret
+		status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", payload)
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+		}
+		var page CursorPage
+		resp.decode(t, &page)
+		return page
+	}
+
+	// walk follows next_cursor to the end, submitting three complaints after
+	// the first page, and returns every ID seen
+	walk := func(sortKey string) []int {
+		t.Helper()
+		var ids []int
+		cursor := ""
+		for pages := 0; ; pages++ {
+			page := fetch(map[string]interface{}{"sort": sortKey, "page_size": 5, "cursor": cursor})
+			for _, c := range page.Complaints {
+				ids = append(ids, c.ID)
+			}
+			if page.NextCursor == "" {
+				return ids
+			}
+			if pages == 0 {
+				for i := 0; i < 3; i++ {
+					submitTestComplaint(t, srv, code, fmt.Sprintf("Mid-walk %s %d", sortKey, i), 10)
+				}
+			}
+			cursor = page.NextCursor
+		}
+	}
+
+	t.Run("Inserts During An ID Walk", func(t *testing.T) {
+		before := len(fetch(map[string]interface{}{"cursor": "", "page_size": maxPageSize}).Complaints)
+		ids := walk(sortOldest)
+		// Newer complaints sort after the cursor, so the walk picks them up
+		if len(ids) != before+3 {
+			t.Fatalf("Expected %d complaints, got %d: %v", before+3, len(ids), ids)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Fatalf("Expected strictly increasing IDs with no repeats, got %v", ids)
+			}
+		}
+	})
+
+	t.Run("Inserts During A Rating Walk", func(t *testing.T) {
+		existing := fetch(map[string]interface{}{"cursor": "", "page_size": maxPageSize}).Complaints
+		ids := walk(sortRatingAsc)
+		seen := map[int]int{}
+		for _, id := range ids {
+			seen[id]++
+			if seen[id] > 1 {
+				t.Fatalf("Complaint %d returned twice: %v", id, ids)
+			}
+		}
+		for _, c := range existing {
+			if seen[c.ID] == 0 {
+				t.Errorf("Complaint %d missing from the walk", c.ID)
+			}
+		}
+	})
+
+	t.Run("Invalid Cursors", func(t *testing.T) {
+		first := fetch(map[string]interface{}{"cursor": "", "page_size": 2})
+		payload, signature, _ := strings.Cut(first.NextCursor, ".")
+		forged := base64.RawURLEncoding.EncodeToString([]byte(`{"s":"oldest","i":1}`))
+
+		for name, payload := range map[string]map[string]interface{}{
+			"Tampered":       {"cursor": forged + "." + signature},
+			"Unsigned":       {"cursor": payload},
+			"Garbage":        {"cursor": "not-a-cursor"},
+			"Different Sort": {"cursor": first.NextCursor, "sort": sortNewest},
+			"With Page":      {"cursor": first.NextCursor, "page": 2},
+		} {
+			payload["secret_code"] = adminSecret
+			status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", payload)
+			if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+				t.Errorf("%s: expected 400 VALIDATION_FAILED, got %d %s", name, status, resp.ErrorCode)
+			}
+		}
+	})
+}
+
+func TestListingFields(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Fields User", "fields@example.com")
+	complaint := submitTestComplaint(t, srv, code, "Projector flickers", 6)
+	postJSON(t, srv, "/addAdminNote", AddAdminNoteRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Note: "Bulb ordered"})
+
+	// keys lists the complaints of a listing response as raw key sets
+	keys := func(t *testing.T, endpoint string, payload map[string]interface{}) []map[string]json.RawMessage {
+		t.Helper()
+		status, resp := postJSON(t, srv, endpoint, payload)
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+		}
+		var complaints []map[string]json.RawMessage
+		if _, paged := payload["page"]; paged {
+			var page struct {
+				Complaints []map[string]json.RawMessage `json:"complaints"`
+				TotalCount int                          `json:"total_count"`
+			}
+			resp.decode(t, &page)
+			if page.TotalCount != 1 {
+				t.Errorf("Expected the page envelope kept, got total_count %d", page.TotalCount)
+			}
+			complaints = page.Complaints
+		} else {
+			resp.decode(t, &complaints)
+		}
+		if len(complaints) != 1 {
+			t.Fatalf("Expected 1 complaint, got %d", len(complaints))
+		}
+		return complaints
+	}
+	assertKeys := func(t *testing.T, got map[string]json.RawMessage, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("Expected keys %v, got %d keys: %v", want, len(got), got)
+		}
+		for _, key := range want {
+			if _, exists := got[key]; !exists {
+				t.Errorf("Expected key %q in %v", key, got)
+			}
+		}
+	}
+
+	t.Run("Admin Table View", func(t *testing.T) {
+		got := keys(t, "/getAllComplaintsForAdmin", map[string]interface{}{"secret_code": adminSecret, "fields": "title, status,created_at"})
+		assertKeys(t, got[0], "id", "title", "status", "created_at")
+		if string(got[0]["status"]) != `"open"` {
+			t.Errorf("Expected derived status kept, got %s", got[0]["status"])
+		}
+	})
+
+	t.Run("Paged", func(t *testing.T) {
+		got := keys(t, "/getAllComplaintsForAdmin", map[string]interface{}{"secret_code": adminSecret, "fields": "admin_notes", "page": 1})
+		assertKeys(t, got[0], "id", "admin_notes")
+	})
+
+	t.Run("Redaction Still Applies", func(t *testing.T) {
+		got := keys(t, "/getAllComplaintsForUser", map[string]interface{}{"secret_code": code, "fields": "title,admin_notes"})
+		assertKeys(t, got[0], "id", "title")
+	})
+
+	t.Run("Unknown Field", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", map[string]interface{}{"secret_code": adminSecret, "fields": "title,secret_code"})
+		if status != http.StatusBadRequest || !strings.Contains(resp.Error, "secret_code") || !strings.Contains(resp.Error, "created_at") {
+			t.Errorf("Expected 400 naming the unknown and valid fields, got %d %q", status, resp.Error)
+		}
+	})
+}
+
+func TestTruncateSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string
+		limit   int
+		want    string
+	}{
+		{"Short", "The tap drips", 20, "The tap drips"},
+		{"Exactly At Limit", "The tap drips", 13, "The tap drips"},
+		{"Word Boundary", "The tap in the kitchen drips all night", 20, "The tap in the…"},
+		{"Cut On A Space", "The tap drips all night", 14, "The tap drips…"},
+		{"No Spaces", strings.Repeat("a", 30), 10, strings.Repeat("a", 9) + "…"},
+		{"Emoji Straddling The Cut", strings.Repeat("😡", 30), 10, strings.Repeat("😡", 9) + "…"},
+		{"Accents Straddling The Cut", "Caf" + strings.Repeat("é", 20), 6, "Caféé…"},
+		{"Mixed Widths", "ab 名前😡é " + strings.Repeat("x", 20), 9, "ab 名前😡é…"},
+		{"Disabled", strings.Repeat("a", 300), 0, strings.Repeat("a", 300)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, truncated := truncateSummary(tc.summary, tc.limit)
+			if got != tc.want || truncated != (got != tc.summary) {
+				t.Errorf("Expected %q, got %q (truncated %v)", tc.want, got, truncated)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Expected valid UTF-8, got %q", got)
+			}
+			if tc.limit > 0 && utf8.RuneCountInString(got) > tc.limit {
+				t.Errorf("Expected at most %d characters, got %d", tc.limit, utf8.RuneCountInString(got))
+			}
+		})
+	}
+}
+
+func TestListingSummaryTruncation(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Wordy User", "wordy@example.com")
+	// A multi-byte character straddles the cut at 280
+	long := strings.Repeat("word ", 10) + strings.Repeat("😡", 300)
+	submitted := submitTestComplaint(t, srv, code, "Long story", 5)
+	storage.mutex.Lock()
+	storage.complaints[submitted.ID].Summary = long
+	storage.mutex.Unlock()
+	short := submitTestComplaint(t, srv, code, "Short story", 5)
+
+	list := func(endpoint string, req GetComplaintsRequest) map[int]Complaint {
+		t.Helper()
+		status, resp := postJSON(t, srv, endpoint, req)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		byID := map[int]Complaint{}
+		for _, complaint := range complaints {
+			byID[complaint.ID] = complaint
+		}
+		return byID
+	}
+
+	for _, endpoint := range []string{"/getAllComplaintsForUser", "/getAllComplaintsForAdmin"} {
+		secret := code
+		if endpoint == "/getAllComplaintsForAdmin" {
+			secret = adminSecret
+		}
+		t.Run(endpoint, func(t *testing.T) {
+			listed := list(endpoint, GetComplaintsRequest{SecretCode: secret})
+			cut := listed[submitted.ID]
+			if !cut.SummaryTruncated || !utf8.ValidString(cut.Summary) || utf8.RuneCountInString(cut.Summary) > config.ListSummaryLength {
+				t.Errorf("Expected the summary cut to %d characters, got %d (truncated %v)", config.ListSummaryLength, utf8.RuneCountInString(cut.Summary), cut.SummaryTruncated)
+			}
+			if !strings.HasSuffix(cut.Summary, "…") || !strings.HasPrefix(long, strings.TrimSuffix(cut.Summary, "…")) {
+				t.Errorf("Expected a prefix of the summary and an ellipsis, got %q", cut.Summary)
+			}
+			if whole := listed[short.ID]; whole.SummaryTruncated || whole.Summary != short.Summary {
+				t.Errorf("Expected a short summary left whole, got %+v", whole)
+			}
+
+			full := list(endpoint, GetComplaintsRequest{SecretCode: secret, Full: true})[submitted.ID]
+			if full.SummaryTruncated || full.Summary != long {
+				t.Errorf("Expected full=true to send the whole summary, got %d characters", utf8.RuneCountInString(full.Summary))
+			}
+		})
+	}
+
+	t.Run("View Is Whole", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: code, ComplaintID: submitted.ID})
+		var viewed Complaint
+		resp.decode(t, &viewed)
+		if viewed.Summary != long || viewed.SummaryTruncated {
+			t.Errorf("Expected the full summary, got %d characters", utf8.RuneCountInString(viewed.Summary))
+		}
+		storage.mutex.RLock()
+		stored := *storage.complaints[submitted.ID]
+		storage.mutex.RUnlock()
+		if stored.Summary != long || stored.SummaryTruncated {
+			t.Error("Expected the stored complaint untouched by listings")
+		}
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: code, Fields: "summary"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var projected []map[string]interface{}
+		resp.decode(t, &projected)
+		if projected[0]["summary_truncated"] != true {
+			t.Errorf("Expected the truncation flag with the summary, got %v", projected[0])
+		}
+	})
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Limits on locations; lengths are in runes
+const (
+	maxLocationLength = 50 // building, floor and room alike
+	maxFloors         = 200
+)
+
+// Building is an entry of an organization's location registry. Once an
+// organization has registered a building, its complaints may only name
+// registered buildings, and one of a building's floors when it lists any.
+// Without a registry, locations are free-form.
+type Building struct {
+	ID        int      `json:"id"`
+	OrgID     int      `json:"org_id"`
+	Name      string   `json:"name"`
+	Floors    []string `json:"floors,omitempty"` // any floor is accepted when empty
+	CreatedBy int      `json:"created_by"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type AddBuildingRequest struct {
+	SecretCode string   `json:"secret_code"`
+	Name       string   `json:"name"`
+	Floors     []string `json:"floors,omitempty"`
+	OrgID      int      `json:"org_id,omitempty"` // super-admins only; the caller's organization when left out
+}
+
+type ListBuildingsRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+type UpdateBuildingRequest struct {
+	SecretCode string   `json:"secret_code"`
+	BuildingID int      `json:"building_id"`
+	Name       string   `json:"name,omitempty"`
+	Floors     []string `json:"floors"` // replaces the floors when given; [] accepts any floor
+}
+
+type DeleteBuildingRequest struct {
+	SecretCode string `json:"secret_code"`
+	BuildingID int    `json:"building_id"`
+}
+
+// BuildingCount is the number of complaints about one building
+type BuildingCount struct {
+	Building string `json:"building"`
+	Open     int    `json:"open"`    // all open complaints, regardless of range
+	Overdue  int    `json:"overdue"` // open complaints past their deadline
+	Created  int    `json:"created"` // complaints created in range
+}
+
+// normalizeFloors sanitizes floors, dropping blanks and duplicates, ignoring
+// case, but keeping the order
+func normalizeFloors(floors []string) ([]string, error) {
+	normalized := []string{}
+	for _, floor := range floors {
+		floor = sanitizeText(floor, false)
+		if floor == "" || containsFold(normalized, floor) {
+			continue
+		}
+		if err := checkLength("Each floor", floor, maxLocationLength); err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, floor)
+	}
+	if len(normalized) > maxFloors {
+		return nil, fmt.Errorf("At most %d floors are allowed", maxFloors)
+	}
+	return normalized, nil
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildingsOf lists orgID's registered buildings by name. Callers must hold
+// storage.mutex.
+func buildingsOf(orgID int) []*Building {
+	list := []*Building{}
+	for _, building := range storage.buildings {
+		if building.OrgID == orgID {
+			list = append(list, building)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if a, b := strings.ToLower(list[i].Name), strings.ToLower(list[j].Name); a != b {
+			return a < b
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// findBuilding looks one of orgID's buildings up by name, ignoring case.
+// Callers must hold storage.mutex.
+func findBuilding(orgID int, name string) *Building {
+	for _, building := range storage.buildings {
+		if building.OrgID == orgID && strings.EqualFold(building.Name, name) {
+			return building
+		}
+	}
+	return nil
+}
+
+// checkLocation validates a complaint's building and floor against orgID's
+// registry and returns them as registered. Anything goes when the
+// organization has no registry. Callers must hold storage.mutex.
+func checkLocation(orgID int, building, floor string) (string, string, *APIError) {
+	registered := buildingsOf(orgID)
+	if len(registered) == 0 || building == "" {
+		return building, floor, nil
+	}
+
+	var v validator
+	match := findBuilding(orgID, building)
+	if match == nil {
+		names := make([]string, len(registered))
+		for i, known := range registered {
+			names[i] = known.Name
+		}
+		v.add("building", "must be one of: "+strings.Join(names, ", "))
+		return building, floor, v.err()
+	}
+	if floor == "" || len(match.Floors) == 0 {
+		return match.Name, floor, nil
+	}
+	for _, known := range match.Floors {
+		if strings.EqualFold(known, floor) {
+			return match.Name, known, nil
+		}
+	}
+	v.add("floor", fmt.Sprintf("must be one of the floors of %s: %s", match.Name, strings.Join(match.Floors, ", ")))
+	return building, floor, v.err()
+}
+
+// /addBuilding - Register a building in an organization's location registry
+// (admin only)
+func addBuildingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req AddBuildingRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	name := sanitizeText(req.Name, false)
+	floors, err := normalizeFloors(req.Floors)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.required("name", name)
+	v.maxRunes("name", name, maxLocationLength)
+	v.check("floors", err)
+	if req.OrgID < 0 {
+		v.add("org_id", "must not be negative")
+	}
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	orgID, apiErr := ruleOrganization(user, req.OrgID)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+	if findBuilding(orgID, name) != nil {
+		respondWithError(w, http.StatusConflict, ErrCodeBuildingExists, "A building with this name already exists")
+		return
+	}
+
+	storage.buildingIDGen++
+	building := &Building{
+		ID:        storage.buildingIDGen,
+		OrgID:     orgID,
+		Name:      name,
+		Floors:    floors,
+		CreatedBy: user.ID,
+		CreatedAt: getCurrentTime(),
+	}
+	storage.buildings[building.ID] = building
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Building added successfully",
+		Data:    *building,
+	})
+}
+
+// /listBuildings - The location registry of the caller's organization, by
+// name (admin only)
+func listBuildingsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ListBuildingsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	buildings := []Building{}
+	for _, building := range storage.buildings {
+		if canSeeOrg(user, building.OrgID) {
+			buildings = append(buildings, *building)
+		}
+	}
+	sort.Slice(buildings, func(i, j int) bool {
+		if buildings[i].OrgID != buildings[j].OrgID {
+			return buildings[i].OrgID < buildings[j].OrgID
+		}
+		if a, b := strings.ToLower(buildings[i].Name), strings.ToLower(buildings[j].Name); a != b {
+			return a < b
+		}
+		return buildings[i].ID < buildings[j].ID
+	})
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Buildings retrieved successfully",
+		Data:    buildings,
+	})
+}
+
+// /updateBuilding - Rename a building or replace its floors (admin only).
+// Complaints already filed keep the location they were submitted with.
+func updateBuildingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req UpdateBuildingRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	name := sanitizeText(req.Name, false)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("building_id", req.BuildingID)
+	v.maxRunes("name", name, maxLocationLength)
+	var floors []string
+	if req.Floors != nil {
+		var err error
+		floors, err = normalizeFloors(req.Floors)
+		v.check("floors", err)
+	}
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	building, exists := storage.buildings[req.BuildingID]
+	if !exists || !canSeeOrg(user, building.OrgID) {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Building not found")
+		return
+	}
+	if name != "" {
+		if other := findBuilding(building.OrgID, name); other != nil && other.ID != building.ID {
+			respondWithError(w, http.StatusConflict, ErrCodeBuildingExists, "A building with this name already exists")
+			return
+		}
+		building.Name = name
+	}
+	if floors != nil {
+		building.Floors = floors
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Building updated successfully",
+		Data:    *building,
+	})
+}
+
+// /deleteBuilding - Remove a building from the registry (admin only).
+// Complaints about it keep their location.
+func deleteBuildingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req DeleteBuildingRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("building_id", req.BuildingID)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	building, exists := storage.buildings[req.BuildingID]
+	if !exists || !canSeeOrg(user, building.OrgID) {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Building not found")
+		return
+	}
+	delete(storage.buildings, building.ID)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Building deleted successfully",
+	})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComplaintLocations(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Located User", "located@example.com")
+
+	submit := func(title, building, floor, room string) (int, testResponse) {
+		t.Helper()
+		return postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: code, Title: title, Summary: "Somewhere in particular", Rating: 5,
+			Building: building, Floor: floor, Room: room,
+		})
+	}
+	listing := func(req GetComplaintsRequest) []Complaint {
+		t.Helper()
+		req.SecretCode = adminSecret
+		status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", req)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		return complaints
+	}
+
+	t.Run("Free Form", func(t *testing.T) {
+		status, resp := submit("Cold office", " Annex ", "2", "201b")
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201 without a registry, got %d (%s)", status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		if complaint.Building != "Annex" || complaint.Floor != "2" || complaint.Room != "201b" {
+			t.Errorf("Expected the location as submitted, got %q %q %q", complaint.Building, complaint.Floor, complaint.Room)
+		}
+
+		status, resp = submit("Too long", strings.Repeat("b", maxLocationLength+1), "", "")
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("Expected 400 for a long building, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	var main Building
+	t.Run("Registry", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/addBuilding", AddBuildingRequest{SecretCode: code, Name: "Main"})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a regular user, got %d", status)
+		}
+
+		status, resp = postJSON(t, srv, "/addBuilding", AddBuildingRequest{SecretCode: adminSecret, Name: "Main", Floors: []string{"G", "1", " 2 ", "g"}})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		resp.decode(t, &main)
+		if !reflect.DeepEqual(main.Floors, []string{"G", "1", "2"}) {
+			t.Errorf("Expected normalized floors, got %v", main.Floors)
+		}
+		if status, resp = postJSON(t, srv, "/addBuilding", AddBuildingRequest{SecretCode: adminSecret, Name: "main"}); status != http.StatusConflict || resp.ErrorCode != ErrCodeBuildingExists {
+			t.Errorf("Expected 409 BUILDING_EXISTS, got %d %s", status, resp.ErrorCode)
+		}
+		postJSON(t, srv, "/addBuilding", AddBuildingRequest{SecretCode: adminSecret, Name: "Warehouse"})
+
+		status, resp = submit("Unknown building", "Annex", "", "")
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Fatalf("Expected 400 for an unregistered building, got %d %s", status, resp.ErrorCode)
+		}
+		if !strings.Contains(resp.Error, "Main, Warehouse") {
+			t.Errorf("Expected the known buildings in the error, got %q", resp.Error)
+		}
+
+		if status, resp = submit("Unknown floor", "Main", "9", ""); status != http.StatusBadRequest || !strings.Contains(resp.Error, "G, 1, 2") {
+			t.Errorf("Expected 400 listing the floors, got %d (%s)", status, resp.Error)
+		}
+
+		status, resp = submit("Leaking tap", "MAIN", "g", "Kitchen")
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		if complaint.Building != "Main" || complaint.Floor != "G" || complaint.Room != "Kitchen" {
+			t.Errorf("Expected the registered spelling, got %q %q %q", complaint.Building, complaint.Floor, complaint.Room)
+		}
+		if status, _ := submit("Any floor", "warehouse", "Mezzanine", ""); status != http.StatusCreated {
+			t.Errorf("Expected any floor of a building without floors, got %d", status)
+		}
+		if status, _ := submit("No location", "", "", ""); status != http.StatusCreated {
+			t.Errorf("Expected a location to stay optional, got %d", status)
+		}
+	})
+
+	t.Run("Filter By Building And Floor", func(t *testing.T) {
+		submit("Upstairs", "Main", "1", "")
+		submit("Ground again", "Main", "G", "")
+
+		titles := func(complaints []Complaint) []string {
+			list := []string{}
+			for _, complaint := range complaints {
+				list = append(list, complaint.Title)
+			}
+			return list
+		}
+		if got := titles(listing(GetComplaintsRequest{Building: "main", Floor: "g"})); !reflect.DeepEqual(got, []string{"Leaking tap", "Ground again"}) {
+			t.Errorf("Expected the ground floor of Main, got %v", got)
+		}
+		if got := titles(listing(GetComplaintsRequest{Building: "Main"})); len(got) != 3 {
+			t.Errorf("Expected every complaint in Main, got %v", got)
+		}
+		if got := titles(listing(GetComplaintsRequest{Building: "Annex"})); !reflect.DeepEqual(got, []string{"Cold office"}) {
+			t.Errorf("Expected the free-form complaint, got %v", got)
+		}
+	})
+
+	t.Run("Update And Delete", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/updateBuilding", UpdateBuildingRequest{SecretCode: adminSecret, BuildingID: main.ID, Floors: []string{}})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if status, _ := submit("Roof", "Main", "Roof", ""); status != http.StatusCreated {
+			t.Errorf("Expected any floor once the floors are removed, got %d", status)
+		}
+
+		status, resp = postJSON(t, srv, "/listBuildings", ListBuildingsRequest{SecretCode: adminSecret})
+		var buildings []Building
+		resp.decode(t, &buildings)
+		if status != http.StatusOK || len(buildings) != 2 {
+			t.Fatalf("Expected two buildings, got %d %+v", status, buildings)
+		}
+
+		for _, building := range buildings {
+			postJSON(t, srv, "/deleteBuilding", DeleteBuildingRequest{SecretCode: adminSecret, BuildingID: building.ID})
+		}
+		if status, _ := submit("Free again", "Annex", "", ""); status != http.StatusCreated {
+			t.Errorf("Expected free-form locations once the registry is empty, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/deleteBuilding", DeleteBuildingRequest{SecretCode: adminSecret, BuildingID: main.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for a deleted building, got %d", status)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/report", ReportRequest{SecretCode: adminSecret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var report Report
+		resp.decode(t, &report)
+		want := []BuildingCount{
+			{Building: "Main", Open: 4, Created: 4},
+			{Building: "Annex", Open: 2, Created: 2},
+			{Building: "Warehouse", Open: 1, Created: 1},
+		}
+		if !reflect.DeepEqual(report.ByBuilding, want) {
+			t.Errorf("Expected %+v, got %+v", want, report.ByBuilding)
+		}
+	})
+}
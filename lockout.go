@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lockoutPrefixLen is how much of an unattributable secret code is used as
+// its lockout key
+const lockoutPrefixLen = 12
+
+type UnlockUserRequest struct {
+	SecretCode string `json:"secret_code"`
+	UserID     int    `json:"user_id"`
+}
+
+type LockoutInfo struct {
+	LockedUntil       string `json:"locked_until"`
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+}
+
+type failureRecord struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LoginLimiter counts consecutive failed authentication attempts per key and
+// locks the key once config.MaxFailedLogins is reached
+type LoginLimiter struct {
+	records   map[string]*failureRecord
+	lastPrune time.Time
+	mutex     sync.Mutex
+}
+
+func newLoginLimiter() *LoginLimiter {
+	return &LoginLimiter{records: make(map[string]*failureRecord)}
+}
+
+var loginLimiter = newLoginLimiter()
+
+// lockoutKey picks the counter an attempt is charged against. Codes in the
+// SEC_{timestamp}_{user_id} format name their account, so guesses against one
+// user share a counter; anything else is keyed by its prefix.
+func lockoutKey(secretCode string) string {
+	if strings.HasPrefix(secretCode, "SEC_") {
+		parts := strings.Split(secretCode, "_")
+		if len(parts) == 3 {
+			if id, err := strconv.Atoi(parts[2]); err == nil && id > 0 {
+				return fmt.Sprintf("user:%d", id)
+			}
+		}
+	}
+	if len(secretCode) > lockoutPrefixLen {
+		secretCode = secretCode[:lockoutPrefixLen]
+	}
+	return "prefix:" + secretCode
+}
+
+// lockedUntil reports when the lock on key expires, or the zero time if the
+// key is not locked
+func (l *LoginLimiter) lockedUntil(key string, now time.Time) time.Time {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if record, exists := l.records[key]; exists && now.Before(record.lockedUntil) {
+		return record.lockedUntil
+	}
+	return time.Time{}
+}
+
+// recordFailure counts a failed attempt and returns the lock expiry if this
+// attempt locked the key
+func (l *LoginLimiter) recordFailure(key string, now time.Time) time.Time {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.pruneLocked(now)
+
+	record, exists := l.records[key]
+	if !exists || now.Sub(record.lastFailure) > config.LockoutDuration {
+		record = &failureRecord{}
+		l.records[key] = record
+	}
+	record.count++
+	record.lastFailure = now
+	if record.count >= config.MaxFailedLogins {
+		record.lockedUntil = now.Add(config.LockoutDuration)
+		record.count = 0
+	}
+	return record.lockedUntil
+}
+
+func (l *LoginLimiter) reset(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.records, key)
+}
+
+// pruneLocked drops expired counters at most once per lockout period so the
+// map cannot grow without bound. Callers must hold l.mutex.
+func (l *LoginLimiter) pruneLocked(now time.Time) {
+	if now.Sub(l.lastPrune) < config.LockoutDuration {
+		return
+	}
+	l.lastPrune = now
+	for key, record := range l.records {
+		if now.After(record.lockedUntil) && now.Sub(record.lastFailure) > config.LockoutDuration {
+			delete(l.records, key)
+		}
+	}
+}
+
+func respondLocked(w http.ResponseWriter, until, now time.Time) {
+	retryAfter := int(math.Ceil(until.Sub(now).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	respondWithJSON(w, http.StatusLocked, APIResponse{
+		Success:   false,
+		Error:     fmt.Sprintf("Account locked after too many failed attempts. Try again in %s", until.Sub(now).Round(time.Second)),
+		ErrorCode: ErrCodeAccountLocked,
+		Data: LockoutInfo{
+			LockedUntil:       until.Local().Format(timestampLayout),
+			RetryAfterSeconds: retryAfter,
+		},
+	})
+}
+
+// authenticate resolves a secret code, an API token, or a JWT in JWT mode to
+// a user, enforcing the failed attempt lockout for secret codes and the
+// terms of service, and replay protection for writes. r is the request being
+// served, which decides what a read-scoped API token may do. It writes the
+// 401/403/409/423/428 response itself and returns nil when the request should
+// stop.
+func authenticate(w http.ResponseWriter, r *http.Request, secretCode string) *User {
+	user, _ := authenticateCredential(w, r, secretCode)
+	if user == nil || !requireCurrentTerms(w, user) || !checkReplay(w, r, user) {
+		return nil
+	}
+	setLogUser(r, user.ID)
+	return user
+}
+
+// authenticateCredential is authenticate without the terms of service, for
+// the endpoints users reach before accepting them. Deactivated users are
+// refused whatever their credential. When it refuses the credential, it also
+// returns why, one of the authFailed constants.
+func authenticateCredential(w http.ResponseWriter, r *http.Request, secretCode string) (*User, string) {
+	user, reason := resolveCredential(w, r, secretCode)
+	if user == nil {
+		return nil, reason
+	}
+	if !requireActive(w, user) {
+		return nil, authFailedDeactivated
+	}
+	return user, ""
+}
+
+// resolveCredential finds the user secretCode identifies, writing the
+// 401/423 response itself, and saying why, when there is none
+func resolveCredential(w http.ResponseWriter, r *http.Request, secretCode string) (*User, string) {
+	if isAPIToken(secretCode) {
+		return orInvalid(authenticateAPIToken(w, r, secretCode))
+	}
+	if jwtEnabled() && looksLikeToken(secretCode) {
+		return orInvalid(authenticateToken(w, secretCode))
+	}
+
+	now := clock.Now()
+	key := lockoutKey(secretCode)
+	if until := loginLimiter.lockedUntil(key, now); !until.IsZero() {
+		respondLocked(w, until, now)
+		return nil, authFailedLocked
+	}
+
+	user := findUserBySecretCode(secretCode)
+	if user == nil {
+		if until := loginLimiter.recordFailure(key, now); now.Before(until) {
+			respondLocked(w, until, now)
+			return nil, authFailedLocked
+		}
+		respondWithError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid secret code")
+		return nil, authFailedInvalid
+	}
+
+	loginLimiter.reset(key)
+	return user, ""
+}
+
+// orInvalid gives a token that resolved to no user authFailedInvalid as the
+// reason
+func orInvalid(user *User) (*User, string) {
+	if user == nil {
+		return nil, authFailedInvalid
+	}
+	return user, ""
+}
+
+// credentialBelongsTo re-checks, under the write lock, that the credential a
+// request authenticated with still identifies userID: a secret code may have
+// been rotated, and an API token or a JWT revoked, since. Callers must hold
+// storage.mutex.
+func credentialBelongsTo(credential string, userID int) bool {
+	if isAPIToken(credential) {
+		_, user, err := activeAPIToken(credential, clock.Now())
+		return err == nil && user.ID == userID
+	}
+	if jwtEnabled() && looksLikeToken(credential) {
+		claims, err := parseToken(credential, clock.Now())
+		user, exists := storage.users[userID]
+		return err == nil && exists && claims.Subject == strconv.Itoa(userID) && claims.Generation == user.CredentialGeneration
+	}
+	return storage.secretIndex[credential] == userID
+}
+
+// /unlockUser - Clear a user's failed attempt lock early (admin only)
+func unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req UnlockUserRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.UserID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid user ID is required")
+		return
+	}
+
+	admin := authenticate(w, r, req.SecretCode)
+	if admin == nil {
+		return
+	}
+	if !authorize(w, adminOnly(actorOf(admin))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	target := scopedUser(admin, req.UserID)
+	var targetCode string
+	if target != nil {
+		targetCode = target.SecretCode
+	}
+	storage.mutex.RUnlock()
+
+	if target == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+
+	loginLimiter.reset(fmt.Sprintf("user:%d", req.UserID))
+	loginLimiter.reset(lockoutKey(targetCode))
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "User unlocked successfully",
+	})
+}
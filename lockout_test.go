@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoginLockout(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	config.MaxFailedLogins = 3
+	config.LockoutDuration = 10 * time.Minute
+
+	userID, code := registerTestUser(t, srv, "Target User", "target@example.com")
+	// A guess in the same SEC_{timestamp}_{user_id} shape targets this account
+	wrongCode := fmt.Sprintf("SEC_1_%d", userID)
+
+	for i := 1; i < config.MaxFailedLogins; i++ {
+		if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: wrongCode}); status != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected status 401, got %d", i, status)
+		}
+	}
+
+	t.Run("Threshold Locks Account", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: wrongCode})
+		if status != http.StatusLocked {
+			t.Fatalf("Expected status 423, got %d", status)
+		}
+		var info LockoutInfo
+		resp.decode(t, &info)
+		if info.RetryAfterSeconds != 600 {
+			t.Errorf("Expected 600 seconds remaining, got %d", info.RetryAfterSeconds)
+		}
+	})
+
+	t.Run("Correct Code Also Locked", func(t *testing.T) {
+		fake.Advance(4 * time.Minute)
+		status, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: code})
+		if status != http.StatusLocked {
+			t.Fatalf("Expected status 423, got %d", status)
+		}
+		var info LockoutInfo
+		resp.decode(t, &info)
+		if info.RetryAfterSeconds != 360 {
+			t.Errorf("Expected 360 seconds remaining, got %d", info.RetryAfterSeconds)
+		}
+	})
+
+	t.Run("Lock Clears After Cooldown", func(t *testing.T) {
+		fake.Advance(6*time.Minute + time.Second)
+		if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: code}); status != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", status)
+		}
+	})
+
+	t.Run("Success Resets Counter", func(t *testing.T) {
+		for i := 1; i < config.MaxFailedLogins; i++ {
+			postJSON(t, srv, "/login", LoginRequest{SecretCode: wrongCode})
+		}
+		postJSON(t, srv, "/login", LoginRequest{SecretCode: code})
+		if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: wrongCode}); status != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 after reset, got %d", status)
+		}
+	})
+
+	t.Run("Admin Unlock", func(t *testing.T) {
+		for i := 0; i < config.MaxFailedLogins; i++ {
+			postJSON(t, srv, "/login", LoginRequest{SecretCode: wrongCode})
+		}
+		if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: code}); status != http.StatusLocked {
+			t.Fatalf("Expected status 423, got %d", status)
+		}
+
+		status, _ := postJSON(t, srv, "/unlockUser", UnlockUserRequest{SecretCode: code, UserID: userID})
+		if status != http.StatusLocked {
+			t.Errorf("Expected locked user to get 423 from unlockUser, got %d", status)
+		}
+		status, _ = postJSON(t, srv, "/unlockUser", UnlockUserRequest{SecretCode: "ADMIN_SECRET_123", UserID: userID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: code}); status != http.StatusOK {
+			t.Errorf("Expected status 200 after unlock, got %d", status)
+		}
+	})
+}
+
+func TestUnlockUserRequiresAdmin(t *testing.T) {
+	srv := newTestServer(t)
+	userID, code := registerTestUser(t, srv, "Plain User", "plain@example.com")
+
+	if status, _ := postJSON(t, srv, "/unlockUser", UnlockUserRequest{SecretCode: code, UserID: userID}); status != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", status)
+	}
+	if status, _ := postJSON(t, srv, "/unlockUser", UnlockUserRequest{SecretCode: "ADMIN_SECRET_123", UserID: 42}); status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", status)
+	}
+}
+
+func TestLoginLimiterPrunesExpiredCounters(t *testing.T) {
+	config = defaultConfig()
+	limiter := newLoginLimiter()
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 100; i++ {
+		limiter.recordFailure(lockoutKey("GUESS_"+time.Duration(i).String()), start)
+	}
+	limiter.recordFailure("prefix:late", start.Add(2*config.LockoutDuration))
+
+	if len(limiter.records) != 1 {
+		t.Errorf("Expected expired counters to be pruned, %d remain", len(limiter.records))
+	}
+}
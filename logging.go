@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attribute names shared by every log line, so one query finds everything
+// about a request, user or complaint whichever part of the server logged it
+const (
+	logKeyRequestID   = "request_id"
+	logKeyUserID      = "user_id"
+	logKeyComplaintID = "complaint_id"
+	logKeyDurationMS  = "duration_ms"
+	logKeyJob         = "job"
+	logKeyError       = "error"
+)
+
+// Formats of log lines
+const (
+	logFormatJSON = "json"
+	logFormatText = "text"
+)
+
+// logLevel drops lines below it. It is shared by every logger, so
+// /admin/logLevel changes it for the whole server at once.
+var logLevel = new(slog.LevelVar)
+
+// logger is the root logger. Requests and jobs log through children of it
+// carried in their context; see loggerFrom.
+var logger = newLogger(os.Stderr, logFormatJSON)
+
+func newLogger(w io.Writer, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: logLevel}
+	if format == logFormatText {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// setupLogging sends log lines to w in format, dropping those below level.
+// The standard library's log package writes through it too.
+func setupLogging(w io.Writer, format string, level slog.Level) error {
+	if format != logFormatJSON && format != logFormatText {
+		return fmt.Errorf("log format must be %s or %s, not %q", logFormatJSON, logFormatText, format)
+	}
+	logLevel.Set(level)
+	logger = newLogger(w, format)
+	slog.SetDefault(logger)
+	return nil
+}
+
+// fatal logs msg as an error and exits, for failures at startup
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// durationMS is d in milliseconds, for logKeyDurationMS
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// logContext is the logger of a request or job run, along with what is
+// learned about it while it runs
+type logContext struct {
+	logger *slog.Logger
+	mutex  sync.Mutex
+	userID int
+}
+
+type logContextKey struct{}
+
+// withLogger returns ctx carrying l for loggerFrom
+func withLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, logContextKey{}, &logContext{logger: l})
+}
+
+// loggerFrom is the logger carried by ctx, with the user_id of the
+// authenticated user once there is one, or the root logger
+func loggerFrom(ctx context.Context) *slog.Logger {
+	lc, ok := ctx.Value(logContextKey{}).(*logContext)
+	if !ok {
+		return logger
+	}
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	if lc.userID != 0 {
+		return lc.logger.With(logKeyUserID, lc.userID)
+	}
+	return lc.logger
+}
+
+// requestLogger is the logger of r, tagged with its request ID
+func requestLogger(r *http.Request) *slog.Logger {
+	return loggerFrom(r.Context())
+}
+
+// setLogUser records that r was made by userID, for the lines logged about
+// it from then on, the access log line included
+func setLogUser(r *http.Request, userID int) {
+	if lc, ok := r.Context().Value(logContextKey{}).(*logContext); ok {
+		lc.mutex.Lock()
+		lc.userID = userID
+		lc.mutex.Unlock()
+	}
+}
+
+type LogLevelRequest struct {
+	SecretCode string `json:"secret_code"`
+	Level      string `json:"level"` // debug, info, warn or error
+}
+
+type LogLevelStatus struct {
+	Level string `json:"level"`
+}
+
+// /admin/logLevel - Show (GET) or change (POST) the level below which log
+// lines are dropped, without a restart (super-admin only)
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if authenticateSuperAdminRequest(w, r) == nil {
+			return
+		}
+		respondWithJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Message: "Log level retrieved successfully",
+			Data:    LogLevelStatus{Level: logLevelName(logLevel.Level())},
+		})
+	case http.MethodPost:
+		var req LogLevelRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		var v validator
+		v.required("secret_code", req.SecretCode)
+		v.oneOf("level", req.Level, logLevelNames)
+		if apiErr := v.err(); apiErr != nil {
+			respondWithAPIError(w, apiErr)
+			return
+		}
+
+		user := authenticate(w, r, req.SecretCode)
+		if user == nil {
+			return
+		}
+		if !authorize(w, superAdminOnly(actorOf(user))) {
+			return
+		}
+
+		var level slog.Level
+		level.UnmarshalText([]byte(req.Level))
+		previous := logLevel.Level()
+		logLevel.Set(level)
+		requestLogger(r).Warn("Log level changed", "from", logLevelName(previous), "to", logLevelName(level))
+
+		respondWithJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Message: "Log level changed",
+			Data:    LogLevelStatus{Level: logLevelName(level)},
+		})
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// logLevelNames are the levels /admin/logLevel accepts
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+// logLevelName is level as /admin/logLevel accepts it
+func logLevelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
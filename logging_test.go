@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useLogOutput sends log lines to w as JSON at the info level until the test
+// ends
+func useLogOutput(t testing.TB, w io.Writer) {
+	previous, previousLevel := logger, logLevel.Level()
+	logger = newLogger(w, logFormatJSON)
+	logLevel.Set(slog.LevelInfo)
+	t.Cleanup(func() {
+		logger = previous
+		logLevel.Set(previousLevel)
+	})
+}
+
+// logCapture collects log lines; the access log line is written after the
+// response, so it is read while the server may still be writing
+type logCapture struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (c *logCapture) Write(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.buf.Write(p)
+}
+
+// lines decodes every line logged so far
+func (c *logCapture) lines(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var lines []map[string]interface{}
+	for _, raw := range strings.Split(strings.TrimSpace(c.buf.String()), "\n") {
+		if raw == "" {
+			continue
+		}
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatalf("Log line is not JSON: %q", raw)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// find waits briefly for a line with msg and attr set to value
+func (c *logCapture) find(t *testing.T, msg, attr string, value interface{}) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		for _, line := range c.lines(t) {
+			if line["msg"] == msg && line[attr] == value {
+				return line
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRequestLogLine(t *testing.T) {
+	srv := newTestServer(t)
+	capture := &logCapture{}
+	useLogOutput(t, capture)
+	userID, code := registerTestUser(t, srv, "Logged User", "logged@example.com")
+
+	status, _ := postWithCorrelationID(t, srv.URL+"/submitComplaint", "trace-log-1", SubmitComplaintRequest{
+		SecretCode: code, Title: "Noisy fan", Summary: "Rattles all day", Rating: 4,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", status)
+	}
+
+	line := capture.find(t, "Request", logKeyRequestID, "trace-log-1")
+	if line == nil {
+		t.Fatalf("Expected a request line with the request ID, got %v", capture.lines(t))
+	}
+	if line["level"] != "INFO" || line["method"] != "POST" || line["path"] != "/submitComplaint" || line["status"] != float64(http.StatusCreated) {
+		t.Errorf("Unexpected request line %v", line)
+	}
+	if line[logKeyUserID] != float64(userID) {
+		t.Errorf("Expected user_id %d, got %v", userID, line[logKeyUserID])
+	}
+	if _, ok := line[logKeyDurationMS].(float64); !ok {
+		t.Errorf("Expected a numeric duration_ms, got %v", line[logKeyDurationMS])
+	}
+
+	// Unauthenticated requests have no user
+	postWithCorrelationID(t, srv.URL+"/login", "trace-log-2", LoginRequest{SecretCode: "WRONG"})
+	if line := capture.find(t, "Request", logKeyRequestID, "trace-log-2"); line == nil || line[logKeyUserID] != nil {
+		t.Errorf("Expected a request line without user_id, got %v", line)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	srv := newTestServer(t)
+	capture := &logCapture{}
+	useLogOutput(t, capture)
+	_, code := registerTestUser(t, srv, "Plain User", "plain@example.com")
+	_, adminCode := registerTestAdmin(t, srv, "Org Admin", "orgadmin@example.com")
+
+	getLevel := func() string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/admin/logLevel?secret_code="+adminSecret, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /admin/logLevel failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body testResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		var status LogLevelStatus
+		body.decode(t, &status)
+		return status.Level
+	}
+	runJob := func() {
+		jobRunner.run(Job{Name: "probe", Run: func(ctx context.Context, now time.Time) int {
+			loggerFrom(ctx).Debug("Probing")
+			return 0
+		}})
+	}
+
+	if level := getLevel(); level != "info" {
+		t.Errorf("Expected info, got %q", level)
+	}
+	runJob()
+	if line := capture.find(t, "Probing", logKeyJob, "probe"); line != nil {
+		t.Errorf("Expected debug lines dropped at info, got %v", line)
+	}
+
+	t.Run("Access And Validation", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/admin/logLevel", LogLevelRequest{SecretCode: code, Level: "debug"}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a user, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/admin/logLevel", LogLevelRequest{SecretCode: adminCode, Level: "debug"}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for an organization admin, got %d", status)
+		}
+		status, resp := postJSON(t, srv, "/admin/logLevel", LogLevelRequest{SecretCode: adminSecret, Level: "verbose"})
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("Expected 400 VALIDATION_FAILED, got %d %s", status, resp.ErrorCode)
+		}
+		if level := getLevel(); level != "info" {
+			t.Errorf("Expected the level unchanged, got %q", level)
+		}
+	})
+
+	t.Run("Debug", func(t *testing.T) {
+		if status, resp := postJSON(t, srv, "/admin/logLevel", LogLevelRequest{SecretCode: adminSecret, Level: "debug"}); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if level := getLevel(); level != "debug" {
+			t.Errorf("Expected debug, got %q", level)
+		}
+		runJob()
+		if line := capture.find(t, "Probing", logKeyJob, "probe"); line == nil {
+			t.Error("Expected the job's debug line with its name")
+		}
+		if line := capture.find(t, "Job finished", logKeyJob, "probe"); line == nil || line[logKeyDurationMS] == nil {
+			t.Errorf("Expected the job's duration, got %v", line)
+		}
+	})
+
+	t.Run("Warn Drops Request Lines", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/admin/logLevel", LogLevelRequest{SecretCode: adminSecret, Level: "warn"}); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", status)
+		}
+		postWithCorrelationID(t, srv.URL+"/health", "trace-quiet", nil)
+		if line := capture.find(t, "Request", logKeyRequestID, "trace-quiet"); line != nil {
+			t.Errorf("Expected no info lines at warn, got %v", line)
+		}
+	})
+}
+
+func TestSetupLogging(t *testing.T) {
+	useLogOutput(t, io.Discard)
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	if err := setupLogging(io.Discard, "xml", slog.LevelInfo); err == nil {
+		t.Error("Expected an unknown format to be refused")
+	}
+
+	var buf bytes.Buffer
+	if err := setupLogging(&buf, logFormatText, slog.LevelWarn); err != nil {
+		t.Fatalf("setupLogging: %v", err)
+	}
+	loggerFrom(withLogger(context.Background(), logger.With(logKeyComplaintID, 7))).Warn("Text line")
+	logger.Info("Dropped")
+	if out := buf.String(); !strings.Contains(out, `msg="Text line" complaint_id=7`) || strings.Contains(out, "Dropped") {
+		t.Errorf("Unexpected text output %q", out)
+	}
+}
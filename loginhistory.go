@@ -0,0 +1,219 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Login history limits
+const (
+	maxLoginHistory   = 50  // entries kept per user; older ones are overwritten
+	maxUserAgentRunes = 200 // longer user agents are cut
+)
+
+// Login history events
+const (
+	loginEventLogin    = "login"
+	loginEventRegister = "register"
+)
+
+// LoginEntry is one authentication, or registration, of a user
+type LoginEntry struct {
+	Event     string `json:"event"` // login or register
+	Success   bool   `json:"success"`
+	Reason    string `json:"reason,omitempty"` // why a login failed; see the authFailed constants
+	At        string `json:"at"`
+	IPPrefix  string `json:"ip_prefix,omitempty"` // the client network, never the full address
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// loginRing keeps a user's most recent entries in a fixed-size buffer
+type loginRing struct {
+	entries [maxLoginHistory]LoginEntry
+	next    int
+	size    int
+}
+
+func (lr *loginRing) add(entry LoginEntry) {
+	lr.entries[lr.next] = entry
+	lr.next = (lr.next + 1) % maxLoginHistory
+	if lr.size < maxLoginHistory {
+		lr.size++
+	}
+}
+
+// newestFirst returns up to limit entries, most recent first
+func (lr *loginRing) newestFirst(limit int) []LoginEntry {
+	if limit <= 0 || limit > lr.size {
+		limit = lr.size
+	}
+	list := make([]LoginEntry, 0, limit)
+	for i := 1; i <= limit; i++ {
+		list = append(list, lr.entries[(lr.next-i+maxLoginHistory)%maxLoginHistory])
+	}
+	return list
+}
+
+// Why authenticateCredential refused a credential, as recorded in LoginEntry.Reason
+const (
+	authFailedInvalid     = "invalid_credential"
+	authFailedLocked      = "account_locked"
+	authFailedDeactivated = "deactivated"
+)
+
+// unknownSecretFailures counts failed logins with a secret code that
+// belongs to nobody. They cannot be attributed to a user.
+var unknownSecretFailures int64
+
+// truncatedIP reduces the client address to its /24 (IPv4) or /48 (IPv6)
+// network, which is enough to tell where a login came from without storing
+// the address itself
+func truncatedIP(r *http.Request) string {
+	ip := net.ParseIP(clientIP(r))
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// newLoginEntry describes an attempt made by request r
+func newLoginEntry(r *http.Request, event string, success bool, reason string) LoginEntry {
+	userAgent := sanitizeText(r.UserAgent(), false)
+	if runes := []rune(userAgent); len(runes) > maxUserAgentRunes {
+		userAgent = string(runes[:maxUserAgentRunes])
+	}
+	return LoginEntry{
+		Event:     event,
+		Success:   success,
+		Reason:    reason,
+		At:        getCurrentTime(),
+		IPPrefix:  truncatedIP(r),
+		UserAgent: userAgent,
+	}
+}
+
+// recordLoginEntry adds entry to user's history, updating LastLoginAt on a
+// successful login. It takes storage.loginMutex itself, so callers need hold
+// no other lock.
+func recordLoginEntry(user *User, entry LoginEntry) {
+	storage.loginMutex.Lock()
+	defer storage.loginMutex.Unlock()
+
+	ring, exists := storage.loginHistory[user.ID]
+	if !exists {
+		ring = &loginRing{}
+		storage.loginHistory[user.ID] = ring
+	}
+	ring.add(entry)
+	if entry.Event == loginEventLogin && entry.Success {
+		user.LastLoginAt = entry.At
+	}
+}
+
+// lastLoginAt returns when user last logged in. It takes storage.loginMutex,
+// so it may be called with any other storage lock held.
+func lastLoginAt(user *User) string {
+	storage.loginMutex.Lock()
+	defer storage.loginMutex.Unlock()
+	return user.LastLoginAt
+}
+
+// snapshotUser returns a copy of user that later logins leave alone. Callers
+// must hold storage.mutex or storage.usersMutex.
+func snapshotUser(user *User) User {
+	storage.loginMutex.Lock()
+	defer storage.loginMutex.Unlock()
+	return *user
+}
+
+// recordFailedLogin records a login that authenticateCredential refused for
+// reason against the user secretCode belongs to; a code that belongs to
+// nobody only counts towards unknownSecretFailures
+func recordFailedLogin(r *http.Request, secretCode, reason string) {
+	storage.usersMutex.RLock()
+	defer storage.usersMutex.RUnlock()
+
+	userID, exists := storage.secretIndex[secretCode]
+	if !exists {
+		atomic.AddInt64(&unknownSecretFailures, 1)
+		return
+	}
+	recordLoginEntry(storage.users[userID], newLoginEntry(r, loginEventLogin, false, reason))
+}
+
+type LoginHistoryRequest struct {
+	SecretCode string `json:"secret_code"`
+	UserID     int    `json:"user_id,omitempty"` // admins only; defaults to the caller
+	Limit      int    `json:"limit,omitempty"`   // defaults to every kept entry
+}
+
+type LoginHistory struct {
+	UserID      int          `json:"user_id"`
+	LastLoginAt string       `json:"last_login_at,omitempty"`
+	Entries     []LoginEntry `json:"entries"` // most recent first
+}
+
+// /loginHistory - Recent logins of the caller, or as an admin, of any user
+func loginHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req LoginHistoryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.UserID < 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid user ID is required")
+		return
+	}
+	if req.Limit < 0 || req.Limit > maxLoginHistory {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Limit must be between 1 and 50")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, canActOnUser(actorOf(user), req.UserID)) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	subject := user
+	if req.UserID != 0 {
+		subject = scopedUser(user, req.UserID)
+	}
+	if subject == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+
+	storage.loginMutex.Lock()
+	history := LoginHistory{UserID: subject.ID, LastLoginAt: subject.LastLoginAt, Entries: []LoginEntry{}}
+	if ring, exists := storage.loginHistory[subject.ID]; exists {
+		history.Entries = ring.newestFirst(req.Limit)
+	}
+	storage.loginMutex.Unlock()
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Login history retrieved successfully",
+		Data:    history,
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loginWithAgent posts to /login with the given User-Agent and returns the
+// status code
+func loginWithAgent(t *testing.T, srv *httptest.Server, secretCode, userAgent string) int {
+	t.Helper()
+	jsonData, _ := json.Marshal(LoginRequest{SecretCode: secretCode})
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/login", bytes.NewReader(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /login failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestLoginHistory(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local))
+	config.MaxFailedLogins = 2
+
+	userID, code := registerTestUser(t, srv, "Audited User", "audited@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+	unknownBefore := atomic.LoadInt64(&unknownSecretFailures)
+
+	fake.Advance(time.Minute)
+	if status := loginWithAgent(t, srv, code, "Browser/1.0"); status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	fake.Advance(time.Minute)
+	loginWithAgent(t, srv, code, "Phone/2.0")
+
+	// Guesses that belong to nobody are only counted; two of them in the
+	// user's secret format also lock the account
+	loginWithAgent(t, srv, "not-a-real-secret", "Scanner")
+	for i := 0; i < config.MaxFailedLogins; i++ {
+		loginWithAgent(t, srv, fmt.Sprintf("SEC_1_%d", userID), "Scanner")
+	}
+	fake.Advance(time.Minute)
+	if status := loginWithAgent(t, srv, code, "Browser/1.0"); status != http.StatusLocked {
+		t.Fatalf("Expected the account to be locked, got %d", status)
+	}
+
+	if got := atomic.LoadInt64(&unknownSecretFailures) - unknownBefore; got != 3 {
+		t.Errorf("Expected 3 unattributed failures, got %d", got)
+	}
+
+	status, resp := postJSON(t, srv, "/loginHistory", LoginHistoryRequest{SecretCode: adminSecret, UserID: userID})
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+	}
+	var history LoginHistory
+	resp.decode(t, &history)
+
+	t.Run("Contents And Ordering", func(t *testing.T) {
+		want := []struct {
+			event     string
+			success   bool
+			userAgent string
+			at        string
+		}{
+			{loginEventLogin, false, "Browser/1.0", "2024-05-01 09:03:00"},
+			{loginEventLogin, true, "Phone/2.0", "2024-05-01 09:02:00"},
+			{loginEventLogin, true, "Browser/1.0", "2024-05-01 09:01:00"},
+			{loginEventRegister, true, "Go-http-client/1.1", "2024-05-01 09:00:00"},
+		}
+		if len(history.Entries) != len(want) {
+			t.Fatalf("Expected %d entries, got %+v", len(want), history.Entries)
+		}
+		for i, w := range want {
+			got := history.Entries[i]
+			if got.Event != w.event || got.Success != w.success || got.UserAgent != w.userAgent || got.At != w.at {
+				t.Errorf("Entry %d: expected %+v, got %+v", i, w, got)
+			}
+			if got.IPPrefix != "127.0.0.0/24" {
+				t.Errorf("Entry %d: expected a truncated IP, got %q", i, got.IPPrefix)
+			}
+		}
+		if history.Entries[0].Reason != authFailedLocked {
+			t.Errorf("Expected the failure reason, got %q", history.Entries[0].Reason)
+		}
+		if history.LastLoginAt != "2024-05-01 09:02:00" {
+			t.Errorf("Expected the last successful login, got %q", history.LastLoginAt)
+		}
+	})
+
+	t.Run("Last Login On Profiles", func(t *testing.T) {
+		loginLimiter = newLoginLimiter()
+		submitTestComplaint(t, srv, code, "Audit me", 5)
+
+		_, resp := postJSON(t, srv, "/me", MeRequest{SecretCode: code})
+		var me MeResponse
+		resp.decode(t, &me)
+		if me.LastLoginAt != "2024-05-01 09:02:00" {
+			t.Errorf("Expected last_login_at on /me, got %q", me.LastLoginAt)
+		}
+
+		_, resp = postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret})
+		var list []Complaint
+		resp.decode(t, &list)
+		if len(list) != 1 || list[0].User == nil || list[0].User.LastLoginAt != "2024-05-01 09:02:00" {
+			t.Errorf("Expected last_login_at on the admin listing, got %+v", list)
+		}
+	})
+
+	t.Run("Access", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/loginHistory", LoginHistoryRequest{SecretCode: code, Limit: 2})
+		if status != http.StatusOK {
+			t.Fatalf("Expected users to see their own history, got %d", status)
+		}
+		var own LoginHistory
+		resp.decode(t, &own)
+		if len(own.Entries) != 2 || own.Entries[0].Success {
+			t.Errorf("Expected the 2 most recent entries, got %+v", own.Entries)
+		}
+
+		status, _ = postJSON(t, srv, "/loginHistory", LoginHistoryRequest{SecretCode: otherCode, UserID: userID})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 for another user's history, got %d", status)
+		}
+	})
+
+	t.Run("Deactivated User", func(t *testing.T) {
+		suspendedID, suspended := registerTestUser(t, srv, "Suspended User", "suspended@example.com")
+		postJSON(t, srv, "/deactivateUser", UserStatusRequest{SecretCode: adminSecret, UserID: suspendedID})
+		if status := loginWithAgent(t, srv, suspended, "Browser/1.0"); status != http.StatusForbidden {
+			t.Fatalf("Expected 403 for a deactivated user, got %d", status)
+		}
+
+		_, resp := postJSON(t, srv, "/loginHistory", LoginHistoryRequest{SecretCode: adminSecret, UserID: suspendedID})
+		var history LoginHistory
+		resp.decode(t, &history)
+		if len(history.Entries) == 0 || history.Entries[0].Success || history.Entries[0].Reason != authFailedDeactivated {
+			t.Errorf("Expected the refused login recorded as %s, got %+v", authFailedDeactivated, history.Entries)
+		}
+	})
+}
+
+func TestLoginRingIsBounded(t *testing.T) {
+	var ring loginRing
+	for i := 1; i <= maxLoginHistory+5; i++ {
+		ring.add(LoginEntry{At: fmt.Sprint(i)})
+	}
+	entries := ring.newestFirst(0)
+	if len(entries) != maxLoginHistory {
+		t.Fatalf("Expected %d entries, got %d", maxLoginHistory, len(entries))
+	}
+	if entries[0].At != fmt.Sprint(maxLoginHistory+5) || entries[len(entries)-1].At != "6" {
+		t.Errorf("Expected entries 55 down to 6, got %s down to %s", entries[0].At, entries[len(entries)-1].At)
+	}
+}
@@ -1,37 +1,129 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
 // User represents a user in the system
 type User struct {
-	ID         int         `json:"id"`
-	SecretCode string      `json:"secret_code"`
-	Name       string      `json:"name"`
-	Email      string      `json:"email"`
-	Complaints []Complaint `json:"complaints"`
-	IsAdmin    bool        `json:"is_admin"`
+	ID           int         `json:"id" xml:"id"`
+	SecretCode   string      `json:"secret_code" xml:"secret_code"`
+	Name         string      `json:"name" xml:"name"`
+	Email        string      `json:"email" xml:"email"`
+	Complaints   []Complaint `json:"complaints" xml:"complaints>complaint"`
+	OrgID        int         `json:"org_id" xml:"org_id"`
+	IsAdmin      bool        `json:"is_admin" xml:"is_admin"`                                 // admin of their organization
+	IsSuperAdmin bool        `json:"is_super_admin,omitempty" xml:"is_super_admin,omitempty"` // admin of every organization
+	LastLoginAt  string      `json:"last_login_at,omitempty" xml:"last_login_at,omitempty"`   // last successful /login
+	// TOSVersion is the terms of service version the user last accepted
+	TOSVersion    string `json:"tos_version,omitempty" xml:"tos_version,omitempty"`
+	TOSAcceptedAt string `json:"tos_accepted_at,omitempty" xml:"tos_accepted_at,omitempty"`
+	// Provisional marks a user created for an unknown sender by
+	// /ingestEmail; their secret code was never shown to anyone
+	Provisional bool `json:"provisional,omitempty" xml:"provisional,omitempty"`
+	// IsActive is false for a user an admin deactivated: they cannot log in
+	// or authenticate, but their complaints stay
+	IsActive      bool   `json:"is_active" xml:"is_active"`
+	DeactivatedAt string `json:"deactivated_at,omitempty" xml:"deactivated_at,omitempty"`
+	// CredentialGeneration is carried in every token issued to the user and
+	// bumped by revokeCredentials, which invalidates those tokens
+	CredentialGeneration int `json:"-" xml:"-"`
 }
 
 // Complaint represents a complaint in the system
 type Complaint struct {
-	ID           int    `json:"id"`
-	Title        string `json:"title"`
-	Summary      string `json:"summary"`
-	Rating       int    `json:"rating"`
-	UserID       int    `json:"user_id"`
-	UserName     string `json:"user_name,omitempty"`
-	IsResolved   bool   `json:"is_resolved"`
-	CreatedAt    string `json:"created_at"`
-	ResolvedAt   string `json:"resolved_at,omitempty"`
+	ID             int            `json:"id" xml:"id"`
+	Reference      string         `json:"reference" xml:"reference"` // e.g. CMP-7F3K9Q; accepted wherever an ID is
+	Title          string         `json:"title" xml:"title"`
+	Summary        string         `json:"summary" xml:"summary"`
+	Rating         int            `json:"rating" xml:"rating"`
+	RatingScale    RatingScale    `json:"rating_scale" xml:"rating_scale"` // the scale Rating was given on
+	Priority       string         `json:"priority" xml:"priority"`
+	Tags           []string       `json:"tags,omitempty" xml:"tags>tag,omitempty"`         // lowercase, unique
+	Department     string         `json:"department,omitempty" xml:"department,omitempty"` // set by routing rules on submission
+	CustomFields   CustomValues   `json:"custom_fields,omitempty" xml:"custom_fields,omitempty"`
+	Building       string         `json:"building,omitempty" xml:"building,omitempty"` // as registered, when the organization has a location registry
+	Floor          string         `json:"floor,omitempty" xml:"floor,omitempty"`
+	Room           string         `json:"room,omitempty" xml:"room,omitempty"`
+	UserID         int            `json:"user_id" xml:"user_id"`
+	OrgID          int            `json:"org_id" xml:"org_id"` // the submitter's organization
+	UserName       string         `json:"user_name,omitempty" xml:"user_name,omitempty"`
+	IsResolved     bool           `json:"is_resolved" xml:"is_resolved"`
+	Status         string         `json:"status" xml:"status"`                               // open, resolved or merged; set by complaintForViewer
+	MergedInto     int            `json:"merged_into,omitempty" xml:"merged_into,omitempty"` // primary complaint this duplicate was merged into
+	MergedAt       string         `json:"merged_at,omitempty" xml:"merged_at,omitempty"`
+	Duplicates     []int          `json:"duplicates,omitempty" xml:"duplicates>id,omitempty"` // complaints merged into this one
+	CreatedAt      string         `json:"created_at" xml:"created_at"`
+	ResolvedAt     string         `json:"resolved_at,omitempty" xml:"resolved_at,omitempty"`
+	ResolutionNote string         `json:"resolution_note,omitempty" xml:"resolution_note,omitempty"`
+	IsDeleted      bool           `json:"is_deleted,omitempty" xml:"is_deleted,omitempty"`
+	DeletedAt      string         `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+	ArchivedAt     string         `json:"archived_at,omitempty" xml:"archived_at,omitempty"`         // left out of listings and reports unless asked for
+	DueAt          string         `json:"due_at,omitempty" xml:"due_at,omitempty"`                   // resolution deadline from the SLA of the department or priority
+	IsOverdue      bool           `json:"is_overdue" xml:"is_overdue"`                               // open past DueAt; set by complaintForViewer
+	SLABreachedAt  string         `json:"sla_breached_at,omitempty" xml:"sla_breached_at,omitempty"` // when the overdue job flagged it
+	Escalated      bool           `json:"escalated" xml:"escalated"`
+	EscalatedAt    string         `json:"escalated_at,omitempty" xml:"escalated_at,omitempty"`
+	Version        int            `json:"version" xml:"version"`                                       // bumped by every change
+	Feedback       *Feedback      `json:"feedback,omitempty" xml:"feedback,omitempty"`                 // admins and the submitter only
+	AdminNotes     []AdminNote    `json:"admin_notes,omitempty" xml:"admin_notes>note,omitempty"`      // admins only
+	AssignedTo     int            `json:"assigned_to,omitempty" xml:"assigned_to,omitempty"`           // admin ID; admins only
+	AssignedToName string         `json:"assigned_to_name,omitempty" xml:"assigned_to_name,omitempty"` // admins only
+	History        []HistoryEntry `json:"history,omitempty" xml:"history>entry,omitempty"`             // admins only
+	User           *ComplaintUser `json:"user,omitempty" xml:"user,omitempty"`                         // admins only; set by complaintForViewer
+	WatchersCount  int            `json:"watchers_count" xml:"watchers_count"`                         // set by complaintForViewer
+	Watchers       []int          `json:"-" xml:"-"`                                                   // IDs of users watching, owner excluded
+	Related        []int          `json:"-" xml:"-"`                                                   // IDs of linked complaints; links are symmetric
+	// RelatedComplaints are the linked complaints the viewer may see; set
+	// by lookupComplaint
+	RelatedComplaints []RelatedComplaint `json:"related_complaints,omitempty" xml:"related_complaints>complaint,omitempty"`
+	// RatingOutOfRange marks a rating outside the configured scale, which
+	// changed since it was given; set by complaintForViewer
+	RatingOutOfRange bool `json:"rating_out_of_range,omitempty" xml:"rating_out_of_range,omitempty"`
+	// SummaryTruncated marks a summary a listing cut short; viewComplaint
+	// has the full text
+	SummaryTruncated bool `json:"summary_truncated,omitempty" xml:"summary_truncated,omitempty"`
+	// EscalationLevel is the highest escalation level the assignee and
+	// watchers were told about, so each level notifies them once
+	EscalationLevel int `json:"escalation_level,omitempty" xml:"escalation_level,omitempty"`
+	// NeedsReview marks a complaint the moderation checker flagged. It stays
+	// off the board until an admin approves or redacts it on /reviewComplaint.
+	NeedsReview bool `json:"needs_review,omitempty" xml:"needs_review,omitempty"`
+	// ModerationReason is why the complaint was flagged; admins only
+	ModerationReason string `json:"moderation_reason,omitempty" xml:"moderation_reason,omitempty"`
+	// PendingConfirmation marks a resolved complaint whose owner has yet to
+	// confirm or reject the resolution, with -confirm-resolutions
+	PendingConfirmation bool `json:"pending_confirmation,omitempty" xml:"pending_confirmation,omitempty"`
+	// ResolvedBy is the admin who resolved the complaint, or proposed its
+	// resolution, and ResolvedByName their name at the time; admins only.
+	// Complaints resolved before it was recorded have neither.
+	ResolvedBy     int    `json:"resolved_by,omitempty" xml:"resolved_by,omitempty"`
+	ResolvedByName string `json:"resolved_by_name,omitempty" xml:"resolved_by_name,omitempty"`
+	// Source is the channel the complaint came in through, e.g. api or
+	// email; see complaintSources. It never changes.
+	Source string `json:"source,omitempty" xml:"source,omitempty"`
+	// Comments are replies added to the complaint, oldest first; admins and
+	// the submitter only
+	Comments []Comment `json:"comments,omitempty" xml:"comments>comment,omitempty"`
+	// Pinned complaints come first in the admin listing, most recently
+	// pinned first, until unpinned or no longer open. PinnedBy is for
+	// admins only.
+	Pinned   bool   `json:"pinned" xml:"pinned"`
+	PinnedAt string `json:"pinned_at,omitempty" xml:"pinned_at,omitempty"`
+	PinnedBy int    `json:"pinned_by,omitempty" xml:"pinned_by,omitempty"`
+
+	words []string // normalized words of the title and summary, for /suggestSimilar
 }
 
 // Request/Response structures
@@ -40,15 +132,36 @@ type LoginRequest struct {
 }
 
 type RegisterRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	InviteCode string `json:"invite_code,omitempty"` // joins its organization instead of the default one; required with -require-invites
+	AcceptTOS  bool   `json:"accept_tos"`            // must be true when -tos-version is set
 }
 
 type SubmitComplaintRequest struct {
-	SecretCode string `json:"secret_code"`
-	Title      string `json:"title"`
-	Summary    string `json:"summary"`
-	Rating     int    `json:"rating"`
+	SecretCode string   `json:"secret_code"`
+	Title      string   `json:"title"`
+	Summary    string   `json:"summary"`
+	Rating     int      `json:"rating"`
+	Priority   string   `json:"priority,omitempty"` // low, medium (default), high or critical
+	Tags       []string `json:"tags,omitempty"`
+	// Department skips the routing rules; its custom fields go in CustomFields
+	Department   string       `json:"department,omitempty"`
+	CustomFields CustomValues `json:"custom_fields,omitempty"`
+	// Building and Floor are checked against the location registry, if
+	// the organization has one
+	Building string `json:"building,omitempty"`
+	Floor    string `json:"floor,omitempty"`
+	Room     string `json:"room,omitempty"`
+	// FromDraft fills in the fields left out from the user's draft, which
+	// is cleared once the complaint is stored
+	FromDraft bool `json:"from_draft,omitempty"`
+	// Suggest stores nothing when there are similar open complaints and
+	// returns those instead, as /suggestSimilar does
+	Suggest bool `json:"suggest,omitempty"`
+	// Source is set by the entry point, never from the body; see
+	// requestSource
+	Source string `json:"-"`
 }
 
 type ViewComplaintRequest struct {
@@ -59,60 +172,153 @@ type ViewComplaintRequest struct {
 type ResolveComplaintRequest struct {
 	SecretCode  string `json:"secret_code"`
 	ComplaintID int    `json:"complaint_id"`
+	Note        string `json:"note,omitempty"`
+	// Version, when set, makes the resolve fail with 409 if the complaint
+	// has changed since the client saw it
+	Version int `json:"version,omitempty"`
 }
 
 type GetComplaintsRequest struct {
-	SecretCode string `json:"secret_code"`
+	SecretCode  string   `json:"secret_code"`
+	Page        *int     `json:"page,omitempty"`
+	PageSize    *int     `json:"page_size,omitempty"`
+	Status      string   `json:"status,omitempty"` // open or resolved
+	Priority    string   `json:"priority,omitempty"`
+	Sort        string   `json:"sort,omitempty"` // oldest, newest, rating_desc, rating_asc or priority
+	Escalated   *bool    `json:"escalated,omitempty"`
+	Tags        []string `json:"tags,omitempty"` // complaints must carry every tag
+	Overdue     *bool    `json:"overdue,omitempty"`
+	Department  string   `json:"department,omitempty"`
+	Building    string   `json:"building,omitempty"`
+	Floor       string   `json:"floor,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	CreatedFrom string   `json:"created_from,omitempty"` // YYYY-MM-DD, UTC, inclusive
+	CreatedTo   string   `json:"created_to,omitempty"`   // YYYY-MM-DD, UTC, inclusive
+	FilterID    int      `json:"filter_id,omitempty"`    // a saved filter whose fields fill in those left out; admin listing only
+	Cursor      *string  `json:"cursor,omitempty"`       // next_cursor of the previous page; "" starts a cursor walk
+	Fields      string   `json:"fields,omitempty"`       // comma-separated complaint fields to return; id is always included
+	Full        bool     `json:"full,omitempty"`         // send summaries whole rather than cut to config.ListSummaryLength
+
+	IncludeArchived bool `json:"include_archived,omitempty"`
 }
 
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success   bool        `json:"success" xml:"success"`
+	Message   string      `json:"message" xml:"message"`
+	Data      interface{} `json:"data,omitempty" xml:"data,omitempty"`
+	Error     string      `json:"error,omitempty" xml:"error,omitempty"`
+	ErrorCode ErrorCode   `json:"error_code,omitempty" xml:"error_code,omitempty"`
 }
 
-// Global storage with mutex for concurrency safety
+// Global storage with mutex for concurrency safety.
+//
+// mutex guards everything in Storage. usersMutex also guards users,
+// secretIndex and the fields of every User: they are only written with both
+// locks held for writing, so either one is enough to read them. That lets
+// logins, which need nothing else, run while complaints are being written.
+// loginMutex alone guards loginHistory and User.LastLoginAt, which logins
+// write. The locks are always taken in that order, mutex then usersMutex then
+// loginMutex, and nothing is locked while holding loginMutex.
 type Storage struct {
-	users      map[int]*User
-	complaints map[int]*Complaint
-	userIDGen  int
-	compIDGen  int
-	mutex      sync.RWMutex
+	users          map[int]*User
+	complaints     map[int]*Complaint
+	references     map[string]int          // complaint reference -> ID
+	secretIndex    map[string]int          // secret code -> user ID
+	submissions    map[int][]time.Time     // user ID -> recent submission times, for throttling
+	notifications  map[int][]*Notification // user ID -> inbox, oldest first
+	loginHistory   map[int]*loginRing      // user ID -> recent logins
+	departments    map[int]*Department
+	apiTokens      map[string]*APIToken // token hash -> token
+	savedFilters   map[int]*SavedFilter
+	drafts         map[int]*Draft // user ID -> unsubmitted complaint
+	organizations  map[int]*Organization
+	invites        map[int]*Invite
+	outbox         map[int]*OutboxMessage // notifications not yet sent, and dead letters
+	tagRules       map[int]*TagRule
+	announcements  map[int]*Announcement
+	buildings      map[int]*Building    // location registries of every organization
+	feedTokens     map[int]*FeedToken   // by admin ID
+	revokedShares  map[string]time.Time // share link ID -> when the link expires anyway
+	auditLog       []AuditEntry         // oldest first
+	userIDGen      int
+	compIDGen      int
+	notifIDGen     int
+	deptIDGen      int
+	apiTokenIDGen  int
+	filterIDGen    int
+	orgIDGen       int
+	inviteIDGen    int
+	outboxIDGen    int
+	tagRuleIDGen   int
+	announceIDGen  int
+	buildingIDGen  int
+	auditIDGen     int
+	defaultAdminID int // the bootstrap super-admin, who is never auto-assigned
+	defaultOrgID   int // the organization of registrations without an invite code
+	mutex          generationLock
+	usersMutex     generationLock
+	loginMutex     sync.Mutex
 }
 
-var storage = &Storage{
-	users:      make(map[int]*User),
-	complaints: make(map[int]*Complaint),
-	userIDGen:  0,
-	compIDGen:  0,
+func newStorage() *Storage {
+	return &Storage{
+		users:         make(map[int]*User),
+		complaints:    make(map[int]*Complaint),
+		references:    make(map[string]int),
+		secretIndex:   make(map[string]int),
+		submissions:   make(map[int][]time.Time),
+		notifications: make(map[int][]*Notification),
+		loginHistory:  make(map[int]*loginRing),
+		departments:   make(map[int]*Department),
+		apiTokens:     make(map[string]*APIToken),
+		savedFilters:  make(map[int]*SavedFilter),
+		drafts:        make(map[int]*Draft),
+		organizations: make(map[int]*Organization),
+		invites:       make(map[int]*Invite),
+		outbox:        make(map[int]*OutboxMessage),
+		tagRules:      make(map[int]*TagRule),
+		announcements: make(map[int]*Announcement),
+		buildings:     make(map[int]*Building),
+		feedTokens:    make(map[int]*FeedToken),
+		revokedShares: make(map[string]time.Time),
+		userIDGen:     0,
+		compIDGen:     0,
+	}
 }
 
+var storage = newStorage()
+
 // Helper functions
-func generateSecretCode() string {
-	return fmt.Sprintf("SEC_%d_%d", time.Now().Unix(), storage.userIDGen+1)
+func generateSecretCode(userID int) string {
+	return fmt.Sprintf("SEC_%d_%d", clock.Now().Unix(), userID)
 }
 
+// timestampLayout is the format of CreatedAt/ResolvedAt, in server local time
+const timestampLayout = "2006-01-02 15:04:05"
+
 func getCurrentTime() string {
-	return time.Now().Format("2006-01-02 15:04:05")
+	return clock.Now().Local().Format(timestampLayout)
+}
+
+// parseTimestamp reads a CreatedAt/ResolvedAt value back into a time
+func parseTimestamp(value string) (time.Time, error) {
+	return time.ParseInLocation(timestampLayout, value, time.Local)
 }
 
 func findUserBySecretCode(secretCode string) *User {
-	storage.mutex.RLock()
-	defer storage.mutex.RUnlock()
-	
-	for _, user := range storage.users {
-		if user.SecretCode == secretCode {
-			return user
-		}
+	storage.usersMutex.RLock()
+	defer storage.usersMutex.RUnlock()
+
+	if userID, exists := storage.secretIndex[secretCode]; exists {
+		return storage.users[userID]
 	}
 	return nil
 }
 
 func findUserByEmail(email string) *User {
-	storage.mutex.RLock()
-	defer storage.mutex.RUnlock()
-	
+	storage.usersMutex.RLock()
+	defer storage.usersMutex.RUnlock()
+
 	for _, user := range storage.users {
 		if user.Email == email {
 			return user
@@ -121,16 +327,90 @@ func findUserByEmail(email string) *User {
 	return nil
 }
 
+// touchComplaint records a change to complaint: it bumps the version, which
+// changes its ETag, and refreshes the owner's copy. Every write path must
+// call it. Callers must hold storage.mutex for writing.
+func touchComplaint(complaint *Complaint) {
+	complaint.Version++
+	syncUserComplaint(complaint)
+}
+
+// checkVersion rejects a write based on a stale copy of complaint. version
+// is what the client last saw; on a mismatch the error carries the current
+// complaint, shaped for user, so the client can merge and retry. Callers must
+// hold storage.mutex for writing until they have called touchComplaint, so
+// no other write can slip in between.
+func checkVersion(user *User, complaint *Complaint, version int) *APIError {
+	if version == complaint.Version {
+		return nil
+	}
+	err := newAPIError(http.StatusConflict, ErrCodeVersionConflict, fmt.Sprintf("Complaint has changed since version %d; it is now at version %d", version, complaint.Version))
+	err.Data = complaintForViewer(user, *complaint)
+	return err
+}
+
+// syncUserComplaint refreshes the owner's copy of complaint in User.Complaints,
+// leaving it out while the complaint is deleted. Callers must hold
+// storage.mutex for writing, but not storage.usersMutex.
+func syncUserComplaint(complaint *Complaint) {
+	storage.usersMutex.Lock()
+	defer storage.usersMutex.Unlock()
+
+	owner, exists := storage.users[complaint.UserID]
+	if !exists {
+		return
+	}
+
+	// Build a new slice so responses already holding the old one are unaffected
+	updated := make([]Complaint, 0, len(owner.Complaints)+1)
+	for _, existing := range owner.Complaints {
+		if existing.ID != complaint.ID {
+			updated = append(updated, existing)
+		}
+	}
+	if !complaint.IsDeleted {
+		updated = append(updated, complaintForViewer(owner, *complaint))
+		sort.Slice(updated, func(i, j int) bool { return updated[i].ID < updated[j].ID })
+	}
+	owner.Complaints = updated
+}
+
+// respondWithJSON writes response with statusCode. It is sent as XML when
+// the client asked for that (see withContentNegotiation) and the data has
+// an XML form; maps, for one, do not. statusCode decides whether it is a
+// success (see consistentResponse). An error replaces whatever headers the
+// handler set for the body it meant to send.
 func respondWithJSON(w http.ResponseWriter, statusCode int, response APIResponse) {
-	w.Header().Set("Content-Type", "application/json")
+	response = consistentResponse(statusCode, response)
+	if statusCode >= http.StatusBadRequest {
+		for _, name := range []string{"Content-Disposition", "Content-Length", "ETag", "Last-Modified", "X-Next-Cursor"} {
+			w.Header().Del(name)
+		}
+	}
+	contentType, body := encodeResponse(w, response)
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(response)
+	w.Write(body)
+}
+
+// encodeResponse serializes response as respondWithJSON sends it to w,
+// returning the content type with the body
+func encodeResponse(w http.ResponseWriter, response APIResponse) (string, []byte) {
+	if wantsXML(w) {
+		if body, err := encodeXML(response); err == nil {
+			return "application/xml; charset=utf-8", body
+		}
+	}
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(response)
+	return "application/json", body.Bytes()
 }
 
-func respondWithError(w http.ResponseWriter, statusCode int, message string) {
+func respondWithError(w http.ResponseWriter, statusCode int, code ErrorCode, message string) {
 	respondWithJSON(w, statusCode, APIResponse{
-		Success: false,
-		Error:   message,
+		Success:   false,
+		Error:     message,
+		ErrorCode: code,
 	})
 }
 
@@ -139,180 +419,400 @@ func respondWithError(w http.ResponseWriter, statusCode int, message string) {
 // /register - Create a new user
 func registerHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	// Validate input
-	if strings.TrimSpace(req.Name) == "" {
-		respondWithError(w, http.StatusBadRequest, "Name is required")
+	name, email := strings.TrimSpace(req.Name), strings.TrimSpace(req.Email)
+	var v validator
+	v.required("name", name)
+	v.maxRunes("name", name, maxNameLength)
+	v.required("email", email)
+	v.maxRunes("email", email, maxEmailLength)
+	v.email("email", email)
+	if termsRequired() && !req.AcceptTOS {
+		v.add("accept_tos", "must be true to accept the terms of service version "+config.TOSVersion)
+	}
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
 		return
 	}
-	if strings.TrimSpace(req.Email) == "" {
-		respondWithError(w, http.StatusBadRequest, "Email is required")
+
+	if apiErr := emailDomains.check(email); apiErr != nil {
+		respondWithAPIError(w, apiErr)
 		return
 	}
 
 	// Check if email already exists
-	if findUserByEmail(req.Email) != nil {
-		respondWithError(w, http.StatusConflict, "User with this email already exists")
+	if findUserByEmail(email) != nil {
+		respondWithError(w, http.StatusConflict, ErrCodeEmailExists, "User with this email already exists")
 		return
 	}
 
 	storage.mutex.Lock()
 	defer storage.mutex.Unlock()
 
+	orgID, invite, apiErr := redeemableInvite(strings.TrimSpace(req.InviteCode), clock.Now())
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
 	storage.userIDGen++
 	newUser := &User{
 		ID:         storage.userIDGen,
-		SecretCode: generateSecretCode(),
-		Name:       strings.TrimSpace(req.Name),
-		Email:      strings.TrimSpace(req.Email),
+		SecretCode: generateSecretCode(storage.userIDGen),
+		Name:       name,
+		Email:      email,
 		Complaints: []Complaint{},
+		OrgID:      orgID,
 		IsAdmin:    false, // Default users are not admin
+		IsActive:   true,
 	}
+	recordTermsAcceptance(newUser)
 
+	storage.usersMutex.Lock()
 	storage.users[newUser.ID] = newUser
+	storage.secretIndex[newUser.SecretCode] = newUser.ID
+	storage.usersMutex.Unlock()
+	if invite != nil {
+		invite.UsedBy = append(invite.UsedBy, newUser.ID)
+	}
+	recordLoginEntry(newUser, newLoginEntry(r, loginEventRegister, true, ""))
 
 	respondWithJSON(w, http.StatusCreated, APIResponse{
 		Success: true,
 		Message: "User registered successfully",
-		Data:    newUser,
+		Data:    snapshotUser(newUser),
 	})
 }
 
 // /login - User login with secret code
 func loginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	if strings.TrimSpace(req.SecretCode) == "" {
-		respondWithError(w, http.StatusBadRequest, "Secret code is required")
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	// Logging in returns the secret code, and a JWT could be traded for a
+	// new one forever
+	if isAPIToken(req.SecretCode) || jwtEnabled() && looksLikeToken(req.SecretCode) {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Log in with a secret code, not a token")
 		return
 	}
 
-	user := findUserBySecretCode(req.SecretCode)
+	// Logging in still works for users who have to accept new terms, so
+	// they can find out
+	user, reason := authenticateCredential(w, r, req.SecretCode)
 	if user == nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid secret code")
+		recordFailedLogin(r, req.SecretCode, reason)
 		return
 	}
 
+	recordLoginEntry(user, newLoginEntry(r, loginEventLogin, true, ""))
+
+	// Logins only read users, so they need not wait for other writes
+	storage.usersMutex.RLock()
+	defer storage.usersMutex.RUnlock()
+
+	var data interface{} = userForViewer(user, snapshotUser(user))
+	if jwtEnabled() {
+		token, expires := issueToken(user, clock.Now())
+		data = LoginResponse{User: userForViewer(user, snapshotUser(user)), Token: token, TokenExpiresAt: expires.Local().Format(timestampLayout)}
+	}
+
 	respondWithJSON(w, http.StatusOK, APIResponse{
 		Success: true,
 		Message: "Login successful",
-		Data:    user,
+		Data:    data,
 	})
 }
 
-// /submitComplaint - Submit a new complaint
-func submitComplaintHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
+// validateSubmission sanitizes the complaint fields of req and checks them,
+// applying the default priority
+func validateSubmission(req SubmitComplaintRequest) (SubmitComplaintRequest, *APIError) {
+	req.Title = sanitizeText(req.Title, false)
+	req.Summary = sanitizeText(req.Summary, true)
+	req.Department = strings.TrimSpace(req.Department)
+	req.Building = sanitizeText(req.Building, false)
+	req.Floor = sanitizeText(req.Floor, false)
+	req.Room = sanitizeText(req.Room, false)
+	if req.Priority == "" {
+		req.Priority = priorityMedium
+	}
+
+	var v validator
+	v.required("title", req.Title)
+	v.maxRunes("title", req.Title, maxTitleLength)
+	v.required("summary", req.Summary)
+	v.maxRunes("summary", req.Summary, maxSummaryLength)
+	scale := currentRatingScale()
+	v.between("rating", req.Rating, scale.Min, scale.Max)
+	v.oneOf("priority", req.Priority, priorities)
+	tags, err := normalizeTags(req.Tags)
+	v.check("tags", err)
+	req.Tags = tags
+	v.maxRunes("department", req.Department, maxDepartmentNameLength)
+	v.maxRunes("building", req.Building, maxLocationLength)
+	v.maxRunes("floor", req.Floor, maxLocationLength)
+	v.maxRunes("room", req.Room, maxLocationLength)
+	return req, v.err()
+}
+
+// storeComplaint adds complaint to storage and to owner's list under the next
+// ID and a new reference, at version 1. Callers must hold storage.mutex for
+// writing, but not storage.usersMutex.
+func storeComplaint(owner *User, complaint Complaint) (*Complaint, error) {
+	reference, err := newReference(storage.references)
+	if err != nil {
+		return nil, err
+	}
+	storage.compIDGen++
+	complaint.ID = storage.compIDGen
+	complaint.Reference = reference
+	complaint.UserID = owner.ID
+	complaint.OrgID = owner.OrgID
+	complaint.UserName = owner.Name
+	complaint.Version = 1
+	complaint.RatingScale = currentRatingScale()
+	if complaint.Source == "" {
+		complaint.Source = sourceAPI
+	}
+	complaint.words = similarityWords(complaint.Title, complaint.Summary)
+	if complaint.Department == "" {
+		complaint.Department = routeComplaint(&complaint)
+	}
+	if complaint.DueAt == "" {
+		setDueAt(&complaint)
+	}
+
+	stored := &complaint
+	storage.complaints[stored.ID] = stored
+	storage.references[stored.Reference] = stored.ID
+	shaped := complaintForViewer(owner, *stored)
+	storage.usersMutex.Lock()
+	owner.Complaints = append(owner.Complaints, shaped)
+	storage.usersMutex.Unlock()
+	return stored, nil
+}
+
+// checkComplaint builds the complaint user, who authenticated with
+// secretCode, would file with a validated submission, running every check
+// it must pass before it is stored: the credential, the department and its
+// custom fields, the location and the submission limits at now. It stores
+// nothing, so /validateComplaint runs exactly the checks /submitComplaint
+// does. Callers must hold storage.mutex for writing.
+func checkComplaint(user *User, secretCode string, req SubmitComplaintRequest, now time.Time) (Complaint, *APIError) {
+	// The secret code may have been rotated since it was looked up
+	if !credentialBelongsTo(secretCode, user.ID) {
+		return Complaint{}, newAPIError(http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid secret code")
+	}
+
+	complaint := Complaint{
+		Title:     req.Title,
+		Summary:   req.Summary,
+		Rating:    req.Rating,
+		Priority:  req.Priority,
+		OrgID:     user.OrgID,
+		CreatedAt: now.Local().Format(timestampLayout),
+		Source:    req.Source,
+	}
+	complaint.Tags, _ = applyTagRules(user.OrgID, req.Tags, req.Title, req.Summary)
+
+	// The department decides which custom fields the complaint needs
+	if req.Department != "" {
+		name, exists := resolveDepartmentName(req.Department)
+		if !exists {
+			return Complaint{}, newAPIError(http.StatusBadRequest, ErrCodeValidationFailed, "Department does not exist")
+		}
+		complaint.Department = name
+	} else {
+		complaint.Department = routeComplaint(&complaint)
+	}
+	customFields, apiErr := validateCustomFields(findDepartment(complaint.Department), req.CustomFields)
+	if apiErr != nil {
+		return Complaint{}, apiErr
+	}
+	complaint.CustomFields = customFields
+	complaint.Building, complaint.Floor, apiErr = checkLocation(user.OrgID, req.Building, req.Floor)
+	if apiErr != nil {
+		return Complaint{}, apiErr
+	}
+	complaint.Room = req.Room
+
+	if err := checkSubmissionLimits(user, now); err != nil {
+		return Complaint{}, err
+	}
+	return complaint, nil
+}
+
+// createComplaint stores a validated submission from user, who authenticated
+// with secretCode, and returns it shaped for them. correlationID is that of
+// the request.
+func createComplaint(user *User, secretCode string, req SubmitComplaintRequest, correlationID string) (Complaint, *APIError) {
+	// Before locking, as the checker may call out to another service
+	verdict := moderate(req.Title, req.Summary)
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	now := clock.Now()
+	complaint, apiErr := checkComplaint(user, secretCode, req, now)
+	if apiErr != nil {
+		return Complaint{}, apiErr
+	}
+	complaint.NeedsReview = verdict.Flagged
+	complaint.ModerationReason = verdict.Reason
+
+	autoAssign(&complaint, correlationID)
+	newComplaint, err := storeComplaint(user, complaint)
+	if err != nil {
+		return Complaint{}, newAPIError(http.StatusInternalServerError, ErrCodeInternal, "Failed to store complaint")
 	}
+	recordSubmission(user.ID, now)
+	if req.FromDraft {
+		delete(storage.drafts, user.ID)
+	}
+	publishEvent(eventComplaintCreated, newComplaint, correlationID)
+	if newComplaint.AssignedTo != 0 {
+		publishEvent(eventComplaintAssigned, newComplaint, correlationID)
+	}
+
+	return complaintForViewer(user, *newComplaint), nil
+}
 
+// decodeSubmission reads a /submitComplaint or /validateComplaint request,
+// validates its fields and authenticates it, filling in the caller's draft
+// first when asked to. It writes the error response when any of that fails.
+func decodeSubmission(w http.ResponseWriter, r *http.Request) (SubmitComplaintRequest, *User) {
 	var req SubmitComplaintRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
-		return
+	if !decodeJSON(w, r, &req) {
+		return req, nil
 	}
 
 	// Validate input
 	if strings.TrimSpace(req.SecretCode) == "" {
-		respondWithError(w, http.StatusBadRequest, "Secret code is required")
-		return
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return req, nil
 	}
-	if strings.TrimSpace(req.Title) == "" {
-		respondWithError(w, http.StatusBadRequest, "Title is required")
-		return
+	// A submission from a draft can only be checked once the draft is found
+	var apiErr *APIError
+	if !req.FromDraft {
+		if req, apiErr = validateSubmission(req); apiErr != nil {
+			respondWithAPIError(w, apiErr)
+			return req, nil
+		}
 	}
-	if strings.TrimSpace(req.Summary) == "" {
-		respondWithError(w, http.StatusBadRequest, "Summary is required")
-		return
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return req, nil
+	}
+
+	if req.FromDraft {
+		if req, apiErr = withDraft(user, req); apiErr == nil {
+			req, apiErr = validateSubmission(req)
+		}
+		if apiErr != nil {
+			respondWithAPIError(w, apiErr)
+			return req, nil
+		}
 	}
-	if req.Rating < 1 || req.Rating > 10 {
-		respondWithError(w, http.StatusBadRequest, "Rating must be between 1 and 10")
+	req.Source = requestSource(r)
+	return req, user
+}
+
+// /submitComplaint - Submit a new complaint
+func submitComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
+	deprecatedRoute(w, "/v1/complaints")
 
-	user := findUserBySecretCode(req.SecretCode)
+	req, user := decodeSubmission(w, r)
 	if user == nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid secret code")
 		return
 	}
 
-	storage.mutex.Lock()
-	defer storage.mutex.Unlock()
-
-	storage.compIDGen++
-	newComplaint := &Complaint{
-		ID:         storage.compIDGen,
-		Title:      strings.TrimSpace(req.Title),
-		Summary:    strings.TrimSpace(req.Summary),
-		Rating:     req.Rating,
-		UserID:     user.ID,
-		UserName:   user.Name,
-		IsResolved: false,
-		CreatedAt:  getCurrentTime(),
+	if req.Suggest {
+		storage.mutex.RLock()
+		similar := similarComplaints(user, req.Title, req.Summary)
+		storage.mutex.RUnlock()
+		if len(similar) > 0 {
+			respondWithJSON(w, http.StatusOK, APIResponse{
+				Success: true,
+				Message: "Similar complaints found; submit again without suggest to file yours anyway",
+				Data:    similar,
+			})
+			return
+		}
 	}
 
-	storage.complaints[newComplaint.ID] = newComplaint
-	user.Complaints = append(user.Complaints, *newComplaint)
+	complaint, apiErr := createComplaint(user, req.SecretCode, req, correlationID(r))
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
 
 	respondWithJSON(w, http.StatusCreated, APIResponse{
 		Success: true,
 		Message: "Complaint submitted successfully",
-		Data:    newComplaint,
+		Data:    complaint,
 	})
 }
 
 // /getAllComplaintsForUser - Get all complaints for a specific user
 func getAllComplaintsForUserHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req GetComplaintsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	if strings.TrimSpace(req.SecretCode) == "" {
-		respondWithError(w, http.StatusBadRequest, "Secret code is required")
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
 		return
 	}
 
-	user := findUserBySecretCode(req.SecretCode)
+	opts, err := parseListOptions(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
 	if user == nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid secret code")
 		return
 	}
 
 	storage.mutex.RLock()
 	defer storage.mutex.RUnlock()
 
-	var userComplaints []Complaint
-	for _, complaint := range storage.complaints {
-		if complaint.UserID == user.ID {
-			userComplaints = append(userComplaints, *complaint)
-		}
-	}
+	caller := actorOf(user)
+	userComplaints := listComplaints(user, func(c *Complaint) bool {
+		return caller.owns(c)
+	}, opts)
 
 	respondWithJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -324,87 +824,114 @@ func getAllComplaintsForUserHandler(w http.ResponseWriter, r *http.Request) {
 // /getAllComplaintsForAdmin - Get all complaints (admin only)
 func getAllComplaintsForAdminHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req GetComplaintsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	if strings.TrimSpace(req.SecretCode) == "" {
-		respondWithError(w, http.StatusBadRequest, "Secret code is required")
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
 		return
 	}
 
-	user := findUserBySecretCode(req.SecretCode)
+	// With a saved filter the options can only be checked once it is found
+	var opts listOptions
+	if req.FilterID == 0 {
+		var err error
+		if opts, err = parseListOptions(req); err != nil {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+			return
+		}
+	}
+
+	user := authenticate(w, r, req.SecretCode)
 	if user == nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid secret code")
 		return
 	}
 
-	if !user.IsAdmin {
-		respondWithError(w, http.StatusForbidden, "Access denied. Admin privileges required")
+	if !authorize(w, adminOnly(actorOf(user))) {
 		return
 	}
 
+	// A saved filter fills in the fields the request leaves out
+	if req.FilterID != 0 {
+		filter, apiErr := savedFilterFor(user, req.FilterID)
+		if apiErr != nil {
+			respondWithAPIError(w, apiErr)
+			return
+		}
+		var err error
+		if opts, err = parseListOptions(withFilter(req, filter)); err != nil {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+			return
+		}
+	}
+
+	opts.pinnedFirst = true
+
+	readCache.serve(w, r, cacheKey("/getAllComplaintsForAdmin", user.ID, opts.cacheKey()), func() APIResponse {
+		return APIResponse{
+			Success: true,
+			Message: "All complaints retrieved successfully",
+			Data:    listComplaints(user, func(c *Complaint) bool { return true }, opts),
+		}
+	})
+}
+
+// lookupComplaint returns complaint id as user may see it. Deleted complaints
+// stay visible to admins, flagged by is_deleted.
+func lookupComplaint(user *User, id int) (Complaint, *APIError) {
 	storage.mutex.RLock()
 	defer storage.mutex.RUnlock()
 
-	var allComplaints []Complaint
-	for _, complaint := range storage.complaints {
-		allComplaints = append(allComplaints, *complaint)
+	complaint, err := accessibleComplaint(user, id, true, "Access denied. You can only view your own complaints")
+	if err != nil {
+		return Complaint{}, err
 	}
 
-	respondWithJSON(w, http.StatusOK, APIResponse{
-		Success: true,
-		Message: "All complaints retrieved successfully",
-		Data:    allComplaints,
-	})
+	shaped := complaintForViewer(user, *complaint)
+	shaped.RelatedComplaints = relatedComplaints(user, complaint)
+	return shaped, nil
 }
 
 // /viewComplaint - View a specific complaint
 func viewComplaintHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
+	deprecatedRoute(w, "/v1/complaints/{id}")
 
 	var req ViewComplaintRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	if strings.TrimSpace(req.SecretCode) == "" {
-		respondWithError(w, http.StatusBadRequest, "Secret code is required")
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
 		return
 	}
 	if req.ComplaintID <= 0 {
-		respondWithError(w, http.StatusBadRequest, "Valid complaint ID is required")
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
 		return
 	}
 
-	user := findUserBySecretCode(req.SecretCode)
+	user := authenticate(w, r, req.SecretCode)
 	if user == nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid secret code")
 		return
 	}
 
-	storage.mutex.RLock()
-	defer storage.mutex.RUnlock()
-
-	complaint, exists := storage.complaints[req.ComplaintID]
-	if !exists {
-		respondWithError(w, http.StatusNotFound, "Complaint not found")
+	complaint, apiErr := lookupComplaint(user, req.ComplaintID)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
 		return
 	}
 
-	// Check if user has permission to view this complaint
-	if !user.IsAdmin && complaint.UserID != user.ID {
-		respondWithError(w, http.StatusForbidden, "Access denied. You can only view your own complaints")
+	if notModified(w, r, complaintETag(complaint)) {
 		return
 	}
 
@@ -415,67 +942,99 @@ func viewComplaintHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// resolveComplaint marks complaint id resolved on behalf of user, who
+// authenticated with secretCode, and returns it shaped for them. A zero
+// version skips the concurrency check, so the last resolve wins.
+func resolveComplaint(user *User, secretCode string, id int, note string, version int, correlationID string) (Complaint, *APIError) {
+	if apiErr := canResolve(actorOf(user)).apiError(); apiErr != nil {
+		return Complaint{}, apiErr
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	// The secret code may have been rotated since it was looked up
+	if !credentialBelongsTo(secretCode, user.ID) {
+		return Complaint{}, newAPIError(http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid secret code")
+	}
+
+	complaint := scopedComplaint(user, id)
+	if complaint == nil || complaint.IsDeleted {
+		return Complaint{}, newAPIError(http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+	}
+
+	if version != 0 {
+		if err := checkVersion(user, complaint, version); err != nil {
+			return Complaint{}, err
+		}
+	}
+
+	if complaint.IsResolved {
+		return Complaint{}, newAPIError(http.StatusBadRequest, ErrCodeAlreadyResolved, "Complaint is already resolved")
+	}
+	if complaint.MergedInto != 0 {
+		return Complaint{}, newAPIError(http.StatusConflict, ErrCodeAlreadyMerged, fmt.Sprintf("Complaint was merged into complaint %d; resolve that one instead", complaint.MergedInto))
+	}
+
+	complaint.ResolutionNote = sanitizeText(note, true)
+	complaint.ResolvedBy = user.ID
+	complaint.ResolvedByName = user.Name
+	unpinComplaint(complaint, HistoryEntry{ActorID: user.ID, Detail: "Resolved", CorrelationID: correlationID})
+	if config.ConfirmResolutions {
+		// The owner has the last word; see resolution.go
+		transitionResolution(complaint, HistoryEntry{Action: historyResolutionProposed, ActorID: user.ID, Detail: complaint.ResolutionNote, CorrelationID: correlationID})
+		notifyOwner(complaint, notificationConfirmResolution, fmt.Sprintf("Your complaint %q has been marked resolved. Confirm the resolution, or reject it if the problem remains", complaint.Title))
+	} else {
+		complaint.IsResolved = true
+		complaint.ResolvedAt = getCurrentTime()
+		addHistory(complaint, HistoryEntry{Action: historyResolved, ActorID: user.ID, Detail: complaint.ResolutionNote, CorrelationID: correlationID})
+		touchComplaint(complaint)
+		notifyOwner(complaint, notificationResolved, fmt.Sprintf("Your complaint %q has been resolved", complaint.Title))
+	}
+	notifyWatchers(complaint, notificationResolved, fmt.Sprintf("A complaint you are watching, %q, has been resolved", complaint.Title), false)
+	publishEvent(eventComplaintResolved, complaint, correlationID)
+	resolveDuplicates(complaint, correlationID)
+
+	return complaintForViewer(user, *complaint), nil
+}
+
 // /resolveComplaint - Mark a complaint as resolved (admin only)
 func resolveComplaintHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
+	deprecatedRoute(w, "/v1/complaints/{id}/resolve")
 
 	var req ResolveComplaintRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	if strings.TrimSpace(req.SecretCode) == "" {
-		respondWithError(w, http.StatusBadRequest, "Secret code is required")
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
 		return
 	}
 	if req.ComplaintID <= 0 {
-		respondWithError(w, http.StatusBadRequest, "Valid complaint ID is required")
-		return
-	}
-
-	user := findUserBySecretCode(req.SecretCode)
-	if user == nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid secret code")
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
 		return
 	}
-
-	if !user.IsAdmin {
-		respondWithError(w, http.StatusForbidden, "Access denied. Admin privileges required")
+	if req.Version < 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Version must be positive")
 		return
 	}
 
-	storage.mutex.Lock()
-	defer storage.mutex.Unlock()
-
-	complaint, exists := storage.complaints[req.ComplaintID]
-	if !exists {
-		respondWithError(w, http.StatusNotFound, "Complaint not found")
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
 		return
 	}
 
-	if complaint.IsResolved {
-		respondWithError(w, http.StatusBadRequest, "Complaint is already resolved")
+	complaint, apiErr := resolveComplaint(user, req.SecretCode, req.ComplaintID, req.Note, req.Version, correlationID(r))
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
 		return
 	}
 
-	complaint.IsResolved = true
-	complaint.ResolvedAt = getCurrentTime()
-
-	// Update the complaint in user's list as well
-	if userOwner, exists := storage.users[complaint.UserID]; exists {
-		for i := range userOwner.Complaints {
-			if userOwner.Complaints[i].ID == complaint.ID {
-				userOwner.Complaints[i].IsResolved = true
-				userOwner.Complaints[i].ResolvedAt = complaint.ResolvedAt
-				break
-			}
-		}
-	}
-
 	respondWithJSON(w, http.StatusOK, APIResponse{
 		Success: true,
 		Message: "Complaint resolved successfully",
@@ -483,58 +1042,431 @@ func resolveComplaintHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Create default admin user
+// Create the default organization and its super-admin
 func createDefaultAdmin() {
 	storage.mutex.Lock()
 	defer storage.mutex.Unlock()
 
+	storage.orgIDGen++
+	org := &Organization{ID: storage.orgIDGen, Name: defaultOrganizationName, CreatedAt: getCurrentTime()}
+	storage.organizations[org.ID] = org
+	storage.defaultOrgID = org.ID
+
 	storage.userIDGen++
 	adminUser := &User{
-		ID:         storage.userIDGen,
-		SecretCode: "ADMIN_SECRET_123",
-		Name:       "System Administrator",
-		Email:      "admin@complaintportal.com",
-		Complaints: []Complaint{},
-		IsAdmin:    true,
+		ID:           storage.userIDGen,
+		SecretCode:   "ADMIN_SECRET_123",
+		Name:         "System Administrator",
+		Email:        "admin@complaintportal.com",
+		Complaints:   []Complaint{},
+		OrgID:        org.ID,
+		IsAdmin:      true,
+		IsSuperAdmin: true,
+		IsActive:     true,
 	}
 
+	storage.usersMutex.Lock()
 	storage.users[adminUser.ID] = adminUser
-	fmt.Println("Default admin created with secret code:", adminUser.SecretCode)
+	storage.secretIndex[adminUser.SecretCode] = adminUser.ID
+	storage.usersMutex.Unlock()
+	storage.defaultAdminID = adminUser.ID
+	fmt.Fprintln(os.Stderr, "Default admin created with secret code:", adminUser.SecretCode)
+}
+
+// newRouter registers every API route on a fresh ServeMux. Each route is
+// registered as a read or a write, which decides whether it keeps working in
+// maintenance mode.
+func newRouter() http.Handler {
+	routes := newRouteTable()
+	routes.write("/register", registerHandler)
+	routes.read("/login", loginHandler)
+	routes.write("/submitComplaint", submitComplaintHandler)
+	routes.read("/validateComplaint", validateComplaintHandler)
+	routes.read("/getAllComplaintsForUser", getAllComplaintsForUserHandler)
+	routes.read("/getAllComplaintsForAdmin", getAllComplaintsForAdminHandler)
+	routes.read("/viewComplaint", viewComplaintHandler)
+	routes.write("/resolveComplaint", resolveComplaintHandler)
+	routes.write("/rotateSecretCode", rotateSecretCodeHandler)
+	routes.write("/unlockUser", unlockUserHandler)
+	routes.write("/deactivateUser", deactivateUserHandler)
+	routes.write("/reactivateUser", reactivateUserHandler)
+	routes.read("/report", reportHandler)
+	routes.read("/events", eventsHandler)
+	routes.read("/ws", wsHandler)
+	routes.read("/feed.atom", feedHandler)
+	routes.write("/createFeedToken", createFeedTokenHandler)
+	routes.write("/addAdminNote", addAdminNoteHandler)
+	routes.write("/addComment", addCommentHandler)
+	routes.write("/editComment", editCommentHandler)
+	routes.write("/deleteComment", deleteCommentHandler)
+	routes.write("/deleteComplaint", deleteComplaintHandler)
+	routes.read("/listDeletedComplaints", listDeletedComplaintsHandler)
+	routes.write("/restoreComplaint", restoreComplaintHandler)
+	routes.write("/purgeDeletedComplaints", purgeDeletedComplaintsHandler)
+	routes.write("/runJobs", runJobsHandler)
+	routes.read("/retentionPreview", retentionPreviewHandler)
+	routes.read("/me", meHandler)
+	routes.read("/myStats", myStatsHandler)
+	routes.read("/userStats", userStatsHandler)
+	routes.read("/getNotifications", getNotificationsHandler)
+	routes.write("/markNotificationRead", markNotificationReadHandler)
+	routes.read("/board", boardHandler)
+	routes.read("/publicStats", publicStatsHandler)
+	routes.write("/setPriority", setPriorityHandler)
+	routes.write("/seed", seedHandler)
+	routes.read("/exportComplaints", exportComplaintsHandler)
+	routes.read("/exportReport", exportReportHandler)
+	routes.write("/importComplaints", importComplaintsHandler)
+	routes.write("/submitFeedback", submitFeedbackHandler)
+	routes.write("/confirmResolution", confirmResolutionHandler)
+	routes.write("/rejectResolution", rejectResolutionHandler)
+	routes.write("/setComplaintTags", setComplaintTagsHandler)
+	routes.read("/listTags", listTagsHandler)
+	routes.write("/addTagRule", addTagRuleHandler)
+	routes.read("/listTagRules", listTagRulesHandler)
+	routes.write("/deleteTagRule", deleteTagRuleHandler)
+	routes.read("/previewTagRules", previewTagRulesHandler)
+	routes.write("/createAnnouncement", createAnnouncementHandler)
+	routes.write("/deleteAnnouncement", deleteAnnouncementHandler)
+	routes.read("/getAnnouncements", getAnnouncementsHandler)
+	routes.write("/addBuilding", addBuildingHandler)
+	routes.read("/listBuildings", listBuildingsHandler)
+	routes.write("/updateBuilding", updateBuildingHandler)
+	routes.write("/deleteBuilding", deleteBuildingHandler)
+	routes.read("/exportMyData", exportMyDataHandler)
+	routes.read("/loginHistory", loginHistoryHandler)
+	routes.write("/acceptTos", acceptTOSHandler)
+	routes.read("/listUsers", listUsersHandler)
+	routes.read("/reloadEmailDomains", reloadEmailDomainsHandler)
+	routes.read("/reviewQueue", reviewQueueHandler)
+	routes.write("/reviewComplaint", reviewComplaintHandler)
+	routes.write("/addDepartment", addDepartmentHandler)
+	routes.read("/listDepartments", listDepartmentsHandler)
+	routes.write("/updateDepartment", updateDepartmentHandler)
+	routes.write("/deleteDepartment", deleteDepartmentHandler)
+	routes.write("/setComplaintDepartment", setComplaintDepartmentHandler)
+	routes.write("/linkComplaints", linkComplaintsHandler)
+	routes.write("/unlinkComplaints", unlinkComplaintsHandler)
+	routes.write("/saveFilter", saveFilterHandler)
+	routes.read("/listFilters", listFiltersHandler)
+	routes.write("/deleteFilter", deleteFilterHandler)
+	routes.write("/saveDraft", saveDraftHandler)
+	routes.read("/getDraft", getDraftHandler)
+	routes.write("/createOrganization", createOrganizationHandler)
+	routes.read("/listOrganizations", listOrganizationsHandler)
+	routes.write("/createInvite", createInviteHandler)
+	routes.read("/listInvites", listInvitesHandler)
+	routes.write("/archiveComplaint", archiveComplaintHandler)
+	routes.write("/unarchiveComplaint", unarchiveComplaintHandler)
+	routes.read("/suggestSimilar", suggestSimilarHandler)
+	routes.read("/getMyAssignedComplaints", getMyAssignedComplaintsHandler)
+	routes.read("/triageQueue", triageQueueHandler)
+	routes.write("/mergeComplaints", mergeComplaintsHandler)
+	routes.write("/createShareLink", createShareLinkHandler)
+	routes.write("/revokeShareLink", revokeShareLinkHandler)
+	routes.read(sharedPathPrefix, sharedComplaintHandler)
+	routes.write("/createApiToken", createAPITokenHandler)
+	routes.read("/listApiTokens", listAPITokensHandler)
+	routes.write("/revokeApiToken", revokeAPITokenHandler)
+	routes.write("/watchComplaint", watchComplaintHandler)
+	routes.write("/unwatchComplaint", unwatchComplaintHandler)
+	routes.read("/listWatchers", listWatchersHandler)
+	routes.read("/agingReport", agingReportHandler)
+	routes.read("/analytics/ratings", ratingAnalyticsHandler)
+	routes.read("/adminWorkload", adminWorkloadHandler)
+	routes.write("/ingestEmail", ingestEmailHandler)
+	routes.write("/pinComplaint", pinComplaintHandler)
+	routes.write("/unpinComplaint", unpinComplaintHandler)
+	routes.read("/admin/backup", backupHandler)
+	routes.write("/admin/restore", restoreHandler)
+	routes.read("/admin/deadLetters", deadLettersHandler)
+	routes.write("/admin/retryDeadLetter", retryDeadLetterHandler)
+
+	// Resource-oriented API; the flat routes above remain as adapters
+	routes.handle("/v1/", routeWrite, newV1Router())
+
+	// Health check endpoint
+	routes.read("/health", healthLiveHandler)
+	routes.read("/health/live", healthLiveHandler)
+	routes.read("/health/ready", healthReadyHandler)
+	routes.read("/config", configHandler)
+	routes.read("/metrics", metricsHandler)
+
+	// Must keep working in maintenance mode so it can be turned off
+	routes.handle("/setMaintenanceMode", routeControl, http.HandlerFunc(setMaintenanceModeHandler))
+	routes.handle("/admin/logLevel", routeControl, http.HandlerFunc(logLevelHandler))
+
+	return routes
+}
+
+// newHandler wraps the router in the middleware shared by every route
+func newHandler() http.Handler {
+	return withClientIP(withCorrelationID(withLogging(withMetrics(withTimeout(config.RequestTimeout, withGzip(withComplaintReferences(withContentNegotiation(newRouter()))))))))
+}
+
+// newServer configures the HTTP server. WriteTimeout must leave room for a
+// timed-out request to receive its 503.
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
 }
 
 func main() {
+	parseFlags()
+	if err := setupLogging(os.Stderr, config.LogFormat, config.LogLevel); err != nil {
+		fatal("Invalid configuration", logKeyError, err)
+	}
+
+	// Check the whole configuration before anything is loaded or bound, so
+	// that every mistake in it is reported at once
+	results := runSelfCheck(config.CheckEndpoints)
+	printSelfCheck(os.Stderr, results)
+	if config.CheckOnly {
+		os.Exit(selfCheckExitCode(results))
+	}
+	if selfCheckFailed(results) {
+		fatal("Invalid configuration; see the self-check above")
+	}
+
+	if err := emailDomains.load(); err != nil {
+		fatal("Invalid configuration", logKeyError, err)
+	}
+	words, err := loadModerationWords(config.ModerationWordsFile)
+	if err != nil {
+		fatal("Invalid configuration", logKeyError, err)
+	}
+	moderator = newWordlistChecker(words)
+
 	// Create default admin user
 	createDefaultAdmin()
 
-	// Setup routes
-	http.HandleFunc("/register", registerHandler)
-	http.HandleFunc("/login", loginHandler)
-	http.HandleFunc("/submitComplaint", submitComplaintHandler)
-	http.HandleFunc("/getAllComplaintsForUser", getAllComplaintsForUserHandler)
-	http.HandleFunc("/getAllComplaintsForAdmin", getAllComplaintsForAdminHandler)
-	http.HandleFunc("/viewComplaint", viewComplaintHandler)
-	http.HandleFunc("/resolveComplaint", resolveComplaintHandler)
+	// Start from a backup, which replaces the default admin too
+	if config.RestoreFile != "" {
+		result, err := loadBackupFile(config.RestoreFile)
+		if err != nil {
+			fatal("Restoring failed", "file", config.RestoreFile, logKeyError, err)
+		}
+		logger.Info("Restored users and complaints", "users", result.Users, "complaints", result.Complaints, "file", config.RestoreFile, "schema_version", result.SchemaVersion)
+		storage.mutex.RLock()
+		if n := countRatingsOutOfScale(); n > 0 {
+			logger.Warn("Complaints rated outside the scale are kept and flagged", "count", n, "rating_min", config.RatingMin, "rating_max", config.RatingMax)
+		}
+		storage.mutex.RUnlock()
+	}
+
+	// Demo or load test data
+	if err := seedFromFlags(); err != nil {
+		fatal("Seeding failed", logKeyError, err)
+	}
 
-	// Health check endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		respondWithJSON(w, http.StatusOK, APIResponse{
-			Success: true,
-			Message: "Complaint Portal API is running",
-		})
-	})
+	// Fail before doing anything else if HTTPS cannot be served
+	server := newServer(config.Addr, newHandler())
+	scheme := "http"
+	tlsConfig, certManager, err := serverTLS()
+	if err != nil {
+		fatal("TLS setup failed", logKeyError, err)
+	}
+	if tlsConfig != nil {
+		server.TLSConfig = tlsConfig
+		scheme = "https"
+	}
+	var redirect *http.Server
+	if config.HTTPRedirectAddr != "" {
+		handler := httpsRedirect(config.Addr)
+		if certManager != nil {
+			// Let's Encrypt's HTTP challenges come in on port 80
+			handler = certManager.HTTPHandler(handler)
+		}
+		redirect = newServer(config.HTTPRedirectAddr, handler)
+	}
+
+	if config.MaintenanceMode {
+		maintenance.set(true, config.MaintenanceMessage, config.MaintenanceRetryAfter)
+		logger.Info("Starting in read-only maintenance mode")
+	}
+
+	// Escalation, trash purging and other periodic work
+	jobRunner.start(scheduledJobs())
 
-	port := ":8080"
-	fmt.Printf("Complaint Portal API server starting on port %s\n", port)
-	fmt.Println("Available endpoints:")
-	fmt.Println("  POST /register")
-	fmt.Println("  POST /login")
-	fmt.Println("  POST /submitComplaint")
-	fmt.Println("  POST /getAllComplaintsForUser")
-	fmt.Println("  POST /getAllComplaintsForAdmin")
-	fmt.Println("  POST /viewComplaint")
-	fmt.Println("  POST /resolveComplaint")
-	fmt.Println("  GET  /health")
-	fmt.Println("\nDefault Admin Secret Code: ADMIN_SECRET_123")
-
-	log.Fatal(http.ListenAndServe(port, nil))
-}
\ No newline at end of file
+	// Post urgent and escalated complaints to Slack and email owners when
+	// their complaints are resolved, through the outbox
+	channels := map[string]outboxChannel{}
+	if config.SlackWebhookURL != "" {
+		channels[outboxChannelSlack] = newSlackNotifier(config.SlackWebhookURL, config.SlackLinkTemplate)
+	}
+	if config.SMTPHost != "" {
+		channels[outboxChannelEmail] = newMailer(newSMTPNotifier(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.SMTPFrom))
+	}
+	dispatcher = newDispatcher(channels)
+	dispatcher.start()
+	defer dispatcher.shutdown()
+
+	fmt.Fprintf(os.Stderr, "Complaint Portal API server starting on %s://%s\n", scheme, config.Addr)
+	if redirect != nil {
+		fmt.Fprintf(os.Stderr, "Redirecting http://%s to HTTPS\n", config.HTTPRedirectAddr)
+	}
+	fmt.Fprintln(os.Stderr, "Available endpoints:")
+	fmt.Fprintln(os.Stderr, "  POST /register")
+	fmt.Fprintln(os.Stderr, "  POST /login")
+	fmt.Fprintln(os.Stderr, "  POST /submitComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /validateComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /getAllComplaintsForUser")
+	fmt.Fprintln(os.Stderr, "  POST /getAllComplaintsForAdmin")
+	fmt.Fprintln(os.Stderr, "  POST /viewComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /resolveComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /rotateSecretCode")
+	fmt.Fprintln(os.Stderr, "  POST /unlockUser")
+	fmt.Fprintln(os.Stderr, "  POST /deactivateUser")
+	fmt.Fprintln(os.Stderr, "  POST /reactivateUser")
+	fmt.Fprintln(os.Stderr, "  POST /report")
+	fmt.Fprintln(os.Stderr, "  GET  /events")
+	fmt.Fprintln(os.Stderr, "  GET  /ws")
+	fmt.Fprintln(os.Stderr, "  GET  /feed.atom")
+	fmt.Fprintln(os.Stderr, "  POST /createFeedToken")
+	fmt.Fprintln(os.Stderr, "  POST /addAdminNote")
+	fmt.Fprintln(os.Stderr, "  POST /addComment")
+	fmt.Fprintln(os.Stderr, "  POST /editComment")
+	fmt.Fprintln(os.Stderr, "  POST /deleteComment")
+	fmt.Fprintln(os.Stderr, "  POST /deleteComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /listDeletedComplaints")
+	fmt.Fprintln(os.Stderr, "  POST /restoreComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /purgeDeletedComplaints")
+	fmt.Fprintln(os.Stderr, "  POST /runJobs")
+	fmt.Fprintln(os.Stderr, "  POST /retentionPreview")
+	fmt.Fprintln(os.Stderr, "  POST /me")
+	fmt.Fprintln(os.Stderr, "  POST /myStats")
+	fmt.Fprintln(os.Stderr, "  POST /userStats")
+	fmt.Fprintln(os.Stderr, "  POST /getNotifications")
+	fmt.Fprintln(os.Stderr, "  POST /markNotificationRead")
+	fmt.Fprintln(os.Stderr, "  GET  /board")
+	fmt.Fprintln(os.Stderr, "  GET  /publicStats")
+	fmt.Fprintln(os.Stderr, "  POST /setPriority")
+	fmt.Fprintln(os.Stderr, "  POST /seed (with -dev)")
+	fmt.Fprintln(os.Stderr, "  GET  /exportComplaints")
+	fmt.Fprintln(os.Stderr, "  GET  /exportReport")
+	fmt.Fprintln(os.Stderr, "  POST /importComplaints")
+	fmt.Fprintln(os.Stderr, "  POST /submitFeedback")
+	fmt.Fprintln(os.Stderr, "  POST /confirmResolution")
+	fmt.Fprintln(os.Stderr, "  POST /rejectResolution")
+	fmt.Fprintln(os.Stderr, "  POST /setComplaintTags")
+	fmt.Fprintln(os.Stderr, "  POST /listTags")
+	fmt.Fprintln(os.Stderr, "  POST /addTagRule")
+	fmt.Fprintln(os.Stderr, "  POST /listTagRules")
+	fmt.Fprintln(os.Stderr, "  POST /deleteTagRule")
+	fmt.Fprintln(os.Stderr, "  POST /previewTagRules")
+	fmt.Fprintln(os.Stderr, "  POST /createAnnouncement")
+	fmt.Fprintln(os.Stderr, "  POST /deleteAnnouncement")
+	fmt.Fprintln(os.Stderr, "  POST /getAnnouncements")
+	fmt.Fprintln(os.Stderr, "  POST /addBuilding")
+	fmt.Fprintln(os.Stderr, "  POST /listBuildings")
+	fmt.Fprintln(os.Stderr, "  POST /updateBuilding")
+	fmt.Fprintln(os.Stderr, "  POST /deleteBuilding")
+	fmt.Fprintln(os.Stderr, "  POST /exportMyData")
+	fmt.Fprintln(os.Stderr, "  POST /loginHistory")
+	fmt.Fprintln(os.Stderr, "  POST /acceptTos")
+	fmt.Fprintln(os.Stderr, "  POST /listUsers")
+	fmt.Fprintln(os.Stderr, "  POST /reloadEmailDomains")
+	fmt.Fprintln(os.Stderr, "  POST /reviewQueue")
+	fmt.Fprintln(os.Stderr, "  POST /reviewComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /addDepartment")
+	fmt.Fprintln(os.Stderr, "  POST /listDepartments")
+	fmt.Fprintln(os.Stderr, "  POST /updateDepartment")
+	fmt.Fprintln(os.Stderr, "  POST /deleteDepartment")
+	fmt.Fprintln(os.Stderr, "  POST /setComplaintDepartment")
+	fmt.Fprintln(os.Stderr, "  POST /linkComplaints")
+	fmt.Fprintln(os.Stderr, "  POST /unlinkComplaints")
+	fmt.Fprintln(os.Stderr, "  POST /saveFilter")
+	fmt.Fprintln(os.Stderr, "  POST /listFilters")
+	fmt.Fprintln(os.Stderr, "  POST /deleteFilter")
+	fmt.Fprintln(os.Stderr, "  POST /saveDraft")
+	fmt.Fprintln(os.Stderr, "  POST /getDraft")
+	fmt.Fprintln(os.Stderr, "  POST /createOrganization")
+	fmt.Fprintln(os.Stderr, "  POST /listOrganizations")
+	fmt.Fprintln(os.Stderr, "  POST /createInvite")
+	fmt.Fprintln(os.Stderr, "  POST /listInvites")
+	fmt.Fprintln(os.Stderr, "  POST /archiveComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /unarchiveComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /suggestSimilar")
+	fmt.Fprintln(os.Stderr, "  POST /getMyAssignedComplaints")
+	fmt.Fprintln(os.Stderr, "  POST /triageQueue")
+	fmt.Fprintln(os.Stderr, "  POST /mergeComplaints")
+	fmt.Fprintln(os.Stderr, "  POST /createShareLink")
+	fmt.Fprintln(os.Stderr, "  POST /revokeShareLink")
+	fmt.Fprintln(os.Stderr, "  GET  /shared/{token}")
+	fmt.Fprintln(os.Stderr, "  POST /createApiToken")
+	fmt.Fprintln(os.Stderr, "  POST /listApiTokens")
+	fmt.Fprintln(os.Stderr, "  POST /revokeApiToken")
+	fmt.Fprintln(os.Stderr, "  POST /watchComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /unwatchComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /listWatchers")
+	fmt.Fprintln(os.Stderr, "  POST /agingReport")
+	fmt.Fprintln(os.Stderr, "  POST /analytics/ratings")
+	fmt.Fprintln(os.Stderr, "  POST /adminWorkload")
+	fmt.Fprintln(os.Stderr, "  POST /ingestEmail")
+	fmt.Fprintln(os.Stderr, "  POST /pinComplaint")
+	fmt.Fprintln(os.Stderr, "  POST /unpinComplaint")
+	fmt.Fprintln(os.Stderr, "  GET  /admin/backup")
+	fmt.Fprintln(os.Stderr, "  POST /admin/restore")
+	fmt.Fprintln(os.Stderr, "  GET  /admin/deadLetters")
+	fmt.Fprintln(os.Stderr, "  POST /admin/retryDeadLetter")
+	fmt.Fprintln(os.Stderr, "  POST /setMaintenanceMode")
+	fmt.Fprintln(os.Stderr, "  GET  /admin/logLevel")
+	fmt.Fprintln(os.Stderr, "  POST /admin/logLevel")
+	fmt.Fprintln(os.Stderr, "  POST /v1/complaints")
+	fmt.Fprintln(os.Stderr, "  GET  /v1/complaints/{id}")
+	fmt.Fprintln(os.Stderr, "  PATCH /v1/complaints/{id}")
+	fmt.Fprintln(os.Stderr, "  POST /v1/complaints/{id}/resolve")
+	fmt.Fprintln(os.Stderr, "  GET  /v1/users/me")
+	fmt.Fprintln(os.Stderr, "  GET  /health/live")
+	fmt.Fprintln(os.Stderr, "  GET  /config")
+	fmt.Fprintln(os.Stderr, "  GET  /health/ready")
+	fmt.Fprintln(os.Stderr, "  GET  /metrics")
+	fmt.Fprintln(os.Stderr, "\nDefault Admin Secret Code: ADMIN_SECRET_123")
+
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			// The certificate comes from TLSConfig.GetCertificate
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("Server failed", logKeyError, err)
+		}
+	}()
+	if redirect != nil {
+		go func() {
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("Server failed", logKeyError, err)
+			}
+		}()
+	}
+
+	// Stop accepting requests and let background jobs finish on Ctrl+C or SIGTERM
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	<-shutdown
+
+	logger.Info("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("Shutdown failed", logKeyError, err)
+	}
+	if redirect != nil {
+		if err := redirect.Shutdown(ctx); err != nil {
+			logger.Error("Shutdown of HTTP redirect failed", logKeyError, err)
+		}
+	}
+	// Upgraded connections are not closed by server.Shutdown
+	wsHub.shutdown()
+	jobRunner.shutdown()
+	announcementFanOuts.Wait()
+}
@@ -1,238 +1,118 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
 	"testing"
-	"time"
 )
 
-const baseURL = "http://localhost:8080"
-
-// Test API client
-func makeRequest(method, endpoint string, payload interface{}) (*http.Response, error) {
-	var body io.Reader
-	if payload != nil {
-		jsonData, err := json.Marshal(payload)
-		if err != nil {
-			return nil, err
-		}
-		body = bytes.NewBuffer(jsonData)
-	}
-
-	req, err := http.NewRequest(method, baseURL+endpoint, body)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 10 * time.Second}
-	return client.Do(req)
-}
-
 func TestComplaintPortalAPI(t *testing.T) {
-	// Wait for server to start
-	time.Sleep(2 * time.Second)
+	srv := newTestServer(t)
 
-	// Test 1: Health check
 	t.Run("Health Check", func(t *testing.T) {
-		resp, err := makeRequest("GET", "/health", nil)
+		resp, err := http.Get(srv.URL + "/health")
 		if err != nil {
 			t.Fatalf("Health check failed: %v", err)
 		}
-		defer resp.Body.Close()
+		resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
 			t.Errorf("Expected status 200, got %d", resp.StatusCode)
 		}
 	})
 
-	var userSecretCode string
+	userID, userSecretCode := registerTestUser(t, srv, "Test User", "test@example.com")
 
-	// Test 2: Register user
-	t.Run("Register User", func(t *testing.T) {
-		payload := RegisterRequest{
-			Name:  "Test User",
-			Email: "test@example.com",
+	t.Run("Register Duplicate Email", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/register", RegisterRequest{Name: "Someone Else", Email: "test@example.com"})
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeEmailExists {
+			t.Errorf("Expected 409 EMAIL_EXISTS, got %d %s", status, resp.ErrorCode)
 		}
-
-		resp, err := makeRequest("POST", "/register", payload)
-		if err != nil {
-			t.Fatalf("Register failed: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusCreated {
-			t.Errorf("Expected status 201, got %d", resp.StatusCode)
-		}
-
-		var response APIResponse
-		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-			t.Fatalf("Failed to decode response: %v", err)
-		}
-
-		if !response.Success {
-			t.Errorf("Expected success true, got false")
-		}
-
-		// Extract user data
-		userData := response.Data.(map[string]interface{})
-		userSecretCode = userData["secret_code"].(string)
-		fmt.Printf("User registered with secret code: %s\n", userSecretCode)
 	})
 
-	// Test 3: Login
 	t.Run("Login", func(t *testing.T) {
-		payload := LoginRequest{
-			SecretCode: userSecretCode,
+		status, resp := postJSON(t, srv, "/login", LoginRequest{SecretCode: userSecretCode})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
 		}
-
-		resp, err := makeRequest("POST", "/login", payload)
-		if err != nil {
-			t.Fatalf("Login failed: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		var user User
+		resp.decode(t, &user)
+		if user.ID != userID {
+			t.Errorf("Expected user %d, got %d", userID, user.ID)
 		}
 	})
 
-	var complaintID float64
-
-	// Test 4: Submit complaint
-	t.Run("Submit Complaint", func(t *testing.T) {
-		payload := SubmitComplaintRequest{
-			SecretCode: userSecretCode,
-			Title:      "Test Complaint",
-			Summary:    "This is a test complaint for API testing",
-			Rating:     7,
-		}
-
-		resp, err := makeRequest("POST", "/submitComplaint", payload)
-		if err != nil {
-			t.Fatalf("Submit complaint failed: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusCreated {
-			t.Errorf("Expected status 201, got %d", resp.StatusCode)
-		}
+	complaint := submitTestComplaint(t, srv, userSecretCode, "Test Complaint", 7)
 
-		var response APIResponse
-		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-			t.Fatalf("Failed to decode response: %v", err)
+	t.Run("Submit With Rating Out Of Range", func(t *testing.T) {
+		for _, rating := range []int{0, 11} {
+			status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+				SecretCode: userSecretCode,
+				Title:      "Bad rating",
+				Summary:    "This complaint has an invalid rating",
+				Rating:     rating,
+			})
+			if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+				t.Errorf("Rating %d: expected 400 VALIDATION_FAILED, got %d %s", rating, status, resp.ErrorCode)
+			}
 		}
-
-		// Extract complaint ID
-		complaintData := response.Data.(map[string]interface{})
-		complaintID = complaintData["id"].(float64)
-		fmt.Printf("Complaint submitted with ID: %.0f\n", complaintID)
 	})
 
-	// Test 5: Get user complaints
 	t.Run("Get User Complaints", func(t *testing.T) {
-		payload := GetComplaintsRequest{
-			SecretCode: userSecretCode,
-		}
-
-		resp, err := makeRequest("POST", "/getAllComplaintsForUser", payload)
-		if err != nil {
-			t.Fatalf("Get user complaints failed: %v", err)
+		status, resp := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: userSecretCode})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		if len(complaints) != 1 || complaints[0].ID != complaint.ID {
+			t.Errorf("Expected only complaint %d, got %+v", complaint.ID, complaints)
 		}
 	})
 
-	// Test 6: View complaint
 	t.Run("View Complaint", func(t *testing.T) {
-		payload := ViewComplaintRequest{
-			SecretCode:  userSecretCode,
-			ComplaintID: int(complaintID),
+		status, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: userSecretCode, ComplaintID: complaint.ID})
+		if status != http.StatusOK {
+			t.Errorf("Expected status 200, got %d (%s)", status, resp.Error)
 		}
+	})
 
-		resp, err := makeRequest("POST", "/viewComplaint", payload)
-		if err != nil {
-			t.Fatalf("View complaint failed: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	t.Run("View Nonexistent Complaint", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: userSecretCode, ComplaintID: 9999})
+		if status != http.StatusNotFound || resp.ErrorCode != ErrCodeNotFound {
+			t.Errorf("Expected 404 NOT_FOUND, got %d %s", status, resp.ErrorCode)
 		}
 	})
 
-	// Test 7: Admin operations
 	t.Run("Admin Get All Complaints", func(t *testing.T) {
-		payload := GetComplaintsRequest{
-			SecretCode: "ADMIN_SECRET_123",
-		}
-
-		resp, err := makeRequest("POST", "/getAllComplaintsForAdmin", payload)
-		if err != nil {
-			t.Fatalf("Admin get complaints failed: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret})
+		if status != http.StatusOK {
+			t.Errorf("Expected status 200, got %d (%s)", status, resp.Error)
 		}
 	})
 
-	// Test 8: Resolve complaint (admin only)
 	t.Run("Resolve Complaint", func(t *testing.T) {
-		payload := ResolveComplaintRequest{
-			SecretCode:  "ADMIN_SECRET_123",
-			ComplaintID: int(complaintID),
+		status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
 		}
 
-		resp, err := makeRequest("POST", "/resolveComplaint", payload)
-		if err != nil {
-			t.Fatalf("Resolve complaint failed: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		status, resp = postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeAlreadyResolved {
+			t.Errorf("Expected 400 ALREADY_RESOLVED resolving twice, got %d %s", status, resp.ErrorCode)
 		}
 	})
 
-	// Test 9: Error cases
 	t.Run("Invalid Secret Code", func(t *testing.T) {
-		payload := LoginRequest{
-			SecretCode: "INVALID_SECRET",
-		}
-
-		resp, err := makeRequest("POST", "/login", payload)
-		if err != nil {
-			t.Fatalf("Request failed: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusUnauthorized {
-			t.Errorf("Expected status 401, got %d", resp.StatusCode)
+		status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: "INVALID_SECRET"})
+		if status != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", status)
 		}
 	})
 
 	t.Run("Unauthorized Access to Admin Endpoint", func(t *testing.T) {
-		payload := GetComplaintsRequest{
-			SecretCode: userSecretCode, // Regular user trying to access admin endpoint
-		}
-
-		resp, err := makeRequest("POST", "/getAllComplaintsForAdmin", payload)
-		if err != nil {
-			t.Fatalf("Request failed: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusForbidden {
-			t.Errorf("Expected status 403, got %d", resp.StatusCode)
+		status, _ := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: userSecretCode})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", status)
 		}
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaintenanceMessage is shown to rejected writes when no message is set
+const defaultMaintenanceMessage = "The portal is read-only for maintenance. Please try again later."
+
+// routeKind says how a route behaves in maintenance mode
+type routeKind int
+
+const (
+	// routeWrite changes data and is rejected during maintenance
+	routeWrite routeKind = iota
+	// routeRead only reads data and keeps working
+	routeRead
+	// routeControl keeps working so that maintenance can be turned off
+	routeControl
+)
+
+// MaintenanceStatus is the current maintenance mode
+type MaintenanceStatus struct {
+	Enabled           bool   `json:"enabled"`
+	Message           string `json:"message,omitempty"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+	Since             string `json:"since,omitempty"`
+}
+
+// MaintenanceMode is the read-only switch. While it is on, write routes
+// answer 503 and scheduled jobs are skipped.
+type MaintenanceMode struct {
+	status     MaintenanceStatus
+	retryAfter time.Duration
+	mutex      sync.RWMutex
+}
+
+var maintenance = &MaintenanceMode{}
+
+// set turns maintenance mode on or off. An empty message means the default.
+func (m *MaintenanceMode) set(enabled bool, message string, retryAfter time.Duration) MaintenanceStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !enabled {
+		m.status = MaintenanceStatus{}
+		m.retryAfter = 0
+		return m.status
+	}
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	since := m.status.Since
+	if !m.status.Enabled {
+		since = getCurrentTime()
+	}
+	m.status = MaintenanceStatus{
+		Enabled:           true,
+		Message:           message,
+		RetryAfterSeconds: int(retryAfter.Seconds()),
+		Since:             since,
+	}
+	m.retryAfter = retryAfter
+	return m.status
+}
+
+func (m *MaintenanceMode) current() MaintenanceStatus {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.status
+}
+
+// rejection is the error for a write made during maintenance, or nil when
+// maintenance mode is off
+func (m *MaintenanceMode) rejection() *APIError {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if !m.status.Enabled {
+		return nil
+	}
+	err := newAPIError(http.StatusServiceUnavailable, ErrCodeMaintenance, m.status.Message)
+	err.RetryAfter = m.retryAfter
+	return err
+}
+
+type routeKindKey struct{}
+
+// asWrite is for a read route whose request r changes data after all, such
+// as a claiming /triageQueue: it rejects r during maintenance and otherwise
+// returns it marked as a write, so read-only API tokens and replay
+// protection treat it as one. On failure it has already written the
+// response.
+func asWrite(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if err := maintenance.rejection(); err != nil {
+		respondWithAPIError(w, err)
+		return nil, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), routeKindKey{}, routeWrite)), true
+}
+
+// requestWrites reports whether r may change data: it was routed to a write
+// or control route and is not a GET or HEAD
+func requestWrites(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return false
+	}
+	kind, ok := r.Context().Value(routeKindKey{}).(routeKind)
+	return !ok || kind != routeRead
+}
+
+// routeTable registers routes on a ServeMux along with their kind
+type routeTable struct {
+	mux   *http.ServeMux
+	kinds map[string]routeKind
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{mux: http.NewServeMux(), kinds: map[string]routeKind{}}
+}
+
+func (t *routeTable) handle(pattern string, kind routeKind, handler http.Handler) {
+	t.kinds[pattern] = kind
+	t.mux.Handle(pattern, handler)
+}
+
+func (t *routeTable) read(pattern string, handler http.HandlerFunc) {
+	t.handle(pattern, routeRead, handler)
+}
+
+func (t *routeTable) write(pattern string, handler http.HandlerFunc) {
+	t.handle(pattern, routeWrite, handler)
+}
+
+// ServeHTTP labels the request with its route and kind, limits its body
+// and rejects writes during maintenance. GET and HEAD requests are always
+// reads, so a subtree such as /v1/ is registered as a write and its GET
+// routes still work.
+func (t *routeTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The mux's own 404 is plain text
+	_, pattern := t.mux.Handler(r)
+	if pattern == "" {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Endpoint not found")
+		return
+	}
+	setRouteLabel(r, pattern)
+	r = r.WithContext(context.WithValue(r.Context(), routeKindKey{}, t.kinds[pattern]))
+	if !limitBody(w, r, bodyLimit(pattern)) {
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		if pattern != "" && t.kinds[pattern] == routeWrite {
+			if err := maintenance.rejection(); err != nil {
+				respondWithAPIError(w, err)
+				return
+			}
+		}
+	}
+	t.mux.ServeHTTP(w, r)
+}
+
+type SetMaintenanceModeRequest struct {
+	SecretCode        string `json:"secret_code"`
+	Enabled           *bool  `json:"enabled"`
+	Message           string `json:"message,omitempty"`
+	RetryAfterSeconds *int   `json:"retry_after_seconds,omitempty"` // defaults to -maintenance-retry-after
+}
+
+// /setMaintenanceMode - Turn read-only maintenance mode on or off
+// (super-admin only)
+func setMaintenanceModeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req SetMaintenanceModeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.Enabled == nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Enabled is required")
+		return
+	}
+	message := sanitizeText(req.Message, false)
+	if err := checkLength("Message", message, maxTitleLength); err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	retryAfter := config.MaintenanceRetryAfter
+	if req.RetryAfterSeconds != nil {
+		if *req.RetryAfterSeconds < 0 {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Retry after must not be negative")
+			return
+		}
+		retryAfter = time.Duration(*req.RetryAfterSeconds) * time.Second
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	status := maintenance.set(*req.Enabled, message, retryAfter)
+
+	message = "Maintenance mode disabled"
+	if status.Enabled {
+		message = "Maintenance mode enabled"
+	}
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    status,
+	})
+}
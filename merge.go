@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxMergeDuplicates caps how many complaints one /mergeComplaints call folds
+// into its primary
+const maxMergeDuplicates = 50
+
+// isOpen reports whether complaint still needs work: it is neither resolved
+// nor merged into another complaint
+func isOpen(complaint *Complaint) bool {
+	return !complaint.IsResolved && complaint.MergedInto == 0
+}
+
+// complaintStatus is the state reported in Complaint.Status and matched by
+// the status listing filter
+func complaintStatus(complaint *Complaint) string {
+	switch {
+	case complaint.IsResolved && complaint.PendingConfirmation:
+		return statusPendingConfirmation
+	case complaint.IsResolved:
+		return statusResolved
+	case complaint.MergedInto != 0:
+		return statusMerged
+	}
+	return statusOpen
+}
+
+type MergeComplaintsRequest struct {
+	SecretCode   string `json:"secret_code"`
+	PrimaryID    int    `json:"primary_id"`
+	DuplicateIDs []int  `json:"duplicate_ids"`
+	Version      int    `json:"version,omitempty"` // opt-in concurrency check on the primary
+}
+
+// checkMergeable rejects a complaint that cannot take part in a merge
+func checkMergeable(complaint *Complaint, role string) *APIError {
+	if complaint.MergedInto != 0 {
+		return newAPIError(http.StatusConflict, ErrCodeAlreadyMerged, fmt.Sprintf("%s complaint %d is already merged into complaint %d", role, complaint.ID, complaint.MergedInto))
+	}
+	if complaint.IsResolved {
+		return newAPIError(http.StatusBadRequest, ErrCodeAlreadyResolved, fmt.Sprintf("%s complaint %d is already resolved", role, complaint.ID))
+	}
+	return nil
+}
+
+// mergeComplaints folds duplicateIDs into primaryID: each duplicate is marked
+// merged and its admin notes are copied to the primary, which resolves them
+// all when it is resolved. Nothing changes unless every complaint can be
+// merged. correlationID is that of the request.
+func mergeComplaints(user *User, req MergeComplaintsRequest, correlationID string) (Complaint, *APIError) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	primary := scopedComplaint(user, req.PrimaryID)
+	if primary == nil || primary.IsDeleted {
+		return Complaint{}, newAPIError(http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+	}
+	if req.Version != 0 {
+		if err := checkVersion(user, primary, req.Version); err != nil {
+			return Complaint{}, err
+		}
+	}
+	if err := checkMergeable(primary, "Primary"); err != nil {
+		return Complaint{}, err
+	}
+
+	var duplicates []*Complaint
+	seen := map[int]bool{}
+	for _, id := range req.DuplicateIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		duplicate := scopedComplaint(user, id)
+		if duplicate == nil || duplicate.IsDeleted {
+			return Complaint{}, newAPIError(http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("Complaint %d not found", id))
+		}
+		if duplicate.OrgID != primary.OrgID {
+			return Complaint{}, newAPIError(http.StatusBadRequest, ErrCodeValidationFailed, fmt.Sprintf("Complaint %d belongs to another organization", id))
+		}
+		if err := checkMergeable(duplicate, "Duplicate"); err != nil {
+			return Complaint{}, err
+		}
+		duplicates = append(duplicates, duplicate)
+	}
+
+	now := getCurrentTime()
+	ids := make([]string, len(duplicates))
+	for i, duplicate := range duplicates {
+		ids[i] = fmt.Sprintf("#%d", duplicate.ID)
+		for _, note := range duplicate.AdminNotes {
+			note.Note = fmt.Sprintf("(from #%d) %s", duplicate.ID, note.Note)
+			primary.AdminNotes = append(primary.AdminNotes, note)
+		}
+
+		duplicate.MergedInto = primary.ID
+		duplicate.MergedAt = now
+		unpinComplaint(duplicate, HistoryEntry{ActorID: user.ID, Detail: fmt.Sprintf("Merged into #%d", primary.ID), CorrelationID: correlationID})
+		addHistory(duplicate, HistoryEntry{Action: historyMerged, ActorID: user.ID, Detail: fmt.Sprintf("Merged into #%d", primary.ID), CorrelationID: correlationID})
+		touchComplaint(duplicate)
+		publishEvent(eventComplaintMerged, duplicate, correlationID)
+
+		primary.Duplicates = append(primary.Duplicates, duplicate.ID)
+	}
+	addHistory(primary, HistoryEntry{Action: historyMerged, ActorID: user.ID, Detail: "Merged " + strings.Join(ids, ", "), CorrelationID: correlationID})
+	touchComplaint(primary)
+
+	return complaintForViewer(user, *primary), nil
+}
+
+// resolveDuplicates resolves the open complaints merged into primary with
+// its resolution, notifying each owner and watcher. Callers must hold storage.mutex for
+// writing.
+func resolveDuplicates(primary *Complaint, correlationID string) {
+	for _, id := range primary.Duplicates {
+		duplicate, exists := storage.complaints[id]
+		if !exists || duplicate.IsDeleted || duplicate.IsResolved || duplicate.MergedInto != primary.ID {
+			continue
+		}
+		duplicate.IsResolved = true
+		duplicate.ResolvedAt = primary.ResolvedAt
+		duplicate.ResolutionNote = primary.ResolutionNote
+		duplicate.ResolvedBy = primary.ResolvedBy
+		duplicate.ResolvedByName = primary.ResolvedByName
+		touchComplaint(duplicate)
+		notifyOwner(duplicate, notificationResolved, fmt.Sprintf("Your complaint %q has been resolved", duplicate.Title))
+		notifyWatchers(duplicate, notificationResolved, fmt.Sprintf("A complaint you are watching, %q, has been resolved", duplicate.Title), false)
+		publishEvent(eventComplaintResolved, duplicate, correlationID)
+	}
+}
+
+// /mergeComplaints - Fold duplicate complaints into a primary one (admin only)
+func mergeComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req MergeComplaintsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.PrimaryID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid primary complaint ID is required")
+		return
+	}
+	if len(req.DuplicateIDs) == 0 || len(req.DuplicateIDs) > maxMergeDuplicates {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, fmt.Sprintf("Between 1 and %d duplicate IDs are required", maxMergeDuplicates))
+		return
+	}
+	for _, id := range req.DuplicateIDs {
+		if id <= 0 {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid duplicate IDs are required")
+			return
+		}
+		if id == req.PrimaryID {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "A complaint cannot be merged into itself")
+			return
+		}
+	}
+	if req.Version < 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Version must be positive")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	primary, apiErr := mergeComplaints(user, req, correlationID(r))
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaints merged successfully",
+		Data:    primary,
+	})
+}
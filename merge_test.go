@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMergeComplaints(t *testing.T) {
+	srv := newTestServer(t)
+	_, firstCode := registerTestUser(t, srv, "First Reporter", "first@example.com")
+	secondID, secondCode := registerTestUser(t, srv, "Second Reporter", "second@example.com")
+	thirdID, thirdCode := registerTestUser(t, srv, "Third Reporter", "third@example.com")
+
+	primary := submitTestComplaint(t, srv, firstCode, "Elevator broken", 5)
+	second := submitTestComplaint(t, srv, secondCode, "Lift stuck", 5)
+	third := submitTestComplaint(t, srv, thirdCode, "Elevator again", 5)
+	postJSON(t, srv, "/addAdminNote", AddAdminNoteRequest{SecretCode: adminSecret, ComplaintID: second.ID, Note: "Called the vendor"})
+
+	status, resp := postJSON(t, srv, "/mergeComplaints", MergeComplaintsRequest{SecretCode: secondCode, PrimaryID: primary.ID, DuplicateIDs: []int{second.ID}})
+	if status != http.StatusForbidden {
+		t.Fatalf("Expected 403 for a non-admin, got %d", status)
+	}
+
+	status, resp = postJSON(t, srv, "/mergeComplaints", MergeComplaintsRequest{
+		SecretCode: adminSecret, PrimaryID: primary.ID, DuplicateIDs: []int{second.ID, third.ID, second.ID},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+	}
+	var merged Complaint
+	resp.decode(t, &merged)
+	if len(merged.Duplicates) != 2 || merged.Duplicates[0] != second.ID || merged.Duplicates[1] != third.ID {
+		t.Errorf("Expected duplicates %d and %d, got %v", second.ID, third.ID, merged.Duplicates)
+	}
+	if len(merged.AdminNotes) != 1 || merged.AdminNotes[0].Note != "(from #2) Called the vendor" {
+		t.Errorf("Expected the duplicate's note on the primary, got %+v", merged.AdminNotes)
+	}
+
+	t.Run("Owners Still See Their Original", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: secondCode, ComplaintID: second.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var own Complaint
+		resp.decode(t, &own)
+		if own.Status != statusMerged || own.MergedInto != primary.ID || own.IsResolved {
+			t.Errorf("Expected a merged complaint pointing at %d, got %+v", primary.ID, own)
+		}
+
+		_, resp = postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: secondCode, Status: statusMerged})
+		var list []Complaint
+		resp.decode(t, &list)
+		if len(list) != 1 || list[0].ID != second.ID {
+			t.Errorf("Expected the merged complaint in the owner's listing, got %+v", list)
+		}
+		_, resp = postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret, Status: statusOpen})
+		resp.decode(t, &list)
+		if len(list) != 1 || list[0].ID != primary.ID {
+			t.Errorf("Expected only the primary to be open, got %+v", list)
+		}
+	})
+
+	t.Run("Rejections", func(t *testing.T) {
+		fourth := submitTestComplaint(t, srv, firstCode, "Elevator noise", 5)
+		resolved := submitTestComplaint(t, srv, firstCode, "Old elevator issue", 5)
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: resolved.ID})
+
+		for _, tc := range []struct {
+			name   string
+			req    MergeComplaintsRequest
+			status int
+			code   ErrorCode
+		}{
+			{"Already Merged Duplicate", MergeComplaintsRequest{PrimaryID: fourth.ID, DuplicateIDs: []int{second.ID}}, http.StatusConflict, ErrCodeAlreadyMerged},
+			{"Already Merged Primary", MergeComplaintsRequest{PrimaryID: third.ID, DuplicateIDs: []int{fourth.ID}}, http.StatusConflict, ErrCodeAlreadyMerged},
+			{"Resolved Duplicate", MergeComplaintsRequest{PrimaryID: fourth.ID, DuplicateIDs: []int{resolved.ID}}, http.StatusBadRequest, ErrCodeAlreadyResolved},
+			{"Resolved Primary", MergeComplaintsRequest{PrimaryID: resolved.ID, DuplicateIDs: []int{fourth.ID}}, http.StatusBadRequest, ErrCodeAlreadyResolved},
+			{"Into Itself", MergeComplaintsRequest{PrimaryID: fourth.ID, DuplicateIDs: []int{fourth.ID}}, http.StatusBadRequest, ErrCodeValidationFailed},
+			{"Unknown Duplicate", MergeComplaintsRequest{PrimaryID: fourth.ID, DuplicateIDs: []int{999}}, http.StatusNotFound, ErrCodeNotFound},
+		} {
+			tc.req.SecretCode = adminSecret
+			status, resp := postJSON(t, srv, "/mergeComplaints", tc.req)
+			if status != tc.status || resp.ErrorCode != tc.code {
+				t.Errorf("%s: expected %d %s, got %d %s (%s)", tc.name, tc.status, tc.code, status, resp.ErrorCode, resp.Error)
+			}
+		}
+
+		// A failed merge changes nothing
+		_, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: adminSecret, ComplaintID: fourth.ID})
+		var untouched Complaint
+		resp.decode(t, &untouched)
+		if untouched.MergedInto != 0 || untouched.Version != fourth.Version {
+			t.Errorf("Expected complaint %d to be untouched, got %+v", fourth.ID, untouched)
+		}
+
+		status, resp = postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: second.ID})
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeAlreadyMerged {
+			t.Errorf("Expected 409 ALREADY_MERGED resolving a duplicate directly, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Resolve Cascades", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: primary.ID, Note: "Motor replaced"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+
+		for _, tc := range []struct {
+			userID int
+			code   string
+			id     int
+		}{
+			{secondID, secondCode, second.ID},
+			{thirdID, thirdCode, third.ID},
+		} {
+			_, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: tc.code, ComplaintID: tc.id})
+			var duplicate Complaint
+			resp.decode(t, &duplicate)
+			if !duplicate.IsResolved || duplicate.Status != statusResolved || duplicate.ResolutionNote != "Motor replaced" {
+				t.Errorf("Complaint %d: expected it resolved with the primary's note, got %+v", tc.id, duplicate)
+			}
+
+			_, resp = postJSON(t, srv, "/getNotifications", GetNotificationsRequest{SecretCode: tc.code})
+			var inbox NotificationPage
+			resp.decode(t, &inbox)
+			if len(inbox.Notifications) != 1 || inbox.Notifications[0].ComplaintID != tc.id || inbox.Notifications[0].UserID != tc.userID {
+				t.Errorf("User %d: expected one notification about %d, got %+v", tc.userID, tc.id, inbox.Notifications)
+			}
+		}
+	})
+}
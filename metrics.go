@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request duration
+// histogram
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// unmatchedRoute labels requests that no route handled, so stray paths
+// cannot add label values
+const unmatchedRoute = "unmatched"
+
+// routeLabel carries the registered pattern of the route that handled a
+// request back out to the metrics middleware
+type routeLabel struct {
+	name  string
+	mutex sync.Mutex // a timed-out handler may still be running
+}
+
+type routeLabelKey struct{}
+
+// setRouteLabel records the pattern a request was routed by. Routers call it
+// with their registration pattern, never the raw path.
+func setRouteLabel(r *http.Request, pattern string) {
+	if label, ok := r.Context().Value(routeLabelKey{}).(*routeLabel); ok {
+		label.mutex.Lock()
+		label.name = pattern
+		label.mutex.Unlock()
+	}
+}
+
+type metricKey struct {
+	route  string
+	method string
+	status string
+}
+
+// histogram counts observations per bucket; counts are not cumulative
+type histogram struct {
+	buckets []uint64 // one per latencyBuckets entry
+	count   uint64
+	sum     float64
+}
+
+// Metrics holds the request instruments exposed on /metrics
+type Metrics struct {
+	durations map[metricKey]*histogram
+	inFlight  int64
+	mutex     sync.Mutex
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{durations: make(map[metricKey]*histogram)}
+}
+
+var metrics = newMetrics()
+
+func (m *Metrics) observe(key metricKey, seconds float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	h, exists := m.durations[key]
+	if !exists {
+		h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+		m.durations[key] = h
+	}
+	h.count++
+	h.sum += seconds
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+			break
+		}
+	}
+}
+
+// metricMethod keeps the method label to the methods the API uses
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// withMetrics times every request from the first byte of the handler to the
+// last byte of the response, and counts requests in flight
+func withMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&metrics.inFlight, 1)
+		defer atomic.AddInt64(&metrics.inFlight, -1)
+
+		label := &routeLabel{name: unmatchedRoute}
+		r = r.WithContext(context.WithValue(r.Context(), routeLabelKey{}, label))
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		elapsed := time.Since(start).Seconds()
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		label.mutex.Lock()
+		route := label.name
+		label.mutex.Unlock()
+		metrics.observe(metricKey{
+			route:  route,
+			method: metricMethod(r.Method),
+			status: strconv.Itoa(status/100) + "xx",
+		}, elapsed)
+	})
+}
+
+// formatBound writes a bucket bound the way Prometheus clients do
+func formatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
+
+// write renders the metrics in the Prometheus text exposition format
+func (m *Metrics) write(w io.Writer) {
+	m.mutex.Lock()
+	keys := make([]metricKey, 0, len(m.durations))
+	snapshot := make(map[metricKey]histogram, len(m.durations))
+	for key, h := range m.durations {
+		keys = append(keys, key)
+		snapshot[key] = histogram{buckets: append([]uint64(nil), h.buckets...), count: h.count, sum: h.sum}
+	}
+	m.mutex.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+
+	fmt.Fprintln(w, "# HELP complaint_portal_http_request_duration_seconds Time to handle a request, including writing the response.")
+	fmt.Fprintln(w, "# TYPE complaint_portal_http_request_duration_seconds histogram")
+	for _, key := range keys {
+		h := snapshot[key]
+		labels := fmt.Sprintf(`route=%q,method=%q,status=%q`, key.route, key.method, key.status)
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(w, "complaint_portal_http_request_duration_seconds_bucket{%s,le=%q} %d\n", labels, formatBound(bound), cumulative)
+		}
+		fmt.Fprintf(w, "complaint_portal_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "complaint_portal_http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "complaint_portal_http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP complaint_portal_failed_logins_unknown_secret_total Failed logins with a secret code that belongs to no user.")
+	fmt.Fprintln(w, "# TYPE complaint_portal_failed_logins_unknown_secret_total counter")
+	fmt.Fprintf(w, "complaint_portal_failed_logins_unknown_secret_total %d\n", atomic.LoadInt64(&unknownSecretFailures))
+
+	fmt.Fprintln(w, "# HELP complaint_portal_http_requests_in_flight Requests currently being handled.")
+	fmt.Fprintln(w, "# TYPE complaint_portal_http_requests_in_flight gauge")
+	fmt.Fprintf(w, "complaint_portal_http_requests_in_flight %d\n", atomic.LoadInt64(&m.inFlight))
+}
+
+// /metrics - Request metrics in the Prometheus text format
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var body strings.Builder
+	metrics.write(&body)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	io.WriteString(w, body.String())
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrapeMetrics fetches /metrics and returns each sample line's value keyed
+// by its name and labels
+func scrapeMetrics(t *testing.T, url string) map[string]float64 {
+	t.Helper()
+	resp, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected Content-Type %q", resp.Header.Get("Content-Type"))
+	}
+
+	samples := map[string]float64{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("Unparseable sample %q", line)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+func TestMetricsRouteLabels(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Measured User", "measured@example.com")
+	first := submitTestComplaint(t, srv, code, "First", 5)
+	second := submitTestComplaint(t, srv, code, "Second", 5)
+
+	postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret})
+	doV1(t, srv, http.MethodGet, "/v1/complaints/"+strconv.Itoa(first.ID), code, nil)
+	doV1(t, srv, http.MethodGet, "/v1/complaints/"+strconv.Itoa(second.ID), code, nil)
+	doV1(t, srv, http.MethodGet, "/v1/complaints/9999", code, nil)
+	http.Get(srv.URL + "/no/such/path")
+
+	samples := scrapeMetrics(t, srv.URL)
+	for series, want := range map[string]float64{
+		`complaint_portal_http_request_duration_seconds_count{route="/submitComplaint",method="POST",status="2xx"}`:          2,
+		`complaint_portal_http_request_duration_seconds_count{route="/getAllComplaintsForAdmin",method="POST",status="2xx"}`: 1,
+		`complaint_portal_http_request_duration_seconds_count{route="/v1/complaints/{id}",method="GET",status="2xx"}`:        2,
+		`complaint_portal_http_request_duration_seconds_count{route="/v1/complaints/{id}",method="GET",status="4xx"}`:        1,
+		`complaint_portal_http_request_duration_seconds_count{route="unmatched",method="GET",status="4xx"}`:                  1,
+	} {
+		if samples[series] != want {
+			t.Errorf("Expected %s = %v, got %v", series, want, samples[series])
+		}
+	}
+
+	for series := range samples {
+		if strings.Contains(series, `route="/v1/complaints/`+strconv.Itoa(first.ID)) || strings.Contains(series, "/no/such/path") {
+			t.Errorf("Raw path leaked into a label: %s", series)
+		}
+	}
+}
+
+func TestMetricsBucketsAndInFlight(t *testing.T) {
+	newTestServer(t)
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+
+	routes := newRouteTable()
+	routes.read("/slow", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		// Time spent after the header is still part of the request
+		time.Sleep(30 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	routes.read("/blocked", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	routes.read("/metrics", metricsHandler)
+	srv := httptest.NewServer(withMetrics(routes))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/slow")
+	if err != nil {
+		t.Fatalf("GET /slow failed: %v", err)
+	}
+	resp.Body.Close()
+
+	go http.Get(srv.URL + "/blocked")
+	<-started
+	samples := scrapeMetrics(t, srv.URL)
+	close(release)
+
+	labels := `route="/slow",method="GET",status="2xx"`
+	bucket := func(le string) float64 {
+		return samples[`complaint_portal_http_request_duration_seconds_bucket{`+labels+`,le="`+le+`"}`]
+	}
+	if bucket("0.025") != 0 || bucket("0.05") != 1 || bucket("+Inf") != 1 {
+		t.Errorf("Expected the 30ms request in the 0.05 bucket, got 0.025=%v 0.05=%v +Inf=%v", bucket("0.025"), bucket("0.05"), bucket("+Inf"))
+	}
+	if sum := samples[`complaint_portal_http_request_duration_seconds_sum{`+labels+`}`]; sum < 0.03 {
+		t.Errorf("Expected a sum of at least 0.03s, got %v", sum)
+	}
+	// The blocked request and the scrape itself
+	if inFlight := samples["complaint_portal_http_requests_in_flight"]; inFlight != 2 {
+		t.Errorf("Expected 2 requests in flight, got %v", inFlight)
+	}
+}
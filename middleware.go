@@ -0,0 +1,213 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gzipMinSize is the smallest response body worth compressing
+const gzipMinSize = 1024
+
+// statusRecorder captures the status code and body size written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// withLogging gives every request a logger tagged with its correlation ID
+// as request_id, for requestLogger to return, and logs the client, method,
+// path, status, size and duration of the request through it when it is done
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		ctx := withLogger(r.Context(), logger.With(logKeyRequestID, correlationID(r)))
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		loggerFrom(ctx).Info("Request",
+			"client_ip", clientIP(r),
+			"method", r.Method,
+			"path", loggedPath(r.URL.Path),
+			"status", rec.status,
+			"bytes", rec.bytes,
+			logKeyDurationMS, durationMS(time.Since(start)),
+		)
+	})
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether
+// the body is large enough, and of a suitable type, to be worth compressing
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	buf     []byte
+	status  int
+	decided bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.status == 0 {
+		g.status = code
+	}
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if g.decided {
+		if g.gz != nil {
+			return g.gz.Write(b)
+		}
+		return g.ResponseWriter.Write(b)
+	}
+
+	g.buf = append(g.buf, b...)
+	if len(g.buf) >= gzipMinSize {
+		if err := g.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush lets streaming handlers push data out immediately. A stream flushed
+// before reaching gzipMinSize is sent uncompressed.
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		g.decide()
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// decide picks compressed or plain output, writes the header and whatever
+// body has been buffered so far
+func (g *gzipResponseWriter) decide() error {
+	g.decided = true
+	header := g.ResponseWriter.Header()
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+
+	if len(g.buf) >= gzipMinSize && header.Get("Content-Encoding") == "" && isCompressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+
+	g.ResponseWriter.WriteHeader(g.status)
+	buffered := g.buf
+	g.buf = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	if g.gz != nil {
+		_, err := g.gz.Write(buffered)
+		return err
+	}
+	_, err := g.ResponseWriter.Write(buffered)
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+func (g *gzipResponseWriter) finish() {
+	if !g.decided {
+		g.decide()
+	}
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
+
+// compressedTypes are the prefixes of content types that are already
+// compressed, so gzip would only cost CPU. Office Open XML documents, such
+// as the /exportReport workbook, are zip archives.
+var compressedTypes = []string{
+	"image/", "video/", "audio/",
+	"application/zip", "application/gzip", "application/x-gzip", "application/octet-stream",
+	"application/vnd.openxmlformats-officedocument.",
+}
+
+// isCompressible rejects content types that are already compressed
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range compressedTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// withGzip compresses responses for clients that send Accept-Encoding: gzip
+func withGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		// An upgraded connection is no longer HTTP
+		if !acceptsGzip(r) || isWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.finish()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether gzip is listed in Accept-Encoding with a
+// non-zero quality
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		if strings.TrimSpace(fields[0]) != "gzip" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
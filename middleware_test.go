@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// rawPost sends a POST without letting the transport negotiate or undo
+// compression, so the test sees exactly what the server wrote
+func rawPost(t *testing.T, url, body, acceptEncoding string) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	return resp, data
+}
+
+func TestGzipCompression(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Verbose User", "verbose@example.com")
+	for i := 0; i < 30; i++ {
+		submitTestComplaint(t, srv, code, fmt.Sprintf("Complaint number %d", i), 5)
+	}
+	body := fmt.Sprintf(`{"secret_code": %q}`, adminSecret)
+
+	plainResp, plain := rawPost(t, srv.URL+"/getAllComplaintsForAdmin", body, "")
+	if plainResp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("Expected no Content-Encoding without Accept-Encoding, got %q", plainResp.Header.Get("Content-Encoding"))
+	}
+
+	gzResp, compressed := rawPost(t, srv.URL+"/getAllComplaintsForAdmin", body, "deflate, gzip;q=0.8")
+	if gzResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", gzResp.StatusCode)
+	}
+	if gzResp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip Content-Encoding, got %q", gzResp.Header.Get("Content-Encoding"))
+	}
+	if !strings.Contains(gzResp.Header.Get("Vary"), "Accept-Encoding") {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", gzResp.Header.Get("Vary"))
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Response is not valid gzip: %v", err)
+	}
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to decompress: %v", err)
+	}
+	if !bytes.Equal(decompressed, plain) {
+		t.Errorf("Decompressed body differs from the uncompressed response")
+	}
+
+	t.Run("Small Responses Stay Plain", func(t *testing.T) {
+		resp, _ := rawPost(t, srv.URL+"/login", body, "gzip")
+		if resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Expected small response to stay uncompressed, got %q", resp.Header.Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("Gzip Refused With q=0", func(t *testing.T) {
+		resp, _ := rawPost(t, srv.URL+"/getAllComplaintsForAdmin", body, "gzip;q=0")
+		if resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Expected no compression for q=0, got %q", resp.Header.Get("Content-Encoding"))
+		}
+	})
+}
+
+// TestGzipSkipsCompressedContent goes through every content type the
+// server sends, so a new download type that is compressed twice, or not at
+// all, shows up here
+func TestGzipSkipsCompressedContent(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x50, 0x4b}, gzipMinSize)
+	for _, tc := range []struct {
+		contentType string
+		compressed  bool // already, so it must pass through
+	}{
+		{xlsxContentType, true}, // /exportReport
+		{"application/zip", true},
+		{"application/gzip", true},
+		{"application/x-gzip", true},
+		{"application/octet-stream", true},
+		{"image/png", true},
+		{"application/json", false},                         // the API, /admin/backup and /exportMyData
+		{"application/xml", false},                          // Accept: application/xml
+		{"application/x-ndjson", false},                     // /exportComplaints
+		{"text/csv; charset=utf-8", false},                  // /report, /analytics/ratings and /adminWorkload
+		{"application/atom+xml; charset=utf-8", false},      // /feed.atom
+		{"text/html; charset=utf-8", false},                 // /board and share links
+		{"text/plain; version=0.0.4; charset=utf-8", false}, // /metrics
+	} {
+		handler := withGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			w.Write(payload)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/download", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		gzipped := rec.Header().Get("Content-Encoding") == "gzip"
+		if gzipped == tc.compressed {
+			t.Errorf("%s: expected gzip %v, got Content-Encoding %q", tc.contentType, !tc.compressed, rec.Header().Get("Content-Encoding"))
+		}
+		if tc.compressed && !bytes.Equal(rec.Body.Bytes(), payload) {
+			t.Errorf("%s: expected the body to be unchanged", tc.contentType)
+		}
+	}
+}
+
+func TestGzipWithStreamingAndLogging(t *testing.T) {
+	var recorded *statusRecorder
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "data: second\n\n")
+	})
+	capture := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorded = &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorded, r)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	capture(withGzip(stream)).ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Errorf("Expected Flush to reach the underlying writer")
+	}
+	if recorded.status != http.StatusAccepted {
+		t.Errorf("Expected logging middleware to see status 202, got %d", recorded.status)
+	}
+	if rec.Body.String() != "data: first\n\ndata: second\n\n" {
+		t.Errorf("Unexpected streamed body %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// backupMigration upgrades a decoded backup document by one schema version
+// in place
+type backupMigration func(doc map[string]interface{}) error
+
+// backupMigrations[i] upgrades schema version i+1 to i+2, so there is one
+// fewer than backupSchemaVersion. Old documents keep loading after the
+// stored format changes; add a migration whenever it does.
+var backupMigrations = []backupMigration{
+	migrateResolvedToStatus, // 1 -> 2
+	migrateToOrganizations,  // 2 -> 3
+	migrateToRatingScales,   // 3 -> 4
+	migrateToReferences,     // 4 -> 5
+	migrateToSources,        // 5 -> 6
+}
+
+var errNewerSchema = errors.New("backup schema is newer than this server supports")
+
+// documentVersion reads the schema version of doc. Version 1 documents
+// called it format_version.
+func documentVersion(doc map[string]interface{}) (int, error) {
+	value, exists := doc["schema_version"]
+	if !exists {
+		value, exists = doc["format_version"]
+	}
+	number, ok := value.(float64)
+	if !exists || !ok || number != float64(int(number)) || number < 1 {
+		return 0, errors.New("schema_version is missing or not a positive integer")
+	}
+	return int(number), nil
+}
+
+// decodeBackup decodes a backup document of any supported schema version,
+// migrating it to the current one. It also returns the version the
+// document was written in. Nothing outside the document is touched, so a
+// failed migration leaves the server as it was.
+func decodeBackup(data []byte) (Backup, int, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Backup{}, 0, err
+	}
+	version, err := documentVersion(doc)
+	if err != nil {
+		return Backup{}, 0, err
+	}
+	if version > backupSchemaVersion {
+		return Backup{}, version, fmt.Errorf("%w: version %d, this server reads versions 1 to %d", errNewerSchema, version, backupSchemaVersion)
+	}
+
+	for from := version; from < backupSchemaVersion; from++ {
+		if err := backupMigrations[from-1](doc); err != nil {
+			return Backup{}, version, fmt.Errorf("migrating schema version %d to %d: %v", from, from+1, err)
+		}
+		doc["schema_version"] = from + 1
+	}
+
+	if version != backupSchemaVersion {
+		if data, err = json.Marshal(doc); err != nil {
+			return Backup{}, version, err
+		}
+	}
+	var backup Backup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return Backup{}, version, err
+	}
+	return backup, version, nil
+}
+
+// migrateResolvedToStatus stores a complaint's resolution as status (open
+// or resolved) instead of the is_resolved flag, and renames format_version
+// to schema_version
+func migrateResolvedToStatus(doc map[string]interface{}) error {
+	delete(doc, "format_version")
+
+	complaints, _ := doc["complaints"].([]interface{})
+	for i, item := range complaints {
+		complaint, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("complaint %d is not an object", i)
+		}
+		resolved, _ := complaint["is_resolved"].(bool)
+		complaint["status"] = statusOpen
+		if resolved {
+			complaint["status"] = statusResolved
+		}
+		delete(complaint, "is_resolved")
+	}
+	return nil
+}
+
+// migrateToOrganizations puts every user and complaint in a new default
+// organization, makes the default admin a super-admin and moves the
+// round-robin state onto the organization
+func migrateToOrganizations(doc map[string]interface{}) error {
+	counters, ok := doc["counters"].(map[string]interface{})
+	if !ok {
+		return errors.New("counters are missing")
+	}
+
+	org := map[string]interface{}{"id": 1, "name": defaultOrganizationName, "created_at": ""}
+	if value, _ := doc["created_at"].(string); value != "" {
+		if created, err := time.Parse(time.RFC3339, value); err == nil {
+			org["created_at"] = created.Local().Format(timestampLayout)
+		}
+	}
+	if last, exists := counters["last_assignee_id"]; exists {
+		org["last_assignee_id"] = last
+		delete(counters, "last_assignee_id")
+	}
+	doc["organizations"] = []interface{}{org}
+	counters["organization_id"] = 1
+	counters["default_organization_id"] = 1
+
+	for _, key := range []string{"users", "complaints"} {
+		items, _ := doc[key].([]interface{})
+		for i, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s %d is not an object", key[:len(key)-1], i)
+			}
+			entry["org_id"] = 1
+			if key == "users" && entry["id"] == counters["default_admin_id"] {
+				entry["is_super_admin"] = true
+			}
+		}
+	}
+	return nil
+}
+
+// migrateToRatingScales records that every complaint was rated on the
+// 1-10 scale, the only one before the scale was configurable
+func migrateToRatingScales(doc map[string]interface{}) error {
+	complaints, _ := doc["complaints"].([]interface{})
+	for i, item := range complaints {
+		complaint, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("complaint %d is not an object", i)
+		}
+		complaint["rating_scale"] = map[string]interface{}{"min": legacyRatingScale.Min, "max": legacyRatingScale.Max}
+	}
+	return nil
+}
+
+// migrateToReferences gives every complaint a reference, unique within the
+// document
+func migrateToReferences(doc map[string]interface{}) error {
+	complaints, _ := doc["complaints"].([]interface{})
+	taken := map[string]int{}
+	for i, item := range complaints {
+		complaint, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("complaint %d is not an object", i)
+		}
+		reference, err := newReference(taken)
+		if err != nil {
+			return err
+		}
+		taken[reference] = i
+		complaint["reference"] = reference
+	}
+	return nil
+}
+
+// migrateToSources records that every complaint without a source came in
+// through the API; only emailed ones were marked before
+func migrateToSources(doc map[string]interface{}) error {
+	complaints, _ := doc["complaints"].([]interface{})
+	for i, item := range complaints {
+		complaint, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("complaint %d is not an object", i)
+		}
+		if source, _ := complaint["source"].(string); source == "" {
+			complaint["source"] = sourceAPI
+		}
+	}
+	return nil
+}
+
+// loadBackupFile replaces storage with the backup at path, as the
+// -restore-file flag does at startup
+func loadBackupFile(path string) (RestoreResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	backup, version, err := decodeBackup(data)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	restored, err := storageFromBackup(backup)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+
+	storage.mutex.Lock()
+	replaceStorage(restored)
+	storage.mutex.Unlock()
+	return restoreResult(backup, version), nil
+}
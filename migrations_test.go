@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupMigrations(t *testing.T) {
+	if len(backupMigrations) != backupSchemaVersion-1 {
+		t.Fatalf("Expected %d migrations for schema version %d, got %d", backupSchemaVersion-1, backupSchemaVersion, len(backupMigrations))
+	}
+
+	// Every historical version loads to the same state
+	for version, path := range map[int]string{1: "testdata/backup-v1.json", 2: "testdata/backup-v2.json", 3: "testdata/backup-v3.json", 4: "testdata/backup-v4.json", 5: "testdata/backup-v5.json", 6: "testdata/backup-v6.json"} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			newTestServer(t)
+			result, err := loadBackupFile(path)
+			if err != nil {
+				t.Fatalf("Failed to load %s: %v", path, err)
+			}
+			if result.SchemaVersion != version || result.Users != 2 || result.Complaints != 3 {
+				t.Errorf("Unexpected result %+v", result)
+			}
+
+			storage.mutex.RLock()
+			defer storage.mutex.RUnlock()
+			for id, resolved := range map[int]bool{1: true, 2: true, 3: false} {
+				if complaint := storage.complaints[id]; complaint == nil || complaint.IsResolved != resolved {
+					t.Errorf("Complaint %d: expected resolved %v, got %+v", id, resolved, complaint)
+				}
+			}
+			if complaint := storage.complaints[2]; complaint.MergedInto != 1 {
+				t.Errorf("Expected complaint 2 to stay merged into 1, got %+v", complaint)
+			}
+			if owner := storage.users[2]; len(owner.Complaints) != 3 {
+				t.Errorf("Expected the owner's complaint list rebuilt, got %d", len(owner.Complaints))
+			}
+			// Everything lands in one default organization run by the default admin
+			if org := storage.organizations[storage.defaultOrgID]; org == nil || org.Name != defaultOrganizationName || len(storage.organizations) != 1 {
+				t.Errorf("Expected a single default organization, got %+v", storage.organizations)
+			}
+			if admin := storage.users[storage.defaultAdminID]; !admin.IsSuperAdmin || storage.users[2].IsSuperAdmin {
+				t.Error("Expected the default admin, and only them, to be a super-admin")
+			}
+			for _, complaint := range storage.complaints {
+				if complaint.OrgID != storage.defaultOrgID {
+					t.Errorf("Complaint %d: expected the default organization, got %d", complaint.ID, complaint.OrgID)
+				}
+				if storage.references[complaint.Reference] != complaint.ID {
+					t.Errorf("Complaint %d: expected a unique reference, got %q", complaint.ID, complaint.Reference)
+				}
+				if complaint.RatingScale != legacyRatingScale {
+					t.Errorf("Complaint %d: expected the 1-10 rating scale, got %+v", complaint.ID, complaint.RatingScale)
+				}
+				if complaint.Source != sourceAPI {
+					t.Errorf("Complaint %d: expected source %s, got %q", complaint.ID, sourceAPI, complaint.Source)
+				}
+			}
+
+			backup := snapshotStorage()
+			if backup.SchemaVersion != backupSchemaVersion || backup.Complaints[0].Status != statusResolved || backup.Complaints[2].Status != statusOpen {
+				t.Errorf("Expected a current-version backup with statuses, got version %d", backup.SchemaVersion)
+			}
+		})
+	}
+}
+
+func TestNewerBackupSchemaRefused(t *testing.T) {
+	srv := newTestServer(t)
+	registerTestUser(t, srv, "Existing User", "existing@example.com")
+
+	path := filepath.Join(t.TempDir(), "future.json")
+	os.WriteFile(path, []byte(`{"schema_version": 99, "users": "in a shape this server has never seen"}`), 0600)
+
+	if _, err := loadBackupFile(path); !errors.Is(err, errNewerSchema) {
+		t.Fatalf("Expected errNewerSchema, got %v", err)
+	}
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if len(storage.users) != 2 {
+		t.Errorf("Expected storage untouched, got %d users", len(storage.users))
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Review actions of /reviewComplaint
+const (
+	reviewApprove = "approve"
+	reviewRedact  = "redact"
+)
+
+// redactedText replaces the title and summary of a redacted complaint
+const redactedText = "[Removed by a moderator]"
+
+// ModerationResult is a ModerationChecker's verdict on some text
+type ModerationResult struct {
+	Flagged bool
+	Reason  string // why the text was flagged, shown to admins
+}
+
+// ModerationChecker decides whether submitted text needs an admin's review
+// before it is shown on the board. Check may call an external service; it
+// runs without storage.mutex held, and on error the text is held for review.
+type ModerationChecker interface {
+	Check(text string) (ModerationResult, error)
+}
+
+// wordlistChecker flags text containing any of a list of words, compared
+// whole and without case
+type wordlistChecker struct {
+	words map[string]bool
+}
+
+// moderator checks every new complaint
+var moderator ModerationChecker = newWordlistChecker(nil)
+
+func newWordlistChecker(words []string) *wordlistChecker {
+	checker := &wordlistChecker{words: make(map[string]bool, len(words))}
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			checker.words[word] = true
+		}
+	}
+	return checker
+}
+
+func (c *wordlistChecker) Check(text string) (ModerationResult, error) {
+	var matched []string
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if c.words[word] && !seen[word] {
+			seen[word] = true
+			matched = append(matched, word)
+		}
+	}
+	if len(matched) == 0 {
+		return ModerationResult{}, nil
+	}
+	return ModerationResult{Flagged: true, Reason: "Contains " + strings.Join(matched, ", ")}, nil
+}
+
+// loadModerationWords reads -moderation-words-file, one word per line with #
+// comments. No file means no words, so nothing is flagged.
+func loadModerationWords(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words, scanner.Err()
+}
+
+// moderate runs the moderator over a complaint's title and summary
+func moderate(title, summary string) ModerationResult {
+	result, err := moderator.Check(title + "\n" + summary)
+	if err != nil {
+		logger.Error("Moderation check failed, holding for review", logKeyError, err)
+		return ModerationResult{Flagged: true, Reason: "Moderation check failed"}
+	}
+	return result
+}
+
+type ReviewQueueRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+type ReviewComplaintRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+	Action      string `json:"action"` // approve or redact
+}
+
+// /reviewQueue - Complaints flagged by moderation, oldest first (admin only)
+func reviewQueueHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ReviewQueueRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	queue := []Complaint{}
+	for _, complaint := range storage.complaints {
+		if complaint.NeedsReview && !complaint.IsDeleted && canSeeOrg(user, complaint.OrgID) {
+			queue = append(queue, complaintForViewer(user, *complaint))
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool { return queue[i].ID < queue[j].ID })
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d complaints awaiting review", len(queue)),
+		Data:    queue,
+	})
+}
+
+// /reviewComplaint - Approve a flagged complaint, or redact its text (admin
+// only)
+func reviewComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ReviewComplaintRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("complaint_id", req.ComplaintID)
+	v.oneOf("action", req.Action, []string{reviewApprove, reviewRedact})
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+	if !complaint.NeedsReview {
+		respondWithError(w, http.StatusConflict, ErrCodeNotInReview, "Complaint is not awaiting review")
+		return
+	}
+
+	entry := HistoryEntry{ActorID: user.ID, Detail: complaint.ModerationReason, CorrelationID: correlationID(r)}
+	message := "Complaint approved"
+	if req.Action == reviewRedact {
+		complaint.Title = redactedText
+		complaint.Summary = redactedText
+		complaint.words = similarityWords(complaint.Title, complaint.Summary)
+		entry.Action = historyRedacted
+		message = "Complaint redacted"
+	} else {
+		entry.Action = historyApproved
+	}
+	complaint.NeedsReview = false
+	complaint.ModerationReason = ""
+	addHistory(complaint, entry)
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
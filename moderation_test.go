@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// failingChecker stands in for an external moderation service that is down
+type failingChecker struct{}
+
+func (failingChecker) Check(text string) (ModerationResult, error) {
+	return ModerationResult{}, errors.New("moderation service unavailable")
+}
+
+func TestModeration(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	moderator = newWordlistChecker([]string{"idiots", "Scum"})
+	_, code := registerTestUser(t, srv, "Angry User", "angry@example.com")
+
+	clean := submitTestComplaint(t, srv, code, "Broken heater", 5)
+	status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+		SecretCode: code, Title: "Facilities are IDIOTS", Summary: "Absolute scum, the lot of them.", Rating: 1,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected flagged text to be accepted, got %d (%s)", status, resp.Error)
+	}
+	var flagged Complaint
+	resp.decode(t, &flagged)
+	if !flagged.NeedsReview || clean.NeedsReview {
+		t.Fatalf("Expected only the abusive complaint to need review, got %v and %v", flagged.NeedsReview, clean.NeedsReview)
+	}
+	if flagged.ModerationReason != "" {
+		t.Errorf("Expected the reason to be hidden from the submitter, got %q", flagged.ModerationReason)
+	}
+	redacted := submitTestComplaint(t, srv, code, "Scum everywhere", 1)
+
+	t.Run("Hidden From Board", func(t *testing.T) {
+		_, body := getBoard(t, srv.URL+"/board")
+		if !strings.Contains(body, "Broken heater") || strings.Contains(body, "IDIOTS") || strings.Contains(body, "Scum everywhere") {
+			t.Errorf("Expected only the clean complaint on the board:\n%s", body)
+		}
+		_, body = getBoard(t, srv.URL+"/board?token="+adminSecret)
+		if strings.Contains(body, "IDIOTS") {
+			t.Errorf("Expected flagged complaints off the board for admins too:\n%s", body)
+		}
+	})
+
+	t.Run("Queue", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/reviewQueue", ReviewQueueRequest{SecretCode: code}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a regular user, got %d", status)
+		}
+		status, resp := postJSON(t, srv, "/reviewQueue", ReviewQueueRequest{SecretCode: adminSecret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var queue []Complaint
+		resp.decode(t, &queue)
+		if len(queue) != 2 || queue[0].ID != flagged.ID || queue[1].ID != redacted.ID {
+			t.Fatalf("Expected both flagged complaints, oldest first, got %+v", queue)
+		}
+		if queue[0].ModerationReason != "Contains idiots, scum" {
+			t.Errorf("Expected the matched words as the reason, got %q", queue[0].ModerationReason)
+		}
+	})
+
+	t.Run("Approve", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/reviewComplaint", ReviewComplaintRequest{SecretCode: adminSecret, ComplaintID: flagged.ID, Action: reviewApprove})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var approved Complaint
+		resp.decode(t, &approved)
+		if approved.NeedsReview || approved.Title != "Facilities are IDIOTS" {
+			t.Errorf("Expected the flag cleared and the text kept, got %+v", approved)
+		}
+		if last := approved.History[len(approved.History)-1]; last.Action != historyApproved {
+			t.Errorf("Expected an approval in the history, got %+v", last)
+		}
+		if _, body := getBoard(t, srv.URL+"/board"); !strings.Contains(body, "IDIOTS") {
+			t.Errorf("Expected the approved complaint on the board:\n%s", body)
+		}
+
+		status, resp = postJSON(t, srv, "/reviewComplaint", ReviewComplaintRequest{SecretCode: adminSecret, ComplaintID: flagged.ID, Action: reviewApprove})
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeNotInReview {
+			t.Errorf("Expected 409 NOT_IN_REVIEW the second time, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Redact", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/reviewComplaint", ReviewComplaintRequest{SecretCode: adminSecret, ComplaintID: redacted.ID, Action: "delete"}); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an unknown action, got %d", status)
+		}
+		status, resp := postJSON(t, srv, "/reviewComplaint", ReviewComplaintRequest{SecretCode: adminSecret, ComplaintID: redacted.ID, Action: reviewRedact})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		if complaint.NeedsReview || complaint.Title != redactedText || complaint.Summary != redactedText {
+			t.Errorf("Expected the text replaced, got %+v", complaint)
+		}
+		if len(complaint.History) == 0 {
+			t.Fatal("Expected a history entry")
+		}
+		if last := complaint.History[len(complaint.History)-1]; last.Action != historyRedacted || last.Detail != "Contains scum" {
+			t.Errorf("Expected a redaction with the reason in the history, got %+v", last)
+		}
+		if _, body := getBoard(t, srv.URL+"/board"); strings.Contains(body, "Scum everywhere") || !strings.Contains(body, redactedText) {
+			t.Errorf("Expected only the placeholder on the board:\n%s", body)
+		}
+
+		_, resp = postJSON(t, srv, "/reviewQueue", ReviewQueueRequest{SecretCode: adminSecret})
+		var queue []Complaint
+		resp.decode(t, &queue)
+		if len(queue) != 0 {
+			t.Errorf("Expected an empty queue, got %+v", queue)
+		}
+	})
+
+	t.Run("Checker Failure Holds For Review", func(t *testing.T) {
+		moderator = failingChecker{}
+		if complaint := submitTestComplaint(t, srv, code, "Lights out", 4); !complaint.NeedsReview {
+			t.Error("Expected a complaint to be held when the checker fails")
+		}
+	})
+}
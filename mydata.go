@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ExportMyDataRequest struct {
+	SecretCode string `json:"secret_code"`
+	UserID     int    `json:"user_id,omitempty"` // admins only; defaults to the caller
+}
+
+// PersonalProfile is the account data held about a user. The secret code is
+// a credential, not personal data, and is left out.
+type PersonalProfile struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	IsAdmin     bool   `json:"is_admin"`
+	LastLoginAt string `json:"last_login_at,omitempty"`
+}
+
+// PersonalFeedback is one piece of feedback with the complaint it is about
+type PersonalFeedback struct {
+	ComplaintID int `json:"complaint_id"`
+	Feedback
+}
+
+// PersonalDataExport is everything stored about one user
+type PersonalDataExport struct {
+	ExportedAt        string             `json:"exported_at"`
+	Profile           PersonalProfile    `json:"profile"`
+	Complaints        []Complaint        `json:"complaints"` // including resolved and deleted ones
+	Notifications     []Notification     `json:"notifications"`
+	Feedback          []PersonalFeedback `json:"feedback"`
+	LoginHistory      []LoginEntry       `json:"login_history"`      // most recent first
+	RecentSubmissions []string           `json:"recent_submissions"` // kept for submission rate limiting
+}
+
+// personalDataFor collects the data stored about user, shaped as the user
+// would see it so that nothing about other people, such as admin notes, is
+// included. Any new per-user data must be added here. Callers must hold
+// storage.mutex for reading.
+func personalDataFor(user *User) PersonalDataExport {
+	// Shape complaints for the subject as a regular user, even if they are an
+	// admin, so internal notes stay out
+	subject := snapshotUser(user)
+	subject.IsAdmin = false
+
+	export := PersonalDataExport{
+		ExportedAt:        clock.Now().Format(time.RFC3339),
+		Profile:           PersonalProfile{ID: user.ID, Name: user.Name, Email: user.Email, IsAdmin: user.IsAdmin, LastLoginAt: subject.LastLoginAt},
+		Complaints:        []Complaint{},
+		Notifications:     []Notification{},
+		Feedback:          []PersonalFeedback{},
+		LoginHistory:      []LoginEntry{},
+		RecentSubmissions: []string{},
+	}
+
+	for _, complaint := range storage.complaints {
+		if complaint.UserID != user.ID {
+			continue
+		}
+		export.Complaints = append(export.Complaints, complaintForViewer(&subject, *complaint))
+		if complaint.Feedback != nil {
+			export.Feedback = append(export.Feedback, PersonalFeedback{ComplaintID: complaint.ID, Feedback: *complaint.Feedback})
+		}
+	}
+	sort.Slice(export.Complaints, func(i, j int) bool { return export.Complaints[i].ID < export.Complaints[j].ID })
+	sort.Slice(export.Feedback, func(i, j int) bool { return export.Feedback[i].ComplaintID < export.Feedback[j].ComplaintID })
+
+	for _, notification := range storage.notifications[user.ID] {
+		export.Notifications = append(export.Notifications, *notification)
+	}
+	storage.loginMutex.Lock()
+	if ring, exists := storage.loginHistory[user.ID]; exists {
+		export.LoginHistory = ring.newestFirst(0)
+	}
+	storage.loginMutex.Unlock()
+	for _, submitted := range storage.submissions[user.ID] {
+		export.RecentSubmissions = append(export.RecentSubmissions, submitted.Format(time.RFC3339))
+	}
+	return export
+}
+
+// /exportMyData - Download everything stored about the caller, or as an
+// admin, about any user
+func exportMyDataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ExportMyDataRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.UserID < 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid user ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, canActOnUser(actorOf(user), req.UserID)) {
+		return
+	}
+
+	storage.mutex.RLock()
+	subject := user
+	if req.UserID != 0 {
+		subject = scopedUser(user, req.UserID)
+	}
+	if subject == nil {
+		storage.mutex.RUnlock()
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	export := personalDataFor(subject)
+	storage.mutex.RUnlock()
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to build export")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-data.json"`, subject.ID))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
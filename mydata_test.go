@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// exportMyData requests a personal data export and returns the status, the
+// raw body and the Content-Disposition header
+func exportMyData(t *testing.T, srv *httptest.Server, req ExportMyDataRequest) (int, []byte, string) {
+	t.Helper()
+	jsonData, _ := json.Marshal(req)
+	resp, err := http.Post(srv.URL+"/exportMyData", "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		t.Fatalf("POST /exportMyData failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	return resp.StatusCode, body, resp.Header.Get("Content-Disposition")
+}
+
+func TestExportMyData(t *testing.T) {
+	srv := newTestServer(t)
+	userID, code := registerTestUser(t, srv, "Data Subject", "subject@example.com")
+	otherID, otherCode := registerTestUser(t, srv, "Bystander", "bystander@example.com")
+
+	// Data in every subsystem: a resolved complaint with a notification and
+	// feedback, an admin note, a deleted complaint, and someone else's complaint
+	resolved := submitTestComplaint(t, srv, code, "Broken window", 6)
+	deleted := submitTestComplaint(t, srv, code, "Filed by mistake", 2)
+	submitTestComplaint(t, srv, otherCode, "Bystander problem", 4)
+
+	postJSON(t, srv, "/addAdminNote", AddAdminNoteRequest{SecretCode: adminSecret, ComplaintID: resolved.ID, Note: "Internal: tenant is difficult"})
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: resolved.ID, Note: "Glazier came"})
+	postJSON(t, srv, "/submitFeedback", SubmitFeedbackRequest{SecretCode: code, ComplaintID: resolved.ID, Score: 5, Comment: "Quick"})
+	postJSON(t, srv, "/deleteComplaint", DeleteComplaintRequest{SecretCode: code, ComplaintID: deleted.ID})
+
+	status, body, disposition := exportMyData(t, srv, ExportMyDataRequest{SecretCode: code})
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", status, body)
+	}
+	if !strings.HasPrefix(disposition, "attachment;") || !strings.Contains(disposition, "user-"+strconv.Itoa(userID)+"-data.json") {
+		t.Errorf("Expected a JSON attachment, got %q", disposition)
+	}
+
+	var export PersonalDataExport
+	if err := json.Unmarshal(body, &export); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+
+	t.Run("Completeness", func(t *testing.T) {
+		if export.Profile.ID != userID || export.Profile.Email != "subject@example.com" {
+			t.Errorf("Unexpected profile %+v", export.Profile)
+		}
+		if len(export.Complaints) != 2 || export.Complaints[0].ID != resolved.ID || export.Complaints[1].ID != deleted.ID {
+			t.Fatalf("Expected both of the user's complaints, got %+v", export.Complaints)
+		}
+		if c := export.Complaints[0]; !c.IsResolved || c.ResolutionNote != "Glazier came" || c.Feedback == nil {
+			t.Errorf("Expected the resolved complaint with its note and feedback, got %+v", c)
+		}
+		if !export.Complaints[1].IsDeleted {
+			t.Error("Expected the deleted complaint to be marked deleted")
+		}
+		if len(export.Notifications) != 1 || export.Notifications[0].ComplaintID != resolved.ID {
+			t.Errorf("Expected the resolution notification, got %+v", export.Notifications)
+		}
+		if len(export.Feedback) != 1 || export.Feedback[0].Score != 5 || export.Feedback[0].ComplaintID != resolved.ID {
+			t.Errorf("Expected the feedback, got %+v", export.Feedback)
+		}
+		if len(export.RecentSubmissions) != 2 {
+			t.Errorf("Expected 2 recent submissions, got %v", export.RecentSubmissions)
+		}
+	})
+
+	t.Run("Exclusions", func(t *testing.T) {
+		for _, leaked := range []string{"Internal: tenant is difficult", "admin_notes", "Bystander", "bystander@example.com", code, "secret_code"} {
+			if bytes.Contains(body, []byte(leaked)) {
+				t.Errorf("Export contains %q", leaked)
+			}
+		}
+	})
+
+	t.Run("Admin Variant", func(t *testing.T) {
+		status, adminBody, _ := exportMyData(t, srv, ExportMyDataRequest{SecretCode: adminSecret, UserID: userID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", status)
+		}
+		var adminExport PersonalDataExport
+		json.Unmarshal(adminBody, &adminExport)
+		if adminExport.Profile.ID != userID || len(adminExport.Complaints) != 2 {
+			t.Errorf("Expected the subject's data, got %+v", adminExport.Profile)
+		}
+		if bytes.Contains(adminBody, []byte("Internal: tenant is difficult")) {
+			t.Error("Expected admin notes to stay out of an admin-requested export")
+		}
+
+		status, _, _ = exportMyData(t, srv, ExportMyDataRequest{SecretCode: code, UserID: otherID})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 exporting another user's data, got %d", status)
+		}
+		status, _, _ = exportMyData(t, srv, ExportMyDataRequest{SecretCode: adminSecret, UserID: 9999})
+		if status != http.StatusNotFound {
+			t.Errorf("Expected 404 for a missing user, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// xmlResponseWriter marks a response the client asked to receive as XML,
+// for respondWithJSON to find
+type xmlResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (x *xmlResponseWriter) Unwrap() http.ResponseWriter {
+	return x.ResponseWriter
+}
+
+func (x *xmlResponseWriter) Flush() {
+	if flusher, ok := x.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// prefersXML reports whether an Accept header ranks XML above JSON. JSON
+// is the default, so it wins ties, wildcards count for it, and headers
+// naming neither type get JSON rather than a 406.
+func prefersXML(accept string) bool {
+	var xmlQuality, jsonQuality float64
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, exists := params["q"]; exists {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/xml", "text/xml":
+			xmlQuality = max(xmlQuality, quality)
+		case "application/json", "application/*", "*/*":
+			jsonQuality = max(jsonQuality, quality)
+		}
+	}
+	return xmlQuality > 0 && xmlQuality > jsonQuality
+}
+
+// negotiated returns w marked for XML responses when r asks for them
+func negotiated(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if prefersXML(r.Header.Get("Accept")) {
+		return &xmlResponseWriter{ResponseWriter: w}
+	}
+	return w
+}
+
+// withContentNegotiation lets clients that send Accept: application/xml
+// receive the usual response envelope as XML, errors included. Responses
+// that are not envelopes, such as CSV exports and the board, are left as
+// they are.
+func withContentNegotiation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		next.ServeHTTP(negotiated(w, r), r)
+	})
+}
+
+// wantsXML reports whether w, or a writer it wraps, was marked by
+// negotiated
+func wantsXML(w http.ResponseWriter) bool {
+	for {
+		if _, ok := w.(*xmlResponseWriter); ok {
+			return true
+		}
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = wrapper.Unwrap()
+	}
+}
+
+// xmlList wraps list data so each entry becomes an <item> inside <data>
+type xmlList struct {
+	Items interface{} `xml:"item"`
+}
+
+// encodeXML renders response as an XML document with a <response> root
+func encodeXML(response APIResponse) ([]byte, error) {
+	if value := reflect.ValueOf(response.Data); value.Kind() == reflect.Slice {
+		response.Data = xmlList{Items: response.Data}
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).EncodeElement(response, xml.StartElement{Name: xml.Name{Local: "response"}}); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// postAccepting posts payload as JSON with the given Accept header and
+// returns the response and its body
+func postAccepting(t *testing.T, srv *httptest.Server, path, accept string, payload interface{}) (*http.Response, []byte) {
+	t.Helper()
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Failed to encode payload: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, bytes.NewReader(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, body
+}
+
+func TestPrefersXML(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/xml", true},
+		{"text/xml; charset=utf-8", true},
+		{"application/json", false},
+		{"*/*", false},
+		{"text/html", false},
+		{"application/xml, application/json", false}, // JSON wins ties
+		{"application/json;q=0.5, application/xml", true},
+		{"application/xml;q=0.5, */*", false},
+		{"application/xml;q=0", false},
+		{"application/xml;q=abc", false},
+	}
+	for _, tc := range tests {
+		if got := prefersXML(tc.accept); got != tc.want {
+			t.Errorf("prefersXML(%q) = %v, want %v", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestXMLResponses(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Legacy User", "legacy@example.com")
+	postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: "Boiler <noisy> & hot", Summary: "Bangs at night", Rating: 3, Tags: []string{"heating", "noise"}})
+	other := submitTestComplaint(t, srv, code, "Radiator cold", 5)
+	postJSON(t, srv, "/linkComplaints", LinkComplaintsRequest{SecretCode: adminSecret, ComplaintID: 1, RelatedID: other.ID})
+	postJSON(t, srv, "/addAdminNote", AddAdminNoteRequest{SecretCode: adminSecret, ComplaintID: 1, Note: "Engineer booked"})
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: 1, Note: "Bled the pipes"})
+	postJSON(t, srv, "/submitFeedback", SubmitFeedbackRequest{SecretCode: code, ComplaintID: 1, Score: 4, Comment: "Quiet now"})
+
+	t.Run("Same Complaint In Both Formats", func(t *testing.T) {
+		view := ViewComplaintRequest{SecretCode: adminSecret, ComplaintID: 1}
+		status, resp := postJSON(t, srv, "/viewComplaint", view)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var fromJSON Complaint
+		resp.decode(t, &fromJSON)
+
+		httpResp, body := postAccepting(t, srv, "/viewComplaint", "application/xml", view)
+		if httpResp.StatusCode != http.StatusOK || httpResp.Header.Get("Content-Type") != "application/xml; charset=utf-8" {
+			t.Fatalf("Expected 200 application/xml, got %d %q", httpResp.StatusCode, httpResp.Header.Get("Content-Type"))
+		}
+		if !strings.Contains(httpResp.Header.Get("Vary"), "Accept") {
+			t.Errorf("Expected Vary: Accept, got %q", httpResp.Header.Values("Vary"))
+		}
+		var envelope struct {
+			XMLName xml.Name  `xml:"response"`
+			Success bool      `xml:"success"`
+			Message string    `xml:"message"`
+			Data    Complaint `xml:"data"`
+		}
+		if err := xml.Unmarshal(body, &envelope); err != nil {
+			t.Fatalf("Failed to decode XML: %v\n%s", err, body)
+		}
+		if !envelope.Success || envelope.Message != resp.Message {
+			t.Errorf("Expected the same envelope, got %+v", envelope)
+		}
+		if len(fromJSON.Tags) != 2 || len(fromJSON.AdminNotes) != 1 || fromJSON.Feedback == nil || len(fromJSON.RelatedComplaints) != 1 || fromJSON.User == nil {
+			t.Fatalf("Expected a complaint with every kind of field set, got %+v", fromJSON)
+		}
+		if !reflect.DeepEqual(envelope.Data, fromJSON) {
+			t.Errorf("Expected the XML complaint to match the JSON one\nJSON: %+v\nXML:  %+v", fromJSON, envelope.Data)
+		}
+	})
+
+	t.Run("Lists", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: code})
+		var fromJSON []Complaint
+		resp.decode(t, &fromJSON)
+
+		_, body := postAccepting(t, srv, "/getAllComplaintsForUser", "application/xml", GetComplaintsRequest{SecretCode: code})
+		var envelope struct {
+			Data struct {
+				Items []Complaint `xml:"item"`
+			} `xml:"data"`
+		}
+		if err := xml.Unmarshal(body, &envelope); err != nil {
+			t.Fatalf("Failed to decode XML: %v\n%s", err, body)
+		}
+		if !reflect.DeepEqual(envelope.Data.Items, fromJSON) {
+			t.Errorf("Expected the XML list to match the JSON one\nJSON: %+v\nXML:  %+v", fromJSON, envelope.Data.Items)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		type xmlError struct {
+			Success   bool         `xml:"success"`
+			Error     string       `xml:"error"`
+			ErrorCode ErrorCode    `xml:"error_code"`
+			Fields    []FieldError `xml:"data>item"`
+		}
+		tests := []struct {
+			name       string
+			path       string
+			payload    interface{}
+			status     int
+			code       ErrorCode
+			fieldCount int
+		}{
+			{"Unauthorized", "/viewComplaint", ViewComplaintRequest{SecretCode: "WRONG", ComplaintID: 1}, http.StatusUnauthorized, ErrCodeUnauthorized, 0},
+			{"Validation", "/createAnnouncement", CreateAnnouncementRequest{}, http.StatusBadRequest, ErrCodeValidationFailed, 3},
+			{"Invalid JSON", "/viewComplaint", "not an object", http.StatusBadRequest, ErrCodeInvalidJSON, 0},
+		}
+		for _, tc := range tests {
+			resp, body := postAccepting(t, srv, tc.path, "text/xml", tc.payload)
+			var got xmlError
+			if err := xml.Unmarshal(body, &got); err != nil {
+				t.Errorf("%s: failed to decode XML: %v\n%s", tc.name, err, body)
+				continue
+			}
+			if resp.StatusCode != tc.status || got.Success || got.ErrorCode != tc.code || got.Error == "" || len(got.Fields) != tc.fieldCount {
+				t.Errorf("%s: expected %d %s with %d field errors, got %d %+v", tc.name, tc.status, tc.code, tc.fieldCount, resp.StatusCode, got)
+			}
+		}
+	})
+
+	t.Run("JSON By Default", func(t *testing.T) {
+		for _, accept := range []string{"", "text/html", "application/json", "application/xml;q=0.5, application/json"} {
+			resp, body := postAccepting(t, srv, "/viewComplaint", accept, ViewComplaintRequest{SecretCode: code, ComplaintID: 1})
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" || !json.Valid(body) {
+				t.Errorf("Accept %q: expected 200 JSON, got %d %q", accept, resp.StatusCode, resp.Header.Get("Content-Type"))
+			}
+		}
+	})
+
+	t.Run("Data Without An XML Form", func(t *testing.T) {
+		if _, err := encodeXML(APIResponse{Success: true, Data: map[string]int{"open": 1}}); err == nil {
+			t.Error("Expected maps to have no XML form")
+		}
+		w := httptest.NewRecorder()
+		respondWithJSON(&xmlResponseWriter{ResponseWriter: w}, http.StatusOK, APIResponse{Success: true, Data: map[string]int{"open": 1}})
+		if w.Header().Get("Content-Type") != "application/json" || !json.Valid(w.Body.Bytes()) {
+			t.Errorf("Expected a JSON fallback, got %q", w.Header().Get("Content-Type"))
+		}
+	})
+}
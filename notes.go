@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// maxAdminNoteLength caps a single note, in characters
+const maxAdminNoteLength = 2000
+
+// AdminNote is an internal triage note on a complaint. Notes are only ever
+// sent to admins; see complaintForViewer.
+type AdminNote struct {
+	AuthorID   int    `json:"author_id" xml:"author_id"`
+	AuthorName string `json:"author_name" xml:"author_name"`
+	Note       string `json:"note" xml:"note"`
+	CreatedAt  string `json:"created_at" xml:"created_at"`
+}
+
+type AddAdminNoteRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+	Note        string `json:"note"`
+}
+
+// /addAdminNote - Attach an internal note to a complaint (admin only)
+func addAdminNoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req AddAdminNoteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	note := sanitizeText(req.Note, true)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("complaint_id", req.ComplaintID)
+	v.required("note", note)
+	v.maxRunes("note", note, maxAdminNoteLength)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+
+	complaint.AdminNotes = append(complaint.AdminNotes, AdminNote{
+		AuthorID:   user.ID,
+		AuthorName: user.Name,
+		Note:       note,
+		CreatedAt:  getCurrentTime(),
+	})
+	touchComplaint(complaint)
+	// Notes are internal, so only watching admins hear about them
+	notifyWatchers(complaint, notificationAdminNote, fmt.Sprintf("%s added a note to %q", user.Name, complaint.Title), true)
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Admin note added successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestAdminNotes(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Note User", "notes@example.com")
+	complaint := submitTestComplaint(t, srv, code, "Broken heater", 6)
+
+	status, resp := postJSON(t, srv, "/addAdminNote", map[string]interface{}{
+		"secret_code": code, "complaint_id": complaint.ID, "note": "Let me in",
+	})
+	if status != http.StatusForbidden || resp.ErrorCode != ErrCodeForbidden {
+		t.Errorf("Expected 403 FORBIDDEN for non-admin, got %d %s", status, resp.ErrorCode)
+	}
+
+	status, resp = postJSON(t, srv, "/addAdminNote", map[string]interface{}{
+		"secret_code": adminSecret, "complaint_id": complaint.ID, "note": "  ",
+	})
+	if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+		t.Errorf("Expected 400 VALIDATION_FAILED for blank note, got %d %s", status, resp.ErrorCode)
+	}
+
+	status, resp = postJSON(t, srv, "/addAdminNote", map[string]interface{}{
+		"secret_code": adminSecret, "complaint_id": complaint.ID, "note": "Waiting on vendor quote",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d (%s)", status, resp.Error)
+	}
+	var noted Complaint
+	resp.decode(t, &noted)
+	if len(noted.AdminNotes) != 1 {
+		t.Fatalf("Expected one admin note, got %+v", noted.AdminNotes)
+	}
+	if n := noted.AdminNotes[0]; n.Note != "Waiting on vendor quote" || n.AuthorName != "System Administrator" || n.CreatedAt == "" {
+		t.Errorf("Unexpected note %+v", n)
+	}
+
+	t.Run("Admin Sees Notes", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/viewComplaint", map[string]interface{}{
+			"secret_code": adminSecret, "complaint_id": complaint.ID,
+		})
+		var viewed Complaint
+		resp.decode(t, &viewed)
+		if len(viewed.AdminNotes) != 1 {
+			t.Errorf("Expected admin view to include the note, got %+v", viewed.AdminNotes)
+		}
+
+		_, resp = postJSON(t, srv, "/getAllComplaintsForAdmin", map[string]string{"secret_code": adminSecret})
+		if !bytes.Contains(resp.Data, []byte(`"admin_notes"`)) {
+			t.Errorf("Expected admin listing to include admin_notes, got %s", resp.Data)
+		}
+	})
+
+	// Every response a regular user can get must be free of the field, not
+	// merely empty
+	t.Run("User Never Sees Notes", func(t *testing.T) {
+		requests := []struct {
+			endpoint string
+			payload  interface{}
+		}{
+			{"/viewComplaint", map[string]interface{}{"secret_code": code, "complaint_id": complaint.ID}},
+			{"/getAllComplaintsForUser", map[string]interface{}{"secret_code": code}},
+			{"/getAllComplaintsForUser", map[string]interface{}{"secret_code": code, "page": 1}},
+			{"/login", map[string]interface{}{"secret_code": code}},
+		}
+		for _, req := range requests {
+			status, resp := postJSON(t, srv, req.endpoint, req.payload)
+			if status != http.StatusOK {
+				t.Fatalf("%s: expected status 200, got %d (%s)", req.endpoint, status, resp.Error)
+			}
+			if bytes.Contains(resp.Data, []byte("admin_notes")) || bytes.Contains(resp.Data, []byte("vendor quote")) {
+				t.Errorf("%s leaked admin notes: %s", req.endpoint, resp.Data)
+			}
+		}
+	})
+
+	t.Run("Survives Resolve", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/resolveComplaint", map[string]interface{}{
+			"secret_code": adminSecret, "complaint_id": complaint.ID,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+		}
+		var resolved Complaint
+		resp.decode(t, &resolved)
+		if len(resolved.AdminNotes) != 1 {
+			t.Errorf("Expected note to survive resolve, got %+v", resolved.AdminNotes)
+		}
+
+		_, resp = postJSON(t, srv, "/login", map[string]string{"secret_code": code})
+		if bytes.Contains(resp.Data, []byte("admin_notes")) {
+			t.Errorf("Login leaked admin notes after resolve: %s", resp.Data)
+		}
+	})
+}
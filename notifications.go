@@ -0,0 +1,247 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Notification types
+const (
+	notificationResolved     = "complaint_resolved"
+	notificationAdminNote    = "admin_note"
+	notificationAnnouncement = "announcement"
+	notificationEscalated    = "complaint_escalated"
+	notificationCommented    = "comment_added"
+
+	notificationConfirmResolution  = "resolution_pending_confirmation"
+	notificationResolutionRejected = "resolution_rejected"
+)
+
+// Notification is an inbox entry telling a user something happened to one of
+// their complaints, or one they watch, or carrying an announcement
+type Notification struct {
+	ID             int    `json:"id" xml:"id"`
+	UserID         int    `json:"user_id" xml:"user_id"`
+	Type           string `json:"type" xml:"type"`
+	Message        string `json:"message" xml:"message"`
+	ComplaintID    int    `json:"complaint_id,omitempty" xml:"complaint_id,omitempty"`
+	AnnouncementID int    `json:"announcement_id,omitempty" xml:"announcement_id,omitempty"`
+	CreatedAt      string `json:"created_at" xml:"created_at"`
+	Read           bool   `json:"read" xml:"read"`
+}
+
+type GetNotificationsRequest struct {
+	SecretCode string `json:"secret_code"`
+	Page       *int   `json:"page,omitempty"`
+	PageSize   *int   `json:"page_size,omitempty"`
+}
+
+type MarkNotificationReadRequest struct {
+	SecretCode     string `json:"secret_code"`
+	NotificationID int    `json:"notification_id"`
+}
+
+// NotificationPage is one page of a user's inbox, unread first
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications" xml:"notifications>notification"`
+	Page          int            `json:"page" xml:"page"`
+	PageSize      int            `json:"page_size" xml:"page_size"`
+	TotalCount    int            `json:"total_count" xml:"total_count"`
+	TotalPages    int            `json:"total_pages" xml:"total_pages"`
+	UnreadCount   int            `json:"unread_count" xml:"unread_count"`
+}
+
+// notifyOwner adds a notification about complaint to its owner's inbox.
+// Callers must hold storage.mutex for writing.
+func notifyOwner(complaint *Complaint, notificationType, message string) {
+	notifyUser(complaint.UserID, complaint, notificationType, message)
+}
+
+// notifyUser adds a notification about complaint to userID's inbox. Callers
+// must hold storage.mutex for writing.
+func notifyUser(userID int, complaint *Complaint, notificationType, message string) {
+	addNotification(Notification{
+		UserID:      userID,
+		Type:        notificationType,
+		Message:     message,
+		ComplaintID: complaint.ID,
+	})
+}
+
+// addNotification adds notification to its user's inbox under the next ID.
+// Deactivated users get nothing. Callers must hold storage.mutex for
+// writing.
+func addNotification(notification Notification) {
+	if _, active := activeUser(notification.UserID); !active {
+		return
+	}
+	storage.notifIDGen++
+	notification.ID = storage.notifIDGen
+	notification.CreatedAt = getCurrentTime()
+	storage.notifications[notification.UserID] = append(storage.notifications[notification.UserID], &notification)
+}
+
+// visibleNotifications returns userID's inbox without notifications about
+// complaints in the trash or expired announcements. Callers must hold
+// storage.mutex.
+func visibleNotifications(userID int) []*Notification {
+	var visible []*Notification
+	now := clock.Now()
+	for _, notification := range storage.notifications[userID] {
+		if notification.AnnouncementID != 0 {
+			if announcement, exists := storage.announcements[notification.AnnouncementID]; exists && announcement.isActive(now) {
+				visible = append(visible, notification)
+			}
+			continue
+		}
+		if complaint, exists := storage.complaints[notification.ComplaintID]; exists && !complaint.IsDeleted {
+			visible = append(visible, notification)
+		}
+	}
+	return visible
+}
+
+// unreadNotificationCount counts userID's visible unread notifications.
+// Callers must hold storage.mutex.
+func unreadNotificationCount(userID int) int {
+	count := 0
+	for _, notification := range visibleNotifications(userID) {
+		if !notification.Read {
+			count++
+		}
+	}
+	return count
+}
+
+// removeComplaintNotifications drops every notification about complaintID,
+// once the complaint itself is gone for good. Callers must hold
+// storage.mutex for writing.
+func removeComplaintNotifications(complaintID int) {
+	removeNotifications(func(notification *Notification) bool { return notification.ComplaintID == complaintID })
+}
+
+// removeNotifications drops every notification matching drop. Callers must
+// hold storage.mutex for writing.
+func removeNotifications(drop func(notification *Notification) bool) {
+	for userID, inbox := range storage.notifications {
+		kept := inbox[:0]
+		for _, notification := range inbox {
+			if !drop(notification) {
+				kept = append(kept, notification)
+			}
+		}
+		if len(kept) == 0 {
+			delete(storage.notifications, userID)
+		} else {
+			storage.notifications[userID] = kept
+		}
+	}
+}
+
+// /getNotifications - The caller's inbox, unread first then newest first
+func getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req GetNotificationsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	page, pageSize, _, err := parsePage(req.Page, req.PageSize)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	inbox := []Notification{}
+	unread := 0
+	for _, notification := range visibleNotifications(user.ID) {
+		inbox = append(inbox, *notification)
+		if !notification.Read {
+			unread++
+		}
+	}
+	sort.Slice(inbox, func(i, j int) bool {
+		if inbox[i].Read != inbox[j].Read {
+			return !inbox[i].Read
+		}
+		return inbox[i].ID > inbox[j].ID
+	})
+
+	start, end, totalPages := pageBounds(len(inbox), page, pageSize)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Notifications retrieved successfully",
+		Data: NotificationPage{
+			Notifications: inbox[start:end],
+			Page:          page,
+			PageSize:      pageSize,
+			TotalCount:    len(inbox),
+			TotalPages:    totalPages,
+			UnreadCount:   unread,
+		},
+	})
+}
+
+// /markNotificationRead - Mark one of the caller's notifications as read
+func markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req MarkNotificationReadRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.NotificationID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid notification ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	// Only the caller's own inbox is searched, so other users' IDs look missing
+	for _, notification := range visibleNotifications(user.ID) {
+		if notification.ID == req.NotificationID {
+			notification.Read = true
+			respondWithJSON(w, http.StatusOK, APIResponse{
+				Success: true,
+				Message: "Notification marked as read",
+				Data:    *notification,
+			})
+			return
+		}
+	}
+
+	respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Notification not found")
+}
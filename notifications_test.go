@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestResolveNotifiesOwner(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Inbox User", "inbox@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+	complaint := submitTestComplaint(t, srv, code, "Noisy neighbours", 6)
+
+	inbox := func(secret string) NotificationPage {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/getNotifications", map[string]string{"secret_code": secret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+		}
+		var page NotificationPage
+		resp.decode(t, &page)
+		return page
+	}
+	unreadOnMe := func() int {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/me", map[string]string{"secret_code": code})
+		var me MeResponse
+		resp.decode(t, &me)
+		return me.UnreadNotifications
+	}
+
+	if page := inbox(code); page.TotalCount != 0 {
+		t.Fatalf("Expected an empty inbox before resolving, got %+v", page)
+	}
+
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+
+	page := inbox(code)
+	if page.TotalCount != 1 || page.UnreadCount != 1 || len(page.Notifications) != 1 {
+		t.Fatalf("Expected exactly one unread notification, got %+v", page)
+	}
+	notification := page.Notifications[0]
+	if notification.Type != notificationResolved || notification.ComplaintID != complaint.ID || notification.Read {
+		t.Errorf("Unexpected notification %+v", notification)
+	}
+	if got := unreadOnMe(); got != 1 {
+		t.Errorf("Expected /me to report 1 unread notification, got %d", got)
+	}
+	if page := inbox(otherCode); page.TotalCount != 0 {
+		t.Errorf("Expected other user's inbox to stay empty, got %+v", page)
+	}
+
+	status, resp := postJSON(t, srv, "/markNotificationRead", MarkNotificationReadRequest{SecretCode: otherCode, NotificationID: notification.ID})
+	if status != http.StatusNotFound || resp.ErrorCode != ErrCodeNotFound {
+		t.Errorf("Expected 404 marking someone else's notification, got %d %s", status, resp.ErrorCode)
+	}
+
+	status, resp = postJSON(t, srv, "/markNotificationRead", MarkNotificationReadRequest{SecretCode: code, NotificationID: notification.ID})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+	}
+
+	page = inbox(code)
+	if page.TotalCount != 1 || page.UnreadCount != 0 || !page.Notifications[0].Read {
+		t.Errorf("Expected the notification to be read, got %+v", page)
+	}
+	if got := unreadOnMe(); got != 0 {
+		t.Errorf("Expected /me to report 0 unread notifications, got %d", got)
+	}
+}
+
+func TestNotificationOrderingAndCleanup(t *testing.T) {
+	srv := newTestServer(t)
+	userID, code := registerTestUser(t, srv, "Busy Inbox", "busy@example.com")
+
+	var ids []int
+	for _, title := range []string{"First", "Second", "Third"} {
+		complaint := submitTestComplaint(t, srv, code, title, 4)
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		ids = append(ids, complaint.ID)
+	}
+
+	_, resp := postJSON(t, srv, "/getNotifications", map[string]string{"secret_code": code})
+	var page NotificationPage
+	resp.decode(t, &page)
+	postJSON(t, srv, "/markNotificationRead", MarkNotificationReadRequest{SecretCode: code, NotificationID: page.Notifications[0].ID})
+
+	// Unread first, newest first within each group
+	_, resp = postJSON(t, srv, "/getNotifications", map[string]interface{}{"secret_code": code, "page_size": 2})
+	resp.decode(t, &page)
+	if page.TotalPages != 2 || len(page.Notifications) != 2 {
+		t.Fatalf("Expected 2 pages of 2, got %+v", page)
+	}
+	if page.Notifications[0].ComplaintID != ids[1] || page.Notifications[1].ComplaintID != ids[0] {
+		t.Errorf("Expected unread notifications for %d then %d, got %+v", ids[1], ids[0], page.Notifications)
+	}
+
+	// Trashing a complaint hides its notification; purging removes it
+	postJSON(t, srv, "/deleteComplaint", DeleteComplaintRequest{SecretCode: code, ComplaintID: ids[0]})
+	_, resp = postJSON(t, srv, "/getNotifications", map[string]string{"secret_code": code})
+	resp.decode(t, &page)
+	if page.TotalCount != 2 || page.UnreadCount != 1 {
+		t.Errorf("Expected deleted complaint's notification hidden, got %+v", page)
+	}
+
+	postJSON(t, srv, "/purgeDeletedComplaints", map[string]interface{}{"secret_code": adminSecret, "older_than_days": 0})
+	storage.mutex.RLock()
+	remaining := len(storage.notifications[userID])
+	storage.mutex.RUnlock()
+	if remaining != 2 {
+		t.Errorf("Expected purge to remove the notification, %d remain", remaining)
+	}
+}
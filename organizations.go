@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Limits on organizations; lengths are in runes
+const maxOrganizationNameLength = 100
+
+// defaultOrganizationName is the organization created with the default
+// admin, which users registering without an invite code join
+const defaultOrganizationName = "Default"
+
+// Organization is a tenant. Users belong to exactly one, complaints to their
+// submitter's, and nobody but a super-admin sees across organizations.
+type Organization struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// InviteCode joins a registering user to the organization; the default
+	// organization has none, as registering without a code joins it
+	InviteCode string `json:"invite_code,omitempty"`
+	CreatedAt  string `json:"created_at"`
+
+	lastAssigneeID int // previous round-robin pick among its admins
+}
+
+type CreateOrganizationRequest struct {
+	SecretCode string `json:"secret_code"`
+	Name       string `json:"name"`
+	// AdminName and AdminEmail, when given, register the organization's
+	// first admin
+	AdminName  string `json:"admin_name,omitempty"`
+	AdminEmail string `json:"admin_email,omitempty"`
+}
+
+// CreateOrganizationResponse is the /createOrganization payload. Admin
+// carries the new admin's secret code, which is not shown again.
+type CreateOrganizationResponse struct {
+	Organization Organization `json:"organization"`
+	Admin        *User        `json:"admin,omitempty"`
+}
+
+type ListOrganizationsRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+// canSeeOrg reports whether viewer may see the data of organization orgID.
+// This is the one place tenants are separated: super-admins see every
+// organization and everyone else only their own. A nil viewer, such as an
+// anonymous /board, sees the default organization. Callers must hold
+// storage.mutex.
+func canSeeOrg(viewer *User, orgID int) bool {
+	if viewer == nil {
+		return orgID == storage.defaultOrgID
+	}
+	return viewer.IsSuperAdmin || viewer.OrgID == orgID
+}
+
+// scopedComplaint returns complaint id, deleted or not, if viewer's
+// organization may see it, and nil otherwise, so a complaint of another
+// organization looks exactly like a missing one. Handlers that take a
+// complaint ID from an admin must look it up through here or through
+// accessibleComplaint. Callers must hold storage.mutex.
+func scopedComplaint(viewer *User, id int) *Complaint {
+	complaint, exists := storage.complaints[id]
+	if !exists || !canSeeOrg(viewer, complaint.OrgID) {
+		return nil
+	}
+	return complaint
+}
+
+// scopedUser returns user id if viewer may act on them: they share an
+// organization, and only super-admins reach super-admins, so an admin can
+// never take over an account with wider reach than their own. Otherwise it
+// returns nil. Callers must hold storage.mutex.
+func scopedUser(viewer *User, id int) *User {
+	user, exists := storage.users[id]
+	if !exists || !canSeeOrg(viewer, user.OrgID) || (user.IsSuperAdmin && !viewer.IsSuperAdmin) {
+		return nil
+	}
+	return user
+}
+
+// organizationByInviteCode finds the organization code joins. Callers must
+// hold storage.mutex.
+func organizationByInviteCode(code string) *Organization {
+	for _, org := range storage.organizations {
+		if org.InviteCode != "" && org.InviteCode == code {
+			return org
+		}
+	}
+	return nil
+}
+
+func generateInviteCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "ORG_" + hex.EncodeToString(buf), nil
+}
+
+// /createOrganization - Create an organization, optionally with its first
+// admin (super-admin only)
+func createOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req CreateOrganizationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	name := sanitizeText(req.Name, false)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Organization name is required")
+		return
+	}
+	if err := checkLength("Organization name", name, maxOrganizationNameLength); err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	adminName, adminEmail := strings.TrimSpace(req.AdminName), strings.TrimSpace(req.AdminEmail)
+	if (adminName == "") != (adminEmail == "") {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Admin name and admin email must be given together")
+		return
+	}
+	if adminEmail != "" {
+		if apiErr := emailDomains.check(adminEmail); apiErr != nil {
+			respondWithAPIError(w, apiErr)
+			return
+		}
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	if adminEmail != "" && findUserByEmail(adminEmail) != nil {
+		respondWithError(w, http.StatusConflict, ErrCodeEmailExists, "User with this email already exists")
+		return
+	}
+
+	inviteCode, err := generateInviteCode()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate invite code")
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	for _, existing := range storage.organizations {
+		if strings.EqualFold(existing.Name, name) {
+			respondWithError(w, http.StatusConflict, ErrCodeOrganizationExists, "Organization already exists")
+			return
+		}
+	}
+
+	storage.orgIDGen++
+	org := &Organization{
+		ID:         storage.orgIDGen,
+		Name:       name,
+		InviteCode: inviteCode,
+		CreatedAt:  getCurrentTime(),
+	}
+	storage.organizations[org.ID] = org
+
+	result := CreateOrganizationResponse{Organization: *org}
+	if adminEmail != "" {
+		storage.userIDGen++
+		admin := &User{
+			ID:         storage.userIDGen,
+			SecretCode: generateSecretCode(storage.userIDGen),
+			Name:       adminName,
+			Email:      adminEmail,
+			Complaints: []Complaint{},
+			OrgID:      org.ID,
+			IsAdmin:    true,
+			IsActive:   true,
+		}
+		storage.usersMutex.Lock()
+		storage.users[admin.ID] = admin
+		storage.secretIndex[admin.SecretCode] = admin.ID
+		storage.usersMutex.Unlock()
+		snapshot := snapshotUser(admin)
+		result.Admin = &snapshot
+	}
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Organization created successfully",
+		Data:    result,
+	})
+}
+
+// /listOrganizations - Every organization with its invite code, by ID
+// (super-admin only)
+func listOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ListOrganizationsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	list := make([]Organization, 0, len(storage.organizations))
+	for _, org := range storage.organizations {
+		list = append(list, *org)
+	}
+	storage.mutex.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Organizations retrieved successfully",
+		Data:    list,
+	})
+}
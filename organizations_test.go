@@ -0,0 +1,297 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// createTestOrganization creates an organization with a first admin as the
+// default admin and returns its invite code and the admin's ID and code
+func createTestOrganization(t *testing.T, srv *httptest.Server, name, adminEmail string) (string, int, string) {
+	t.Helper()
+	status, resp := postJSON(t, srv, "/createOrganization", CreateOrganizationRequest{
+		SecretCode: adminSecret, Name: name, AdminName: name + " Admin", AdminEmail: adminEmail,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Create %s: expected 201, got %d (%s)", name, status, resp.Error)
+	}
+	var created CreateOrganizationResponse
+	resp.decode(t, &created)
+	if created.Organization.InviteCode == "" || created.Admin == nil || !created.Admin.IsAdmin || created.Admin.OrgID != created.Organization.ID {
+		t.Fatalf("Unexpected organization %+v", created)
+	}
+	return created.Organization.InviteCode, created.Admin.ID, created.Admin.SecretCode
+}
+
+// registerInOrganization registers a user with an invite code
+func registerInOrganization(t *testing.T, srv *httptest.Server, name, email, inviteCode string) (int, string) {
+	t.Helper()
+	status, resp := postJSON(t, srv, "/register", RegisterRequest{Name: name, Email: email, InviteCode: inviteCode})
+	if status != http.StatusCreated {
+		t.Fatalf("Register %s: expected 201, got %d (%s)", email, status, resp.Error)
+	}
+	var user User
+	resp.decode(t, &user)
+	return user.ID, user.SecretCode
+}
+
+func TestCreateOrganization(t *testing.T) {
+	srv := newTestServer(t)
+	inviteCode, _, adminCode := createTestOrganization(t, srv, "Acme", "admin@acme.example")
+	_, userCode := registerInOrganization(t, srv, "Acme User", "user@acme.example", inviteCode)
+
+	tests := []struct {
+		name   string
+		req    CreateOrganizationRequest
+		status int
+	}{
+		{"Org Admin", CreateOrganizationRequest{SecretCode: adminCode, Name: "Rogue"}, http.StatusForbidden},
+		{"Regular User", CreateOrganizationRequest{SecretCode: userCode, Name: "Rogue"}, http.StatusForbidden},
+		{"Missing Name", CreateOrganizationRequest{SecretCode: adminSecret, Name: "  "}, http.StatusBadRequest},
+		{"Admin Email Without Name", CreateOrganizationRequest{SecretCode: adminSecret, Name: "Beta", AdminEmail: "b@beta.example"}, http.StatusBadRequest},
+		{"Duplicate Name", CreateOrganizationRequest{SecretCode: adminSecret, Name: "acme"}, http.StatusConflict},
+		{"Admin Email Taken", CreateOrganizationRequest{SecretCode: adminSecret, Name: "Beta", AdminName: "B", AdminEmail: "user@acme.example"}, http.StatusConflict},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if status, resp := postJSON(t, srv, "/createOrganization", tc.req); status != tc.status {
+				t.Errorf("Expected %d, got %d (%s)", tc.status, status, resp.Error)
+			}
+		})
+	}
+
+	t.Run("Invite Codes", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/register", RegisterRequest{Name: "Lost", Email: "lost@example.com", InviteCode: "ORG_nope"})
+		if status != http.StatusForbidden || resp.ErrorCode != ErrCodeInviteInvalid {
+			t.Errorf("Expected 403 %s for an unknown invite code, got %d %s (%s)", ErrCodeInviteInvalid, status, resp.ErrorCode, resp.Error)
+		}
+		id, _ := registerTestUser(t, srv, "Walk In", "walkin@example.com")
+		storage.mutex.RLock()
+		orgID := storage.users[id].OrgID
+		storage.mutex.RUnlock()
+		if orgID != storage.defaultOrgID {
+			t.Errorf("Expected a registration without a code in the default organization, got %d", orgID)
+		}
+	})
+
+	t.Run("Listing", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/listOrganizations", ListOrganizationsRequest{SecretCode: adminCode}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for an org admin, got %d", status)
+		}
+		status, resp := postJSON(t, srv, "/listOrganizations", ListOrganizationsRequest{SecretCode: adminSecret})
+		var orgs []Organization
+		resp.decode(t, &orgs)
+		if status != http.StatusOK || len(orgs) != 2 || orgs[0].Name != defaultOrganizationName || orgs[1].InviteCode != inviteCode {
+			t.Errorf("Expected the default organization and Acme, got %d %+v", status, orgs)
+		}
+	})
+}
+
+func TestOrganizationIsolation(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	config.AssignmentStrategy = assignRoundRobin
+
+	acmeInvite, acmeAdminID, acmeAdmin := createTestOrganization(t, srv, "Acme", "admin@acme.example")
+	betaInvite, betaAdminID, betaAdmin := createTestOrganization(t, srv, "Beta", "admin@beta.example")
+	_, acmeUser := registerInOrganization(t, srv, "Acme User", "user@acme.example", acmeInvite)
+	betaUserID, betaUser := registerInOrganization(t, srv, "Beta User", "user@beta.example", betaInvite)
+
+	acme := submitTestComplaint(t, srv, acmeUser, "Acme printer", 5)
+	status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+		SecretCode: betaUser, Title: "Beta payroll", Summary: "Confidential to Beta", Rating: 7, Tags: []string{"beta-only"},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+	}
+	var beta Complaint
+	resp.decode(t, &beta)
+	betaTrashed := submitTestComplaint(t, srv, betaUser, "Beta trashed", 3)
+	postJSON(t, srv, "/deleteComplaint", DeleteComplaintRequest{SecretCode: betaAdmin, ComplaintID: betaTrashed.ID})
+
+	if beta.OrgID == acme.OrgID {
+		t.Fatalf("Expected complaints of different organizations, got %d and %d", acme.OrgID, beta.OrgID)
+	}
+	storage.mutex.RLock()
+	acmeAssignee, betaAssignee := storage.complaints[acme.ID].AssignedTo, storage.complaints[beta.ID].AssignedTo
+	storage.mutex.RUnlock()
+	if acmeAssignee != acmeAdminID || betaAssignee != betaAdminID {
+		t.Errorf("Expected each complaint assigned within its organization, got %d and %d", acmeAssignee, betaAssignee)
+	}
+
+	// Every way an Acme admin could reach a Beta complaint or user
+	t.Run("Org Admin Probes", func(t *testing.T) {
+		probes := []struct {
+			endpoint string
+			payload  interface{}
+		}{
+			{"/viewComplaint", ViewComplaintRequest{SecretCode: acmeAdmin, ComplaintID: beta.ID}},
+			{"/resolveComplaint", ResolveComplaintRequest{SecretCode: acmeAdmin, ComplaintID: beta.ID}},
+			{"/addAdminNote", AddAdminNoteRequest{SecretCode: acmeAdmin, ComplaintID: beta.ID, Note: "peek"}},
+			{"/setPriority", SetPriorityRequest{SecretCode: acmeAdmin, ComplaintID: beta.ID, Priority: priorityHigh, Version: beta.Version}},
+			{"/setComplaintTags", SetComplaintTagsRequest{SecretCode: acmeAdmin, ComplaintID: beta.ID, Tags: []string{"x"}}},
+			{"/setComplaintDepartment", SetComplaintDepartmentRequest{SecretCode: acmeAdmin, ComplaintID: beta.ID, Department: generalDepartment}},
+			{"/deleteComplaint", DeleteComplaintRequest{SecretCode: acmeAdmin, ComplaintID: beta.ID}},
+			{"/restoreComplaint", RestoreComplaintRequest{SecretCode: acmeAdmin, ComplaintID: betaTrashed.ID}},
+			{"/linkComplaints", LinkComplaintsRequest{SecretCode: acmeAdmin, ComplaintID: acme.ID, RelatedID: beta.ID}},
+			{"/mergeComplaints", MergeComplaintsRequest{SecretCode: acmeAdmin, PrimaryID: acme.ID, DuplicateIDs: []int{beta.ID}}},
+			{"/watchComplaint", WatchComplaintRequest{SecretCode: acmeAdmin, ComplaintID: beta.ID}},
+			{"/listWatchers", WatchComplaintRequest{SecretCode: acmeAdmin, ComplaintID: beta.ID}},
+			{"/watchComplaint", WatchComplaintRequest{SecretCode: acmeUser, ComplaintID: beta.ID}},
+			{"/viewComplaint", ViewComplaintRequest{SecretCode: acmeUser, ComplaintID: beta.ID}},
+			{"/loginHistory", LoginHistoryRequest{SecretCode: acmeAdmin, UserID: betaUserID}},
+			{"/exportMyData", ExportMyDataRequest{SecretCode: acmeAdmin, UserID: betaUserID}},
+			{"/unlockUser", UnlockUserRequest{SecretCode: acmeAdmin, UserID: betaUserID}},
+			{"/rotateSecretCode", RotateSecretCodeRequest{SecretCode: acmeAdmin, UserID: betaUserID}},
+		}
+		for _, probe := range probes {
+			if status, resp := postJSON(t, srv, probe.endpoint, probe.payload); status != http.StatusNotFound {
+				t.Errorf("%s %+v: expected 404, got %d (%s)", probe.endpoint, probe.payload, status, resp.Error)
+			}
+		}
+	})
+
+	t.Run("Listings", func(t *testing.T) {
+		for _, endpoint := range []string{"/getAllComplaintsForAdmin", "/getAllComplaintsForUser"} {
+			code := acmeAdmin
+			if endpoint == "/getAllComplaintsForUser" {
+				code = acmeUser
+			}
+			_, resp := postJSON(t, srv, endpoint, GetComplaintsRequest{SecretCode: code})
+			var list []Complaint
+			resp.decode(t, &list)
+			if len(list) != 1 || list[0].ID != acme.ID {
+				t.Errorf("%s: expected only the Acme complaint, got %+v", endpoint, list)
+			}
+		}
+
+		_, resp := postJSON(t, srv, "/listDeletedComplaints", GetComplaintsRequest{SecretCode: acmeAdmin})
+		var trash []Complaint
+		resp.decode(t, &trash)
+		if len(trash) != 0 {
+			t.Errorf("Expected an empty trash for Acme, got %+v", trash)
+		}
+
+		_, resp = postJSON(t, srv, "/listTags", GetComplaintsRequest{SecretCode: acmeAdmin})
+		var tags []TagCount
+		resp.decode(t, &tags)
+		if len(tags) != 0 {
+			t.Errorf("Expected no Beta tags, got %+v", tags)
+		}
+
+		lines := exportLines(t, srv.URL+"/exportComplaints?include_deleted=true&secret_code="+acmeAdmin)
+		if len(lines) != 1 || lines[0].ID != acme.ID {
+			t.Errorf("Expected the export to hold only the Acme complaint, got %d lines", len(lines))
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/report", ReportRequest{SecretCode: acmeAdmin})
+		var report Report
+		resp.decode(t, &report)
+		if report.TotalCreated != 1 || len(report.TopUsers) != 1 || report.TopUsers[0].UserName != "Acme User" {
+			t.Errorf("Expected a report on Acme alone, got %+v", report)
+		}
+
+		_, resp = postJSON(t, srv, "/agingReport", AgingReportRequest{SecretCode: acmeAdmin})
+		var aging AgingReport
+		resp.decode(t, &aging)
+		if aging.Open != 1 {
+			t.Errorf("Expected 1 open Acme complaint, got %d", aging.Open)
+		}
+	})
+
+	t.Run("Board", func(t *testing.T) {
+		_, body := getBoard(t, srv.URL+"/board?token="+acmeAdmin)
+		if !strings.Contains(body, "Acme printer") || strings.Contains(body, "Beta payroll") {
+			t.Errorf("Expected the Acme board without Beta complaints:\n%s", body)
+		}
+		// Anonymous visitors see the default organization only
+		_, body = getBoard(t, srv.URL+"/board")
+		if strings.Contains(body, "Acme printer") || strings.Contains(body, "Beta payroll") {
+			t.Errorf("Expected the public board to hide other organizations:\n%s", body)
+		}
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		storage.mutex.RLock()
+		admin := storage.users[acmeAdminID]
+		storage.mutex.RUnlock()
+		if !canSeeEvent(admin, Event{Complaint: acme}) || canSeeEvent(admin, Event{Complaint: beta}) {
+			t.Error("Expected Acme's stream to carry Acme events only")
+		}
+	})
+
+	t.Run("API Tokens", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/createApiToken", CreateAPITokenRequest{SecretCode: betaAdmin, Label: "Beta wallboard", Scope: scopeRead})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var token CreatedAPIToken
+		resp.decode(t, &token)
+
+		_, resp = postJSON(t, srv, "/listApiTokens", ListAPITokensRequest{SecretCode: acmeAdmin})
+		var tokens []APIToken
+		resp.decode(t, &tokens)
+		if len(tokens) != 0 {
+			t.Errorf("Expected Acme to see no Beta tokens, got %+v", tokens)
+		}
+		if status, _ := postJSON(t, srv, "/revokeApiToken", RevokeAPITokenRequest{SecretCode: acmeAdmin, TokenID: token.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 revoking a Beta token, got %d", status)
+		}
+		// A token acts with its creator's scope
+		if status, _ := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: token.Token, ComplaintID: acme.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected a Beta token to be refused Acme complaints, got %d", status)
+		}
+	})
+
+	t.Run("Instance Endpoints", func(t *testing.T) {
+		for endpoint, payload := range map[string]interface{}{
+			"/addDepartment":      AddDepartmentRequest{SecretCode: acmeAdmin, Name: "Acme IT", Keywords: []string{"printer"}},
+			"/runJobs":            RunJobsRequest{SecretCode: acmeAdmin},
+			"/createOrganization": CreateOrganizationRequest{SecretCode: acmeAdmin, Name: "Gamma"},
+		} {
+			if status, _ := postJSON(t, srv, endpoint, payload); status != http.StatusForbidden {
+				t.Errorf("%s: expected 403 for an org admin, got %d", endpoint, status)
+			}
+		}
+		resp, err := http.Get(srv.URL + "/admin/backup?secret_code=" + acmeAdmin)
+		if err != nil {
+			t.Fatalf("GET /admin/backup: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 for an org admin's backup, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Super Admin", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret})
+		var list []Complaint
+		resp.decode(t, &list)
+		if len(list) != 2 {
+			t.Errorf("Expected the super-admin to see both organizations, got %d", len(list))
+		}
+		status, _ := postJSON(t, srv, "/linkComplaints", LinkComplaintsRequest{SecretCode: adminSecret, ComplaintID: acme.ID, RelatedID: beta.ID})
+		if status != http.StatusBadRequest {
+			t.Errorf("Expected 400 linking across organizations, got %d", status)
+		}
+		status, _ = postJSON(t, srv, "/mergeComplaints", MergeComplaintsRequest{SecretCode: adminSecret, PrimaryID: acme.ID, DuplicateIDs: []int{beta.ID}})
+		if status != http.StatusBadRequest {
+			t.Errorf("Expected 400 merging across organizations, got %d", status)
+		}
+	})
+
+	t.Run("Default Admins Cannot Reach The Super Admin", func(t *testing.T) {
+		_, code := registerTestAdmin(t, srv, "Local Admin", "local@example.com")
+		storage.mutex.RLock()
+		superID := storage.defaultAdminID
+		storage.mutex.RUnlock()
+		status, _ := postJSON(t, srv, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: code, UserID: superID})
+		if status != http.StatusNotFound {
+			t.Errorf("Expected 404 rotating the super-admin's code, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outbox channels, the destinations notifications are sent to
+const (
+	outboxChannelEmail = "email"
+	outboxChannelSlack = "slack"
+)
+
+const (
+	// outboxPollInterval is the longest the dispatcher sleeps between looks
+	// at the outbox; it is also woken whenever a message is queued and when
+	// the next retry is due
+	outboxPollInterval = time.Second
+	// maxOutboxRetryDelay caps the backoff between two attempts
+	maxOutboxRetryDelay = time.Hour
+)
+
+// OutboxMessage is a notification waiting to be sent, or one that kept
+// failing and was dead-lettered. Its ID goes with every attempt, so a
+// receiver can drop the duplicates at-least-once delivery brings.
+type OutboxMessage struct {
+	ID          int             `json:"id"`
+	Channel     string          `json:"channel"`
+	Event       string          `json:"event"` // the event type it announces
+	ComplaintID int             `json:"complaint_id"`
+	Payload     json.RawMessage `json:"payload"` // the SlackMessage or EmailMessage
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   string          `json:"created_at"`
+	DeadAt      string          `json:"dead_at,omitempty"` // when it was dead-lettered
+	// CorrelationID is that of the request that caused the event; it goes
+	// with every attempt
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	nextAttempt time.Time // zero when due now
+}
+
+type RetryDeadLetterRequest struct {
+	ID int `json:"id"`
+}
+
+// outboxChannel renders and sends the notifications of one destination
+type outboxChannel interface {
+	// message is what event should send, if anything. Callers must hold
+	// storage.mutex.
+	message(event Event) (interface{}, bool)
+	// deliver makes one attempt at sending payload, a JSON-encoded message,
+	// tagged with the event and correlation IDs. An error with a retryable
+	// method returning false is not retried.
+	deliver(eventID int, correlationID string, payload []byte) error
+}
+
+// Dispatcher sends the outbox in the background, oldest message first,
+// retrying with exponential backoff. A message that fails
+// config.OutboxMaxAttempts times, or is refused outright, is dead-lettered
+// until an admin retries it. Messages for a channel this server does not
+// have stay queued.
+type Dispatcher struct {
+	channels map[string]outboxChannel
+	names    []string // of channels, sorted so messages are queued in a fixed order
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+var dispatcher = newDispatcher(nil)
+
+func newDispatcher(channels map[string]outboxChannel) *Dispatcher {
+	d := &Dispatcher{
+		channels: channels,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	for name := range channels {
+		d.names = append(d.names, name)
+	}
+	sort.Strings(d.names)
+	return d
+}
+
+// start begins sending in the background
+func (d *Dispatcher) start() {
+	d.wg.Add(1)
+	go d.run()
+}
+
+// shutdown stops sending once the attempt in progress is over. Whatever is
+// left stays in the outbox, and so in backups.
+func (d *Dispatcher) shutdown() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+// notify wakes the dispatcher without waiting for it
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// publishEvent announces a change to complaint, made by the request with
+// correlationID, on the event bus and queues the notifications it calls
+// for. Callers must hold storage.mutex for writing, so the messages are
+// stored together with the change.
+func publishEvent(eventType string, complaint *Complaint, correlationID string) {
+	dispatcher.enqueue(eventBus.publish(eventType, *complaint, correlationID))
+}
+
+// enqueue adds the messages of every channel for event to the outbox.
+// Callers must hold storage.mutex for writing.
+func (d *Dispatcher) enqueue(event Event) {
+	queued := false
+	for _, name := range d.names {
+		message, ok := d.channels[name].message(event)
+		if !ok {
+			continue
+		}
+		payload, err := json.Marshal(message)
+		if err != nil {
+			logger.Error("Outbox message not queued", "channel", name, logKeyComplaintID, event.Complaint.ID, logKeyError, err)
+			continue
+		}
+		storage.outboxIDGen++
+		storage.outbox[storage.outboxIDGen] = &OutboxMessage{
+			ID:          storage.outboxIDGen,
+			Channel:     name,
+			Event:       event.Type,
+			ComplaintID: event.Complaint.ID,
+			Payload:     payload,
+			CreatedAt:   getCurrentTime(),
+
+			CorrelationID: event.CorrelationID,
+		}
+		queued = true
+	}
+	if queued {
+		d.notify()
+	}
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+
+	for {
+		d.sendDue()
+		wait := outboxPollInterval
+		now := clock.Now()
+		if _, next := d.dueMessages(now); !next.IsZero() && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-d.stop:
+			timer.Stop()
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// dueMessages returns the messages d can send whose next attempt is due at
+// now, by ID, and the earliest attempt of the others
+func (d *Dispatcher) dueMessages(now time.Time) ([]*OutboxMessage, time.Time) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	due := []*OutboxMessage{}
+	var next time.Time
+	for _, message := range storage.outbox {
+		if message.DeadAt != "" || d.channels[message.Channel] == nil {
+			continue
+		}
+		if !message.nextAttempt.After(now) {
+			due = append(due, message)
+		} else if next.IsZero() || message.nextAttempt.Before(next) {
+			next = message.nextAttempt
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due, next
+}
+
+// sendDue makes one attempt at each due message. The ID, channel, payload
+// and correlation ID of a message never change, so they are read without
+// the lock.
+func (d *Dispatcher) sendDue() {
+	due, _ := d.dueMessages(clock.Now())
+	for _, message := range due {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+		err := d.channels[message.Channel].deliver(message.ID, message.CorrelationID, message.Payload)
+		d.record(message, err)
+	}
+}
+
+// record updates message after an attempt ended with err: it leaves the
+// outbox once sent, and otherwise waits for its next attempt or is
+// dead-lettered
+func (d *Dispatcher) record(message *OutboxMessage, err error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if storage.outbox[message.ID] != message {
+		return // replaced by a restore while it was being sent
+	}
+	if err == nil {
+		delete(storage.outbox, message.ID)
+		return
+	}
+
+	message.Attempts++
+	message.LastError = err.Error()
+	var refusal interface{ retryable() bool }
+	if message.Attempts >= config.OutboxMaxAttempts || (errors.As(err, &refusal) && !refusal.retryable()) {
+		message.DeadAt = getCurrentTime()
+		logger.Error("Outbox message dead-lettered", "channel", message.Channel, "message_id", message.ID, logKeyComplaintID, message.ComplaintID, "attempts", message.Attempts, logKeyError, err)
+		return
+	}
+	message.nextAttempt = clock.Now().Add(outboxRetryDelay(message.Attempts))
+}
+
+// outboxRetryDelay is the wait after a message's attempts-th failure:
+// config.OutboxRetryDelay, doubling each time up to maxOutboxRetryDelay
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := config.OutboxRetryDelay
+	for i := 1; i < attempts && delay < maxOutboxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxOutboxRetryDelay {
+		delay = maxOutboxRetryDelay
+	}
+	return delay
+}
+
+// /admin/deadLetters - The notifications that were given up on, by ID
+// (super-admin only)
+func deadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if authenticateSuperAdminRequest(w, r) == nil {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	deadLetters := []OutboxMessage{}
+	for _, message := range storage.outbox {
+		if message.DeadAt != "" {
+			deadLetters = append(deadLetters, *message)
+		}
+	}
+	sort.Slice(deadLetters, func(i, j int) bool { return deadLetters[i].ID < deadLetters[j].ID })
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Dead letters retrieved successfully",
+		Data:    deadLetters,
+	})
+}
+
+// /admin/retryDeadLetter - Put a dead letter back in the outbox for a fresh
+// round of attempts (super-admin only)
+func retryDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if authenticateSuperAdminRequest(w, r) == nil {
+		return
+	}
+
+	var req RetryDeadLetterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.positiveID("id", req.ID)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	storage.mutex.Lock()
+	message, exists := storage.outbox[req.ID]
+	if !exists || message.DeadAt == "" {
+		storage.mutex.Unlock()
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Dead letter not found")
+		return
+	}
+	message.Attempts = 0
+	message.DeadAt = ""
+	message.nextAttempt = time.Time{}
+	retried := *message
+	storage.mutex.Unlock()
+
+	dispatcher.notify()
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Dead letter queued for retry",
+		Data:    retried,
+	})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// useDispatcher sends the test's notifications through channels, retrying
+// a millisecond after a failure
+func useDispatcher(t *testing.T, channels map[string]outboxChannel) {
+	t.Helper()
+	config.OutboxRetryDelay = time.Millisecond
+	previous, current := dispatcher, newDispatcher(channels)
+	dispatcher = current
+	current.start()
+	t.Cleanup(func() {
+		current.shutdown()
+		dispatcher = previous
+	})
+}
+
+// outboxCounts returns how many messages are waiting to be sent and how
+// many were dead-lettered
+func outboxCounts() (pending, dead int) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	for _, message := range storage.outbox {
+		if message.DeadAt == "" {
+			pending++
+		} else {
+			dead++
+		}
+	}
+	return pending, dead
+}
+
+// waitForOutbox waits until the outbox holds pending waiting and dead
+// dead-lettered messages
+func waitForOutbox(t *testing.T, pending, dead int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		gotPending, gotDead := outboxCounts()
+		if gotPending == pending && gotDead == dead {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d pending and %d dead outbox messages, got %d and %d", pending, dead, gotPending, gotDead)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForDeadLetters waits until the outbox holds n dead letters and
+// nothing else
+func waitForDeadLetters(t *testing.T, n int) {
+	t.Helper()
+	waitForOutbox(t, 0, n)
+}
+
+func getDeadLetters(t *testing.T, srvURL, secretCode string) (int, []OutboxMessage) {
+	t.Helper()
+	resp, err := http.Get(srvURL + "/admin/deadLetters?secret_code=" + secretCode)
+	if err != nil {
+		t.Fatalf("GET /admin/deadLetters failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var result testResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	var deadLetters []OutboxMessage
+	if resp.StatusCode == http.StatusOK {
+		result.decode(t, &deadLetters)
+	}
+	return resp.StatusCode, deadLetters
+}
+
+func TestOutbox(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	config.OutboxMaxAttempts = 3
+	capture, hook := newSlackCapture(t)
+	useDispatcher(t, map[string]outboxChannel{outboxChannelSlack: newSlackNotifier(hook.URL, "")})
+	_, code := registerTestUser(t, srv, "Outbox User", "outbox@example.com")
+
+	submitUrgent := func(title string) Complaint {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: code, Title: title, Summary: "Summary", Rating: 9, Priority: priorityCritical,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		return complaint
+	}
+	failNext := func(n, status int) {
+		capture.mutex.Lock()
+		defer capture.mutex.Unlock()
+		capture.failures, capture.status = capture.requests+n, status
+		capture.eventIDs = nil
+	}
+
+	t.Run("Flaky Receiver", func(t *testing.T) {
+		failNext(2, http.StatusServiceUnavailable)
+		submitUrgent("Lift stuck")
+		capture.wait(t)
+		waitForOutbox(t, 0, 0)
+
+		capture.mutex.Lock()
+		defer capture.mutex.Unlock()
+		if len(capture.eventIDs) != 3 {
+			t.Fatalf("Expected 3 attempts, got %d", len(capture.eventIDs))
+		}
+		for _, id := range capture.eventIDs {
+			if id == "" || id != capture.eventIDs[0] {
+				t.Errorf("Expected every attempt to carry the same event ID, got %v", capture.eventIDs)
+				break
+			}
+		}
+	})
+
+	t.Run("Dead Letter And Retry", func(t *testing.T) {
+		failNext(config.OutboxMaxAttempts, http.StatusInternalServerError)
+		complaint := submitUrgent("Boiler leaking")
+		waitForDeadLetters(t, 1)
+
+		status, deadLetters := getDeadLetters(t, srv.URL, adminSecret)
+		if status != http.StatusOK || len(deadLetters) != 1 {
+			t.Fatalf("Expected 1 dead letter, got %d %+v", status, deadLetters)
+		}
+		dead := deadLetters[0]
+		if dead.Channel != outboxChannelSlack || dead.Event != eventComplaintCreated || dead.ComplaintID != complaint.ID ||
+			dead.Attempts != config.OutboxMaxAttempts || dead.LastError != "webhook returned status 500" || dead.DeadAt == "" {
+			t.Errorf("Unexpected dead letter %+v", dead)
+		}
+		if status, _ := getDeadLetters(t, srv.URL, code); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a user, got %d", status)
+		}
+
+		status, resp := postJSON(t, srv, "/admin/retryDeadLetter?secret_code="+adminSecret, RetryDeadLetterRequest{ID: dead.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 retrying, got %d (%s)", status, resp.Error)
+		}
+		capture.wait(t)
+		waitForOutbox(t, 0, 0)
+		capture.mutex.Lock()
+		if last := capture.eventIDs[len(capture.eventIDs)-1]; last != strconv.Itoa(dead.ID) {
+			t.Errorf("Expected the retry to keep event ID %d, got %s", dead.ID, last)
+		}
+		capture.mutex.Unlock()
+
+		if status, _ := postJSON(t, srv, "/admin/retryDeadLetter?secret_code="+adminSecret, RetryDeadLetterRequest{ID: dead.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 retrying a delivered message, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/admin/retryDeadLetter?secret_code="+adminSecret, RetryDeadLetterRequest{}); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 without an ID, got %d", status)
+		}
+	})
+}
+
+func TestOutboxSurvivesRestore(t *testing.T) {
+	srv := newTestServer(t)
+	capture, hook := newSlackCapture(t)
+	slack := newSlackNotifier(hook.URL, "")
+
+	// The server goes down before its dispatcher gets to the message
+	previous := dispatcher
+	dispatcher = newDispatcher(map[string]outboxChannel{outboxChannelSlack: slack})
+	t.Cleanup(func() { dispatcher = previous })
+	_, code := registerTestUser(t, srv, "Restart User", "restart@example.com")
+	status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+		SecretCode: code, Title: "Gas smell", Summary: "Summary", Rating: 10, Priority: priorityCritical,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+	}
+	document := fetchBackup(t, srv)
+
+	var backup Backup
+	if err := json.Unmarshal(document, &backup); err != nil {
+		t.Fatalf("Backup does not parse: %v", err)
+	}
+	if len(backup.Outbox) != 1 || backup.Outbox[0].Channel != outboxChannelSlack {
+		t.Fatalf("Expected the queued Slack message in the backup, got %+v", backup.Outbox)
+	}
+
+	srv = newTestServer(t)
+	if status, resp := restoreBackup(t, srv, document); status != http.StatusOK {
+		t.Fatalf("Expected 200 restoring, got %d (%s)", status, resp.Error)
+	}
+	useDispatcher(t, map[string]outboxChannel{outboxChannelSlack: slack})
+
+	message := capture.wait(t)
+	if message.Text == "" {
+		t.Error("Expected the restored message to be posted")
+	}
+	waitForOutbox(t, 0, 0)
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	if want := strconv.Itoa(backup.Outbox[0].ID); len(capture.eventIDs) != 1 || capture.eventIDs[0] != want {
+		t.Errorf("Expected one post with event ID %s, got %v", want, capture.eventIDs)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// PATCH /v1/complaints/{id} takes a JSON merge patch (RFC 7396): a field
+// left out is left alone, a field set to null is removed, and any other
+// value replaces the field's. Only optional fields can be removed.
+
+// immutableComplaintFields are complaint fields a patch may never change
+var immutableComplaintFields = map[string]bool{
+	"id":         true,
+	"reference":  true,
+	"user_id":    true,
+	"org_id":     true,
+	"user_name":  true,
+	"created_at": true,
+	"source":     true,
+}
+
+// adminPatchFields are patchable fields only admins may change, as on
+// /setPriority and /setComplaintDepartment
+var adminPatchFields = map[string]bool{
+	"priority":   true,
+	"department": true,
+}
+
+// complaintPatch is a validated merge patch. A nil field is left alone; a
+// removed field is a pointer to its zero value.
+type complaintPatch struct {
+	Title      *string
+	Summary    *string
+	Rating     *int
+	Priority   *string
+	Tags       *[]string
+	Department *string // "" has the routing rules choose again
+	Building   *string
+	Floor      *string
+	Room       *string
+	Version    int // opt-in concurrency check, as on the other writes
+
+	fields []string // the patched fields, sorted
+}
+
+// parseComplaintPatch decodes and validates a merge patch, naming every
+// field that cannot be patched
+func parseComplaintPatch(body []byte) (complaintPatch, *APIError) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		return complaintPatch{}, newAPIError(http.StatusBadRequest, ErrCodeInvalidJSON, "A merge patch must be a JSON object")
+	}
+
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var patch complaintPatch
+	var v validator
+	for _, name := range names {
+		raw := members[name]
+		isNull := string(raw) == "null"
+
+		// decode reads the member into target, recording a type error
+		decode := func(target interface{}, kind string) bool {
+			if err := json.Unmarshal(raw, target); err != nil {
+				v.add(name, "must be "+kind)
+				return false
+			}
+			return true
+		}
+		// text reads a string member, with null as ""
+		text := func(nullable bool) *string {
+			value := ""
+			if isNull {
+				if !nullable {
+					v.add(name, "cannot be removed")
+					return nil
+				}
+			} else if !decode(&value, "a string") {
+				return nil
+			}
+			return &value
+		}
+
+		switch {
+		case immutableComplaintFields[name]:
+			v.add(name, "cannot be changed")
+			continue
+		case name == "version":
+			if !isNull && decode(&patch.Version, "an integer") && patch.Version < 0 {
+				v.add(name, "must be positive")
+			}
+			continue
+		}
+
+		switch name {
+		case "title":
+			if patch.Title = text(false); patch.Title != nil {
+				*patch.Title = sanitizeText(*patch.Title, false)
+				v.required(name, *patch.Title)
+				v.maxRunes(name, *patch.Title, maxTitleLength)
+			}
+		case "summary":
+			if patch.Summary = text(false); patch.Summary != nil {
+				*patch.Summary = sanitizeText(*patch.Summary, true)
+				v.required(name, *patch.Summary)
+				v.maxRunes(name, *patch.Summary, maxSummaryLength)
+			}
+		case "rating":
+			var rating int
+			if isNull {
+				v.add(name, "cannot be removed")
+			} else if decode(&rating, "an integer") {
+				scale := currentRatingScale()
+				v.between(name, rating, scale.Min, scale.Max)
+				patch.Rating = &rating
+			}
+		case "priority":
+			if patch.Priority = text(false); patch.Priority != nil {
+				v.oneOf(name, *patch.Priority, priorities)
+			}
+		case "tags":
+			tags := []string{}
+			if !isNull && !decode(&tags, "a list of strings") {
+				break
+			}
+			tags, err := normalizeTags(tags)
+			v.check(name, err)
+			patch.Tags = &tags
+		case "department":
+			if patch.Department = text(true); patch.Department != nil {
+				*patch.Department = strings.TrimSpace(*patch.Department)
+				v.maxRunes(name, *patch.Department, maxDepartmentNameLength)
+			}
+		case "building", "floor", "room":
+			value := text(true)
+			if value == nil {
+				break
+			}
+			*value = sanitizeText(*value, false)
+			v.maxRunes(name, *value, maxLocationLength)
+			switch name {
+			case "building":
+				patch.Building = value
+			case "floor":
+				patch.Floor = value
+			default:
+				patch.Room = value
+			}
+		default:
+			v.add(name, "cannot be patched")
+			continue
+		}
+		patch.fields = append(patch.fields, name)
+	}
+	return patch, v.err()
+}
+
+// applyComplaintPatch applies patch to complaint for user, returning the
+// fields whose values changed. Nothing is changed when it fails. Callers
+// must hold storage.mutex for writing.
+func applyComplaintPatch(user *User, complaint *Complaint, patch complaintPatch, verdict *ModerationResult) ([]string, *APIError) {
+	if !adminOnly(actorOf(user)).allowed() {
+		var denied []string
+		for _, name := range patch.fields {
+			if adminPatchFields[name] {
+				denied = append(denied, name)
+			}
+		}
+		if len(denied) > 0 {
+			return nil, newAPIError(http.StatusForbidden, ErrCodeForbidden, "Access denied. Only admins can change "+strings.Join(denied, ", "))
+		}
+	}
+
+	// Worked out on a copy, so a failed check leaves the complaint as it was
+	updated := *complaint
+	if patch.Title != nil {
+		updated.Title = *patch.Title
+	}
+	if patch.Summary != nil {
+		updated.Summary = *patch.Summary
+	}
+	if patch.Rating != nil {
+		updated.Rating = *patch.Rating
+		updated.RatingScale = currentRatingScale()
+	}
+	if patch.Priority != nil {
+		updated.Priority = *patch.Priority
+	}
+	if patch.Tags != nil {
+		updated.Tags = *patch.Tags
+	}
+	if patch.Department != nil {
+		if *patch.Department == "" {
+			updated.Department = routeComplaint(&updated)
+		} else {
+			name, exists := resolveDepartmentName(*patch.Department)
+			if !exists {
+				return nil, newAPIError(http.StatusBadRequest, ErrCodeValidationFailed, "Department does not exist")
+			}
+			updated.Department = name
+		}
+	}
+	if patch.Building != nil || patch.Floor != nil {
+		building, floor := updated.Building, updated.Floor
+		if patch.Building != nil {
+			building = *patch.Building
+		}
+		if patch.Floor != nil {
+			floor = *patch.Floor
+		}
+		var apiErr *APIError
+		if updated.Building, updated.Floor, apiErr = checkLocation(updated.OrgID, building, floor); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+	if patch.Room != nil {
+		updated.Room = *patch.Room
+	}
+
+	var changed []string
+	for _, field := range []struct {
+		name  string
+		equal bool
+	}{
+		{"title", updated.Title == complaint.Title},
+		{"summary", updated.Summary == complaint.Summary},
+		{"rating", updated.Rating == complaint.Rating},
+		{"priority", updated.Priority == complaint.Priority},
+		{"tags", strings.Join(updated.Tags, ",") == strings.Join(complaint.Tags, ",")},
+		{"department", updated.Department == complaint.Department},
+		{"building", updated.Building == complaint.Building},
+		{"floor", updated.Floor == complaint.Floor},
+		{"room", updated.Room == complaint.Room},
+	} {
+		if !field.equal {
+			changed = append(changed, field.name)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	if updated.Title != complaint.Title || updated.Summary != complaint.Summary {
+		updated.words = similarityWords(updated.Title, updated.Summary)
+		// New text from a non-admin is checked like a new complaint's
+		if verdict != nil && verdict.Flagged {
+			updated.NeedsReview = true
+			updated.ModerationReason = verdict.Reason
+		}
+	}
+	if updated.Priority != complaint.Priority || updated.Department != complaint.Department {
+		resetDueAt(&updated)
+	}
+	*complaint = updated
+	return changed, nil
+}
+
+// PATCH /v1/complaints/{id} - Change some of a complaint's fields with a
+// JSON merge patch (owner or admin; priority and department admin only)
+func v1PatchComplaintHandler(w http.ResponseWriter, r *http.Request, params pathParams) {
+	id, ok := complaintIDParam(w, params)
+	if !ok {
+		return
+	}
+
+	body, apiErr := readJSONBody(r.Body)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+	patch, apiErr := parseComplaintPatch(body)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user, secret := authenticateRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	// Before locking, as the checker may call out to another service
+	var verdict *ModerationResult
+	if !adminOnly(actorOf(user)).allowed() && (patch.Title != nil || patch.Summary != nil) {
+		title, summary := "", ""
+		if patch.Title != nil {
+			title = *patch.Title
+		}
+		if patch.Summary != nil {
+			summary = *patch.Summary
+		}
+		result := moderate(title, summary)
+		verdict = &result
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	// The secret code may have been rotated since it was looked up
+	if !credentialBelongsTo(secret, user.ID) {
+		respondWithError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid secret code")
+		return
+	}
+
+	complaint, apiErr := accessibleComplaint(user, id, false, "Access denied. You can only update your own complaints")
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	if patch.Version != 0 {
+		if err := checkVersion(user, complaint, patch.Version); err != nil {
+			respondWithAPIError(w, err)
+			return
+		}
+	}
+
+	changed, apiErr := applyComplaintPatch(user, complaint, patch, verdict)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	message := "Complaint unchanged"
+	if len(changed) > 0 {
+		addHistory(complaint, HistoryEntry{
+			Action:        historyUpdated,
+			ActorID:       user.ID,
+			Detail:        strings.Join(changed, ", "),
+			CorrelationID: correlationID(r),
+		})
+		touchComplaint(complaint)
+		message = "Complaint updated successfully"
+	}
+
+	w.Header().Set("ETag", complaintETag(*complaint))
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
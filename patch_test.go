@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestV1PatchComplaint(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Patch User", "patch@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+
+	status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+		SecretCode: code, Title: "Leaking tap", Summary: "Drips all night", Rating: 5,
+		Tags: []string{"plumbing", "recurring"}, Building: "North", Floor: "2", Room: "214",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+	}
+	var complaint Complaint
+	resp.decode(t, &complaint)
+	path := "/v1/complaints/" + strconv.Itoa(complaint.ID)
+
+	t.Run("Null Clears", func(t *testing.T) {
+		resp, body := doV1(t, srv, http.MethodPatch, path, code, map[string]interface{}{
+			"tags": nil, "building": nil, "summary": "Drips all day too",
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", resp.StatusCode, body.Error)
+		}
+		var patched Complaint
+		body.decode(t, &patched)
+		if len(patched.Tags) != 0 || patched.Building != "" || patched.Summary != "Drips all day too" {
+			t.Errorf("Expected tags and building removed and a new summary, got %+v", patched)
+		}
+		if patched.Version != complaint.Version+1 {
+			t.Errorf("Expected version %d, got %d", complaint.Version+1, patched.Version)
+		}
+
+		storage.mutex.RLock()
+		history := storage.complaints[complaint.ID].History
+		storage.mutex.RUnlock()
+		last := history[len(history)-1]
+		if last.Action != historyUpdated || last.Detail != "summary, tags, building" {
+			t.Errorf("Expected the changed fields in history, got %+v", last)
+		}
+		complaint = patched
+	})
+
+	t.Run("Absent Fields Untouched", func(t *testing.T) {
+		resp, body := doV1(t, srv, http.MethodPatch, path, code, map[string]interface{}{"rating": 8})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", resp.StatusCode, body.Error)
+		}
+		var patched Complaint
+		body.decode(t, &patched)
+		if patched.Rating != 8 {
+			t.Errorf("Expected rating 8, got %d", patched.Rating)
+		}
+		if patched.Title != complaint.Title || patched.Summary != complaint.Summary || patched.Floor != "2" || patched.Room != "214" || patched.Priority != complaint.Priority {
+			t.Errorf("Expected the other fields as they were, got %+v", patched)
+		}
+
+		// Setting a field to what it already is changes nothing
+		resp, body = doV1(t, srv, http.MethodPatch, path, code, map[string]interface{}{"rating": 8})
+		var unchanged Complaint
+		body.decode(t, &unchanged)
+		if resp.StatusCode != http.StatusOK || unchanged.Version != patched.Version {
+			t.Errorf("Expected 200 at version %d, got %d at version %d", patched.Version, resp.StatusCode, unchanged.Version)
+		}
+		complaint = patched
+	})
+
+	t.Run("Immutable Fields", func(t *testing.T) {
+		resp, body := doV1(t, srv, http.MethodPatch, path, code, map[string]interface{}{
+			"id": 99, "user_id": 1, "created_at": "2020-01-01 00:00:00", "title": "Renamed",
+		})
+		if resp.StatusCode != http.StatusBadRequest || body.ErrorCode != ErrCodeValidationFailed {
+			t.Fatalf("Expected 400 VALIDATION_FAILED, got %d %s", resp.StatusCode, body.ErrorCode)
+		}
+		var fields []FieldError
+		body.decode(t, &fields)
+		named := map[string]bool{}
+		for _, field := range fields {
+			named[field.Field] = true
+		}
+		if len(fields) != 3 || !named["id"] || !named["user_id"] || !named["created_at"] {
+			t.Errorf("Expected id, user_id and created_at named, got %+v", fields)
+		}
+
+		storage.mutex.RLock()
+		stored := *storage.complaints[complaint.ID]
+		storage.mutex.RUnlock()
+		if stored.Title != complaint.Title || stored.Version != complaint.Version {
+			t.Errorf("Expected a rejected patch to change nothing, got %q at version %d", stored.Title, stored.Version)
+		}
+	})
+
+	t.Run("Rejected Patches", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			secret string
+			patch  interface{}
+			status int
+		}{
+			{"Required Field Removed", code, map[string]interface{}{"title": nil}, http.StatusBadRequest},
+			{"Rating Out Of Range", code, map[string]interface{}{"rating": 42}, http.StatusBadRequest},
+			{"Wrong Type", code, map[string]interface{}{"tags": "plumbing"}, http.StatusBadRequest},
+			{"Unknown Field", code, map[string]interface{}{"status": "resolved"}, http.StatusBadRequest},
+			{"Not An Object", code, []string{"title"}, http.StatusBadRequest},
+			{"Admin Field", code, map[string]interface{}{"priority": priorityHigh}, http.StatusForbidden},
+			{"Another User", otherCode, map[string]interface{}{"rating": 1}, http.StatusNotFound},
+		}
+		for _, tc := range cases {
+			resp, body := doV1(t, srv, http.MethodPatch, path, tc.secret, tc.patch)
+			if resp.StatusCode != tc.status {
+				t.Errorf("%s: expected %d, got %d (%s)", tc.name, tc.status, resp.StatusCode, body.Error)
+			}
+		}
+
+		resp, body := doV1(t, srv, http.MethodPatch, path, adminSecret, map[string]interface{}{"priority": priorityHigh})
+		var patched Complaint
+		body.decode(t, &patched)
+		if resp.StatusCode != http.StatusOK || patched.Priority != priorityHigh {
+			t.Errorf("Expected an admin to change the priority, got %d %q", resp.StatusCode, patched.Priority)
+		}
+		complaint = patched
+	})
+
+	t.Run("Concurrent Patches", func(t *testing.T) {
+		const writers = 8
+
+		// Every patch based on the same version: exactly one wins
+		var wg sync.WaitGroup
+		statuses := make([]int, writers)
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				resp, _ := doV1(t, srv, http.MethodPatch, path, code, map[string]interface{}{
+					"room": fmt.Sprintf("Room %d", i), "version": complaint.Version,
+				})
+				statuses[i] = resp.StatusCode
+			}(i)
+		}
+		wg.Wait()
+		won := 0
+		for _, status := range statuses {
+			switch status {
+			case http.StatusOK:
+				won++
+			case http.StatusConflict:
+			default:
+				t.Errorf("Expected 200 or 409, got %d", status)
+			}
+		}
+		if won != 1 {
+			t.Errorf("Expected exactly one patch to win, got %d", won)
+		}
+
+		// Without a version every patch applies, each bumping the version once
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				doV1(t, srv, http.MethodPatch, path, code, map[string]interface{}{"room": fmt.Sprintf("Suite %d", i)})
+			}(i)
+		}
+		wg.Wait()
+
+		storage.mutex.RLock()
+		version := storage.complaints[complaint.ID].Version
+		storage.mutex.RUnlock()
+		if want := complaint.Version + 1 + writers; version != want {
+			t.Errorf("Expected version %d, got %d", want, version)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PinComplaintRequest is the body of /pinComplaint and /unpinComplaint
+type PinComplaintRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+}
+
+// pinnedCount is how many complaints of organization orgID are pinned, those
+// in the trash left out. Callers must hold storage.mutex.
+func pinnedCount(orgID int) int {
+	count := 0
+	for _, complaint := range storage.complaints {
+		if complaint.Pinned && complaint.OrgID == orgID && !complaint.IsDeleted {
+			count++
+		}
+	}
+	return count
+}
+
+// pinnedAtUnix is when complaint was pinned, in Unix seconds, or 0 when it
+// is not pinned; listings put higher values first
+func pinnedAtUnix(complaint *Complaint) int64 {
+	if !complaint.Pinned {
+		return 0
+	}
+	at, err := parseTimestamp(complaint.PinnedAt)
+	if err != nil {
+		return 1
+	}
+	return at.Unix()
+}
+
+// unpinComplaint takes complaint off the top of the admin listing, if it was
+// pinned, recording entry as historyUnpinned. Callers must hold
+// storage.mutex for writing and touch the complaint.
+func unpinComplaint(complaint *Complaint, entry HistoryEntry) {
+	if !complaint.Pinned {
+		return
+	}
+	complaint.Pinned = false
+	complaint.PinnedAt = ""
+	complaint.PinnedBy = 0
+	entry.Action = historyUnpinned
+	addHistory(complaint, entry)
+}
+
+// decodePinRequest reads and checks a pin or unpin request, writing the
+// error response when it fails
+func decodePinRequest(w http.ResponseWriter, r *http.Request) (PinComplaintRequest, *User) {
+	var req PinComplaintRequest
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return req, nil
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return req, nil
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return req, nil
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return req, nil
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return req, nil
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return req, nil
+	}
+	return req, user
+}
+
+// /pinComplaint - Keep an open complaint at the top of the admin listing,
+// e.g. during an incident (admin only)
+func pinComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	req, user := decodePinRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+	if complaint.Pinned {
+		respondWithError(w, http.StatusConflict, ErrCodeAlreadyPinned, "Complaint is already pinned")
+		return
+	}
+	if complaint.IsResolved {
+		respondWithError(w, http.StatusBadRequest, ErrCodeAlreadyResolved, "Resolved complaints cannot be pinned")
+		return
+	}
+	if complaint.MergedInto != 0 {
+		respondWithError(w, http.StatusConflict, ErrCodeAlreadyMerged, fmt.Sprintf("Complaint was merged into complaint %d; pin that one instead", complaint.MergedInto))
+		return
+	}
+	if config.MaxPinned > 0 && pinnedCount(complaint.OrgID) >= config.MaxPinned {
+		respondWithError(w, http.StatusConflict, ErrCodePinLimitReached, fmt.Sprintf("At most %d complaints can be pinned; unpin one first", config.MaxPinned))
+		return
+	}
+
+	complaint.Pinned = true
+	complaint.PinnedAt = getCurrentTime()
+	complaint.PinnedBy = user.ID
+	addHistory(complaint, HistoryEntry{Action: historyPinned, ActorID: user.ID, CorrelationID: correlationID(r)})
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint pinned successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
+
+// /unpinComplaint - Let a pinned complaint drop back into its place in the
+// admin listing (admin only)
+func unpinComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	req, user := decodePinRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+	if !complaint.Pinned {
+		respondWithError(w, http.StatusConflict, ErrCodeNotPinned, "Complaint is not pinned")
+		return
+	}
+
+	unpinComplaint(complaint, HistoryEntry{ActorID: user.ID, CorrelationID: correlationID(r)})
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint unpinned successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPinComplaints(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC))
+	_, code := registerTestUser(t, srv, "Incident User", "incident@example.com")
+	adminID, adminCode := registerTestAdmin(t, srv, "Incident Admin", "commander@example.com")
+
+	// Ratings 1 to 6, so rating_desc reverses ID order
+	var ids []int
+	for i := 1; i <= 6; i++ {
+		ids = append(ids, submitTestComplaint(t, srv, code, "Complaint "+strconv.Itoa(i), i).ID)
+	}
+	pin := func(id int) (int, testResponse) {
+		t.Helper()
+		fake.Advance(time.Minute)
+		return postJSON(t, srv, "/pinComplaint", PinComplaintRequest{SecretCode: adminCode, ComplaintID: id})
+	}
+	order := func(req GetComplaintsRequest) []int {
+		t.Helper()
+		req.SecretCode = adminSecret
+		status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", req)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		var got []int
+		for _, complaint := range complaints {
+			got = append(got, complaint.ID)
+		}
+		return got
+	}
+
+	status, resp := pin(ids[1])
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+	}
+	var pinned Complaint
+	resp.decode(t, &pinned)
+	if !pinned.Pinned || pinned.PinnedAt == "" || pinned.PinnedBy != adminID {
+		t.Errorf("Expected the pin recorded, got %+v", pinned)
+	}
+	pin(ids[4])
+
+	t.Run("Ordering", func(t *testing.T) {
+		// Most recently pinned first, then the requested sort
+		cases := []struct {
+			sort string
+			want []int
+		}{
+			{"", []int{ids[4], ids[1], ids[0], ids[2], ids[3], ids[5]}},
+			{sortNewest, []int{ids[4], ids[1], ids[5], ids[3], ids[2], ids[0]}},
+			{sortRatingDesc, []int{ids[4], ids[1], ids[5], ids[3], ids[2], ids[0]}},
+			{sortRatingAsc, []int{ids[4], ids[1], ids[0], ids[2], ids[3], ids[5]}},
+		}
+		for _, tc := range cases {
+			if got := order(GetComplaintsRequest{Sort: tc.sort}); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("Sort %q: expected %v, got %v", tc.sort, tc.want, got)
+			}
+		}
+
+		// Filters still apply to pinned complaints
+		if got := order(GetComplaintsRequest{Status: statusResolved}); len(got) != 0 {
+			t.Errorf("Expected no resolved complaints, got %v", got)
+		}
+
+		// Cursor pages walk the same order
+		size := 4
+		var walked []int
+		cursor := ""
+		for {
+			req := GetComplaintsRequest{SecretCode: adminSecret, Sort: sortNewest, PageSize: &size, Cursor: &cursor}
+			_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", req)
+			var page CursorPage
+			resp.decode(t, &page)
+			for _, complaint := range page.Complaints {
+				walked = append(walked, complaint.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if fmt.Sprint(walked) != fmt.Sprint(cases[1].want) {
+			t.Errorf("Expected cursor pages in pinned order %v, got %v", cases[1].want, walked)
+		}
+
+		// Users' own listings keep the requested order
+		_, resp := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: code})
+		var own []Complaint
+		resp.decode(t, &own)
+		if own[0].ID != ids[0] || !own[1].Pinned || own[1].PinnedBy != 0 {
+			t.Errorf("Expected the user's listing unchanged, with the flag but not who pinned, got %+v", own[:2])
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if status, resp := pin(ids[1]); status != http.StatusConflict || resp.ErrorCode != ErrCodeAlreadyPinned {
+			t.Errorf("Expected 409 ALREADY_PINNED, got %d %s", status, resp.ErrorCode)
+		}
+		if status, resp := postJSON(t, srv, "/unpinComplaint", PinComplaintRequest{SecretCode: adminCode, ComplaintID: ids[0]}); status != http.StatusConflict || resp.ErrorCode != ErrCodeNotPinned {
+			t.Errorf("Expected 409 NOT_PINNED, got %d %s", status, resp.ErrorCode)
+		}
+		if status, _ := postJSON(t, srv, "/pinComplaint", PinComplaintRequest{SecretCode: code, ComplaintID: ids[0]}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a user, got %d", status)
+		}
+		if status, _ := pin(9999); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for a missing complaint, got %d", status)
+		}
+	})
+
+	t.Run("Cap", func(t *testing.T) {
+		config.MaxPinned = 3
+		if status, resp := pin(ids[2]); status != http.StatusOK {
+			t.Fatalf("Expected 200 for the third pin, got %d (%s)", status, resp.Error)
+		}
+		if status, resp := pin(ids[3]); status != http.StatusConflict || resp.ErrorCode != ErrCodePinLimitReached {
+			t.Errorf("Expected 409 PIN_LIMIT_REACHED, got %d %s", status, resp.ErrorCode)
+		}
+		if status, _ := postJSON(t, srv, "/unpinComplaint", PinComplaintRequest{SecretCode: adminCode, ComplaintID: ids[2]}); status != http.StatusOK {
+			t.Errorf("Expected 200 unpinning, got %d", status)
+		}
+		if status, _ := pin(ids[3]); status != http.StatusOK {
+			t.Errorf("Expected room for a pin after unpinning, got %d", status)
+		}
+	})
+
+	t.Run("Resolve Unpins", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminCode, ComplaintID: ids[4]})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var resolved Complaint
+		resp.decode(t, &resolved)
+		if resolved.Pinned || resolved.PinnedAt != "" || resolved.PinnedBy != 0 {
+			t.Errorf("Expected the complaint unpinned, got %+v", resolved)
+		}
+		// Before the resolution itself
+		if entry := resolved.History[len(resolved.History)-2]; entry.Action != historyUnpinned || entry.Detail != "Resolved" {
+			t.Errorf("Expected the unpin in history, got %+v", entry)
+		}
+		if got := order(GetComplaintsRequest{}); got[0] != ids[3] || got[1] != ids[1] {
+			t.Errorf("Expected the remaining pins first, got %v", got)
+		}
+		if status, resp := pin(ids[4]); status != http.StatusBadRequest || resp.ErrorCode != ErrCodeAlreadyResolved {
+			t.Errorf("Expected 400 ALREADY_RESOLVED pinning a resolved complaint, got %d %s", status, resp.ErrorCode)
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package main
+
+import "net/http"
+
+// Authorization policies. A policy decides whether an actor may do
+// something from the actor and what they are acting on alone, without
+// reading storage, config or the clock, so the whole permission model is
+// tested as one table in policy_test.go. Handlers ask a policy and pass the
+// decision to authorize; none of them compares roles or owners itself.
+// Tenants are still separated by canSeeOrg and scopedComplaint, which look
+// records up, and the policies repeat the check for the records they get.
+
+// effect is what a decision lets the caller do
+type effect int
+
+const (
+	allow effect = iota
+	// denyNotFound answers as if the resource did not exist, so probing
+	// reveals nothing
+	denyNotFound
+	denyForbidden
+)
+
+// decision is a policy's answer, with the error to respond with when it
+// denies
+type decision struct {
+	effect effect
+	code   ErrorCode
+	reason string
+}
+
+func permit() decision {
+	return decision{effect: allow}
+}
+
+func hide(reason string) decision {
+	return decision{effect: denyNotFound, code: ErrCodeNotFound, reason: reason}
+}
+
+func refuse(reason string) decision {
+	return decision{effect: denyForbidden, code: ErrCodeForbidden, reason: reason}
+}
+
+func (d decision) allowed() bool {
+	return d.effect == allow
+}
+
+// because replaces the reason of a denial, for handlers that explain it in
+// their own words
+func (d decision) because(reason string) decision {
+	if d.effect == denyForbidden && d.code == ErrCodeForbidden {
+		d.reason = reason
+	}
+	return d
+}
+
+// apiError is the error d is answered with; nil when it allows
+func (d decision) apiError() *APIError {
+	switch d.effect {
+	case denyNotFound:
+		return newAPIError(http.StatusNotFound, d.code, d.reason)
+	case denyForbidden:
+		return newAPIError(http.StatusForbidden, d.code, d.reason)
+	}
+	return nil
+}
+
+// authorize responds to a denial and reports whether d allows the request
+func authorize(w http.ResponseWriter, d decision) bool {
+	if apiErr := d.apiError(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return false
+	}
+	return true
+}
+
+// actor is the caller as the policies see them
+type actor struct {
+	id         int
+	orgID      int
+	admin      bool // of orgID
+	superAdmin bool // of every organization
+	active     bool
+}
+
+// actorOf copies what the policies need of user. Callers must not hold
+// storage.usersMutex for writing.
+func actorOf(user *User) actor {
+	storage.usersMutex.RLock()
+	defer storage.usersMutex.RUnlock()
+	return actorOfLocked(user)
+}
+
+// actorOfLocked is actorOf for callers that already hold storage.mutex or
+// storage.usersMutex, as roles and deactivation change under both
+func actorOfLocked(user *User) actor {
+	return actor{id: user.ID, orgID: user.OrgID, admin: user.IsAdmin, superAdmin: user.IsSuperAdmin, active: user.IsActive}
+}
+
+// is reports whether a is user userID
+func (a actor) is(userID int) bool {
+	return a.id == userID
+}
+
+// seesOrg is canSeeOrg for an actor
+func (a actor) seesOrg(orgID int) bool {
+	return a.superAdmin || a.orgID == orgID
+}
+
+// owns reports whether a submitted complaint
+func (a actor) owns(complaint *Complaint) bool {
+	return complaint.UserID == a.id
+}
+
+// Deactivated users are refused before any handler runs; the policies
+// refuse them too, so a check can never be reached without authentication.
+func deactivated() decision {
+	return decision{effect: denyForbidden, code: ErrCodeAccountDeactivated, reason: "This account has been deactivated. Contact an administrator"}
+}
+
+// adminOnly allows admins, of their own organization or of every one
+func adminOnly(a actor) decision {
+	switch {
+	case !a.active:
+		return deactivated()
+	case !a.admin:
+		return refuse("Access denied. Admin privileges required")
+	}
+	return permit()
+}
+
+// superAdminOnly allows super-admins, for what spans organizations
+func superAdminOnly(a actor) decision {
+	switch {
+	case !a.active:
+		return deactivated()
+	case !a.superAdmin:
+		return refuse("Access denied. Super-admin privileges required")
+	}
+	return permit()
+}
+
+// canViewComplaint lets admins see every complaint of their organization,
+// those in the trash included, and everyone else their own outside it.
+// Another organization's complaint, or one in the trash, looks missing; a
+// complaint of someone else is forbidden, which handlers answer as missing
+// too unless -reveal-forbidden-complaints is set.
+func canViewComplaint(a actor, complaint *Complaint) decision {
+	switch {
+	case !a.active:
+		return deactivated()
+	case !a.seesOrg(complaint.OrgID):
+		return hide("Complaint not found")
+	case complaint.IsDeleted && !a.admin:
+		return hide("Complaint not found")
+	case !a.admin && !a.owns(complaint):
+		return refuse("Access denied. You can only view your own complaints")
+	}
+	return permit()
+}
+
+// canSeeAdminFields lets admins see what complaints keep from their
+// owners: notes, history, the assignee and who the owner is
+func canSeeAdminFields(a actor) decision {
+	return adminOnly(a)
+}
+
+// canSkipSubmissionLimits exempts admins from the open complaint quota and
+// the submission rate limit
+func canSkipSubmissionLimits(a actor) decision {
+	return adminOnly(a)
+}
+
+// canTakeAssignment lets admins of organization orgID be assigned its
+// complaints. Super-admins of another organization are not in its rotation.
+func canTakeAssignment(a actor, orgID int) decision {
+	switch {
+	case !a.active:
+		return deactivated()
+	case !a.admin || a.orgID != orgID:
+		return refuse("Only admins of the organization can be assigned its complaints")
+	}
+	return permit()
+}
+
+// canResolve allows admins to resolve and reopen complaints
+func canResolve(a actor) decision {
+	return adminOnly(a)
+}
+
+// canComment lets the submitter comment on their complaint and admins on
+// any of their organization
+func canComment(a actor, complaint *Complaint) decision {
+	return canViewComplaint(a, complaint).because("Access denied. You can only comment on your own complaints")
+}
+
+// canEditComment lets authors change their own comments on complaint. The
+// edit window is a matter of time, not permission, and is checked by the
+// handler.
+func canEditComment(a actor, complaint *Complaint, comment *Comment) decision {
+	if d := canViewComplaint(a, complaint); !d.allowed() {
+		return d
+	}
+	if comment.UserID != a.id {
+		return refuse("Access denied. You can only edit your own comments")
+	}
+	return permit()
+}
+
+// canDeleteComment lets authors delete their own comments on complaint and
+// admins, as moderators, any comment on it
+func canDeleteComment(a actor, complaint *Complaint, comment *Comment) decision {
+	if d := canViewComplaint(a, complaint); !d.allowed() {
+		return d
+	}
+	if comment.UserID != a.id && !a.admin {
+		return refuse("Access denied. You can only delete your own comments")
+	}
+	return permit()
+}
+
+// canAnswerForComplaint lets only the submitter act as its owner, giving
+// feedback or answering a resolution. Admins may see the complaint but it
+// is not theirs to answer for.
+func canAnswerForComplaint(a actor, complaint *Complaint) decision {
+	if d := canViewComplaint(a, complaint); !d.allowed() {
+		return d
+	}
+	if !a.owns(complaint) {
+		return refuse("Access denied. Only the submitter can do this")
+	}
+	return permit()
+}
+
+// canActOnUser lets users act on their own account, and admins on those of
+// others, which scopedUser then limits to their organization. userID 0
+// means the actor.
+func canActOnUser(a actor, userID int) decision {
+	switch {
+	case !a.active:
+		return deactivated()
+	case userID != 0 && userID != a.id && !a.admin:
+		return refuse("Access denied. Admin privileges required")
+	}
+	return permit()
+}
+
+// canUseFilter lets users use and delete only the filters they saved
+func canUseFilter(a actor, saved *SavedFilter) decision {
+	switch {
+	case !a.active:
+		return deactivated()
+	case saved.OwnerID != a.id:
+		return refuse("Access denied. You can only use your own filters")
+	}
+	return permit()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Complaint priorities, from least to most urgent
+const (
+	priorityLow      = "low"
+	priorityMedium   = "medium"
+	priorityHigh     = "high"
+	priorityCritical = "critical"
+)
+
+// priorities lists every priority, most urgent first
+var priorities = []string{priorityCritical, priorityHigh, priorityMedium, priorityLow}
+
+// priorityRank orders priorities for sorting; higher is more urgent.
+// Complaints stored before priorities existed rank as medium.
+func priorityRank(priority string) int {
+	switch priority {
+	case priorityCritical:
+		return 3
+	case priorityHigh:
+		return 2
+	case priorityLow:
+		return 0
+	}
+	return 1
+}
+
+// validatePriority checks priority against the enum
+func validatePriority(priority string) error {
+	for _, p := range priorities {
+		if priority == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("Priority must be one of: %s", strings.Join(priorities, ", "))
+}
+
+type SetPriorityRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+	Priority    string `json:"priority"`
+	Version     int    `json:"version"` // the version the admin last saw
+}
+
+// PriorityCount is the number of open complaints at one priority
+type PriorityCount struct {
+	Priority string `json:"priority"`
+	Open     int    `json:"open"`
+}
+
+// /setPriority - Change a complaint's priority (admin only)
+func setPriorityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req SetPriorityRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return
+	}
+	if err := validatePriority(req.Priority); err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	if req.Version <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Version is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+
+	if err := checkVersion(user, complaint, req.Version); err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	complaint.Priority = req.Priority
+	// The deadline follows the new priority
+	resetDueAt(complaint)
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Priority updated successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestComplaintPriority(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Urgent User", "urgent@example.com")
+
+	submit := func(title, priority string) (int, testResponse) {
+		return postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: code, Title: title, Summary: "Summary of " + title, Rating: 5, Priority: priority,
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		status, resp := submit("Bad priority", "urgent")
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("Expected 400 VALIDATION_FAILED, got %d %s", status, resp.ErrorCode)
+		}
+		status, resp = postJSON(t, srv, "/getAllComplaintsForUser", map[string]string{"secret_code": code, "priority": "Critical"})
+		if status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an invalid priority filter, got %d", status)
+		}
+	})
+
+	ids := map[string]int{}
+	for _, tc := range []struct{ title, priority, want string }{
+		{"Defaulted", "", priorityMedium},
+		{"Minor", priorityLow, priorityLow},
+		{"Fire", priorityCritical, priorityCritical},
+		{"Leak", priorityHigh, priorityHigh},
+	} {
+		status, resp := submit(tc.title, tc.priority)
+		if status != http.StatusCreated {
+			t.Fatalf("Submit %q: expected status 201, got %d (%s)", tc.title, status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		if complaint.Priority != tc.want {
+			t.Errorf("Submit %q: expected priority %q, got %q", tc.title, tc.want, complaint.Priority)
+		}
+		ids[tc.title] = complaint.ID
+	}
+
+	listIDs := func(payload map[string]interface{}) []int {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", payload)
+		var list []Complaint
+		resp.decode(t, &list)
+		var got []int
+		for _, c := range list {
+			got = append(got, c.ID)
+		}
+		return got
+	}
+
+	t.Run("Sort", func(t *testing.T) {
+		got := listIDs(map[string]interface{}{"secret_code": adminSecret, "sort": "priority"})
+		want := []int{ids["Fire"], ids["Leak"], ids["Defaulted"], ids["Minor"]}
+		if len(got) != len(want) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Expected %v, got %v", want, got)
+			}
+		}
+	})
+
+	t.Run("Admin Override", func(t *testing.T) {
+		status, _ := postJSON(t, srv, "/setPriority", SetPriorityRequest{SecretCode: code, ComplaintID: ids["Minor"], Priority: priorityCritical, Version: 1})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 for the owner, got %d", status)
+		}
+
+		status, resp := postJSON(t, srv, "/setPriority", SetPriorityRequest{SecretCode: adminSecret, ComplaintID: ids["Minor"], Priority: "extreme"})
+		if status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an invalid priority, got %d", status)
+		}
+
+		status, resp = postJSON(t, srv, "/setPriority", SetPriorityRequest{SecretCode: adminSecret, ComplaintID: ids["Minor"], Priority: priorityCritical, Version: 1})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+		}
+
+		got := listIDs(map[string]interface{}{"secret_code": adminSecret, "priority": priorityCritical})
+		if len(got) != 2 || got[0] != ids["Minor"] || got[1] != ids["Fire"] {
+			t.Errorf("Expected both critical complaints, got %v", got)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: ids["Fire"]})
+
+		_, resp := postJSON(t, srv, "/report", ReportRequest{SecretCode: adminSecret})
+		var report Report
+		resp.decode(t, &report)
+		want := []PriorityCount{{priorityCritical, 1}, {priorityHigh, 1}, {priorityMedium, 1}, {priorityLow, 0}}
+		if len(report.OpenByPriority) != len(want) {
+			t.Fatalf("Expected %v, got %v", want, report.OpenByPriority)
+		}
+		for i := range want {
+			if report.OpenByPriority[i] != want[i] {
+				t.Errorf("Expected %v, got %v", want, report.OpenByPriority)
+				break
+			}
+		}
+	})
+}
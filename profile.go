@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+type MeRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+// MeResponse is the caller's own profile plus account-level counters
+type MeResponse struct {
+	User
+	UnreadNotifications int `json:"unread_notifications" xml:"unread_notifications"`
+	ActiveAnnouncements int `json:"active_announcements" xml:"active_announcements"`
+}
+
+// profileFor builds user's own profile
+func profileFor(user *User) MeResponse {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return MeResponse{
+		User:                userForViewer(user, snapshotUser(user)),
+		UnreadNotifications: unreadNotificationCount(user.ID),
+		ActiveAnnouncements: len(activeAnnouncements(user.OrgID)),
+	}
+}
+
+// /me - The authenticated user's profile
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req MeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Profile retrieved successfully",
+		Data:    profileFor(user),
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// apiVersion is the version of the versioned API, served under /v1/
+const apiVersion = "v1"
+
+// Registration modes of PublicConfig
+const (
+	registrationOpen   = "open"
+	registrationInvite = "invite" // an invite code is required
+)
+
+// PublicConfig is the configuration clients need to build their forms. It
+// is built from the live configuration and registries on every request, so
+// it cannot drift from what the server enforces.
+type PublicConfig struct {
+	APIVersion string `json:"api_version" xml:"api_version"`
+	Version    string `json:"version" xml:"version"` // the server build
+	// Timezone and UTCOffset are the zone timestamps such as created_at are
+	// written in, as TimestampFormat
+	Timezone        string             `json:"timezone" xml:"timezone"`
+	UTCOffset       string             `json:"utc_offset" xml:"utc_offset"`
+	TimestampFormat string             `json:"timestamp_format" xml:"timestamp_format"`
+	RatingScale     RatingScale        `json:"rating_scale" xml:"rating_scale"`
+	Registration    string             `json:"registration" xml:"registration"`                   // open or invite
+	TOSVersion      string             `json:"tos_version,omitempty" xml:"tos_version,omitempty"` // terms of service to accept on /register and /acceptTos
+	Priorities      []string           `json:"priorities" xml:"priorities>priority"`              // most urgent first
+	Departments     []PublicDepartment `json:"departments" xml:"departments>department"`          // in routing order, General last
+	MaxLengths      FieldLimits        `json:"max_lengths" xml:"max_lengths"`
+	MaxTags         int                `json:"max_tags" xml:"max_tags"`
+}
+
+// PublicDepartment is a department complaints can be routed to, with the
+// custom fields a submission routed there must fill in
+type PublicDepartment struct {
+	Name   string        `json:"name" xml:"name"`
+	Fields []CustomField `json:"fields,omitempty" xml:"fields>field,omitempty"`
+}
+
+// FieldLimits are the longest values accepted, in characters
+type FieldLimits struct {
+	Name    int `json:"name" xml:"name"`
+	Email   int `json:"email" xml:"email"`
+	Title   int `json:"title" xml:"title"`
+	Summary int `json:"summary" xml:"summary"`
+	Tag     int `json:"tag" xml:"tag"`
+}
+
+// currentPublicConfig builds the public configuration
+func currentPublicConfig() PublicConfig {
+	zone, offset := clock.Now().Zone()
+	registration := registrationOpen
+	if config.RequireInvites {
+		registration = registrationInvite
+	}
+
+	storage.mutex.RLock()
+	departments := []PublicDepartment{}
+	for _, department := range orderedDepartments() {
+		departments = append(departments, PublicDepartment{Name: department.Name, Fields: department.Fields})
+	}
+	storage.mutex.RUnlock()
+	departments = append(departments, PublicDepartment{Name: generalDepartment})
+
+	return PublicConfig{
+		APIVersion:      apiVersion,
+		Version:         version,
+		Timezone:        zone,
+		UTCOffset:       formatUTCOffset(offset),
+		TimestampFormat: "YYYY-MM-DD HH:MM:SS",
+		RatingScale:     currentRatingScale(),
+		Registration:    registration,
+		TOSVersion:      config.TOSVersion,
+		Priorities:      priorities,
+		Departments:     departments,
+		MaxLengths: FieldLimits{
+			Name:    maxNameLength,
+			Email:   maxEmailLength,
+			Title:   maxTitleLength,
+			Summary: maxSummaryLength,
+			Tag:     maxTagLength,
+		},
+		MaxTags: maxTags,
+	}
+}
+
+// formatUTCOffset writes offset, in seconds east of UTC, as +hh:mm
+func formatUTCOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+// publicConfigETag is a validator for cfg that changes whenever any part
+// of it does, a department added or renamed included
+func publicConfigETag(cfg PublicConfig) string {
+	body, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// /config - Settings clients adapt to, such as the rating scale and the
+// departments; no authentication needed
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	cfg := currentPublicConfig()
+	// Clients may cache it, but must revalidate as it changes at runtime
+	w.Header().Set("Cache-Control", "no-cache")
+	if notModified(w, r, publicConfigETag(cfg)) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Configuration retrieved successfully",
+		Data:    cfg,
+	})
+}
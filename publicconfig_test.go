@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestPublicConfig(t *testing.T) {
+	srv := newTestServer(t)
+
+	fetch := func(etag string) (*http.Response, PublicConfig) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/config", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Data PublicConfig `json:"data"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			json.NewDecoder(resp.Body).Decode(&body)
+		}
+		return resp, body.Data
+	}
+
+	resp, cfg := fetch("")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	if cfg.APIVersion != apiVersion || cfg.Registration != registrationOpen || cfg.RatingScale != (RatingScale{Min: 1, Max: 10}) {
+		t.Errorf("Unexpected configuration %+v", cfg)
+	}
+	if fmt.Sprint(cfg.Priorities) != fmt.Sprint(priorities) || cfg.MaxLengths.Title != maxTitleLength || cfg.MaxTags != maxTags {
+		t.Errorf("Expected the priorities and limits the server enforces, got %+v", cfg)
+	}
+	if len(cfg.Departments) != 1 || cfg.Departments[0].Name != generalDepartment {
+		t.Errorf("Expected only %s, got %+v", generalDepartment, cfg.Departments)
+	}
+	if cfg.Timezone == "" || len(cfg.UTCOffset) != len("+00:00") {
+		t.Errorf("Expected the server's zone, got %q %q", cfg.Timezone, cfg.UTCOffset)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatalf("Expected an ETag to revalidate with, got %q and Cache-Control %q", etag, resp.Header.Get("Cache-Control"))
+	}
+
+	t.Run("Not Modified", func(t *testing.T) {
+		if resp, _ := fetch(etag); resp.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected 304 for the current ETag, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Departments Change ETag", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/addDepartment", AddDepartmentRequest{
+			SecretCode: adminSecret, Name: "IT", Keywords: []string{"laptop"},
+			Fields: []CustomField{{Name: "Asset tag", Type: customFieldString, Required: true}},
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		fresh, cfg := fetch(etag)
+		if fresh.StatusCode != http.StatusOK || fresh.Header.Get("ETag") == etag {
+			t.Fatalf("Expected a new ETag after adding a department, got %d %q", fresh.StatusCode, fresh.Header.Get("ETag"))
+		}
+		if len(cfg.Departments) != 2 || cfg.Departments[0].Name != "IT" || len(cfg.Departments[0].Fields) != 1 || cfg.Departments[1].Name != generalDepartment {
+			t.Errorf("Expected IT with its field, then %s, got %+v", generalDepartment, cfg.Departments)
+		}
+		etag = fresh.Header.Get("ETag")
+	})
+
+	t.Run("Config Changes", func(t *testing.T) {
+		config.RatingMin, config.RatingMax = 1, 5
+		config.RequireInvites = true
+		config.TOSVersion = "2024-09"
+		resp, cfg := fetch(etag)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 after the configuration changed, got %d", resp.StatusCode)
+		}
+		if cfg.RatingScale != (RatingScale{Min: 1, Max: 5}) || cfg.Registration != registrationInvite || cfg.TOSVersion != "2024-09" {
+			t.Errorf("Expected the new configuration, got %+v", cfg)
+		}
+	})
+
+	t.Run("Method", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/config", nil); status != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405, got %d", status)
+		}
+	})
+}
+
+func TestFormatUTCOffset(t *testing.T) {
+	for offset, want := range map[int]string{0: "+00:00", 3600: "+01:00", -5*3600 - 1800: "-05:30", 5*3600 + 2700: "+05:45"} {
+		if got := formatUTCOffset(offset); got != want {
+			t.Errorf("formatUTCOffset(%d) = %s, want %s", offset, got, want)
+		}
+	}
+}
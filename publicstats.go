@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	publicStatsTTL      = time.Minute // how long computed figures are served
+	publicStatsMonths   = 12
+	publicStatsMinCount = 5 // smaller counts are shown as "<5"
+	publicStatsRounding = 5 // larger counts are rounded to the nearest multiple
+)
+
+// bucketedCount is a count safe to publish: under publicStatsMinCount it is
+// "<5", so a count of one or two cannot point at a person; otherwise it is
+// rounded to the nearest publicStatsRounding
+type bucketedCount int
+
+func (c bucketedCount) MarshalJSON() ([]byte, error) {
+	if c < publicStatsMinCount {
+		return json.Marshal("<" + strconv.Itoa(publicStatsMinCount))
+	}
+	rounded := (int(c) + publicStatsRounding/2) / publicStatsRounding * publicStatsRounding
+	return json.Marshal(rounded)
+}
+
+// MonthCount is how many complaints were submitted in a month
+type MonthCount struct {
+	Month string        `json:"month"` // YYYY-MM, UTC
+	Count bucketedCount `json:"count"`
+}
+
+// PublicStats are the /publicStats figures, across every organization. The
+// rate and median are left out while they rest on fewer than
+// publicStatsMinCount complaints.
+type PublicStats struct {
+	TotalComplaints       bucketedCount `json:"total_complaints"`
+	ResolutionRate        *float64      `json:"resolution_rate"`         // resolved over total
+	MedianResolutionHours *float64      `json:"median_resolution_hours"` // of the resolved complaints
+	ComplaintsPerMonth    []MonthCount  `json:"complaints_per_month"`    // oldest first, this month included
+	GeneratedAt           string        `json:"generated_at"`            // RFC 3339
+}
+
+// publicStatsCache keeps the figures for publicStatsTTL, so an endpoint
+// anyone may call costs at most one pass over storage a minute
+type publicStatsCache struct {
+	stats      PublicStats
+	computedAt time.Time
+	mutex      sync.Mutex
+}
+
+var publicStats = &publicStatsCache{}
+
+// get returns the cached figures, computing them first when they are
+// missing or older than publicStatsTTL
+func (c *publicStatsCache) get(now time.Time) (PublicStats, time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.computedAt.IsZero() || now.Sub(c.computedAt) >= publicStatsTTL {
+		storage.mutex.RLock()
+		c.stats = buildPublicStats(now)
+		storage.mutex.RUnlock()
+		c.computedAt = now
+	}
+	return c.stats, c.computedAt
+}
+
+// buildPublicStats computes the figures at now. Callers must hold
+// storage.mutex.
+func buildPublicStats(now time.Time) PublicStats {
+	thisMonth := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	first := thisMonth.AddDate(0, 1-publicStatsMonths, 0)
+	months := make([]MonthCount, publicStatsMonths)
+	for i := range months {
+		months[i].Month = first.AddDate(0, i, 0).Format("2006-01")
+	}
+
+	total, resolved := 0, 0
+	var durations []time.Duration
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted {
+			continue
+		}
+		total++
+		created, err := parseTimestamp(complaint.CreatedAt)
+		if err == nil && !created.Before(first) {
+			month := created.UTC()
+			if index := (month.Year()-first.Year())*12 + int(month.Month()-first.Month()); index < publicStatsMonths {
+				months[index].Count++
+			}
+		}
+		if !complaint.IsResolved {
+			continue
+		}
+		resolved++
+		if resolvedAt, resolvedErr := parseTimestamp(complaint.ResolvedAt); err == nil && resolvedErr == nil {
+			durations = append(durations, resolvedAt.Sub(created))
+		}
+	}
+
+	stats := PublicStats{
+		TotalComplaints:    bucketedCount(total),
+		ComplaintsPerMonth: months,
+		GeneratedAt:        now.UTC().Format(time.RFC3339),
+	}
+	if total >= publicStatsMinCount {
+		rate := roundTo(float64(resolved)/float64(total), 2)
+		stats.ResolutionRate = &rate
+	}
+	if len(durations) >= publicStatsMinCount {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		median := durations[len(durations)/2]
+		if len(durations)%2 == 0 {
+			median = (durations[len(durations)/2-1] + median) / 2
+		}
+		hours := roundTo(median.Hours(), 1)
+		stats.MedianResolutionHours = &hours
+	}
+	return stats
+}
+
+// /publicStats - Anonymous figures about complaints, for publishing. No
+// authentication; nothing about any one complaint or user is included.
+func publicStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	now := clock.Now()
+	stats, computedAt := publicStats.get(now)
+
+	// Shared caches may keep the response until the figures are recomputed
+	maxAge := int((publicStatsTTL - now.Sub(computedAt)) / time.Second)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Statistics retrieved successfully",
+		Data:    stats,
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// publicStatsFigures is /publicStats as published; counts decode as
+// numbers or as "<5"
+type publicStatsFigures struct {
+	TotalComplaints       interface{} `json:"total_complaints"`
+	ResolutionRate        *float64    `json:"resolution_rate"`
+	MedianResolutionHours *float64    `json:"median_resolution_hours"`
+	ComplaintsPerMonth    []struct {
+		Month string      `json:"month"`
+		Count interface{} `json:"count"`
+	} `json:"complaints_per_month"`
+}
+
+func getPublicStats(t *testing.T, srv *httptest.Server) (publicStatsFigures, string) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/publicStats")
+	if err != nil {
+		t.Fatalf("GET /publicStats failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	var body testResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	var figures publicStatsFigures
+	body.decode(t, &figures)
+	return figures, resp.Header.Get("Cache-Control")
+}
+
+func TestBucketedCount(t *testing.T) {
+	cases := map[int]string{0: "<5", 1: "<5", 4: "<5", 5: "5", 7: "5", 8: "10", 12: "10", 1234: "1235"}
+	for count, want := range cases {
+		data, err := json.Marshal(bucketedCount(count))
+		if err != nil {
+			t.Fatalf("Marshal(%d): %v", count, err)
+		}
+		var got interface{}
+		json.Unmarshal(data, &got)
+		if fmt.Sprint(got) != want {
+			t.Errorf("Count %d: expected %s, got %v", count, want, got)
+		}
+	}
+}
+
+func TestPublicStats(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC))
+
+	// Outside the last 12 months, but counted in the total
+	submitTestComplaint(t, srv, adminSecret, "Old complaint", 5)
+
+	t.Run("Small Numbers Hidden", func(t *testing.T) {
+		figures, _ := getPublicStats(t, srv)
+		if fmt.Sprint(figures.TotalComplaints) != "<5" {
+			t.Errorf("Expected a total of <5, got %v", figures.TotalComplaints)
+		}
+		if figures.ResolutionRate != nil || figures.MedianResolutionHours != nil {
+			t.Errorf("Expected no rate or median from one complaint, got %v %v", figures.ResolutionRate, figures.MedianResolutionHours)
+		}
+	})
+
+	fake.Advance(time.Date(2024, 2, 10, 12, 0, 0, 0, time.UTC).Sub(fake.Now()))
+	for i := 0; i < 3; i++ {
+		submitTestComplaint(t, srv, adminSecret, "February complaint", 5)
+	}
+	fake.Advance(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC).Sub(fake.Now()))
+	var june []Complaint
+	for i := 0; i < 6; i++ {
+		june = append(june, submitTestComplaint(t, srv, adminSecret, "June complaint", 5))
+	}
+	// Three resolved after 2 hours, two after 6
+	fake.Advance(2 * time.Hour)
+	for i, complaint := range june[:5] {
+		if i == 3 {
+			fake.Advance(4 * time.Hour)
+		}
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+	}
+
+	t.Run("Figures", func(t *testing.T) {
+		figures, cacheControl := getPublicStats(t, srv)
+		if fmt.Sprint(figures.TotalComplaints) != "10" {
+			t.Errorf("Expected a total of 10, got %v", figures.TotalComplaints)
+		}
+		if figures.ResolutionRate == nil || *figures.ResolutionRate != 0.5 {
+			t.Errorf("Expected a resolution rate of 0.5, got %v", figures.ResolutionRate)
+		}
+		if figures.MedianResolutionHours == nil || *figures.MedianResolutionHours != 2 {
+			t.Errorf("Expected a median of 2 hours, got %v", figures.MedianResolutionHours)
+		}
+
+		months := figures.ComplaintsPerMonth
+		if len(months) != 12 || months[0].Month != "2023-07" || months[11].Month != "2024-06" {
+			t.Fatalf("Expected 2023-07 to 2024-06, got %+v", months)
+		}
+		for _, month := range months {
+			want := "<5"
+			if month.Month == "2024-06" {
+				want = "5"
+			}
+			if fmt.Sprint(month.Count) != want {
+				t.Errorf("Month %s: expected %s, got %v", month.Month, want, month.Count)
+			}
+		}
+		if cacheControl != "public, max-age=60" {
+			t.Errorf("Unexpected Cache-Control %q", cacheControl)
+		}
+	})
+
+	t.Run("Cached For A Minute", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			submitTestComplaint(t, srv, adminSecret, "Late complaint", 5)
+		}
+
+		fake.Advance(45 * time.Second)
+		figures, cacheControl := getPublicStats(t, srv)
+		if fmt.Sprint(figures.TotalComplaints) != "10" {
+			t.Errorf("Expected the cached total of 10, got %v", figures.TotalComplaints)
+		}
+		if cacheControl != "public, max-age=15" {
+			t.Errorf("Expected the time left in the cache, got %q", cacheControl)
+		}
+
+		fake.Advance(15 * time.Second)
+		figures, cacheControl = getPublicStats(t, srv)
+		if fmt.Sprint(figures.TotalComplaints) != "15" {
+			t.Errorf("Expected a recomputed total of 15, got %v", figures.TotalComplaints)
+		}
+		if cacheControl != "public, max-age=60" {
+			t.Errorf("Unexpected Cache-Control %q", cacheControl)
+		}
+	})
+
+	t.Run("Method", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/publicStats", struct{}{}); status != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// openComplaintCount counts user's open, undeleted complaints; merged ones
+// do not count. Callers must hold storage.mutex.
+func openComplaintCount(userID int) int {
+	count := 0
+	for _, complaint := range storage.complaints {
+		if complaint.UserID == userID && isOpen(complaint) && !complaint.IsDeleted {
+			count++
+		}
+	}
+	return count
+}
+
+// recentSubmissions drops userID's submission times that have left the rate
+// window and returns the rest, oldest first. Callers must hold
+// storage.mutex for writing.
+func recentSubmissions(userID int, now time.Time) []time.Time {
+	windowStart := now.Add(-config.SubmitRateWindow)
+	kept := storage.submissions[userID][:0]
+	for _, at := range storage.submissions[userID] {
+		if at.After(windowStart) {
+			kept = append(kept, at)
+		}
+	}
+	if len(kept) == 0 {
+		delete(storage.submissions, userID)
+		return nil
+	}
+	storage.submissions[userID] = kept
+	return kept
+}
+
+// checkSubmissionLimits enforces the open complaint quota and the submission
+// rate limit for user. Admins are exempt. Callers must hold storage.mutex for
+// writing and keep it until the complaint is stored, so concurrent submits
+// cannot overshoot.
+func checkSubmissionLimits(user *User, now time.Time) *APIError {
+	if canSkipSubmissionLimits(actorOfLocked(user)).allowed() {
+		return nil
+	}
+
+	if config.MaxOpenComplaints > 0 && openComplaintCount(user.ID) >= config.MaxOpenComplaints {
+		return newAPIError(http.StatusConflict, ErrCodeQuotaExceeded, fmt.Sprintf("You already have %d open complaints. Wait for some to be resolved before submitting more", config.MaxOpenComplaints))
+	}
+
+	if config.SubmitRateLimit > 0 {
+		recent := recentSubmissions(user.ID, now)
+		if len(recent) >= config.SubmitRateLimit {
+			retryAfter := recent[0].Add(config.SubmitRateWindow).Sub(now)
+			err := newAPIError(http.StatusTooManyRequests, ErrCodeRateLimited, fmt.Sprintf("Too many complaints submitted. Try again in %s", retryAfter.Round(time.Second)))
+			err.RetryAfter = retryAfter
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordSubmission adds a submission to userID's rate window. Callers must
+// hold storage.mutex for writing.
+func recordSubmission(userID int, now time.Time) {
+	if config.SubmitRateLimit > 0 {
+		storage.submissions[userID] = append(storage.submissions[userID], now)
+	}
+}
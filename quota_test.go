@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenComplaintQuota(t *testing.T) {
+	srv := newTestServer(t)
+	config.MaxOpenComplaints = 3
+	config.SubmitRateLimit = 0
+	_, code := registerTestUser(t, srv, "Busy User", "busy@example.com")
+
+	var ids []int
+	for i := 0; i < 3; i++ {
+		ids = append(ids, submitTestComplaint(t, srv, code, fmt.Sprintf("Complaint %d", i), 5).ID)
+	}
+
+	submit := func(secret string) (int, testResponse) {
+		return postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: secret, Title: "One more", Summary: "Still unhappy", Rating: 5,
+		})
+	}
+
+	status, resp := submit(code)
+	if status != http.StatusConflict || resp.ErrorCode != ErrCodeQuotaExceeded {
+		t.Errorf("Expected 409 QUOTA_EXCEEDED over the quota, got %d %s", status, resp.ErrorCode)
+	}
+
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: ids[0]})
+	if status, resp := submit(code); status != http.StatusCreated {
+		t.Errorf("Expected submit to succeed after a resolve, got %d (%s)", status, resp.Error)
+	}
+
+	postJSON(t, srv, "/deleteComplaint", DeleteComplaintRequest{SecretCode: code, ComplaintID: ids[1]})
+	if status, resp := submit(code); status != http.StatusCreated {
+		t.Errorf("Expected deleted complaints not to count, got %d (%s)", status, resp.Error)
+	}
+
+	for i := 0; i < 5; i++ {
+		if status, resp := submit(adminSecret); status != http.StatusCreated {
+			t.Fatalf("Expected admin to be exempt, got %d (%s)", status, resp.Error)
+		}
+	}
+}
+
+func TestSubmissionRateLimit(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 10, 0, 0, 0, time.Local))
+	srv := newTestServer(t)
+	config.MaxOpenComplaints = 0
+	config.SubmitRateLimit = 2
+	config.SubmitRateWindow = time.Hour
+	_, code := registerTestUser(t, srv, "Fast User", "fast@example.com")
+
+	submitTestComplaint(t, srv, code, "First", 5)
+	fake.Advance(20 * time.Minute)
+	submitTestComplaint(t, srv, code, "Second", 5)
+
+	status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+		SecretCode: code, Title: "Third", Summary: "Too soon", Rating: 5,
+	})
+	if status != http.StatusTooManyRequests || resp.ErrorCode != ErrCodeRateLimited {
+		t.Fatalf("Expected 429 RATE_LIMITED, got %d %s", status, resp.ErrorCode)
+	}
+
+	// The window slides: the first submission expires 40 minutes later
+	fake.Advance(40 * time.Minute)
+	submitTestComplaint(t, srv, code, "Third", 5)
+
+	for i := 0; i < 3; i++ {
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: adminSecret, Title: "Admin", Summary: "Exempt", Rating: 5,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected admin to be exempt, got %d (%s)", status, resp.Error)
+		}
+	}
+}
+
+func TestSubmissionLimitsUnderConcurrency(t *testing.T) {
+	srv := newTestServer(t)
+	config.MaxOpenComplaints = 5
+	config.SubmitRateLimit = 0
+	_, code := registerTestUser(t, srv, "Racing User", "racing@example.com")
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	created := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status, _ := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+				SecretCode: code, Title: fmt.Sprintf("Race %d", i), Summary: "Concurrent", Rating: 5,
+			})
+			if status == http.StatusCreated {
+				mutex.Lock()
+				created++
+				mutex.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if created != 5 {
+		t.Errorf("Expected exactly 5 complaints created, got %d", created)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// RatingScale is the range complaints are rated in, both ends included
+type RatingScale struct {
+	Min int `json:"min" xml:"min"`
+	Max int `json:"max" xml:"max"`
+}
+
+// legacyRatingScale is the default scale, and the one complaints were rated
+// on before it could be configured
+var legacyRatingScale = RatingScale{Min: 1, Max: 10}
+
+// maxRatingScaleSize bounds how many ratings a scale may have, which keeps
+// rating histograms small
+const maxRatingScaleSize = 100
+
+// validateRatingScale checks a configured or stored scale. Ratings start
+// at 1, since 0 means "no rating" in drafts.
+func validateRatingScale(scale RatingScale) error {
+	if scale.Min < 1 {
+		return errors.New("the lowest rating must be at least 1")
+	}
+	if scale.Max <= scale.Min || scale.Max-scale.Min+1 > maxRatingScaleSize {
+		return fmt.Errorf("the highest rating must be above the lowest, with at most %d ratings in between", maxRatingScaleSize)
+	}
+	return nil
+}
+
+// currentRatingScale is the scale new complaints are rated on
+func currentRatingScale() RatingScale {
+	return RatingScale{Min: config.RatingMin, Max: config.RatingMax}
+}
+
+func (s RatingScale) contains(rating int) bool {
+	return rating >= s.Min && rating <= s.Max
+}
+
+// normalize maps rating on s to 0 (the lowest) through 1 (the highest),
+// clamping ratings outside s
+func (s RatingScale) normalize(rating int) float64 {
+	if s.Max <= s.Min {
+		return 0
+	}
+	value := float64(rating-s.Min) / float64(s.Max-s.Min)
+	return math.Max(0, math.Min(1, value))
+}
+
+// denormalize maps a normalized value back onto s
+func (s RatingScale) denormalize(value float64) float64 {
+	return float64(s.Min) + value*float64(s.Max-s.Min)
+}
+
+// ratingOnCurrentScale is the rating on the configured scale closest to
+// complaint's rating on the scale it was given on
+func ratingOnCurrentScale(complaint *Complaint) int {
+	value := complaint.RatingScale.normalize(complaint.Rating)
+	return int(math.Round(currentRatingScale().denormalize(value)))
+}
+
+// countRatingsOutOfScale counts the complaints whose rating falls outside
+// the configured scale, as after the scale was changed. Callers must hold
+// storage.mutex.
+func countRatingsOutOfScale() int {
+	scale, count := currentRatingScale(), 0
+	for _, complaint := range storage.complaints {
+		if !scale.contains(complaint.Rating) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateRatingScale(t *testing.T) {
+	for _, scale := range []RatingScale{{1, 5}, {1, 10}, {0, 5}, {3, 3}, {5, 1}, {1, 101}} {
+		err := validateRatingScale(scale)
+		valid := scale == RatingScale{1, 5} || scale == RatingScale{1, 10}
+		if (err == nil) != valid {
+			t.Errorf("validateRatingScale(%+v) = %v, want valid %v", scale, err, valid)
+		}
+	}
+}
+
+func TestRatingScale(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	config.RatingMin, config.RatingMax = 1, 5
+	_, code := registerTestUser(t, srv, "Star User", "stars@example.com")
+
+	t.Run("Public Config", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/config")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Data PublicConfig `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != http.StatusOK || body.Data.RatingScale != (RatingScale{Min: 1, Max: 5}) {
+			t.Errorf("Expected 200 with the 1-5 scale, got %d and %+v", resp.StatusCode, body.Data)
+		}
+	})
+
+	t.Run("Submission", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: "Too high", Summary: "Summary", Rating: 6})
+		if status != http.StatusBadRequest || !strings.Contains(resp.Error, "between 1 and 5") {
+			t.Errorf("Expected 400 naming the 1-5 range for rating 6, got %d (%s)", status, resp.Error)
+		}
+		complaint := submitTestComplaint(t, srv, code, "Five stars", 5)
+		if complaint.RatingScale != (RatingScale{Min: 1, Max: 5}) || complaint.RatingOutOfRange {
+			t.Errorf("Expected a complaint rated on the 1-5 scale, got %+v", complaint)
+		}
+	})
+}
+
+func TestLegacyRatingsOutOfScale(t *testing.T) {
+	srv := newTestServer(t)
+	if _, err := loadBackupFile("testdata/backup-v3.json"); err != nil {
+		t.Fatalf("Failed to load backup: %v", err)
+	}
+	// The server restarts with a 1-5 scale; the complaints were rated 8, 6
+	// and 4 out of 10
+	config.RatingMin, config.RatingMax = 1, 5
+	storage.mutex.RLock()
+	outOfScale := countRatingsOutOfScale()
+	storage.mutex.RUnlock()
+	if outOfScale != 2 {
+		t.Errorf("Expected 2 complaints out of scale, got %d", outOfScale)
+	}
+
+	t.Run("Flagged", func(t *testing.T) {
+		for id, flagged := range map[int]bool{1: true, 2: true, 3: false} {
+			complaint := assigneeOf(t, srv, id)
+			if complaint.RatingOutOfRange != flagged || complaint.RatingScale != legacyRatingScale {
+				t.Errorf("Complaint %d: expected flagged %v on the 1-10 scale, got %v on %+v", id, flagged, complaint.RatingOutOfRange, complaint.RatingScale)
+			}
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/userStats", UserStatsRequest{SecretCode: adminSecret, UserID: 2})
+		var stats UserStats
+		resp.decode(t, &stats)
+		// 8, 6 and 4 out of 10 average 5/9 of the way up, 3.22 out of 5
+		if stats.AverageRating != 3.22 {
+			t.Errorf("Expected an average rating of 3.22, got %v", stats.AverageRating)
+		}
+	})
+
+	t.Run("Analytics", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/analytics/ratings", RatingAnalyticsRequest{SecretCode: adminSecret})
+		var analytics RatingAnalytics
+		resp.decode(t, &analytics)
+		want := []RatingCount{{1, 0}, {2, 1}, {3, 1}, {4, 1}, {5, 0}}
+		if analytics.Scale != (RatingScale{Min: 1, Max: 5}) || len(analytics.Ratings) != len(want) {
+			t.Fatalf("Expected a 1-5 histogram, got %+v", analytics)
+		}
+		for i := range want {
+			if analytics.Ratings[i] != want[i] {
+				t.Errorf("Expected %+v, got %+v", want, analytics.Ratings)
+				break
+			}
+		}
+	})
+
+	t.Run("Restored As Is", func(t *testing.T) {
+		document := fetchBackup(t, srv)
+		var backup Backup
+		json.Unmarshal(document, &backup)
+		if backup.Complaints[0].Rating != 8 || backup.Complaints[0].RatingScale != legacyRatingScale {
+			t.Errorf("Expected the backup to keep the 8/10 rating, got %+v", backup.Complaints[0].Complaint)
+		}
+		if status, resp := restoreBackup(t, srv, document); status != http.StatusOK {
+			t.Errorf("Expected the backup to restore under the new scale, got %d (%s)", status, resp.Error)
+		}
+	})
+}
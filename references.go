@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// Complaint references are short codes like CMP-7F3K9Q given to every
+// complaint, which clients can show and pass instead of the sequential,
+// guessable integer ID. The integer ID stays the key of storage.complaints.
+const (
+	referencePrefix = "CMP-"
+	referenceLength = 6 // characters after the prefix
+	// referenceAlphabet leaves out 0, 1, I, L and O, which are easily
+	// mistaken for one another
+	referenceAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+)
+
+// maxReferenceAttempts bounds the codes tried for one complaint before
+// giving up, which only happens if the generator keeps colliding
+const maxReferenceAttempts = 10
+
+// maxReferenceBodySize bounds the request bodies withComplaintReferences
+// looks into; larger ones, such as restores, pass through untouched
+const maxReferenceBodySize = 1 << 20
+
+// unknownReferenceID stands in for a reference no complaint has. It passes
+// validation and matches no complaint, so handlers answer 404 after
+// authenticating the caller, exactly as for an unknown integer ID, and
+// unauthenticated callers cannot probe which references exist.
+const unknownReferenceID = math.MaxInt
+
+var errReferencesExhausted = errors.New("could not generate an unused complaint reference")
+
+// referenceGenerator makes candidate references; tests replace it to force
+// collisions
+var referenceGenerator = generateReference
+
+func generateReference() (string, error) {
+	buf := make([]byte, referenceLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := make([]byte, referenceLength)
+	for i, b := range buf {
+		code[i] = referenceAlphabet[int(b)%len(referenceAlphabet)]
+	}
+	return referencePrefix + string(code), nil
+}
+
+// newReference returns a reference not in taken, trying up to
+// maxReferenceAttempts candidates
+func newReference(taken map[string]int) (string, error) {
+	for attempt := 0; attempt < maxReferenceAttempts; attempt++ {
+		reference, err := referenceGenerator()
+		if err != nil {
+			return "", err
+		}
+		if _, exists := taken[reference]; !exists {
+			return reference, nil
+		}
+	}
+	return "", errReferencesExhausted
+}
+
+// normalizeReference trims and uppercases a client-supplied reference, and
+// reports whether it has the shape of one
+func normalizeReference(raw string) (string, bool) {
+	reference := strings.ToUpper(strings.TrimSpace(raw))
+	code, found := strings.CutPrefix(reference, referencePrefix)
+	if !found || len(code) != referenceLength {
+		return "", false
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(referenceAlphabet, r) {
+			return "", false
+		}
+	}
+	return reference, true
+}
+
+// complaintIDForReference is the ID of the complaint with reference, or
+// unknownReferenceID when there is none
+func complaintIDForReference(reference string) int {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if id, exists := storage.references[reference]; exists {
+		return id
+	}
+	return unknownReferenceID
+}
+
+// complaintIDFields are the request fields holding one complaint ID, and
+// complaintIDListFields those holding several
+var (
+	complaintIDFields     = []string{"complaint_id", "related_id", "primary_id"}
+	complaintIDListFields = []string{"complaint_ids", "duplicate_ids"}
+)
+
+// resolveReference turns value into the complaint ID it refers to when it
+// is a reference string, reporting whether it did
+func resolveReference(value json.RawMessage) (json.RawMessage, bool) {
+	var raw string
+	if json.Unmarshal(value, &raw) != nil {
+		return value, false
+	}
+	reference, ok := normalizeReference(raw)
+	if !ok {
+		return value, false
+	}
+	id, _ := json.Marshal(complaintIDForReference(reference))
+	return id, true
+}
+
+// replaceReferences rewrites the references in the complaint ID fields of
+// a JSON request body to IDs. It returns body itself when there are none,
+// or when body is not a JSON object, leaving that for the handler to
+// reject.
+func replaceReferences(body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return body
+	}
+	changed := false
+	for _, name := range complaintIDFields {
+		if value, exists := fields[name]; exists {
+			if id, ok := resolveReference(value); ok {
+				fields[name], changed = id, true
+			}
+		}
+	}
+	for _, name := range complaintIDListFields {
+		var values []json.RawMessage
+		if json.Unmarshal(fields[name], &values) != nil {
+			continue
+		}
+		listChanged := false
+		for i, value := range values {
+			if id, ok := resolveReference(value); ok {
+				values[i], listChanged = id, true
+			}
+		}
+		if listChanged {
+			fields[name], _ = json.Marshal(values)
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+// withComplaintReferences lets every endpoint taking complaint_id,
+// related_id, primary_id, duplicate_ids or complaint_ids in its JSON body
+// accept complaint references there too, by swapping them for IDs before
+// the handler decodes the body
+func withComplaintReferences(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxReferenceBodySize+1))
+		if err != nil || len(body) > maxReferenceBodySize {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if bytes.Contains(bytes.ToUpper(body), []byte(referencePrefix)) {
+			body = replaceReferences(body)
+			r.ContentLength = int64(len(body))
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// rigReferences makes the reference generator return codes in order,
+// repeating the last one once they run out
+func rigReferences(t *testing.T, codes ...string) {
+	t.Helper()
+	previous := referenceGenerator
+	t.Cleanup(func() { referenceGenerator = previous })
+	next := 0
+	referenceGenerator = func() (string, error) {
+		code := codes[min(next, len(codes)-1)]
+		next++
+		return code, nil
+	}
+}
+
+func TestComplaintReferences(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Reference User", "reference@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+	heating := submitTestComplaint(t, srv, code, "Heating broken", 4)
+	lift := submitTestComplaint(t, srv, code, "Lift noisy", 6)
+
+	if reference, ok := normalizeReference(heating.Reference); !ok || reference != heating.Reference {
+		t.Fatalf("Expected a reference like CMP-7F3K9Q, got %q", heating.Reference)
+	}
+	if heating.Reference == lift.Reference {
+		t.Fatalf("Expected distinct references, got %s twice", heating.Reference)
+	}
+
+	t.Run("View", func(t *testing.T) {
+		for _, id := range []interface{}{heating.ID, heating.Reference, " " + strings.ToLower(heating.Reference)} {
+			status, resp := postJSON(t, srv, "/viewComplaint", map[string]interface{}{"secret_code": code, "complaint_id": id})
+			if status != http.StatusOK {
+				t.Fatalf("View by %v: expected 200, got %d (%s)", id, status, resp.Error)
+			}
+			var complaint Complaint
+			resp.decode(t, &complaint)
+			if complaint.ID != heating.ID || complaint.Reference != heating.Reference {
+				t.Errorf("View by %v: expected complaint %d (%s), got %d (%s)", id, heating.ID, heating.Reference, complaint.ID, complaint.Reference)
+			}
+		}
+
+		resp, body := doV1(t, srv, http.MethodGet, "/v1/complaints/"+lift.Reference, code, nil)
+		var complaint Complaint
+		body.decode(t, &complaint)
+		if resp.StatusCode != http.StatusOK || complaint.ID != lift.ID {
+			t.Errorf("Expected /v1/complaints/%s to be complaint %d, got %d (%+v)", lift.Reference, lift.ID, resp.StatusCode, complaint)
+		}
+	})
+
+	t.Run("Unknown Or Hidden", func(t *testing.T) {
+		// Unknown references fail like unknown IDs, after authentication
+		tests := []struct {
+			name   string
+			secret string
+			id     interface{}
+			status int
+		}{
+			{"Unknown Reference", code, "CMP-ZZZZZZ", http.StatusNotFound},
+			{"Unknown ID", code, 9999, http.StatusNotFound},
+			{"Unknown Reference, Bad Secret", "WRONG", "CMP-ZZZZZZ", http.StatusUnauthorized},
+			{"Known Reference, Bad Secret", "WRONG", heating.Reference, http.StatusUnauthorized},
+			{"Another User's", otherCode, heating.Reference, http.StatusNotFound},
+			{"Not A Reference", code, "heating", http.StatusBadRequest},
+		}
+		for _, tc := range tests {
+			status, _ := postJSON(t, srv, "/viewComplaint", map[string]interface{}{"secret_code": tc.secret, "complaint_id": tc.id})
+			if status != tc.status {
+				t.Errorf("%s: expected %d, got %d", tc.name, tc.status, status)
+			}
+		}
+		if resp, _ := doV1(t, srv, http.MethodGet, "/v1/complaints/CMP-ZZZZZZ", code, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 for an unknown reference on /v1, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Resolve And Note", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/addAdminNote", map[string]interface{}{"secret_code": adminSecret, "complaint_id": heating.Reference, "note": "Engineer booked"})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201 for a note by reference, got %d (%s)", status, resp.Error)
+		}
+		status, resp = postJSON(t, srv, "/resolveComplaint", map[string]interface{}{"secret_code": adminSecret, "complaint_id": heating.Reference, "note": "Fixed"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 for a resolve by reference, got %d (%s)", status, resp.Error)
+		}
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		if complaint := storage.complaints[heating.ID]; !complaint.IsResolved || len(complaint.AdminNotes) != 1 {
+			t.Errorf("Expected complaint %d noted and resolved, got %+v", heating.ID, complaint)
+		}
+	})
+
+	t.Run("Lists", func(t *testing.T) {
+		again := submitTestComplaint(t, srv, code, "Lift still noisy", 6)
+		status, resp := postJSON(t, srv, "/mergeComplaints", map[string]interface{}{"secret_code": adminSecret, "primary_id": lift.Reference, "duplicate_ids": []interface{}{again.Reference}})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 for a merge by references, got %d (%s)", status, resp.Error)
+		}
+		if got := assigneeOf(t, srv, again.ID); got.MergedInto != lift.ID {
+			t.Errorf("Expected complaint %d merged into %d, got %+v", again.ID, lift.ID, got)
+		}
+	})
+}
+
+func TestReferenceCollisions(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Collision User", "collision@example.com")
+
+	rigReferences(t, "CMP-AAAAAA", "CMP-AAAAAA", "CMP-AAAAAA", "CMP-BBBBBB")
+	first := submitTestComplaint(t, srv, code, "First", 5)
+	second := submitTestComplaint(t, srv, code, "Second", 5)
+	if first.Reference != "CMP-AAAAAA" || second.Reference != "CMP-BBBBBB" {
+		t.Fatalf("Expected CMP-AAAAAA then CMP-BBBBBB after the collisions, got %s and %s", first.Reference, second.Reference)
+	}
+
+	// Only CMP-BBBBBB from now on, which is taken
+	status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: "Third", Summary: "Summary", Rating: 5})
+	if status != http.StatusInternalServerError {
+		t.Fatalf("Expected 500 once no unused reference is found, got %d (%s)", status, resp.Error)
+	}
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if len(storage.complaints) != 2 || storage.compIDGen != 2 {
+		t.Errorf("Expected nothing stored, got %d complaints and counter %d", len(storage.complaints), storage.compIDGen)
+	}
+}
+
+func TestReferencesSurviveRestore(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Restore User", "restore@example.com")
+	complaint := submitTestComplaint(t, srv, code, "Leaking tap", 3)
+	other := submitTestComplaint(t, srv, code, "Broken blind", 3)
+	backup := fetchBackup(t, srv)
+
+	// A fresh server holding other complaints under the same IDs
+	srv = newTestServer(t)
+	_, code = registerTestUser(t, srv, "Restore User", "restore@example.com")
+	submitTestComplaint(t, srv, code, "Something else", 3)
+	if status, resp := restoreBackup(t, srv, backup); status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+	}
+
+	status, resp := postJSON(t, srv, "/viewComplaint", map[string]interface{}{"secret_code": adminSecret, "complaint_id": complaint.Reference})
+	var restored Complaint
+	resp.decode(t, &restored)
+	if status != http.StatusOK || restored.ID != complaint.ID || restored.Title != "Leaking tap" {
+		t.Fatalf("Expected %s to still be complaint %d, got %d (%+v)", complaint.Reference, complaint.ID, status, restored)
+	}
+
+	duplicated := bytes.Replace(backup, []byte(`"reference":"`+other.Reference), []byte(`"reference":"`+complaint.Reference), 1)
+	if bytes.Equal(duplicated, backup) {
+		t.Fatal("Expected the backup to contain the second complaint's reference")
+	}
+	if status, _ := restoreBackup(t, srv, duplicated); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a reference used twice, got %d", status)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers a client signs each write with when -replay-protection is on
+const (
+	nonceHeader     = "X-Request-Nonce"
+	timestampHeader = "X-Request-Timestamp" // Unix seconds
+)
+
+const (
+	maxNonceLength      = 128
+	replayNoncesPerUser = 1000 // nonces remembered per user; the least recently used go first
+)
+
+// seenNonce is a nonce accepted at a given time
+type seenNonce struct {
+	nonce string
+	at    time.Time
+}
+
+// nonceCache is one user's recently accepted nonces, oldest first
+type nonceCache struct {
+	order *list.List
+	index map[string]*list.Element
+}
+
+// ReplayGuard remembers the nonces each user's writes carried, long enough
+// that a captured request cannot be sent again while its timestamp is still
+// accepted
+type ReplayGuard struct {
+	users map[int]*nonceCache
+	mutex sync.Mutex
+}
+
+func newReplayGuard() *ReplayGuard {
+	return &ReplayGuard{users: make(map[int]*nonceCache)}
+}
+
+var replayGuard = newReplayGuard()
+
+// use records nonce for userID at now, and reports false if it was already
+// used. A nonce is remembered for twice config.ReplayWindow, as a timestamp
+// up to the window ahead stays acceptable until the window has passed after
+// it.
+func (g *ReplayGuard) use(userID int, nonce string, now time.Time) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	cache, exists := g.users[userID]
+	if !exists {
+		cache = &nonceCache{order: list.New(), index: make(map[string]*list.Element)}
+		g.users[userID] = cache
+	}
+	for front := cache.order.Front(); front != nil; front = cache.order.Front() {
+		seen := front.Value.(*seenNonce)
+		if now.Sub(seen.at) <= 2*config.ReplayWindow {
+			break
+		}
+		cache.order.Remove(front)
+		delete(cache.index, seen.nonce)
+	}
+
+	if _, used := cache.index[nonce]; used {
+		return false
+	}
+	cache.index[nonce] = cache.order.PushBack(&seenNonce{nonce: nonce, at: now})
+	if cache.order.Len() > replayNoncesPerUser {
+		oldest := cache.order.Front()
+		cache.order.Remove(oldest)
+		delete(cache.index, oldest.Value.(*seenNonce).nonce)
+	}
+	return true
+}
+
+// checkReplay rejects a write by user that does not carry a fresh timestamp
+// and a nonce user has not sent before, with -replay-protection on. It runs
+// once the user is known, so each user's nonces are kept apart. On failure
+// it has already written the response.
+func checkReplay(w http.ResponseWriter, r *http.Request, user *User) bool {
+	if !config.ReplayProtection || !requestWrites(r) {
+		return true
+	}
+
+	nonce := strings.TrimSpace(r.Header.Get(nonceHeader))
+	seconds, err := strconv.ParseInt(strings.TrimSpace(r.Header.Get(timestampHeader)), 10, 64)
+	if nonce == "" || err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, nonceHeader+" and "+timestampHeader+" (Unix seconds) are required")
+		return false
+	}
+	if len(nonce) > maxNonceLength {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, nonceHeader+" must be at most "+strconv.Itoa(maxNonceLength)+" characters")
+		return false
+	}
+
+	now := clock.Now()
+	skew := now.Sub(time.Unix(seconds, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > config.ReplayWindow {
+		respondWithError(w, http.StatusConflict, ErrCodeReplayDetected, "Request timestamp is outside the accepted window")
+		return false
+	}
+	if !replayGuard.use(user.ID, nonce, now) {
+		respondWithError(w, http.StatusConflict, ErrCodeReplayDetected, "Request nonce was already used")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReplayProtection(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC))
+	_, code := registerTestUser(t, srv, "Careful User", "careful@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+
+	// Off by default
+	submitTestComplaint(t, srv, code, "Before protection", 5)
+	config.ReplayProtection = true
+
+	send := func(endpoint string, payload interface{}, nonce string, timestamp time.Time) (int, testResponse) {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+endpoint, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if nonce != "" {
+			req.Header.Set(nonceHeader, nonce)
+			req.Header.Set(timestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", endpoint, err)
+		}
+		defer resp.Body.Close()
+		var response testResponse
+		json.NewDecoder(resp.Body).Decode(&response)
+		return resp.StatusCode, response
+	}
+	countComplaints := func(secret string) int {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: secret})
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		return len(complaints)
+	}
+	submission := SubmitComplaintRequest{SecretCode: code, Title: "Captured", Summary: "Sent twice", Rating: 5}
+
+	t.Run("Replayed Submit", func(t *testing.T) {
+		if status, resp := send("/submitComplaint", submission, "nonce-1", fake.Now()); status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		fake.Advance(time.Minute)
+		status, resp := send("/submitComplaint", submission, "nonce-1", fake.Now().Add(-time.Minute))
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeReplayDetected {
+			t.Errorf("Expected 409 REPLAY_DETECTED, got %d %s", status, resp.ErrorCode)
+		}
+		if count := countComplaints(code); count != 2 {
+			t.Errorf("Expected exactly one complaint from the captured request, got %d in all", count-1)
+		}
+	})
+
+	t.Run("Timestamp Window", func(t *testing.T) {
+		for _, offset := range []time.Duration{-6 * time.Minute, 6 * time.Minute} {
+			status, resp := send("/submitComplaint", submission, "nonce-skew-"+offset.String(), fake.Now().Add(offset))
+			if status != http.StatusConflict || resp.ErrorCode != ErrCodeReplayDetected {
+				t.Errorf("Offset %s: expected 409 REPLAY_DETECTED, got %d %s", offset, status, resp.ErrorCode)
+			}
+		}
+		// A timestamp ahead of the clock is still refused once replayed
+		// after the window has passed since its nonce was first seen
+		ahead := fake.Now().Add(5 * time.Minute)
+		if status, resp := send("/submitComplaint", submission, "nonce-ahead", ahead); status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		fake.Advance(9 * time.Minute)
+		if status, _ := send("/submitComplaint", submission, "nonce-ahead", ahead); status != http.StatusConflict {
+			t.Errorf("Expected 409 replaying within the timestamp's window, got %d", status)
+		}
+	})
+
+	t.Run("Headers Required", func(t *testing.T) {
+		status, resp := send("/submitComplaint", submission, "", time.Time{})
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("Expected 400 VALIDATION_FAILED without headers, got %d %s", status, resp.ErrorCode)
+		}
+		// Reads need neither
+		if status, _ := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: code}); status != http.StatusOK {
+			t.Errorf("Expected 200 reading without headers, got %d", status)
+		}
+	})
+
+	t.Run("Nonces Per User", func(t *testing.T) {
+		other := SubmitComplaintRequest{SecretCode: otherCode, Title: "Mine", Summary: "Same nonce", Rating: 5}
+		if status, resp := send("/submitComplaint", other, "nonce-1", fake.Now()); status != http.StatusCreated {
+			t.Errorf("Expected another user's nonces kept apart, got %d (%s)", status, resp.Error)
+		}
+	})
+}
+
+func TestReplayGuardBound(t *testing.T) {
+	config = defaultConfig()
+	guard := newReplayGuard()
+	now := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	for i := 0; i <= replayNoncesPerUser; i++ {
+		if !guard.use(1, "n"+strconv.Itoa(i), now) {
+			t.Fatalf("Nonce %d refused", i)
+		}
+	}
+	if n := guard.users[1].order.Len(); n != replayNoncesPerUser {
+		t.Errorf("Expected %d nonces kept, got %d", replayNoncesPerUser, n)
+	}
+	if guard.use(1, "n"+strconv.Itoa(replayNoncesPerUser), now) {
+		t.Error("Expected the newest nonce remembered")
+	}
+	if !guard.use(1, "n0", now) {
+		t.Error("Expected the oldest nonce evicted")
+	}
+	if !guard.use(1, "n5", now.Add(2*config.ReplayWindow+time.Second)) {
+		t.Error("Expected nonces forgotten after twice the window")
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout        = "2006-01-02"
+	maxReportDays     = 366
+	reportTopUsers    = 5
+	staleComplaintAge = 7 * 24 * time.Hour
+)
+
+type ReportRequest struct {
+	SecretCode string `json:"secret_code"`
+	From       string `json:"from,omitempty"`   // YYYY-MM-DD, UTC, inclusive; defaults to 6 days before To
+	To         string `json:"to,omitempty"`     // YYYY-MM-DD, UTC, inclusive; defaults to today
+	Format     string `json:"format,omitempty"` // json (default) or csv
+
+	IncludeArchived bool `json:"include_archived,omitempty"`
+}
+
+type DailyCount struct {
+	Date     string `json:"date"`
+	Created  int    `json:"created"`
+	Resolved int    `json:"resolved"`
+}
+
+type UserComplaintCount struct {
+	UserID     int    `json:"user_id"`
+	UserName   string `json:"user_name"`
+	Complaints int    `json:"complaints"`
+}
+
+type StaleComplaint struct {
+	ID        int     `json:"id"`
+	Reference string  `json:"reference"`
+	Title     string  `json:"title"`
+	UserName  string  `json:"user_name"`
+	CreatedAt string  `json:"created_at"`
+	AgeDays   float64 `json:"age_days"`
+}
+
+type Report struct {
+	From                   string               `json:"from"`
+	To                     string               `json:"to"`
+	Days                   []DailyCount         `json:"days"`
+	TotalCreated           int                  `json:"total_created"`
+	TotalResolved          int                  `json:"total_resolved"`
+	AverageResolutionHours float64              `json:"average_resolution_hours"`
+	TopUsers               []UserComplaintCount `json:"top_users"`
+	StaleOpen              []StaleComplaint     `json:"stale_open_complaints"`
+	OpenByPriority         []PriorityCount      `json:"open_by_priority"` // all open complaints, regardless of range
+	OverdueOpen            int                  `json:"overdue_open"`     // open complaints past their SLA deadline
+	ByDepartment           []DepartmentCount    `json:"by_department"`    // busiest first
+	ByBuilding             []BuildingCount      `json:"by_building"`      // busiest first; complaints without a building are left out
+	BySource               []SourceCount        `json:"by_source"`        // busiest first
+	FeedbackCount          int                  `json:"feedback_count"`   // feedback submitted in range
+	AverageSatisfaction    float64              `json:"average_satisfaction"`
+}
+
+// parseReportRange validates the requested UTC date range
+func parseReportRange(req ReportRequest, now time.Time) (time.Time, time.Time, error) {
+	to := now.UTC().Truncate(24 * time.Hour)
+	if req.To != "" {
+		parsed, err := time.Parse(dateLayout, req.To)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("To must be a date in YYYY-MM-DD format")
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -6)
+	if req.From != "" {
+		parsed, err := time.Parse(dateLayout, req.From)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("From must be a date in YYYY-MM-DD format")
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("From must not be after To")
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxReportDays {
+		return time.Time{}, time.Time{}, fmt.Errorf("Report range cannot exceed %d days", maxReportDays)
+	}
+	return from, to, nil
+}
+
+// buildReport computes the report on the complaints viewer's organization
+// may see, archived ones only if includeArchived, in a single pass over
+// storage.complaints
+func buildReport(viewer *User, from, to, now time.Time, includeArchived bool) Report {
+	numDays := int(to.Sub(from).Hours()/24) + 1
+	report := Report{
+		From:      from.Format(dateLayout),
+		To:        to.Format(dateLayout),
+		Days:      make([]DailyCount, numDays),
+		TopUsers:  []UserComplaintCount{},
+		StaleOpen: []StaleComplaint{},
+	}
+	for i := range report.Days {
+		report.Days[i].Date = from.AddDate(0, 0, i).Format(dateLayout)
+	}
+
+	// dayIndex maps a timestamp to its UTC-day bucket, or -1 outside the range
+	dayIndex := func(t time.Time) int {
+		day := t.UTC().Truncate(24 * time.Hour)
+		if day.Before(from) || day.After(to) {
+			return -1
+		}
+		return int(day.Sub(from).Hours() / 24)
+	}
+
+	openByPriority := make(map[string]int)
+	perDepartment := make(map[string]*DepartmentCount)
+	departmentCount := func(name string) *DepartmentCount {
+		if name == "" {
+			name = generalDepartment
+		}
+		count, exists := perDepartment[name]
+		if !exists {
+			count = &DepartmentCount{Department: name}
+			perDepartment[name] = count
+		}
+		return count
+	}
+	// Buildings are told apart ignoring case, as free-form ones may be typed
+	// differently each time; the first spelling seen is kept
+	perBuilding := make(map[string]*BuildingCount)
+	buildingCount := func(name string) *BuildingCount {
+		key := strings.ToLower(name)
+		count, exists := perBuilding[key]
+		if !exists {
+			count = &BuildingCount{Building: name}
+			perBuilding[key] = count
+		}
+		return count
+	}
+	perSource := make(map[string]*SourceCount)
+	for _, source := range complaintSources {
+		perSource[source] = &SourceCount{Source: source}
+	}
+	perUser := make(map[int]*UserComplaintCount)
+	var totalResolution time.Duration
+	totalScore := 0
+
+	storage.mutex.RLock()
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted || (isArchived(complaint) && !includeArchived) || !canSeeOrg(viewer, complaint.OrgID) {
+			continue
+		}
+		created, err := parseTimestamp(complaint.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		if i := dayIndex(created); i >= 0 {
+			report.Days[i].Created++
+			report.TotalCreated++
+			count, exists := perUser[complaint.UserID]
+			if !exists {
+				count = &UserComplaintCount{UserID: complaint.UserID, UserName: complaint.UserName}
+				perUser[complaint.UserID] = count
+			}
+			count.Complaints++
+			departmentCount(complaint.Department).Created++
+			if count, known := perSource[complaint.Source]; known {
+				count.Created++
+			}
+			if complaint.Building != "" {
+				buildingCount(complaint.Building).Created++
+			}
+		}
+
+		if feedback := complaint.Feedback; feedback != nil {
+			if submitted, err := parseTimestamp(feedback.SubmittedAt); err == nil && dayIndex(submitted) >= 0 {
+				report.FeedbackCount++
+				totalScore += feedback.Score
+			}
+		}
+
+		if complaint.IsResolved {
+			if resolved, err := parseTimestamp(complaint.ResolvedAt); err == nil {
+				if i := dayIndex(resolved); i >= 0 {
+					report.Days[i].Resolved++
+					report.TotalResolved++
+					totalResolution += resolved.Sub(created)
+				}
+			}
+		} else if isOpen(complaint) {
+			openByPriority[complaint.Priority]++
+			departmentCount(complaint.Department).Open++
+			if count, known := perSource[complaint.Source]; known {
+				count.Open++
+			}
+			if complaint.Building != "" {
+				buildingCount(complaint.Building).Open++
+			}
+			if isOverdue(complaint, now) {
+				report.OverdueOpen++
+				departmentCount(complaint.Department).Overdue++
+				if complaint.Building != "" {
+					buildingCount(complaint.Building).Overdue++
+				}
+			}
+			if age := now.Sub(created); age > staleComplaintAge {
+				report.StaleOpen = append(report.StaleOpen, StaleComplaint{
+					ID:        complaint.ID,
+					Reference: complaint.Reference,
+					Title:     complaint.Title,
+					UserName:  complaint.UserName,
+					CreatedAt: complaint.CreatedAt,
+					AgeDays:   roundTo(age.Hours()/24, 1),
+				})
+			}
+		}
+	}
+	// Departments without complaints are listed too
+	for _, department := range storage.departments {
+		departmentCount(department.Name)
+	}
+	// and so are the registered buildings
+	for _, building := range storage.buildings {
+		if canSeeOrg(viewer, building.OrgID) {
+			buildingCount(building.Name)
+		}
+	}
+	storage.mutex.RUnlock()
+
+	if report.TotalResolved > 0 {
+		report.AverageResolutionHours = roundTo(totalResolution.Hours()/float64(report.TotalResolved), 2)
+	}
+	if report.FeedbackCount > 0 {
+		report.AverageSatisfaction = roundTo(float64(totalScore)/float64(report.FeedbackCount), 2)
+	}
+
+	for _, count := range perUser {
+		report.TopUsers = append(report.TopUsers, *count)
+	}
+	sort.Slice(report.TopUsers, func(i, j int) bool {
+		a, b := report.TopUsers[i], report.TopUsers[j]
+		if a.Complaints != b.Complaints {
+			return a.Complaints > b.Complaints
+		}
+		return a.UserID < b.UserID
+	})
+	if len(report.TopUsers) > reportTopUsers {
+		report.TopUsers = report.TopUsers[:reportTopUsers]
+	}
+	report.ByDepartment = []DepartmentCount{}
+	for _, count := range perDepartment {
+		report.ByDepartment = append(report.ByDepartment, *count)
+	}
+	sort.Slice(report.ByDepartment, func(i, j int) bool {
+		a, b := report.ByDepartment[i], report.ByDepartment[j]
+		if a.Open != b.Open {
+			return a.Open > b.Open
+		}
+		if a.Created != b.Created {
+			return a.Created > b.Created
+		}
+		return a.Department < b.Department
+	})
+	report.ByBuilding = []BuildingCount{}
+	for _, count := range perBuilding {
+		report.ByBuilding = append(report.ByBuilding, *count)
+	}
+	sort.Slice(report.ByBuilding, func(i, j int) bool {
+		a, b := report.ByBuilding[i], report.ByBuilding[j]
+		if a.Open != b.Open {
+			return a.Open > b.Open
+		}
+		if a.Created != b.Created {
+			return a.Created > b.Created
+		}
+		return a.Building < b.Building
+	})
+	report.BySource = []SourceCount{}
+	for _, count := range perSource {
+		report.BySource = append(report.BySource, *count)
+	}
+	sort.Slice(report.BySource, func(i, j int) bool {
+		a, b := report.BySource[i], report.BySource[j]
+		if a.Created != b.Created {
+			return a.Created > b.Created
+		}
+		if a.Open != b.Open {
+			return a.Open > b.Open
+		}
+		return a.Source < b.Source
+	})
+	sort.Slice(report.StaleOpen, func(i, j int) bool {
+		return report.StaleOpen[i].ID < report.StaleOpen[j].ID
+	})
+
+	// Complaints stored before priorities existed count as medium
+	openByPriority[priorityMedium] += openByPriority[""]
+	for _, priority := range priorities {
+		report.OpenByPriority = append(report.OpenByPriority, PriorityCount{Priority: priority, Open: openByPriority[priority]})
+	}
+
+	return report
+}
+
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow10(places)
+	return math.Round(value*scale) / scale
+}
+
+// writeReportCSV renders the report as blank-line separated CSV tables
+func writeReportCSV(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="report-%s-%s.csv"`, report.From, report.To))
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"date", "created", "resolved"})
+	for _, day := range report.Days {
+		out.Write([]string{day.Date, strconv.Itoa(day.Created), strconv.Itoa(day.Resolved)})
+	}
+	out.Write(nil)
+	out.Write([]string{"metric", "value"})
+	out.Write([]string{"total_created", strconv.Itoa(report.TotalCreated)})
+	out.Write([]string{"total_resolved", strconv.Itoa(report.TotalResolved)})
+	out.Write([]string{"average_resolution_hours", strconv.FormatFloat(report.AverageResolutionHours, 'f', 2, 64)})
+	out.Write([]string{"feedback_count", strconv.Itoa(report.FeedbackCount)})
+	out.Write([]string{"average_satisfaction", strconv.FormatFloat(report.AverageSatisfaction, 'f', 2, 64)})
+	out.Write([]string{"overdue_open", strconv.Itoa(report.OverdueOpen)})
+	out.Write(nil)
+	out.Write([]string{"user_id", "user_name", "complaints"})
+	for _, user := range report.TopUsers {
+		out.Write([]string{strconv.Itoa(user.UserID), user.UserName, strconv.Itoa(user.Complaints)})
+	}
+	out.Write(nil)
+	out.Write([]string{"stale_complaint_id", "title", "user_name", "created_at", "age_days"})
+	for _, stale := range report.StaleOpen {
+		out.Write([]string{strconv.Itoa(stale.ID), stale.Title, stale.UserName, stale.CreatedAt, strconv.FormatFloat(stale.AgeDays, 'f', 1, 64)})
+	}
+	out.Write(nil)
+	out.Write([]string{"priority", "open"})
+	for _, count := range report.OpenByPriority {
+		out.Write([]string{count.Priority, strconv.Itoa(count.Open)})
+	}
+	out.Write(nil)
+	out.Write([]string{"department", "open", "overdue", "created"})
+	for _, count := range report.ByDepartment {
+		out.Write([]string{count.Department, strconv.Itoa(count.Open), strconv.Itoa(count.Overdue), strconv.Itoa(count.Created)})
+	}
+	out.Write(nil)
+	out.Write([]string{"building", "open", "overdue", "created"})
+	for _, count := range report.ByBuilding {
+		out.Write([]string{count.Building, strconv.Itoa(count.Open), strconv.Itoa(count.Overdue), strconv.Itoa(count.Created)})
+	}
+	out.Write(nil)
+	out.Write([]string{"source", "open", "created"})
+	for _, count := range report.BySource {
+		out.Write([]string{count.Source, strconv.Itoa(count.Open), strconv.Itoa(count.Created)})
+	}
+	out.Flush()
+}
+
+// /report - Summary of complaint activity over a date range (admin only)
+func reportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ReportRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.Format != "" && req.Format != "json" && req.Format != "csv" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Format must be json or csv")
+		return
+	}
+
+	now := clock.Now()
+	from, to, err := parseReportRange(req, now)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	report := buildReport(user, from, to, now, req.IncludeArchived)
+
+	if req.Format == "csv" {
+		writeReportCSV(w, report)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Report generated successfully",
+		Data:    report,
+	})
+}
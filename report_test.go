@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	srv := newTestServer(t)
+	start := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC) // Monday
+	fake := useFakeClock(t, start)
+
+	_, alice := registerTestUser(t, srv, "Alice", "alice@example.com")
+	_, bob := registerTestUser(t, srv, "Bob", "bob@example.com")
+
+	// May 6: two from Alice, one from Bob
+	a1 := submitTestComplaint(t, srv, alice, "Heating", 5)
+	submitTestComplaint(t, srv, alice, "Parking", 3)
+	b1 := submitTestComplaint(t, srv, bob, "Printer", 7)
+
+	// May 7 23:30 UTC: one from Bob, still May 7 in UTC
+	fake.Advance(35*time.Hour + 30*time.Minute)
+	submitTestComplaint(t, srv, bob, "Late night", 2)
+
+	// May 8 00:30 UTC: resolve a1 (36.5h after creation) and b1
+	fake.Advance(time.Hour)
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: a1.ID})
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: b1.ID})
+
+	// Report generated on May 15 00:30 UTC, so both open complaints are stale
+	fake.Advance(7 * 24 * time.Hour)
+
+	status, resp := postJSON(t, srv, "/report", ReportRequest{SecretCode: adminSecret, From: "2024-05-05", To: "2024-05-09"})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+	}
+	var report Report
+	resp.decode(t, &report)
+
+	wantDays := []DailyCount{
+		{Date: "2024-05-05", Created: 0, Resolved: 0},
+		{Date: "2024-05-06", Created: 3, Resolved: 0},
+		{Date: "2024-05-07", Created: 1, Resolved: 0},
+		{Date: "2024-05-08", Created: 0, Resolved: 2},
+		{Date: "2024-05-09", Created: 0, Resolved: 0},
+	}
+	if len(report.Days) != len(wantDays) {
+		t.Fatalf("Expected %d days, got %d", len(wantDays), len(report.Days))
+	}
+	for i, want := range wantDays {
+		if report.Days[i] != want {
+			t.Errorf("Day %d: expected %+v, got %+v", i, want, report.Days[i])
+		}
+	}
+
+	if report.TotalCreated != 4 || report.TotalResolved != 2 {
+		t.Errorf("Expected 4 created and 2 resolved, got %d and %d", report.TotalCreated, report.TotalResolved)
+	}
+	if report.AverageResolutionHours != 36.5 {
+		t.Errorf("Expected average resolution of 36.5h, got %v", report.AverageResolutionHours)
+	}
+	if len(report.TopUsers) != 2 || report.TopUsers[0].UserName != "Alice" || report.TopUsers[0].Complaints != 2 {
+		t.Errorf("Unexpected top users %+v", report.TopUsers)
+	}
+	if len(report.StaleOpen) != 2 ||
+		report.StaleOpen[0].Title != "Parking" || report.StaleOpen[0].AgeDays != 8.5 ||
+		report.StaleOpen[1].Title != "Late night" || report.StaleOpen[1].AgeDays != 7 {
+		t.Errorf("Expected Parking (8.5 days) and Late night (7 days) to be stale, got %+v", report.StaleOpen)
+	}
+
+	t.Run("CSV Format", func(t *testing.T) {
+		httpResp, err := http.Post(srv.URL+"/report", "application/json",
+			strings.NewReader(`{"secret_code":"ADMIN_SECRET_123","from":"2024-05-06","to":"2024-05-08","format":"csv"}`))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer httpResp.Body.Close()
+		if !strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/csv") {
+			t.Errorf("Expected text/csv, got %q", httpResp.Header.Get("Content-Type"))
+		}
+
+		body, _ := io.ReadAll(httpResp.Body)
+		reader := csv.NewReader(strings.NewReader(string(body)))
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("Invalid CSV: %v", err)
+		}
+		want := [][]string{
+			{"date", "created", "resolved"},
+			{"2024-05-06", "3", "0"},
+			{"2024-05-07", "1", "0"},
+			{"2024-05-08", "0", "2"},
+			{"metric", "value"},
+		}
+		for i, row := range want {
+			if strings.Join(rows[i], ",") != strings.Join(row, ",") {
+				t.Errorf("Row %d: expected %v, got %v", i, row, rows[i])
+			}
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		cases := []ReportRequest{
+			{SecretCode: adminSecret, From: "2024-05-09", To: "2024-05-01"},
+			{SecretCode: adminSecret, From: "05/01/2024"},
+			{SecretCode: adminSecret, From: "2020-01-01", To: "2024-01-01"},
+			{SecretCode: adminSecret, Format: "pdf"},
+		}
+		for _, req := range cases {
+			if status, _ := postJSON(t, srv, "/report", req); status != http.StatusBadRequest {
+				t.Errorf("%+v: expected status 400, got %d", req, status)
+			}
+		}
+		if status, _ := postJSON(t, srv, "/report", ReportRequest{SecretCode: alice}); status != http.StatusForbidden {
+			t.Errorf("Expected non-admin to get 403, got %d", status)
+		}
+	})
+}
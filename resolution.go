@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxRejectionReasonLength caps the reason an owner gives for rejecting a
+// resolution, in characters
+const maxRejectionReasonLength = 1000
+
+// With -confirm-resolutions, resolving a complaint only proposes the
+// resolution. The complaint waits in pending_confirmation until its owner
+// confirms it, which closes it, or rejects it, which reopens it. An owner
+// who does neither for -auto-confirm-days is taken to agree.
+
+// resolutionTransition is a change of status made by a resolution action
+type resolutionTransition struct {
+	from, to string
+}
+
+// resolutionTransitions are the status changes of two-phase resolution,
+// keyed by the history action recording them
+var resolutionTransitions = map[string]resolutionTransition{
+	historyResolutionProposed:  {statusOpen, statusPendingConfirmation},
+	historyResolutionConfirmed: {statusPendingConfirmation, statusResolved},
+	historyResolutionRejected:  {statusPendingConfirmation, statusOpen},
+}
+
+type ResolutionAnswerRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+	Reason      string `json:"reason,omitempty"` // why the problem remains; required to reject
+}
+
+// transitionResolution moves complaint along the resolutionTransitions of
+// entry.Action, recording entry in its history and bumping its version.
+// Callers must hold storage.mutex for writing.
+func transitionResolution(complaint *Complaint, entry HistoryEntry) *APIError {
+	transition := resolutionTransitions[entry.Action]
+	if complaintStatus(complaint) != transition.from {
+		return newAPIError(http.StatusConflict, ErrCodeNotPendingConfirmation, "Complaint is not awaiting confirmation of its resolution")
+	}
+
+	switch transition.to {
+	case statusPendingConfirmation:
+		complaint.IsResolved = true
+		complaint.ResolvedAt = getCurrentTime()
+		complaint.PendingConfirmation = true
+	case statusResolved:
+		complaint.PendingConfirmation = false
+	case statusOpen:
+		complaint.IsResolved = false
+		complaint.ResolvedAt = ""
+		complaint.ResolutionNote = ""
+		complaint.ResolvedBy = 0
+		complaint.ResolvedByName = ""
+		complaint.PendingConfirmation = false
+	}
+	addHistory(complaint, entry)
+	touchComplaint(complaint)
+	return nil
+}
+
+// resolvedBy is the admin who last proposed complaint's resolution, or 0
+func resolvedBy(complaint *Complaint) int {
+	for i := len(complaint.History) - 1; i >= 0; i-- {
+		if complaint.History[i].Action == historyResolutionProposed {
+			return complaint.History[i].ActorID
+		}
+	}
+	return 0
+}
+
+// confirmPendingResolutions confirms resolutions whose owners have not
+// answered within config.AutoConfirmAfterDays
+func confirmPendingResolutions(ctx context.Context, now time.Time) int {
+	if config.AutoConfirmAfterDays <= 0 {
+		return 0
+	}
+	cutoff := now.AddDate(0, 0, -config.AutoConfirmAfterDays)
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	confirmed := 0
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted || complaintStatus(complaint) != statusPendingConfirmation {
+			continue
+		}
+		resolvedAt, err := parseTimestamp(complaint.ResolvedAt)
+		if err != nil || resolvedAt.After(cutoff) {
+			continue
+		}
+		transitionResolution(complaint, HistoryEntry{
+			Action: historyResolutionConfirmed,
+			Detail: fmt.Sprintf("No answer from the owner in %d days", config.AutoConfirmAfterDays),
+		})
+		confirmed++
+	}
+	if confirmed > 0 {
+		loggerFrom(ctx).Info("Confirmed resolutions for their owners", "count", confirmed)
+	}
+	return confirmed
+}
+
+// decodeResolutionAnswer reads and checks a /confirmResolution or
+// /rejectResolution request and finds the caller's complaint, writing the
+// error response when it fails. On success storage.mutex is held for
+// writing and the caller must unlock it.
+func decodeResolutionAnswer(w http.ResponseWriter, r *http.Request, reject bool) (ResolutionAnswerRequest, *User, *Complaint) {
+	var req ResolutionAnswerRequest
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return req, nil, nil
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return req, nil, nil
+	}
+
+	req.Reason = sanitizeText(req.Reason, true)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("complaint_id", req.ComplaintID)
+	if reject {
+		v.required("reason", req.Reason)
+	}
+	v.maxRunes("reason", req.Reason, maxRejectionReasonLength)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return req, nil, nil
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return req, nil, nil
+	}
+
+	storage.mutex.Lock()
+	complaint, apiErr := accessibleComplaint(user, req.ComplaintID, false, "Access denied. Only the submitter can answer a resolution")
+	if apiErr != nil {
+		storage.mutex.Unlock()
+		respondWithAPIError(w, apiErr)
+		return req, nil, nil
+	}
+	if apiErr := canAnswerForComplaint(actorOf(user), complaint).because("Access denied. Only the submitter can answer a resolution").apiError(); apiErr != nil {
+		storage.mutex.Unlock()
+		respondWithAPIError(w, apiErr)
+		return req, nil, nil
+	}
+	return req, user, complaint
+}
+
+// /confirmResolution - Agree that your own complaint was resolved, closing
+// it
+func confirmResolutionHandler(w http.ResponseWriter, r *http.Request) {
+	_, user, complaint := decodeResolutionAnswer(w, r, false)
+	if complaint == nil {
+		return
+	}
+	defer storage.mutex.Unlock()
+
+	if apiErr := transitionResolution(complaint, HistoryEntry{Action: historyResolutionConfirmed, ActorID: user.ID, CorrelationID: correlationID(r)}); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Resolution confirmed",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
+
+// /rejectResolution - Reopen your own complaint whose problem remains,
+// telling the admin who resolved it why
+func rejectResolutionHandler(w http.ResponseWriter, r *http.Request) {
+	req, user, complaint := decodeResolutionAnswer(w, r, true)
+	if complaint == nil {
+		return
+	}
+	defer storage.mutex.Unlock()
+
+	if apiErr := transitionResolution(complaint, HistoryEntry{Action: historyResolutionRejected, ActorID: user.ID, Detail: req.Reason, CorrelationID: correlationID(r)}); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	message := fmt.Sprintf("The submitter of %q rejected its resolution: %s", complaint.Title, req.Reason)
+	resolver := resolvedBy(complaint)
+	if _, exists := storage.users[resolver]; exists {
+		notifyUser(resolver, complaint, notificationResolutionRejected, message)
+	}
+	if complaint.AssignedTo != 0 && complaint.AssignedTo != resolver {
+		notifyUser(complaint.AssignedTo, complaint, notificationResolutionRejected, message)
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Resolution rejected; the complaint is open again",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
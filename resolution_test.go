@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolutionConfirmation(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC))
+	_, code := registerTestUser(t, srv, "Confirming User", "confirm@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+	adminID, adminCode := registerTestAdmin(t, srv, "Resolving Admin", "resolver@example.com")
+
+	resolve := func(id int) Complaint {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminCode, ComplaintID: id, Note: "Fixed the tap"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 resolving, got %d (%s)", status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		return complaint
+	}
+	view := func(id int) Complaint {
+		t.Helper()
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		return complaintForViewer(storage.users[adminID], *storage.complaints[id])
+	}
+	hasNotification := func(secret, notificationType string) bool {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/getNotifications", GetNotificationsRequest{SecretCode: secret})
+		var page NotificationPage
+		resp.decode(t, &page)
+		for _, notification := range page.Notifications {
+			if notification.Type == notificationType {
+				return true
+			}
+		}
+		return false
+	}
+	lastHistory := func(complaint Complaint) HistoryEntry {
+		if len(complaint.History) == 0 {
+			return HistoryEntry{}
+		}
+		return complaint.History[len(complaint.History)-1]
+	}
+
+	t.Run("Flag Off", func(t *testing.T) {
+		complaint := submitTestComplaint(t, srv, code, "Closed at once", 5)
+		resolved := resolve(complaint.ID)
+		if resolved.Status != statusResolved || resolved.PendingConfirmation || len(resolved.History) != 1 || resolved.History[0].Action != historyResolved {
+			t.Errorf("Expected a plain resolution, got %q with history %+v", resolved.Status, resolved.History)
+		}
+		if !hasNotification(code, notificationResolved) {
+			t.Error("Expected the usual resolved notification")
+		}
+		status, resp := postJSON(t, srv, "/confirmResolution", ResolutionAnswerRequest{SecretCode: code, ComplaintID: complaint.ID})
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeNotPendingConfirmation {
+			t.Errorf("Expected 409 NOT_PENDING_CONFIRMATION, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	config.ConfirmResolutions = true
+
+	t.Run("Confirm", func(t *testing.T) {
+		complaint := submitTestComplaint(t, srv, code, "Leaking tap", 5)
+		resolved := resolve(complaint.ID)
+		if resolved.Status != statusPendingConfirmation || !resolved.IsResolved {
+			t.Fatalf("Expected pending_confirmation, got %q", resolved.Status)
+		}
+		if entry := lastHistory(resolved); entry.Action != historyResolutionProposed || entry.ActorID != adminID || entry.Detail != "Fixed the tap" {
+			t.Errorf("Expected the proposal in history, got %+v", entry)
+		}
+		if !hasNotification(code, notificationConfirmResolution) {
+			t.Error("Expected the owner to be asked to confirm")
+		}
+
+		if status, _ := postJSON(t, srv, "/confirmResolution", ResolutionAnswerRequest{SecretCode: otherCode, ComplaintID: complaint.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for another user, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/confirmResolution", ResolutionAnswerRequest{SecretCode: adminCode, ComplaintID: complaint.ID}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for an admin, got %d", status)
+		}
+
+		status, resp := postJSON(t, srv, "/confirmResolution", ResolutionAnswerRequest{SecretCode: code, ComplaintID: complaint.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var confirmed Complaint
+		resp.decode(t, &confirmed)
+		if confirmed.Status != statusResolved || confirmed.Version != resolved.Version+1 {
+			t.Errorf("Expected resolved at version %d, got %q at %d", resolved.Version+1, confirmed.Status, confirmed.Version)
+		}
+		if entry := lastHistory(view(complaint.ID)); entry.Action != historyResolutionConfirmed {
+			t.Errorf("Expected the confirmation in history, got %+v", entry)
+		}
+
+		if status, _ := postJSON(t, srv, "/confirmResolution", ResolutionAnswerRequest{SecretCode: code, ComplaintID: complaint.ID}); status != http.StatusConflict {
+			t.Errorf("Expected 409 confirming twice, got %d", status)
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		complaint := submitTestComplaint(t, srv, code, "Broken heater", 5)
+		resolve(complaint.ID)
+
+		status, resp := postJSON(t, srv, "/rejectResolution", ResolutionAnswerRequest{SecretCode: code, ComplaintID: complaint.ID})
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("Expected 400 without a reason, got %d %s", status, resp.ErrorCode)
+		}
+
+		status, resp = postJSON(t, srv, "/rejectResolution", ResolutionAnswerRequest{SecretCode: code, ComplaintID: complaint.ID, Reason: "Still cold"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var reopened Complaint
+		resp.decode(t, &reopened)
+		if reopened.Status != statusOpen || reopened.IsResolved || reopened.ResolvedAt != "" {
+			t.Errorf("Expected the complaint open again, got %+v", reopened)
+		}
+		if entry := lastHistory(view(complaint.ID)); entry.Action != historyResolutionRejected || entry.Detail != "Still cold" {
+			t.Errorf("Expected the rejection in history, got %+v", entry)
+		}
+		if !hasNotification(adminCode, notificationResolutionRejected) {
+			t.Error("Expected the resolving admin to be told")
+		}
+
+		// Resolving again asks again
+		if again := resolve(complaint.ID); again.Status != statusPendingConfirmation {
+			t.Errorf("Expected pending_confirmation again, got %q", again.Status)
+		}
+	})
+
+	t.Run("Auto-confirm", func(t *testing.T) {
+		complaint := submitTestComplaint(t, srv, code, "Flickering light", 5)
+		resolve(complaint.ID)
+
+		fake.Advance(time.Duration(config.AutoConfirmAfterDays)*24*time.Hour - time.Hour)
+		if confirmed := confirmPendingResolutions(context.Background(), fake.Now()); confirmed != 0 {
+			t.Errorf("Expected nothing confirmed early, got %d", confirmed)
+		}
+
+		fake.Advance(time.Hour)
+		// The earlier rejected-then-resolved complaint is due as well
+		if confirmed := confirmPendingResolutions(context.Background(), fake.Now()); confirmed != 2 {
+			t.Errorf("Expected two confirmations, got %d", confirmed)
+		}
+		shown := view(complaint.ID)
+		if shown.Status != statusResolved {
+			t.Errorf("Expected resolved, got %q", shown.Status)
+		}
+		if entry := lastHistory(shown); entry.Action != historyResolutionConfirmed || entry.ActorID != 0 {
+			t.Errorf("Expected a confirmation by the server, got %+v", entry)
+		}
+		if confirmed := confirmPendingResolutions(context.Background(), fake.Now()); confirmed != 0 {
+			t.Errorf("Expected a second run to do nothing, got %d", confirmed)
+		}
+	})
+}
+
+func TestResolvedBy(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Owner", "owner@example.com")
+	firstID, firstCode := registerTestAdmin(t, srv, "First Resolver", "first@example.com")
+	secondID, secondCode := registerTestAdmin(t, srv, "Second Resolver", "second@example.com")
+	first := submitTestComplaint(t, srv, code, "Resolved by the first", 5)
+	second := submitTestComplaint(t, srv, code, "Resolved by the second", 5)
+
+	resolve := func(secret string, id int) Complaint {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: secret, ComplaintID: id, Note: "Done"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 resolving %d, got %d (%s)", id, status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		return complaint
+	}
+	view := func(secret string, id int) (Complaint, string) {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: secret, ComplaintID: id})
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		return complaint, string(resp.Data)
+	}
+	checkResolver := func(where string, complaint Complaint, id int, name string) {
+		t.Helper()
+		if complaint.ResolvedBy != id || complaint.ResolvedByName != name {
+			t.Errorf("%s: expected resolved by %d %q, got %d %q", where, id, name, complaint.ResolvedBy, complaint.ResolvedByName)
+		}
+	}
+
+	t.Run("Attribution", func(t *testing.T) {
+		checkResolver("resolve response", resolve(firstCode, first.ID), firstID, "First Resolver")
+		resolve(secondCode, second.ID)
+
+		viewed, _ := view(adminSecret, first.ID)
+		checkResolver("view", viewed, firstID, "First Resolver")
+		if entry := viewed.History[len(viewed.History)-1]; entry.Action != historyResolved || entry.ActorID != firstID || entry.Detail != "Done" {
+			t.Errorf("Expected a resolved history entry by %d, got %+v", firstID, entry)
+		}
+
+		var listed []Complaint
+		_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret})
+		resp.decode(t, &listed)
+		byID := map[int]Complaint{}
+		for _, complaint := range listed {
+			byID[complaint.ID] = complaint
+		}
+		checkResolver("listing", byID[first.ID], firstID, "First Resolver")
+		checkResolver("listing", byID[second.ID], secondID, "Second Resolver")
+
+		for _, line := range exportLines(t, srv.URL+"/exportComplaints?format=ndjson&secret_code="+adminSecret) {
+			switch line.ID {
+			case first.ID:
+				checkResolver("export", line.Complaint, firstID, "First Resolver")
+			case second.ID:
+				checkResolver("export", line.Complaint, secondID, "Second Resolver")
+			}
+		}
+	})
+
+	t.Run("Admins Only", func(t *testing.T) {
+		if _, raw := view(code, first.ID); strings.Contains(raw, "resolved_by") {
+			t.Errorf("Expected the owner not to see who resolved it, got %s", raw)
+		}
+	})
+
+	t.Run("Reopen Clears", func(t *testing.T) {
+		config.ConfirmResolutions = true
+		defer func() { config.ConfirmResolutions = false }()
+		complaint := submitTestComplaint(t, srv, code, "Rejected", 5)
+		checkResolver("proposal", resolve(secondCode, complaint.ID), secondID, "Second Resolver")
+		if status, resp := postJSON(t, srv, "/rejectResolution", ResolutionAnswerRequest{SecretCode: code, ComplaintID: complaint.ID, Reason: "Still broken"}); status != http.StatusOK {
+			t.Fatalf("Expected 200 rejecting, got %d (%s)", status, resp.Error)
+		}
+		reopened, raw := view(adminSecret, complaint.ID)
+		checkResolver("reopened", reopened, 0, "")
+		if strings.Contains(raw, "resolved_by") {
+			t.Errorf("Expected no resolver fields once reopened, got %s", raw)
+		}
+	})
+
+	t.Run("Backfilled", func(t *testing.T) {
+		if _, err := loadFixture(Fixture{
+			Users:      []FixtureUser{{ID: 1, Name: "Seeded User", Email: "seeded@example.com"}},
+			Complaints: []FixtureComplaint{{UserID: 1, Title: "Seeded", Summary: "Resolved long ago", Rating: 3, IsResolved: true}},
+		}); err != nil {
+			t.Fatalf("Failed to load fixture: %v", err)
+		}
+		storage.mutex.RLock()
+		id := storage.compIDGen
+		storage.mutex.RUnlock()
+		seeded, raw := view(adminSecret, id)
+		if !seeded.IsResolved || strings.Contains(raw, "resolved_by") {
+			t.Errorf("Expected a resolved complaint without resolver fields, got %s", raw)
+		}
+	})
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type RetentionPreviewRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+// RetentionCategory is what one retention policy removes on the next run
+type RetentionCategory struct {
+	RetainDays int    `json:"retain_days"`      // 0 keeps records forever
+	Cutoff     string `json:"cutoff,omitempty"` // records from this time or before go; empty when kept forever
+	Count      int    `json:"count"`
+	// IDs are of the complaints, audit entries or notifications that go;
+	// for login history, of the users whose entries go
+	IDs []int `json:"ids"`
+
+	cutoff time.Time
+}
+
+// RetentionPreview is what the retention job would remove if it ran now.
+// The job removes exactly what planRetention returns, so a preview taken
+// under the same lock is what a run removes.
+type RetentionPreview struct {
+	ResolvedComplaints RetentionCategory `json:"resolved_complaints"`
+	AuditLog           RetentionCategory `json:"audit_log"`
+	Notifications      RetentionCategory `json:"notifications"`
+	LoginHistory       RetentionCategory `json:"login_history"`
+	Total              int               `json:"total"`
+}
+
+// retentionCategory starts a category for a policy of days, as of now
+func retentionCategory(days int, now time.Time) RetentionCategory {
+	category := RetentionCategory{RetainDays: days, IDs: []int{}}
+	if days > 0 {
+		category.cutoff = now.AddDate(0, 0, -days)
+		category.Cutoff = category.cutoff.Local().Format(timestampLayout)
+	}
+	return category
+}
+
+// expired reports whether a record stamped at is past category's cutoff.
+// Records with a timestamp that cannot be read are kept.
+func (c *RetentionCategory) expired(at string) bool {
+	if c.RetainDays <= 0 {
+		return false
+	}
+	t, err := parseTimestamp(at)
+	return err == nil && !t.After(c.cutoff)
+}
+
+// planRetention works out what each retention policy removes as of now.
+// Callers must hold storage.mutex; it takes storage.loginMutex itself.
+func planRetention(now time.Time) RetentionPreview {
+	plan := RetentionPreview{
+		ResolvedComplaints: retentionCategory(config.RetainResolvedDays, now),
+		AuditLog:           retentionCategory(config.RetainAuditDays, now),
+		Notifications:      retentionCategory(config.RetainNotificationsDays, now),
+		LoginHistory:       retentionCategory(config.RetainLoginHistoryDays, now),
+	}
+
+	expired := map[int]bool{}
+	for id, complaint := range storage.complaints {
+		if complaint.IsResolved && !complaint.PendingConfirmation && plan.ResolvedComplaints.expired(complaint.ResolvedAt) {
+			expired[id] = true
+		}
+	}
+	// A merge goes whole or not at all, so no complaint is left pointing at
+	// one that is gone
+	for changed := true; changed; {
+		changed = false
+		for id := range expired {
+			complaint := storage.complaints[id]
+			keep := complaint.MergedInto != 0 && !expired[complaint.MergedInto]
+			for _, duplicate := range complaint.Duplicates {
+				keep = keep || !expired[duplicate]
+			}
+			if keep {
+				delete(expired, id)
+				changed = true
+			}
+		}
+	}
+	for id := range expired {
+		plan.ResolvedComplaints.IDs = append(plan.ResolvedComplaints.IDs, id)
+	}
+
+	for _, entry := range storage.auditLog {
+		if plan.AuditLog.expired(entry.At) {
+			plan.AuditLog.IDs = append(plan.AuditLog.IDs, entry.ID)
+		}
+	}
+
+	for _, inbox := range storage.notifications {
+		for _, notification := range inbox {
+			if plan.Notifications.expired(notification.CreatedAt) {
+				plan.Notifications.IDs = append(plan.Notifications.IDs, notification.ID)
+			}
+		}
+	}
+
+	storage.loginMutex.Lock()
+	for userID, ring := range storage.loginHistory {
+		count := 0
+		for _, entry := range ring.newestFirst(0) {
+			if plan.LoginHistory.expired(entry.At) {
+				count++
+			}
+		}
+		if count > 0 {
+			plan.LoginHistory.IDs = append(plan.LoginHistory.IDs, userID)
+			plan.LoginHistory.Count += count
+		}
+	}
+	storage.loginMutex.Unlock()
+
+	for _, category := range []*RetentionCategory{&plan.ResolvedComplaints, &plan.AuditLog, &plan.Notifications} {
+		category.Count = len(category.IDs)
+	}
+	for _, category := range []*RetentionCategory{&plan.ResolvedComplaints, &plan.AuditLog, &plan.Notifications, &plan.LoginHistory} {
+		sort.Ints(category.IDs)
+		plan.Total += category.Count
+	}
+	return plan
+}
+
+// applyRetention removes what plan lists. Callers must hold storage.mutex
+// for writing, and must not have let go of it since planning.
+func applyRetention(plan RetentionPreview) {
+	for _, id := range plan.ResolvedComplaints.IDs {
+		purgeComplaint(storage.complaints[id])
+	}
+
+	if plan.AuditLog.Count > 0 {
+		drop := idSet(plan.AuditLog.IDs)
+		kept := make([]AuditEntry, 0, len(storage.auditLog)-plan.AuditLog.Count)
+		for _, entry := range storage.auditLog {
+			if !drop[entry.ID] {
+				kept = append(kept, entry)
+			}
+		}
+		storage.auditLog = kept
+	}
+
+	if plan.Notifications.Count > 0 {
+		drop := idSet(plan.Notifications.IDs)
+		removeNotifications(func(notification *Notification) bool { return drop[notification.ID] })
+	}
+
+	if plan.LoginHistory.Count > 0 {
+		storage.loginMutex.Lock()
+		for _, userID := range plan.LoginHistory.IDs {
+			entries := storage.loginHistory[userID].newestFirst(0)
+			ring := &loginRing{}
+			for i := len(entries) - 1; i >= 0; i-- {
+				if !plan.LoginHistory.expired(entries[i].At) {
+					ring.add(entries[i])
+				}
+			}
+			if ring.size == 0 {
+				delete(storage.loginHistory, userID)
+			} else {
+				storage.loginHistory[userID] = ring
+			}
+		}
+		storage.loginMutex.Unlock()
+	}
+}
+
+func idSet(ids []int) map[int]bool {
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// purgeComplaint permanently removes complaint, its links and the
+// notifications about it. IDs are never reused since compIDGen only grows.
+// Callers must hold storage.mutex for writing.
+func purgeComplaint(complaint *Complaint) {
+	unlinkAll(complaint)
+	delete(storage.complaints, complaint.ID)
+	delete(storage.references, complaint.Reference)
+	removeComplaintNotifications(complaint.ID)
+
+	storage.usersMutex.Lock()
+	defer storage.usersMutex.Unlock()
+	owner, exists := storage.users[complaint.UserID]
+	if !exists {
+		return
+	}
+	// Build a new slice so responses already holding the old one are unaffected
+	updated := make([]Complaint, 0, len(owner.Complaints))
+	for _, existing := range owner.Complaints {
+		if existing.ID != complaint.ID {
+			updated = append(updated, existing)
+		}
+	}
+	owner.Complaints = updated
+}
+
+// enforceRetention removes every record past its retention policy and
+// records what it removed in the audit log
+func enforceRetention(ctx context.Context, now time.Time) int {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	plan := planRetention(now)
+	if plan.Total == 0 {
+		return 0
+	}
+	applyRetention(plan)
+
+	loggerFrom(ctx).Info("Applied retention policies",
+		"resolved_complaints", plan.ResolvedComplaints.Count, "complaint_ids", plan.ResolvedComplaints.IDs,
+		"audit_log", plan.AuditLog.Count,
+		"notifications", plan.Notifications.Count,
+		"login_history", plan.LoginHistory.Count)
+	// After the purge, so the entry outlives this run
+	recordAudit(AuditEntry{
+		Action: auditRetentionPurge,
+		Detail: fmt.Sprintf("Removed %d resolved complaints, %d audit log entries, %d notifications and %d login history entries",
+			plan.ResolvedComplaints.Count, plan.AuditLog.Count, plan.Notifications.Count, plan.LoginHistory.Count),
+	})
+	return plan.Total
+}
+
+// /retentionPreview - What the retention job would remove if it ran now
+// (super-admin only)
+func retentionPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RetentionPreviewRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	// Retention spans every organization
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	plan := planRetention(clock.Now())
+	storage.mutex.RUnlock()
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("The next retention run would remove %d records", plan.Total),
+		Data:    plan,
+	})
+}
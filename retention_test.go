@@ -0,0 +1,220 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetention(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.Local)
+	fake := useFakeClock(t, start)
+	config.RetainResolvedDays = 730
+	config.RetainAuditDays = 1825
+	config.RetainNotificationsDays = 30
+	config.RetainLoginHistoryDays = 90
+
+	userID, code := registerTestUser(t, srv, "Retention User", "retention@example.com")
+	old := submitTestComplaint(t, srv, code, "Resolved first", 4)
+	linked := submitTestComplaint(t, srv, code, "Linked to the first", 5)
+	primary := submitTestComplaint(t, srv, code, "Primary of a merge", 6)
+	duplicate := submitTestComplaint(t, srv, code, "Duplicate of the primary", 6)
+	postJSON(t, srv, "/linkComplaints", LinkComplaintsRequest{SecretCode: adminSecret, ComplaintID: old.ID, RelatedID: linked.ID})
+	postJSON(t, srv, "/mergeComplaints", MergeComplaintsRequest{SecretCode: adminSecret, PrimaryID: primary.ID, DuplicateIDs: []int{duplicate.ID}})
+	for _, id := range []int{old.ID, primary.ID} {
+		if status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: id}); status != http.StatusOK {
+			t.Fatalf("Expected 200 resolving %d, got %d (%s)", id, status, resp.Error)
+		}
+	}
+	// A primary resolved after its duplicate, as older data can have
+	storage.mutex.Lock()
+	storage.complaints[primary.ID].ResolvedAt = start.AddDate(0, 0, 10).Format(timestampLayout)
+	storage.mutex.Unlock()
+
+	preview := func() RetentionPreview {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/retentionPreview", RetentionPreviewRequest{SecretCode: adminSecret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var plan RetentionPreview
+		resp.decode(t, &plan)
+		return plan
+	}
+	runJob := func() int {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/runJobs", RunJobsRequest{SecretCode: adminSecret, Job: "enforce_retention"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200 running the retention job, got %d (%s)", status, resp.Error)
+		}
+		var results []JobResult
+		resp.decode(t, &results)
+		return results[0].Affected
+	}
+	complaintExists := func(id int) bool {
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		return storage.complaints[id] != nil
+	}
+	countNotifications := func() int {
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		count := 0
+		for _, inbox := range storage.notifications {
+			count += len(inbox)
+		}
+		return count
+	}
+	countLogins := func() int {
+		storage.loginMutex.Lock()
+		defer storage.loginMutex.Unlock()
+		count := 0
+		for _, ring := range storage.loginHistory {
+			count += ring.size
+		}
+		return count
+	}
+	auditLog := func() []AuditEntry {
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		return append([]AuditEntry{}, storage.auditLog...)
+	}
+	equalIDs := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("Nothing Expired Yet", func(t *testing.T) {
+		fake.Advance(30*24*time.Hour - time.Minute)
+		if plan := preview(); plan.Total != 0 {
+			t.Errorf("Expected nothing to remove, got %+v", plan)
+		}
+		if removed := runJob(); removed != 0 {
+			t.Errorf("Expected the job to remove nothing, got %d", removed)
+		}
+		if entries := auditLog(); len(entries) != 0 {
+			t.Errorf("Expected no audit entry for an empty run, got %+v", entries)
+		}
+	})
+
+	t.Run("Notifications And Logins", func(t *testing.T) {
+		fake.Advance(61 * 24 * time.Hour) // a minute short of 91 days in
+		notifications, logins := countNotifications(), countLogins()
+		plan := preview()
+		if plan.ResolvedComplaints.Count != 0 || plan.Notifications.Count == 0 || plan.LoginHistory.Count == 0 {
+			t.Fatalf("Expected only notifications and logins to expire, got %+v", plan)
+		}
+		if removed := runJob(); removed != plan.Total {
+			t.Errorf("Expected the job to remove the %d records previewed, got %d", plan.Total, removed)
+		}
+		if got := countNotifications(); got != notifications-plan.Notifications.Count {
+			t.Errorf("Expected %d notifications left, got %d", notifications-plan.Notifications.Count, got)
+		}
+		if got := countLogins(); got != logins-plan.LoginHistory.Count {
+			t.Errorf("Expected %d login entries left, got %d", logins-plan.LoginHistory.Count, got)
+		}
+		if entries := auditLog(); len(entries) != 1 || entries[0].Action != auditRetentionPurge || entries[0].ActorID != 0 {
+			t.Errorf("Expected one retention audit entry, got %+v", entries)
+		}
+	})
+
+	t.Run("Resolved Complaints", func(t *testing.T) {
+		fake.Advance((730 - 91) * 24 * time.Hour)
+		if plan := preview(); plan.ResolvedComplaints.Count != 0 {
+			t.Fatalf("Expected nothing a minute before the 2 years are up, got %+v", plan.ResolvedComplaints)
+		}
+		fake.Advance(time.Minute)
+		plan := preview()
+		// The duplicate stays with its primary, resolved 10 days later
+		if !equalIDs(plan.ResolvedComplaints.IDs, []int{old.ID}) {
+			t.Fatalf("Expected only complaint %d to expire, got %+v", old.ID, plan.ResolvedComplaints)
+		}
+		if again := preview(); !equalIDs(again.ResolvedComplaints.IDs, plan.ResolvedComplaints.IDs) || again.Total != plan.Total {
+			t.Errorf("Expected previews to change nothing, got %+v", again)
+		}
+		if removed := runJob(); removed != plan.Total {
+			t.Errorf("Expected the job to remove the %d records previewed, got %d", plan.Total, removed)
+		}
+		if complaintExists(old.ID) || !complaintExists(duplicate.ID) || !complaintExists(primary.ID) {
+			t.Error("Expected only the expired complaint removed")
+		}
+		if status, _ := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: code, ComplaintID: old.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for the purged complaint, got %d", status)
+		}
+		storage.mutex.RLock()
+		related := storage.complaints[linked.ID].Related
+		storage.mutex.RUnlock()
+		if len(related) != 0 {
+			t.Errorf("Expected the link to the purged complaint gone, got %v", related)
+		}
+
+		fake.Advance(10 * 24 * time.Hour)
+		plan = preview()
+		if !equalIDs(plan.ResolvedComplaints.IDs, []int{primary.ID, duplicate.ID}) {
+			t.Fatalf("Expected the merge to expire whole, got %+v", plan.ResolvedComplaints)
+		}
+		runJob()
+		if complaintExists(primary.ID) || complaintExists(duplicate.ID) {
+			t.Error("Expected the merge removed")
+		}
+	})
+
+	t.Run("IDs Not Reused", func(t *testing.T) {
+		fresh := submitTestComplaint(t, srv, code, "After the purge", 5)
+		if fresh.ID <= duplicate.ID {
+			t.Errorf("Expected a new ID after %d, got %d", duplicate.ID, fresh.ID)
+		}
+		var complaints []Complaint
+		_, resp := postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: code})
+		resp.decode(t, &complaints)
+		if len(complaints) != 2 || complaints[0].ID != linked.ID || complaints[1].ID != fresh.ID {
+			t.Errorf("Expected the owner to keep only the unpurged complaints, got %+v", complaints)
+		}
+		storage.usersMutex.RLock()
+		owned := storage.users[userID].Complaints
+		storage.usersMutex.RUnlock()
+		if len(owned) != 2 {
+			t.Errorf("Expected the purged complaints gone from the owner's list, got %d", len(owned))
+		}
+	})
+
+	t.Run("Audit Log", func(t *testing.T) {
+		entries := auditLog()
+		if len(entries) != 3 {
+			t.Fatalf("Expected an audit entry per run that removed something, got %+v", entries)
+		}
+		// 5 years after the first run, only its entry has expired
+		first, _ := parseTimestamp(entries[0].At)
+		fake.Advance(first.AddDate(0, 0, 1825).Sub(fake.Now()))
+		plan := preview()
+		if !equalIDs(plan.AuditLog.IDs, []int{entries[0].ID}) {
+			t.Fatalf("Expected only audit entry %d to expire, got %+v", entries[0].ID, plan.AuditLog)
+		}
+		runJob()
+		after := auditLog()
+		if len(after) != 3 || after[0].ID != entries[1].ID || after[2].ID != entries[2].ID+1 {
+			t.Errorf("Expected the oldest entry replaced by this run's, got %+v", after)
+		}
+	})
+
+	t.Run("Backup", func(t *testing.T) {
+		if status, resp := restoreBackup(t, srv, fetchBackup(t, srv)); status != http.StatusOK {
+			t.Errorf("Expected the purged state to restore, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Super Admin Only", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/retentionPreview", RetentionPreviewRequest{SecretCode: code}); status != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type RotateSecretCodeRequest struct {
+	SecretCode string `json:"secret_code"`
+	UserID     int    `json:"user_id,omitempty"` // admin only: rotate another user's code
+}
+
+type RotateSecretCodeResponse struct {
+	UserID     int    `json:"user_id" xml:"user_id"`
+	SecretCode string `json:"secret_code" xml:"secret_code"`
+}
+
+var (
+	errInvalidSecretCode = errors.New("invalid secret code")
+	errUserNotFound      = errors.New("user not found")
+)
+
+// generateRandomSecretCode builds a secret code from crypto/rand bytes so it
+// cannot be guessed from the registration time or user ID
+func generateRandomSecretCode() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "SEC_" + hex.EncodeToString(buf), nil
+}
+
+// rotateSecretCode swaps targetID's secret code for a new random one. The
+// caller's secret is re-checked under the write lock so that concurrent
+// rotations using the same old code cannot both succeed.
+func rotateSecretCode(callerSecret string, callerID, targetID int) (string, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if !credentialBelongsTo(callerSecret, callerID) {
+		return "", errInvalidSecretCode
+	}
+
+	target := scopedUser(storage.users[callerID], targetID)
+	if target == nil {
+		return "", errUserNotFound
+	}
+
+	newCode, err := generateRandomSecretCode()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, taken := storage.secretIndex[newCode]; !taken {
+			break
+		}
+		if newCode, err = generateRandomSecretCode(); err != nil {
+			return "", err
+		}
+	}
+
+	storage.usersMutex.Lock()
+	delete(storage.secretIndex, target.SecretCode)
+	target.SecretCode = newCode
+	storage.secretIndex[newCode] = target.ID
+	revokeCredentials(target)
+	storage.usersMutex.Unlock()
+
+	return newCode, nil
+}
+
+// /rotateSecretCode - Replace a secret code with a new random one
+func rotateSecretCodeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RotateSecretCodeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.UserID < 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid user ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, canActOnUser(actorOf(user), req.UserID)) {
+		return
+	}
+	targetID := user.ID
+	if req.UserID != 0 {
+		targetID = req.UserID
+	}
+
+	newCode, err := rotateSecretCode(req.SecretCode, user.ID, targetID)
+	switch {
+	case errors.Is(err, errInvalidSecretCode):
+		respondWithError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid secret code")
+		return
+	case errors.Is(err, errUserNotFound):
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate secret code")
+		return
+	}
+	wsHub.closeUser(targetID, "Secret code rotated")
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Secret code rotated successfully. Store it now, it will not be shown again",
+		Data: RotateSecretCodeResponse{
+			UserID:     targetID,
+			SecretCode: newCode,
+		},
+	})
+}
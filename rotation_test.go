@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestRotateSecretCode(t *testing.T) {
+	srv := newTestServer(t)
+	_, oldCode := registerTestUser(t, srv, "Rotating User", "rotate@example.com")
+
+	status, resp := postJSON(t, srv, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: oldCode})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+	}
+	var rotated RotateSecretCodeResponse
+	resp.decode(t, &rotated)
+	if rotated.SecretCode == "" || rotated.SecretCode == oldCode {
+		t.Fatalf("Expected a new secret code, got %q", rotated.SecretCode)
+	}
+
+	t.Run("Old Code Rejected", func(t *testing.T) {
+		status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: oldCode})
+		if status != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", status)
+		}
+		status, _ = postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: oldCode, Title: "Stale", Summary: "Using the old code", Rating: 3,
+		})
+		if status != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", status)
+		}
+	})
+
+	t.Run("New Code Accepted", func(t *testing.T) {
+		status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: rotated.SecretCode})
+		if status != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", status)
+		}
+	})
+}
+
+func TestAdminForceRotateSecretCode(t *testing.T) {
+	srv := newTestServer(t)
+	userID, userCode := registerTestUser(t, srv, "Leaky User", "leaky@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+
+	t.Run("Non-admin Forbidden", func(t *testing.T) {
+		status, _ := postJSON(t, srv, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: otherCode, UserID: userID})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", status)
+		}
+	})
+
+	t.Run("Unknown User", func(t *testing.T) {
+		status, _ := postJSON(t, srv, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: "ADMIN_SECRET_123", UserID: 999})
+		if status != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", status)
+		}
+	})
+
+	status, resp := postJSON(t, srv, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: "ADMIN_SECRET_123", UserID: userID})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+	}
+	var rotated RotateSecretCodeResponse
+	resp.decode(t, &rotated)
+	if rotated.UserID != userID {
+		t.Errorf("Expected user ID %d, got %d", userID, rotated.UserID)
+	}
+
+	if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: userCode}); status != http.StatusUnauthorized {
+		t.Errorf("Expected old code to get 401, got %d", status)
+	}
+	if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: rotated.SecretCode}); status != http.StatusOK {
+		t.Errorf("Expected new code to get 200, got %d", status)
+	}
+	if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: "ADMIN_SECRET_123"}); status != http.StatusOK {
+		t.Errorf("Expected admin code to stay valid, got %d", status)
+	}
+}
+
+func TestConcurrentSecretCodeRotation(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Racy User", "racy@example.com")
+	registerTestUser(t, srv, "Bystander", "bystander@example.com")
+
+	const workers = 20
+	var wg sync.WaitGroup
+	statuses := make(chan int, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := postJSON(t, srv, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: code})
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	succeeded := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			succeeded++
+		case http.StatusUnauthorized, http.StatusLocked:
+			// Losers hold a stale code; enough of them trip the lockout
+		default:
+			t.Errorf("Unexpected status %d", status)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one rotation to win, got %d", succeeded)
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if len(storage.secretIndex) != len(storage.users) {
+		t.Errorf("Expected %d secret index entries, got %d", len(storage.users), len(storage.secretIndex))
+	}
+	for _, user := range storage.users {
+		if storage.secretIndex[user.SecretCode] != user.ID {
+			t.Errorf("Secret index does not map user %d's current code", user.ID)
+		}
+	}
+}
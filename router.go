@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// pathParams holds the values of a route's {name} segments
+type pathParams map[string]string
+
+// int returns param name as a positive integer, or false when it is not one
+func (p pathParams) int(name string) (int, bool) {
+	n, err := strconv.Atoi(p[name])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+type paramHandler func(w http.ResponseWriter, r *http.Request, params pathParams)
+
+type route struct {
+	method   string
+	pattern  string
+	segments []string
+	handler  paramHandler
+}
+
+// pathRouter dispatches on method and path, where a pattern segment written
+// as {name} matches any single path segment. Routes are tried in the order
+// they were added.
+type pathRouter struct {
+	routes []route
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func (pr *pathRouter) handle(method, pattern string, handler paramHandler) {
+	pr.routes = append(pr.routes, route{method: method, pattern: pattern, segments: splitPath(pattern), handler: handler})
+}
+
+// match returns the params when path fits segments
+func (rt route) match(path []string) (pathParams, bool) {
+	if len(path) != len(rt.segments) {
+		return nil, false
+	}
+	params := pathParams{}
+	for i, segment := range rt.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if path[i] == "" {
+				return nil, false
+			}
+			params[segment[1:len(segment)-1]] = path[i]
+			continue
+		}
+		if segment != path[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// ServeHTTP answers 404 when no route fits the path, and 405 with an Allow
+// header when some do but not for this method
+func (pr *pathRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := splitPath(r.URL.Path)
+
+	var allowed []string
+	for _, rt := range pr.routes {
+		params, ok := rt.match(path)
+		if !ok {
+			continue
+		}
+		if rt.method == r.Method {
+			setRouteLabel(r, rt.pattern)
+			rt.handler(w, r, params)
+			return
+		}
+		if len(allowed) == 0 {
+			setRouteLabel(r, rt.pattern)
+		}
+		allowed = append(allowed, rt.method)
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Endpoint not found")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Maximum lengths of user-supplied text, in runes
+const (
+	maxTitleLength   = 200
+	maxSummaryLength = 5000
+)
+
+// htmlTagPattern matches anything that looks like an HTML tag, comment or
+// doctype, including one left unclosed at the end of the text
+var htmlTagPattern = regexp.MustCompile(`<[a-zA-Z/!?][^>]*(>|$)`)
+
+// sanitizeText makes user-supplied text safe to store and display: control
+// characters and HTML tags are removed and whitespace runs collapse to a
+// single space. With multiline, line breaks survive, with at most one blank
+// line in a row; otherwise they become spaces.
+func sanitizeText(text string, multiline bool) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var cleaned strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\n' && multiline:
+			cleaned.WriteRune(r)
+		case unicode.IsSpace(r):
+			cleaned.WriteRune(' ')
+		case unicode.IsControl(r) || r == utf8.RuneError:
+			// dropped
+		default:
+			cleaned.WriteRune(r)
+		}
+	}
+	text = cleaned.String()
+
+	// Removing one tag can expose another, as in "<<b>script>"
+	for {
+		stripped := htmlTagPattern.ReplaceAllString(text, "")
+		if stripped == text {
+			break
+		}
+		text = stripped
+	}
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkLength reports an error naming field when value is longer than max
+// runes
+func checkLength(field, value string, max int) error {
+	if utf8.RuneCountInString(value) > max {
+		return fmt.Errorf("%s must be at most %d characters", field, max)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		multiline bool
+		want      string
+	}{
+		{"plain", "Broken heater", false, "Broken heater"},
+		{"script tag", "<script>alert(1)</script>Heater", false, "alert(1)Heater"},
+		{"img onerror", `Hi <img src=x onerror="alert(1)"> there`, false, "Hi there"},
+		{"unclosed tag", "Broken <img src=x onerror=alert(1)", false, "Broken"},
+		{"nested tags", "<<b>script>alert(1)<</b>/script>", false, "alert(1)"},
+		{"comment", "Before<!-- hidden -->After", false, "BeforeAfter"},
+		{"less than", "5 < 6 and 7 > 3", false, "5 < 6 and 7 > 3"},
+		{"nul byte", "Heat\x00er", false, "Heater"},
+		{"nul inside tag", "<\x00script>alert(1)", false, "alert(1)"},
+		{"control characters", "Bell\x07 and escape\x1b[31m", false, "Bell and escape[31m"},
+		{"whitespace runs", "  Too \t\t many   spaces  ", false, "Too many spaces"},
+		{"newlines in title", "Line one\nLine two\r\nLine three", false, "Line one Line two Line three"},
+		{"newlines in summary", "Line one\r\nLine two\n\n\n\nLine three\n", true, "Line one\nLine two\n\nLine three"},
+		{"emoji", "Elevator 🛗 broken 😡😡", false, "Elevator 🛗 broken 😡😡"},
+		{"zwj emoji", "Family 👨‍👩‍👧", false, "Family 👨‍👩‍👧"},
+		{"combining characters", "Cafe\u0301 re\u0301sume\u0301", false, "Cafe\u0301 re\u0301sume\u0301"},
+		{"non-breaking space", "Room\u00a0\u00a012", false, "Room 12"},
+		{"only markup", "<b></b>", false, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sanitizeText(tc.input, tc.multiline); got != tc.want {
+				t.Errorf("sanitizeText(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSubmitSanitizesAndLimits(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Sanitized User", "sanitized@example.com")
+
+	submit := func(title, summary string) (int, testResponse) {
+		return postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: code, Title: title, Summary: summary, Rating: 5,
+		})
+	}
+
+	status, resp := submit(`<img src=x onerror="alert(1)">Broken   door`, "It\x00 squeaks\n\n\n\nloudly")
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d (%s)", status, resp.Error)
+	}
+	var complaint Complaint
+	resp.decode(t, &complaint)
+	if complaint.Title != "Broken door" || complaint.Summary != "It squeaks\n\nloudly" {
+		t.Errorf("Expected sanitized text, got %q / %q", complaint.Title, complaint.Summary)
+	}
+
+	tests := []struct {
+		name    string
+		title   string
+		summary string
+		status  int
+		message string
+	}{
+		{"markup only title", "<b></b>", "Summary", http.StatusBadRequest, "Title is required"},
+		{"title at limit in emoji", strings.Repeat("😡", maxTitleLength), "Summary", http.StatusCreated, ""},
+		{"title over limit", strings.Repeat("a", maxTitleLength+1), "Summary", http.StatusBadRequest, "Title must be at most 200 characters"},
+		{"summary at limit with combining marks", "Title", strings.Repeat("e\u0301", maxSummaryLength/2), http.StatusCreated, ""},
+		{"summary over limit", "Title", strings.Repeat("e\u0301", maxSummaryLength/2+1), http.StatusBadRequest, "Summary must be at most 5000 characters"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status, resp := submit(tc.title, tc.summary)
+			if status != tc.status {
+				t.Fatalf("Expected status %d, got %d (%s)", tc.status, status, resp.Error)
+			}
+			if tc.message != "" && resp.Error != tc.message {
+				t.Errorf("Expected error %q, got %q", tc.message, resp.Error)
+			}
+		})
+	}
+}
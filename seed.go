@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSeedComplaintsPerUser caps the Zipf-distributed complaint count of a
+// generated user
+const maxSeedComplaintsPerUser = 50
+
+// Fixture is a dataset for demos and load tests. IDs in a fixture are local
+// to it: complaints name their submitter by the fixture user's id, and
+// everything gets fresh IDs when loaded.
+type Fixture struct {
+	Users      []FixtureUser      `json:"users"`
+	Complaints []FixtureComplaint `json:"complaints"`
+}
+
+type FixtureUser struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	SecretCode string `json:"secret_code,omitempty"` // generated when empty
+	IsAdmin    bool   `json:"is_admin,omitempty"`
+}
+
+type FixtureComplaint struct {
+	UserID         int    `json:"user_id"` // a FixtureUser id
+	Title          string `json:"title"`
+	Summary        string `json:"summary"`
+	Rating         int    `json:"rating"`
+	Priority       string `json:"priority,omitempty"`
+	CreatedAt      string `json:"created_at,omitempty"` // now when empty
+	IsResolved     bool   `json:"is_resolved,omitempty"`
+	ResolvedAt     string `json:"resolved_at,omitempty"` // now when resolved and empty
+	ResolutionNote string `json:"resolution_note,omitempty"`
+}
+
+// SeededUser is a user created by a seed, with the secret code to log in as
+type SeededUser struct {
+	FixtureID  int    `json:"fixture_id"`
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	SecretCode string `json:"secret_code"`
+}
+
+type SeedResult struct {
+	Users      []SeededUser `json:"users"`
+	Complaints int          `json:"complaints"`
+}
+
+type SeedRequest struct {
+	SecretCode string   `json:"secret_code"`
+	Fixture    *Fixture `json:"fixture,omitempty"`
+	Random     int      `json:"random,omitempty"`      // generate this many users instead
+	RandomSeed int64    `json:"random_seed,omitempty"` // seed for Random; the same seed gives the same data
+}
+
+// loadFixture adds fixture to storage. The whole fixture is checked first,
+// so a bad one loads nothing.
+func loadFixture(fixture Fixture) (SeedResult, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	now := clock.Now()
+	emails := map[string]bool{}
+	for _, user := range storage.users {
+		emails[user.Email] = true
+	}
+	secrets := map[string]bool{}
+	fixtureUsers := map[int]bool{}
+	for i, user := range fixture.Users {
+		if user.ID <= 0 || fixtureUsers[user.ID] {
+			return SeedResult{}, fmt.Errorf("user %d: id must be positive and unique", i+1)
+		}
+		fixtureUsers[user.ID] = true
+		if strings.TrimSpace(user.Name) == "" || strings.TrimSpace(user.Email) == "" {
+			return SeedResult{}, fmt.Errorf("user %d: name and email are required", user.ID)
+		}
+		if emails[strings.TrimSpace(user.Email)] {
+			return SeedResult{}, fmt.Errorf("user %d: email %s is already registered", user.ID, user.Email)
+		}
+		emails[strings.TrimSpace(user.Email)] = true
+		if user.SecretCode != "" {
+			if _, taken := storage.secretIndex[user.SecretCode]; taken || secrets[user.SecretCode] {
+				return SeedResult{}, fmt.Errorf("user %d: secret code is already in use", user.ID)
+			}
+			secrets[user.SecretCode] = true
+		}
+	}
+
+	complaints := make([]Complaint, 0, len(fixture.Complaints))
+	for i, fc := range fixture.Complaints {
+		if !fixtureUsers[fc.UserID] {
+			return SeedResult{}, fmt.Errorf("complaint %d: user_id %d is not a user in the fixture", i+1, fc.UserID)
+		}
+		req, apiErr := validateSubmission(SubmitComplaintRequest{
+			Title:    fc.Title,
+			Summary:  fc.Summary,
+			Rating:   fc.Rating,
+			Priority: fc.Priority,
+		})
+		if apiErr != nil {
+			return SeedResult{}, fmt.Errorf("complaint %d: %s", i+1, apiErr.Message)
+		}
+
+		complaint := Complaint{
+			Title:      req.Title,
+			Summary:    req.Summary,
+			Rating:     req.Rating,
+			Priority:   req.Priority,
+			UserID:     fc.UserID, // the fixture's; storeComplaint sets the real one
+			IsResolved: fc.IsResolved,
+			CreatedAt:  fc.CreatedAt,
+		}
+		if complaint.CreatedAt == "" {
+			complaint.CreatedAt = now.Local().Format(timestampLayout)
+		} else if _, err := parseTimestamp(complaint.CreatedAt); err != nil {
+			return SeedResult{}, fmt.Errorf("complaint %d: created_at must look like %s", i+1, timestampLayout)
+		}
+		if fc.IsResolved {
+			complaint.ResolvedAt = fc.ResolvedAt
+			complaint.ResolutionNote = sanitizeText(fc.ResolutionNote, true)
+			if complaint.ResolvedAt == "" {
+				complaint.ResolvedAt = now.Local().Format(timestampLayout)
+			} else if _, err := parseTimestamp(complaint.ResolvedAt); err != nil {
+				return SeedResult{}, fmt.Errorf("complaint %d: resolved_at must look like %s", i+1, timestampLayout)
+			}
+		}
+		complaints = append(complaints, complaint)
+	}
+
+	result := SeedResult{Users: []SeededUser{}}
+	users := map[int]*User{}
+	for _, fu := range fixture.Users {
+		storage.userIDGen++
+		user := &User{
+			ID:         storage.userIDGen,
+			SecretCode: fu.SecretCode,
+			Name:       strings.TrimSpace(fu.Name),
+			Email:      strings.TrimSpace(fu.Email),
+			Complaints: []Complaint{},
+			OrgID:      storage.defaultOrgID,
+			IsAdmin:    fu.IsAdmin,
+			IsActive:   true,
+		}
+		if user.SecretCode == "" {
+			user.SecretCode = generateSecretCode(user.ID)
+		}
+		storage.usersMutex.Lock()
+		storage.users[user.ID] = user
+		storage.secretIndex[user.SecretCode] = user.ID
+		storage.usersMutex.Unlock()
+		users[fu.ID] = user
+		result.Users = append(result.Users, SeededUser{
+			FixtureID:  fu.ID,
+			ID:         user.ID,
+			Name:       user.Name,
+			Email:      user.Email,
+			SecretCode: user.SecretCode,
+		})
+	}
+
+	for _, complaint := range complaints {
+		if _, err := storeComplaint(users[complaint.UserID], complaint); err != nil {
+			return SeedResult{}, err
+		}
+	}
+	result.Complaints = len(complaints)
+
+	return result, nil
+}
+
+// Word lists for generated complaints
+var (
+	seedFirstNames = []string{"Ada", "Ben", "Chloe", "Dev", "Elif", "Femi", "Grace", "Hiro", "Ines", "Jonas", "Kemi", "Luca", "Mei", "Nadia", "Omar", "Priya"}
+	seedLastNames  = []string{"Adams", "Berg", "Costa", "Dlamini", "Evans", "Fischer", "Garcia", "Haddad", "Ito", "Jensen", "Kowalski", "Li", "Moreau", "Novak", "Okafor", "Patel"}
+	seedProblems   = []string{"Broken", "Leaking", "Noisy", "Flickering", "Missing", "Overheating", "Jammed", "Slow", "Dirty", "Unresponsive"}
+	seedThings     = []string{"elevator", "heater", "printer", "door lock", "WiFi", "projector", "coffee machine", "window", "air conditioning", "badge reader", "toilet", "parking gate"}
+	seedPlaces     = []string{"the lobby", "floor 2", "floor 3", "the cafeteria", "meeting room B", "the east wing", "the car park", "the library"}
+)
+
+// randomFixture generates users fixture users with a Zipf-distributed number
+// of complaints each, spread over the 30 days before now. The same seed and
+// now always give the same fixture.
+func randomFixture(users int, seed int64, now time.Time) Fixture {
+	rng := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(rng, 1.2, 1, maxSeedComplaintsPerUser)
+	pick := func(words []string) string { return words[rng.Intn(len(words))] }
+
+	window := int64(30 * 24 * time.Hour)
+	fixture := Fixture{}
+	var created []time.Time
+	for id := 1; id <= users; id++ {
+		fixture.Users = append(fixture.Users, FixtureUser{
+			ID:    id,
+			Name:  pick(seedFirstNames) + " " + pick(seedLastNames),
+			Email: fmt.Sprintf("seed%d-user%d@example.com", seed, id),
+		})
+
+		for n := int(zipf.Uint64()); n > 0; n-- {
+			thing := pick(seedThings)
+			createdAt := now.Add(-time.Duration(rng.Int63n(window)))
+			complaint := FixtureComplaint{
+				UserID:    id,
+				Title:     pick(seedProblems) + " " + thing,
+				Summary:   fmt.Sprintf("The %s in %s has been a problem for a while. Please take a look.", thing, pick(seedPlaces)),
+				Rating:    config.RatingMin + rng.Intn(config.RatingMax-config.RatingMin+1),
+				Priority:  priorities[rng.Intn(len(priorities))],
+				CreatedAt: createdAt.Local().Format(timestampLayout),
+			}
+			if rng.Intn(10) < 3 {
+				resolvedAt := createdAt.Add(time.Duration(rng.Int63n(int64(now.Sub(createdAt)) + 1)))
+				complaint.IsResolved = true
+				complaint.ResolvedAt = resolvedAt.Local().Format(timestampLayout)
+				complaint.ResolutionNote = "Fixed by facilities"
+			}
+			fixture.Complaints = append(fixture.Complaints, complaint)
+			created = append(created, createdAt)
+		}
+	}
+
+	// Oldest first, so complaint IDs follow creation time as they would live
+	order := make([]int, len(created))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return created[order[i]].Before(created[order[j]]) })
+	sorted := make([]FixtureComplaint, len(order))
+	for i, index := range order {
+		sorted[i] = fixture.Complaints[index]
+	}
+	fixture.Complaints = sorted
+
+	return fixture
+}
+
+// readFixture reads a fixture from a JSON file
+func readFixture(path string) (Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Fixture{}, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return Fixture{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return fixture, nil
+}
+
+// seedFromFlags loads the -seed file and -seed-random data, if any
+func seedFromFlags() error {
+	if config.SeedFile != "" {
+		fixture, err := readFixture(config.SeedFile)
+		if err != nil {
+			return err
+		}
+		result, err := loadFixture(fixture)
+		if err != nil {
+			return fmt.Errorf("%s: %v", config.SeedFile, err)
+		}
+		logger.Info("Seeded users and complaints", "users", len(result.Users), "complaints", result.Complaints, "file", config.SeedFile)
+	}
+
+	if config.SeedRandom > 0 {
+		result, err := loadFixture(randomFixture(config.SeedRandom, config.SeedRandomSeed, clock.Now()))
+		if err != nil {
+			return fmt.Errorf("random seed data: %v", err)
+		}
+		logger.Info("Seeded random users and complaints", "users", len(result.Users), "complaints", result.Complaints, "seed", config.SeedRandomSeed)
+	}
+	return nil
+}
+
+// /seed - Load fixture or generated data (super-admin only, dev mode only)
+func seedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !config.DevMode {
+		respondWithError(w, http.StatusForbidden, ErrCodeForbidden, "Seeding is only available when the server runs with -dev")
+		return
+	}
+
+	var req SeedRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if (req.Fixture == nil) == (req.Random <= 0) {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Exactly one of fixture or random is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, superAdminOnly(actorOf(user))) {
+		return
+	}
+
+	fixture := req.Fixture
+	if fixture == nil {
+		generated := randomFixture(req.Random, req.RandomSeed, clock.Now())
+		fixture = &generated
+	}
+
+	result, err := loadFixture(*fixture)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Seed data loaded successfully",
+		Data:    result,
+	})
+}
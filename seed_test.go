@@ -0,0 +1,205 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLoadFixture(t *testing.T) {
+	srv := newTestServer(t)
+
+	fixture, err := readFixture("fixtures/demo.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	result, err := loadFixture(fixture)
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	if len(result.Users) != 4 || result.Complaints != 5 {
+		t.Fatalf("Expected 4 users and 5 complaints, got %d and %d", len(result.Users), result.Complaints)
+	}
+
+	t.Run("Referential Integrity", func(t *testing.T) {
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+
+		// Each fixture user is now a real user with the same name
+		idFor := map[int]int{}
+		for _, seeded := range result.Users {
+			user := storage.users[seeded.ID]
+			if user == nil || user.Name != seeded.Name || storage.secretIndex[seeded.SecretCode] != seeded.ID {
+				t.Fatalf("Seeded user %+v is not stored correctly", seeded)
+			}
+			idFor[seeded.FixtureID] = seeded.ID
+		}
+
+		for i, fc := range fixture.Complaints {
+			complaint := storage.complaints[i+1]
+			owner := storage.users[idFor[fc.UserID]]
+			if complaint == nil || complaint.UserID != owner.ID || complaint.UserName != owner.Name {
+				t.Fatalf("Complaint %d does not belong to fixture user %d", i+1, fc.UserID)
+			}
+			found := false
+			for _, own := range owner.Complaints {
+				found = found || own.ID == complaint.ID
+			}
+			if !found {
+				t.Errorf("Complaint %d is missing from its owner's list", complaint.ID)
+			}
+		}
+
+		if c := storage.complaints[2]; !c.IsResolved || c.ResolvedAt != "2024-03-02 15:05:00" || c.ResolutionNote != "Toner replaced" {
+			t.Errorf("Expected resolution fields to be kept, got %+v", c)
+		}
+		if c := storage.complaints[4]; c.Priority != priorityMedium {
+			t.Errorf("Expected the default priority, got %q", c.Priority)
+		}
+	})
+
+	t.Run("Seeded Users Can Log In", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: "DEMO_FACILITIES"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected the seeded admin to list complaints, got %d (%s)", status, resp.Error)
+		}
+		status, _ = postJSON(t, srv, "/getAllComplaintsForUser", GetComplaintsRequest{SecretCode: "DEMO_BOB"})
+		if status != http.StatusOK {
+			t.Errorf("Expected a seeded user to log in, got %d", status)
+		}
+	})
+
+	t.Run("Counters Advanced", func(t *testing.T) {
+		id, _ := registerTestUser(t, srv, "After Seed", "after@example.com")
+		if id != result.Users[len(result.Users)-1].ID+1 {
+			t.Errorf("Expected the next user ID after the seed, got %d", id)
+		}
+		complaint := submitTestComplaint(t, srv, "DEMO_CAROL", "Another one", 5)
+		if complaint.ID != 6 {
+			t.Errorf("Expected complaint ID 6, got %d", complaint.ID)
+		}
+	})
+
+	t.Run("Invalid Fixture Loads Nothing", func(t *testing.T) {
+		storage.mutex.RLock()
+		users, complaints := len(storage.users), len(storage.complaints)
+		storage.mutex.RUnlock()
+
+		for name, bad := range map[string]Fixture{
+			"dangling reference": {
+				Users:      []FixtureUser{{ID: 1, Name: "New", Email: "new@example.com"}},
+				Complaints: []FixtureComplaint{{UserID: 2, Title: "T", Summary: "S", Rating: 5}},
+			},
+			"duplicate email": {
+				Users: []FixtureUser{{ID: 1, Name: "Again", Email: "alice@example.com"}},
+			},
+			"bad rating": {
+				Users:      []FixtureUser{{ID: 1, Name: "New", Email: "new@example.com"}},
+				Complaints: []FixtureComplaint{{UserID: 1, Title: "T", Summary: "S", Rating: 11}},
+			},
+		} {
+			if _, err := loadFixture(bad); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		if len(storage.users) != users || len(storage.complaints) != complaints {
+			t.Errorf("Expected a rejected fixture to leave storage alone")
+		}
+	})
+}
+
+func TestRandomFixture(t *testing.T) {
+	newTestServer(t)
+
+	now := clock.Now()
+	a, b := randomFixture(30, 42, now), randomFixture(30, 42, now)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("Expected the same seed to generate the same fixture")
+	}
+	if reflect.DeepEqual(a.Users, randomFixture(30, 7, now).Users) {
+		t.Error("Expected a different seed to generate different users")
+	}
+
+	perUser := map[int]int{}
+	for i, complaint := range a.Complaints {
+		perUser[complaint.UserID]++
+		if i > 0 && complaint.CreatedAt < a.Complaints[i-1].CreatedAt {
+			t.Fatalf("Expected complaints oldest first")
+		}
+	}
+	for _, n := range perUser {
+		if n > maxSeedComplaintsPerUser {
+			t.Errorf("Expected at most %d complaints per user, got %d", maxSeedComplaintsPerUser, n)
+		}
+	}
+
+	result, err := loadFixture(a)
+	if err != nil {
+		t.Fatalf("Failed to load random fixture: %v", err)
+	}
+	if len(result.Users) != 30 || result.Complaints != len(a.Complaints) {
+		t.Errorf("Expected 30 users and %d complaints, got %d and %d", len(a.Complaints), len(result.Users), result.Complaints)
+	}
+}
+
+func TestSeedEndpoint(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Regular User", "regular@example.com")
+
+	payload := SeedRequest{SecretCode: adminSecret, Random: 5, RandomSeed: 3}
+	status, _ := postJSON(t, srv, "/seed", payload)
+	if status != http.StatusForbidden {
+		t.Errorf("Expected 403 outside dev mode, got %d", status)
+	}
+
+	config.DevMode = true
+
+	status, _ = postJSON(t, srv, "/seed", SeedRequest{SecretCode: code, Random: 5})
+	if status != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin, got %d", status)
+	}
+	status, _ = postJSON(t, srv, "/seed", SeedRequest{SecretCode: adminSecret})
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a fixture or random count, got %d", status)
+	}
+
+	status, resp := postJSON(t, srv, "/seed", payload)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+	}
+	var result SeedResult
+	resp.decode(t, &result)
+	if len(result.Users) != 5 || result.Users[0].SecretCode == "" {
+		t.Errorf("Expected 5 users with secret codes, got %+v", result.Users)
+	}
+
+	// Loading the same generated users again collides on email
+	status, _ = postJSON(t, srv, "/seed", payload)
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected 400 reseeding the same data, got %d", status)
+	}
+}
+
+func TestSeedFromFlags(t *testing.T) {
+	newTestServer(t)
+
+	path := t.TempDir() + "/fixture.json"
+	if err := os.WriteFile(path, []byte(`{"users": [{"id": 7, "name": "Flag User", "email": "flag@example.com"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config.SeedFile = path
+	config.SeedRandom = 2
+
+	if err := seedFromFlags(); err != nil {
+		t.Fatalf("seedFromFlags: %v", err)
+	}
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if len(storage.users) != 4 {
+		t.Errorf("Expected the admin plus 3 seeded users, got %d", len(storage.users))
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Results of a self-check. Only a failure stops the server.
+const (
+	checkPassed  = "ok"
+	checkWarned  = "warn"
+	checkFailed  = "FAIL"
+	checkSkipped = "skip"
+)
+
+// endpointDialTimeout bounds each connection attempt of -check-endpoints
+const endpointDialTimeout = 3 * time.Second
+
+// shortJWTKey is the length in bytes below which an HS256 key is warned about
+const shortJWTKey = 32
+
+// checkResult is one line of the self-check table
+type checkResult struct {
+	name   string
+	status string
+	detail string
+}
+
+func passed(detail string) checkResult  { return checkResult{status: checkPassed, detail: detail} }
+func warned(detail string) checkResult  { return checkResult{status: checkWarned, detail: detail} }
+func failed(err error) checkResult      { return checkResult{status: checkFailed, detail: err.Error()} }
+func skipped(detail string) checkResult { return checkResult{status: checkSkipped, detail: detail} }
+
+// startupChecks are run in order by runSelfCheck. A check reads config and
+// the files it names but changes nothing, so -check can run against a live
+// deployment's settings. With probe, checks of outside services also try to
+// connect to them; an unreachable one is a warning, as the outbox retries.
+var startupChecks = []struct {
+	name string
+	run  func(probe bool) checkResult
+}{
+	{"listen address", checkListenAddrs},
+	{"tls", checkTLS},
+	{"admin secret", checkAdminSecret},
+	{"jwt", checkJWT},
+	{"share links", checkShareLinks},
+	{"rating scale", checkRatingScale},
+	{"sla", checkSLA},
+	{"job intervals", checkJobIntervals},
+	{"durations", checkDurations},
+	{"limits", checkLimits},
+	{"retention", checkRetention},
+	{"assignment", checkAssignment},
+	{"ingest", checkIngest},
+	{"email domains", checkEmailDomains},
+	{"moderation words", checkModerationWords},
+	{"startup data", checkStartupData},
+	{"public url", checkPublicURL},
+	{"slack", checkSlack},
+	{"smtp", checkSMTP},
+	{"outbox", checkOutbox},
+}
+
+// runSelfCheck runs every startup check, failed ones included, so that all
+// that is wrong with the configuration is reported at once
+func runSelfCheck(probe bool) []checkResult {
+	results := make([]checkResult, 0, len(startupChecks))
+	for _, check := range startupChecks {
+		result := check.run(probe)
+		result.name = check.name
+		results = append(results, result)
+	}
+	return results
+}
+
+// selfCheckFailed reports whether any check failed
+func selfCheckFailed(results []checkResult) bool {
+	for _, result := range results {
+		if result.status == checkFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// printSelfCheck writes results to w as a table
+func printSelfCheck(w io.Writer, results []checkResult) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "CHECK\tRESULT\tDETAIL")
+	for _, result := range results {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", result.name, result.status, result.detail)
+	}
+	table.Flush()
+}
+
+// selfCheckExitCode is the exit status of -check: 1 when a check failed,
+// 0 otherwise, warnings included
+func selfCheckExitCode(results []checkResult) int {
+	if selfCheckFailed(results) {
+		return 1
+	}
+	return 0
+}
+
+// checkValid passes with detail when err is nil
+func checkValid(err error, detail string) checkResult {
+	if err != nil {
+		return failed(err)
+	}
+	return passed(detail)
+}
+
+// firstError returns the first of errs that is not nil
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// atLeast is an error when value, set with flag, is below least
+func atLeast(flag string, value, least int) error {
+	if value < least {
+		return fmt.Errorf("-%s must be at least %d, not %d", flag, least, value)
+	}
+	return nil
+}
+
+// atLeastDuration is atLeast for a duration
+func atLeastDuration(flag string, value, least time.Duration) error {
+	if value < least {
+		return fmt.Errorf("-%s must be at least %s, not %s", flag, least, value)
+	}
+	return nil
+}
+
+func checkListenAddrs(bool) checkResult {
+	for _, a := range []struct{ flag, addr string }{{"addr", config.Addr}, {"http-redirect-addr", config.HTTPRedirectAddr}} {
+		if a.addr == "" && a.flag == "http-redirect-addr" {
+			continue
+		}
+		_, port, err := net.SplitHostPort(a.addr)
+		if err == nil {
+			_, err = net.LookupPort("tcp", port)
+		}
+		if err != nil {
+			return failed(fmt.Errorf("-%s %q: %w", a.flag, a.addr, err))
+		}
+	}
+	return passed(config.Addr)
+}
+
+func checkTLS(bool) checkResult {
+	tlsConfig, certManager, err := serverTLS()
+	switch {
+	case err != nil:
+		return failed(err)
+	case tlsConfig == nil && config.HTTPRedirectAddr != "":
+		return failed(errors.New("-http-redirect-addr needs -tls-cert and -tls-key, or -autocert-domains"))
+	case tlsConfig == nil:
+		return skipped("plain HTTP")
+	case certManager != nil:
+		return passed("Let's Encrypt for " + config.AutocertDomains)
+	}
+	return passed(config.TLSCertFile)
+}
+
+func checkAdminSecret(bool) checkResult {
+	if config.RestoreFile != "" {
+		return passed("the default admin comes from -restore-file")
+	}
+	return warned("the default admin has the published secret code; rotate it with /rotateSecretCode")
+}
+
+func checkJWT(bool) checkResult {
+	if config.JWTSigningKey == "" {
+		if config.JWTPreviousKey != "" {
+			return warned("-jwt-previous-key is ignored without -jwt-key")
+		}
+		return skipped("off")
+	}
+	if err := firstError(atLeastDuration("jwt-ttl", config.JWTTTL, time.Second), atLeastDuration("jwt-clock-skew", config.JWTClockSkew, 0)); err != nil {
+		return failed(err)
+	}
+	if len(config.JWTSigningKey) < shortJWTKey {
+		return warned(fmt.Sprintf("-jwt-key is shorter than %d bytes", shortJWTKey))
+	}
+	return passed(fmt.Sprintf("tokens valid for %s", config.JWTTTL))
+}
+
+func checkShareLinks(bool) checkResult {
+	if config.ShareLinkKey == "" {
+		return warned("no -share-key; links stop working on restart")
+	}
+	return passed("signed with -share-key")
+}
+
+func checkRatingScale(bool) checkResult {
+	scale := currentRatingScale()
+	return checkValid(validateRatingScale(scale), fmt.Sprintf("%d to %d", scale.Min, scale.Max))
+}
+
+// checkSLA fails on a negative deadline and warns when a more urgent
+// priority is given longer than a less urgent one
+func checkSLA(bool) checkResult {
+	deadlines := []struct {
+		priority string
+		sla      time.Duration
+	}{
+		{priorityCritical, config.SLACritical},
+		{priorityHigh, config.SLAHigh},
+		{priorityMedium, config.SLAMedium},
+		{priorityLow, config.SLALow},
+	}
+	for _, d := range deadlines {
+		if err := atLeastDuration("sla-"+d.priority, d.sla, 0); err != nil {
+			return failed(err)
+		}
+	}
+	var set []string
+	for i, d := range deadlines {
+		if d.sla == 0 {
+			continue
+		}
+		set = append(set, d.priority+" "+d.sla.String())
+		for _, later := range deadlines[i+1:] {
+			if later.sla != 0 && later.sla < d.sla {
+				return warned(fmt.Sprintf("%s complaints have less time (%s) than %s ones (%s)", later.priority, later.sla, d.priority, d.sla))
+			}
+		}
+	}
+	if len(set) == 0 {
+		return skipped("no deadlines")
+	}
+	return passed(strings.Join(set, ", "))
+}
+
+// checkJobIntervals fails on intervals background tickers cannot run at
+func checkJobIntervals(bool) checkResult {
+	return checkValid(firstError(
+		atLeastDuration("purge-interval", config.PurgeInterval, time.Second),
+		atLeastDuration("escalation-interval", config.EscalationInterval, time.Second),
+		atLeastDuration("sla-check-interval", config.SLACheckInterval, time.Second),
+	), fmt.Sprintf("purge every %s, escalation every %s", config.PurgeInterval, config.EscalationInterval))
+}
+
+func checkDurations(bool) checkResult {
+	err := firstError(
+		atLeastDuration("request-timeout", config.RequestTimeout, 0),
+		atLeastDuration("read-timeout", config.ReadTimeout, 0),
+		atLeastDuration("write-timeout", config.WriteTimeout, 0),
+		atLeastDuration("idle-timeout", config.IdleTimeout, 0),
+		atLeastDuration("lockout-duration", config.LockoutDuration, time.Second),
+		atLeastDuration("response-cache-ttl", config.ResponseCacheTTL, 0),
+		atLeastDuration("maintenance-retry-after", config.MaintenanceRetryAfter, 0),
+		atLeastDuration("comment-edit-window", config.CommentEditWindow, 0),
+	)
+	if err == nil && config.SubmitRateLimit > 0 {
+		err = atLeastDuration("submit-rate-window", config.SubmitRateWindow, time.Second)
+	}
+	if err == nil && config.ReplayProtection {
+		err = atLeastDuration("replay-window", config.ReplayWindow, time.Second)
+	}
+	return checkValid(err, fmt.Sprintf("requests time out after %s", config.RequestTimeout))
+}
+
+func checkLimits(bool) checkResult {
+	return checkValid(firstError(
+		validateBodyLimits(),
+		atLeast("max-failed-logins", config.MaxFailedLogins, 1),
+		atLeast("max-open-complaints", config.MaxOpenComplaints, 0),
+		atLeast("submit-rate-limit", config.SubmitRateLimit, 0),
+		atLeast("max-pinned", config.MaxPinned, 0),
+		atLeast("list-summary-length", config.ListSummaryLength, 0),
+		atLeast("response-cache-size", config.ResponseCacheSize, 0),
+	), "bodies up to "+formatSize(config.MaxBodySize))
+}
+
+func checkRetention(bool) checkResult {
+	return checkValid(firstError(
+		atLeast("purge-after-days", config.PurgeAfterDays, 0),
+		atLeast("draft-max-age-days", config.DraftMaxAgeDays, 0),
+		atLeast("archive-after-days", config.ArchiveAfterDays, 0),
+		atLeast("escalate-after-days", config.EscalateAfterDays, 0),
+		atLeast("auto-confirm-days", config.AutoConfirmAfterDays, 0),
+		atLeast("retain-resolved-days", config.RetainResolvedDays, 0),
+		atLeast("retain-audit-days", config.RetainAuditDays, 0),
+		atLeast("retain-notifications-days", config.RetainNotificationsDays, 0),
+		atLeast("retain-login-history-days", config.RetainLoginHistoryDays, 0),
+	), fmt.Sprintf("trash purged after %d days", config.PurgeAfterDays))
+}
+
+func checkAssignment(bool) checkResult {
+	return checkValid(validateAssignmentStrategy(config.AssignmentStrategy), config.AssignmentStrategy)
+}
+
+func checkIngest(bool) checkResult {
+	return checkValid(validateIngestPriority(config.IngestPriority), "priority "+config.IngestPriority)
+}
+
+// checkEmailDomains loads the policy into a throwaway copy, leaving the one
+// in use alone
+func checkEmailDomains(bool) checkResult {
+	if config.EmailDomainPolicy == emailDomainsAny {
+		return skipped("any domain")
+	}
+	return checkValid((&EmailDomainPolicy{}).load(), config.EmailDomainPolicy)
+}
+
+func checkModerationWords(bool) checkResult {
+	if config.ModerationWordsFile == "" {
+		return skipped("nothing is flagged")
+	}
+	words, err := loadModerationWords(config.ModerationWordsFile)
+	return checkValid(err, fmt.Sprintf("%d words", len(words)))
+}
+
+// checkStartupData makes sure the files loaded at startup can be read. Their
+// contents are checked when they are loaded.
+func checkStartupData(bool) checkResult {
+	var files []string
+	for _, name := range []string{config.RestoreFile, config.SeedFile} {
+		if name == "" {
+			continue
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return failed(err)
+		}
+		file.Close()
+		files = append(files, name)
+	}
+	if len(files) == 0 && config.SeedRandom == 0 {
+		return skipped("empty")
+	}
+	if config.SeedRandom > 0 {
+		files = append(files, fmt.Sprintf("%d random users", config.SeedRandom))
+	}
+	return passed(strings.Join(files, ", "))
+}
+
+// absoluteURL is an error unless raw is an http or https URL with a host
+func absoluteURL(flag, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("-%s must be an http or https URL, not %q", flag, raw)
+	}
+	return u, nil
+}
+
+func checkPublicURL(bool) checkResult {
+	if config.PublicURL == "" {
+		return skipped("taken from each request")
+	}
+	_, err := absoluteURL("public-url", config.PublicURL)
+	return checkValid(err, config.PublicURL)
+}
+
+// reachable tries to connect to addr when probing, saying how it went
+func reachable(probe bool, addr string) checkResult {
+	if !probe {
+		return passed(addr + ", not probed")
+	}
+	conn, err := net.DialTimeout("tcp", addr, endpointDialTimeout)
+	if err != nil {
+		return warned(fmt.Sprintf("%s unreachable: %v", addr, err))
+	}
+	conn.Close()
+	return passed(addr + " reachable")
+}
+
+func checkSlack(probe bool) checkResult {
+	if config.SlackWebhookURL == "" {
+		return skipped("off")
+	}
+	u, err := absoluteURL("slack-webhook-url", config.SlackWebhookURL)
+	if err != nil {
+		return failed(err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return reachable(probe, net.JoinHostPort(u.Hostname(), port))
+}
+
+func checkSMTP(probe bool) checkResult {
+	if config.SMTPHost == "" {
+		return skipped("off")
+	}
+	if config.SMTPPort < 1 || config.SMTPPort > 65535 {
+		return failed(fmt.Errorf("-smtp-port must be between 1 and 65535, not %d", config.SMTPPort))
+	}
+	if _, err := mail.ParseAddress(config.SMTPFrom); err != nil {
+		return failed(fmt.Errorf("-smtp-from %q: %w", config.SMTPFrom, err))
+	}
+	return reachable(probe, net.JoinHostPort(config.SMTPHost, strconv.Itoa(config.SMTPPort)))
+}
+
+func checkOutbox(bool) checkResult {
+	return checkValid(firstError(
+		atLeast("outbox-max-attempts", config.OutboxMaxAttempts, 1),
+		atLeastDuration("outbox-retry-delay", config.OutboxRetryDelay, time.Second),
+	), fmt.Sprintf("%d attempts", config.OutboxMaxAttempts))
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkNamed returns the result of the check called name
+func checkNamed(t *testing.T, results []checkResult, name string) checkResult {
+	t.Helper()
+	for _, result := range results {
+		if result.name == name {
+			return result
+		}
+	}
+	t.Fatalf("No %q check in %+v", name, results)
+	return checkResult{}
+}
+
+func TestSelfCheck(t *testing.T) {
+	t.Cleanup(func() { config = defaultConfig() })
+
+	t.Run("Defaults", func(t *testing.T) {
+		config = defaultConfig()
+		results := runSelfCheck(false)
+		if code := selfCheckExitCode(results); code != 0 {
+			t.Fatalf("Expected the defaults to pass, got exit code %d: %+v", code, results)
+		}
+		for _, name := range []string{"admin secret", "share links"} {
+			if result := checkNamed(t, results, name); result.status != checkWarned {
+				t.Errorf("Expected a warning from %s, got %+v", name, result)
+			}
+		}
+
+		var table bytes.Buffer
+		printSelfCheck(&table, results)
+		lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+		if len(lines) != len(startupChecks)+1 || !strings.HasPrefix(lines[0], "CHECK") {
+			t.Fatalf("Expected a header and a line per check, got %s", table.String())
+		}
+		if !strings.HasPrefix(lines[len(lines)-1], "outbox") || !strings.Contains(lines[len(lines)-1], " ok ") {
+			t.Errorf("Expected the outbox check last and aligned, got %q", lines[len(lines)-1])
+		}
+	})
+
+	t.Run("Failing Configs", func(t *testing.T) {
+		for _, tc := range []struct {
+			check     string
+			configure func(c *Config)
+			detail    string
+		}{
+			{"listen address", func(c *Config) { c.Addr = "8080" }, "-addr"},
+			{"tls", func(c *Config) { c.HTTPRedirectAddr = ":80" }, "needs -tls-cert"},
+			{"tls", func(c *Config) { c.TLSCertFile, c.TLSKeyFile = "missing.pem", "missing.key" }, "missing.pem"},
+			{"tls", func(c *Config) { c.AutocertDomains, c.TLSCertFile = "portal.example.com", "cert.pem" }, "-autocert-domains"},
+			{"jwt", func(c *Config) { c.JWTSigningKey, c.JWTTTL = strings.Repeat("k", 32), 0 }, "-jwt-ttl"},
+			{"rating scale", func(c *Config) { c.RatingMin = 0 }, "at least 1"},
+			{"sla", func(c *Config) { c.SLAHigh = -time.Hour }, "-sla-high"},
+			{"job intervals", func(c *Config) { c.PurgeInterval = 0 }, "-purge-interval"},
+			{"durations", func(c *Config) { c.ReplayProtection, c.ReplayWindow = true, 0 }, "-replay-window"},
+			{"limits", func(c *Config) { c.MaxBodySize = 0 }, "must be positive"},
+			{"limits", func(c *Config) { c.MaxFailedLogins = 0 }, "-max-failed-logins"},
+			{"retention", func(c *Config) { c.RetainAuditDays = -1 }, "-retain-audit-days"},
+			{"assignment", func(c *Config) { c.AssignmentStrategy = "random" }, "must be one of"},
+			{"ingest", func(c *Config) { c.IngestPriority = "urgent" }, "must be one of"},
+			{"email domains", func(c *Config) { c.EmailDomainPolicy = emailDomainsAllow }, "-allowed-email-domains"},
+			{"moderation words", func(c *Config) { c.ModerationWordsFile = "testdata/missing-words.txt" }, "missing-words.txt"},
+			{"startup data", func(c *Config) { c.RestoreFile = "testdata/missing-backup.json" }, "missing-backup.json"},
+			{"public url", func(c *Config) { c.PublicURL = "complaints.example.com" }, "-public-url"},
+			{"slack", func(c *Config) { c.SlackWebhookURL = "hooks.slack.com/services/x" }, "-slack-webhook-url"},
+			{"smtp", func(c *Config) { c.SMTPHost, c.SMTPPort = "mail.example.com", 0 }, "-smtp-port"},
+			{"smtp", func(c *Config) { c.SMTPHost, c.SMTPFrom = "mail.example.com", "not an address" }, "-smtp-from"},
+			{"outbox", func(c *Config) { c.OutboxMaxAttempts = 0 }, "-outbox-max-attempts"},
+		} {
+			config = defaultConfig()
+			tc.configure(&config)
+			results := runSelfCheck(false)
+			result := checkNamed(t, results, tc.check)
+			if result.status != checkFailed || !strings.Contains(result.detail, tc.detail) {
+				t.Errorf("%s: expected a failure mentioning %q, got %+v", tc.check, tc.detail, result)
+			}
+			if code := selfCheckExitCode(results); code != 1 {
+				t.Errorf("%s: expected exit code 1, got %d", tc.check, code)
+			}
+		}
+	})
+
+	t.Run("Warnings", func(t *testing.T) {
+		for _, tc := range []struct {
+			check     string
+			configure func(c *Config)
+		}{
+			{"sla", func(c *Config) { c.SLACritical, c.SLAHigh = 48*time.Hour, 24*time.Hour }},
+			{"jwt", func(c *Config) { c.JWTSigningKey = "short" }},
+			{"jwt", func(c *Config) { c.JWTPreviousKey = strings.Repeat("k", 32) }},
+		} {
+			config = defaultConfig()
+			tc.configure(&config)
+			results := runSelfCheck(false)
+			if result := checkNamed(t, results, tc.check); result.status != checkWarned {
+				t.Errorf("%s: expected a warning, got %+v", tc.check, result)
+			}
+			if code := selfCheckExitCode(results); code != 0 {
+				t.Errorf("%s: expected a warning not to fail the check, got exit code %d", tc.check, code)
+			}
+		}
+	})
+
+	t.Run("Endpoints", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		up := listener.Addr().(*net.TCPAddr).Port
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		down := closed.Addr().(*net.TCPAddr).Port
+		closed.Close()
+		defer listener.Close()
+
+		config = defaultConfig()
+		config.SMTPHost, config.SMTPPort = "127.0.0.1", up
+		if result := checkNamed(t, runSelfCheck(true), "smtp"); result.status != checkPassed || !strings.Contains(result.detail, "reachable") {
+			t.Errorf("Expected a listening server to be reachable, got %+v", result)
+		}
+		config.SMTPPort = down
+		if result := checkNamed(t, runSelfCheck(false), "smtp"); result.status != checkPassed || !strings.Contains(result.detail, "not probed") {
+			t.Errorf("Expected no connection without probing, got %+v", result)
+		}
+		results := runSelfCheck(true)
+		if result := checkNamed(t, results, "smtp"); result.status != checkWarned || !strings.Contains(result.detail, "unreachable") {
+			t.Errorf("Expected an unreachable server to be a warning, got %+v", result)
+		}
+		if code := selfCheckExitCode(results); code != 0 {
+			t.Errorf("Expected an unreachable server not to fail the check, got exit code %d", code)
+		}
+	})
+}
+
+// TestCheckFlag runs the server binary's main with -check in a child
+// process, as a deploy pipeline would, and looks at its exit code
+func TestCheckFlag(t *testing.T) {
+	if args, ok := os.LookupEnv("SELF_CHECK_ARGS"); ok {
+		os.Args = append([]string{"complaint-portal"}, strings.Fields(args)...)
+		main()
+		t.Fatal("Expected main to exit")
+	}
+
+	for _, tc := range []struct {
+		name string
+		args string
+		code int
+	}{
+		{"Defaults", "-check", 0},
+		{"Warnings Only", "-check -jwt-key short", 0},
+		{"Bad Rating Scale", "-check -rating-min 0", 1},
+		{"Negative SLA", "-check -sla-high=-1h", 1},
+		{"Missing Restore File", "-check -restore-file testdata/missing-backup.json", 1},
+		// Without -check a failure stops the server before it listens
+		{"Startup", "-addr 127.0.0.1:0 -purge-interval 0", 1},
+	} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCheckFlag$")
+		cmd.Env = append(os.Environ(), "SELF_CHECK_ARGS="+tc.args)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		code := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatalf("%s: failed to run: %v", tc.name, err)
+		}
+		if code != tc.code {
+			t.Errorf("%s: expected exit code %d, got %d: %s", tc.name, tc.code, code, stderr.String())
+		}
+		if !strings.Contains(stderr.String(), "CHECK") {
+			t.Errorf("%s: expected the self-check table, got %s", tc.name, stderr.String())
+		}
+		if strings.Contains(stderr.String(), "server starting") {
+			t.Errorf("%s: expected the server not to start, got %s", tc.name, stderr.String())
+		}
+	}
+}
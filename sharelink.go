@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sharedPathPrefix      = "/shared/"
+	defaultShareLinkHours = 7 * 24
+	maxShareLinkHours     = 30 * 24
+)
+
+var sharedTemplate = template.Must(template.ParseFS(templateFS, "templates/shared.html"))
+
+// processShareKey signs share links when -share-key is not set. It is
+// random per process, so such links do not survive a restart.
+var processShareKey = newCursorKey()
+
+func shareKey() []byte {
+	if config.ShareLinkKey != "" {
+		return []byte(config.ShareLinkKey)
+	}
+	return processShareKey
+}
+
+// shareClaims are what a share link token carries. Nothing about a link is
+// stored until it is revoked.
+type shareClaims struct {
+	ID          string `json:"i"`
+	ComplaintID int    `json:"c"`
+	Expires     int64  `json:"e"` // Unix seconds
+}
+
+func (c shareClaims) expires() time.Time {
+	return time.Unix(c.Expires, 0)
+}
+
+// ShareLink is the /createShareLink payload
+type ShareLink struct {
+	ID          string `json:"id"` // also in the token; revocations are kept by it
+	ComplaintID int    `json:"complaint_id"`
+	Reference   string `json:"reference"`
+	ExpiresAt   string `json:"expires_at"`
+	Token       string `json:"token"`
+	URL         string `json:"url"`
+}
+
+// SharedComplaint is what a share link shows: the complaint itself, without
+// who submitted it, admin notes, comments, history or assignment
+type SharedComplaint struct {
+	Reference      string `json:"reference" xml:"reference"`
+	Title          string `json:"title" xml:"title"`
+	Summary        string `json:"summary" xml:"summary"`
+	Status         string `json:"status" xml:"status"`
+	Priority       string `json:"priority" xml:"priority"`
+	Department     string `json:"department,omitempty" xml:"department,omitempty"`
+	Building       string `json:"building,omitempty" xml:"building,omitempty"`
+	Floor          string `json:"floor,omitempty" xml:"floor,omitempty"`
+	Room           string `json:"room,omitempty" xml:"room,omitempty"`
+	CreatedAt      string `json:"created_at" xml:"created_at"`
+	DueAt          string `json:"due_at,omitempty" xml:"due_at,omitempty"`
+	ResolvedAt     string `json:"resolved_at,omitempty" xml:"resolved_at,omitempty"`
+	ResolutionNote string `json:"resolution_note,omitempty" xml:"resolution_note,omitempty"`
+	LinkExpiresAt  string `json:"link_expires_at" xml:"link_expires_at"`
+}
+
+type CreateShareLinkRequest struct {
+	SecretCode     string `json:"secret_code"`
+	ComplaintID    int    `json:"complaint_id"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"` // default 7 days
+}
+
+type RevokeShareLinkRequest struct {
+	SecretCode string `json:"secret_code"`
+	Token      string `json:"token"` // as returned by /createShareLink, or the URL holding it
+}
+
+func shareSignature(payload string) string {
+	mac := hmac.New(sha256.New, shareKey())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// encodeShareToken turns claims into a signed token for a share link URL
+func encodeShareToken(claims shareClaims) string {
+	data, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + shareSignature(payload)
+}
+
+// decodeShareToken checks token's signature and returns its claims, whether
+// or not they have expired
+func decodeShareToken(token string) (shareClaims, bool) {
+	var claims shareClaims
+	payload, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(shareSignature(payload))) {
+		return claims, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(data, &claims) != nil || claims.ID == "" {
+		return claims, false
+	}
+	return claims, true
+}
+
+// sharedComplaint is the complaint token gives access to, or nil when the
+// token is forged, expired or revoked, or the complaint is gone. Callers
+// must hold storage.mutex.
+func sharedComplaint(token string, now time.Time) (*Complaint, shareClaims) {
+	claims, valid := decodeShareToken(token)
+	if !valid || !now.Before(claims.expires()) {
+		return nil, claims
+	}
+	if _, revoked := storage.revokedShares[claims.ID]; revoked {
+		return nil, claims
+	}
+	complaint, exists := storage.complaints[claims.ComplaintID]
+	if !exists || complaint.IsDeleted {
+		return nil, claims
+	}
+	return complaint, claims
+}
+
+func shareView(complaint *Complaint, claims shareClaims) SharedComplaint {
+	return SharedComplaint{
+		Reference:      complaint.Reference,
+		Title:          complaint.Title,
+		Summary:        complaint.Summary,
+		Status:         complaintStatus(complaint),
+		Priority:       complaint.Priority,
+		Department:     complaint.Department,
+		Building:       complaint.Building,
+		Floor:          complaint.Floor,
+		Room:           complaint.Room,
+		CreatedAt:      complaint.CreatedAt,
+		DueAt:          complaint.DueAt,
+		ResolvedAt:     complaint.ResolvedAt,
+		ResolutionNote: complaint.ResolutionNote,
+		LinkExpiresAt:  claims.expires().Local().Format(timestampLayout),
+	}
+}
+
+// prefersHTML reports whether an Accept header ranks HTML above JSON, as
+// browsers' do
+func prefersHTML(accept string) bool {
+	var htmlQuality, jsonQuality float64
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, exists := params["q"]; exists {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "text/html":
+			htmlQuality = max(htmlQuality, quality)
+		case "application/json":
+			jsonQuality = max(jsonQuality, quality)
+		}
+	}
+	return htmlQuality > 0 && htmlQuality > jsonQuality
+}
+
+// loggedPath is r's path as request logs show it, without share link tokens,
+// which are as good as a password for as long as they last
+func loggedPath(path string) string {
+	if strings.HasPrefix(path, sharedPathPrefix) {
+		return sharedPathPrefix + "…"
+	}
+	return path
+}
+
+// /createShareLink - Issue an expiring link to a read-only view of one
+// complaint, for someone without an account (admin only)
+func createShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req CreateShareLinkRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = defaultShareLinkHours
+	}
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("complaint_id", req.ComplaintID)
+	v.between("expires_in_hours", req.ExpiresInHours, 1, maxShareLinkHours)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate share link")
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+
+	claims := shareClaims{
+		ID:          hex.EncodeToString(id),
+		ComplaintID: complaint.ID,
+		Expires:     clock.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour).Unix(),
+	}
+	token := encodeShareToken(claims)
+	link := ShareLink{
+		ID:          claims.ID,
+		ComplaintID: complaint.ID,
+		Reference:   complaint.Reference,
+		ExpiresAt:   claims.expires().Local().Format(timestampLayout),
+		Token:       token,
+		URL:         baseURL(r) + sharedPathPrefix + token,
+	}
+	addHistory(complaint, HistoryEntry{
+		Action:        historyShared,
+		ActorID:       user.ID,
+		Detail:        fmt.Sprintf("Share link %s, valid until %s", link.ID, link.ExpiresAt),
+		CorrelationID: correlationID(r),
+	})
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Share link created",
+		Data:    link,
+	})
+}
+
+// /revokeShareLink - Stop a share link working before it expires (admin
+// only)
+func revokeShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RevokeShareLinkRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.required("token", req.Token)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	token := strings.TrimSpace(req.Token)
+	if i := strings.LastIndex(token, sharedPathPrefix); i >= 0 {
+		token = token[i+len(sharedPathPrefix):]
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	claims, valid := decodeShareToken(token)
+	var complaint *Complaint
+	if valid {
+		complaint = scopedComplaint(user, claims.ComplaintID)
+	}
+	if complaint == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Share link not found")
+		return
+	}
+
+	now := clock.Now()
+	for id, expires := range storage.revokedShares {
+		// Expired links are turned away anyway
+		if !now.Before(expires) {
+			delete(storage.revokedShares, id)
+		}
+	}
+	_, revoked := storage.revokedShares[claims.ID]
+	if !revoked && now.Before(claims.expires()) {
+		storage.revokedShares[claims.ID] = claims.expires()
+		addHistory(complaint, HistoryEntry{
+			Action:        historyShareRevoked,
+			ActorID:       user.ID,
+			Detail:        "Share link " + claims.ID,
+			CorrelationID: correlationID(r),
+		})
+		touchComplaint(complaint)
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Share link revoked",
+	})
+}
+
+// /shared/{token} - Read-only view of the complaint a share link is for, as
+// JSON or, for browsers, HTML. No other credential is needed.
+func sharedComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// The link is the credential: keep it out of caches and other sites'
+	// logs
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	storage.mutex.RLock()
+	complaint, claims := sharedComplaint(strings.TrimPrefix(r.URL.Path, sharedPathPrefix), clock.Now())
+	var view SharedComplaint
+	if complaint != nil {
+		view = shareView(complaint, claims)
+	}
+	storage.mutex.RUnlock()
+
+	// Forged, expired and revoked links look alike, so none can be told
+	// from one that never existed
+	if complaint == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Share link not found")
+		return
+	}
+
+	if !prefersHTML(r.Header.Get("Accept")) {
+		respondWithJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Message: "Shared complaint retrieved successfully",
+			Data:    view,
+		})
+		return
+	}
+
+	var body bytes.Buffer
+	if err := sharedTemplate.Execute(&body, view); err != nil {
+		requestLogger(r).Error("Rendering shared complaint failed", logKeyError, err)
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to render complaint")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(body.Bytes())
+}
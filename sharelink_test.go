@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getShared fetches a share link with accept as the Accept header
+func getShared(t *testing.T, url, accept string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("Failed to build GET %s: %v", url, err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading shared complaint: %v", err)
+	}
+	return resp, string(body)
+}
+
+func TestShareLinks(t *testing.T) {
+	srv := newTestServer(t)
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.Local))
+	_, code := registerTestUser(t, srv, "Private Person", "private@example.com")
+	complaint := submitTestComplaint(t, srv, code, "Pipes & drains", 5)
+	postJSON(t, srv, "/addAdminNote", AddAdminNoteRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Note: "Tenant is difficult"})
+
+	share := func(t *testing.T, req CreateShareLinkRequest) ShareLink {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/createShareLink", req)
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var link ShareLink
+		resp.decode(t, &link)
+		return link
+	}
+	expectGone := func(t *testing.T, url, why string) {
+		t.Helper()
+		resp, body := getShared(t, url, "")
+		var envelope testResponse
+		json.Unmarshal([]byte(body), &envelope)
+		if resp.StatusCode != http.StatusNotFound || envelope.Error != "Share link not found" {
+			t.Errorf("Expected 404 for %s, got %d %s", why, resp.StatusCode, body)
+		}
+	}
+
+	t.Run("Generation", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			req    CreateShareLinkRequest
+			status int
+		}{
+			{"Regular User", CreateShareLinkRequest{SecretCode: code, ComplaintID: complaint.ID}, http.StatusForbidden},
+			{"Unknown Complaint", CreateShareLinkRequest{SecretCode: adminSecret, ComplaintID: complaint.ID + 100}, http.StatusNotFound},
+			{"Too Long", CreateShareLinkRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, ExpiresInHours: maxShareLinkHours + 1}, http.StatusBadRequest},
+		} {
+			if status, resp := postJSON(t, srv, "/createShareLink", tc.req); status != tc.status {
+				t.Errorf("%s: expected %d, got %d (%s)", tc.name, tc.status, status, resp.Error)
+			}
+		}
+
+		link := share(t, CreateShareLinkRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		if link.ComplaintID != complaint.ID || link.Reference != complaint.Reference || link.URL != srv.URL+"/shared/"+link.Token {
+			t.Errorf("Unexpected link %+v", link)
+		}
+		if link.ExpiresAt != "2024-06-10 09:00:00" {
+			t.Errorf("Expected the link to last a week by default, got %s", link.ExpiresAt)
+		}
+		stored := assigneeOf(t, srv, complaint.ID)
+		if entry := stored.History[len(stored.History)-1]; entry.Action != historyShared || !strings.Contains(entry.Detail, link.ID) {
+			t.Errorf("Expected the link in history, got %+v", entry)
+		}
+	})
+
+	t.Run("Fetch", func(t *testing.T) {
+		link := share(t, CreateShareLinkRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+
+		resp, body := getShared(t, link.URL, "")
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Cache-Control") != "no-store" {
+			t.Fatalf("Expected an uncached 200, got %d %q: %s", resp.StatusCode, resp.Header.Get("Cache-Control"), body)
+		}
+		var envelope testResponse
+		json.Unmarshal([]byte(body), &envelope)
+		var shared SharedComplaint
+		envelope.decode(t, &shared)
+		if shared.Title != complaint.Title || shared.Reference != complaint.Reference || shared.Status != statusOpen || shared.LinkExpiresAt != link.ExpiresAt {
+			t.Errorf("Unexpected shared complaint %+v", shared)
+		}
+
+		resp, page := getShared(t, link.URL, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+			t.Fatalf("Expected HTML for a browser, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if !strings.Contains(page, "Pipes &amp; drains") {
+			t.Errorf("Expected the escaped title on the page, got %s", page)
+		}
+
+		for _, rendered := range []string{body, page} {
+			for _, private := range []string{"Private Person", "private@example.com", "Tenant is difficult", "user_id", "admin_notes", "history"} {
+				if strings.Contains(rendered, private) {
+					t.Errorf("Expected no %q in the shared view, got %s", private, rendered)
+				}
+			}
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		link := share(t, CreateShareLinkRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, ExpiresInHours: 2})
+		fake.Advance(2*time.Hour - time.Second)
+		if resp, body := getShared(t, link.URL, ""); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 before expiry, got %d %s", resp.StatusCode, body)
+		}
+		fake.Advance(time.Second)
+		expectGone(t, link.URL, "an expired link")
+	})
+
+	t.Run("Revocation", func(t *testing.T) {
+		link := share(t, CreateShareLinkRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		other := share(t, CreateShareLinkRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+
+		if status, _ := postJSON(t, srv, "/revokeShareLink", RevokeShareLinkRequest{SecretCode: code, Token: link.Token}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a regular user, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/revokeShareLink", RevokeShareLinkRequest{SecretCode: adminSecret, Token: "forged.token"}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for a forged token, got %d", status)
+		}
+		// Twice, and by URL the second time: revoking is idempotent
+		for _, token := range []string{link.Token, link.URL} {
+			if status, resp := postJSON(t, srv, "/revokeShareLink", RevokeShareLinkRequest{SecretCode: adminSecret, Token: token}); status != http.StatusOK {
+				t.Fatalf("Expected 200 revoking, got %d (%s)", status, resp.Error)
+			}
+		}
+		expectGone(t, link.URL, "a revoked link")
+		if resp, _ := getShared(t, other.URL, ""); resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected other links to the complaint to keep working, got %d", resp.StatusCode)
+		}
+
+		stored := assigneeOf(t, srv, complaint.ID)
+		revocations := 0
+		for _, entry := range stored.History {
+			if entry.Action == historyShareRevoked {
+				revocations++
+			}
+		}
+		if revocations != 1 {
+			t.Errorf("Expected one revocation in history, got %d", revocations)
+		}
+	})
+
+	t.Run("Tampering", func(t *testing.T) {
+		link := share(t, CreateShareLinkRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		payload, signature, _ := strings.Cut(link.Token, ".")
+		data, _ := base64.RawURLEncoding.DecodeString(payload)
+
+		// A longer expiry, or another complaint, under the original signature
+		longer := bytes.Replace(data, []byte(`"e":`), []byte(`"e":9`), 1)
+		other := bytes.Replace(data, []byte(`"c":`), []byte(`"c":1`), 1)
+		flipped := []byte(signature)
+		flipped[0] ^= 1
+		for why, token := range map[string]string{
+			"an extended expiry":  base64.RawURLEncoding.EncodeToString(longer) + "." + signature,
+			"another complaint":   base64.RawURLEncoding.EncodeToString(other) + "." + signature,
+			"a changed signature": payload + "." + string(flipped),
+			"a missing signature": payload,
+			"garbage":             "not-a-token",
+		} {
+			expectGone(t, srv.URL+"/shared/"+token, why)
+		}
+
+		// A link signed with another key, as after a restart without -share-key
+		config.ShareLinkKey = "another key"
+		expectGone(t, link.URL, "a link signed with another key")
+		config.ShareLinkKey = ""
+		if resp, _ := getShared(t, link.URL, ""); resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected the link to work again with its own key, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Deleted Complaint", func(t *testing.T) {
+		link := share(t, CreateShareLinkRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		storage.mutex.Lock()
+		storage.complaints[complaint.ID].IsDeleted = true
+		storage.mutex.Unlock()
+		expectGone(t, link.URL, "a deleted complaint")
+	})
+
+	t.Run("Token Not Logged", func(t *testing.T) {
+		var logs bytes.Buffer
+		useLogOutput(t, &logs)
+		getShared(t, srv.URL+"/shared/secret-looking-token", "")
+		if strings.Contains(logs.String(), "secret-looking-token") {
+			t.Errorf("Expected the token kept out of request logs, got %s", logs.String())
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxSimilarComplaints is how many suggestions are returned at most
+	maxSimilarComplaints = 5
+	// minSimilarity is the Jaccard similarity below which two complaints
+	// are not considered alike
+	minSimilarity = 0.2
+)
+
+// similarityStopWords are too common to tell complaints apart
+var similarityStopWords = map[string]bool{
+	"an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "been": true, "but": true,
+	"by": true, "for": true, "from": true, "has": true, "have": true, "in": true, "is": true, "it": true,
+	"its": true, "my": true, "no": true, "not": true, "of": true, "on": true, "or": true, "our": true,
+	"so": true, "that": true, "the": true, "there": true, "this": true, "to": true, "was": true, "we": true,
+	"were": true, "with": true,
+}
+
+type SuggestSimilarRequest struct {
+	SecretCode string `json:"secret_code"`
+	Title      string `json:"title"`
+	Summary    string `json:"summary,omitempty"`
+}
+
+// SimilarComplaint is an open complaint that reads like the one being
+// written. Score is the Jaccard similarity of their words, from 0 to 1.
+type SimilarComplaint struct {
+	ID        int     `json:"id"`
+	Reference string  `json:"reference"`
+	Title     string  `json:"title"`
+	CreatedAt string  `json:"created_at"`
+	Score     float64 `json:"score"`
+}
+
+// similarityWords returns the distinct words of texts, lowercased and
+// sorted, leaving out stop words and single characters
+func similarityWords(texts ...string) []string {
+	seen := map[string]bool{}
+	for _, text := range texts {
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, word := range words {
+			if utf8.RuneCountInString(word) > 1 && !similarityStopWords[word] {
+				seen[word] = true
+			}
+		}
+	}
+	words := make([]string, 0, len(seen))
+	for word := range seen {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// jaccard is the size of the intersection of two sorted word sets over the
+// size of their union
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			shared++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// similarComplaints returns up to maxSimilarComplaints open complaints
+// viewer may see that read like title and summary, most similar first and
+// the oldest first among equals. Users see only their own complaints,
+// admins those of their organization. Callers must hold storage.mutex.
+func similarComplaints(viewer *User, title, summary string) []SimilarComplaint {
+	words := similarityWords(title, summary)
+	similar := []SimilarComplaint{}
+	caller := actorOf(viewer)
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted || !isOpen(complaint) || !canViewComplaint(caller, complaint).allowed() {
+			continue
+		}
+		score := jaccard(words, complaint.words)
+		if score < minSimilarity {
+			continue
+		}
+		similar = append(similar, SimilarComplaint{
+			ID:        complaint.ID,
+			Reference: complaint.Reference,
+			Title:     complaint.Title,
+			CreatedAt: complaint.CreatedAt,
+			Score:     math.Round(score*1000) / 1000,
+		})
+	}
+
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Score != similar[j].Score {
+			return similar[i].Score > similar[j].Score
+		}
+		return similar[i].ID < similar[j].ID
+	})
+	if len(similar) > maxSimilarComplaints {
+		similar = similar[:maxSimilarComplaints]
+	}
+	return similar
+}
+
+// /suggestSimilar - Open complaints like the one being written, so the
+// caller can add to one of those instead of filing a duplicate
+func suggestSimilarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req SuggestSimilarRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	title, summary := sanitizeText(req.Title, false), sanitizeText(req.Summary, true)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.required("title", title)
+	v.maxRunes("title", title, maxTitleLength)
+	v.maxRunes("summary", summary, maxSummaryLength)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Similar complaints retrieved successfully",
+		Data:    similarComplaints(user, title, summary),
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSimilarityWords(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  []string
+	}{
+		{"case and punctuation", []string{"Heater BROKEN!", "heater, broken."}, []string{"broken", "heater"}},
+		{"stop words and single letters", []string{"The heater in room B is not working"}, []string{"heater", "room", "working"}},
+		{"numbers", []string{"Room 12, floor 3"}, []string{"12", "floor", "room"}},
+		{"other scripts", []string{"Chauffage cassé — chauffage"}, []string{"cassé", "chauffage"}},
+		{"empty", []string{"", "!!"}, []string{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := similarityWords(tc.texts...); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("similarityWords(%q) = %q, want %q", tc.texts, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSuggestSimilar(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, alice := registerTestUser(t, srv, "Alice", "alice@example.com")
+	_, bob := registerTestUser(t, srv, "Bob", "bob@example.com")
+
+	submit := func(code, title, summary string) int {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: title, Summary: summary, Rating: 5})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		return complaint.ID
+	}
+	suggest := func(code, title, summary string) []int {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/suggestSimilar", SuggestSimilarRequest{SecretCode: code, Title: title, Summary: summary})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var similar []SimilarComplaint
+		resp.decode(t, &similar)
+		ids := []int{}
+		for _, s := range similar {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	heater := submit(alice, "Heater broken in room 12", "The heater in room 12 stopped working on Monday")
+	noisy := submit(alice, "Heater noisy in room 12", "The heater in room 12 rattles all night")
+	submit(alice, "Elevator stuck", "Stuck between floors two and three")
+	resolved := submit(alice, "Heater broken in room 12 again", "The heater in room 12 stopped working")
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: resolved})
+	bobs := submit(bob, "Heater broken in room 12", "The heater in room 12 stopped working on Monday")
+
+	t.Run("Ranking", func(t *testing.T) {
+		ids := suggest(alice, "Broken heater, room 12", "Heater stopped working")
+		if want := []int{heater, noisy}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("Expected %v, most similar first and without resolved or other users' complaints, got %v", want, ids)
+		}
+	})
+
+	t.Run("Scores", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/suggestSimilar", SuggestSimilarRequest{SecretCode: alice, Title: "Heater broken in room 12", Summary: "The heater in room 12 stopped working on Monday"})
+		var similar []SimilarComplaint
+		resp.decode(t, &similar)
+		if len(similar) == 0 || similar[0].ID != heater || similar[0].Score != 1 {
+			t.Errorf("Expected an identical complaint to score 1, got %+v", similar)
+		}
+	})
+
+	t.Run("Nothing Alike", func(t *testing.T) {
+		if ids := suggest(alice, "Parking permit", "Lost my parking permit"); len(ids) != 0 {
+			t.Errorf("Expected no suggestions, got %v", ids)
+		}
+	})
+
+	t.Run("Admins See Their Organization", func(t *testing.T) {
+		if ids := suggest(adminSecret, "Heater broken in room 12", "The heater in room 12 stopped working on Monday"); len(ids) < 2 || ids[0] != heater || ids[1] != bobs {
+			t.Errorf("Expected Alice's and Bob's identical complaints first, oldest first, got %v", ids)
+		}
+		_, _, orgAdmin := createTestOrganization(t, srv, "Acme", "admin@acme.example")
+		if ids := suggest(orgAdmin, "Heater broken in room 12", ""); len(ids) != 0 {
+			t.Errorf("Expected nothing from another organization, got %v", ids)
+		}
+	})
+
+	t.Run("At Most Five", func(t *testing.T) {
+		for i := 0; i < 6; i++ {
+			submit(bob, "Water leak in kitchen", "Water leaking under the kitchen sink")
+		}
+		ids := suggest(bob, "Water leak in kitchen", "Water leaking under the kitchen sink")
+		if len(ids) != maxSimilarComplaints || ids[0] != bobs+1 || ids[4] != bobs+5 {
+			t.Errorf("Expected the 5 oldest of 6 equal matches, got %v", ids)
+		}
+	})
+
+	t.Run("Submit With Suggest", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: alice, Title: "Heater in room 12 broken", Summary: "Heater stopped working", Rating: 5, Suggest: true,
+		})
+		var similar []SimilarComplaint
+		resp.decode(t, &similar)
+		if status != http.StatusOK || len(similar) == 0 || similar[0].ID != heater {
+			t.Fatalf("Expected 200 with suggestions and nothing stored, got %d %+v", status, similar)
+		}
+		storage.mutex.RLock()
+		stored := len(storage.complaints)
+		storage.mutex.RUnlock()
+		if stored != bobs+6 {
+			t.Errorf("Expected no complaint stored, have %d", stored)
+		}
+
+		status, resp = postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: alice, Title: "Broken window", Summary: "Window cracked in the lobby", Rating: 5, Suggest: true,
+		})
+		if status != http.StatusCreated {
+			t.Errorf("Expected 201 when nothing is alike, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Survives Restore", func(t *testing.T) {
+		document := fetchBackup(t, srv)
+		if status, resp := restoreBackup(t, srv, document); status != http.StatusOK {
+			t.Fatalf("Expected 200 restoring, got %d (%s)", status, resp.Error)
+		}
+		if ids := suggest(alice, "Broken heater, room 12", "Heater stopped working"); len(ids) == 0 || ids[0] != heater {
+			t.Errorf("Expected suggestions after a restore, got %v", ids)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// slaFor returns how much business time a complaint of priority has to be
+// resolved, or 0 when it has no deadline. Complaints stored before
+// priorities existed count as medium.
+func slaFor(priority string) time.Duration {
+	switch priority {
+	case priorityCritical:
+		return config.SLACritical
+	case priorityHigh:
+		return config.SLAHigh
+	case priorityLow:
+		return config.SLALow
+	}
+	return config.SLAMedium
+}
+
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// nextMidnight returns the start of the day after t, in t's location. Days
+// are stepped by date rather than by 24 hours so DST changes are harmless.
+func nextMidnight(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+}
+
+// addBusinessTime returns the moment d of business time after start, where
+// business time runs around the clock on weekdays and stops over weekends.
+// A complaint submitted on Friday at 15:00 with one business day is due on
+// Monday at 15:00; one submitted on Saturday starts its clock on Monday.
+func addBusinessTime(start time.Time, d time.Duration) time.Time {
+	t := start
+	for {
+		for isWeekend(t) {
+			t = nextMidnight(t)
+		}
+		midnight := nextMidnight(t)
+		left := midnight.Sub(t)
+		if d <= left {
+			return t.Add(d)
+		}
+		d -= left
+		t = midnight
+	}
+}
+
+// complaintSLA returns how much business time complaint has to be resolved:
+// its department's SLA when it has one, otherwise its priority's. Callers
+// must hold storage.mutex for reading.
+func complaintSLA(complaint *Complaint) time.Duration {
+	if department := findDepartment(complaint.Department); department != nil && department.SLA != "" {
+		if sla, err := time.ParseDuration(department.SLA); err == nil {
+			return sla
+		}
+	}
+	return slaFor(complaint.Priority)
+}
+
+// setDueAt computes complaint's deadline from its creation time, department
+// and priority, clearing it when there is no SLA. Callers must hold
+// storage.mutex for reading.
+func setDueAt(complaint *Complaint) {
+	complaint.DueAt = ""
+	sla := complaintSLA(complaint)
+	if sla <= 0 {
+		return
+	}
+	created, err := parseTimestamp(complaint.CreatedAt)
+	if err != nil {
+		return
+	}
+	complaint.DueAt = addBusinessTime(created, sla).Local().Format(timestampLayout)
+}
+
+// resetDueAt recomputes complaint's deadline after a change of priority or
+// department. A breach stays on record only while the complaint is still
+// overdue. Callers must hold storage.mutex for writing.
+func resetDueAt(complaint *Complaint) {
+	setDueAt(complaint)
+	if !isOverdue(complaint, clock.Now()) {
+		complaint.SLABreachedAt = ""
+	}
+}
+
+// isOverdue reports whether complaint is still open past its deadline.
+// Merged complaints follow their primary instead.
+func isOverdue(complaint *Complaint, now time.Time) bool {
+	if !isOpen(complaint) || complaint.DueAt == "" {
+		return false
+	}
+	due, err := parseTimestamp(complaint.DueAt)
+	return err == nil && now.After(due)
+}
+
+// flagOverdueComplaints records the SLA breach of every complaint that has
+// become overdue since the last run and publishes sla.breached for each.
+// Complaints already flagged are left alone, so repeated runs are no-ops.
+func flagOverdueComplaints(ctx context.Context, now time.Time) int {
+	storage.mutex.RLock()
+	var candidates []int
+	for id, complaint := range storage.complaints {
+		if !complaint.IsDeleted && complaint.SLABreachedAt == "" && isOverdue(complaint, now) {
+			candidates = append(candidates, id)
+		}
+	}
+	storage.mutex.RUnlock()
+
+	flagged := 0
+	for start := 0; start < len(candidates); start += escalationBatchSize {
+		end := start + escalationBatchSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		flagged += flagOverdueBatch(candidates[start:end], now)
+	}
+
+	if flagged > 0 {
+		loggerFrom(ctx).Info("Flagged overdue complaints", "count", flagged)
+	}
+	return flagged
+}
+
+// flagOverdueBatch flags ids under a single write lock, re-checking each one
+// since it may have been resolved, deleted or re-prioritized since it was
+// picked
+func flagOverdueBatch(ids []int, now time.Time) int {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	flagged := 0
+	for _, id := range ids {
+		complaint, exists := storage.complaints[id]
+		if !exists || complaint.IsDeleted || complaint.SLABreachedAt != "" || !isOverdue(complaint, now) {
+			continue
+		}
+		complaint.SLABreachedAt = getCurrentTime()
+		addHistory(complaint, HistoryEntry{Action: historySLABreached, Detail: "Due at " + complaint.DueAt})
+		touchComplaint(complaint)
+		publishEvent(eventSLABreached, complaint, "")
+		flagged++
+	}
+	return flagged
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAddBusinessTime(t *testing.T) {
+	day := 24 * time.Hour
+	at := func(month time.Month, date, hour, minute int) time.Time {
+		return time.Date(2024, month, date, hour, minute, 0, 0, time.UTC)
+	}
+
+	// 2024-05-03 is a Friday
+	for _, tc := range []struct {
+		name  string
+		start time.Time
+		d     time.Duration
+		want  time.Time
+	}{
+		{"Within A Weekday", at(5, 1, 9, 0), 6 * time.Hour, at(5, 1, 15, 0)},
+		{"Monday Plus Three Days", at(5, 6, 10, 0), 3 * day, at(5, 9, 10, 0)},
+		{"Friday Afternoon Plus One Day", at(5, 3, 15, 0), day, at(5, 6, 15, 0)},
+		{"Friday Afternoon Plus Three Days", at(5, 3, 16, 30), 3 * day, at(5, 8, 16, 30)},
+		{"Friday Afternoon Plus A Few Hours", at(5, 3, 22, 0), 4 * time.Hour, at(5, 6, 2, 0)},
+		{"Ends Exactly At Friday Midnight", at(5, 3, 15, 0), 9 * time.Hour, at(5, 4, 0, 0)},
+		{"Saturday Starts On Monday", at(5, 4, 11, 0), day, at(5, 7, 0, 0)},
+		{"Sunday Night Starts On Monday", at(5, 5, 23, 59), 2 * time.Hour, at(5, 6, 2, 0)},
+		{"Spans Two Weekends", at(5, 3, 12, 0), 10 * day, at(5, 17, 12, 0)},
+		{"Zero On A Weekday", at(5, 1, 9, 0), 0, at(5, 1, 9, 0)},
+	} {
+		if got := addBusinessTime(tc.start, tc.d); !got.Equal(tc.want) {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want.Format(time.RFC1123), got.Format(time.RFC1123))
+		}
+	}
+
+	t.Run("Across A DST Change", func(t *testing.T) {
+		loc, err := time.LoadLocation("Europe/Berlin")
+		if err != nil {
+			t.Skipf("No time zone data: %v", err)
+		}
+		// Clocks go forward on Sunday 2024-03-31; Friday 15:00 plus one
+		// business day is still Monday 15:00 on the wall clock
+		start := time.Date(2024, 3, 29, 15, 0, 0, 0, loc)
+		if got, want := addBusinessTime(start, day), time.Date(2024, 4, 1, 15, 0, 0, 0, loc); !got.Equal(want) {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	})
+}
+
+func TestSLADeadlines(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	// Friday afternoon
+	fake := useFakeClock(t, time.Date(2024, 5, 3, 15, 0, 0, 0, time.Local))
+	_, code := registerTestUser(t, srv, "Impatient User", "impatient@example.com")
+
+	submit := func(title, priority string) Complaint {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: code, Title: title, Summary: "Summary of " + title, Rating: 5, Priority: priority,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Submit %q: expected 201, got %d (%s)", title, status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		return complaint
+	}
+
+	critical := submit("Fire", priorityCritical)
+	high := submit("Flood", priorityHigh)
+	config.SLALow = 0
+	low := submit("Squeaky door", priorityLow)
+
+	if critical.DueAt != "2024-05-06 15:00:00" || high.DueAt != "2024-05-08 15:00:00" {
+		t.Errorf("Expected Monday and Wednesday deadlines, got %q and %q", critical.DueAt, high.DueAt)
+	}
+	if low.DueAt != "" || critical.IsOverdue {
+		t.Errorf("Unexpected deadline state: low due %q, critical overdue %v", low.DueAt, critical.IsOverdue)
+	}
+
+	sub := eventBus.subscribe()
+	defer eventBus.unsubscribe(sub)
+
+	// Tuesday: the critical complaint is overdue
+	fake.Advance(4 * 24 * time.Hour)
+
+	t.Run("Overdue Filter", func(t *testing.T) {
+		overdue := true
+		_, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret, Overdue: &overdue})
+		var list []Complaint
+		resp.decode(t, &list)
+		if len(list) != 1 || list[0].ID != critical.ID || !list[0].IsOverdue {
+			t.Errorf("Expected only the critical complaint, got %+v", list)
+		}
+
+		notOverdue := false
+		_, resp = postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret, Overdue: &notOverdue})
+		resp.decode(t, &list)
+		if len(list) != 2 {
+			t.Errorf("Expected the other 2 complaints, got %+v", list)
+		}
+	})
+
+	t.Run("Breach Job", func(t *testing.T) {
+		if flagged := flagOverdueComplaints(context.Background(), clock.Now()); flagged != 1 {
+			t.Fatalf("Expected 1 complaint flagged, got %d", flagged)
+		}
+		if flagged := flagOverdueComplaints(context.Background(), clock.Now()); flagged != 0 {
+			t.Errorf("Expected a second run to flag nothing, got %d", flagged)
+		}
+
+		select {
+		case event := <-sub.events:
+			if event.Type != eventSLABreached || event.Complaint.ID != critical.ID {
+				t.Errorf("Expected sla.breached for %d, got %s for %d", critical.ID, event.Type, event.Complaint.ID)
+			}
+		default:
+			t.Error("Expected an sla.breached event")
+		}
+
+		viewed := assigneeOf(t, srv, critical.ID)
+		if viewed.SLABreachedAt == "" || len(viewed.History) != 1 || viewed.History[0].Action != historySLABreached {
+			t.Errorf("Expected the breach on record, got %+v", viewed)
+		}
+	})
+
+	t.Run("Priority Change Moves The Deadline", func(t *testing.T) {
+		current := assigneeOf(t, srv, critical.ID)
+		status, resp := postJSON(t, srv, "/setPriority", SetPriorityRequest{
+			SecretCode: adminSecret, ComplaintID: critical.ID, Priority: priorityMedium, Version: current.Version,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var updated Complaint
+		resp.decode(t, &updated)
+		if updated.DueAt != "2024-05-10 15:00:00" || updated.IsOverdue || updated.SLABreachedAt != "" {
+			t.Errorf("Expected a Friday deadline and no breach, got %q overdue=%v breached=%q", updated.DueAt, updated.IsOverdue, updated.SLABreachedAt)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		// Thursday: the high complaint is overdue; resolved ones never are
+		fake.Advance(2 * 24 * time.Hour)
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: low.ID})
+
+		_, resp := postJSON(t, srv, "/report", ReportRequest{SecretCode: adminSecret})
+		var report Report
+		resp.decode(t, &report)
+		if report.OverdueOpen != 1 {
+			t.Errorf("Expected 1 overdue complaint, got %d", report.OverdueOpen)
+		}
+		if len(report.ByDepartment) != 1 || report.ByDepartment[0].Overdue != 1 {
+			t.Errorf("Expected the overdue complaint under General, got %+v", report.ByDepartment)
+		}
+	})
+}
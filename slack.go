@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slackTimeout bounds a single webhook call
+const slackTimeout = 10 * time.Second
+
+// webhookPoster sends a JSON payload to a webhook URL
+type webhookPoster interface {
+	post(url string, payload []byte, eventID int, correlationID string) error
+}
+
+// httpPoster posts over HTTP with the event ID in an X-Event-ID header and
+// the correlation ID, when there is one, in an X-Correlation-Id header; any
+// status other than 2xx is an error
+type httpPoster struct {
+	client *http.Client
+}
+
+// webhookStatusError is a webhook response with a non-2xx status
+type webhookStatusError struct {
+	status int
+}
+
+func (e webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d", e.status)
+}
+
+// retryable reports whether sending again might succeed
+func (e webhookStatusError) retryable() bool {
+	return e.status == http.StatusTooManyRequests || e.status >= 500
+}
+
+func (p httpPoster) post(url string, payload []byte, eventID int, correlationID string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.Itoa(eventID))
+	if correlationID != "" {
+		req.Header.Set(correlationHeader, correlationID)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return webhookStatusError{status: resp.StatusCode}
+	}
+	return nil
+}
+
+// SlackMessage is the incoming-webhook payload
+type SlackMessage struct {
+	Text string `json:"text"`
+}
+
+// SlackNotifier is the outbox channel that posts high and critical
+// priority complaints, and escalations, to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL   string
+	linkTemplate string // complaint link with {id} in place of the ID; no link when empty
+	poster       webhookPoster
+}
+
+func newSlackNotifier(webhookURL, linkTemplate string) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL:   webhookURL,
+		linkTemplate: linkTemplate,
+		poster:       httpPoster{client: &http.Client{Timeout: slackTimeout}},
+	}
+}
+
+func (n *SlackNotifier) deliver(eventID int, correlationID string, payload []byte) error {
+	return n.poster.post(n.webhookURL, payload, eventID, correlationID)
+}
+
+// slackEscaper escapes the characters Slack treats as markup
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// message builds the post for event, if it is one Slack should hear about
+func (n *SlackNotifier) message(event Event) (interface{}, bool) {
+	complaint := event.Complaint
+
+	var headline string
+	switch {
+	case event.Type == eventComplaintCreated && (complaint.Priority == priorityHigh || complaint.Priority == priorityCritical):
+		headline = fmt.Sprintf(":rotating_light: New *%s* priority complaint #%d", complaint.Priority, complaint.ID)
+	case event.Type == eventComplaintEscalated:
+		headline = fmt.Sprintf(":warning: Complaint #%d escalated after %d days unresolved", complaint.ID, config.EscalateAfterDays)
+	default:
+		return nil, false
+	}
+
+	lines := []string{
+		headline,
+		"*" + slackEscaper.Replace(complaint.Title) + "*",
+		fmt.Sprintf("Submitted by %s · Rating %d/%d · Priority %s", slackEscaper.Replace(complaint.UserName), complaint.Rating, complaint.RatingScale.Max, complaint.Priority),
+	}
+	if n.linkTemplate != "" {
+		link := strings.ReplaceAll(n.linkTemplate, "{id}", strconv.Itoa(complaint.ID))
+		lines = append(lines, "<"+link+"|View complaint>")
+	}
+	return SlackMessage{Text: strings.Join(lines, "\n")}, true
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slackCapture is a fake incoming webhook that records every payload and
+// the event and correlation IDs of every request, and fails the first
+// failures requests with status
+type slackCapture struct {
+	mutex          sync.Mutex
+	messages       []SlackMessage
+	eventIDs       []string
+	correlationIDs []string
+	requests       int
+	failures       int
+	status         int
+	received       chan struct{}
+}
+
+func newSlackCapture(t *testing.T) (*slackCapture, *httptest.Server) {
+	t.Helper()
+	capture := &slackCapture{received: make(chan struct{}, 100)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capture.mutex.Lock()
+		defer capture.mutex.Unlock()
+		capture.requests++
+		capture.eventIDs = append(capture.eventIDs, r.Header.Get("X-Event-ID"))
+		capture.correlationIDs = append(capture.correlationIDs, r.Header.Get(correlationHeader))
+		if capture.requests <= capture.failures {
+			w.WriteHeader(capture.status)
+			return
+		}
+		var message SlackMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		capture.messages = append(capture.messages, message)
+		capture.received <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return capture, srv
+}
+
+func (c *slackCapture) wait(t *testing.T) SlackMessage {
+	t.Helper()
+	select {
+	case <-c.received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a Slack message")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.messages[len(c.messages)-1]
+}
+
+func TestSlackNotifier(t *testing.T) {
+	srv := newTestServer(t)
+	capture, hook := newSlackCapture(t)
+
+	useDispatcher(t, map[string]outboxChannel{outboxChannelSlack: newSlackNotifier(hook.URL, "https://portal.example.com/complaints/{id}")})
+
+	_, code := registerTestUser(t, srv, "Tom & <Jerry>", "tom@example.com")
+	submit := func(title, priority string) Complaint {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: code, Title: title, Summary: "Summary", Rating: 8, Priority: priority,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Submit: expected 201, got %d (%s)", status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		return complaint
+	}
+
+	t.Run("Urgent Complaint", func(t *testing.T) {
+		submit("Flickering lights", priorityLow) // not posted
+		complaint := submit("Gas smell", priorityCritical)
+
+		message := capture.wait(t)
+		want := ":rotating_light: New *critical* priority complaint #" + strconv.Itoa(complaint.ID) + "\n" +
+			"*Gas smell*\n" +
+			"Submitted by Tom &amp; &lt;Jerry&gt; · Rating 8/10 · Priority critical\n" +
+			"<https://portal.example.com/complaints/" + strconv.Itoa(complaint.ID) + "|View complaint>"
+		if message.Text != want {
+			t.Errorf("Unexpected message:\n%s\nwant:\n%s", message.Text, want)
+		}
+	})
+
+	t.Run("Escalation With Retries", func(t *testing.T) {
+		capture.mutex.Lock()
+		capture.failures, capture.status = capture.requests+2, http.StatusBadGateway
+		capture.mutex.Unlock()
+
+		escalateBatch([]int{1}, clock.Now())
+
+		message := capture.wait(t)
+		if !strings.HasPrefix(message.Text, ":warning: Complaint #1 escalated after 7 days unresolved") {
+			t.Errorf("Unexpected message: %s", message.Text)
+		}
+		capture.mutex.Lock()
+		defer capture.mutex.Unlock()
+		if len(capture.messages) != 2 {
+			t.Errorf("Expected 2 messages in total, got %d", len(capture.messages))
+		}
+	})
+}
+
+func TestSlackNotifierFailuresAreContained(t *testing.T) {
+	srv := newTestServer(t)
+	capture, hook := newSlackCapture(t)
+	capture.failures, capture.status = 100, http.StatusNotFound
+	useDispatcher(t, map[string]outboxChannel{outboxChannelSlack: newSlackNotifier(hook.URL, "")})
+
+	_, code := registerTestUser(t, srv, "Urgent User", "urgent@example.com")
+	status, _ := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+		SecretCode: code, Title: "Flood", Summary: "Water everywhere", Rating: 10, Priority: priorityHigh,
+	})
+	if status != http.StatusCreated {
+		t.Errorf("Expected the submit to succeed whatever Slack does, got %d", status)
+	}
+
+	waitForDeadLetters(t, 1)
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	if capture.requests != 1 {
+		t.Errorf("Expected a 404 not to be retried, got %d requests", capture.requests)
+	}
+}
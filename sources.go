@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Sources of complaints: the channel each came in through, recorded when it
+// is created and never changed
+const (
+	sourceAPI    = "api"    // the JSON API, /submitComplaint or /v1/complaints
+	sourceBatch  = "batch"  // scripts submitting in bulk through the API
+	sourceCLI    = "cli"    // complaintctl
+	sourceEmail  = "email"  // /ingestEmail
+	sourceImport = "import" // /importComplaints
+)
+
+// complaintSources lists every source, as listing filters accept them
+var complaintSources = []string{sourceAPI, sourceBatch, sourceCLI, sourceEmail, sourceImport}
+
+// clientSourceHeader names the source of a submission through the API. Only
+// clientSources are taken from it; anything else counts as sourceAPI.
+const clientSourceHeader = "X-Client-Source"
+
+var clientSources = []string{sourceBatch, sourceCLI}
+
+// requestSource is the source of a complaint submitted with r
+func requestSource(r *http.Request) string {
+	claimed := strings.ToLower(strings.TrimSpace(r.Header.Get(clientSourceHeader)))
+	for _, source := range clientSources {
+		if claimed == source {
+			return source
+		}
+	}
+	return sourceAPI
+}
+
+// SourceCount is the number of complaints that came in through one source
+type SourceCount struct {
+	Source  string `json:"source"`
+	Open    int    `json:"open"`    // all open complaints, regardless of range
+	Created int    `json:"created"` // complaints created in range
+}
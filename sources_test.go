@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestComplaintSources(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Mail User", "mailer@example.com")
+
+	// submit files a complaint through path, naming source in the header
+	submit := func(path, source, title string) Complaint {
+		t.Helper()
+		payload, _ := json.Marshal(SubmitComplaintRequest{SecretCode: code, Title: title, Summary: "Details", Rating: 5})
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+path, bytes.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer "+code)
+		if source != "" {
+			req.Header.Set(clientSourceHeader, source)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body testResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", resp.StatusCode, body.Error)
+		}
+		var complaint Complaint
+		body.decode(t, &complaint)
+		return complaint
+	}
+
+	want := map[int]string{}
+	for _, tc := range []struct {
+		path, header, source string
+	}{
+		{"/submitComplaint", "", sourceAPI},
+		{"/submitComplaint", "toaster", sourceAPI},
+		{"/submitComplaint", " CLI ", sourceCLI},
+		{"/v1/complaints", "", sourceAPI},
+		{"/v1/complaints", "batch", sourceBatch},
+		{"/v1/complaints", "import", sourceAPI}, // only the server says what was imported
+	} {
+		complaint := submit(tc.path, tc.header, "Through "+tc.path+" as "+tc.header)
+		if complaint.Source != tc.source {
+			t.Errorf("%s with %q: expected source %s, got %q", tc.path, tc.header, tc.source, complaint.Source)
+		}
+		want[complaint.ID] = tc.source
+	}
+
+	token := func() string {
+		status, resp := postJSON(t, srv, "/createApiToken", CreateAPITokenRequest{SecretCode: adminSecret, Label: "Mail gateway", Scope: scopeIngestEmail})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var created CreatedAPIToken
+		resp.decode(t, &created)
+		return created.Token
+	}()
+	httpResp, resp := doV1(t, srv, http.MethodPost, "/ingestEmail", token, IngestEmailRequest{From: "mailer@example.com", Subject: "Broken lift", Body: "Stuck again"})
+	if httpResp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201 from /ingestEmail, got %d (%s)", httpResp.StatusCode, resp.Error)
+	}
+	var emailed IngestEmailResult
+	resp.decode(t, &emailed)
+	want[emailed.Complaint.ID] = sourceEmail
+
+	status, resp := postImport(t, srv, []byte("email,title,summary,rating\nmailer@example.com,Old leak,From the spreadsheet,4\n"), map[string]string{"secret_code": adminSecret})
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200 from /importComplaints, got %d (%s)", status, resp.Error)
+	}
+	var imported ImportResult
+	resp.decode(t, &imported)
+	if imported.Imported != 1 {
+		t.Fatalf("Expected one imported complaint, got %+v", imported)
+	}
+	want[imported.Rows[0].ComplaintID] = sourceImport
+
+	t.Run("Stored", func(t *testing.T) {
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		for id, source := range want {
+			if got := storage.complaints[id].Source; got != source {
+				t.Errorf("Complaint %d: expected source %s, got %q", id, source, got)
+			}
+		}
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret, Source: "API"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var complaints []Complaint
+		resp.decode(t, &complaints)
+		if len(complaints) != 4 {
+			t.Errorf("Expected the 4 complaints from the API, got %d", len(complaints))
+		}
+		for _, complaint := range complaints {
+			if complaint.Source != sourceAPI {
+				t.Errorf("Expected only API complaints, got %+v", complaint)
+			}
+		}
+		if status, resp := postJSON(t, srv, "/getAllComplaintsForAdmin", GetComplaintsRequest{SecretCode: adminSecret, Source: "fax"}); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an unknown source, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/report", ReportRequest{SecretCode: adminSecret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var report Report
+		resp.decode(t, &report)
+		got := map[string]SourceCount{}
+		for _, count := range report.BySource {
+			got[count.Source] = count
+		}
+		// The imported complaint was created today too, so counts in range
+		for source, created := range map[string]int{sourceAPI: 4, sourceBatch: 1, sourceCLI: 1, sourceEmail: 1, sourceImport: 1} {
+			if got[source].Created != created || got[source].Open != created {
+				t.Errorf("Expected %d %s complaints, got %+v", created, source, got[source])
+			}
+		}
+		if len(report.BySource) != len(complaintSources) || report.BySource[0].Source != sourceAPI {
+			t.Errorf("Expected every source, busiest first, got %+v", report.BySource)
+		}
+	})
+
+	t.Run("Immutable", func(t *testing.T) {
+		path := "/v1/complaints/" + strconv.Itoa(emailed.Complaint.ID)
+		httpResp, body := doV1(t, srv, http.MethodPatch, path, adminSecret, map[string]interface{}{"source": sourceAPI})
+		if httpResp.StatusCode != http.StatusBadRequest || body.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("Expected 400 changing the source, got %d %s", httpResp.StatusCode, body.ErrorCode)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+type MyStatsRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+type UserStatsRequest struct {
+	SecretCode string `json:"secret_code"`
+	UserID     int    `json:"user_id"`
+}
+
+// UserStats sums up a user's complaints. Deleted complaints are left out;
+// merged ones count towards the total only, until they are resolved with
+// the complaint they were merged into. Averages are 0 when there is
+// nothing to average; the average rating is on the configured scale.
+type UserStats struct {
+	UserID                 int             `json:"user_id"`
+	UserName               string          `json:"user_name"`
+	TotalComplaints        int             `json:"total_complaints"`
+	Open                   int             `json:"open"`
+	Resolved               int             `json:"resolved"`
+	AverageResolutionHours float64         `json:"average_resolution_hours"`
+	AverageRating          float64         `json:"average_rating"`
+	OldestOpen             *StaleComplaint `json:"oldest_open,omitempty"`
+}
+
+// statsFor computes subject's UserStats at now. Callers must hold
+// storage.mutex.
+func statsFor(subject *User, now time.Time) UserStats {
+	stats := UserStats{UserID: subject.ID, UserName: subject.Name}
+	var totalResolution time.Duration
+	resolvedTimed, totalRating := 0, 0.0 // ratings normalized to 0-1
+	var oldest *Complaint
+	for _, complaint := range storage.complaints {
+		if complaint.UserID != subject.ID || complaint.IsDeleted {
+			continue
+		}
+		stats.TotalComplaints++
+		totalRating += complaint.RatingScale.normalize(complaint.Rating)
+
+		switch {
+		case complaint.IsResolved:
+			stats.Resolved++
+			created, createdErr := parseTimestamp(complaint.CreatedAt)
+			resolved, resolvedErr := parseTimestamp(complaint.ResolvedAt)
+			if createdErr == nil && resolvedErr == nil {
+				totalResolution += resolved.Sub(created)
+				resolvedTimed++
+			}
+		case isOpen(complaint):
+			stats.Open++
+			if oldest == nil || complaint.ID < oldest.ID {
+				oldest = complaint
+			}
+		}
+	}
+
+	if resolvedTimed > 0 {
+		stats.AverageResolutionHours = roundTo(totalResolution.Hours()/float64(resolvedTimed), 2)
+	}
+	if stats.TotalComplaints > 0 {
+		stats.AverageRating = roundTo(currentRatingScale().denormalize(totalRating/float64(stats.TotalComplaints)), 2)
+	}
+	if oldest != nil {
+		stats.OldestOpen = &StaleComplaint{
+			ID:        oldest.ID,
+			Reference: oldest.Reference,
+			Title:     oldest.Title,
+			UserName:  oldest.UserName,
+			CreatedAt: oldest.CreatedAt,
+		}
+		if created, err := parseTimestamp(oldest.CreatedAt); err == nil {
+			stats.OldestOpen.AgeDays = roundTo(now.Sub(created).Hours()/24, 1)
+		}
+	}
+	return stats
+}
+
+// /myStats - How many of the caller's complaints were resolved, and how fast
+func myStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req MyStatsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.RLock()
+	stats := statsFor(user, clock.Now())
+	storage.mutex.RUnlock()
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Statistics retrieved successfully",
+		Data:    stats,
+	})
+}
+
+// /userStats - The /myStats figures of any user (admin only)
+func userStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req UserStatsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("user_id", req.UserID)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	subject := scopedUser(user, req.UserID)
+	if subject == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Statistics retrieved successfully",
+		Data:    statsFor(subject, clock.Now()),
+	})
+}
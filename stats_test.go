@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUserStats(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 10, 0, 0, 0, time.Local))
+	aliceID, alice := registerTestUser(t, srv, "Alice", "alice@example.com")
+	bobID, bob := registerTestUser(t, srv, "Bob", "bob@example.com")
+
+	first := submitTestComplaint(t, srv, alice, "Heater broken", 4).ID
+	fake.Advance(2 * time.Hour)
+	second := submitTestComplaint(t, srv, alice, "Window stuck", 8).ID
+	fake.Advance(time.Hour)
+	third := submitTestComplaint(t, srv, alice, "Lift noisy", 6)
+	deleted := submitTestComplaint(t, srv, alice, "Never mind", 10).ID
+	postJSON(t, srv, "/deleteComplaint", DeleteComplaintRequest{SecretCode: alice, ComplaintID: deleted})
+	fake.Advance(3 * time.Hour)
+	for _, id := range []int{first, second} {
+		if status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: id}); status != http.StatusOK {
+			t.Fatalf("Resolve %d: expected 200, got %d (%s)", id, status, resp.Error)
+		}
+	}
+	fake.Advance(24 * time.Hour)
+
+	want := UserStats{
+		UserID:                 aliceID,
+		UserName:               "Alice",
+		TotalComplaints:        3,
+		Open:                   1,
+		Resolved:               2,
+		AverageResolutionHours: 5, // 6 and 4 hours
+		AverageRating:          6, // 4, 8 and 6; the deleted complaint is left out
+	}
+	wantOldest := StaleComplaint{ID: third.ID, Reference: third.Reference, Title: "Lift noisy", UserName: "Alice", CreatedAt: "2024-06-03 13:00:00", AgeDays: 1.1}
+
+	check := func(t *testing.T, status int, resp testResponse, want UserStats, wantOldest *StaleComplaint) {
+		t.Helper()
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var stats UserStats
+		resp.decode(t, &stats)
+		oldest := stats.OldestOpen
+		stats.OldestOpen = nil
+		if stats != want {
+			t.Errorf("Expected %+v, got %+v", want, stats)
+		}
+		if (oldest == nil) != (wantOldest == nil) || (oldest != nil && *oldest != *wantOldest) {
+			t.Errorf("Expected oldest open %+v, got %+v", wantOldest, oldest)
+		}
+	}
+
+	t.Run("Self", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/myStats", MyStatsRequest{SecretCode: alice})
+		check(t, status, resp, want, &wantOldest)
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/userStats", UserStatsRequest{SecretCode: adminSecret, UserID: aliceID})
+		check(t, status, resp, want, &wantOldest)
+	})
+
+	t.Run("No Complaints", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/myStats", MyStatsRequest{SecretCode: bob})
+		check(t, status, resp, UserStats{UserID: bobID, UserName: "Bob"}, nil)
+		status, resp = postJSON(t, srv, "/userStats", UserStatsRequest{SecretCode: adminSecret, UserID: bobID})
+		check(t, status, resp, UserStats{UserID: bobID, UserName: "Bob"}, nil)
+	})
+
+	t.Run("Access", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/userStats", UserStatsRequest{SecretCode: bob, UserID: aliceID}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a user, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/userStats", UserStatsRequest{SecretCode: adminSecret, UserID: 999}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for an unknown user, got %d", status)
+		}
+		_, _, orgAdmin := createTestOrganization(t, srv, "Acme", "admin@acme.example")
+		if status, _ := postJSON(t, srv, "/userStats", UserStatsRequest{SecretCode: orgAdmin, UserID: aliceID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for a user of another organization, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/userStats", UserStatsRequest{SecretCode: adminSecret}); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 without a user ID, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// TagRule tags the complaints of an organization whose title or summary
+// mentions one of its keywords. Keywords match whole words unless Partial is
+// set, so "ass" does not tag "classic" but "leak" with Partial tags
+// "leaking".
+type TagRule struct {
+	ID        int      `json:"id"`
+	OrgID     int      `json:"org_id"`
+	Keywords  []string `json:"keywords"` // in routing form, like a department's
+	Tag       string   `json:"tag"`
+	Partial   bool     `json:"partial,omitempty"` // also match inside longer words
+	CreatedBy int      `json:"created_by"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type AddTagRuleRequest struct {
+	SecretCode string   `json:"secret_code"`
+	Keywords   []string `json:"keywords"`
+	Tag        string   `json:"tag"`
+	Partial    bool     `json:"partial,omitempty"`
+	OrgID      int      `json:"org_id,omitempty"` // super-admins only; the caller's organization when left out
+}
+
+type ListTagRulesRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+type DeleteTagRuleRequest struct {
+	SecretCode string `json:"secret_code"`
+	RuleID     int    `json:"rule_id"`
+}
+
+type PreviewTagRulesRequest struct {
+	SecretCode string   `json:"secret_code"`
+	Title      string   `json:"title"`
+	Summary    string   `json:"summary,omitempty"`
+	Tags       []string `json:"tags,omitempty"`   // as they would be submitted
+	OrgID      int      `json:"org_id,omitempty"` // super-admins only; the caller's organization when left out
+}
+
+// TagRulePreview is what a complaint would be tagged with, and the rules
+// that matched it
+type TagRulePreview struct {
+	Tags  []string `json:"tags"`
+	Rules []int    `json:"rules"`
+}
+
+// matches reports whether text, in routing form, mentions one of rule's
+// keywords
+func (rule *TagRule) matches(text string) bool {
+	padded := " " + text + " "
+	for _, keyword := range rule.Keywords {
+		if strings.Contains(padded, " "+keyword+" ") || (rule.Partial && strings.Contains(text, keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTagRules adds the tags of orgID's rules that match title and summary
+// to tags, oldest rule first, and returns them with the IDs of the rules
+// that matched. The submitted tags come first; rule tags that would go over
+// maxTags are dropped. Callers must hold storage.mutex.
+func applyTagRules(orgID int, tags []string, title, summary string) ([]string, []int) {
+	rules := []*TagRule{}
+	for _, rule := range storage.tagRules {
+		if rule.OrgID == orgID {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	text := routingText(title + " " + summary)
+	merged := append([]string{}, tags...)
+	matched := []int{}
+	for _, rule := range rules {
+		if !rule.matches(text) {
+			continue
+		}
+		matched = append(matched, rule.ID)
+		if len(merged) < maxTags && !containsString(merged, rule.Tag) {
+			merged = append(merged, rule.Tag)
+		}
+	}
+	return merged, matched
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleOrganization is the organization a tag rule request acts on: the
+// caller's, or orgID when a super-admin names one. Callers must hold
+// storage.mutex.
+func ruleOrganization(user *User, orgID int) (int, *APIError) {
+	if orgID == 0 {
+		return user.OrgID, nil
+	}
+	if _, exists := storage.organizations[orgID]; !exists || !canSeeOrg(user, orgID) {
+		return 0, newAPIError(http.StatusNotFound, ErrCodeNotFound, "Organization not found")
+	}
+	return orgID, nil
+}
+
+// /addTagRule - Tag matching complaints of an organization on submission
+// (admin only)
+func addTagRuleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req AddTagRuleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	tag := strings.ToLower(sanitizeText(req.Tag, false))
+	keywords, err := normalizeKeywords(req.Keywords)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.check("keywords", err)
+	if err == nil && len(keywords) == 0 {
+		v.required("keywords", "")
+	}
+	v.required("tag", tag)
+	v.maxRunes("tag", tag, maxTagLength)
+	if req.OrgID < 0 {
+		v.add("org_id", "must not be negative")
+	}
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	orgID, apiErr := ruleOrganization(user, req.OrgID)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	storage.tagRuleIDGen++
+	rule := &TagRule{
+		ID:        storage.tagRuleIDGen,
+		OrgID:     orgID,
+		Keywords:  keywords,
+		Tag:       tag,
+		Partial:   req.Partial,
+		CreatedBy: user.ID,
+		CreatedAt: getCurrentTime(),
+	}
+	storage.tagRules[rule.ID] = rule
+
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Tag rule added successfully",
+		Data:    *rule,
+	})
+}
+
+// /listTagRules - Tag rules of the caller's organization, oldest first
+// (admin only)
+func listTagRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ListTagRulesRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	rules := []TagRule{}
+	for _, rule := range storage.tagRules {
+		if canSeeOrg(user, rule.OrgID) {
+			rules = append(rules, *rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Tag rules retrieved successfully",
+		Data:    rules,
+	})
+}
+
+// /deleteTagRule - Stop applying a tag rule (admin only). Complaints it
+// already tagged keep the tag.
+func deleteTagRuleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req DeleteTagRuleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.positiveID("rule_id", req.RuleID)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	rule, exists := storage.tagRules[req.RuleID]
+	if !exists || !canSeeOrg(user, rule.OrgID) {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Tag rule not found")
+		return
+	}
+	delete(storage.tagRules, rule.ID)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Tag rule deleted successfully",
+	})
+}
+
+// /previewTagRules - The tags a complaint with this text would get, without
+// submitting anything (admin only)
+func previewTagRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req PreviewTagRulesRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	title, summary := sanitizeText(req.Title, false), sanitizeText(req.Summary, true)
+	tags, err := normalizeTags(req.Tags)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.required("title", title)
+	v.maxRunes("title", title, maxTitleLength)
+	v.maxRunes("summary", summary, maxSummaryLength)
+	v.check("tags", err)
+	if req.OrgID < 0 {
+		v.add("org_id", "must not be negative")
+	}
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	orgID, apiErr := ruleOrganization(user, req.OrgID)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+	preview := TagRulePreview{}
+	preview.Tags, preview.Rules = applyTagRules(orgID, tags, title, summary)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Tag rules previewed successfully",
+		Data:    preview,
+	})
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestTagRuleMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    TagRule
+		text    string
+		matches bool
+	}{
+		{"whole word", TagRule{Keywords: []string{"leak"}}, "Water leak in the kitchen", true},
+		{"inside a word", TagRule{Keywords: []string{"ass"}}, "A classic mistake", false},
+		{"inside a word when partial", TagRule{Keywords: []string{"leak"}, Partial: true}, "The sink is leaking", true},
+		{"punctuation", TagRule{Keywords: []string{"leak"}}, "Leak! Again.", true},
+		{"phrase", TagRule{Keywords: []string{"no hot water"}}, "There is no hot-water since Monday", true},
+		{"phrase split up", TagRule{Keywords: []string{"no hot water"}}, "No water, hot or cold", false},
+		{"any keyword", TagRule{Keywords: []string{"leak", "flood", "water"}}, "Basement flood", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.rule.matches(routingText(tc.text)); got != tc.matches {
+				t.Errorf("%v matching %q = %v, want %v", tc.rule.Keywords, tc.text, got, tc.matches)
+			}
+		})
+	}
+}
+
+func TestTagRules(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Rule User", "rules@example.com")
+
+	addRule := func(secretCode string, req AddTagRuleRequest) TagRule {
+		t.Helper()
+		req.SecretCode = secretCode
+		status, resp := postJSON(t, srv, "/addTagRule", req)
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var rule TagRule
+		resp.decode(t, &rule)
+		return rule
+	}
+	submit := func(title, summary string, tags ...string) Complaint {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: code, Title: title, Summary: summary, Rating: 5, Tags: tags,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		return complaint
+	}
+
+	plumbing := addRule(adminSecret, AddTagRuleRequest{Keywords: []string{" Leak", "FLOOD", "water", "leak"}, Tag: " Plumbing "})
+	if !reflect.DeepEqual(plumbing.Keywords, []string{"leak", "flood", "water"}) || plumbing.Tag != "plumbing" {
+		t.Errorf("Expected normalized keywords and tag, got %+v", plumbing)
+	}
+	addRule(adminSecret, AddTagRuleRequest{Keywords: []string{"kitchen"}, Tag: "kitchen"})
+	addRule(adminSecret, AddTagRuleRequest{Keywords: []string{"ass"}, Tag: "abuse"})
+
+	t.Run("Applied On Submit", func(t *testing.T) {
+		complaint := submit("Water leak in kitchen", "It drips", "urgent", "plumbing")
+		if want := []string{"urgent", "plumbing", "kitchen"}; !reflect.DeepEqual(complaint.Tags, want) {
+			t.Errorf("Expected %v, submitted tags first and no duplicates, got %v", want, complaint.Tags)
+		}
+		if complaint := submit("A classic mistake", "Assorted passwords"); len(complaint.Tags) != 0 {
+			t.Errorf("Expected no tags from words merely containing a keyword, got %v", complaint.Tags)
+		}
+	})
+
+	t.Run("Cap", func(t *testing.T) {
+		tags := []string{}
+		for i := 0; i < maxTags-1; i++ {
+			tags = append(tags, fmt.Sprintf("tag-%d", i))
+		}
+		complaint := submit("Flooded kitchen", "Water everywhere", tags...)
+		if want := append(tags, "plumbing"); !reflect.DeepEqual(complaint.Tags, want) {
+			t.Errorf("Expected the first rule's tag to fill the last slot, got %v", complaint.Tags)
+		}
+		complaint = submit("Flooded kitchen", "Water everywhere", append(tags, "tag-full")...)
+		if len(complaint.Tags) != maxTags || complaint.Tags[maxTags-1] != "tag-full" {
+			t.Errorf("Expected submitted tags to keep every slot, got %v", complaint.Tags)
+		}
+	})
+
+	t.Run("Preview", func(t *testing.T) {
+		storage.mutex.RLock()
+		count := len(storage.complaints)
+		storage.mutex.RUnlock()
+		status, resp := postJSON(t, srv, "/previewTagRules", PreviewTagRulesRequest{
+			SecretCode: adminSecret, Title: "Kitchen flood", Summary: "No water pressure", Tags: []string{"Urgent"},
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var preview TagRulePreview
+		resp.decode(t, &preview)
+		want := TagRulePreview{Tags: []string{"urgent", "plumbing", "kitchen"}, Rules: []int{plumbing.ID, plumbing.ID + 1}}
+		if !reflect.DeepEqual(preview, want) {
+			t.Errorf("Expected %+v, got %+v", want, preview)
+		}
+		storage.mutex.RLock()
+		if len(storage.complaints) != count {
+			t.Error("Expected a preview not to submit anything")
+		}
+		storage.mutex.RUnlock()
+		if status, _ := postJSON(t, srv, "/previewTagRules", PreviewTagRulesRequest{SecretCode: code, Title: "Leak"}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a user, got %d", status)
+		}
+	})
+
+	t.Run("Organizations", func(t *testing.T) {
+		invite, orgAdminID, orgAdmin := createTestOrganization(t, srv, "Acme", "admin@acme.example")
+		addRule(orgAdmin, AddTagRuleRequest{Keywords: []string{"leak"}, Tag: "acme-leak"})
+		_, acmeUser := registerInOrganization(t, srv, "Acme User", "user@acme.example", invite)
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: acmeUser, Title: "Roof leak", Summary: "Summary", Rating: 5})
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		if status != http.StatusCreated || !reflect.DeepEqual(complaint.Tags, []string{"acme-leak"}) {
+			t.Errorf("Expected only Acme's rule to apply, got %d %v", status, complaint.Tags)
+		}
+		if complaint := submit("Roof leak", "Summary"); !reflect.DeepEqual(complaint.Tags, []string{"plumbing"}) {
+			t.Errorf("Expected Acme's rule not to apply elsewhere, got %v", complaint.Tags)
+		}
+
+		_, resp = postJSON(t, srv, "/listTagRules", ListTagRulesRequest{SecretCode: orgAdmin})
+		var rules []TagRule
+		resp.decode(t, &rules)
+		if len(rules) != 1 || rules[0].CreatedBy != orgAdminID || rules[0].OrgID == storage.defaultOrgID {
+			t.Errorf("Expected only Acme's rule listed, got %+v", rules)
+		}
+		if status, _ := postJSON(t, srv, "/deleteTagRule", DeleteTagRuleRequest{SecretCode: orgAdmin, RuleID: plumbing.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 deleting another organization's rule, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/addTagRule", AddTagRuleRequest{SecretCode: orgAdmin, Keywords: []string{"x"}, Tag: "x", OrgID: storage.defaultOrgID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 adding a rule to another organization, got %d", status)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/addTagRule", AddTagRuleRequest{SecretCode: adminSecret, Keywords: []string{" ", "!"}})
+		var fieldErrs []FieldError
+		resp.decode(t, &fieldErrs)
+		want := []FieldError{{Field: "keywords", Error: "is required"}, {Field: "tag", Error: "is required"}}
+		if status != http.StatusBadRequest || !reflect.DeepEqual(fieldErrs, want) {
+			t.Errorf("Expected 400 with %+v, got %d %+v", want, status, fieldErrs)
+		}
+		if status, _ := postJSON(t, srv, "/addTagRule", AddTagRuleRequest{SecretCode: code, Keywords: []string{"x"}, Tag: "x"}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a user, got %d", status)
+		}
+	})
+
+	t.Run("Survives Restore", func(t *testing.T) {
+		document := fetchBackup(t, srv)
+		if status, resp := restoreBackup(t, srv, document); status != http.StatusOK {
+			t.Fatalf("Expected 200 restoring, got %d (%s)", status, resp.Error)
+		}
+		if complaint := submit("Pipe leak", "Summary"); !reflect.DeepEqual(complaint.Tags, []string{"plumbing"}) {
+			t.Errorf("Expected rules to apply after a restore, got %v", complaint.Tags)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/deleteTagRule", DeleteTagRuleRequest{SecretCode: adminSecret, RuleID: plumbing.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if complaint := submit("Pipe leak", "Summary"); len(complaint.Tags) != 0 {
+			t.Errorf("Expected a deleted rule to stop applying, got %v", complaint.Tags)
+		}
+		if status, _ := postJSON(t, srv, "/deleteTagRule", DeleteTagRuleRequest{SecretCode: adminSecret, RuleID: plumbing.ID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 deleting it again, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Limits on complaint tags; the length is in runes
+const (
+	maxTags      = 10
+	maxTagLength = 30
+)
+
+// normalizeTags lowercases and deduplicates tags, keeping their order.
+// Blank tags are dropped.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(sanitizeText(tag, false))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if err := checkLength("Each tag", tag, maxTagLength); err != nil {
+			return nil, err
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("A complaint can have at most %d tags", maxTags)
+	}
+	return normalized, nil
+}
+
+// hasTags reports whether complaint carries every tag in want
+func hasTags(complaint *Complaint, want []string) bool {
+	for _, tag := range want {
+		found := false
+		for _, have := range complaint.Tags {
+			found = found || have == tag
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+type SetComplaintTagsRequest struct {
+	SecretCode  string   `json:"secret_code"`
+	ComplaintID int      `json:"complaint_id"`
+	Tags        []string `json:"tags"`              // replaces the current tags; empty clears them
+	Version     int      `json:"version,omitempty"` // opt-in concurrency check
+}
+
+// TagCount is how many visible complaints carry a tag
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// /setComplaintTags - Replace a complaint's tags (owner or admin)
+func setComplaintTagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req SetComplaintTagsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint, apiErr := accessibleComplaint(user, req.ComplaintID, false, "Access denied. You can only tag your own complaints")
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	if req.Version != 0 {
+		if err := checkVersion(user, complaint, req.Version); err != nil {
+			respondWithAPIError(w, err)
+			return
+		}
+	}
+
+	complaint.Tags = tags
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Tags updated successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
+
+// /listTags - Tags in use with the number of complaints carrying each, most
+// used first. Admins see every complaint; other users only their own.
+func listTagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req GetComplaintsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	caller := actorOf(user)
+	storage.mutex.RLock()
+	counts := map[string]int{}
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted || isArchived(complaint) || !canViewComplaint(caller, complaint).allowed() {
+			continue
+		}
+		for _, tag := range complaint.Tags {
+			counts[tag]++
+		}
+	}
+	storage.mutex.RUnlock()
+
+	tags := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Tags retrieved successfully",
+		Data:    tags,
+	})
+}
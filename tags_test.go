@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{" Building-A ", "recurring", "BUILDING-a", "", "<b>Vendor</b>"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []string{"building-a", "recurring", "vendor"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Expected %v, got %v", want, tags)
+	}
+
+	if _, err := normalizeTags([]string{strings.Repeat("x", maxTagLength+1)}); err == nil {
+		t.Error("Expected an error for a tag that is too long")
+	}
+
+	var many []string
+	for i := 0; i <= maxTags; i++ {
+		many = append(many, fmt.Sprintf("tag-%d", i))
+	}
+	if _, err := normalizeTags(many); err == nil {
+		t.Errorf("Expected an error for %d tags", len(many))
+	}
+	// Duplicates do not count towards the cap
+	if tags, err := normalizeTags(append(many[:maxTags], "TAG-0", "tag-1")); err != nil || len(tags) != maxTags {
+		t.Errorf("Expected %d tags, got %v (%v)", maxTags, tags, err)
+	}
+}
+
+func TestComplaintTags(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Tagging User", "tags@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+
+	submit := func(secretCode, title string, tags ...string) Complaint {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{
+			SecretCode: secretCode, Title: title, Summary: "Summary of " + title, Rating: 5, Tags: tags,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Submit %q: expected status 201, got %d (%s)", title, status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		return complaint
+	}
+
+	heating := submit(code, "No heating", "Building-A", "recurring")
+	lift := submit(code, "Lift broken", "building-a", "vendor")
+	other := submit(otherCode, "Noise", "building-b", "recurring")
+	if !reflect.DeepEqual(heating.Tags, []string{"building-a", "recurring"}) {
+		t.Errorf("Expected normalized tags on submit, got %v", heating.Tags)
+	}
+
+	t.Run("Set", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/setComplaintTags", SetComplaintTagsRequest{
+			SecretCode: code, ComplaintID: lift.ID, Tags: []string{"Vendor", "building-a", "urgent", "vendor"}, Version: lift.Version,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var updated Complaint
+		resp.decode(t, &updated)
+		if !reflect.DeepEqual(updated.Tags, []string{"vendor", "building-a", "urgent"}) || updated.Version != lift.Version+1 {
+			t.Errorf("Unexpected tags %v at version %d", updated.Tags, updated.Version)
+		}
+
+		status, resp = postJSON(t, srv, "/setComplaintTags", SetComplaintTagsRequest{
+			SecretCode: code, ComplaintID: lift.ID, Tags: []string{"stale"}, Version: lift.Version,
+		})
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeVersionConflict {
+			t.Errorf("Expected 409 VERSION_CONFLICT, got %d %s", status, resp.ErrorCode)
+		}
+
+		status, _ = postJSON(t, srv, "/setComplaintTags", SetComplaintTagsRequest{
+			SecretCode: otherCode, ComplaintID: lift.ID, Tags: []string{"mine"},
+		})
+		if status != http.StatusNotFound {
+			t.Errorf("Expected 404 for another user's complaint, got %d", status)
+		}
+
+		status, _ = postJSON(t, srv, "/setComplaintTags", SetComplaintTagsRequest{
+			SecretCode: adminSecret, ComplaintID: other.ID, Tags: []string{"building-b", "recurring", "noise"},
+		})
+		if status != http.StatusOK {
+			t.Errorf("Expected an admin to tag any complaint, got %d", status)
+		}
+
+		tooMany := make([]string, maxTags+1)
+		for i := range tooMany {
+			tooMany[i] = fmt.Sprintf("t%d", i)
+		}
+		status, resp = postJSON(t, srv, "/setComplaintTags", SetComplaintTagsRequest{
+			SecretCode: code, ComplaintID: heating.ID, Tags: tooMany,
+		})
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("Expected 400 for too many tags, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		listIDs := func(endpoint, secretCode string, tags ...string) []int {
+			t.Helper()
+			status, resp := postJSON(t, srv, endpoint, GetComplaintsRequest{SecretCode: secretCode, Tags: tags})
+			if status != http.StatusOK {
+				t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+			}
+			var list []Complaint
+			resp.decode(t, &list)
+			ids := []int{}
+			for _, c := range list {
+				ids = append(ids, c.ID)
+			}
+			return ids
+		}
+
+		for _, tc := range []struct {
+			endpoint string
+			tags     []string
+			want     []int
+		}{
+			{"/getAllComplaintsForAdmin", []string{"recurring"}, []int{heating.ID, other.ID}},
+			{"/getAllComplaintsForAdmin", []string{"Building-A"}, []int{heating.ID, lift.ID}},
+			{"/getAllComplaintsForAdmin", []string{"building-a", "vendor"}, []int{lift.ID}},
+			{"/getAllComplaintsForAdmin", []string{"building-a", "noise"}, []int{}},
+			{"/getAllComplaintsForUser", []string{"recurring"}, []int{heating.ID}},
+		} {
+			secretCode := adminSecret
+			if tc.endpoint == "/getAllComplaintsForUser" {
+				secretCode = code
+			}
+			if got := listIDs(tc.endpoint, secretCode, tc.tags...); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s with tags %v: expected %v, got %v", tc.endpoint, tc.tags, tc.want, got)
+			}
+		}
+	})
+
+	t.Run("Counts", func(t *testing.T) {
+		counts := func(secretCode string) []TagCount {
+			t.Helper()
+			status, resp := postJSON(t, srv, "/listTags", GetComplaintsRequest{SecretCode: secretCode})
+			if status != http.StatusOK {
+				t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+			}
+			var tags []TagCount
+			resp.decode(t, &tags)
+			return tags
+		}
+
+		want := []TagCount{
+			{"building-a", 2}, {"recurring", 2},
+			{"building-b", 1}, {"noise", 1}, {"urgent", 1}, {"vendor", 1},
+		}
+		if got := counts(adminSecret); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected admin counts %v, got %v", want, got)
+		}
+
+		// Users only count their own complaints
+		want = []TagCount{{"building-b", 1}, {"noise", 1}, {"recurring", 1}}
+		if got := counts(otherCode); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected user counts %v, got %v", want, got)
+		}
+	})
+}
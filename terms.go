@@ -0,0 +1,201 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Users accept the terms of service version set with -tos-version when they
+// register, and again through /acceptTos whenever it changes. Until they
+// have accepted the current version, every authenticated request is refused
+// with 428, apart from /login and /acceptTos. Without -tos-version nothing
+// is asked of anyone.
+
+type AcceptTOSRequest struct {
+	SecretCode string `json:"secret_code"`
+	Version    string `json:"version"` // must be the current version, so nobody accepts terms they were not shown
+}
+
+// TermsRequired is the data of a 428 response
+type TermsRequired struct {
+	CurrentVersion  string `json:"current_version" xml:"current_version"`
+	AcceptedVersion string `json:"accepted_version,omitempty" xml:"accepted_version,omitempty"`
+}
+
+type ListUsersRequest struct {
+	SecretCode string `json:"secret_code"`
+}
+
+// UserSummary is a user as admins list them, without secret code or
+// complaints
+type UserSummary struct {
+	ID            int    `json:"id" xml:"id"`
+	Name          string `json:"name" xml:"name"`
+	Email         string `json:"email" xml:"email"`
+	OrgID         int    `json:"org_id" xml:"org_id"`
+	IsAdmin       bool   `json:"is_admin" xml:"is_admin"`
+	IsSuperAdmin  bool   `json:"is_super_admin,omitempty" xml:"is_super_admin,omitempty"`
+	LastLoginAt   string `json:"last_login_at,omitempty" xml:"last_login_at,omitempty"`
+	TOSVersion    string `json:"tos_version,omitempty" xml:"tos_version,omitempty"`
+	TOSAcceptedAt string `json:"tos_accepted_at,omitempty" xml:"tos_accepted_at,omitempty"`
+	TOSCurrent    bool   `json:"tos_current" xml:"tos_current"` // accepted the current version, or none is configured
+	IsActive      bool   `json:"is_active" xml:"is_active"`
+	DeactivatedAt string `json:"deactivated_at,omitempty" xml:"deactivated_at,omitempty"`
+}
+
+// termsRequired reports whether the terms of service are in force
+func termsRequired() bool {
+	return config.TOSVersion != ""
+}
+
+// acceptedTerms reports whether user may use the API under the current
+// terms. Callers must hold storage.mutex or storage.usersMutex.
+func acceptedTerms(user *User) bool {
+	return !termsRequired() || user.TOSVersion == config.TOSVersion
+}
+
+// requireCurrentTerms answers 428 and returns false when user has not
+// accepted the current terms of service
+func requireCurrentTerms(w http.ResponseWriter, user *User) bool {
+	storage.usersMutex.RLock()
+	accepted, version := acceptedTerms(user), user.TOSVersion
+	storage.usersMutex.RUnlock()
+	if accepted {
+		return true
+	}
+
+	respondWithAPIError(w, &APIError{
+		Status:  http.StatusPreconditionRequired,
+		Code:    ErrCodeTermsNotAccepted,
+		Message: "The terms of service have changed. Accept version " + config.TOSVersion + " with /acceptTos to continue",
+		Data:    TermsRequired{CurrentVersion: config.TOSVersion, AcceptedVersion: version},
+	})
+	return false
+}
+
+// recordTermsAcceptance marks user as having accepted the current terms.
+// Callers must hold storage.mutex and storage.usersMutex.
+func recordTermsAcceptance(user *User) {
+	if termsRequired() {
+		user.TOSVersion = config.TOSVersion
+		user.TOSAcceptedAt = getCurrentTime()
+	}
+}
+
+// /acceptTos - Accept the current terms of service
+func acceptTOSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req AcceptTOSRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	version := strings.TrimSpace(req.Version)
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.required("version", version)
+	if version != "" && termsRequired() && version != config.TOSVersion {
+		v.add("version", "must be the current version, "+config.TOSVersion)
+	}
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	// Users who have not accepted the current terms must be able to
+	user, _ := authenticateCredential(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !termsRequired() {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "No terms of service are configured")
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	storage.usersMutex.Lock()
+	defer storage.usersMutex.Unlock()
+
+	if user.TOSVersion != config.TOSVersion {
+		recordTermsAcceptance(user)
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Terms of service accepted",
+		Data:    summarizeUser(user),
+	})
+}
+
+// summarizeUser lists user for admins. Callers must hold storage.mutex or
+// storage.usersMutex.
+func summarizeUser(user *User) UserSummary {
+	return UserSummary{
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		OrgID:         user.OrgID,
+		IsAdmin:       user.IsAdmin,
+		IsSuperAdmin:  user.IsSuperAdmin,
+		LastLoginAt:   lastLoginAt(user),
+		TOSVersion:    user.TOSVersion,
+		TOSAcceptedAt: user.TOSAcceptedAt,
+		TOSCurrent:    acceptedTerms(user),
+		IsActive:      user.IsActive,
+		DeactivatedAt: user.DeactivatedAt,
+	}
+}
+
+// /listUsers - The users of the caller's organization, with the terms of
+// service version each accepted (admin only)
+func listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ListUsersRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.usersMutex.RLock()
+	defer storage.usersMutex.RUnlock()
+
+	users := []UserSummary{}
+	for _, candidate := range storage.users {
+		if canSeeOrg(user, candidate.OrgID) {
+			users = append(users, summarizeUser(candidate))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Users retrieved successfully",
+		Data:    users,
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTermsOfService(t *testing.T) {
+	srv := newTestServer(t)
+	config.TOSVersion = "2024-06"
+
+	var code string
+	t.Run("Registration Needs Acceptance", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/register", RegisterRequest{Name: "Terms User", Email: "terms@example.com"})
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Fatalf("Expected 400 without accept_tos, got %d %s", status, resp.ErrorCode)
+		}
+		var fields []FieldError
+		resp.decode(t, &fields)
+		if len(fields) != 1 || fields[0].Field != "accept_tos" {
+			t.Errorf("Expected an accept_tos error, got %+v", fields)
+		}
+
+		status, resp = postJSON(t, srv, "/register", RegisterRequest{Name: "Terms User", Email: "terms@example.com", AcceptTOS: true})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d (%s)", status, resp.Error)
+		}
+		var user User
+		resp.decode(t, &user)
+		if user.TOSVersion != "2024-06" || user.TOSAcceptedAt == "" {
+			t.Errorf("Expected the accepted version and time, got %q %q", user.TOSVersion, user.TOSAcceptedAt)
+		}
+		code = user.SecretCode
+
+		if status, _ := postJSON(t, srv, "/me", MeRequest{SecretCode: code}); status != http.StatusOK {
+			t.Errorf("Expected 200 on the current version, got %d", status)
+		}
+	})
+
+	t.Run("Gate After A Version Bump", func(t *testing.T) {
+		config.TOSVersion = "2024-09"
+
+		status, resp := postJSON(t, srv, "/me", MeRequest{SecretCode: code})
+		if status != http.StatusPreconditionRequired || resp.ErrorCode != ErrCodeTermsNotAccepted {
+			t.Fatalf("Expected 428 TERMS_NOT_ACCEPTED, got %d %s", status, resp.ErrorCode)
+		}
+		var required TermsRequired
+		resp.decode(t, &required)
+		if required.CurrentVersion != "2024-09" || required.AcceptedVersion != "2024-06" {
+			t.Errorf("Expected both versions, got %+v", required)
+		}
+		if status, _ := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: "Gated", Summary: "Not yet", Rating: 5}); status != http.StatusPreconditionRequired {
+			t.Errorf("Expected 428 on writes too, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/listUsers", ListUsersRequest{SecretCode: adminSecret}); status != http.StatusPreconditionRequired {
+			t.Errorf("Expected admins to be gated as well, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/login", LoginRequest{SecretCode: code}); status != http.StatusOK {
+			t.Errorf("Expected login to keep working, got %d", status)
+		}
+	})
+
+	t.Run("Re-acceptance", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/acceptTos", AcceptTOSRequest{SecretCode: code, Version: "2024-06"})
+		if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+			t.Errorf("Expected 400 for an outdated version, got %d %s", status, resp.ErrorCode)
+		}
+
+		status, resp = postJSON(t, srv, "/acceptTos", AcceptTOSRequest{SecretCode: code, Version: "2024-09"})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		if status, _ := postJSON(t, srv, "/me", MeRequest{SecretCode: code}); status != http.StatusOK {
+			t.Errorf("Expected 200 after accepting, got %d", status)
+		}
+
+		postJSON(t, srv, "/acceptTos", AcceptTOSRequest{SecretCode: adminSecret, Version: "2024-09"})
+		status, resp = postJSON(t, srv, "/listUsers", ListUsersRequest{SecretCode: adminSecret})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var users []UserSummary
+		resp.decode(t, &users)
+		if len(users) != 2 {
+			t.Fatalf("Expected two users, got %+v", users)
+		}
+		for _, user := range users {
+			if user.TOSVersion != "2024-09" || !user.TOSCurrent {
+				t.Errorf("Expected user %d on 2024-09, got %q", user.ID, user.TOSVersion)
+			}
+		}
+
+		if status, _ := postJSON(t, srv, "/listUsers", ListUsersRequest{SecretCode: code}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a regular user, got %d", status)
+		}
+	})
+
+	t.Run("Off Without A Version", func(t *testing.T) {
+		config.TOSVersion = ""
+		if status, _ := postJSON(t, srv, "/register", RegisterRequest{Name: "Free User", Email: "free@example.com"}); status != http.StatusCreated {
+			t.Errorf("Expected registration without terms, got %d", status)
+		}
+		if status, _ := postJSON(t, srv, "/acceptTos", AcceptTOSRequest{SecretCode: code, Version: "2024-09"}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 with no terms configured, got %d", status)
+		}
+	})
+}
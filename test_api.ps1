@@ -6,7 +6,7 @@ Write-Host "=================================" -ForegroundColor Green
 
 # Start the server in background
 Write-Host "`nStarting API server..." -ForegroundColor Yellow
-$serverJob = Start-Job -ScriptBlock { Set-Location "C:\Users\ASUS\Downloads\1_Aditya Rastogi"; go run main.go }
+$serverJob = Start-Job -ScriptBlock { Set-Location "C:\Users\ASUS\Downloads\1_Aditya Rastogi"; go run . }
 
 # Wait for server to start
 Start-Sleep -Seconds 3
@@ -175,5 +175,5 @@ finally {
     Write-Host "Server stopped." -ForegroundColor Green
 }
 
-Write-Host "`nTo start the server manually, run: go run main.go" -ForegroundColor Cyan
+Write-Host "`nTo start the server manually, run: go run ." -ForegroundColor Cyan
 Write-Host "Server will be available at: http://localhost:8080" -ForegroundColor Cyan
\ No newline at end of file
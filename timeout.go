@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamingPaths hold a connection open on purpose, so the request timeout
+// does not apply to them. They still stop when the client goes away.
+var streamingPaths = map[string]bool{
+	"/events":           true,
+	"/ws":               true,
+	"/exportComplaints": true,
+	"/exportReport":     true,
+}
+
+// timeoutWriter buffers a response so that it can be thrown away if the
+// handler runs out of time
+type timeoutWriter struct {
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+	mutex    sync.Mutex
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.status == 0 && !tw.timedOut {
+		tw.status = code
+	}
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
+
+// withTimeout cancels the request context after timeout and answers 503 if
+// the handler has not finished by then. Handlers see the cancellation
+// through r.Context() and should stop any remaining work.
+func withTimeout(timeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if timeout <= 0 || streamingPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			// A panic here would otherwise take down the whole server
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mutex.Lock()
+			defer tw.mutex.Unlock()
+			for name, values := range tw.header {
+				w.Header()[name] = values
+			}
+			if tw.status == 0 {
+				tw.status = http.StatusOK
+			}
+			w.WriteHeader(tw.status)
+			w.Write(tw.buf.Bytes())
+		case <-ctx.Done():
+			tw.mutex.Lock()
+			tw.timedOut = true
+			tw.mutex.Unlock()
+			// Nobody is listening when the client has gone away
+			if ctx.Err() == context.DeadlineExceeded {
+				respondWithError(negotiated(w, r), http.StatusServiceUnavailable, ErrCodeTimeout, "Request timed out")
+			}
+		}
+	})
+}
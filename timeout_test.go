@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	abandoned := make(chan error, 1)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			// Anything written now is discarded
+			w.Write([]byte("too late"))
+			abandoned <- r.Context().Err()
+		case <-time.After(5 * time.Second):
+			abandoned <- nil
+		}
+	})
+	srv := httptest.NewServer(withTimeout(50*time.Millisecond, slow))
+	defer srv.Close()
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/slow")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the timeout to answer promptly, took %s", elapsed)
+	}
+	var body testResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected the JSON envelope: %v", err)
+	}
+	if body.Success || body.ErrorCode != ErrCodeTimeout {
+		t.Errorf("Expected REQUEST_TIMEOUT, got %+v", body)
+	}
+
+	select {
+	case err := <-abandoned:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Expected the handler to be abandoned at the deadline, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the handler to see its context cancelled")
+	}
+}
+
+func TestRequestTimeoutPassesResponsesThrough(t *testing.T) {
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handled", "yes")
+		respondWithError(w, http.StatusTeapot, ErrCodeValidationFailed, "Short and stout")
+	})
+	srv := httptest.NewServer(withTimeout(time.Second, fast))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/fast")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot || resp.Header.Get("X-Handled") != "yes" {
+		t.Errorf("Expected the handler's status and headers, got %d %q", resp.StatusCode, resp.Header.Get("X-Handled"))
+	}
+	var body testResponse
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.Error != "Short and stout" {
+		t.Errorf("Expected the handler's body, got %+v", body)
+	}
+}
+
+func TestStreamingRoutesSkipRequestTimeout(t *testing.T) {
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+			w.Write([]byte("still streaming"))
+		}
+	})
+	srv := httptest.NewServer(withTimeout(20*time.Millisecond, stream))
+	defer srv.Close()
+
+	for path := range streamingPaths {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		var buf [64]byte
+		n, _ := resp.Body.Read(buf[:])
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(buf[:n]) != "still streaming" {
+			t.Errorf("Expected %s to outlive the request timeout, got %d %q", path, resp.StatusCode, buf[:n])
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certReloader serves a certificate pair from disk and picks up a renewed
+// pair, as written by certbot or a similar tool, without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex   sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// newCertReloader loads the pair once so that unreadable or mismatched
+// files stop the server at startup rather than on the first handshake
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both -tls-cert and -tls-key are required to serve HTTPS")
+	}
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	info, err := os.Stat(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading TLS certificate: %w", err)
+	}
+	if err := reloader.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+func (c *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate %s and key %s: %w", c.certFile, c.keyFile, err)
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+// GetCertificate reloads the pair when the certificate file has changed. A
+// pair that fails to load is logged and the previous one stays in use.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if info, err := os.Stat(c.certFile); err == nil && !info.ModTime().Equal(c.modTime) {
+		if err := c.load(info.ModTime()); err != nil {
+			logger.Error("Keeping the current TLS certificate", logKeyError, err)
+			c.modTime = info.ModTime()
+		}
+	}
+	return c.cert, nil
+}
+
+// newTLSConfig serves the pair in certFile and keyFile
+func newTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}, nil
+}
+
+// newAutocertManager gets and renews certificates from Let's Encrypt for
+// the comma-separated hostnames in domains, and for no others, keeping them
+// in cacheDir so a restart does not ask for new ones. email, when set, is
+// given to Let's Encrypt for expiry notices. Certificates are only requested
+// on the first handshake, so only the settings are checked here.
+func newAutocertManager(domains, cacheDir, email string) (*autocert.Manager, error) {
+	var hosts []string
+	for _, host := range strings.Split(domains, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			continue
+		}
+		if strings.ContainsAny(host, ":/*@ ") || !strings.Contains(host, ".") {
+			return nil, fmt.Errorf("-autocert-domains: %q is not a hostname", host)
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("-autocert-domains needs at least one hostname")
+	}
+	if cacheDir == "" {
+		return nil, errors.New("-autocert-cache is required with -autocert-domains")
+	}
+	if info, err := os.Stat(cacheDir); err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("-autocert-cache %s is not a directory", cacheDir)
+	}
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      email,
+	}, nil
+}
+
+// serverTLS is the TLS config the flags ask for: a certificate pair from
+// disk, Let's Encrypt for -autocert-domains, or nil for plain HTTP. With
+// Let's Encrypt the manager is returned too, as the plain-HTTP listener has
+// to answer its challenges.
+func serverTLS() (*tls.Config, *autocert.Manager, error) {
+	pair := config.TLSCertFile != "" || config.TLSKeyFile != ""
+	switch {
+	case pair && config.AutocertDomains != "":
+		return nil, nil, errors.New("-autocert-domains cannot be used with -tls-cert and -tls-key")
+	case pair:
+		tlsConfig, err := newTLSConfig(config.TLSCertFile, config.TLSKeyFile)
+		return tlsConfig, nil, err
+	case config.AutocertDomains != "":
+		manager, err := newAutocertManager(config.AutocertDomains, config.AutocertCacheDir, config.AutocertEmail)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Answers TLS-ALPN challenges on the HTTPS listener itself
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return tlsConfig, manager, nil
+	}
+	return nil, nil, nil
+}
+
+// httpsRedirect sends plain-HTTP requests to the same path on the HTTPS
+// listener at httpsAddr. GET and HEAD get 301; other methods get 308 so the
+// client repeats the method and body.
+func httpsRedirect(httpsAddr string) http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
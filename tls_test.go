@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a self-signed certificate for localhost and its
+// key to dir and returns their paths
+func writeSelfSigned(t *testing.T, dir, commonName string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// tlsGet fetches url without verifying the certificate and returns the
+// response and the common name the server presented
+func tlsGet(t *testing.T, url string) (*http.Response, string) {
+	t.Helper()
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp, resp.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+func TestServeTLS(t *testing.T) {
+	newTestServer(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, "first")
+
+	tlsConfig, err := newTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Expected the pair to load: %v", err)
+	}
+	server := newServer("127.0.0.1:0", newHandler())
+	server.TLSConfig = tlsConfig
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeTLS(ln, "", "")
+	defer server.Close()
+
+	url := "https://" + ln.Addr().String() + "/health/live"
+	resp, name := tlsGet(t, url)
+	if resp.StatusCode != http.StatusOK || name != "first" {
+		t.Fatalf("Expected 200 from the first certificate, got %d from %q", resp.StatusCode, name)
+	}
+
+	// A renewed pair is picked up on the next handshake
+	writeSelfSigned(t, dir, "renewed")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if _, name := tlsGet(t, url); name != "renewed" {
+		t.Errorf("Expected the renewed certificate, got %q", name)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := writeSelfSigned(t, dir, "one")
+	_, otherKey := writeSelfSigned(t, t.TempDir(), "two")
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		want     string
+	}{
+		{"Missing Key", certFile, "", "both -tls-cert and -tls-key"},
+		{"Unreadable Certificate", filepath.Join(dir, "missing.pem"), otherKey, "no such file"},
+		{"Mismatched Pair", certFile, otherKey, "does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTLSConfig(tt.certFile, tt.keyFile)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestAutocert(t *testing.T) {
+	t.Cleanup(func() { config = defaultConfig() })
+	config = defaultConfig()
+	config.AutocertDomains = "Portal.example.com, status.example.com"
+	config.AutocertCacheDir = t.TempDir()
+
+	tlsConfig, manager, err := serverTLS()
+	if err != nil || manager == nil {
+		t.Fatalf("Expected a Let's Encrypt manager, got %v", err)
+	}
+	acceptsChallenges := false
+	for _, proto := range tlsConfig.NextProtos {
+		acceptsChallenges = acceptsChallenges || proto == "acme-tls/1"
+	}
+	if !acceptsChallenges || tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected TLS-ALPN challenges and TLS 1.2 at least, got %v and %x", tlsConfig.NextProtos, tlsConfig.MinVersion)
+	}
+	for host, allowed := range map[string]bool{"portal.example.com": true, "status.example.com": true, "evil.example.com": false} {
+		if err := manager.HostPolicy(context.Background(), host); (err == nil) != allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", host, allowed, err)
+		}
+	}
+
+	// Anything but a challenge is still redirected
+	req := httptest.NewRequest(http.MethodGet, "http://portal.example.com/board", nil)
+	rec := httptest.NewRecorder()
+	manager.HTTPHandler(httpsRedirect(":443")).ServeHTTP(rec, req)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://portal.example.com/board" {
+		t.Errorf("Expected a redirect to HTTPS, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writeSelfSigned(t, t.TempDir(), "pair")
+	tests := []struct {
+		name      string
+		configure func(c *Config)
+		want      string
+	}{
+		{"With A Pair", func(c *Config) { c.TLSCertFile, c.TLSKeyFile = certFile, keyFile }, "cannot be used with -tls-cert"},
+		{"Not A Hostname", func(c *Config) { c.AutocertDomains = "https://portal.example.com" }, "is not a hostname"},
+		{"No Hostnames", func(c *Config) { c.AutocertDomains = " , " }, "at least one hostname"},
+		{"No Cache", func(c *Config) { c.AutocertCacheDir = "" }, "-autocert-cache is required"},
+		{"Cache Is A File", func(c *Config) { c.AutocertCacheDir = file }, "is not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AutocertDomains, config.AutocertCacheDir = "portal.example.com", t.TempDir()
+			config.TLSCertFile, config.TLSKeyFile = "", ""
+			tt.configure(&config)
+			if _, _, err := serverTLS(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		httpsAddr string
+		method    string
+		status    int
+		location  string
+	}{
+		{":443", http.MethodGet, http.StatusMovedPermanently, "https://portal.example.com/board?status=open"},
+		{":8443", http.MethodGet, http.StatusMovedPermanently, "https://portal.example.com:8443/board?status=open"},
+		{":443", http.MethodPost, http.StatusPermanentRedirect, "https://portal.example.com/board?status=open"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://portal.example.com:80/board?status=open", nil)
+		rec := httptest.NewRecorder()
+		httpsRedirect(tt.httpsAddr).ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Header().Get("Location") != tt.location {
+			t.Errorf("%s via %s: expected %d %s, got %d %s", tt.method, tt.httpsAddr, tt.status, tt.location, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type DeleteComplaintRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+}
+
+type RestoreComplaintRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+}
+
+type PurgeDeletedRequest struct {
+	SecretCode string `json:"secret_code"`
+	// OlderThanDays overrides config.PurgeAfterDays for this run
+	OlderThanDays *int `json:"older_than_days,omitempty"`
+}
+
+type PurgeResult struct {
+	Purged       int   `json:"purged"`
+	ComplaintIDs []int `json:"complaint_ids"`
+}
+
+// purgeDeletedComplaints permanently removes the complaints matching include
+// that were deleted before cutoff and returns their IDs
+func purgeDeletedComplaints(cutoff time.Time, include func(*Complaint) bool) []int {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	purged := []int{}
+	for id, complaint := range storage.complaints {
+		if !complaint.IsDeleted || !include(complaint) {
+			continue
+		}
+		deletedAt, err := parseTimestamp(complaint.DeletedAt)
+		if err != nil || deletedAt.After(cutoff) {
+			continue
+		}
+		purgeComplaint(complaint)
+		purged = append(purged, id)
+	}
+	sort.Ints(purged)
+	return purged
+}
+
+// /deleteComplaint - Move a complaint to the trash (owner or admin)
+func deleteComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req DeleteComplaintRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint, apiErr := accessibleComplaint(user, req.ComplaintID, true, "Access denied. You can only delete your own complaints")
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	if complaint.IsDeleted {
+		respondWithError(w, http.StatusConflict, ErrCodeAlreadyDeleted, "Complaint is already deleted")
+		return
+	}
+
+	complaint.IsDeleted = true
+	complaint.DeletedAt = getCurrentTime()
+	unlinkAll(complaint)
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint deleted successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
+
+// /listDeletedComplaints - Complaints currently in the trash (admin only)
+func listDeletedComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req GetComplaintsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	deleted := []Complaint{}
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted && canSeeOrg(user, complaint.OrgID) {
+			deleted = append(deleted, complaintForViewer(user, *complaint))
+		}
+	}
+	sortComplaints(deleted, sortOldest)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Deleted complaints retrieved successfully",
+		Data:    deleted,
+	})
+}
+
+// /restoreComplaint - Bring a complaint back from the trash (admin only)
+func restoreComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RestoreComplaintRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+
+	if !complaint.IsDeleted {
+		respondWithError(w, http.StatusConflict, ErrCodeNotDeleted, "Complaint is not deleted")
+		return
+	}
+
+	complaint.IsDeleted = false
+	complaint.DeletedAt = ""
+	touchComplaint(complaint)
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint restored successfully",
+		Data:    complaintForViewer(user, *complaint),
+	})
+}
+
+// /purgeDeletedComplaints - Permanently remove old trash of the caller's
+// organization now (admin only)
+func purgeDeletedComplaintsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req PurgeDeletedRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	days := config.PurgeAfterDays
+	if req.OlderThanDays != nil {
+		if *req.OlderThanDays < 0 {
+			respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Older than days must not be negative")
+			return
+		}
+		days = *req.OlderThanDays
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	purged := purgeDeletedComplaints(clock.Now().AddDate(0, 0, -days), func(c *Complaint) bool {
+		return canSeeOrg(user, c.OrgID)
+	})
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Purged %d deleted complaints", len(purged)),
+		Data:    PurgeResult{Purged: len(purged), ComplaintIDs: purged},
+	})
+}
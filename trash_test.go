@@ -0,0 +1,186 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Trash User", "trash@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+	kept := submitTestComplaint(t, srv, code, "Keep me", 3)
+	doomed := submitTestComplaint(t, srv, code, "Delete me", 4)
+
+	listIDs := func(endpoint, secret string) []int {
+		t.Helper()
+		status, resp := postJSON(t, srv, endpoint, map[string]string{"secret_code": secret})
+		if status != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d (%s)", endpoint, status, resp.Error)
+		}
+		var list []Complaint
+		resp.decode(t, &list)
+		ids := []int{}
+		for _, c := range list {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+
+	t.Run("Other User Cannot Delete", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/deleteComplaint", map[string]interface{}{
+			"secret_code": otherCode, "complaint_id": doomed.ID,
+		})
+		if status != http.StatusNotFound || resp.ErrorCode != ErrCodeNotFound {
+			t.Errorf("Expected 404 NOT_FOUND, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/deleteComplaint", map[string]interface{}{
+			"secret_code": code, "complaint_id": doomed.ID,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+		}
+		var deleted Complaint
+		resp.decode(t, &deleted)
+		if !deleted.IsDeleted || deleted.DeletedAt == "" {
+			t.Errorf("Expected deleted flag and timestamp, got %+v", deleted)
+		}
+
+		status, resp = postJSON(t, srv, "/deleteComplaint", map[string]interface{}{
+			"secret_code": adminSecret, "complaint_id": doomed.ID,
+		})
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeAlreadyDeleted {
+			t.Errorf("Expected 409 ALREADY_DELETED on second delete, got %d %s", status, resp.ErrorCode)
+		}
+	})
+
+	t.Run("Hidden", func(t *testing.T) {
+		for _, tc := range []struct{ endpoint, secret string }{
+			{"/getAllComplaintsForUser", code},
+			{"/getAllComplaintsForAdmin", adminSecret},
+		} {
+			ids := listIDs(tc.endpoint, tc.secret)
+			if len(ids) != 1 || ids[0] != kept.ID {
+				t.Errorf("%s: expected only complaint %d, got %v", tc.endpoint, kept.ID, ids)
+			}
+		}
+
+		status, resp := postJSON(t, srv, "/viewComplaint", map[string]interface{}{
+			"secret_code": code, "complaint_id": doomed.ID,
+		})
+		if status != http.StatusNotFound || resp.ErrorCode != ErrCodeNotFound {
+			t.Errorf("Expected owner view to 404, got %d %s", status, resp.ErrorCode)
+		}
+
+		status, resp = postJSON(t, srv, "/viewComplaint", map[string]interface{}{
+			"secret_code": adminSecret, "complaint_id": doomed.ID,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected admin view to succeed, got %d (%s)", status, resp.Error)
+		}
+		var viewed Complaint
+		resp.decode(t, &viewed)
+		if !viewed.IsDeleted {
+			t.Error("Expected admin view to carry the deleted flag")
+		}
+
+		if ids := listIDs("/listDeletedComplaints", adminSecret); len(ids) != 1 || ids[0] != doomed.ID {
+			t.Errorf("Expected trash to hold complaint %d, got %v", doomed.ID, ids)
+		}
+
+		status, resp = postJSON(t, srv, "/listDeletedComplaints", map[string]string{"secret_code": code})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 for non-admin trash listing, got %d", status)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/restoreComplaint", map[string]interface{}{
+			"secret_code": code, "complaint_id": doomed.ID,
+		})
+		if status != http.StatusForbidden {
+			t.Errorf("Expected 403 for non-admin restore, got %d", status)
+		}
+
+		status, resp = postJSON(t, srv, "/restoreComplaint", map[string]interface{}{
+			"secret_code": adminSecret, "complaint_id": doomed.ID,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+		}
+
+		if ids := listIDs("/getAllComplaintsForUser", code); len(ids) != 2 {
+			t.Errorf("Expected both complaints back in the listing, got %v", ids)
+		}
+		if ids := listIDs("/listDeletedComplaints", adminSecret); len(ids) != 0 {
+			t.Errorf("Expected empty trash, got %v", ids)
+		}
+
+		status, resp = postJSON(t, srv, "/restoreComplaint", map[string]interface{}{
+			"secret_code": adminSecret, "complaint_id": doomed.ID,
+		})
+		if status != http.StatusConflict || resp.ErrorCode != ErrCodeNotDeleted {
+			t.Errorf("Expected 409 NOT_DELETED on second restore, got %d %s", status, resp.ErrorCode)
+		}
+	})
+}
+
+func TestPurgeDeletedComplaints(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local))
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Purge User", "purge@example.com")
+	old := submitTestComplaint(t, srv, code, "Old trash", 2)
+	recent := submitTestComplaint(t, srv, code, "Recent trash", 2)
+
+	deleteComplaint := func(id int) {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/deleteComplaint", map[string]interface{}{
+			"secret_code": code, "complaint_id": id,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("Delete %d: expected status 200, got %d (%s)", id, status, resp.Error)
+		}
+	}
+
+	deleteComplaint(old.ID)
+	fake.Advance(20 * 24 * time.Hour)
+	deleteComplaint(recent.ID)
+	fake.Advance(11 * 24 * time.Hour)
+
+	status, resp := postJSON(t, srv, "/purgeDeletedComplaints", map[string]string{"secret_code": code})
+	if status != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-admin purge, got %d", status)
+	}
+
+	status, resp = postJSON(t, srv, "/purgeDeletedComplaints", map[string]string{"secret_code": adminSecret})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+	}
+	var result PurgeResult
+	resp.decode(t, &result)
+	if result.Purged != 1 || len(result.ComplaintIDs) != 1 || result.ComplaintIDs[0] != old.ID {
+		t.Errorf("Expected only complaint %d purged after 31 days, got %+v", old.ID, result)
+	}
+
+	status, resp = postJSON(t, srv, "/restoreComplaint", map[string]interface{}{
+		"secret_code": adminSecret, "complaint_id": old.ID,
+	})
+	if status != http.StatusNotFound {
+		t.Errorf("Expected purged complaint to be gone, got %d", status)
+	}
+
+	status, resp = postJSON(t, srv, "/purgeDeletedComplaints", map[string]interface{}{
+		"secret_code": adminSecret, "older_than_days": 0,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", status, resp.Error)
+	}
+	resp.decode(t, &result)
+	if result.Purged != 1 || result.ComplaintIDs[0] != recent.ID {
+		t.Errorf("Expected older_than_days=0 to purge complaint %d, got %+v", recent.ID, result)
+	}
+}
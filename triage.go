@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sizes of the triage queue, set with limit
+const (
+	defaultTriageLimit = 20
+	maxTriageLimit     = 100
+)
+
+type TriageQueueRequest struct {
+	SecretCode string `json:"secret_code"`
+	Priority   string `json:"priority,omitempty"`
+	Department string `json:"department,omitempty"` // case-insensitive
+	Limit      int    `json:"limit,omitempty"`      // complaints returned, and claimed with Claim; default 20
+	// Claim assigns the returned complaints to the caller
+	Claim bool `json:"claim,omitempty"`
+}
+
+// TriageItem is a complaint in the triage queue, with how long it has been
+// waiting. is_overdue tells whether it is past its SLA.
+type TriageItem struct {
+	Complaint
+	AgeHours float64 `json:"age_hours" xml:"age_hours"` // 0 when the creation time cannot be read
+}
+
+type TriageQueue struct {
+	Complaints []TriageItem `json:"complaints" xml:"complaints>complaint"`
+	Total      int          `json:"total" xml:"total"` // unassigned open complaints matching, the returned ones included
+}
+
+func triageItem(complaint Complaint, now time.Time) TriageItem {
+	item := TriageItem{Complaint: complaint}
+	if created, err := parseTimestamp(complaint.CreatedAt); err == nil && !created.After(now) {
+		item.AgeHours = roundTo(now.Sub(created).Hours(), 1)
+	}
+	return item
+}
+
+// buildTriageQueue returns the oldest limit unassigned open complaints of
+// user's organization that match opts. With claim, it assigns them to user
+// first and publishes each assignment, which callers must hold
+// storage.mutex for writing for: two admins claiming at once are served one
+// after the other, and the second gets the next complaints in line.
+// Otherwise storage.mutex held for reading will do.
+func buildTriageQueue(user *User, opts listOptions, limit int, claim bool, correlationID string) TriageQueue {
+	queue := filterComplaints(user, func(c *Complaint) bool { return c.AssignedTo == 0 }, opts)
+	result := TriageQueue{Complaints: []TriageItem{}, Total: len(queue)}
+	if len(queue) > limit {
+		queue = queue[:limit]
+	}
+	for _, complaint := range queue {
+		if claim {
+			stored := storage.complaints[complaint.ID]
+			stored.AssignedTo = user.ID
+			stored.AssignedToName = user.Name
+			addHistory(stored, HistoryEntry{
+				Action:        historyAssigned,
+				ActorID:       user.ID,
+				Detail:        fmt.Sprintf("Claimed by %s from the triage queue", user.Name),
+				CorrelationID: correlationID,
+			})
+			touchComplaint(stored)
+			publishEvent(eventComplaintAssigned, stored, correlationID)
+			complaint = complaintForViewer(user, *stored)
+		}
+		result.Complaints = append(result.Complaints, triageItem(complaint, opts.now))
+	}
+	return result
+}
+
+// /triageQueue - The oldest unassigned open complaints, optionally claiming
+// them (admin only)
+func triageQueueHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req TriageQueueRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	// Listing is a read, and keeps working during maintenance; claiming
+	// is not
+	if req.Claim {
+		var ok bool
+		if r, ok = asWrite(w, r); !ok {
+			return
+		}
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultTriageLimit
+	}
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	if req.Priority != "" {
+		v.oneOf("priority", req.Priority, priorities)
+	}
+	v.between("limit", req.Limit, 1, maxTriageLimit)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	opts := listOptions{
+		status:     statusOpen,
+		priority:   req.Priority,
+		department: strings.TrimSpace(req.Department),
+		sort:       sortOldest,
+		now:        clock.Now(),
+	}
+	var queue TriageQueue
+	message := "Triage queue retrieved successfully"
+	if req.Claim {
+		storage.mutex.Lock()
+		queue = buildTriageQueue(user, opts, req.Limit, true, correlationID(r))
+		storage.mutex.Unlock()
+		message = fmt.Sprintf("Claimed %d complaints", len(queue.Complaints))
+	} else {
+		storage.mutex.RLock()
+		queue = buildTriageQueue(user, opts, req.Limit, false, "")
+		storage.mutex.RUnlock()
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    queue,
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTriageQueue(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.Local))
+	config.SLAHigh = 24 * time.Hour
+	_, code := registerTestUser(t, srv, "Queue User", "queue@example.com")
+	firstID, firstCode := registerTestAdmin(t, srv, "First Triager", "first@example.com")
+	secondID, secondCode := registerTestAdmin(t, srv, "Second Triager", "second@example.com")
+
+	var ids []int
+	for i, priority := range []string{priorityHigh, priorityLow, priorityHigh, priorityMedium, priorityLow, priorityHigh} {
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: "Queued", Summary: "Waiting", Rating: 5, Priority: priority})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201 submitting %d, got %d (%s)", i, status, resp.Error)
+		}
+		var complaint Complaint
+		resp.decode(t, &complaint)
+		ids = append(ids, complaint.ID)
+		fake.Advance(10 * time.Hour)
+	}
+	// Neither resolved nor assigned complaints are in the queue
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: ids[1]})
+	storage.mutex.Lock()
+	storage.complaints[ids[3]].AssignedTo = secondID
+	storage.mutex.Unlock()
+
+	triage := func(req TriageQueueRequest) TriageQueue {
+		t.Helper()
+		status, resp := postJSON(t, srv, "/triageQueue", req)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var queue TriageQueue
+		resp.decode(t, &queue)
+		return queue
+	}
+	queueIDs := func(queue TriageQueue) []int {
+		list := []int{}
+		for _, item := range queue.Complaints {
+			list = append(list, item.ID)
+		}
+		return list
+	}
+	equalIDs := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("Oldest Unassigned First", func(t *testing.T) {
+		queue := triage(TriageQueueRequest{SecretCode: firstCode})
+		if want := []int{ids[0], ids[2], ids[4], ids[5]}; !equalIDs(queueIDs(queue), want) || queue.Total != 4 {
+			t.Fatalf("Expected %v of 4, got %v of %d", want, queueIDs(queue), queue.Total)
+		}
+		oldest := queue.Complaints[0]
+		if oldest.AgeHours != 60 || !oldest.IsOverdue || oldest.AssignedTo != 0 {
+			t.Errorf("Expected the oldest 60 hours old and past its SLA, got %.1f hours, overdue %v", oldest.AgeHours, oldest.IsOverdue)
+		}
+		if newest := queue.Complaints[3]; newest.AgeHours != 10 || newest.IsOverdue {
+			t.Errorf("Expected the newest 10 hours old and within its SLA, got %.1f hours, overdue %v", newest.AgeHours, newest.IsOverdue)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		queue := triage(TriageQueueRequest{SecretCode: firstCode, Priority: priorityHigh, Limit: 2})
+		if want := []int{ids[0], ids[2]}; !equalIDs(queueIDs(queue), want) || queue.Total != 3 {
+			t.Errorf("Expected %v of 3, got %v of %d", want, queueIDs(queue), queue.Total)
+		}
+		if queue := triage(TriageQueueRequest{SecretCode: firstCode, Department: "no such team"}); len(queue.Complaints) != 0 {
+			t.Errorf("Expected an empty queue, got %v", queueIDs(queue))
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, req := range []TriageQueueRequest{
+			{SecretCode: firstCode, Limit: maxTriageLimit + 1},
+			{SecretCode: firstCode, Priority: "someday"},
+		} {
+			if status, resp := postJSON(t, srv, "/triageQueue", req); status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+				t.Errorf("Expected 400 for %+v, got %d %s", req, status, resp.ErrorCode)
+			}
+		}
+		if status, _ := postJSON(t, srv, "/triageQueue", TriageQueueRequest{SecretCode: code}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a regular user, got %d", status)
+		}
+	})
+
+	t.Run("Claiming Is A Write", func(t *testing.T) {
+		maintenance.set(true, "", 0)
+		if queue := triage(TriageQueueRequest{SecretCode: firstCode}); queue.Total != 4 {
+			t.Errorf("Expected the queue listed during maintenance, got %v", queueIDs(queue))
+		}
+		status, resp := postJSON(t, srv, "/triageQueue", TriageQueueRequest{SecretCode: firstCode, Claim: true})
+		maintenance.set(false, "", 0)
+		if status != http.StatusServiceUnavailable || resp.ErrorCode != ErrCodeMaintenance {
+			t.Errorf("Expected 503 %s claiming during maintenance, got %d %s", ErrCodeMaintenance, status, resp.ErrorCode)
+		}
+
+		status, resp = postJSON(t, srv, "/createApiToken", CreateAPITokenRequest{SecretCode: adminSecret, Label: "Triage board", Scope: scopeRead})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201 creating a token, got %d (%s)", status, resp.Error)
+		}
+		var token CreatedAPIToken
+		resp.decode(t, &token)
+		triage(TriageQueueRequest{SecretCode: token.Token})
+		if status, _ := postJSON(t, srv, "/triageQueue", TriageQueueRequest{SecretCode: token.Token, Claim: true}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 claiming with a read-only token, got %d", status)
+		}
+	})
+
+	t.Run("Concurrent Claims", func(t *testing.T) {
+		claimers := []struct {
+			id   int
+			code string
+		}{{firstID, firstCode}, {secondID, secondCode}}
+		claimed := make([][]int, len(claimers))
+		sub := eventBus.subscribe()
+		defer eventBus.unsubscribe(sub)
+		var wg sync.WaitGroup
+		for i, claimer := range claimers {
+			wg.Add(1)
+			go func(i int, code string) {
+				defer wg.Done()
+				status, resp, err := sendJSON(srv, "/triageQueue", TriageQueueRequest{SecretCode: code, Limit: 3, Claim: true})
+				if err != nil || status != http.StatusOK {
+					t.Errorf("Expected 200 claiming, got %d (%s) %v", status, resp.Error, err)
+					return
+				}
+				var queue TriageQueue
+				json.Unmarshal(resp.Data, &queue)
+				claimed[i] = queueIDs(queue)
+			}(i, claimer.code)
+		}
+		wg.Wait()
+
+		// Whoever went first took the 3 oldest, leaving the last one
+		if len(claimed[0])+len(claimed[1]) != 4 || (len(claimed[0]) != 3 && len(claimed[1]) != 3) {
+			t.Fatalf("Expected 3 and 1 complaints claimed, got %v and %v", claimed[0], claimed[1])
+		}
+		seen := map[int]bool{}
+		for i, list := range claimed {
+			for _, id := range list {
+				if seen[id] {
+					t.Errorf("Expected disjoint claims, complaint %d was claimed twice", id)
+				}
+				seen[id] = true
+				complaint := assigneeOf(t, srv, id)
+				if complaint.AssignedTo != claimers[i].id {
+					t.Errorf("Expected complaint %d assigned to %d, got %d", id, claimers[i].id, complaint.AssignedTo)
+				}
+				if entry := complaint.History[len(complaint.History)-1]; entry.Action != historyAssigned || entry.ActorID != claimers[i].id {
+					t.Errorf("Expected the claim in history, got %+v", entry)
+				}
+			}
+		}
+		if queue := triage(TriageQueueRequest{SecretCode: firstCode}); queue.Total != 0 {
+			t.Errorf("Expected the queue emptied, got %v", queueIDs(queue))
+		}
+
+		for range seen {
+			select {
+			case event := <-sub.events:
+				if event.Type != eventComplaintAssigned || !seen[event.Complaint.ID] || event.Complaint.AssignedTo == 0 {
+					t.Errorf("Expected %s for a claimed complaint, got %s for %+v", eventComplaintAssigned, event.Type, event.Complaint)
+				}
+			default:
+				t.Fatal("Expected an event for every claimed complaint")
+			}
+		}
+	})
+}
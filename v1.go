@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The /v1 API addresses resources by path and takes the secret code from the
+// Authorization header instead of the body. The flat routes it replaces keep
+// working and share the same logic.
+
+// V1SubmitRequest is the body of POST /v1/complaints
+type V1SubmitRequest struct {
+	Title    string   `json:"title"`
+	Summary  string   `json:"summary"`
+	Rating   int      `json:"rating"`
+	Priority string   `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// Department skips the routing rules; its custom fields go in CustomFields
+	Department   string       `json:"department,omitempty"`
+	CustomFields CustomValues `json:"custom_fields,omitempty"`
+	Building     string       `json:"building,omitempty"`
+	Floor        string       `json:"floor,omitempty"`
+	Room         string       `json:"room,omitempty"`
+}
+
+// V1ResolveRequest is the body of POST /v1/complaints/{id}/resolve
+type V1ResolveRequest struct {
+	Note    string `json:"note,omitempty"`
+	Version int    `json:"version,omitempty"` // opt-in concurrency check
+}
+
+func newV1Router() *pathRouter {
+	router := &pathRouter{}
+	router.handle(http.MethodPost, "/v1/complaints", v1SubmitComplaintHandler)
+	router.handle(http.MethodGet, "/v1/complaints/{id}", v1ViewComplaintHandler)
+	router.handle(http.MethodPatch, "/v1/complaints/{id}", v1PatchComplaintHandler)
+	router.handle(http.MethodPost, "/v1/complaints/{id}/resolve", v1ResolveComplaintHandler)
+	router.handle(http.MethodGet, "/v1/users/me", v1MeHandler)
+	return router
+}
+
+// secretFromRequest reads the secret code from "Authorization: Bearer
+// <secret>", falling back to X-Secret-Code
+func secretFromRequest(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, secret, found := strings.Cut(auth, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(secret)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-Secret-Code"))
+}
+
+// authenticateRequest authenticates a /v1 request from its headers. On
+// failure it has already written the response.
+func authenticateRequest(w http.ResponseWriter, r *http.Request) (*User, string) {
+	secret := secretFromRequest(r)
+	if secret == "" {
+		respondWithError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Secret code is required")
+		return nil, ""
+	}
+	return authenticate(w, r, secret), secret
+}
+
+// deprecatedRoute marks a flat route's response as superseded by a /v1 path
+func deprecatedRoute(w http.ResponseWriter, successor string) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+}
+
+// complaintIDParam reads {id}, a complaint ID or reference; on failure it
+// has already written the response
+func complaintIDParam(w http.ResponseWriter, params pathParams) (int, bool) {
+	if reference, ok := normalizeReference(params["id"]); ok {
+		return complaintIDForReference(reference), true
+	}
+	id, ok := params.int("id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+	}
+	return id, ok
+}
+
+// POST /v1/complaints - Submit a new complaint
+func v1SubmitComplaintHandler(w http.ResponseWriter, r *http.Request, params pathParams) {
+	var body V1SubmitRequest
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	req, apiErr := validateSubmission(SubmitComplaintRequest{
+		Title:        body.Title,
+		Summary:      body.Summary,
+		Rating:       body.Rating,
+		Priority:     body.Priority,
+		Tags:         body.Tags,
+		Department:   body.Department,
+		CustomFields: body.CustomFields,
+		Building:     body.Building,
+		Floor:        body.Floor,
+		Room:         body.Room,
+		Source:       requestSource(r),
+	})
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user, secret := authenticateRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	complaint, apiErr := createComplaint(user, secret, req, correlationID(r))
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/complaints/"+strconv.Itoa(complaint.ID))
+	respondWithJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Complaint submitted successfully",
+		Data:    complaint,
+	})
+}
+
+// GET /v1/complaints/{id} - View a complaint
+func v1ViewComplaintHandler(w http.ResponseWriter, r *http.Request, params pathParams) {
+	id, ok := complaintIDParam(w, params)
+	if !ok {
+		return
+	}
+
+	user, _ := authenticateRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	complaint, apiErr := lookupComplaint(user, id)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	if notModified(w, r, complaintETag(complaint)) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint retrieved successfully",
+		Data:    complaint,
+	})
+}
+
+// POST /v1/complaints/{id}/resolve - Resolve a complaint (admin only). The
+// body is optional.
+func v1ResolveComplaintHandler(w http.ResponseWriter, r *http.Request, params pathParams) {
+	id, ok := complaintIDParam(w, params)
+	if !ok {
+		return
+	}
+
+	var body V1ResolveRequest
+	if !decodeOptionalJSON(w, r, &body) {
+		return
+	}
+	if body.Version < 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Version must be positive")
+		return
+	}
+
+	user, secret := authenticateRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	complaint, apiErr := resolveComplaint(user, secret, id, body.Note, body.Version, correlationID(r))
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint resolved successfully",
+		Data:    complaint,
+	})
+}
+
+// GET /v1/users/me - The authenticated user's profile
+func v1MeHandler(w http.ResponseWriter, r *http.Request, params pathParams) {
+	user, _ := authenticateRequest(w, r)
+	if user == nil {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Profile retrieved successfully",
+		Data:    profileFor(user),
+	})
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// doV1 sends a /v1 request with secretCode as a bearer token
+func doV1(t *testing.T, srv *httptest.Server, method, path, secretCode string, payload interface{}) (*http.Response, testResponse) {
+	t.Helper()
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatalf("Failed to encode payload: %v", err)
+		}
+		body = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, body)
+	if err != nil {
+		t.Fatalf("Failed to build %s %s: %v", method, path, err)
+	}
+	if secretCode != "" {
+		req.Header.Set("Authorization", "Bearer "+secretCode)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var response testResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response from %s %s: %v", method, path, err)
+	}
+	return resp, response
+}
+
+func TestV1API(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Versioned User", "v1@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+
+	// One complaint through each surface; both land in the same storage
+	legacy := submitTestComplaint(t, srv, code, "Legacy submit", 4)
+
+	resp, body := doV1(t, srv, http.MethodPost, "/v1/complaints", code, V1SubmitRequest{
+		Title: "  V1 <b>submit</b> ", Summary: "Filed through v1", Rating: 6,
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d (%s)", resp.StatusCode, body.Error)
+	}
+	var created Complaint
+	body.decode(t, &created)
+	if created.Title != "V1 submit" || created.Priority != priorityMedium {
+		t.Errorf("Expected sanitized title and default priority, got %q %q", created.Title, created.Priority)
+	}
+	if created.ID != legacy.ID+1 {
+		t.Errorf("Expected ID %d, got %d", legacy.ID+1, created.ID)
+	}
+	if loc := resp.Header.Get("Location"); loc != "/v1/complaints/"+strconv.Itoa(created.ID) {
+		t.Errorf("Unexpected Location %q", loc)
+	}
+
+	t.Run("Deprecation Headers", func(t *testing.T) {
+		jsonData, _ := json.Marshal(ViewComplaintRequest{SecretCode: code, ComplaintID: created.ID})
+		resp, err := http.Post(srv.URL+"/viewComplaint", "application/json", bytes.NewReader(jsonData))
+		if err != nil {
+			t.Fatalf("POST /viewComplaint failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 viewing a v1 complaint through the flat route, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Deprecation") != "true" {
+			t.Error("Expected Deprecation header on the flat route")
+		}
+		if link := resp.Header.Get("Link"); link != `</v1/complaints/{id}>; rel="successor-version"` {
+			t.Errorf("Unexpected Link %q", link)
+		}
+	})
+
+	t.Run("View", func(t *testing.T) {
+		resp, body := doV1(t, srv, http.MethodGet, "/v1/complaints/"+strconv.Itoa(legacy.ID), code, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", resp.StatusCode, body.Error)
+		}
+		if resp.Header.Get("Deprecation") != "" {
+			t.Error("Expected no Deprecation header on /v1")
+		}
+
+		resp, _ = doV1(t, srv, http.MethodGet, "/v1/complaints/"+strconv.Itoa(legacy.ID), otherCode, nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 for another user, got %d", resp.StatusCode)
+		}
+		resp, _ = doV1(t, srv, http.MethodGet, "/v1/complaints/9999", code, nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 for a missing complaint, got %d", resp.StatusCode)
+		}
+		resp, _ = doV1(t, srv, http.MethodGet, "/v1/complaints/abc", code, nil)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a bad ID, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Authentication", func(t *testing.T) {
+		resp, body := doV1(t, srv, http.MethodGet, "/v1/users/me", "", nil)
+		if resp.StatusCode != http.StatusUnauthorized || body.ErrorCode != ErrCodeUnauthorized {
+			t.Errorf("Expected 401 without credentials, got %d %s", resp.StatusCode, body.ErrorCode)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/users/me", nil)
+		req.Header.Set("X-Secret-Code", code)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /v1/users/me failed: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Expected X-Secret-Code to authenticate, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Routing", func(t *testing.T) {
+		resp, _ := doV1(t, srv, http.MethodDelete, "/v1/complaints/1", code, nil)
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, PATCH" {
+			t.Errorf("Expected 405 with Allow: GET, PATCH, got %d %q", resp.StatusCode, resp.Header.Get("Allow"))
+		}
+		resp, _ = doV1(t, srv, http.MethodGet, "/v1/nothing", code, nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		path := "/v1/complaints/" + strconv.Itoa(legacy.ID) + "/resolve"
+		resp, _ := doV1(t, srv, http.MethodPost, path, code, V1ResolveRequest{Note: "Mine"})
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 for a non-admin, got %d", resp.StatusCode)
+		}
+
+		resp, body := doV1(t, srv, http.MethodPost, path, adminSecret, V1ResolveRequest{Note: "Fixed"})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", resp.StatusCode, body.Error)
+		}
+
+		// The flat route sees the same state
+		status, flat := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: legacy.ID})
+		if status != http.StatusBadRequest || flat.ErrorCode != ErrCodeAlreadyResolved {
+			t.Errorf("Expected 400 ALREADY_RESOLVED, got %d %s", status, flat.ErrorCode)
+		}
+
+		// And the reverse: resolved through the flat route, rejected on /v1
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: created.ID})
+		resp, body = doV1(t, srv, http.MethodPost, "/v1/complaints/"+strconv.Itoa(created.ID)+"/resolve", adminSecret, nil)
+		if resp.StatusCode != http.StatusBadRequest || body.ErrorCode != ErrCodeAlreadyResolved {
+			t.Errorf("Expected 400 ALREADY_RESOLVED, got %d %s", resp.StatusCode, body.ErrorCode)
+		}
+	})
+
+	t.Run("Me", func(t *testing.T) {
+		resp, body := doV1(t, srv, http.MethodGet, "/v1/users/me", code, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+		var me MeResponse
+		body.decode(t, &me)
+		if len(me.Complaints) != 2 || me.UnreadNotifications != 2 {
+			t.Errorf("Expected 2 complaints and 2 unread notifications, got %d and %d", len(me.Complaints), me.UnreadNotifications)
+		}
+
+		_, flat := postJSON(t, srv, "/me", MeRequest{SecretCode: code})
+		if string(flat.Data) != string(body.Data) {
+			t.Errorf("Expected /me and /v1/users/me to agree:\n%s\n%s", flat.Data, body.Data)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+)
+
+// ComplaintValidation is what /validateComplaint found out about a
+// submission that passed every check
+type ComplaintValidation struct {
+	Department string   `json:"department"`     // where the complaint would be routed
+	Tags       []string `json:"tags,omitempty"` // with tag rules applied
+	// Similar are the caller's similar open complaints, as suggest would
+	// find them; empty when there are none
+	Similar []SimilarComplaint `json:"similar"`
+}
+
+// /validateComplaint - Check a /submitComplaint payload without storing
+// anything. Decoding and every check are shared with /submitComplaint, so
+// a payload that fails here fails there with the same response.
+func validateComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	req, user := decodeSubmission(w, r)
+	if user == nil {
+		return
+	}
+
+	// Checking the submission limits prunes the rate window, a write
+	storage.mutex.Lock()
+	complaint, apiErr := checkComplaint(user, req.SecretCode, req, clock.Now())
+	similar := similarComplaints(user, req.Title, req.Summary)
+	storage.mutex.Unlock()
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Complaint is valid",
+		Data: ComplaintValidation{
+			Department: complaint.Department,
+			Tags:       complaint.Tags,
+			Similar:    similar,
+		},
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestValidateComplaint(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Tenant", "tenant@example.com")
+	existing := submitTestComplaint(t, srv, code, "Heating broken in flat 4", 7)
+	postJSON(t, srv, "/addDepartment", AddDepartmentRequest{
+		SecretCode: adminSecret, Name: "IT", Keywords: []string{"laptop"},
+		Fields: []CustomField{{Name: "Asset tag", Type: customFieldString, Required: true}},
+	})
+
+	// post returns the raw response, so payloads can be compared byte for byte
+	post := func(endpoint string, payload interface{}) (int, []byte) {
+		t.Helper()
+		data, _ := json.Marshal(payload)
+		resp, err := http.Post(srv.URL+endpoint, "application/json", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", endpoint, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, body
+	}
+	complaintCount := func() int {
+		storage.mutex.RLock()
+		defer storage.mutex.RUnlock()
+		return len(storage.complaints)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		config.SubmitRateLimit = 1
+		defer disableSubmissionLimits()
+		req := SubmitComplaintRequest{SecretCode: code, Title: "Heating broken again", Summary: "Flat 4 radiators are cold", Rating: 6}
+		for i := 0; i < 2; i++ {
+			status, resp := postJSON(t, srv, "/validateComplaint", req)
+			if status != http.StatusOK {
+				t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+			}
+			var validation ComplaintValidation
+			resp.decode(t, &validation)
+			if validation.Department != generalDepartment || len(validation.Similar) != 1 || validation.Similar[0].ID != existing.ID {
+				t.Errorf("Expected the routing and the similar complaint, got %+v", validation)
+			}
+		}
+		if got := complaintCount(); got != 1 {
+			t.Errorf("Expected nothing stored, got %d complaints", got)
+		}
+		// Validating did not use up the one submission allowed
+		if status, resp := postJSON(t, srv, "/submitComplaint", req); status != http.StatusCreated {
+			t.Errorf("Expected the submission to go through, got %d (%s)", status, resp.Error)
+		}
+	})
+
+	t.Run("Same Errors As Submit", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			req    SubmitComplaintRequest
+			status int
+			setup  func()
+		}{
+			{"Fields", SubmitComplaintRequest{SecretCode: code, Title: " ", Summary: "Cold", Rating: 99, Priority: "urgent"}, http.StatusBadRequest, nil},
+			{"Unknown Department", SubmitComplaintRequest{SecretCode: code, Title: "Cold", Summary: "Cold", Rating: 5, Department: "Catering"}, http.StatusBadRequest, nil},
+			{"Custom Fields", SubmitComplaintRequest{SecretCode: code, Title: "Laptop broken", Summary: "Screen cracked", Rating: 5}, http.StatusBadRequest, nil},
+			{"Invalid Secret", SubmitComplaintRequest{SecretCode: "WRONG", Title: "Cold", Summary: "Cold", Rating: 5}, http.StatusUnauthorized, nil},
+			{"Missing Draft", SubmitComplaintRequest{SecretCode: code, FromDraft: true}, http.StatusNotFound, nil},
+			{"Quota", SubmitComplaintRequest{SecretCode: code, Title: "Cold", Summary: "Cold", Rating: 5}, http.StatusConflict, func() { config.MaxOpenComplaints = 1 }},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				defer disableSubmissionLimits()
+				if tc.setup != nil {
+					tc.setup()
+				}
+				before := complaintCount()
+				validateStatus, validateBody := post("/validateComplaint", tc.req)
+				submitStatus, submitBody := post("/submitComplaint", tc.req)
+				if validateStatus != tc.status || submitStatus != tc.status {
+					t.Fatalf("Expected %d from both, got %d and %d (%s)", tc.status, validateStatus, submitStatus, validateBody)
+				}
+				if !bytes.Equal(validateBody, submitBody) {
+					t.Errorf("Expected identical error payloads, got\n%s\n%s", validateBody, submitBody)
+				}
+				if got := complaintCount(); got != before {
+					t.Errorf("Expected nothing stored, got %d complaints", got)
+				}
+			})
+		}
+	})
+
+	t.Run("Method", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/validateComplaint")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405, got %d", resp.StatusCode)
+		}
+	})
+}
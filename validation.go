@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// Maximum lengths of account fields, in runes
+const (
+	maxNameLength  = 100
+	maxEmailLength = 254
+)
+
+// validator collects every problem with a request, at most one per field,
+// so a client can fix them all in one round trip. Fields are named as in
+// the JSON body; the message of each problem starts with a label derived
+// from that name, so the same rule reads the same on every endpoint.
+type validator struct {
+	errors   []FieldError
+	messages []string // one sentence per error, for the response message
+}
+
+// fieldLabel turns a JSON field name into the start of a sentence:
+// "secret_code" becomes "Secret code" and "complaint_id" "Complaint ID"
+func fieldLabel(field string) string {
+	words := strings.Split(field, "_")
+	for i, word := range words {
+		if word == "id" {
+			words[i] = "ID"
+		}
+	}
+	label := strings.Join(words, " ")
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// failed reports whether field already has a problem; later rules for it
+// are skipped so only the first is reported
+func (v *validator) failed(field string) bool {
+	for _, fieldErr := range v.errors {
+		if fieldErr.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// add records problem, a phrase such as "is required", for field unless it
+// already has one
+func (v *validator) add(field, problem string) {
+	if !v.failed(field) {
+		v.errors = append(v.errors, FieldError{Field: field, Error: problem})
+		v.messages = append(v.messages, fieldLabel(field)+" "+problem)
+	}
+}
+
+// required checks that value is not blank
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+	}
+}
+
+// positiveID checks that an ID was given
+func (v *validator) positiveID(field string, id int) {
+	if id <= 0 {
+		v.add(field, "is required")
+	}
+}
+
+// maxRunes checks that value is at most max characters. Characters are
+// runes, so text in any script or with emoji gets the same allowance.
+func (v *validator) maxRunes(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.add(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+// between checks that value is in the inclusive range min to max
+func (v *validator) between(field string, value, min, max int) {
+	if value < min || value > max {
+		v.add(field, fmt.Sprintf("must be between %d and %d", min, max))
+	}
+}
+
+// oneOf checks that value is one of allowed
+func (v *validator) oneOf(field, value string, allowed []string) {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	v.add(field, "must be one of: "+strings.Join(allowed, ", "))
+}
+
+// email checks that a value that was given is a bare email address, without
+// a display name
+func (v *validator) email(field, value string) {
+	if value == "" {
+		return
+	}
+	if address, err := mail.ParseAddress(value); err != nil || address.Address != value {
+		v.add(field, "must be a valid email address")
+	}
+}
+
+// check records err from a helper with its own rules, such as
+// normalizeTags, under field. Its message is used as it is.
+func (v *validator) check(field string, err error) {
+	if err == nil || v.failed(field) {
+		return
+	}
+	v.errors = append(v.errors, FieldError{Field: field, Error: strings.TrimPrefix(err.Error(), fieldLabel(field)+" ")})
+	v.messages = append(v.messages, err.Error())
+}
+
+// err is the response for the problems found, or nil when there are none.
+// The message lists every problem; the data has one FieldError per field.
+func (v *validator) err() *APIError {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	apiErr := newAPIError(http.StatusBadRequest, ErrCodeValidationFailed, strings.Join(v.messages, "; "))
+	apiErr.Data = v.errors
+	return apiErr
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidationAcrossHandlers(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	_, code := registerTestUser(t, srv, "Validated User", "validated@example.com")
+
+	tooLongTitle := strings.Repeat("😡", maxTitleLength+1)
+	tests := []struct {
+		name     string
+		endpoint string
+		body     interface{}
+		field    string
+		want     string
+	}{
+		{"Submit Title Too Long", "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: tooLongTitle, Summary: "Summary", Rating: 5}, "title", "must be at most 200 characters"},
+		{"Draft Title Too Long", "/saveDraft", SaveDraftRequest{SecretCode: code, Title: tooLongTitle}, "title", "must be at most 200 characters"},
+		{"Submit Rating Out Of Range", "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: "Title", Summary: "Summary", Rating: 11}, "rating", "must be between 1 and 10"},
+		{"Feedback Score Out Of Range", "/submitFeedback", SubmitFeedbackRequest{SecretCode: code, ComplaintID: 1, Score: 6}, "score", "must be between 1 and 5"},
+		{"Submit Unknown Priority", "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: "Title", Summary: "Summary", Rating: 5, Priority: "someday"}, "priority", "must be one of: " + strings.Join(priorities, ", ")},
+		{"Draft Missing Secret Code", "/saveDraft", SaveDraftRequest{Title: "Title"}, "secret_code", "is required"},
+		{"Feedback Missing Secret Code", "/submitFeedback", SubmitFeedbackRequest{ComplaintID: 1, Score: 3}, "secret_code", "is required"},
+		{"Note Missing Secret Code", "/addAdminNote", AddAdminNoteRequest{ComplaintID: 1, Note: "Note"}, "secret_code", "is required"},
+		{"Feedback Missing Complaint", "/submitFeedback", SubmitFeedbackRequest{SecretCode: code, Score: 3}, "complaint_id", "is required"},
+		{"Note Missing Complaint", "/addAdminNote", AddAdminNoteRequest{SecretCode: adminSecret, Note: "Note"}, "complaint_id", "is required"},
+		{"Register Missing Name", "/register", RegisterRequest{Email: "nameless@example.com"}, "name", "is required"},
+		{"Submit Missing Title", "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Summary: "Summary", Rating: 5}, "title", "is required"},
+		{"Register Name Too Long", "/register", RegisterRequest{Name: strings.Repeat("名", maxNameLength+1), Email: "long@example.com"}, "name", "must be at most 100 characters"},
+		{"Register Invalid Email", "/register", RegisterRequest{Name: "Someone", Email: "Someone <someone@example.com>"}, "email", "must be a valid email address"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status, resp := postJSON(t, srv, tc.endpoint, tc.body)
+			if status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+				t.Fatalf("Expected 400 %s, got %d %s (%s)", ErrCodeValidationFailed, status, resp.ErrorCode, resp.Error)
+			}
+			var fieldErrs []FieldError
+			resp.decode(t, &fieldErrs)
+			want := FieldError{Field: tc.field, Error: tc.want}
+			if len(fieldErrs) != 1 || fieldErrs[0] != want {
+				t.Errorf("Expected %+v, got %+v", want, fieldErrs)
+			}
+			if wantMessage := fieldLabel(tc.field) + " " + tc.want; resp.Error != wantMessage {
+				t.Errorf("Expected message %q, got %q", wantMessage, resp.Error)
+			}
+		})
+	}
+
+	t.Run("All Errors At Once", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Summary: strings.Repeat("a", maxSummaryLength+1), Rating: 0})
+		if status != http.StatusBadRequest {
+			t.Fatalf("Expected 400, got %d", status)
+		}
+		var fieldErrs []FieldError
+		resp.decode(t, &fieldErrs)
+		want := []FieldError{
+			{Field: "title", Error: "is required"},
+			{Field: "summary", Error: "must be at most 5000 characters"},
+			{Field: "rating", Error: "must be between 1 and 10"},
+		}
+		if len(fieldErrs) != len(want) {
+			t.Fatalf("Expected %+v, got %+v", want, fieldErrs)
+		}
+		for i := range want {
+			if fieldErrs[i] != want[i] {
+				t.Errorf("Expected %+v, got %+v", want[i], fieldErrs[i])
+			}
+		}
+		if wantMessage := "Title is required; Summary must be at most 5000 characters; Rating must be between 1 and 10"; resp.Error != wantMessage {
+			t.Errorf("Expected message %q, got %q", wantMessage, resp.Error)
+		}
+	})
+
+	t.Run("Limits Count Characters", func(t *testing.T) {
+		title := strings.Repeat("😡", maxTitleLength)
+		status, resp := postJSON(t, srv, "/submitComplaint", SubmitComplaintRequest{SecretCode: code, Title: title, Summary: "Summary", Rating: 5})
+		if status != http.StatusCreated {
+			t.Errorf("Expected %d emoji, %d bytes, to fit the title limit, got %d (%s)", maxTitleLength, len(title), status, resp.Error)
+		}
+		name := strings.Repeat("é", maxNameLength)
+		if status, resp := postJSON(t, srv, "/register", RegisterRequest{Name: name, Email: "accented@example.com"}); status != http.StatusCreated {
+			t.Errorf("Expected a %d character name to fit, got %d (%s)", maxNameLength, status, resp.Error)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import "net/http"
+
+// ComplaintUser is the submitter's contact details, shown to admins so they
+// can follow up. It never carries the secret code.
+type ComplaintUser struct {
+	ID          int    `json:"id" xml:"id"`
+	Name        string `json:"name" xml:"name"`
+	Email       string `json:"email" xml:"email"`
+	LastLoginAt string `json:"last_login_at,omitempty" xml:"last_login_at,omitempty"`
+}
+
+// complaintForViewer returns the copy of complaint that viewer is allowed to
+// see. Every response that carries a complaint must go through here so that
+// admin-only fields never leave the server for regular users. Callers must
+// hold storage.mutex or storage.usersMutex.
+func complaintForViewer(viewer *User, complaint Complaint) Complaint {
+	complaint.Status = complaintStatus(&complaint)
+	complaint.IsOverdue = isOverdue(&complaint, clock.Now())
+	complaint.RatingOutOfRange = !currentRatingScale().contains(complaint.Rating)
+	complaint.WatchersCount = len(complaint.Watchers)
+	complaint.Watchers = nil
+	var a actor
+	if viewer != nil {
+		a = actorOfLocked(viewer)
+	}
+	if canSeeAdminFields(a).allowed() {
+		complaint.AdminNotes = append([]AdminNote(nil), complaint.AdminNotes...)
+		complaint.History = append([]HistoryEntry(nil), complaint.History...)
+		complaint.Comments = append([]Comment(nil), complaint.Comments...)
+		if owner, exists := storage.users[complaint.UserID]; exists {
+			complaint.User = &ComplaintUser{ID: owner.ID, Name: owner.Name, Email: owner.Email, LastLoginAt: lastLoginAt(owner)}
+		}
+		return complaint
+	}
+	complaint.AdminNotes = nil
+	complaint.AssignedTo = 0
+	complaint.AssignedToName = ""
+	complaint.History = nil
+	complaint.ModerationReason = ""
+	complaint.ResolvedBy = 0
+	complaint.ResolvedByName = ""
+	complaint.PinnedBy = 0
+	complaint.User = nil
+	if !a.owns(&complaint) {
+		complaint.Feedback = nil
+		complaint.Comments = nil
+	}
+	return complaint
+}
+
+// accessibleComplaint returns complaint id if user may see it: admins see
+// every complaint of their organization, and those in the trash when
+// includeDeleted is set; everyone else sees only their own, outside the
+// trash. Any handler that takes a complaint ID from a regular user must look
+// it up through here.
+//
+// A complaint the user may not see is reported exactly like a missing one,
+// so probing IDs reveals nothing, unless config.RevealForbiddenComplaints
+// asks for a 403 with denied as the message. Complaints of another
+// organization always look missing. Callers must hold storage.mutex.
+func accessibleComplaint(user *User, id int, includeDeleted bool, denied string) (*Complaint, *APIError) {
+	complaint := scopedComplaint(user, id)
+	if complaint == nil || (complaint.IsDeleted && !includeDeleted) {
+		return nil, newAPIError(http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+	}
+	decision := canViewComplaint(actorOf(user), complaint).because(denied)
+	if decision.effect == denyForbidden && decision.code == ErrCodeForbidden && !config.RevealForbiddenComplaints {
+		decision = hide("Complaint not found")
+	}
+	if apiErr := decision.apiError(); apiErr != nil {
+		return nil, apiErr
+	}
+	return complaint, nil
+}
+
+// complaintsForViewer applies complaintForViewer to every complaint in list
+func complaintsForViewer(viewer *User, list []Complaint) []Complaint {
+	shaped := make([]Complaint, len(list))
+	for i, complaint := range list {
+		shaped[i] = complaintForViewer(viewer, complaint)
+	}
+	return shaped
+}
+
+// userForViewer returns a copy of user whose complaints are shaped for viewer.
+// Callers must hold storage.mutex or storage.usersMutex.
+func userForViewer(viewer *User, user User) User {
+	user.Complaints = complaintsForViewer(viewer, user.Complaints)
+	return user
+}
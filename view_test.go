@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestAdminSeesSubmitterContact(t *testing.T) {
+	srv := newTestServer(t)
+	userID, code := registerTestUser(t, srv, "Contact Me", "contact@example.com")
+	complaint := submitTestComplaint(t, srv, code, "Cold office", 4)
+
+	t.Run("Admin", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		var viewed Complaint
+		resp.decode(t, &viewed)
+		want := ComplaintUser{ID: userID, Name: "Contact Me", Email: "contact@example.com"}
+		if viewed.User == nil || *viewed.User != want {
+			t.Errorf("Expected submitter %+v, got %+v", want, viewed.User)
+		}
+
+		_, resp = postJSON(t, srv, "/getAllComplaintsForAdmin", map[string]interface{}{"secret_code": adminSecret, "page": 1})
+		var page ComplaintPage
+		resp.decode(t, &page)
+		if len(page.Complaints) != 1 || page.Complaints[0].User == nil || page.Complaints[0].User.Email != "contact@example.com" {
+			t.Errorf("Expected the admin listing to carry the email, got %+v", page.Complaints)
+		}
+		if bytes.Contains(resp.Data, []byte(code)) {
+			t.Errorf("Admin listing leaked the submitter's secret code: %s", resp.Data)
+		}
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		for _, req := range []struct {
+			endpoint string
+			payload  interface{}
+		}{
+			{"/viewComplaint", ViewComplaintRequest{SecretCode: code, ComplaintID: complaint.ID}},
+			{"/getAllComplaintsForUser", map[string]string{"secret_code": code}},
+			{"/login", map[string]string{"secret_code": code}},
+		} {
+			status, resp := postJSON(t, srv, req.endpoint, req.payload)
+			if status != http.StatusOK {
+				t.Fatalf("%s: expected status 200, got %d (%s)", req.endpoint, status, resp.Error)
+			}
+			if bytes.Contains(resp.Data, []byte(`"user":`)) {
+				t.Errorf("%s: expected no nested user for the owner, got %s", req.endpoint, resp.Data)
+			}
+		}
+	})
+}
+
+func TestHiddenComplaintsLookMissing(t *testing.T) {
+	srv := newTestServer(t)
+	_, ownerCode := registerTestUser(t, srv, "Owner", "owner@example.com")
+	_, otherCode := registerTestUser(t, srv, "Prober", "prober@example.com")
+	complaint := submitTestComplaint(t, srv, ownerCode, "Private matter", 2)
+	postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+	missingID := complaint.ID + 100
+
+	raw := func(endpoint string, payload interface{}) (int, string) {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(srv.URL+endpoint, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	probes := []struct {
+		endpoint string
+		payload  func(id int) interface{}
+	}{
+		{"/viewComplaint", func(id int) interface{} { return ViewComplaintRequest{SecretCode: otherCode, ComplaintID: id} }},
+		{"/submitFeedback", func(id int) interface{} {
+			return SubmitFeedbackRequest{SecretCode: otherCode, ComplaintID: id, Score: 1}
+		}},
+		{"/setComplaintTags", func(id int) interface{} {
+			return SetComplaintTagsRequest{SecretCode: otherCode, ComplaintID: id, Tags: []string{"probe"}}
+		}},
+		{"/deleteComplaint", func(id int) interface{} { return DeleteComplaintRequest{SecretCode: otherCode, ComplaintID: id} }},
+	}
+
+	t.Run("Default Policy", func(t *testing.T) {
+		for _, probe := range probes {
+			hiddenStatus, hiddenBody := raw(probe.endpoint, probe.payload(complaint.ID))
+			missingStatus, missingBody := raw(probe.endpoint, probe.payload(missingID))
+			if hiddenStatus != http.StatusNotFound || hiddenStatus != missingStatus || hiddenBody != missingBody {
+				t.Errorf("%s: hidden gave %d %s, missing gave %d %s", probe.endpoint, hiddenStatus, hiddenBody, missingStatus, missingBody)
+			}
+		}
+	})
+
+	t.Run("Admins Still See It", func(t *testing.T) {
+		if status, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID}); status != http.StatusOK {
+			t.Errorf("Expected 200 for an admin, got %d (%s)", status, resp.Error)
+		}
+		if status, _ := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: adminSecret, ComplaintID: missingID}); status != http.StatusNotFound {
+			t.Errorf("Expected 404 for a missing ID, got %d", status)
+		}
+	})
+
+	t.Run("Reveal Policy", func(t *testing.T) {
+		config.RevealForbiddenComplaints = true
+		defer func() { config.RevealForbiddenComplaints = false }()
+
+		status, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: otherCode, ComplaintID: complaint.ID})
+		if status != http.StatusForbidden || resp.ErrorCode != ErrCodeForbidden {
+			t.Errorf("Expected 403 FORBIDDEN, got %d %s", status, resp.ErrorCode)
+		}
+	})
+}
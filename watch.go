@@ -0,0 +1,215 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type WatchComplaintRequest struct {
+	SecretCode  string `json:"secret_code"`
+	ComplaintID int    `json:"complaint_id"`
+}
+
+// WatchStatus is the /watchComplaint and /unwatchComplaint payload. It does
+// not carry the complaint, which a watcher may not be allowed to view.
+type WatchStatus struct {
+	ComplaintID   int  `json:"complaint_id"`
+	Watching      bool `json:"watching"`
+	WatchersCount int  `json:"watchers_count"`
+}
+
+// watchableComplaint returns complaint id if user may watch it: any
+// complaint they can see, or an open complaint of their organization, which
+// its members can already find on /board. Anything else is reported like
+// accessibleComplaint does. Callers must hold storage.mutex.
+func watchableComplaint(user *User, id int) (*Complaint, *APIError) {
+	if complaint := scopedComplaint(user, id); complaint != nil && !complaint.IsDeleted && isOpen(complaint) {
+		return complaint, nil
+	}
+	return accessibleComplaint(user, id, false, "Access denied. You can only watch open complaints and your own")
+}
+
+func isWatching(complaint *Complaint, userID int) bool {
+	for _, id := range complaint.Watchers {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyWatchers adds a notification about complaint to each watcher's
+// inbox. The owner is never a watcher, so owners notified separately hear
+// about a change once. With adminsOnly set, only watchers who are admins
+// are notified. Callers must hold storage.mutex for writing.
+func notifyWatchers(complaint *Complaint, notificationType, message string, adminsOnly bool) {
+	for _, id := range complaint.Watchers {
+		watcher, exists := storage.users[id]
+		if !exists || (adminsOnly && !adminOnly(actorOfLocked(watcher)).allowed()) {
+			continue
+		}
+		notifyUser(id, complaint, notificationType, message)
+	}
+}
+
+// /watchComplaint - Get notified about changes to a complaint
+func watchComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req WatchComplaintRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	complaint, apiErr := watchableComplaint(user, req.ComplaintID)
+	if apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+	if actorOf(user).owns(complaint) {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "You are always notified about your own complaints")
+		return
+	}
+
+	message := "Already watching complaint"
+	if !isWatching(complaint, user.ID) {
+		complaint.Watchers = append(complaint.Watchers, user.ID)
+		message = "Watching complaint"
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    WatchStatus{ComplaintID: complaint.ID, Watching: true, WatchersCount: len(complaint.Watchers)},
+	})
+}
+
+// /unwatchComplaint - Stop notifications about a watched complaint
+func unwatchComplaintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req WatchComplaintRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	// Resolved complaints leave the board, but their watchers can still
+	// stop watching
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil || complaint.IsDeleted || !isWatching(complaint, user.ID) {
+		if _, apiErr := watchableComplaint(user, req.ComplaintID); apiErr != nil {
+			respondWithAPIError(w, apiErr)
+			return
+		}
+	}
+
+	// A new slice, as copies of the complaint handed to events share the old one
+	var watchers []int
+	for _, id := range complaint.Watchers {
+		if id != user.ID {
+			watchers = append(watchers, id)
+		}
+	}
+	complaint.Watchers = watchers
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Stopped watching complaint",
+		Data:    WatchStatus{ComplaintID: complaint.ID, Watching: false, WatchersCount: len(complaint.Watchers)},
+	})
+}
+
+// /listWatchers - Users watching a complaint (admin only)
+func listWatchersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req WatchComplaintRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.SecretCode) == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+	if req.ComplaintID <= 0 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Valid complaint ID is required")
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	complaint := scopedComplaint(user, req.ComplaintID)
+	if complaint == nil {
+		respondWithError(w, http.StatusNotFound, ErrCodeNotFound, "Complaint not found")
+		return
+	}
+
+	watchers := []ComplaintUser{}
+	for _, id := range complaint.Watchers {
+		if watcher, exists := storage.users[id]; exists {
+			watchers = append(watchers, ComplaintUser{ID: watcher.ID, Name: watcher.Name, Email: watcher.Email, LastLoginAt: lastLoginAt(watcher)})
+		}
+	}
+	sort.Slice(watchers, func(i, j int) bool { return watchers[i].ID < watchers[j].ID })
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Watchers retrieved successfully",
+		Data:    watchers,
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestWatchComplaint(t *testing.T) {
+	srv := newTestServer(t)
+	ownerID, ownerCode := registerTestUser(t, srv, "Elevator Reporter", "reporter@example.com")
+	watcherID, watcherCode := registerTestUser(t, srv, "Colleague", "colleague@example.com")
+	_, adminCode := registerTestAdmin(t, srv, "Facilities Admin", "facilities@example.com")
+	complaint := submitTestComplaint(t, srv, ownerCode, "Elevator broken again", 5)
+
+	inbox := func(code string) []Notification {
+		t.Helper()
+		_, resp := postJSON(t, srv, "/getNotifications", GetNotificationsRequest{SecretCode: code})
+		var page NotificationPage
+		resp.decode(t, &page)
+		return page.Notifications
+	}
+	watch := func(code string, id int) (int, testResponse) {
+		return postJSON(t, srv, "/watchComplaint", WatchComplaintRequest{SecretCode: code, ComplaintID: id})
+	}
+
+	status, resp := watch(watcherCode, complaint.ID)
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+	}
+	_, resp = watch(watcherCode, complaint.ID) // watching twice changes nothing
+	var watching WatchStatus
+	resp.decode(t, &watching)
+	if !watching.Watching || watching.WatchersCount != 1 {
+		t.Errorf("Expected 1 watcher, got %+v", watching)
+	}
+
+	t.Run("Owner Watch Is Implicit", func(t *testing.T) {
+		if status, _ := watch(ownerCode, complaint.ID); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 watching your own complaint, got %d", status)
+		}
+	})
+
+	t.Run("Watchers Count And Listing", func(t *testing.T) {
+		_, resp := postJSON(t, srv, "/viewComplaint", ViewComplaintRequest{SecretCode: ownerCode, ComplaintID: complaint.ID})
+		var viewed Complaint
+		resp.decode(t, &viewed)
+		if viewed.WatchersCount != 1 {
+			t.Errorf("Expected watchers_count 1, got %d", viewed.WatchersCount)
+		}
+
+		status, resp := postJSON(t, srv, "/listWatchers", WatchComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var watchers []ComplaintUser
+		resp.decode(t, &watchers)
+		if len(watchers) != 1 || watchers[0].ID != watcherID {
+			t.Errorf("Expected user %d watching, got %+v", watcherID, watchers)
+		}
+
+		if status, _ := postJSON(t, srv, "/listWatchers", WatchComplaintRequest{SecretCode: watcherCode, ComplaintID: complaint.ID}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 listing watchers as a user, got %d", status)
+		}
+	})
+
+	t.Run("Admin Notes Reach Watching Admins Only", func(t *testing.T) {
+		watch(adminCode, complaint.ID)
+		postJSON(t, srv, "/addAdminNote", AddAdminNoteRequest{SecretCode: adminSecret, ComplaintID: complaint.ID, Note: "Vendor booked"})
+
+		if got := inbox(adminCode); len(got) != 1 || got[0].Type != notificationAdminNote {
+			t.Errorf("Expected the watching admin to hear about the note, got %+v", got)
+		}
+		if got := inbox(watcherCode); len(got) != 0 {
+			t.Errorf("Expected no note notification for a regular watcher, got %+v", got)
+		}
+		postJSON(t, srv, "/unwatchComplaint", WatchComplaintRequest{SecretCode: adminCode, ComplaintID: complaint.ID})
+	})
+
+	t.Run("Resolve Notifies Owner And Watcher Once", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: complaint.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+
+		for _, tc := range []struct {
+			userID int
+			code   string
+		}{{ownerID, ownerCode}, {watcherID, watcherCode}} {
+			got := inbox(tc.code)
+			if len(got) != 1 || got[0].Type != notificationResolved || got[0].ComplaintID != complaint.ID || got[0].UserID != tc.userID {
+				t.Errorf("User %d: expected one resolution notification, got %+v", tc.userID, got)
+			}
+		}
+	})
+
+	t.Run("Unwatch After Resolution", func(t *testing.T) {
+		status, resp := postJSON(t, srv, "/unwatchComplaint", WatchComplaintRequest{SecretCode: watcherCode, ComplaintID: complaint.ID})
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		// The complaint is no longer on the board, so it cannot be watched again
+		if status, _ := watch(watcherCode, complaint.ID); status != http.StatusNotFound {
+			t.Errorf("Expected 404 watching someone else's resolved complaint, got %d", status)
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// workloadColumns are the columns of /adminWorkload, in CSV order; any of
+// them can be sorted on
+var workloadColumns = []string{"admin_id", "admin_name", "assigned_open", "resolved", "average_resolution_hours", "reopened", "reopen_rate"}
+
+type AdminWorkloadRequest struct {
+	SecretCode string `json:"secret_code"`
+	From       string `json:"from,omitempty"`   // YYYY-MM-DD, UTC, inclusive; defaults to 6 days before To
+	To         string `json:"to,omitempty"`     // YYYY-MM-DD, UTC, inclusive; defaults to today
+	Sort       string `json:"sort,omitempty"`   // one of workloadColumns; resolved by default
+	Order      string `json:"order,omitempty"`  // asc or desc (default)
+	Format     string `json:"format,omitempty"` // json (default) or csv
+}
+
+// AdminWorkloadRow is what one admin has on their plate and has done in
+// the range. Resolved complaints count once confirmed, with
+// -confirm-resolutions; merged duplicates resolved along with their primary
+// do not count.
+type AdminWorkloadRow struct {
+	AdminID                int     `json:"admin_id"`
+	AdminName              string  `json:"admin_name"`
+	AssignedOpen           int     `json:"assigned_open"`            // now, whatever the range
+	Resolved               int     `json:"resolved"`                 // resolved by them in range
+	AverageResolutionHours float64 `json:"average_resolution_hours"` // from submission, of Resolved
+	Reopened               int     `json:"reopened"`                 // complaints they resolved in range whose owner then rejected the resolution
+	ReopenRate             float64 `json:"reopen_rate"`              // Reopened over every complaint they resolved in range
+}
+
+type AdminWorkload struct {
+	From   string             `json:"from"`
+	To     string             `json:"to"`
+	Admins []AdminWorkloadRow `json:"admins"`
+}
+
+// buildAdminWorkload computes a row for every admin viewer may see, active
+// or not, over the complaints of the same organizations. Archived
+// complaints count; deleted ones do not.
+func buildAdminWorkload(viewer *User, from, to time.Time) AdminWorkload {
+	workload := AdminWorkload{From: from.Format(dateLayout), To: to.Format(dateLayout), Admins: []AdminWorkloadRow{}}
+	inRange := func(timestamp string) bool {
+		t, err := parseTimestamp(timestamp)
+		if err != nil {
+			return false
+		}
+		day := t.UTC().Truncate(24 * time.Hour)
+		return !day.Before(from) && !day.After(to)
+	}
+
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	rows := make(map[int]*AdminWorkloadRow)
+	for _, user := range storage.users {
+		if user.IsAdmin && canSeeOrg(viewer, user.OrgID) {
+			rows[user.ID] = &AdminWorkloadRow{AdminID: user.ID, AdminName: user.Name}
+		}
+	}
+	totalResolution := make(map[int]time.Duration)
+	resolvedAny := make(map[int]int) // complaints each admin resolved in range, reopened or not
+
+	for _, complaint := range storage.complaints {
+		if complaint.IsDeleted || !canSeeOrg(viewer, complaint.OrgID) {
+			continue
+		}
+		if row, exists := rows[complaint.AssignedTo]; exists && isOpen(complaint) {
+			row.AssignedOpen++
+		}
+
+		// Who resolved the complaint in range, and whose resolution the
+		// owner rejected, each admin counted once per complaint
+		resolvers := make(map[int]bool)
+		reopeners := make(map[int]bool)
+		proposer, proposedInRange := 0, false
+		for _, entry := range complaint.History {
+			switch entry.Action {
+			case historyResolutionProposed:
+				proposer, proposedInRange = entry.ActorID, inRange(entry.At)
+				if proposedInRange {
+					resolvers[proposer] = true
+				}
+			case historyResolutionRejected:
+				if proposedInRange {
+					reopeners[proposer] = true
+				}
+			}
+		}
+		if complaint.MergedInto == 0 && complaint.ResolvedBy != 0 && inRange(complaint.ResolvedAt) {
+			resolvers[complaint.ResolvedBy] = true
+			if row, exists := rows[complaint.ResolvedBy]; exists && complaintStatus(complaint) == statusResolved {
+				row.Resolved++
+				created, createdErr := parseTimestamp(complaint.CreatedAt)
+				resolved, resolvedErr := parseTimestamp(complaint.ResolvedAt)
+				if createdErr == nil && resolvedErr == nil {
+					totalResolution[row.AdminID] += resolved.Sub(created)
+				}
+			}
+		}
+		for id := range resolvers {
+			resolvedAny[id]++
+		}
+		for id := range reopeners {
+			if row, exists := rows[id]; exists {
+				row.Reopened++
+			}
+		}
+	}
+
+	for id, row := range rows {
+		if row.Resolved > 0 {
+			row.AverageResolutionHours = roundTo(totalResolution[id].Hours()/float64(row.Resolved), 2)
+		}
+		if resolvedAny[id] > 0 {
+			row.ReopenRate = roundTo(float64(row.Reopened)/float64(resolvedAny[id]), 3)
+		}
+		workload.Admins = append(workload.Admins, *row)
+	}
+	return workload
+}
+
+// sortAdminWorkload orders rows by column, ties by admin ID
+func sortAdminWorkload(rows []AdminWorkloadRow, column string, descending bool) {
+	number := func(row AdminWorkloadRow) float64 {
+		switch column {
+		case "assigned_open":
+			return float64(row.AssignedOpen)
+		case "resolved":
+			return float64(row.Resolved)
+		case "average_resolution_hours":
+			return row.AverageResolutionHours
+		case "reopened":
+			return float64(row.Reopened)
+		case "reopen_rate":
+			return row.ReopenRate
+		}
+		return float64(row.AdminID)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		var less, greater bool
+		if column == "admin_name" {
+			nameA, nameB := strings.ToLower(a.AdminName), strings.ToLower(b.AdminName)
+			less, greater = nameA < nameB, nameA > nameB
+		} else {
+			less, greater = number(a) < number(b), number(a) > number(b)
+		}
+		if !less && !greater {
+			return a.AdminID < b.AdminID
+		}
+		if descending {
+			return greater
+		}
+		return less
+	})
+}
+
+// writeAdminWorkloadCSV renders the rows as one CSV table
+func writeAdminWorkloadCSV(w http.ResponseWriter, workload AdminWorkload) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="workload-%s-%s.csv"`, workload.From, workload.To))
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	out.Write(workloadColumns)
+	for _, row := range workload.Admins {
+		out.Write([]string{
+			strconv.Itoa(row.AdminID), row.AdminName, strconv.Itoa(row.AssignedOpen), strconv.Itoa(row.Resolved),
+			strconv.FormatFloat(row.AverageResolutionHours, 'f', 2, 64), strconv.Itoa(row.Reopened),
+			strconv.FormatFloat(row.ReopenRate, 'f', 3, 64),
+		})
+	}
+	out.Flush()
+}
+
+// /adminWorkload - Open assignments, resolutions and reopens per admin over
+// a date range (admin only)
+func adminWorkloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req AdminWorkloadRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Sort == "" {
+		req.Sort = "resolved"
+	}
+	if req.Order == "" {
+		req.Order = "desc"
+	}
+	var v validator
+	v.required("secret_code", req.SecretCode)
+	v.oneOf("sort", req.Sort, workloadColumns)
+	v.oneOf("order", req.Order, []string{"asc", "desc"})
+	if req.Format != "" {
+		v.oneOf("format", req.Format, []string{"json", "csv"})
+	}
+	from, to, err := parseReportRange(ReportRequest{From: req.From, To: req.To}, clock.Now())
+	v.check("range", err)
+	if apiErr := v.err(); apiErr != nil {
+		respondWithAPIError(w, apiErr)
+		return
+	}
+
+	user := authenticate(w, r, req.SecretCode)
+	if user == nil {
+		return
+	}
+
+	if !authorize(w, adminOnly(actorOf(user))) {
+		return
+	}
+
+	workload := buildAdminWorkload(user, from, to)
+	sortAdminWorkload(workload.Admins, req.Sort, req.Order == "desc")
+
+	if req.Format == "csv" {
+		writeAdminWorkloadCSV(w, workload)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Admin workload generated successfully",
+		Data:    workload,
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminWorkload(t *testing.T) {
+	srv := newTestServer(t)
+	disableSubmissionLimits()
+	fake := useFakeClock(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC))
+	_, code := registerTestUser(t, srv, "Owner", "owner@example.com")
+	aliceID, aliceCode := registerTestAdmin(t, srv, "Alice Admin", "alice@example.com")
+	bobID, bobCode := registerTestAdmin(t, srv, "Bob Admin", "bob@example.com")
+	carolID, _ := registerTestAdmin(t, srv, "Carol Admin", "carol@example.com")
+	defaultAdminID := storage.defaultAdminID
+
+	var complaints []Complaint
+	for i := 0; i < 6; i++ {
+		complaint := submitTestComplaint(t, srv, code, "Complaint "+strconv.Itoa(i+1), 5)
+		complaints = append(complaints, complaint)
+	}
+	assign := func(index, adminID int) {
+		storage.mutex.Lock()
+		storage.complaints[complaints[index].ID].AssignedTo = adminID
+		storage.mutex.Unlock()
+	}
+	resolve := func(index int, secret string) {
+		t.Helper()
+		if status, resp := postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: secret, ComplaintID: complaints[index].ID}); status != http.StatusOK {
+			t.Fatalf("Resolve %d: expected 200, got %d (%s)", index+1, status, resp.Error)
+		}
+	}
+	answer := func(endpoint string, index int) {
+		t.Helper()
+		if status, resp := postJSON(t, srv, endpoint, ResolutionAnswerRequest{SecretCode: code, ComplaintID: complaints[index].ID, Reason: "Still broken"}); status != http.StatusOK {
+			t.Fatalf("%s %d: expected 200, got %d (%s)", endpoint, index+1, status, resp.Error)
+		}
+	}
+
+	// Alice resolves 1 after 2 hours and 2 after 4 and keeps 3 open; Bob
+	// keeps 4 open
+	for index, adminID := range []int{aliceID, aliceID, aliceID, bobID, bobID} {
+		assign(index, adminID)
+	}
+	fake.Advance(2 * time.Hour)
+	resolve(0, aliceCode)
+	fake.Advance(2 * time.Hour)
+	resolve(1, aliceCode)
+
+	// Bob's resolution of 5 is rejected and Alice's confirmed after 6
+	// hours; Bob's resolution of 6 awaits its owner
+	config.ConfirmResolutions = true
+	resolve(4, bobCode)
+	answer("/rejectResolution", 4)
+	fake.Advance(2 * time.Hour)
+	resolve(4, aliceCode)
+	answer("/confirmResolution", 4)
+	resolve(5, bobCode)
+
+	getWorkload := func(req AdminWorkloadRequest) []AdminWorkloadRow {
+		t.Helper()
+		req.SecretCode = adminSecret
+		status, resp := postJSON(t, srv, "/adminWorkload", req)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", status, resp.Error)
+		}
+		var workload AdminWorkload
+		resp.decode(t, &workload)
+		return workload.Admins
+	}
+
+	t.Run("Rows", func(t *testing.T) {
+		rows := getWorkload(AdminWorkloadRequest{})
+		want := []AdminWorkloadRow{
+			{AdminID: aliceID, AdminName: "Alice Admin", AssignedOpen: 1, Resolved: 3, AverageResolutionHours: 4},
+			{AdminID: defaultAdminID, AdminName: "System Administrator"},
+			{AdminID: bobID, AdminName: "Bob Admin", AssignedOpen: 1, Reopened: 1, ReopenRate: 0.5},
+			{AdminID: carolID, AdminName: "Carol Admin"},
+		}
+		if len(rows) != len(want) {
+			t.Fatalf("Expected %d rows, got %+v", len(want), rows)
+		}
+		for i := range want {
+			if rows[i] != want[i] {
+				t.Errorf("Row %d: expected %+v, got %+v", i, want[i], rows[i])
+			}
+		}
+	})
+
+	t.Run("Sorting", func(t *testing.T) {
+		order := func(rows []AdminWorkloadRow) []int {
+			var ids []int
+			for _, row := range rows {
+				ids = append(ids, row.AdminID)
+			}
+			return ids
+		}
+		cases := []struct {
+			sort, order string
+			want        []int
+		}{
+			{"reopen_rate", "desc", []int{bobID, defaultAdminID, aliceID, carolID}},
+			{"admin_name", "asc", []int{aliceID, bobID, carolID, defaultAdminID}},
+			{"assigned_open", "asc", []int{defaultAdminID, carolID, aliceID, bobID}},
+		}
+		for _, tc := range cases {
+			got := order(getWorkload(AdminWorkloadRequest{Sort: tc.sort, Order: tc.order}))
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("Sort %s %s: expected %v, got %v", tc.sort, tc.order, tc.want, got)
+			}
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		rows := getWorkload(AdminWorkloadRequest{From: "2024-06-04", To: "2024-06-10"})
+		for _, row := range rows {
+			if row.Resolved != 0 || row.Reopened != 0 || row.ReopenRate != 0 {
+				t.Errorf("Expected no resolutions outside the range, got %+v", row)
+			}
+			if row.AdminID == aliceID && row.AssignedOpen != 1 {
+				t.Errorf("Expected open assignments regardless of range, got %+v", row)
+			}
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		httpResp, err := http.Post(srv.URL+"/adminWorkload", "application/json",
+			strings.NewReader(`{"secret_code":"ADMIN_SECRET_123","format":"csv"}`))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer httpResp.Body.Close()
+		if !strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/csv") {
+			t.Errorf("Expected text/csv, got %q", httpResp.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(httpResp.Body)
+		records, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+		if err != nil {
+			t.Fatalf("Invalid CSV: %v", err)
+		}
+		if strings.Join(records[0], ",") != strings.Join(workloadColumns, ",") {
+			t.Errorf("Unexpected header %v", records[0])
+		}
+		if got := strings.Join(records[1], ","); got != strconv.Itoa(aliceID)+",Alice Admin,1,3,4.00,0,0.000" {
+			t.Errorf("Unexpected first row %q", got)
+		}
+		if len(records) != 5 {
+			t.Errorf("Expected a header and 4 rows, got %d records", len(records))
+		}
+	})
+
+	t.Run("Validation And Access", func(t *testing.T) {
+		if status, _ := postJSON(t, srv, "/adminWorkload", AdminWorkloadRequest{SecretCode: code}); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a user, got %d", status)
+		}
+		for _, req := range []AdminWorkloadRequest{
+			{SecretCode: adminSecret, Sort: "title"},
+			{SecretCode: adminSecret, Order: "up"},
+			{SecretCode: adminSecret, Format: "xml"},
+			{SecretCode: adminSecret, From: "2024-06-10", To: "2024-06-01"},
+		} {
+			if status, resp := postJSON(t, srv, "/adminWorkload", req); status != http.StatusBadRequest || resp.ErrorCode != ErrCodeValidationFailed {
+				t.Errorf("%+v: expected 400 VALIDATION_FAILED, got %d %s", req, status, resp.ErrorCode)
+			}
+		}
+	})
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// /ws is a WebSocket (RFC 6455) on which a user hears about changes to their
+// own complaints as they happen, from the same event bus as /events. Only
+// what this needs is implemented: the server sends unfragmented text
+// frames, and clients send nothing but ping, pong and close.
+
+const (
+	wsGUID              = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxControlPayload = 125 // the most a control frame may carry, and all a client may send
+	wsWriteTimeout      = 10 * time.Second
+)
+
+// WebSocket opcodes
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// WebSocket close codes
+const (
+	wsCloseNormal        = 1000
+	wsCloseGoingAway     = 1001
+	wsCloseProtocolError = 1002
+	wsClosePolicy        = 1008 // the client fell too far behind, or its credentials were revoked
+	wsCloseTooBig        = 1009
+)
+
+var (
+	errWSProtocol = errors.New("websocket protocol error")
+	errWSTooBig   = errors.New("websocket frame too big")
+)
+
+// wsFrame is one frame read off a connection, unmasked
+type wsFrame struct {
+	opcode  byte
+	masked  bool
+	payload []byte
+}
+
+// WSMessage is the text frame sent for each event
+type WSMessage struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Complaint Complaint `json:"complaint"`
+	CreatedAt string    `json:"created_at"`
+}
+
+// readWSFrame reads a frame of at most maxPayload bytes, unmasking it
+func readWSFrame(r io.Reader, maxPayload int64) (wsFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return wsFrame{}, err
+	}
+	frame := wsFrame{opcode: header[0] & 0x0F, masked: header[1]&0x80 != 0}
+	if header[0]&0x70 != 0 {
+		return frame, errWSProtocol // no extensions were negotiated
+	}
+
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			return frame, errWSProtocol
+		}
+	}
+	if length > maxPayload {
+		return frame, errWSTooBig
+	}
+
+	var mask [4]byte
+	if frame.masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return frame, err
+		}
+	}
+	frame.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, frame.payload); err != nil {
+		return frame, err
+	}
+	if frame.masked {
+		for i := range frame.payload {
+			frame.payload[i] ^= mask[i%4]
+		}
+	}
+	return frame, nil
+}
+
+// writeWSFrame writes payload as one final frame. Servers never mask;
+// clients, such as the tests, must.
+func writeWSFrame(w io.Writer, opcode byte, payload []byte, mask bool) error {
+	frame := []byte{0x80 | opcode}
+	maskBit := byte(0)
+	if mask {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	if mask {
+		key := []byte{0x12, 0x34, 0x56, 0x78}
+		frame = append(frame, key...)
+		for i, b := range payload {
+			frame = append(frame, b^key[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// wsAccept is the Sec-WebSocket-Accept answer to a client's key
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHasToken reports whether the comma-separated header name lists
+// token, ignoring case
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isWebSocketUpgrade reports whether r asks to switch to a WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// wsConn is an open WebSocket. Frames may be written from any goroutine.
+type wsConn struct {
+	userID     int // whose credentials opened it
+	conn       net.Conn
+	writeMutex sync.Mutex
+	closeOnce  sync.Once
+	done       chan struct{}
+}
+
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return writeWSFrame(c.conn, opcode, payload, false)
+}
+
+// close sends a close frame with code and reason, if the connection still
+// takes one, and closes it. Only the first call does anything.
+func (c *wsConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		c.write(wsOpClose, append(payload, reason...))
+		c.conn.Close()
+		close(c.done)
+	})
+}
+
+// readLoop answers the client's pings and closes, and closes the connection
+// when nothing, not even a pong, arrives for pongWait
+func (c *wsConn) readLoop(r *bufio.Reader, pongWait time.Duration) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		frame, err := readWSFrame(r, wsMaxControlPayload)
+		switch {
+		case errors.Is(err, errWSTooBig):
+			c.close(wsCloseTooBig, "Clients may only send control frames")
+			return
+		case errors.Is(err, errWSProtocol):
+			c.close(wsCloseProtocolError, "Bad frame")
+			return
+		case err != nil:
+			// Gone away, or silent for too long
+			c.close(wsCloseGoingAway, "")
+			return
+		case !frame.masked:
+			c.close(wsCloseProtocolError, "Client frames must be masked")
+			return
+		}
+
+		switch frame.opcode {
+		case wsOpPing:
+			if c.write(wsOpPong, frame.payload) != nil {
+				c.close(wsCloseGoingAway, "")
+				return
+			}
+		case wsOpClose:
+			c.close(wsCloseNormal, "")
+			return
+		}
+		// Pongs only keep the connection alive, and messages are ignored
+	}
+}
+
+// WSHub keeps track of open WebSockets, which the HTTP server lets go of
+// once upgraded, so they can be closed when the server stops
+type WSHub struct {
+	conns  map[*wsConn]struct{}
+	closed bool
+	mutex  sync.Mutex
+	wg     sync.WaitGroup
+}
+
+func newWSHub() *WSHub {
+	return &WSHub{conns: make(map[*wsConn]struct{})}
+}
+
+var wsHub = newWSHub()
+
+// add registers c, or returns false once the hub has shut down
+func (h *WSHub) add(c *wsConn) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		return false
+	}
+	h.conns[c] = struct{}{}
+	h.wg.Add(1)
+	return true
+}
+
+func (h *WSHub) remove(c *wsConn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, exists := h.conns[c]; exists {
+		delete(h.conns, c)
+		h.wg.Done()
+	}
+}
+
+// closeUser closes the connections opened with userID's credentials, which
+// are only checked at the upgrade, once those credentials are revoked
+func (h *WSHub) closeUser(userID int, reason string) {
+	h.mutex.Lock()
+	var conns []*wsConn
+	for c := range h.conns {
+		if c.userID == userID {
+			conns = append(conns, c)
+		}
+	}
+	h.mutex.Unlock()
+
+	for _, c := range conns {
+		c.close(wsClosePolicy, reason)
+	}
+}
+
+func (h *WSHub) count() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.conns)
+}
+
+// shutdown closes every connection with 1001 and waits for their handlers
+// to return. Connections opened afterwards are refused.
+func (h *WSHub) shutdown() {
+	h.mutex.Lock()
+	h.closed = true
+	conns := make([]*wsConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mutex.Unlock()
+
+	for _, c := range conns {
+		c.close(wsCloseGoingAway, "Server shutting down")
+	}
+	h.wg.Wait()
+}
+
+// wsMessage is the frame for event, shaped for user
+func wsMessage(user *User, event Event) ([]byte, error) {
+	storage.mutex.RLock()
+	complaint := complaintForViewer(user, event.Complaint)
+	storage.mutex.RUnlock()
+	return json.Marshal(WSMessage{ID: event.ID, Type: event.Type, Complaint: complaint, CreatedAt: event.CreatedAt})
+}
+
+// /ws - WebSocket of changes to the caller's own complaints
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); !isWebSocketUpgrade(r) || err != nil || len(decoded) != 16 {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "WebSocket upgrade required")
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Only WebSocket version 13 is supported")
+		return
+	}
+
+	// Browsers cannot set headers on a WebSocket, so the secret may come
+	// from the query
+	secretCode := r.URL.Query().Get("secret_code")
+	if secretCode == "" {
+		secretCode = secretFromRequest(r)
+	}
+	if secretCode == "" {
+		respondWithError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Secret code is required")
+		return
+	}
+
+	user := authenticate(w, r, secretCode)
+	if user == nil {
+		return
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, ErrCodeInternal, "WebSockets not supported")
+		return
+	}
+	// The server's timeouts no longer apply; readLoop and write set their own
+	netConn.SetDeadline(time.Time{})
+
+	c := &wsConn{userID: user.ID, conn: netConn, done: make(chan struct{})}
+	if !wsHub.add(c) {
+		netConn.Close()
+		return
+	}
+	defer wsHub.remove(c)
+
+	// Subscribed before the handshake completes, so the client misses
+	// nothing published once it is connected
+	sub := eventBus.subscribe()
+	defer eventBus.unsubscribe(sub)
+	caller := actorOf(user)
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAccept(key))
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return
+	}
+
+	go c.readLoop(rw.Reader, 2*config.EventHeartbeat)
+
+	ping := time.NewTicker(config.EventHeartbeat)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case event, open := <-sub.events:
+			if !open {
+				// The send buffer filled up; the client will reconnect
+				c.close(wsClosePolicy, "Too slow")
+				return
+			}
+			if !caller.owns(&event.Complaint) {
+				continue
+			}
+			message, err := wsMessage(user, event)
+			if err != nil {
+				requestLogger(r).Error("WebSocket event not encoded", "event_id", event.ID, logKeyComplaintID, event.Complaint.ID, logKeyError, err)
+				continue
+			}
+			if c.write(wsOpText, message) != nil {
+				c.close(wsCloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			if c.write(wsOpPing, nil) != nil {
+				c.close(wsCloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// wsClient is the test's end of a /ws connection
+type wsClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialWS opens /ws on srv with secretCode and checks the handshake
+func dialWS(t *testing.T, srv *httptest.Server, secretCode string) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws?secret_code="+url.QueryEscape(secretCode), nil)
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("Writing the handshake failed: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatalf("Reading the handshake failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Expected the RFC 6455 accept value, got %q", accept)
+	}
+	return &wsClient{conn: conn, reader: reader}
+}
+
+// next returns the next frame other than a ping
+func (c *wsClient) next(t *testing.T) wsFrame {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		frame, err := readWSFrame(c.reader, 1<<20)
+		if err != nil {
+			t.Fatalf("Reading a frame failed: %v", err)
+		}
+		if frame.masked {
+			t.Fatal("Expected server frames to be unmasked")
+		}
+		if frame.opcode != wsOpPing {
+			return frame
+		}
+	}
+}
+
+// waitForWSUser waits until a connection of userID is registered with wsHub
+func waitForWSUser(t *testing.T, userID int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		wsHub.mutex.Lock()
+		for c := range wsHub.conns {
+			if c.userID == userID {
+				wsHub.mutex.Unlock()
+				return
+			}
+		}
+		wsHub.mutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("No WebSocket of user %d registered", userID)
+}
+
+func TestWebSocket(t *testing.T) {
+	srv := newTestServer(t)
+	_, code := registerTestUser(t, srv, "Live User", "live@example.com")
+	_, otherCode := registerTestUser(t, srv, "Other User", "other@example.com")
+
+	t.Run("Handshake Errors", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/ws?secret_code=" + code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 without an upgrade, got %d", resp.StatusCode)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws?secret_code=nope", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for a bad secret code, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Own Complaint Resolved", func(t *testing.T) {
+		mine := submitTestComplaint(t, srv, code, "Flickering lights", 6)
+		theirs := submitTestComplaint(t, srv, otherCode, "Someone else's", 6)
+		client := dialWS(t, srv, code)
+
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: theirs.ID})
+		postJSON(t, srv, "/resolveComplaint", ResolveComplaintRequest{SecretCode: adminSecret, ComplaintID: mine.ID, Note: "New ballast"})
+
+		frame := client.next(t)
+		if frame.opcode != wsOpText {
+			t.Fatalf("Expected a text frame, got opcode %d", frame.opcode)
+		}
+		var message WSMessage
+		if err := json.Unmarshal(frame.payload, &message); err != nil {
+			t.Fatalf("Expected JSON, got %v: %s", err, frame.payload)
+		}
+		if message.Type != eventComplaintResolved || message.Complaint.ID != mine.ID {
+			t.Fatalf("Expected only the user's own complaint, got %s for %d", message.Type, message.Complaint.ID)
+		}
+		if message.Complaint.Status != statusResolved || message.Complaint.ResolutionNote != "New ballast" {
+			t.Errorf("Expected the resolved complaint, got %+v", message.Complaint)
+		}
+		if message.Complaint.History != nil || message.Complaint.User != nil {
+			t.Errorf("Expected the complaint shaped for its owner, got %+v", message.Complaint)
+		}
+	})
+
+	t.Run("Comment On Own Complaint", func(t *testing.T) {
+		commenterID, commenter := registerTestUser(t, srv, "Commenter", "commenter@example.com")
+		mine := submitTestComplaint(t, srv, commenter, "Noisy pipes", 5)
+		client := dialWS(t, srv, commenter)
+		waitForWSUser(t, commenterID)
+
+		if status, resp := postJSON(t, srv, "/addComment", AddCommentRequest{SecretCode: adminSecret, ComplaintID: mine.ID, Body: "A plumber is on the way"}); status != http.StatusCreated {
+			t.Fatalf("Expected 201 commenting, got %d (%s)", status, resp.Error)
+		}
+		var message WSMessage
+		if err := json.Unmarshal(client.next(t).payload, &message); err != nil {
+			t.Fatalf("Expected JSON, got %v", err)
+		}
+		if message.Type != eventComplaintCommented || message.Complaint.ID != mine.ID {
+			t.Fatalf("Expected %s for %d, got %s for %d", eventComplaintCommented, mine.ID, message.Type, message.Complaint.ID)
+		}
+		if n := len(message.Complaint.Comments); n != 1 || message.Complaint.Comments[0].Body != "A plumber is on the way" {
+			t.Errorf("Expected the admin's comment on the complaint, got %+v", message.Complaint.Comments)
+		}
+	})
+
+	t.Run("Ping And Close", func(t *testing.T) {
+		client := dialWS(t, srv, code)
+		if err := writeWSFrame(client.conn, wsOpPing, []byte("hello"), true); err != nil {
+			t.Fatal(err)
+		}
+		if frame := client.next(t); frame.opcode != wsOpPong || string(frame.payload) != "hello" {
+			t.Errorf("Expected a pong echoing the ping, got opcode %d %q", frame.opcode, frame.payload)
+		}
+
+		if err := writeWSFrame(client.conn, wsOpText, []byte("unmasked"), false); err != nil {
+			t.Fatal(err)
+		}
+		frame := client.next(t)
+		if frame.opcode != wsOpClose || binary.BigEndian.Uint16(frame.payload) != wsCloseProtocolError {
+			t.Errorf("Expected close 1002 for an unmasked frame, got opcode %d %v", frame.opcode, frame.payload)
+		}
+	})
+
+	t.Run("Closed On Rotation", func(t *testing.T) {
+		rotatingID, rotating := registerTestUser(t, srv, "Rotating User", "rotating@example.com")
+		client := dialWS(t, srv, rotating)
+		waitForWSUser(t, rotatingID)
+
+		if status, resp := postJSON(t, srv, "/rotateSecretCode", RotateSecretCodeRequest{SecretCode: rotating}); status != http.StatusOK {
+			t.Fatalf("Expected 200 rotating, got %d (%s)", status, resp.Error)
+		}
+		frame := client.next(t)
+		if frame.opcode != wsOpClose || binary.BigEndian.Uint16(frame.payload) != wsClosePolicy {
+			t.Errorf("Expected close 1008 once the secret code is rotated, got opcode %d %v", frame.opcode, frame.payload)
+		}
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		client := dialWS(t, srv, code)
+		deadline := time.Now().Add(2 * time.Second)
+		for wsHub.count() == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		wsHub.shutdown()
+		frame := client.next(t)
+		if frame.opcode != wsOpClose || binary.BigEndian.Uint16(frame.payload) != wsCloseGoingAway {
+			t.Errorf("Expected close 1001 on shutdown, got opcode %d %v", frame.opcode, frame.payload)
+		}
+		if n := wsHub.count(); n != 0 {
+			t.Errorf("Expected no connections left, got %d", n)
+		}
+
+		resp, err := http.Get(srv.URL + "/health/live")
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected the server to keep serving HTTP, got %v", err)
+		}
+		resp.Body.Close()
+	})
+}